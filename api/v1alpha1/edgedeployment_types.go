@@ -38,6 +38,32 @@ type EdgeDeploymentSpec struct {
 	// from this endpoint. This key is what is defined on the edgedevice
 	// logCollection property
 	LogCollection string `json:"logCollection,omitempty"`
+
+	// DependsOn lists names of EdgeDeployments in the same namespace that have to be
+	// running on the device before this deployment is started
+	DependsOn []string `json:"dependsOn,omitempty"`
+
+	// Placement constrains the devices, out of the ones matching the selector, the deployment is deployed to
+	Placement *PlacementConfiguration `json:"placement,omitempty"`
+}
+
+type PlacementConfiguration struct {
+	// MaxDevices is the maximum number of devices the deployment is deployed to; unlimited when not set
+	// +kubebuilder:validation:Minimum=0
+	MaxDevices int32 `json:"maxDevices,omitempty"`
+
+	// Spread limits the number of devices with the same value of a label the deployment is deployed to
+	Spread *SpreadConstraint `json:"spread,omitempty"`
+}
+
+type SpreadConstraint struct {
+	// LabelKey is the device label the devices are spread by, e.g. site
+	LabelKey string `json:"labelKey"`
+
+	// MaxPerValue is the maximum number of devices with the same value of the label
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=1
+	MaxPerValue int32 `json:"maxPerValue,omitempty"`
 }
 
 type ImageRegistriesConfiguration struct {
@@ -75,6 +101,13 @@ type ContainerMetricsConfiguration struct {
 	// Specification of workload metrics to be collected
 	AllowList *NameRef `json:"allowList,omitempty"`
 
+	// MetricsAllowList is the name of a MetricsAllowList of workload metrics to be collected, in addition to the
+	// ones of AllowList
+	MetricsAllowList *NameRef `json:"metricsAllowList,omitempty"`
+
+	// AllowListOverrides adjusts the workload metrics of the allow-lists for the deployment
+	AllowListOverrides *MetricsAllowListOverrides `json:"allowListOverrides,omitempty"`
+
 	Containers map[string]*MetricsConfigEntity `json:"containers,omitempty"`
 }
 
@@ -84,6 +117,39 @@ type NameRef struct {
 
 type DataConfiguration struct {
 	Paths []DataPath `json:"paths,omitempty"`
+
+	// Schedule defines when the data is uploaded; the device default applies when not set
+	Schedule *DataUploadSchedule `json:"schedule,omitempty"`
+
+	// Include lists glob patterns of files to upload; all files are uploaded when empty
+	Include []string `json:"include,omitempty"`
+
+	// Exclude lists glob patterns of files that are never uploaded
+	Exclude []string `json:"exclude,omitempty"`
+
+	// MaxSizeMiB is the maximum size of data uploaded in a single run
+	// +kubebuilder:validation:Minimum=0
+	MaxSizeMiB int32 `json:"maxSizeMiB,omitempty"`
+
+	// Compression applied to files before upload
+	// +kubebuilder:validation:Enum=none;gzip
+	Compression string `json:"compression,omitempty"`
+
+	// DeleteAfterUpload instructs the device to delete files once they were uploaded successfully
+	DeleteAfterUpload bool `json:"deleteAfterUpload,omitempty"`
+
+	// BucketPrefix is prepended to all targets in the device bucket, allowing data of
+	// different workloads to be kept apart
+	BucketPrefix string `json:"bucketPrefix,omitempty"`
+}
+
+type DataUploadSchedule struct {
+	// IntervalSeconds is the period of the upload; mutually exclusive with Cron
+	// +kubebuilder:validation:Minimum=1
+	IntervalSeconds int32 `json:"intervalSeconds,omitempty"`
+
+	// Cron is a standard 5-field cron expression; mutually exclusive with IntervalSeconds
+	Cron string `json:"cron,omitempty"`
 }
 
 type DataPath struct {
@@ -103,6 +169,35 @@ const (
 
 // EdgeDeploymentStatus defines the observed state of EdgeDeployment
 type EdgeDeploymentStatus struct {
+	// DataUploadErrors lists devices that reported a failure of the latest data upload of this deployment
+	DataUploadErrors []DataUploadError `json:"dataUploadErrors,omitempty"`
+
+	// UnplacedDevices lists devices matching the selector the deployment is not deployed to, with the reason.
+	// The list is limited to the first 100 devices by name.
+	UnplacedDevices []UnplacedDevice `json:"unplacedDevices,omitempty"`
+
+	// DependencyCycle lists the deployments, starting with this one, whose dependencies form a cycle; none of them
+	// can be started on a device
+	DependencyCycle []string `json:"dependencyCycle,omitempty"`
+}
+
+type UnplacedDevice struct {
+	// Device is the name of the EdgeDevice
+	Device string `json:"device"`
+
+	// Reason why the deployment is not deployed to the device
+	Reason string `json:"reason"`
+}
+
+type DataUploadError struct {
+	// Device is the name of the EdgeDevice that reported the error
+	Device string `json:"device"`
+
+	// Message is the error reported by the device
+	Message string `json:"message"`
+
+	// Time when the error was first reported
+	Time metav1.Time `json:"time"`
 }
 
 //+kubebuilder:object:root=true
@@ -20,8 +20,10 @@ package v1alpha1
 import (
 	"errors"
 	"fmt"
+	"path"
 	"strings"
 
+	"github.com/robfig/cron/v3"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -63,26 +65,13 @@ func (r *EdgeDeployment) validate() error {
 	containers := append(podSpec.InitContainers, podSpec.Containers...)
 	containersNames := make(map[string]struct{})
 	for _, container := range containers {
-		if container.Lifecycle != nil {
-			notValidPaths = append(notValidPaths, containersMsg(container, "lifecycle"))
-		}
-		if container.LivenessProbe != nil {
-			notValidPaths = append(notValidPaths, containersMsg(container, "livenessProbe"))
-		}
-		if container.ReadinessProbe != nil {
-			notValidPaths = append(notValidPaths, containersMsg(container, "readinessProbe"))
-		}
-		if container.StartupProbe != nil {
-			notValidPaths = append(notValidPaths, containersMsg(container, "startupProbe"))
-		}
 		if len(container.VolumeDevices) != 0 {
 			notValidPaths = append(notValidPaths, containersMsg(container, "volumeDevices"))
 		}
-		if len(container.Resources.Limits) != 0 {
-			notValidPaths = append(notValidPaths, containersMsg(container, "resources.limits"))
-		}
-		if len(container.Resources.Requests) != 0 {
-			notValidPaths = append(notValidPaths, containersMsg(container, "resources.requests"))
+		for name, request := range container.Resources.Requests {
+			if limit, ok := container.Resources.Limits[name]; ok && request.Cmp(limit) > 0 {
+				return fmt.Errorf("container '%s' requests more %s than its limit", container.Name, name)
+			}
 		}
 
 		for _, envVar := range container.Env {
@@ -114,6 +103,49 @@ func (r *EdgeDeployment) validate() error {
 			strings.Join(notValidPaths, ","))
 	}
 
+	err := validateDependencies(r.Name, r.Spec.DependsOn)
+	if err != nil {
+		return err
+	}
+
+	return validateDataConfiguration(r.Spec.Data)
+}
+
+func validateDependencies(name string, dependsOn []string) error {
+	dependencies := make(map[string]struct{})
+	for _, dependency := range dependsOn {
+		if dependency == name {
+			return fmt.Errorf("deployment '%s' cannot depend on itself", name)
+		}
+		if _, ok := dependencies[dependency]; ok {
+			return fmt.Errorf("dependency '%s' is listed more than once", dependency)
+		}
+		dependencies[dependency] = struct{}{}
+	}
+	return nil
+}
+
+func validateDataConfiguration(data *DataConfiguration) error {
+	if data == nil {
+		return nil
+	}
+	if schedule := data.Schedule; schedule != nil {
+		if schedule.IntervalSeconds != 0 && schedule.Cron != "" {
+			return errors.New("data.schedule.intervalSeconds and data.schedule.cron are mutually exclusive")
+		}
+		if schedule.Cron != "" {
+			if _, err := cron.ParseStandard(schedule.Cron); err != nil {
+				return fmt.Errorf("data.schedule.cron '%s' is not valid: %v", schedule.Cron, err)
+			}
+		}
+	}
+	for _, patterns := range [][]string{data.Include, data.Exclude} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("data upload glob pattern '%s' is not valid: %v", pattern, err)
+			}
+		}
+	}
 	return nil
 }
 
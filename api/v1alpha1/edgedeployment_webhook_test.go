@@ -102,7 +102,7 @@ var _ = Describe("EdgeDeployment Webhook", func() {
 			Expect(err).NotTo(HaveOccurred())
 		})
 
-		table.DescribeTable("test all invalid fields", func(editEdgeDeployment func()) {
+		table.DescribeTable("test all supported fields", func(editEdgeDeployment func()) {
 			// given
 			editEdgeDeployment()
 
@@ -111,8 +111,8 @@ var _ = Describe("EdgeDeployment Webhook", func() {
 			errUpdate := edgeDeployment.ValidateUpdate(nil)
 
 			// then
-			Expect(errCreate).To(HaveOccurred())
-			Expect(errUpdate).To(HaveOccurred())
+			Expect(errCreate).NotTo(HaveOccurred())
+			Expect(errUpdate).NotTo(HaveOccurred())
 		},
 			table.Entry("container.lifecycle", func() {
 				podSpec.Containers[0].Lifecycle = &corev1.Lifecycle{}
@@ -126,9 +126,6 @@ var _ = Describe("EdgeDeployment Webhook", func() {
 			table.Entry("container.startupProbe", func() {
 				podSpec.Containers[0].StartupProbe = &corev1.Probe{}
 			}),
-			table.Entry("container.volumeDevices", func() {
-				podSpec.Containers[0].VolumeDevices = []corev1.VolumeDevice{{}}
-			}),
 			table.Entry("container.resources.limits", func() {
 				podSpec.Containers[0].Resources.Limits = corev1.ResourceList{
 					corev1.ResourceCPU: *resource.NewQuantity(0, resource.BinarySI),
@@ -139,6 +136,31 @@ var _ = Describe("EdgeDeployment Webhook", func() {
 					corev1.ResourceCPU: *resource.NewQuantity(0, resource.BinarySI),
 				}
 			}),
+		)
+
+		table.DescribeTable("test all invalid fields", func(editEdgeDeployment func()) {
+			// given
+			editEdgeDeployment()
+
+			// when
+			errCreate := edgeDeployment.ValidateCreate()
+			errUpdate := edgeDeployment.ValidateUpdate(nil)
+
+			// then
+			Expect(errCreate).To(HaveOccurred())
+			Expect(errUpdate).To(HaveOccurred())
+		},
+			table.Entry("container.volumeDevices", func() {
+				podSpec.Containers[0].VolumeDevices = []corev1.VolumeDevice{{}}
+			}),
+			table.Entry("container.resources.requests exceeding limits", func() {
+				podSpec.Containers[0].Resources.Requests = corev1.ResourceList{
+					corev1.ResourceMemory: resource.MustParse("256Mi"),
+				}
+				podSpec.Containers[0].Resources.Limits = corev1.ResourceList{
+					corev1.ResourceMemory: resource.MustParse("128Mi"),
+				}
+			}),
 			table.Entry("container.env.valueFrom.fieldRef", func() {
 				podSpec.Containers[0].Env = []corev1.EnvVar{
 					{
@@ -169,8 +191,59 @@ var _ = Describe("EdgeDeployment Webhook", func() {
 					},
 				}
 			}),
+			table.Entry("data.schedule with both interval and cron", func() {
+				edgeDeployment.Spec.Data = &v1alpha1.DataConfiguration{
+					Schedule: &v1alpha1.DataUploadSchedule{IntervalSeconds: 60, Cron: "0 * * * *"},
+				}
+			}),
+			table.Entry("data.schedule.cron", func() {
+				edgeDeployment.Spec.Data = &v1alpha1.DataConfiguration{
+					Schedule: &v1alpha1.DataUploadSchedule{Cron: "every hour"},
+				}
+			}),
+			table.Entry("data.include", func() {
+				edgeDeployment.Spec.Data = &v1alpha1.DataConfiguration{Include: []string{"[a-"}}
+			}),
+			table.Entry("data.exclude", func() {
+				edgeDeployment.Spec.Data = &v1alpha1.DataConfiguration{Exclude: []string{"*.log", "[]a]"}}
+			}),
+			table.Entry("dependsOn itself", func() {
+				edgeDeployment.Name = "workload"
+				edgeDeployment.Spec.DependsOn = []string{"database", "workload"}
+			}),
+			table.Entry("dependsOn duplicate", func() {
+				edgeDeployment.Spec.DependsOn = []string{"database", "database"}
+			}),
 		)
 
+		It("create EdgeDeployment with valid data upload configuration", func() {
+			// given
+			edgeDeployment.Spec.Data = &v1alpha1.DataConfiguration{
+				Paths:    []v1alpha1.DataPath{{Source: "stats", Target: "statistics"}},
+				Schedule: &v1alpha1.DataUploadSchedule{Cron: "*/15 * * * *"},
+				Include:  []string{"*.csv"},
+				Exclude:  []string{"tmp-*"},
+			}
+
+			// when
+			err := edgeDeployment.ValidateCreate()
+
+			// then
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("create EdgeDeployment with dependencies", func() {
+			// given
+			edgeDeployment.Name = "workload"
+			edgeDeployment.Spec.DependsOn = []string{"database", "cache"}
+
+			// when
+			err := edgeDeployment.ValidateCreate()
+
+			// then
+			Expect(err).NotTo(HaveOccurred())
+		})
+
 		It("reuse container name", func() {
 			// given
 			podSpec.Containers = append(edgeDeployment.Spec.Pod.Spec.Containers,
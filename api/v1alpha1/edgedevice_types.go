@@ -38,12 +38,80 @@ type EdgeDeviceSpec struct {
 	Storage       *Storage                        `json:"storage,omitempty"`
 	Metrics       *MetricsConfiguration           `json:"metrics,omitempty"`
 	LogCollection map[string]*LogCollectionConfig `json:"logCollection,omitempty"`
+
+	// MaintenanceWindow restricts delivery of workload and OS changes to the device to the window
+	MaintenanceWindow *MaintenanceWindow `json:"maintenanceWindow,omitempty"`
+
+	// Decommission requests the device to be decommissioned: its data is uploaded one last time, its certificate
+	// is revoked and the EdgeDevice is deleted
+	Decommission *Decommission `json:"decommission,omitempty"`
+
+	// RegistrationApproved approves the registration of a device registered with a RegistrationToken requiring
+	// approval: the device gets its certificate once it is set
+	RegistrationApproved bool `json:"registrationApproved,omitempty"`
+
+	// Site is the name of the Site of the same namespace the device is located at
+	Site string `json:"site,omitempty"`
+
+	// Twin holds the desired properties of the device twin, delivered to the device in its configuration
+	Twin *DeviceTwin `json:"twin,omitempty"`
+}
+
+type DeviceTwin struct {
+	// Desired properties of the device, e.g. application settings
+	// +kubebuilder:validation:MaxProperties=256
+	Desired map[string]string `json:"desired,omitempty"`
 }
 
+type DataRetentionPolicy string
+
+const (
+	// RetainData keeps the Object Bucket Claim of a decommissioned device
+	RetainData DataRetentionPolicy = "Retain"
+	// DeleteData deletes the Object Bucket Claim of a decommissioned device
+	DeleteData DataRetentionPolicy = "Delete"
+)
+
+type Decommission struct {
+	// TimeoutSeconds is how long to wait for the final data upload of the device before decommissioning it anyway
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:default=3600
+	TimeoutSeconds int32 `json:"timeoutSeconds,omitempty"`
+
+	// Force decommissions the device without waiting for the final data upload, e.g. when the device is unreachable
+	Force bool `json:"force,omitempty"`
+
+	// DataRetention is what happens to the Object Bucket Claim of the device: Retain keeps it, Delete deletes it
+	// +kubebuilder:validation:Enum=Retain;Delete
+	// +kubebuilder:default=Retain
+	DataRetention DataRetentionPolicy `json:"dataRetention,omitempty"`
+}
+
+type MaintenanceWindow struct {
+	// Schedule is a standard 5-field cron expression of the start of the window
+	Schedule string `json:"schedule"`
+
+	// DurationMinutes is the length of the window
+	// +kubebuilder:validation:Minimum=1
+	DurationMinutes int32 `json:"durationMinutes"`
+
+	// TimeZone is the IANA time zone the schedule is evaluated in, e.g. Europe/Madrid; UTC when not set
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+const (
+	// LogCollectionSyslog ships the logs from the device to the syslog server of the SyslogConfig
+	LogCollectionSyslog = "syslog"
+
+	// LogCollectionYggdrasil ships the logs through the yggdrasil channel to the operator, which forwards them to its
+	// log sink
+	LogCollectionYggdrasil = "yggdrasil"
+)
+
 type LogCollectionConfig struct {
 
 	// Kind is the type of log collection to be used
-	// +kubebuilder:validation:Enum=syslog
+	// +kubebuilder:validation:Enum=syslog;yggdrasil
 	Kind string `json:"kind,omitempty"`
 
 	// +kubebuilder:default=12
@@ -52,6 +120,38 @@ type LogCollectionConfig struct {
 
 	// SyslogConfig is the pointer to the configMap to be used to load the config
 	SyslogConfig *NameRef `json:"syslogConfig,omitempty"`
+
+	// SyslogOptions configures the messages sent to the syslog server of the SyslogConfig and the connection to it
+	SyslogOptions *SyslogOptions `json:"syslogOptions,omitempty"`
+}
+
+type SyslogOptions struct {
+	// Format of the messages: rfc5424, or the BSD syslog format of rfc3164
+	// +kubebuilder:validation:Enum=rfc3164;rfc5424
+	Format string `json:"format,omitempty"`
+
+	// Framing of the messages sent over TCP: octet-counting prefixes each message with its length (RFC 6587),
+	// non-transparent terminates each message with a newline
+	// +kubebuilder:validation:Enum=octet-counting;non-transparent
+	Framing string `json:"framing,omitempty"`
+
+	// Facility of the messages
+	// +kubebuilder:validation:Enum=kern;user;mail;daemon;auth;syslog;lpr;news;uucp;cron;authpriv;ftp;local0;local1;local2;local3;local4;local5;local6;local7
+	Facility string `json:"facility,omitempty"`
+
+	// Tag identifies the sender of the messages: the TAG of RFC 3164 messages and the APP-NAME of RFC 5424 ones
+	// +kubebuilder:validation:MaxLength=32
+	// +kubebuilder:validation:Pattern=`^[!-~]+$`
+	Tag string `json:"tag,omitempty"`
+
+	// TLS sends the messages over TLS; the protocol of the SyslogConfig must be tcp
+	TLS *SyslogTLS `json:"tls,omitempty"`
+}
+
+type SyslogTLS struct {
+	// SecretRef is the Secret holding the CA bundle the server is verified with in its ca.crt key, and optionally the
+	// client certificate and key the device authenticates with in its tls.crt and tls.key keys
+	SecretRef NameRef `json:"secretRef"`
 }
 
 type MetricsConfiguration struct {
@@ -69,6 +169,13 @@ type SystemMetricsConfiguration struct {
 	// list of system metrics that should be scraped
 	AllowList *NameRef `json:"allowList,omitempty"`
 
+	// MetricsAllowList is the name of a MetricsAllowList of system metrics that should be scraped, in addition to
+	// the ones of AllowList
+	MetricsAllowList *NameRef `json:"metricsAllowList,omitempty"`
+
+	// AllowListOverrides adjusts the system metrics of the allow-lists for the device
+	AllowListOverrides *MetricsAllowListOverrides `json:"allowListOverrides,omitempty"`
+
 	// Disabled when set to true instructs the device to turn off system metrics collection
 	Disabled bool `json:"disabled,omitempty"`
 }
@@ -150,6 +257,24 @@ type S3StorageConfiguration struct {
 	AWSSecretAccessKey string `json:"awsSecretAccessKey,omitempty"`
 }
 
+// DeviceAttestation is the identity of the TPM of a device, verified against the attestation policy
+type DeviceAttestation struct {
+	// Time the attestation was verified
+	Time metav1.Time `json:"time"`
+
+	// EndorsementKeyCertificate is the hex encoded SHA-256 fingerprint of the endorsement key certificate of the TPM
+	EndorsementKeyCertificate string `json:"endorsementKeyCertificate"`
+
+	// EndorsementKeyCertificateCA is the issuer of the endorsement key certificate
+	EndorsementKeyCertificateCA string `json:"endorsementKeyCertificateCA,omitempty"`
+
+	// AttestationKeyName is the hex encoded TPM name of the attestation key that signed the quote
+	AttestationKeyName string `json:"attestationKeyName"`
+
+	// PCRs are the hex encoded values of the quoted SHA-256 PCRs, by index
+	PCRs map[string]string `json:"pcrs,omitempty"`
+}
+
 // EdgeDeviceStatus defines the observed state of EdgeDevice
 type EdgeDeviceStatus struct {
 	Phase                     string              `json:"phase,omitempty"`
@@ -159,6 +284,174 @@ type EdgeDeviceStatus struct {
 	Deployments               []Deployment        `json:"deployments,omitempty"`
 	DataOBC                   *string             `json:"dataObc,omitempty"`
 	UpgradeInformation        *UpgradeInformation `json:"upgradeInformation,omitempty"`
+
+	// HardwareChanges is the bounded history of hardware inventory changes
+	// reported by the device, the oldest change first
+	HardwareChanges []HardwareChange `json:"hardwareChanges,omitempty"`
+
+	// HardwareTampering is set when a tampering-sensitive hardware change has
+	// been detected. No configuration is delivered to the device while it is
+	// set; it has to be removed from the status to unblock the device
+	HardwareTampering *HardwareTampering `json:"hardwareTampering,omitempty"`
+
+	// EffectiveConfiguration is the configuration delivered to a device that is a member of an EdgeDeviceSet:
+	// the configuration of the set merged with the one of the device
+	EffectiveConfiguration *EffectiveConfiguration `json:"effectiveConfiguration,omitempty"`
+
+	// DeliveredConfiguration is the workload and OS configuration last delivered to a device with a maintenance
+	// window; it keeps being delivered to the device outside the window
+	DeliveredConfiguration *DeliveredConfiguration `json:"deliveredConfiguration,omitempty"`
+
+	// PendingChanges is set when workload or OS changes are held back until the next maintenance window
+	PendingChanges *PendingChanges `json:"pendingChanges,omitempty"`
+
+	// Decommission is set while the device is being decommissioned
+	Decommission *DecommissionStatus `json:"decommission,omitempty"`
+
+	// DesiredConfiguration identifies the configuration the device is expected to run; it is recomputed when the
+	// device or the objects its configuration refers to change
+	DesiredConfiguration *DesiredConfiguration `json:"desiredConfiguration,omitempty"`
+
+	// RegistrationToken is the name of the RegistrationToken the device registered with
+	RegistrationToken string `json:"registrationToken,omitempty"`
+
+	// Attestation is the TPM attestation verified when the device registered
+	Attestation *DeviceAttestation `json:"attestation,omitempty"`
+
+	// Twin holds the reported properties of the device twin and their difference with the desired ones, as of the
+	// last heartbeat of the device
+	Twin *DeviceTwinStatus `json:"twin,omitempty"`
+
+	// Conditions of the device, e.g. ConfigurationSynced
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+const (
+	// ConfigurationSyncedCondition is true when the device reports the version of its desired configuration; the
+	// last transition time of a false condition is when the device went out of sync
+	ConfigurationSyncedCondition = "ConfigurationSynced"
+)
+
+type DeviceTwinStatus struct {
+	// DesiredVersion is the version of the desired properties of the device
+	DesiredVersion string `json:"desiredVersion,omitempty"`
+
+	// AppliedDesiredVersion is the version of the desired properties the device reports to have applied
+	AppliedDesiredVersion string `json:"appliedDesiredVersion,omitempty"`
+
+	// ReportedVersion is the version of the reported properties, set by the device
+	ReportedVersion string `json:"reportedVersion,omitempty"`
+
+	// Reported properties of the device, e.g. firmware versions or sensor calibration
+	Reported map[string]string `json:"reported,omitempty"`
+
+	// ReportedChangeTime is the time the reported properties last changed
+	ReportedChangeTime *metav1.Time `json:"reportedChangeTime,omitempty"`
+
+	// Diff lists the desired properties the device does not report with their desired value, sorted by name
+	Diff []TwinPropertyDiff `json:"diff,omitempty"`
+
+	// InSync is true when the device has applied the desired properties and reports all of them with their desired
+	// value
+	InSync bool `json:"inSync"`
+}
+
+type TwinPropertyDiff struct {
+	Name    string `json:"name"`
+	Desired string `json:"desired"`
+
+	// Reported value of the property; not set when the device does not report it
+	Reported *string `json:"reported,omitempty"`
+}
+
+type DesiredConfiguration struct {
+	// Hash of the configuration rendered for the device
+	Hash string `json:"hash"`
+
+	// ChangeTime is the time the hash last changed
+	ChangeTime metav1.Time `json:"changeTime"`
+}
+
+type DecommissionStatus struct {
+	// StartTime is the time the decommissioning started; the device has to upload its data after it
+	StartTime metav1.Time `json:"startTime"`
+}
+
+type DeliveredConfiguration struct {
+	// Deployments are the EdgeDeployments delivered to the device
+	Deployments []DeliveredDeployment `json:"deployments,omitempty"`
+
+	// OsInformation is the OS configuration delivered to the device
+	OsInformation *OsInformation `json:"osInformation,omitempty"`
+}
+
+type DeliveredDeployment struct {
+	// Name of the EdgeDeployment
+	Name string `json:"name"`
+
+	// Spec of the EdgeDeployment at the time it was delivered
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Spec EdgeDeploymentSpec `json:"spec"`
+}
+
+type PendingChanges struct {
+	// Since is the time the changes were first held back
+	Since metav1.Time `json:"since"`
+
+	// NextWindow is the start of the next maintenance window
+	NextWindow *metav1.Time `json:"nextWindow,omitempty"`
+}
+
+type EffectiveConfiguration struct {
+	// DeviceSet is the name of the EdgeDeviceSet the device is a member of
+	DeviceSet string `json:"deviceSet"`
+
+	Heartbeat         *HeartbeatConfiguration         `json:"heartbeat,omitempty"`
+	Storage           *Storage                        `json:"storage,omitempty"`
+	Metrics           *MetricsConfiguration           `json:"metrics,omitempty"`
+	LogCollection     map[string]*LogCollectionConfig `json:"logCollection,omitempty"`
+	MaintenanceWindow *MaintenanceWindow              `json:"maintenanceWindow,omitempty"`
+}
+
+type HardwareChangeType string
+
+const (
+	HardwareAdded    HardwareChangeType = "Added"
+	HardwareRemoved  HardwareChangeType = "Removed"
+	HardwareModified HardwareChangeType = "Modified"
+)
+
+type HardwareChange struct {
+	// Time when the change was detected
+	Time metav1.Time `json:"time"`
+
+	// Component is the hardware component that changed (disk, memory, interface, etc.)
+	Component string `json:"component"`
+
+	// ID identifies the component instance, e.g. disk ID or interface MAC address
+	ID string `json:"id,omitempty"`
+
+	// Type of the change
+	// +kubebuilder:validation:Enum=Added;Removed;Modified
+	Type HardwareChangeType `json:"type"`
+
+	// Description is a human readable description of the change
+	Description string `json:"description,omitempty"`
+
+	// Sensitive is true when the change may indicate that the device has been tampered with
+	Sensitive bool `json:"sensitive,omitempty"`
+}
+
+type HardwareTampering struct {
+	// DetectionTime is the time the tampering-sensitive change was detected
+	DetectionTime metav1.Time `json:"detectionTime"`
+
+	// Reason describes the change that caused the device to be flagged
+	Reason string `json:"reason"`
 }
 
 type EdgeDeploymentPhase string
@@ -167,6 +460,8 @@ const (
 	Deploying EdgeDeploymentPhase = "Deploying"
 	Running   EdgeDeploymentPhase = "Running"
 	Exited    EdgeDeploymentPhase = "Exited"
+	// Blocked means that a deployment this deployment depends on is not running on the device
+	Blocked EdgeDeploymentPhase = "Blocked"
 )
 
 type Deployment struct {
@@ -174,6 +469,10 @@ type Deployment struct {
 	Phase              EdgeDeploymentPhase `json:"phase,omitempty"`
 	LastTransitionTime metav1.Time         `json:"lastTransitionTime,omitempty"`
 	LastDataUpload     metav1.Time         `json:"lastDataUpload,omitempty"`
+	// LastDataUploadError is the error of the latest data upload; empty when it succeeded
+	LastDataUploadError string `json:"lastDataUploadError,omitempty"`
+	// DependsOn lists the deployments this deployment depends on, as set in the EdgeDeployment
+	DependsOn []string `json:"dependsOn,omitempty"`
 }
 
 type UpgradeInformation struct {
@@ -0,0 +1,84 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// EdgeDeviceMigrationSpec defines the transfer of the identity of an EdgeDevice to a newly registered
+// EdgeDevice in the same namespace, e.g. when the hardware of a device has been replaced.
+type EdgeDeviceMigrationSpec struct {
+	// Source is the name of the EdgeDevice whose identity is transferred; it is retired by the migration
+	Source string `json:"source"`
+
+	// Target is the name of the newly registered EdgeDevice the identity is transferred to
+	Target string `json:"target"`
+
+	// Approved has to be set by an administrator for the migration to take place
+	Approved bool `json:"approved,omitempty"`
+}
+
+type EdgeDeviceMigrationPhase string
+
+const (
+	// MigrationPending means that the source or target EdgeDevice does not exist yet
+	MigrationPending EdgeDeviceMigrationPhase = "Pending"
+	// MigrationWaitingForApproval means that the migration has not been approved yet
+	MigrationWaitingForApproval EdgeDeviceMigrationPhase = "WaitingForApproval"
+	// MigrationTransferred means that the identity has been transferred to the target EdgeDevice, and that the
+	// source EdgeDevice is being retired
+	MigrationTransferred EdgeDeviceMigrationPhase = "Transferred"
+	MigrationCompleted   EdgeDeviceMigrationPhase = "Completed"
+	MigrationFailed      EdgeDeviceMigrationPhase = "Failed"
+)
+
+// EdgeDeviceMigrationStatus defines the observed state of EdgeDeviceMigration
+type EdgeDeviceMigrationStatus struct {
+	Phase EdgeDeviceMigrationPhase `json:"phase,omitempty"`
+
+	// Message describes the phase
+	Message string `json:"message,omitempty"`
+
+	// CompletionTime is the time the migration completed
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// EdgeDeviceMigration is the Schema for the edgedevicemigrations API
+type EdgeDeviceMigration struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   EdgeDeviceMigrationSpec   `json:"spec,omitempty"`
+	Status EdgeDeviceMigrationStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// EdgeDeviceMigrationList contains a list of EdgeDeviceMigration
+type EdgeDeviceMigrationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []EdgeDeviceMigration `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&EdgeDeviceMigration{}, &EdgeDeviceMigrationList{})
+}
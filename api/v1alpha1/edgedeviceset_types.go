@@ -0,0 +1,63 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// EdgeDeviceSetSpec defines the configuration shared by all EdgeDevices that are members of the set.
+// Devices join the set with the device-set label; configuration specified in the EdgeDevice
+// itself takes precedence over the one of the set.
+type EdgeDeviceSetSpec struct {
+	Heartbeat     *HeartbeatConfiguration         `json:"heartbeat,omitempty"`
+	Storage       *Storage                        `json:"storage,omitempty"`
+	Metrics       *MetricsConfiguration           `json:"metrics,omitempty"`
+	LogCollection map[string]*LogCollectionConfig `json:"logCollection,omitempty"`
+
+	// MaintenanceWindow restricts delivery of workload and OS changes to member devices to the window
+	MaintenanceWindow *MaintenanceWindow `json:"maintenanceWindow,omitempty"`
+}
+
+// EdgeDeviceSetStatus defines the observed state of EdgeDeviceSet
+type EdgeDeviceSetStatus struct {
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// EdgeDeviceSet is the Schema for the edgedevicesets API
+type EdgeDeviceSet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   EdgeDeviceSetSpec   `json:"spec,omitempty"`
+	Status EdgeDeviceSetStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// EdgeDeviceSetList contains a list of EdgeDeviceSet
+type EdgeDeviceSetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []EdgeDeviceSet `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&EdgeDeviceSet{}, &EdgeDeviceSetList{})
+}
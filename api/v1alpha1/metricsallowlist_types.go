@@ -0,0 +1,119 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// MetricNameGlob patterns match names the way path.Match does: * matches any sequence of characters, ? a single
+	// character and [...] a character class
+	MetricNameGlob = "glob"
+
+	// MetricNameRegex patterns are RE2 regular expressions matching whole names
+	MetricNameRegex = "regex"
+)
+
+// MetricsAllowListSpec defines the metrics collected by the devices and workloads using the allow-list
+type MetricsAllowListSpec struct {
+	// Names of the metrics to collect
+	Names []string `json:"names,omitempty"`
+
+	// Patterns matching the names of the metrics to collect
+	Patterns []MetricNamePattern `json:"patterns,omitempty"`
+
+	// Extends is the MetricsAllowList of the same namespace whose metrics the allow-list inherits
+	Extends *NameRef `json:"extends,omitempty"`
+
+	// Includes are MetricsAllowLists of the same namespace whose metrics are added to the allow-list
+	Includes []NameRef `json:"includes,omitempty"`
+
+	// Exclude are names of metrics removed from the ones inherited and included; metrics matching an inherited or
+	// included pattern are still collected
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// MetricNamePattern matches the names of metrics to collect
+type MetricNamePattern struct {
+	// Type of the pattern, glob or regex
+	// +kubebuilder:validation:Enum=glob;regex
+	// +kubebuilder:default=glob
+	Type string `json:"type,omitempty"`
+
+	// +kubebuilder:validation:MinLength=1
+	Pattern string `json:"pattern"`
+}
+
+// MetricsAllowListOverrides adjusts the metrics of an allow-list for a device or a deployment
+type MetricsAllowListOverrides struct {
+	// Add are names of metrics collected in addition to the ones of the allow-list
+	Add []string `json:"add,omitempty"`
+
+	// Remove are names of metrics of the allow-list that are not collected; metrics matching a pattern of the
+	// allow-list are still collected
+	Remove []string `json:"remove,omitempty"`
+}
+
+// MetricsAllowListStatus defines the observed state of MetricsAllowList
+type MetricsAllowListStatus struct {
+	// Devices is the number of EdgeDevices using the allow-list for their system metrics, directly or through their
+	// EdgeDeviceSet
+	Devices int32 `json:"devices"`
+
+	// Deployments is the number of EdgeDeployments using the allow-list for their workload metrics
+	Deployments int32 `json:"deployments"`
+
+	// Conditions of the allow-list, e.g. Resolved
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+const (
+	// MetricsAllowListResolvedCondition is true when the allow-lists the allow-list extends and includes exist and do
+	// not form a cycle
+	MetricsAllowListResolvedCondition = "Resolved"
+)
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Devices",type=integer,JSONPath=`.status.devices`
+//+kubebuilder:printcolumn:name="Deployments",type=integer,JSONPath=`.status.deployments`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// MetricsAllowList is the Schema for the metricsallowlists API
+type MetricsAllowList struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MetricsAllowListSpec   `json:"spec,omitempty"`
+	Status MetricsAllowListStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// MetricsAllowListList contains a list of MetricsAllowList
+type MetricsAllowListList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MetricsAllowList `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MetricsAllowList{}, &MetricsAllowListList{})
+}
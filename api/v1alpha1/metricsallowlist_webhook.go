@@ -0,0 +1,93 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// +kubebuilder:docs-gen:collapse=Apache License
+
+package v1alpha1
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+//+kubebuilder:docs-gen:collapse=Go imports
+
+func (r *MetricsAllowList) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:verbs=create;update,path=/validate-management-project-flotta-io-v1alpha1-metricsallowlist,mutating=false,failurePolicy=fail,groups=management.project-flotta.io,resources=metricsallowlists,versions=v1alpha1,name=metricsallowlist.management.project-flotta.io,sideEffects=None,admissionReviewVersions=v1
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *MetricsAllowList) ValidateCreate() error {
+	return r.validate()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *MetricsAllowList) ValidateUpdate(old runtime.Object) error {
+	return r.validate()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *MetricsAllowList) ValidateDelete() error {
+	return nil
+}
+
+func (r *MetricsAllowList) validate() error {
+	for _, names := range [][]string{r.Spec.Names, r.Spec.Exclude} {
+		for _, name := range names {
+			if name == "" {
+				return fmt.Errorf("metric names cannot be empty")
+			}
+		}
+	}
+
+	for _, pattern := range r.Spec.Patterns {
+		switch pattern.Type {
+		case MetricNameGlob, "":
+			if _, err := path.Match(pattern.Pattern, ""); err != nil {
+				return fmt.Errorf("glob pattern '%s' is not valid: %v", pattern.Pattern, err)
+			}
+		case MetricNameRegex:
+			if _, err := regexp.Compile(pattern.Pattern); err != nil {
+				return fmt.Errorf("regex pattern '%s' is not valid: %v", pattern.Pattern, err)
+			}
+		default:
+			return fmt.Errorf("pattern type '%s' is not supported", pattern.Type)
+		}
+	}
+
+	references := r.Spec.Includes
+	if r.Spec.Extends != nil {
+		references = append([]NameRef{*r.Spec.Extends}, references...)
+	}
+	referenced := map[string]struct{}{}
+	for _, reference := range references {
+		if reference.Name == r.Name {
+			return fmt.Errorf("allow-list '%s' cannot extend or include itself", r.Name)
+		}
+		if _, ok := referenced[reference.Name]; ok {
+			return fmt.Errorf("allow-list '%s' is extended or included more than once", reference.Name)
+		}
+		referenced[reference.Name] = struct{}{}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package v1alpha1_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("MetricsAllowList Webhook", func() {
+	var (
+		allowList v1alpha1.MetricsAllowList
+	)
+
+	BeforeEach(func() {
+		allowList = v1alpha1.MetricsAllowList{
+			ObjectMeta: metav1.ObjectMeta{Name: "node"},
+			Spec: v1alpha1.MetricsAllowListSpec{
+				Names: []string{"node_load1"},
+				Patterns: []v1alpha1.MetricNamePattern{
+					{Type: v1alpha1.MetricNameGlob, Pattern: "node_cpu_*"},
+					{Type: v1alpha1.MetricNameRegex, Pattern: "node_(memory|disk)_.+"},
+				},
+				Extends:  &v1alpha1.NameRef{Name: "base"},
+				Includes: []v1alpha1.NameRef{{Name: "network"}},
+				Exclude:  []string{"node_cpu_guest_seconds_total"},
+			},
+		}
+	})
+
+	It("create valid MetricsAllowList", func() {
+		// when
+		err := allowList.ValidateCreate()
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("delete should always succeed", func() {
+		// given
+		allowList.Spec.Includes = []v1alpha1.NameRef{{Name: "node"}}
+
+		// when
+		err := allowList.ValidateDelete()
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	table.DescribeTable("invalid MetricsAllowList", func(editAllowList func()) {
+		// given
+		editAllowList()
+
+		// when
+		errCreate := allowList.ValidateCreate()
+		errUpdate := allowList.ValidateUpdate(nil)
+
+		// then
+		Expect(errCreate).To(HaveOccurred())
+		Expect(errUpdate).To(HaveOccurred())
+	},
+		table.Entry("empty name", func() {
+			allowList.Spec.Names = append(allowList.Spec.Names, "")
+		}),
+		table.Entry("invalid glob", func() {
+			allowList.Spec.Patterns[0].Pattern = "node_cpu_[a-"
+		}),
+		table.Entry("invalid regex", func() {
+			allowList.Spec.Patterns[1].Pattern = "node_(memory"
+		}),
+		table.Entry("unknown pattern type", func() {
+			allowList.Spec.Patterns[1].Type = "prefix"
+		}),
+		table.Entry("extends itself", func() {
+			allowList.Spec.Extends = &v1alpha1.NameRef{Name: "node"}
+		}),
+		table.Entry("includes itself", func() {
+			allowList.Spec.Includes = append(allowList.Spec.Includes, v1alpha1.NameRef{Name: "node"})
+		}),
+		table.Entry("extends an included allow-list", func() {
+			allowList.Spec.Includes = append(allowList.Spec.Includes, v1alpha1.NameRef{Name: "base"})
+		}),
+	)
+})
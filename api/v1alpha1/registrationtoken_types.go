@@ -0,0 +1,86 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RegistrationTokenSpec defines the devices that can register with the token. Devices present the token as
+// <token name>.<secret> in their registration info.
+type RegistrationTokenSpec struct {
+	// SecretHash is the hex encoded SHA-256 hash of the secret of the token
+	// +kubebuilder:validation:Pattern=`^[0-9a-f]{64}$`
+	SecretHash string `json:"secretHash"`
+
+	// Expiration is the time after which the token cannot be used anymore; the token does not expire when it is not set
+	Expiration *metav1.Time `json:"expiration,omitempty"`
+
+	// MaxUses is the number of devices that can register with the token; the number is not limited when it is not set
+	// +kubebuilder:validation:Minimum=1
+	MaxUses *int32 `json:"maxUses,omitempty"`
+
+	// TargetNamespace is the namespace of the EdgeDevices registered with the token; the namespace of the devices
+	// registered without a token when it is not set
+	TargetNamespace string `json:"targetNamespace,omitempty"`
+
+	// Labels are set on the EdgeDevices registered with the token
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// ApprovalRequired withholds the certificate of the devices registered with the token until their registration is
+	// approved with the registrationApproved field of their EdgeDevice
+	ApprovalRequired bool `json:"approvalRequired,omitempty"`
+}
+
+// RegistrationTokenStatus defines the observed state of RegistrationToken
+type RegistrationTokenStatus struct {
+	// Uses is the number of devices registered with the token
+	Uses int32 `json:"uses"`
+
+	// LastUsedTime is the time the last device registered with the token
+	LastUsedTime *metav1.Time `json:"lastUsedTime,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Uses",type=integer,JSONPath=`.status.uses`
+//+kubebuilder:printcolumn:name="Max Uses",type=integer,JSONPath=`.spec.maxUses`
+//+kubebuilder:printcolumn:name="Expiration",type=date,JSONPath=`.spec.expiration`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// RegistrationToken is the Schema for the registrationtokens API. It is a bootstrap credential for the devices of a
+// site or a batch, consumed by each device registering with it.
+type RegistrationToken struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RegistrationTokenSpec   `json:"spec,omitempty"`
+	Status RegistrationTokenStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// RegistrationTokenList contains a list of RegistrationToken
+type RegistrationTokenList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RegistrationToken `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RegistrationToken{}, &RegistrationTokenList{})
+}
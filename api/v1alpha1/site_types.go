@@ -0,0 +1,104 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SiteSpec defines the desired state of Site
+type SiteSpec struct {
+	// Parent is the name of the Site of the same namespace the site belongs to, e.g. the region of a site or the site
+	// of a line; sites at the root of the topology have no parent
+	Parent string `json:"parent,omitempty"`
+
+	// Type of the site in the topology, e.g. region, site or line
+	Type string `json:"type,omitempty"`
+
+	// DisplayName is the human readable name of the site
+	DisplayName string `json:"displayName,omitempty"`
+
+	// Coordinates of the site
+	Coordinates *Coordinates `json:"coordinates,omitempty"`
+}
+
+// Coordinates are WGS 84 coordinates, in decimal degrees
+type Coordinates struct {
+	// Latitude between -90 and 90, e.g. "40.4168"
+	// +kubebuilder:validation:Pattern=`^[-+]?([0-8]?[0-9](\.[0-9]+)?|90(\.0+)?)$`
+	Latitude string `json:"latitude"`
+
+	// Longitude between -180 and 180, e.g. "-3.7038"
+	// +kubebuilder:validation:Pattern=`^[-+]?((1[0-7][0-9]|[0-9]?[0-9])(\.[0-9]+)?|180(\.0+)?)$`
+	Longitude string `json:"longitude"`
+}
+
+// SiteStatus defines the observed state of Site, rolled up from the EdgeDevices located at the site and at the sites
+// below it
+type SiteStatus struct {
+	// Path are the names of the sites from the root of the topology down to the site
+	Path []string `json:"path,omitempty"`
+
+	// Devices is the number of EdgeDevices located at the site or below it
+	Devices int32 `json:"devices"`
+
+	// OnlineDevices is the number of those devices that have sent a heartbeat recently
+	OnlineDevices int32 `json:"onlineDevices"`
+
+	// WorkloadPhases is the number of workloads deployed to those devices, by phase, e.g. Running
+	WorkloadPhases map[string]int32 `json:"workloadPhases,omitempty"`
+
+	// Conditions of the site, e.g. Resolved
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+const (
+	// SiteResolvedCondition is true when the parents of the site exist and do not form a cycle
+	SiteResolvedCondition = "Resolved"
+)
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Parent",type=string,JSONPath=`.spec.parent`
+//+kubebuilder:printcolumn:name="Type",type=string,JSONPath=`.spec.type`
+//+kubebuilder:printcolumn:name="Devices",type=integer,JSONPath=`.status.devices`
+//+kubebuilder:printcolumn:name="Online",type=integer,JSONPath=`.status.onlineDevices`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// Site is the Schema for the sites API
+type Site struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SiteSpec   `json:"spec,omitempty"`
+	Status SiteStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// SiteList contains a list of Site
+type SiteList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Site `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Site{}, &SiteList{})
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2022
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// +kubebuilder:docs-gen:collapse=Apache License
+
+package v1alpha1
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+//+kubebuilder:docs-gen:collapse=Go imports
+
+// MaxSiteNameLength is the maximum length of the name of a Site: the name is the name part of the site labels of the
+// EdgeDevices located at or below the site
+const MaxSiteNameLength = 63
+
+func (r *Site) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:verbs=create;update,path=/validate-management-project-flotta-io-v1alpha1-site,mutating=false,failurePolicy=fail,groups=management.project-flotta.io,resources=sites,versions=v1alpha1,name=site.management.project-flotta.io,sideEffects=None,admissionReviewVersions=v1
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *Site) ValidateCreate() error {
+	return r.validate()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *Site) ValidateUpdate(old runtime.Object) error {
+	return r.validate()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *Site) ValidateDelete() error {
+	return nil
+}
+
+func (r *Site) validate() error {
+	if len(r.Name) > MaxSiteNameLength {
+		return fmt.Errorf("site name '%s' is longer than %d characters", r.Name, MaxSiteNameLength)
+	}
+	return nil
+}
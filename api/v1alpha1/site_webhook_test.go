@@ -0,0 +1,55 @@
+package v1alpha1_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Site Webhook", func() {
+	var (
+		site v1alpha1.Site
+	)
+
+	BeforeEach(func() {
+		site = v1alpha1.Site{
+			ObjectMeta: metav1.ObjectMeta{Name: strings.Repeat("m", 63)},
+			Spec:       v1alpha1.SiteSpec{Parent: "emea"},
+		}
+	})
+
+	It("create valid Site", func() {
+		// when
+		err := site.ValidateCreate()
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("reject names longer than the name of a label", func() {
+		// given
+		site.Name = strings.Repeat("m", 64)
+
+		// when
+		errCreate := site.ValidateCreate()
+		errUpdate := site.ValidateUpdate(nil)
+
+		// then
+		Expect(errCreate).To(HaveOccurred())
+		Expect(errUpdate).To(HaveOccurred())
+	})
+
+	It("delete should always succeed", func() {
+		// given
+		site.Name = strings.Repeat("m", 64)
+
+		// when
+		err := site.ValidateDelete()
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+	})
+})
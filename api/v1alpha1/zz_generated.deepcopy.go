@@ -68,6 +68,16 @@ func (in *ContainerMetricsConfiguration) DeepCopyInto(out *ContainerMetricsConfi
 		*out = new(NameRef)
 		**out = **in
 	}
+	if in.MetricsAllowList != nil {
+		in, out := &in.MetricsAllowList, &out.MetricsAllowList
+		*out = new(NameRef)
+		**out = **in
+	}
+	if in.AllowListOverrides != nil {
+		in, out := &in.AllowListOverrides, &out.AllowListOverrides
+		*out = new(MetricsAllowListOverrides)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Containers != nil {
 		in, out := &in.Containers, &out.Containers
 		*out = make(map[string]*MetricsConfigEntity, len(*in))
@@ -95,6 +105,21 @@ func (in *ContainerMetricsConfiguration) DeepCopy() *ContainerMetricsConfigurati
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Coordinates) DeepCopyInto(out *Coordinates) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Coordinates.
+func (in *Coordinates) DeepCopy() *Coordinates {
+	if in == nil {
+		return nil
+	}
+	out := new(Coordinates)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DataConfiguration) DeepCopyInto(out *DataConfiguration) {
 	*out = *in
@@ -103,6 +128,21 @@ func (in *DataConfiguration) DeepCopyInto(out *DataConfiguration) {
 		*out = make([]DataPath, len(*in))
 		copy(*out, *in)
 	}
+	if in.Schedule != nil {
+		in, out := &in.Schedule, &out.Schedule
+		*out = new(DataUploadSchedule)
+		**out = **in
+	}
+	if in.Include != nil {
+		in, out := &in.Include, &out.Include
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Exclude != nil {
+		in, out := &in.Exclude, &out.Exclude
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataConfiguration.
@@ -130,11 +170,121 @@ func (in *DataPath) DeepCopy() *DataPath {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DataUploadError) DeepCopyInto(out *DataUploadError) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataUploadError.
+func (in *DataUploadError) DeepCopy() *DataUploadError {
+	if in == nil {
+		return nil
+	}
+	out := new(DataUploadError)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DataUploadSchedule) DeepCopyInto(out *DataUploadSchedule) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataUploadSchedule.
+func (in *DataUploadSchedule) DeepCopy() *DataUploadSchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(DataUploadSchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Decommission) DeepCopyInto(out *Decommission) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Decommission.
+func (in *Decommission) DeepCopy() *Decommission {
+	if in == nil {
+		return nil
+	}
+	out := new(Decommission)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DecommissionStatus) DeepCopyInto(out *DecommissionStatus) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DecommissionStatus.
+func (in *DecommissionStatus) DeepCopy() *DecommissionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DecommissionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeliveredConfiguration) DeepCopyInto(out *DeliveredConfiguration) {
+	*out = *in
+	if in.Deployments != nil {
+		in, out := &in.Deployments, &out.Deployments
+		*out = make([]DeliveredDeployment, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.OsInformation != nil {
+		in, out := &in.OsInformation, &out.OsInformation
+		*out = new(OsInformation)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeliveredConfiguration.
+func (in *DeliveredConfiguration) DeepCopy() *DeliveredConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(DeliveredConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeliveredDeployment) DeepCopyInto(out *DeliveredDeployment) {
+	*out = *in
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeliveredDeployment.
+func (in *DeliveredDeployment) DeepCopy() *DeliveredDeployment {
+	if in == nil {
+		return nil
+	}
+	out := new(DeliveredDeployment)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Deployment) DeepCopyInto(out *Deployment) {
 	*out = *in
 	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
 	in.LastDataUpload.DeepCopyInto(&out.LastDataUpload)
+	if in.DependsOn != nil {
+		in, out := &in.DependsOn, &out.DependsOn
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Deployment.
@@ -147,6 +297,100 @@ func (in *Deployment) DeepCopy() *Deployment {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DesiredConfiguration) DeepCopyInto(out *DesiredConfiguration) {
+	*out = *in
+	in.ChangeTime.DeepCopyInto(&out.ChangeTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DesiredConfiguration.
+func (in *DesiredConfiguration) DeepCopy() *DesiredConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(DesiredConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeviceAttestation) DeepCopyInto(out *DeviceAttestation) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	if in.PCRs != nil {
+		in, out := &in.PCRs, &out.PCRs
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeviceAttestation.
+func (in *DeviceAttestation) DeepCopy() *DeviceAttestation {
+	if in == nil {
+		return nil
+	}
+	out := new(DeviceAttestation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeviceTwin) DeepCopyInto(out *DeviceTwin) {
+	*out = *in
+	if in.Desired != nil {
+		in, out := &in.Desired, &out.Desired
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeviceTwin.
+func (in *DeviceTwin) DeepCopy() *DeviceTwin {
+	if in == nil {
+		return nil
+	}
+	out := new(DeviceTwin)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeviceTwinStatus) DeepCopyInto(out *DeviceTwinStatus) {
+	*out = *in
+	if in.Reported != nil {
+		in, out := &in.Reported, &out.Reported
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ReportedChangeTime != nil {
+		in, out := &in.ReportedChangeTime, &out.ReportedChangeTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Diff != nil {
+		in, out := &in.Diff, &out.Diff
+		*out = make([]TwinPropertyDiff, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeviceTwinStatus.
+func (in *DeviceTwinStatus) DeepCopy() *DeviceTwinStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DeviceTwinStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DeviceConfiguration) DeepCopyInto(out *DeviceConfiguration) {
 	*out = *in
@@ -198,7 +442,7 @@ func (in *EdgeDeployment) DeepCopyInto(out *EdgeDeployment) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EdgeDeployment.
@@ -275,6 +519,16 @@ func (in *EdgeDeploymentSpec) DeepCopyInto(out *EdgeDeploymentSpec) {
 		*out = new(ContainerMetricsConfiguration)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.DependsOn != nil {
+		in, out := &in.DependsOn, &out.DependsOn
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Placement != nil {
+		in, out := &in.Placement, &out.Placement
+		*out = new(PlacementConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EdgeDeploymentSpec.
@@ -290,6 +544,23 @@ func (in *EdgeDeploymentSpec) DeepCopy() *EdgeDeploymentSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *EdgeDeploymentStatus) DeepCopyInto(out *EdgeDeploymentStatus) {
 	*out = *in
+	if in.DataUploadErrors != nil {
+		in, out := &in.DataUploadErrors, &out.DataUploadErrors
+		*out = make([]DataUploadError, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.UnplacedDevices != nil {
+		in, out := &in.UnplacedDevices, &out.UnplacedDevices
+		*out = make([]UnplacedDevice, len(*in))
+		copy(*out, *in)
+	}
+	if in.DependencyCycle != nil {
+		in, out := &in.DependencyCycle, &out.DependencyCycle
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EdgeDeploymentStatus.
@@ -362,457 +633,1283 @@ func (in *EdgeDeviceList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *EdgeDeviceSpec) DeepCopyInto(out *EdgeDeviceSpec) {
+func (in *EdgeDeviceMigration) DeepCopyInto(out *EdgeDeviceMigration) {
 	*out = *in
-	if in.OsInformation != nil {
-		in, out := &in.OsInformation, &out.OsInformation
-		*out = new(OsInformation)
-		**out = **in
-	}
-	if in.RequestTime != nil {
-		in, out := &in.RequestTime, &out.RequestTime
-		*out = (*in).DeepCopy()
-	}
-	if in.Heartbeat != nil {
-		in, out := &in.Heartbeat, &out.Heartbeat
-		*out = new(HeartbeatConfiguration)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.Storage != nil {
-		in, out := &in.Storage, &out.Storage
-		*out = new(Storage)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.Metrics != nil {
-		in, out := &in.Metrics, &out.Metrics
-		*out = new(MetricsConfiguration)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.LogCollection != nil {
-		in, out := &in.LogCollection, &out.LogCollection
-		*out = make(map[string]*LogCollectionConfig, len(*in))
-		for key, val := range *in {
-			var outVal *LogCollectionConfig
-			if val == nil {
-				(*out)[key] = nil
-			} else {
-				in, out := &val, &outVal
-				*out = new(LogCollectionConfig)
-				(*in).DeepCopyInto(*out)
-			}
-			(*out)[key] = outVal
-		}
-	}
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EdgeDeviceSpec.
-func (in *EdgeDeviceSpec) DeepCopy() *EdgeDeviceSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EdgeDeviceMigration.
+func (in *EdgeDeviceMigration) DeepCopy() *EdgeDeviceMigration {
 	if in == nil {
 		return nil
 	}
-	out := new(EdgeDeviceSpec)
+	out := new(EdgeDeviceMigration)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *EdgeDeviceMigration) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *EdgeDeviceStatus) DeepCopyInto(out *EdgeDeviceStatus) {
+func (in *EdgeDeviceMigrationList) DeepCopyInto(out *EdgeDeviceMigrationList) {
 	*out = *in
-	in.LastSeenTime.DeepCopyInto(&out.LastSeenTime)
-	if in.Hardware != nil {
-		in, out := &in.Hardware, &out.Hardware
-		*out = new(Hardware)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.Deployments != nil {
-		in, out := &in.Deployments, &out.Deployments
-		*out = make([]Deployment, len(*in))
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]EdgeDeviceMigration, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
-	if in.DataOBC != nil {
-		in, out := &in.DataOBC, &out.DataOBC
-		*out = new(string)
-		**out = **in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EdgeDeviceMigrationList.
+func (in *EdgeDeviceMigrationList) DeepCopy() *EdgeDeviceMigrationList {
+	if in == nil {
+		return nil
 	}
-	if in.UpgradeInformation != nil {
-		in, out := &in.UpgradeInformation, &out.UpgradeInformation
-		*out = new(UpgradeInformation)
-		**out = **in
+	out := new(EdgeDeviceMigrationList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *EdgeDeviceMigrationList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
 	}
+	return nil
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EdgeDeviceStatus.
-func (in *EdgeDeviceStatus) DeepCopy() *EdgeDeviceStatus {
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EdgeDeviceMigrationSpec) DeepCopyInto(out *EdgeDeviceMigrationSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EdgeDeviceMigrationSpec.
+func (in *EdgeDeviceMigrationSpec) DeepCopy() *EdgeDeviceMigrationSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(EdgeDeviceStatus)
+	out := new(EdgeDeviceMigrationSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Gpu) DeepCopyInto(out *Gpu) {
+func (in *EdgeDeviceMigrationStatus) DeepCopyInto(out *EdgeDeviceMigrationStatus) {
 	*out = *in
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Gpu.
-func (in *Gpu) DeepCopy() *Gpu {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EdgeDeviceMigrationStatus.
+func (in *EdgeDeviceMigrationStatus) DeepCopy() *EdgeDeviceMigrationStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(Gpu)
+	out := new(EdgeDeviceMigrationStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Hardware) DeepCopyInto(out *Hardware) {
+func (in *EdgeDeviceSet) DeepCopyInto(out *EdgeDeviceSet) {
 	*out = *in
-	if in.Boot != nil {
-		in, out := &in.Boot, &out.Boot
-		*out = new(Boot)
-		**out = **in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EdgeDeviceSet.
+func (in *EdgeDeviceSet) DeepCopy() *EdgeDeviceSet {
+	if in == nil {
+		return nil
 	}
-	if in.CPU != nil {
-		in, out := &in.CPU, &out.CPU
-		*out = new(CPU)
-		(*in).DeepCopyInto(*out)
+	out := new(EdgeDeviceSet)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *EdgeDeviceSet) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
 	}
-	if in.Disks != nil {
-		in, out := &in.Disks, &out.Disks
-		*out = make([]*Disk, len(*in))
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EdgeDeviceSetList) DeepCopyInto(out *EdgeDeviceSetList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]EdgeDeviceSet, len(*in))
 		for i := range *in {
-			if (*in)[i] != nil {
-				in, out := &(*in)[i], &(*out)[i]
-				*out = new(Disk)
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EdgeDeviceSetList.
+func (in *EdgeDeviceSetList) DeepCopy() *EdgeDeviceSetList {
+	if in == nil {
+		return nil
+	}
+	out := new(EdgeDeviceSetList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *EdgeDeviceSetList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EdgeDeviceSetSpec) DeepCopyInto(out *EdgeDeviceSetSpec) {
+	*out = *in
+	if in.Heartbeat != nil {
+		in, out := &in.Heartbeat, &out.Heartbeat
+		*out = new(HeartbeatConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Storage != nil {
+		in, out := &in.Storage, &out.Storage
+		*out = new(Storage)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Metrics != nil {
+		in, out := &in.Metrics, &out.Metrics
+		*out = new(MetricsConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LogCollection != nil {
+		in, out := &in.LogCollection, &out.LogCollection
+		*out = make(map[string]*LogCollectionConfig, len(*in))
+		for key, val := range *in {
+			var outVal *LogCollectionConfig
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = new(LogCollectionConfig)
 				(*in).DeepCopyInto(*out)
 			}
+			(*out)[key] = outVal
 		}
 	}
-	if in.Gpus != nil {
-		in, out := &in.Gpus, &out.Gpus
-		*out = make([]*Gpu, len(*in))
-		for i := range *in {
-			if (*in)[i] != nil {
-				in, out := &(*in)[i], &(*out)[i]
-				*out = new(Gpu)
-				**out = **in
+	if in.MaintenanceWindow != nil {
+		in, out := &in.MaintenanceWindow, &out.MaintenanceWindow
+		*out = new(MaintenanceWindow)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EdgeDeviceSetSpec.
+func (in *EdgeDeviceSetSpec) DeepCopy() *EdgeDeviceSetSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(EdgeDeviceSetSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EdgeDeviceSetStatus) DeepCopyInto(out *EdgeDeviceSetStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EdgeDeviceSetStatus.
+func (in *EdgeDeviceSetStatus) DeepCopy() *EdgeDeviceSetStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(EdgeDeviceSetStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EdgeDeviceSpec) DeepCopyInto(out *EdgeDeviceSpec) {
+	*out = *in
+	if in.OsInformation != nil {
+		in, out := &in.OsInformation, &out.OsInformation
+		*out = new(OsInformation)
+		**out = **in
+	}
+	if in.RequestTime != nil {
+		in, out := &in.RequestTime, &out.RequestTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Heartbeat != nil {
+		in, out := &in.Heartbeat, &out.Heartbeat
+		*out = new(HeartbeatConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Storage != nil {
+		in, out := &in.Storage, &out.Storage
+		*out = new(Storage)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Metrics != nil {
+		in, out := &in.Metrics, &out.Metrics
+		*out = new(MetricsConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LogCollection != nil {
+		in, out := &in.LogCollection, &out.LogCollection
+		*out = make(map[string]*LogCollectionConfig, len(*in))
+		for key, val := range *in {
+			var outVal *LogCollectionConfig
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = new(LogCollectionConfig)
+				(*in).DeepCopyInto(*out)
 			}
+			(*out)[key] = outVal
 		}
 	}
-	if in.Interfaces != nil {
-		in, out := &in.Interfaces, &out.Interfaces
-		*out = make([]*Interface, len(*in))
+	if in.MaintenanceWindow != nil {
+		in, out := &in.MaintenanceWindow, &out.MaintenanceWindow
+		*out = new(MaintenanceWindow)
+		**out = **in
+	}
+	if in.Decommission != nil {
+		in, out := &in.Decommission, &out.Decommission
+		*out = new(Decommission)
+		**out = **in
+	}
+	if in.Twin != nil {
+		in, out := &in.Twin, &out.Twin
+		*out = new(DeviceTwin)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EdgeDeviceSpec.
+func (in *EdgeDeviceSpec) DeepCopy() *EdgeDeviceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(EdgeDeviceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EdgeDeviceStatus) DeepCopyInto(out *EdgeDeviceStatus) {
+	*out = *in
+	in.LastSeenTime.DeepCopyInto(&out.LastSeenTime)
+	if in.Hardware != nil {
+		in, out := &in.Hardware, &out.Hardware
+		*out = new(Hardware)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Deployments != nil {
+		in, out := &in.Deployments, &out.Deployments
+		*out = make([]Deployment, len(*in))
 		for i := range *in {
-			if (*in)[i] != nil {
-				in, out := &(*in)[i], &(*out)[i]
-				*out = new(Interface)
-				(*in).DeepCopyInto(*out)
-			}
+			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
-	if in.Memory != nil {
-		in, out := &in.Memory, &out.Memory
-		*out = new(Memory)
+	if in.DataOBC != nil {
+		in, out := &in.DataOBC, &out.DataOBC
+		*out = new(string)
 		**out = **in
 	}
-	if in.SystemVendor != nil {
-		in, out := &in.SystemVendor, &out.SystemVendor
-		*out = new(SystemVendor)
+	if in.UpgradeInformation != nil {
+		in, out := &in.UpgradeInformation, &out.UpgradeInformation
+		*out = new(UpgradeInformation)
 		**out = **in
 	}
+	if in.HardwareChanges != nil {
+		in, out := &in.HardwareChanges, &out.HardwareChanges
+		*out = make([]HardwareChange, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.HardwareTampering != nil {
+		in, out := &in.HardwareTampering, &out.HardwareTampering
+		*out = new(HardwareTampering)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.EffectiveConfiguration != nil {
+		in, out := &in.EffectiveConfiguration, &out.EffectiveConfiguration
+		*out = new(EffectiveConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DeliveredConfiguration != nil {
+		in, out := &in.DeliveredConfiguration, &out.DeliveredConfiguration
+		*out = new(DeliveredConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PendingChanges != nil {
+		in, out := &in.PendingChanges, &out.PendingChanges
+		*out = new(PendingChanges)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Decommission != nil {
+		in, out := &in.Decommission, &out.Decommission
+		*out = new(DecommissionStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DesiredConfiguration != nil {
+		in, out := &in.DesiredConfiguration, &out.DesiredConfiguration
+		*out = new(DesiredConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Attestation != nil {
+		in, out := &in.Attestation, &out.Attestation
+		*out = new(DeviceAttestation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Twin != nil {
+		in, out := &in.Twin, &out.Twin
+		*out = new(DeviceTwinStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EdgeDeviceStatus.
+func (in *EdgeDeviceStatus) DeepCopy() *EdgeDeviceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(EdgeDeviceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EffectiveConfiguration) DeepCopyInto(out *EffectiveConfiguration) {
+	*out = *in
+	if in.Heartbeat != nil {
+		in, out := &in.Heartbeat, &out.Heartbeat
+		*out = new(HeartbeatConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Storage != nil {
+		in, out := &in.Storage, &out.Storage
+		*out = new(Storage)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Metrics != nil {
+		in, out := &in.Metrics, &out.Metrics
+		*out = new(MetricsConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LogCollection != nil {
+		in, out := &in.LogCollection, &out.LogCollection
+		*out = make(map[string]*LogCollectionConfig, len(*in))
+		for key, val := range *in {
+			var outVal *LogCollectionConfig
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = new(LogCollectionConfig)
+				(*in).DeepCopyInto(*out)
+			}
+			(*out)[key] = outVal
+		}
+	}
+	if in.MaintenanceWindow != nil {
+		in, out := &in.MaintenanceWindow, &out.MaintenanceWindow
+		*out = new(MaintenanceWindow)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EffectiveConfiguration.
+func (in *EffectiveConfiguration) DeepCopy() *EffectiveConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(EffectiveConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Gpu) DeepCopyInto(out *Gpu) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Gpu.
+func (in *Gpu) DeepCopy() *Gpu {
+	if in == nil {
+		return nil
+	}
+	out := new(Gpu)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Hardware) DeepCopyInto(out *Hardware) {
+	*out = *in
+	if in.Boot != nil {
+		in, out := &in.Boot, &out.Boot
+		*out = new(Boot)
+		**out = **in
+	}
+	if in.CPU != nil {
+		in, out := &in.CPU, &out.CPU
+		*out = new(CPU)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Disks != nil {
+		in, out := &in.Disks, &out.Disks
+		*out = make([]*Disk, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(Disk)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+	if in.Gpus != nil {
+		in, out := &in.Gpus, &out.Gpus
+		*out = make([]*Gpu, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(Gpu)
+				**out = **in
+			}
+		}
+	}
+	if in.Interfaces != nil {
+		in, out := &in.Interfaces, &out.Interfaces
+		*out = make([]*Interface, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(Interface)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+	if in.Memory != nil {
+		in, out := &in.Memory, &out.Memory
+		*out = new(Memory)
+		**out = **in
+	}
+	if in.SystemVendor != nil {
+		in, out := &in.SystemVendor, &out.SystemVendor
+		*out = new(SystemVendor)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Hardware.
+func (in *Hardware) DeepCopy() *Hardware {
+	if in == nil {
+		return nil
+	}
+	out := new(Hardware)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HardwareChange) DeepCopyInto(out *HardwareChange) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HardwareChange.
+func (in *HardwareChange) DeepCopy() *HardwareChange {
+	if in == nil {
+		return nil
+	}
+	out := new(HardwareChange)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HardwareProfileConfiguration) DeepCopyInto(out *HardwareProfileConfiguration) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HardwareProfileConfiguration.
+func (in *HardwareProfileConfiguration) DeepCopy() *HardwareProfileConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(HardwareProfileConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HardwareTampering) DeepCopyInto(out *HardwareTampering) {
+	*out = *in
+	in.DetectionTime.DeepCopyInto(&out.DetectionTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HardwareTampering.
+func (in *HardwareTampering) DeepCopy() *HardwareTampering {
+	if in == nil {
+		return nil
+	}
+	out := new(HardwareTampering)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HeartbeatConfiguration) DeepCopyInto(out *HeartbeatConfiguration) {
+	*out = *in
+	if in.HardwareProfile != nil {
+		in, out := &in.HardwareProfile, &out.HardwareProfile
+		*out = new(HardwareProfileConfiguration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HeartbeatConfiguration.
+func (in *HeartbeatConfiguration) DeepCopy() *HeartbeatConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(HeartbeatConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageRegistriesConfiguration) DeepCopyInto(out *ImageRegistriesConfiguration) {
+	*out = *in
+	if in.AuthFileSecret != nil {
+		in, out := &in.AuthFileSecret, &out.AuthFileSecret
+		*out = new(NameRef)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageRegistriesConfiguration.
+func (in *ImageRegistriesConfiguration) DeepCopy() *ImageRegistriesConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageRegistriesConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Interface) DeepCopyInto(out *Interface) {
+	*out = *in
+	if in.Flags != nil {
+		in, out := &in.Flags, &out.Flags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.IPV4Addresses != nil {
+		in, out := &in.IPV4Addresses, &out.IPV4Addresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.IPV6Addresses != nil {
+		in, out := &in.IPV6Addresses, &out.IPV6Addresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Interface.
+func (in *Interface) DeepCopy() *Interface {
+	if in == nil {
+		return nil
+	}
+	out := new(Interface)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IoPerf) DeepCopyInto(out *IoPerf) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IoPerf.
+func (in *IoPerf) DeepCopy() *IoPerf {
+	if in == nil {
+		return nil
+	}
+	out := new(IoPerf)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LogCollectionConfig) DeepCopyInto(out *LogCollectionConfig) {
+	*out = *in
+	if in.SyslogConfig != nil {
+		in, out := &in.SyslogConfig, &out.SyslogConfig
+		*out = new(NameRef)
+		**out = **in
+	}
+	if in.SyslogOptions != nil {
+		in, out := &in.SyslogOptions, &out.SyslogOptions
+		*out = new(SyslogOptions)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LogCollectionConfig.
+func (in *LogCollectionConfig) DeepCopy() *LogCollectionConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(LogCollectionConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceWindow) DeepCopyInto(out *MaintenanceWindow) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceWindow.
+func (in *MaintenanceWindow) DeepCopy() *MaintenanceWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenanceWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Memory) DeepCopyInto(out *Memory) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Memory.
+func (in *Memory) DeepCopy() *Memory {
+	if in == nil {
+		return nil
+	}
+	out := new(Memory)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetricNamePattern) DeepCopyInto(out *MetricNamePattern) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricNamePattern.
+func (in *MetricNamePattern) DeepCopy() *MetricNamePattern {
+	if in == nil {
+		return nil
+	}
+	out := new(MetricNamePattern)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetricsAllowList) DeepCopyInto(out *MetricsAllowList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricsAllowList.
+func (in *MetricsAllowList) DeepCopy() *MetricsAllowList {
+	if in == nil {
+		return nil
+	}
+	out := new(MetricsAllowList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MetricsAllowList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetricsAllowListList) DeepCopyInto(out *MetricsAllowListList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]MetricsAllowList, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricsAllowListList.
+func (in *MetricsAllowListList) DeepCopy() *MetricsAllowListList {
+	if in == nil {
+		return nil
+	}
+	out := new(MetricsAllowListList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MetricsAllowListList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetricsAllowListOverrides) DeepCopyInto(out *MetricsAllowListOverrides) {
+	*out = *in
+	if in.Add != nil {
+		in, out := &in.Add, &out.Add
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Remove != nil {
+		in, out := &in.Remove, &out.Remove
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricsAllowListOverrides.
+func (in *MetricsAllowListOverrides) DeepCopy() *MetricsAllowListOverrides {
+	if in == nil {
+		return nil
+	}
+	out := new(MetricsAllowListOverrides)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetricsAllowListSpec) DeepCopyInto(out *MetricsAllowListSpec) {
+	*out = *in
+	if in.Names != nil {
+		in, out := &in.Names, &out.Names
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Patterns != nil {
+		in, out := &in.Patterns, &out.Patterns
+		*out = make([]MetricNamePattern, len(*in))
+		copy(*out, *in)
+	}
+	if in.Extends != nil {
+		in, out := &in.Extends, &out.Extends
+		*out = new(NameRef)
+		**out = **in
+	}
+	if in.Includes != nil {
+		in, out := &in.Includes, &out.Includes
+		*out = make([]NameRef, len(*in))
+		copy(*out, *in)
+	}
+	if in.Exclude != nil {
+		in, out := &in.Exclude, &out.Exclude
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricsAllowListSpec.
+func (in *MetricsAllowListSpec) DeepCopy() *MetricsAllowListSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MetricsAllowListSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetricsAllowListStatus) DeepCopyInto(out *MetricsAllowListStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricsAllowListStatus.
+func (in *MetricsAllowListStatus) DeepCopy() *MetricsAllowListStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MetricsAllowListStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetricsConfigEntity) DeepCopyInto(out *MetricsConfigEntity) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricsConfigEntity.
+func (in *MetricsConfigEntity) DeepCopy() *MetricsConfigEntity {
+	if in == nil {
+		return nil
+	}
+	out := new(MetricsConfigEntity)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetricsConfiguration) DeepCopyInto(out *MetricsConfiguration) {
+	*out = *in
+	if in.Retention != nil {
+		in, out := &in.Retention, &out.Retention
+		*out = new(Retention)
+		**out = **in
+	}
+	if in.SystemMetrics != nil {
+		in, out := &in.SystemMetrics, &out.SystemMetrics
+		*out = new(SystemMetricsConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricsConfiguration.
+func (in *MetricsConfiguration) DeepCopy() *MetricsConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(MetricsConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NameRef) DeepCopyInto(out *NameRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NameRef.
+func (in *NameRef) DeepCopy() *NameRef {
+	if in == nil {
+		return nil
+	}
+	out := new(NameRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OsInformation) DeepCopyInto(out *OsInformation) {
+	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Hardware.
-func (in *Hardware) DeepCopy() *Hardware {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OsInformation.
+func (in *OsInformation) DeepCopy() *OsInformation {
 	if in == nil {
 		return nil
 	}
-	out := new(Hardware)
+	out := new(OsInformation)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *HardwareProfileConfiguration) DeepCopyInto(out *HardwareProfileConfiguration) {
+func (in *PendingChanges) DeepCopyInto(out *PendingChanges) {
 	*out = *in
+	in.Since.DeepCopyInto(&out.Since)
+	if in.NextWindow != nil {
+		in, out := &in.NextWindow, &out.NextWindow
+		*out = (*in).DeepCopy()
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HardwareProfileConfiguration.
-func (in *HardwareProfileConfiguration) DeepCopy() *HardwareProfileConfiguration {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PendingChanges.
+func (in *PendingChanges) DeepCopy() *PendingChanges {
 	if in == nil {
 		return nil
 	}
-	out := new(HardwareProfileConfiguration)
+	out := new(PendingChanges)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *HeartbeatConfiguration) DeepCopyInto(out *HeartbeatConfiguration) {
+func (in *PlacementConfiguration) DeepCopyInto(out *PlacementConfiguration) {
 	*out = *in
-	if in.HardwareProfile != nil {
-		in, out := &in.HardwareProfile, &out.HardwareProfile
-		*out = new(HardwareProfileConfiguration)
+	if in.Spread != nil {
+		in, out := &in.Spread, &out.Spread
+		*out = new(SpreadConstraint)
 		**out = **in
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HeartbeatConfiguration.
-func (in *HeartbeatConfiguration) DeepCopy() *HeartbeatConfiguration {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlacementConfiguration.
+func (in *PlacementConfiguration) DeepCopy() *PlacementConfiguration {
 	if in == nil {
 		return nil
 	}
-	out := new(HeartbeatConfiguration)
+	out := new(PlacementConfiguration)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ImageRegistriesConfiguration) DeepCopyInto(out *ImageRegistriesConfiguration) {
+func (in *Pod) DeepCopyInto(out *Pod) {
 	*out = *in
-	if in.AuthFileSecret != nil {
-		in, out := &in.AuthFileSecret, &out.AuthFileSecret
-		*out = new(NameRef)
-		**out = **in
-	}
+	in.Spec.DeepCopyInto(&out.Spec)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageRegistriesConfiguration.
-func (in *ImageRegistriesConfiguration) DeepCopy() *ImageRegistriesConfiguration {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Pod.
+func (in *Pod) DeepCopy() *Pod {
 	if in == nil {
 		return nil
 	}
-	out := new(ImageRegistriesConfiguration)
+	out := new(Pod)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Interface) DeepCopyInto(out *Interface) {
+func (in *RegistrationToken) DeepCopyInto(out *RegistrationToken) {
 	*out = *in
-	if in.Flags != nil {
-		in, out := &in.Flags, &out.Flags
-		*out = make([]string, len(*in))
-		copy(*out, *in)
-	}
-	if in.IPV4Addresses != nil {
-		in, out := &in.IPV4Addresses, &out.IPV4Addresses
-		*out = make([]string, len(*in))
-		copy(*out, *in)
-	}
-	if in.IPV6Addresses != nil {
-		in, out := &in.IPV6Addresses, &out.IPV6Addresses
-		*out = make([]string, len(*in))
-		copy(*out, *in)
-	}
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Interface.
-func (in *Interface) DeepCopy() *Interface {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistrationToken.
+func (in *RegistrationToken) DeepCopy() *RegistrationToken {
 	if in == nil {
 		return nil
 	}
-	out := new(Interface)
+	out := new(RegistrationToken)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RegistrationToken) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *IoPerf) DeepCopyInto(out *IoPerf) {
+func (in *RegistrationTokenList) DeepCopyInto(out *RegistrationTokenList) {
 	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]RegistrationToken, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IoPerf.
-func (in *IoPerf) DeepCopy() *IoPerf {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistrationTokenList.
+func (in *RegistrationTokenList) DeepCopy() *RegistrationTokenList {
 	if in == nil {
 		return nil
 	}
-	out := new(IoPerf)
+	out := new(RegistrationTokenList)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RegistrationTokenList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *LogCollectionConfig) DeepCopyInto(out *LogCollectionConfig) {
+func (in *RegistrationTokenSpec) DeepCopyInto(out *RegistrationTokenSpec) {
 	*out = *in
-	if in.SyslogConfig != nil {
-		in, out := &in.SyslogConfig, &out.SyslogConfig
-		*out = new(NameRef)
+	if in.Expiration != nil {
+		in, out := &in.Expiration, &out.Expiration
+		*out = (*in).DeepCopy()
+	}
+	if in.MaxUses != nil {
+		in, out := &in.MaxUses, &out.MaxUses
+		*out = new(int32)
 		**out = **in
 	}
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LogCollectionConfig.
-func (in *LogCollectionConfig) DeepCopy() *LogCollectionConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistrationTokenSpec.
+func (in *RegistrationTokenSpec) DeepCopy() *RegistrationTokenSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(LogCollectionConfig)
+	out := new(RegistrationTokenSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Memory) DeepCopyInto(out *Memory) {
+func (in *RegistrationTokenStatus) DeepCopyInto(out *RegistrationTokenStatus) {
 	*out = *in
+	if in.LastUsedTime != nil {
+		in, out := &in.LastUsedTime, &out.LastUsedTime
+		*out = (*in).DeepCopy()
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Memory.
-func (in *Memory) DeepCopy() *Memory {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistrationTokenStatus.
+func (in *RegistrationTokenStatus) DeepCopy() *RegistrationTokenStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(Memory)
+	out := new(RegistrationTokenStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MetricsConfigEntity) DeepCopyInto(out *MetricsConfigEntity) {
+func (in *Retention) DeepCopyInto(out *Retention) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricsConfigEntity.
-func (in *MetricsConfigEntity) DeepCopy() *MetricsConfigEntity {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Retention.
+func (in *Retention) DeepCopy() *Retention {
 	if in == nil {
 		return nil
 	}
-	out := new(MetricsConfigEntity)
+	out := new(Retention)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MetricsConfiguration) DeepCopyInto(out *MetricsConfiguration) {
+func (in *Route) DeepCopyInto(out *Route) {
 	*out = *in
-	if in.Retention != nil {
-		in, out := &in.Retention, &out.Retention
-		*out = new(Retention)
-		**out = **in
-	}
-	if in.SystemMetrics != nil {
-		in, out := &in.SystemMetrics, &out.SystemMetrics
-		*out = new(SystemMetricsConfiguration)
-		(*in).DeepCopyInto(*out)
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricsConfiguration.
-func (in *MetricsConfiguration) DeepCopy() *MetricsConfiguration {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Route.
+func (in *Route) DeepCopy() *Route {
 	if in == nil {
 		return nil
 	}
-	out := new(MetricsConfiguration)
+	out := new(Route)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *NameRef) DeepCopyInto(out *NameRef) {
+func (in *S3Storage) DeepCopyInto(out *S3Storage) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NameRef.
-func (in *NameRef) DeepCopy() *NameRef {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S3Storage.
+func (in *S3Storage) DeepCopy() *S3Storage {
 	if in == nil {
 		return nil
 	}
-	out := new(NameRef)
+	out := new(S3Storage)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *OsInformation) DeepCopyInto(out *OsInformation) {
+func (in *S3StorageConfiguration) DeepCopyInto(out *S3StorageConfiguration) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OsInformation.
-func (in *OsInformation) DeepCopy() *OsInformation {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S3StorageConfiguration.
+func (in *S3StorageConfiguration) DeepCopy() *S3StorageConfiguration {
 	if in == nil {
 		return nil
 	}
-	out := new(OsInformation)
+	out := new(S3StorageConfiguration)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Pod) DeepCopyInto(out *Pod) {
+func (in *Site) DeepCopyInto(out *Site) {
 	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Pod.
-func (in *Pod) DeepCopy() *Pod {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Site.
+func (in *Site) DeepCopy() *Site {
 	if in == nil {
 		return nil
 	}
-	out := new(Pod)
+	out := new(Site)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Site) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Retention) DeepCopyInto(out *Retention) {
+func (in *SiteList) DeepCopyInto(out *SiteList) {
 	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Site, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Retention.
-func (in *Retention) DeepCopy() *Retention {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SiteList.
+func (in *SiteList) DeepCopy() *SiteList {
 	if in == nil {
 		return nil
 	}
-	out := new(Retention)
+	out := new(SiteList)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SiteList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Route) DeepCopyInto(out *Route) {
+func (in *SiteSpec) DeepCopyInto(out *SiteSpec) {
 	*out = *in
+	if in.Coordinates != nil {
+		in, out := &in.Coordinates, &out.Coordinates
+		*out = new(Coordinates)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Route.
-func (in *Route) DeepCopy() *Route {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SiteSpec.
+func (in *SiteSpec) DeepCopy() *SiteSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(Route)
+	out := new(SiteSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *S3Storage) DeepCopyInto(out *S3Storage) {
+func (in *SiteStatus) DeepCopyInto(out *SiteStatus) {
 	*out = *in
+	if in.Path != nil {
+		in, out := &in.Path, &out.Path
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.WorkloadPhases != nil {
+		in, out := &in.WorkloadPhases, &out.WorkloadPhases
+		*out = make(map[string]int32, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S3Storage.
-func (in *S3Storage) DeepCopy() *S3Storage {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SiteStatus.
+func (in *SiteStatus) DeepCopy() *SiteStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(S3Storage)
+	out := new(SiteStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *S3StorageConfiguration) DeepCopyInto(out *S3StorageConfiguration) {
+func (in *SpreadConstraint) DeepCopyInto(out *SpreadConstraint) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S3StorageConfiguration.
-func (in *S3StorageConfiguration) DeepCopy() *S3StorageConfiguration {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SpreadConstraint.
+func (in *SpreadConstraint) DeepCopy() *SpreadConstraint {
 	if in == nil {
 		return nil
 	}
-	out := new(S3StorageConfiguration)
+	out := new(SpreadConstraint)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -857,6 +1954,42 @@ func (in *StorageConfiguration) DeepCopy() *StorageConfiguration {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SyslogOptions) DeepCopyInto(out *SyslogOptions) {
+	*out = *in
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(SyslogTLS)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SyslogOptions.
+func (in *SyslogOptions) DeepCopy() *SyslogOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(SyslogOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SyslogTLS) DeepCopyInto(out *SyslogTLS) {
+	*out = *in
+	out.SecretRef = in.SecretRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SyslogTLS.
+func (in *SyslogTLS) DeepCopy() *SyslogTLS {
+	if in == nil {
+		return nil
+	}
+	out := new(SyslogTLS)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SystemMetricsConfiguration) DeepCopyInto(out *SystemMetricsConfiguration) {
 	*out = *in
@@ -865,6 +1998,16 @@ func (in *SystemMetricsConfiguration) DeepCopyInto(out *SystemMetricsConfigurati
 		*out = new(NameRef)
 		**out = **in
 	}
+	if in.MetricsAllowList != nil {
+		in, out := &in.MetricsAllowList, &out.MetricsAllowList
+		*out = new(NameRef)
+		**out = **in
+	}
+	if in.AllowListOverrides != nil {
+		in, out := &in.AllowListOverrides, &out.AllowListOverrides
+		*out = new(MetricsAllowListOverrides)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SystemMetricsConfiguration.
@@ -892,6 +2035,41 @@ func (in *SystemVendor) DeepCopy() *SystemVendor {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TwinPropertyDiff) DeepCopyInto(out *TwinPropertyDiff) {
+	*out = *in
+	if in.Reported != nil {
+		in, out := &in.Reported, &out.Reported
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TwinPropertyDiff.
+func (in *TwinPropertyDiff) DeepCopy() *TwinPropertyDiff {
+	if in == nil {
+		return nil
+	}
+	out := new(TwinPropertyDiff)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UnplacedDevice) DeepCopyInto(out *UnplacedDevice) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UnplacedDevice.
+func (in *UnplacedDevice) DeepCopy() *UnplacedDevice {
+	if in == nil {
+		return nil
+	}
+	out := new(UnplacedDevice)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *UpgradeInformation) DeepCopyInto(out *UpgradeInformation) {
 	*out = *in
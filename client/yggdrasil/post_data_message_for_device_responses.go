@@ -53,6 +53,12 @@ func (o *PostDataMessageForDeviceReader) ReadResponse(response runtime.ClientRes
 			return nil, err
 		}
 		return nil, result
+	case 429:
+		result := NewPostDataMessageForDeviceTooManyRequests()
+		if err := result.readResponse(response, consumer, o.formats); err != nil {
+			return nil, err
+		}
+		return nil, result
 	case 500:
 		result := NewPostDataMessageForDeviceInternalServerError()
 		if err := result.readResponse(response, consumer, o.formats); err != nil {
@@ -182,6 +188,27 @@ func (o *PostDataMessageForDeviceNotFound) readResponse(response runtime.ClientR
 	return nil
 }
 
+// NewPostDataMessageForDeviceTooManyRequests creates a PostDataMessageForDeviceTooManyRequests with default headers values
+func NewPostDataMessageForDeviceTooManyRequests() *PostDataMessageForDeviceTooManyRequests {
+	return &PostDataMessageForDeviceTooManyRequests{}
+}
+
+/*PostDataMessageForDeviceTooManyRequests handles this case with default header values.
+
+Too many requests
+*/
+type PostDataMessageForDeviceTooManyRequests struct {
+}
+
+func (o *PostDataMessageForDeviceTooManyRequests) Error() string {
+	return fmt.Sprintf("[POST /data/{device_id}/out][%d] postDataMessageForDeviceTooManyRequests ", 429)
+}
+
+func (o *PostDataMessageForDeviceTooManyRequests) readResponse(response runtime.ClientResponse, consumer runtime.Consumer, formats strfmt.Registry) error {
+
+	return nil
+}
+
 // NewPostDataMessageForDeviceInternalServerError creates a PostDataMessageForDeviceInternalServerError with default headers values
 func NewPostDataMessageForDeviceInternalServerError() *PostDataMessageForDeviceInternalServerError {
 	return &PostDataMessageForDeviceInternalServerError{}
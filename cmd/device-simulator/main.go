@@ -0,0 +1,134 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// device-simulator registers virtual devices against the yggdrasil API of the operator, polls their configuration and
+// sends their heartbeats, and reports the latency and the errors of the requests.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/project-flotta/flotta-operator/client"
+	"github.com/project-flotta/flotta-operator/internal/simulator"
+)
+
+func main() {
+	var (
+		serverURL           = flag.String("url", "http://localhost:8888"+client.DefaultBasePath, "URL of the yggdrasil API")
+		caCert              = flag.String("ca-cert", "", "CA certificate verifying the server certificate, for https")
+		registrationCert    = flag.String("cert", "", "registration client certificate, for https")
+		registrationKey     = flag.String("key", "", "registration client key, for https")
+		devices             = flag.Int("devices", 10, "number of simulated devices")
+		devicePrefix        = flag.String("prefix", "simulated-device", "prefix of the ID of the simulated devices")
+		heartbeatPeriod     = flag.Duration("heartbeat-period", 60*time.Second, "period of the heartbeats of each device")
+		configurationPeriod = flag.Duration("configuration-period", 15*time.Second, "period of the configuration polling of each device")
+		rampUp              = flag.Duration("ramp-up", 10*time.Second, "time over which the start of the devices is spread")
+		duration            = flag.Duration("duration", 5*time.Minute, "duration of the simulation; 0 runs until interrupted")
+		reportInterval      = flag.Duration("report-interval", 30*time.Second, "interval of the intermediate reports; 0 disables them")
+	)
+	opts := zap.Options{}
+	opts.BindFlags(flag.CommandLine)
+	flag.Parse()
+	logger := zap.New(zap.UseFlagOptions(&opts))
+
+	config := simulator.Config{
+		Devices:             *devices,
+		DevicePrefix:        *devicePrefix,
+		HeartbeatPeriod:     *heartbeatPeriod,
+		ConfigurationPeriod: *configurationPeriod,
+		RampUp:              *rampUp,
+		Log:                 logger,
+	}
+	var err error
+	config.URL, err = url.Parse(*serverURL)
+	if err != nil {
+		logger.Error(err, "invalid URL", "url", *serverURL)
+		os.Exit(1)
+	}
+	if config.URL.Scheme == "https" {
+		config.RootCAs, config.RegistrationCertificate, err = loadCertificates(*caCert, *registrationCert, *registrationKey)
+		if err != nil {
+			logger.Error(err, "cannot load certificates")
+			os.Exit(1)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	sim := simulator.New(config)
+	if *reportInterval > 0 {
+		go report(ctx, sim.Stats(), *reportInterval)
+	}
+	logger.Info("Starting simulation", "url", config.URL.String(), "devices", config.Devices)
+	sim.Run(ctx)
+
+	fmt.Println("Final report:")
+	_ = sim.Stats().Print(os.Stdout)
+}
+
+func report(ctx context.Context, stats *simulator.Stats, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fmt.Printf("Report at %s:\n", time.Now().Format(time.RFC3339))
+			_ = stats.Print(os.Stdout)
+		}
+	}
+}
+
+func loadCertificates(caFile, certFile, keyFile string) (*x509.CertPool, *tls.Certificate, error) {
+	var rootCAs *x509.CertPool
+	if caFile != "" {
+		caPEM, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		rootCAs = x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, nil, fmt.Errorf("no certificate found in %s", caFile)
+		}
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, nil, fmt.Errorf("registration certificate and key are required for https")
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rootCAs, &cert, nil
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// kubectl-flotta is a kubectl plugin for the operations on a fleet of edge devices. Installed in the PATH, it is run
+// as "kubectl flotta".
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	obv1 "github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+
+	managementv1alpha1 "github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"github.com/project-flotta/flotta-operator/internal/configmaps"
+	"github.com/project-flotta/flotta-operator/internal/devicemetrics"
+	"github.com/project-flotta/flotta-operator/internal/fleet"
+	"github.com/project-flotta/flotta-operator/internal/images"
+	"github.com/project-flotta/flotta-operator/internal/k8sclient"
+	"github.com/project-flotta/flotta-operator/internal/metrics"
+	"github.com/project-flotta/flotta-operator/internal/repository/edgedeployment"
+	"github.com/project-flotta/flotta-operator/internal/repository/edgedevice"
+	"github.com/project-flotta/flotta-operator/internal/repository/edgedevicemigration"
+	"github.com/project-flotta/flotta-operator/internal/repository/edgedeviceset"
+	"github.com/project-flotta/flotta-operator/internal/storage"
+	"github.com/project-flotta/flotta-operator/internal/yggdrasil"
+)
+
+const usage = `Operations on a fleet of edge devices.
+
+Usage:
+  kubectl flotta get devices                          list the devices with their online state and deployments
+  kubectl flotta config DEVICE                        show the configuration delivered to the device, secrets redacted
+  kubectl flotta twin DEVICE                          show the desired and reported properties of the device
+  kubectl flotta explain DEPLOYMENT DEVICE            show why the deployment is or is not deployed to the device
+  kubectl flotta approve registration DEVICE          approve the registration of the device
+  kubectl flotta approve migration MIGRATION          approve an EdgeDeviceMigration
+  kubectl flotta disconnect DEVICE                    delete the device, that is sent the disconnect command
+  kubectl flotta decommission DEVICE [flags]          decommission the device
+
+Flags:
+`
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(managementv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(obv1.AddToScheme(scheme))
+}
+
+func main() {
+	var namespace string
+	flag.StringVar(&namespace, "n", "", "namespace of the devices; the namespace of the current context by default")
+	force := flag.Bool("force", false, "decommission: do not wait for the final data upload of the device")
+	deleteData := flag.Bool("delete-data", false, "decommission: delete the Object Bucket Claim of the device")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+
+	args, err := parseInterspersed(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		os.Exit(2)
+	}
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	commands, err := newCommands(namespace)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	switch {
+	case matches(args, "get", "devices"):
+		err = commands.ListDevices(ctx)
+	case matches(args, "config", ""):
+		err = commands.ShowConfiguration(ctx, args[1])
+	case matches(args, "twin", ""):
+		err = commands.ShowTwin(ctx, args[1])
+	case matches(args, "explain", "", ""):
+		err = commands.ExplainMatch(ctx, args[1], args[2])
+	case matches(args, "approve", "registration", ""):
+		err = commands.ApproveRegistration(ctx, args[2])
+	case matches(args, "approve", "migration", ""):
+		err = commands.ApproveMigration(ctx, args[2])
+	case matches(args, "disconnect", ""):
+		err = commands.Disconnect(ctx, args[1])
+	case matches(args, "decommission", ""):
+		err = commands.Decommission(ctx, args[1], *force, *deleteData)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+// matches tells whether the arguments match the pattern, an empty pattern element matching any argument
+func matches(args []string, pattern ...string) bool {
+	if len(args) != len(pattern) {
+		return false
+	}
+	for i := range pattern {
+		if pattern[i] != "" && pattern[i] != args[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// parseInterspersed parses the flags wherever they are in the arguments, like kubectl does, and returns the other
+// arguments
+func parseInterspersed(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func newCommands(namespace string) (*fleet.Commands, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if kubeconfig := flag.Lookup("kubeconfig"); kubeconfig != nil {
+		loadingRules.ExplicitPath = kubeconfig.Value.String()
+	}
+	if namespace == "" {
+		clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{})
+		currentNamespace, _, err := clientConfig.Namespace()
+		if err != nil {
+			return nil, err
+		}
+		namespace = currentNamespace
+	}
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, err
+	}
+
+	edgeDeviceRepository := edgedevice.NewEdgeDeviceRepository(c)
+	edgeDeploymentRepository := edgedeployment.NewEdgeDeploymentRepository(c)
+	k8sClient := k8sclient.NewK8sClient(c)
+	// the configuration is rendered the way the operator does when the device requests it
+	renderer := yggdrasil.NewYggdrasilHandler(
+		edgeDeviceRepository,
+		edgeDeploymentRepository,
+		edgedeviceset.NewEdgeDeviceSetRepository(c),
+		storage.NewClaimer(c),
+		k8sClient,
+		namespace,
+		&record.FakeRecorder{},
+		images.NewRegistryAuth(c),
+		metrics.New(),
+		devicemetrics.NewAllowListGenerator(k8sClient),
+		configmaps.NewConfigMap(k8sClient),
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+
+	return &fleet.Commands{
+		Namespace:                     namespace,
+		EdgeDeviceRepository:          edgeDeviceRepository,
+		EdgeDeploymentRepository:      edgeDeploymentRepository,
+		EdgeDeviceMigrationRepository: edgedevicemigration.NewEdgeDeviceMigrationRepository(c),
+		Renderer:                      renderer,
+		Out:                           os.Stdout,
+	}, nil
+}
@@ -22,16 +22,26 @@ import (
 	"reflect"
 	"sync"
 
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
+	"github.com/project-flotta/flotta-operator/internal/capacity"
 	"github.com/project-flotta/flotta-operator/internal/labels"
 	"github.com/project-flotta/flotta-operator/internal/metrics"
+	"github.com/project-flotta/flotta-operator/internal/placement"
 	"github.com/project-flotta/flotta-operator/internal/repository/edgedeployment"
 	"github.com/project-flotta/flotta-operator/internal/repository/edgedevice"
+	"github.com/project-flotta/flotta-operator/internal/selectorindex"
 	"github.com/project-flotta/flotta-operator/internal/utils"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -50,6 +60,7 @@ type EdgeDeploymentReconciler struct {
 	Concurrency              uint
 	ExecuteConcurrent        func(uint, ConcurrentFunc, []managementv1alpha1.EdgeDevice) []error
 	Metrics                  metrics.Metrics
+	SelectorIndex            *selectorindex.Index
 	MaxConcurrentReconciles  int
 }
 
@@ -76,10 +87,14 @@ func (r *EdgeDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 	edgeDeployment, err := r.EdgeDeploymentRepository.Read(ctx, req.Name, req.Namespace)
 	if err != nil {
 		if errors.IsNotFound(err) {
+			r.SelectorIndex.Remove(req.Namespace, req.Name)
 			return ctrl.Result{}, nil
 		}
 		return ctrl.Result{Requeue: true}, err
 	}
+	// the index may receive the deployment from the informer after it is reconciled here; update it right away for
+	// the devices to be matched consistently with the placement below
+	r.SelectorIndex.Update(edgeDeployment)
 
 	if edgeDeployment.DeletionTimestamp == nil && !utils.HasFinalizer(&edgeDeployment.ObjectMeta, YggdrasilDeviceReferenceFinalizer) {
 		deploymentCopy := edgeDeployment.DeepCopy()
@@ -92,7 +107,7 @@ func (r *EdgeDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 	}
 
 	if edgeDeployment.DeletionTimestamp == nil {
-		updated, err := r.updateLabelsFromSelector(ctx, edgeDeployment)
+		updated, err := r.removeSelectorLabels(ctx, edgeDeployment)
 		if err != nil {
 			return ctrl.Result{Requeue: true}, err
 		}
@@ -102,6 +117,11 @@ func (r *EdgeDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 			// return here in order to avoid executing rest of the code twice
 			return ctrl.Result{}, nil
 		}
+
+		err = r.updateDependencyCycle(ctx, edgeDeployment)
+		if err != nil {
+			return ctrl.Result{Requeue: true}, err
+		}
 	}
 
 	labelledDevices, err := r.getLabelledEdgeDevices(ctx, edgeDeployment.Name, edgeDeployment.Namespace)
@@ -130,7 +150,17 @@ func (r *EdgeDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 		return ctrl.Result{}, nil
 	}
 
-	err = r.addDeploymentsToDevices(ctx, edgeDeployment.Name, edgeDevices)
+	deployed := func(edgeDevice *managementv1alpha1.EdgeDevice) ([]managementv1alpha1.EdgeDeployment, error) {
+		return r.getDeployedEdgeDeployments(ctx, edgeDevice, edgeDeployment)
+	}
+	placementResult, err := placement.Place(edgeDeployment, edgeDevices, deployed)
+	if err != nil {
+		logger.Error(err, "Cannot place Edge Deployment")
+		return ctrl.Result{Requeue: true}, err
+	}
+	edgeDevices = placementResult.Devices
+
+	err = r.addDeploymentsToDevices(ctx, edgeDeployment, edgeDevices)
 	if err != nil {
 		return ctrl.Result{Requeue: true}, err
 	}
@@ -140,9 +170,112 @@ func (r *EdgeDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 		return ctrl.Result{Requeue: true}, err
 	}
 
+	err = r.pruneDataUploadErrors(ctx, edgeDeployment, edgeDevices)
+	if err != nil {
+		return ctrl.Result{Requeue: true}, err
+	}
+
+	err = r.updateUnplacedDevices(ctx, edgeDeployment, placementResult.Unplaced)
+	if err != nil {
+		return ctrl.Result{Requeue: true}, err
+	}
+
 	return ctrl.Result{}, nil
 }
 
+// updateUnplacedDevices reports devices matching the selector the deployment is not deployed to
+func (r *EdgeDeploymentReconciler) updateUnplacedDevices(ctx context.Context, edgeDeployment *managementv1alpha1.EdgeDeployment, unplaced []managementv1alpha1.UnplacedDevice) error {
+	if len(unplaced) > placement.MaxUnplacedDevices {
+		unplaced = unplaced[:placement.MaxUnplacedDevices]
+	}
+	if len(unplaced) == 0 && len(edgeDeployment.Status.UnplacedDevices) == 0 ||
+		reflect.DeepEqual(unplaced, edgeDeployment.Status.UnplacedDevices) {
+		return nil
+	}
+	patch := client.MergeFromWithOptions(edgeDeployment.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	edgeDeployment.Status.UnplacedDevices = unplaced
+	return r.EdgeDeploymentRepository.PatchStatus(ctx, edgeDeployment, &patch)
+}
+
+// updateDependencyCycle reports the dependency cycle the deployment is part of, if any. The webhook rejects only
+// deployments depending on themselves: cycles spanning several deployments can be created one deployment at a time.
+func (r *EdgeDeploymentReconciler) updateDependencyCycle(ctx context.Context, edgeDeployment *managementv1alpha1.EdgeDeployment) error {
+	cycle, err := r.findDependencyCycle(ctx, edgeDeployment)
+	if err != nil {
+		return err
+	}
+	if equalNames(cycle, edgeDeployment.Status.DependencyCycle) {
+		return nil
+	}
+	if len(cycle) > 0 {
+		log.FromContext(ctx).Info("Dependency cycle detected", "edgeDeployment", edgeDeployment.Name, "cycle", cycle)
+	}
+	patch := client.MergeFromWithOptions(edgeDeployment.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	edgeDeployment.Status.DependencyCycle = cycle
+	return r.EdgeDeploymentRepository.PatchStatus(ctx, edgeDeployment, &patch)
+}
+
+// findDependencyCycle returns the deployments, starting with the given one, on a path of dependencies leading back to
+// it; nil when there is none. Missing dependencies are ignored.
+func (r *EdgeDeploymentReconciler) findDependencyCycle(ctx context.Context, edgeDeployment *managementv1alpha1.EdgeDeployment) ([]string, error) {
+	visited := make(map[string]struct{})
+	path := []string{edgeDeployment.Name}
+	var visit func(dependsOn []string) (bool, error)
+	visit = func(dependsOn []string) (bool, error) {
+		for _, dependency := range dependsOn {
+			if dependency == edgeDeployment.Name {
+				return true, nil
+			}
+			if _, ok := visited[dependency]; ok {
+				continue
+			}
+			visited[dependency] = struct{}{}
+			d, err := r.EdgeDeploymentRepository.Read(ctx, dependency, edgeDeployment.Namespace)
+			if err != nil {
+				if errors.IsNotFound(err) {
+					continue
+				}
+				return false, err
+			}
+			path = append(path, dependency)
+			found, err := visit(d.Spec.DependsOn)
+			if err != nil || found {
+				return found, err
+			}
+			path = path[:len(path)-1]
+		}
+		return false, nil
+	}
+	found, err := visit(edgeDeployment.Spec.DependsOn)
+	if err != nil || !found {
+		return nil, err
+	}
+	return path, nil
+}
+
+// pruneDataUploadErrors removes data upload errors reported by devices the deployment is no longer deployed to
+func (r *EdgeDeploymentReconciler) pruneDataUploadErrors(ctx context.Context, edgeDeployment *managementv1alpha1.EdgeDeployment, edgeDevices []managementv1alpha1.EdgeDevice) error {
+	if len(edgeDeployment.Status.DataUploadErrors) == 0 {
+		return nil
+	}
+	devices := make(map[string]struct{})
+	for _, device := range edgeDevices {
+		devices[device.Name] = struct{}{}
+	}
+	var uploadErrors []managementv1alpha1.DataUploadError
+	for _, uploadError := range edgeDeployment.Status.DataUploadErrors {
+		if _, ok := devices[uploadError.Device]; ok {
+			uploadErrors = append(uploadErrors, uploadError)
+		}
+	}
+	if len(uploadErrors) == len(edgeDeployment.Status.DataUploadErrors) {
+		return nil
+	}
+	patch := client.MergeFromWithOptions(edgeDeployment.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	edgeDeployment.Status.DataUploadErrors = uploadErrors
+	return r.EdgeDeploymentRepository.PatchStatus(ctx, edgeDeployment, &patch)
+}
+
 func (r *EdgeDeploymentReconciler) finalizeRemoval(ctx context.Context, edgeDevices []managementv1alpha1.EdgeDevice, edgeDeployment *managementv1alpha1.EdgeDeployment) error {
 	f := func(input []managementv1alpha1.EdgeDevice) []error {
 		return r.removeDeploymentFromDevices(ctx, input, edgeDeployment.Name)
@@ -218,15 +351,29 @@ func (r *EdgeDeploymentReconciler) removeDeploymentFromNonMatchingDevices(ctx co
 	return nil
 }
 
-func (r *EdgeDeploymentReconciler) addDeploymentsToDevices(ctx context.Context, name string, edgeDevices []managementv1alpha1.EdgeDevice) error {
+func (r *EdgeDeploymentReconciler) addDeploymentsToDevices(ctx context.Context, edgeDeployment *managementv1alpha1.EdgeDeployment, edgeDevices []managementv1alpha1.EdgeDevice) error {
+	name := edgeDeployment.Name
+	checkCapacity := !capacity.Required(edgeDeployment).IsZero()
 	f := func(input []managementv1alpha1.EdgeDevice) []error {
 		var errs []error
 		for i := range input {
 			edgeDevice := input[i]
-			if !hasDeployment(edgeDevice, name) {
-				deploymentStatus := managementv1alpha1.Deployment{Name: name, Phase: managementv1alpha1.Deploying}
+			index := deploymentIndex(edgeDevice, name)
+			if index < 0 || !equalNames(edgeDevice.Status.Deployments[index].DependsOn, edgeDeployment.Spec.DependsOn) {
 				patch := client.MergeFrom(edgeDevice.DeepCopy())
-				edgeDevice.Status.Deployments = append(edgeDevice.Status.Deployments, deploymentStatus)
+				if index < 0 && checkCapacity {
+					// the capacity of the device was checked against this version of its status: when another
+					// deployment is placed on the device concurrently the patch conflicts, and the deployment is
+					// placed again
+					patch = client.MergeFromWithOptions(edgeDevice.DeepCopy(), client.MergeFromWithOptimisticLock{})
+				}
+				if index < 0 {
+					deploymentStatus := managementv1alpha1.Deployment{Name: name, Phase: managementv1alpha1.Deploying}
+					edgeDevice.Status.Deployments = append(edgeDevice.Status.Deployments, deploymentStatus)
+					index = len(edgeDevice.Status.Deployments) - 1
+				}
+				// heartbeats compute the blocked deployments from the dependencies recorded here
+				edgeDevice.Status.Deployments[index].DependsOn = edgeDeployment.Spec.DependsOn
 				err := r.EdgeDeviceRepository.PatchStatus(ctx, &edgeDevice, &patch)
 				if err != nil {
 					errs = append(errs, err)
@@ -256,6 +403,29 @@ func (r *EdgeDeploymentReconciler) addDeploymentsToDevices(ctx context.Context,
 	return nil
 }
 
+// getDeployedEdgeDeployments returns EdgeDeployments already deployed to the device when the given deployment declares
+// resources that have to be checked against the capacity of the device
+func (r *EdgeDeploymentReconciler) getDeployedEdgeDeployments(ctx context.Context, edgeDevice *managementv1alpha1.EdgeDevice, edgeDeployment *managementv1alpha1.EdgeDeployment) ([]managementv1alpha1.EdgeDeployment, error) {
+	if capacity.Required(edgeDeployment).IsZero() {
+		return nil, nil
+	}
+	var deployed []managementv1alpha1.EdgeDeployment
+	for _, deployment := range edgeDevice.Status.Deployments {
+		if deployment.Name == edgeDeployment.Name {
+			continue
+		}
+		d, err := r.EdgeDeploymentRepository.Read(ctx, deployment.Name, edgeDevice.Namespace)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		deployed = append(deployed, *d)
+	}
+	return deployed, nil
+}
+
 func (r *EdgeDeploymentReconciler) getMatchingEdgeDevices(ctx context.Context, edgeDeployment *managementv1alpha1.EdgeDeployment) ([]managementv1alpha1.EdgeDevice, error) {
 	var edgeDevices []managementv1alpha1.EdgeDevice
 	if edgeDeployment.Spec.Device != "" {
@@ -293,10 +463,52 @@ func (r *EdgeDeploymentReconciler) executeConcurrent(ctx context.Context, f Conc
 func (r *EdgeDeploymentReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&managementv1alpha1.EdgeDeployment{}).
+		Watches(&source.Kind{Type: &managementv1alpha1.EdgeDeployment{}},
+			handler.EnqueueRequestsFromMapFunc(relatedEdgeDeployments),
+			builder.WithPredicates(dependenciesChanged())).
 		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
 		Complete(r)
 }
 
+// relatedEdgeDeployments returns the dependencies of the deployment and the deployments of the dependency cycle it
+// reports, for a dependency cycle to be reported, or cleared, by all the deployments forming it
+func relatedEdgeDeployments(obj client.Object) []reconcile.Request {
+	edgeDeployment, ok := obj.(*managementv1alpha1.EdgeDeployment)
+	if !ok {
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, names := range [][]string{edgeDeployment.Spec.DependsOn, edgeDeployment.Status.DependencyCycle} {
+		for _, name := range names {
+			if name == edgeDeployment.Name {
+				continue
+			}
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: name, Namespace: edgeDeployment.Namespace},
+			})
+		}
+	}
+	return requests
+}
+
+// dependenciesChanged filters the EdgeDeployment updates that change its dependencies or the dependency cycle it reports
+func dependenciesChanged() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldDeployment, ok := e.ObjectOld.(*managementv1alpha1.EdgeDeployment)
+			if !ok {
+				return false
+			}
+			newDeployment, ok := e.ObjectNew.(*managementv1alpha1.EdgeDeployment)
+			if !ok {
+				return false
+			}
+			return !equalNames(oldDeployment.Spec.DependsOn, newDeployment.Spec.DependsOn) ||
+				!equalNames(oldDeployment.Status.DependencyCycle, newDeployment.Status.DependencyCycle)
+		},
+	}
+}
+
 func ExecuteConcurrent(concurrency uint, f ConcurrentFunc, edgeDevices []managementv1alpha1.EdgeDevice) []error {
 	if len(edgeDevices) == 0 || concurrency == 0 {
 		return nil
@@ -327,12 +539,28 @@ func hasLabelForDeployment(edgeDevice managementv1alpha1.EdgeDevice, deploymentN
 }
 
 func hasDeployment(edgeDevice managementv1alpha1.EdgeDevice, name string) bool {
-	for _, deployment := range edgeDevice.Status.Deployments {
+	return deploymentIndex(edgeDevice, name) >= 0
+}
+
+func deploymentIndex(edgeDevice managementv1alpha1.EdgeDevice, name string) int {
+	for i, deployment := range edgeDevice.Status.Deployments {
 		if deployment.Name == name {
-			return true
+			return i
+		}
+	}
+	return -1
+}
+
+func equalNames(dependencies1, dependencies2 []string) bool {
+	if len(dependencies1) != len(dependencies2) {
+		return false
+	}
+	for i := range dependencies1 {
+		if dependencies1[i] != dependencies2[i] {
+			return false
 		}
 	}
-	return false
+	return true
 }
 
 func merge(edgeDevices1 []managementv1alpha1.EdgeDevice, edgeDevices2 []managementv1alpha1.EdgeDevice) []managementv1alpha1.EdgeDevice {
@@ -387,51 +615,20 @@ func splitEdgeDevices(edgeDevices []managementv1alpha1.EdgeDevice, splitSize uin
 	return result
 }
 
-func (r *EdgeDeploymentReconciler) updateLabelsFromSelector(ctx context.Context, edgeDeployment *managementv1alpha1.EdgeDeployment) (bool, error) {
+// removeSelectorLabels removes the selector labels set on the deployment by previous versions of the operator,
+// which used them to find the deployments matching a device before the selector index replaced them
+func (r *EdgeDeploymentReconciler) removeSelectorLabels(ctx context.Context, edgeDeployment *managementv1alpha1.EdgeDeployment) (bool, error) {
 	edgeDeploymentCopy := edgeDeployment.DeepCopy()
-	UpdateSelectorLabels(edgeDeploymentCopy)
-	newLabels := edgeDeploymentCopy.Labels
+	for label := range edgeDeploymentCopy.Labels {
+		if labels.IsSelectorLabel(label) {
+			delete(edgeDeploymentCopy.Labels, label)
+		}
+	}
 
-	if (len(newLabels) == 0 && edgeDeployment.Labels == nil) || reflect.DeepEqual(newLabels, edgeDeployment.Labels) {
+	if len(edgeDeploymentCopy.Labels) == len(edgeDeployment.Labels) {
 		return false, nil
 	}
 
 	err := r.EdgeDeploymentRepository.Patch(ctx, edgeDeployment, edgeDeploymentCopy)
 	return true, err
 }
-
-func UpdateSelectorLabels(edgeDeployments ...*managementv1alpha1.EdgeDeployment) {
-	for _, edgeDeployment := range edgeDeployments {
-		deploymentLabels := edgeDeployment.Labels
-		if deploymentLabels == nil {
-			deploymentLabels = map[string]string{}
-			edgeDeployment.Labels = deploymentLabels
-		}
-		for label := range deploymentLabels {
-			if labels.IsSelectorLabel(label) {
-				delete(deploymentLabels, label)
-			}
-		}
-		if edgeDeployment.Spec.Device != "" {
-			selectorLabel := labels.CreateSelectorLabel(labels.DeviceNameLabel)
-			deploymentLabels[selectorLabel] = edgeDeployment.Spec.Device
-		} else {
-			labelSelector := edgeDeployment.Spec.DeviceSelector
-			if labelSelector != nil {
-				for label := range labelSelector.MatchLabels {
-					selectorLabel := labels.CreateSelectorLabel(label)
-					deploymentLabels[selectorLabel] = "true"
-				}
-				for _, requirement := range labelSelector.MatchExpressions {
-					if requirement.Operator == metav1.LabelSelectorOpDoesNotExist {
-						selectorLabel := labels.CreateSelectorLabel(labels.DoesNotExistLabel)
-						deploymentLabels[selectorLabel] = "true"
-					} else {
-						selectorLabel := labels.CreateSelectorLabel(requirement.Key)
-						deploymentLabels[selectorLabel] = "true"
-					}
-				}
-			}
-		}
-	}
-}
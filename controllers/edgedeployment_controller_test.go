@@ -11,10 +11,12 @@ import (
 	. "github.com/onsi/gomega"
 	"github.com/project-flotta/flotta-operator/api/v1alpha1"
 	"github.com/project-flotta/flotta-operator/controllers"
-	"github.com/project-flotta/flotta-operator/internal/labels"
 	"github.com/project-flotta/flotta-operator/internal/repository/edgedeployment"
 	"github.com/project-flotta/flotta-operator/internal/repository/edgedevice"
+	"github.com/project-flotta/flotta-operator/internal/selectorindex"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -53,6 +55,7 @@ var _ = Describe("Controllers", func() {
 			EdgeDeviceRepository:     edgeDeviceRepoMock,
 			Concurrency:              1,
 			ExecuteConcurrent:        controllers.ExecuteConcurrent,
+			SelectorIndex:            selectorindex.NewSynced(),
 		}
 
 		signalContext, cancelContext = context.WithCancel(context.TODO())
@@ -185,7 +188,6 @@ var _ = Describe("Controllers", func() {
 						Name:       "test",
 						Namespace:  "test",
 						Finalizers: []string{controllers.YggdrasilDeviceReferenceFinalizer},
-						Labels:     map[string]string{labels.CreateSelectorLabel("test"): "true"},
 					},
 					Spec: v1alpha1.EdgeDeploymentSpec{
 						DeviceSelector: &v1.LabelSelector{
@@ -282,6 +284,141 @@ var _ = Describe("Controllers", func() {
 				Expect(res).To(Equal(reconcile.Result{Requeue: false, RequeueAfter: 0}))
 			})
 
+			It("Devices without enough capacity do not get deployments", func() {
+				// given
+				deploymentData.Spec.Pod.Spec.Containers = []corev1.Container{{
+					Name: "container",
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2Gi")},
+					},
+				}}
+				device.Status.Hardware = &v1alpha1.Hardware{
+					Memory: &v1alpha1.Memory{PhysicalBytes: 1024 * 1024 * 1024},
+				}
+
+				edgeDeviceRepoMock.EXPECT().
+					ListForSelector(gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(nil, nil).
+					Times(1)
+
+				edgeDeviceRepoMock.EXPECT().
+					ListForSelector(gomock.Any(), gomock.Any(), gomock.Any()).
+					Return([]v1alpha1.EdgeDevice{*device}, nil).
+					Times(1)
+
+				edgeDeviceRepoMock.EXPECT().
+					PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(0)
+
+				edgeDeviceRepoMock.EXPECT().
+					Patch(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(0)
+
+				deployRepoMock.EXPECT().
+					PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).
+					Do(func(ctx context.Context, edgeDeployment *v1alpha1.EdgeDeployment, patch *client.Patch) {
+						Expect(edgeDeployment.Status.UnplacedDevices).To(Equal([]v1alpha1.UnplacedDevice{{
+							Device: "testdevice",
+							Reason: "insufficient memory (required 2147483648 bytes, available 1073741824 bytes) on device testdevice",
+						}}))
+					}).
+					Return(nil).
+					Times(1)
+
+				// when
+				res, err := edgeDeploymentReconciler.Reconcile(context.TODO(), req)
+
+				// then
+				Expect(err).NotTo(HaveOccurred())
+				Expect(res).To(Equal(reconcile.Result{Requeue: false, RequeueAfter: 0}))
+			})
+
+			It("Devices placed concurrently on are placed again", func() {
+				// given
+				deploymentData.Spec.Pod.Spec.Containers = []corev1.Container{{
+					Name: "container",
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+					},
+				}}
+				device.ResourceVersion = "5"
+				device.Status.Hardware = &v1alpha1.Hardware{
+					Memory: &v1alpha1.Memory{PhysicalBytes: 2 * 1024 * 1024 * 1024},
+				}
+
+				edgeDeviceRepoMock.EXPECT().
+					ListForSelector(gomock.Any(), gomock.Any(), gomock.Any()).
+					Return([]v1alpha1.EdgeDevice{*device}, nil).
+					Times(2)
+
+				edgeDeviceRepoMock.EXPECT().
+					PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, patch *client.Patch) error {
+						data, err := (*patch).Data(edgeDevice)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(string(data)).To(ContainSubstring(`"resourceVersion":"5"`))
+						return errors.NewConflict(schema.GroupResource{Resource: "edgedevices"}, edgeDevice.Name, fmt.Errorf("modified"))
+					}).
+					Times(1)
+
+				edgeDeviceRepoMock.EXPECT().
+					Patch(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(0)
+
+				// when
+				res, err := edgeDeploymentReconciler.Reconcile(context.TODO(), req)
+
+				// then
+				Expect(err).To(HaveOccurred())
+				Expect(res).To(Equal(reconcile.Result{Requeue: true, RequeueAfter: 0}))
+			})
+
+			It("Deployments are placed on limited number of devices", func() {
+				// given
+				deploymentData.Spec.Placement = &v1alpha1.PlacementConfiguration{MaxDevices: 1}
+				otherDevice := getDevice("testdevice2")
+
+				edgeDeviceRepoMock.EXPECT().
+					ListForSelector(gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(nil, nil).
+					Times(1)
+
+				edgeDeviceRepoMock.EXPECT().
+					ListForSelector(gomock.Any(), gomock.Any(), gomock.Any()).
+					Return([]v1alpha1.EdgeDevice{*otherDevice, *device}, nil).
+					Times(1)
+
+				edgeDeviceRepoMock.EXPECT().
+					PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).
+					Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, patch *client.Patch) {
+						Expect(edgeDevice.Name).To(Equal("testdevice"))
+					}).
+					Return(nil).
+					Times(1)
+
+				edgeDeviceRepoMock.EXPECT().
+					Patch(gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(nil).
+					Times(1)
+
+				deployRepoMock.EXPECT().
+					PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).
+					Do(func(ctx context.Context, edgeDeployment *v1alpha1.EdgeDeployment, patch *client.Patch) {
+						Expect(edgeDeployment.Status.UnplacedDevices).To(Equal([]v1alpha1.UnplacedDevice{
+							{Device: "testdevice2", Reason: "maximum number of devices (1) reached"},
+						}))
+					}).
+					Return(nil).
+					Times(1)
+
+				// when
+				res, err := edgeDeploymentReconciler.Reconcile(context.TODO(), req)
+
+				// then
+				Expect(err).NotTo(HaveOccurred())
+				Expect(res).To(Equal(reconcile.Result{Requeue: false, RequeueAfter: 0}))
+			})
+
 			It("Only correct devices got deployments", func() {
 				// When  running workloads, the Reconcile got all edgedevices that have
 				// the label workload/name and all matching devices. If one device does
@@ -357,7 +494,6 @@ var _ = Describe("Controllers", func() {
 						Name:       "test",
 						Namespace:  "test",
 						Finalizers: []string{controllers.YggdrasilDeviceReferenceFinalizer},
-						Labels:     map[string]string{labels.CreateSelectorLabel(labels.DeviceNameLabel): "test"},
 					},
 					Spec: v1alpha1.EdgeDeploymentSpec{
 						Device: "test",
@@ -478,7 +614,6 @@ var _ = Describe("Controllers", func() {
 						Namespace:         "test",
 						Finalizers:        []string{controllers.YggdrasilDeviceReferenceFinalizer},
 						DeletionTimestamp: &v1.Time{Time: time.Now()},
-						Labels:            map[string]string{labels.CreateSelectorLabel("test"): "true"},
 					},
 					Spec: v1alpha1.EdgeDeploymentSpec{
 						DeviceSelector: &v1.LabelSelector{
@@ -675,7 +810,6 @@ var _ = Describe("Controllers", func() {
 					ObjectMeta: v1.ObjectMeta{
 						Name:       "test",
 						Namespace:  "test",
-						Labels:     map[string]string{labels.CreateSelectorLabel("test"): "true"},
 						Finalizers: []string{controllers.YggdrasilDeviceReferenceFinalizer},
 					},
 					Spec: v1alpha1.EdgeDeploymentSpec{
@@ -792,59 +926,82 @@ var _ = Describe("Controllers", func() {
 				Expect(actualSplit).To(Equal(expectedSplit))
 			})
 		})
-		Context("Selector labels", func() {
+		Context("Dependencies", func() {
 			var (
-				deploymentData         *v1alpha1.EdgeDeployment
-				expectedSelectorLabels map[string]string
+				deploymentData *v1alpha1.EdgeDeployment
 			)
 
-			BeforeEach(func() {
-				expectedSelectorLabels = map[string]string{
-					labels.CreateSelectorLabel("matchlabel1"):            "true",
-					labels.CreateSelectorLabel("matchexp1"):              "true",
-					labels.CreateSelectorLabel("matchexp2"):              "true",
-					labels.CreateSelectorLabel(labels.DoesNotExistLabel): "true",
-				}
-				deploymentData = &v1alpha1.EdgeDeployment{
+			getDeployment := func(name string, dependsOn ...string) *v1alpha1.EdgeDeployment {
+				return &v1alpha1.EdgeDeployment{
 					ObjectMeta: v1.ObjectMeta{
-						Name:       "test",
+						Name:       name,
 						Namespace:  "test",
 						Finalizers: []string{controllers.YggdrasilDeviceReferenceFinalizer},
 					},
 					Spec: v1alpha1.EdgeDeploymentSpec{
 						DeviceSelector: &v1.LabelSelector{
-							MatchLabels: map[string]string{"matchlabel1": "matchlabel1"},
-							MatchExpressions: []v1.LabelSelectorRequirement{
-								{
-									Key: "matchexp1", Operator: metav1.LabelSelectorOpIn, Values: []string{"matchexp1"},
-								},
-								{
-									Key: "matchexp2", Operator: metav1.LabelSelectorOpExists, Values: nil,
-								},
-								{
-									Key: "matchexp3", Operator: metav1.LabelSelectorOpDoesNotExist, Values: nil,
-								},
-								{
-									Key: "matchexp4", Operator: metav1.LabelSelectorOpDoesNotExist, Values: []string{},
-								},
-							},
+							MatchLabels: map[string]string{"test": "test"},
 						},
-						Type: "test",
-						Pod:  v1alpha1.Pod{},
-						Data: &v1alpha1.DataConfiguration{},
+						Type:      "test",
+						DependsOn: dependsOn,
 					}}
+			}
 
-				deployRepoMock.EXPECT().Read(gomock.Any(), gomock.Any(), gomock.Any()).
+			BeforeEach(func() {
+				deploymentData = getDeployment("test", "backend")
+
+				deployRepoMock.EXPECT().Read(gomock.Any(), "test", "test").
 					Return(deploymentData, nil).Times(1)
+			})
+
+			It("Dependency cycles are reported", func() {
+				// given
+				deployRepoMock.EXPECT().Read(gomock.Any(), "backend", "test").
+					Return(getDeployment("backend", "cache", "database"), nil).Times(1)
+				deployRepoMock.EXPECT().Read(gomock.Any(), "cache", "test").
+					Return(getDeployment("cache"), nil).Times(1)
+				deployRepoMock.EXPECT().Read(gomock.Any(), "database", "test").
+					Return(getDeployment("database", "test"), nil).Times(1)
+
+				edgeDeviceRepoMock.EXPECT().
+					ListForSelector(gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(nil, nil).
+					Times(2)
 
 				deployRepoMock.EXPECT().
-					Patch(gomock.Any(), gomock.Any(), gomock.Any()).
-					Do(func(ctx context.Context, old, new *v1alpha1.EdgeDeployment) {
-						Expect(new.Labels).To(Equal(expectedSelectorLabels))
-					}).Times(1)
+					PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).
+					Do(func(ctx context.Context, edgeDeployment *v1alpha1.EdgeDeployment, patch *client.Patch) {
+						Expect(edgeDeployment.Status.DependencyCycle).To(Equal([]string{"test", "backend", "database"}))
+					}).
+					Return(nil).
+					Times(1)
+
+				// when
+				res, err := edgeDeploymentReconciler.Reconcile(context.TODO(), req)
+
+				// then
+				Expect(err).NotTo(HaveOccurred())
+				Expect(res).To(Equal(reconcile.Result{Requeue: false, RequeueAfter: 0}))
 			})
-			It("New EdgeDeployment", func() {
+
+			It("Resolved dependency cycles are cleared", func() {
 				// given
+				deploymentData.Status.DependencyCycle = []string{"test", "backend"}
+				deployRepoMock.EXPECT().Read(gomock.Any(), "backend", "test").
+					Return(nil, errors.NewNotFound(schema.GroupResource{Resource: "edgedeployments"}, "backend")).Times(1)
+
+				edgeDeviceRepoMock.EXPECT().
+					ListForSelector(gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(nil, nil).
+					Times(2)
+
+				deployRepoMock.EXPECT().
+					PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).
+					Do(func(ctx context.Context, edgeDeployment *v1alpha1.EdgeDeployment, patch *client.Patch) {
+						Expect(edgeDeployment.Status.DependencyCycle).To(BeEmpty())
+					}).
+					Return(nil).
+					Times(1)
 
 				// when
 				res, err := edgeDeploymentReconciler.Reconcile(context.TODO(), req)
@@ -854,12 +1011,32 @@ var _ = Describe("Controllers", func() {
 				Expect(res).To(Equal(reconcile.Result{Requeue: false, RequeueAfter: 0}))
 			})
 
-			It("Updated EdgeDeployment", func() {
+			It("Dependencies are recorded in the status of devices", func() {
 				// given
-				deploymentData.Labels = map[string]string{
-					labels.CreateSelectorLabel("todelete1"): "true",
-					labels.CreateSelectorLabel("todelete2"): "true",
+				device := getDevice("testdevice")
+				device.Labels = map[string]string{"workload/test": "true"}
+				device.Status.Deployments = []v1alpha1.Deployment{
+					{Name: "other", Phase: v1alpha1.Running},
+					{Name: "test", Phase: v1alpha1.Running},
 				}
+				deployRepoMock.EXPECT().Read(gomock.Any(), "backend", "test").
+					Return(getDeployment("backend"), nil).Times(1)
+
+				edgeDeviceRepoMock.EXPECT().
+					ListForSelector(gomock.Any(), gomock.Any(), gomock.Any()).
+					Return([]v1alpha1.EdgeDevice{*device}, nil).
+					Times(2)
+
+				edgeDeviceRepoMock.EXPECT().
+					PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).
+					Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, patch *client.Patch) {
+						Expect(edgeDevice.Status.Deployments).To(Equal([]v1alpha1.Deployment{
+							{Name: "other", Phase: v1alpha1.Running},
+							{Name: "test", Phase: v1alpha1.Running, DependsOn: []string{"backend"}},
+						}))
+					}).
+					Return(nil).
+					Times(1)
 
 				// when
 				res, err := edgeDeploymentReconciler.Reconcile(context.TODO(), req)
@@ -868,13 +1045,45 @@ var _ = Describe("Controllers", func() {
 				Expect(err).NotTo(HaveOccurred())
 				Expect(res).To(Equal(reconcile.Result{Requeue: false, RequeueAfter: 0}))
 			})
+		})
+
+		Context("Selector labels", func() {
+			var (
+				deploymentData *v1alpha1.EdgeDeployment
+			)
+
+			BeforeEach(func() {
+				deploymentData = &v1alpha1.EdgeDeployment{
+					ObjectMeta: v1.ObjectMeta{
+						Name:       "test",
+						Namespace:  "test",
+						Finalizers: []string{controllers.YggdrasilDeviceReferenceFinalizer},
+						Labels: map[string]string{
+							"selector/matchlabel1": "true",
+							"selector/devicename":  "test",
+							"app":                  "camera",
+						},
+					},
+					Spec: v1alpha1.EdgeDeploymentSpec{
+						DeviceSelector: &v1.LabelSelector{
+							MatchLabels: map[string]string{"matchlabel1": "matchlabel1"},
+						},
+						Type: "test",
+						Pod:  v1alpha1.Pod{},
+						Data: &v1alpha1.DataConfiguration{},
+					}}
+
+				deployRepoMock.EXPECT().Read(gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(deploymentData, nil).Times(1)
+			})
 
-			It("Device name label", func() {
+			It("Legacy selector labels are removed", func() {
 				// given
-				deploymentData.Spec.Device = "test"
-				expectedSelectorLabels = map[string]string{
-					labels.CreateSelectorLabel(labels.DeviceNameLabel): "test",
-				}
+				deployRepoMock.EXPECT().
+					Patch(gomock.Any(), gomock.Any(), gomock.Any()).
+					Do(func(ctx context.Context, old, new *v1alpha1.EdgeDeployment) {
+						Expect(new.Labels).To(Equal(map[string]string{"app": "camera"}))
+					}).Times(1)
 
 				// when
 				res, err := edgeDeploymentReconciler.Reconcile(context.TODO(), req)
@@ -883,6 +1092,30 @@ var _ = Describe("Controllers", func() {
 				Expect(err).NotTo(HaveOccurred())
 				Expect(res).To(Equal(reconcile.Result{Requeue: false, RequeueAfter: 0}))
 			})
+
+			It("Selector index is updated", func() {
+				// given
+				deployRepoMock.EXPECT().
+					Patch(gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(nil).Times(1)
+				device := &v1alpha1.EdgeDevice{
+					ObjectMeta: v1.ObjectMeta{
+						Name:      "device",
+						Namespace: "test",
+						Labels:    map[string]string{"matchlabel1": "matchlabel1"},
+					},
+				}
+
+				// when
+				_, err := edgeDeploymentReconciler.Reconcile(context.TODO(), req)
+
+				// then
+				Expect(err).NotTo(HaveOccurred())
+				matching, err := edgeDeploymentReconciler.SelectorIndex.Matching(device)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(matching).To(HaveLen(1))
+				Expect(matching[0].Name).To(Equal("test"))
+			})
 		})
 	})
 })
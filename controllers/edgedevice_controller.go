@@ -24,11 +24,17 @@ import (
 
 	obv1 "github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
 	"github.com/project-flotta/flotta-operator/internal/metrics"
+	"github.com/project-flotta/flotta-operator/internal/mtls"
+	"github.com/project-flotta/flotta-operator/internal/repository/edgedeployment"
 	"github.com/project-flotta/flotta-operator/internal/repository/edgedevice"
 	"github.com/project-flotta/flotta-operator/internal/storage"
+	"github.com/project-flotta/flotta-operator/internal/yggdrasil"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -36,22 +42,32 @@ import (
 	managementv1alpha1 "github.com/project-flotta/flotta-operator/api/v1alpha1"
 )
 
+const (
+	// decommissionPollInterval is how often the final data upload of a device being decommissioned is checked
+	decommissionPollInterval = 30 * time.Second
+)
+
 // EdgeDeviceReconciler reconciles a EdgeDevice object
 type EdgeDeviceReconciler struct {
 	client.Client
-	Scheme                  *runtime.Scheme
-	EdgeDeviceRepository    edgedevice.Repository
-	ObcAutoCreate           bool
-	Claimer                 *storage.Claimer
-	Metrics                 metrics.Metrics
-	MaxConcurrentReconciles int
+	Scheme                   *runtime.Scheme
+	EdgeDeviceRepository     edgedevice.Repository
+	EdgeDeploymentRepository edgedeployment.Repository
+	ObcAutoCreate            bool
+	Claimer                  *storage.Claimer
+	Revocations              *mtls.RevocationList
+	Recorder                 record.EventRecorder
+	Metrics                  metrics.Metrics
+	MaxConcurrentReconciles  int
 }
 
 //+kubebuilder:rbac:groups=management.project-flotta.io,resources=edgedevices,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=management.project-flotta.io,resources=edgedevices/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=management.project-flotta.io,resources=edgedevices/finalizers,verbs=update
-//+kubebuilder:rbac:groups=objectbucket.io,resources=objectbucketclaims,verbs=get;list;watch;create;update;patch
-//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups=management.project-flotta.io,resources=edgedevicesets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=management.project-flotta.io,resources=edgedeployments,verbs=get
+//+kubebuilder:rbac:groups=objectbucket.io,resources=objectbucketclaims,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update
 //+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create
 //+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 //+kubebuilder:rbac:groups=route.openshift.io,resources=routes,verbs=get;list;watch
@@ -77,6 +93,10 @@ func (r *EdgeDeviceReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{Requeue: true}, err
 	}
 
+	if edgeDevice.Spec.Decommission != nil {
+		return r.decommission(ctx, edgeDevice)
+	}
+
 	if !r.ObcAutoCreate && !storage.ShouldCreateOBC(edgeDevice) {
 		return ctrl.Result{}, nil
 	}
@@ -123,6 +143,119 @@ func (r *EdgeDeviceReconciler) addObcReference(ctx context.Context, edgeDevice *
 	return r.EdgeDeviceRepository.PatchStatus(ctx, edgeDevice, &patch)
 }
 
+// decommission requests the final data upload of the device and waits for it, unless forced or timed out. Then it
+// deletes the Object Bucket Claim of the device according to the retention policy, revokes the device certificate
+// and deletes the EdgeDevice without waiting for the device to acknowledge it.
+func (r *EdgeDeviceReconciler) decommission(ctx context.Context, edgeDevice *managementv1alpha1.EdgeDevice) (ctrl.Result, error) {
+	logger := log.FromContext(ctx, "EdgeDevice Name", edgeDevice.Name, "EdgeDevice Namespace", edgeDevice.Namespace)
+	decommission := edgeDevice.Spec.Decommission
+
+	if edgeDevice.Status.Decommission == nil {
+		patch := client.MergeFrom(edgeDevice.DeepCopy())
+		edgeDevice.Status.Decommission = &managementv1alpha1.DecommissionStatus{StartTime: metav1.Now()}
+		err := r.EdgeDeviceRepository.PatchStatus(ctx, edgeDevice, &patch)
+		if err != nil {
+			return ctrl.Result{Requeue: true}, err
+		}
+		r.Recorder.Event(edgeDevice, corev1.EventTypeNormal, "DecommissionStarted", "Final data upload requested from the device")
+	}
+
+	finalDataUpload := "skipped"
+	if !decommission.Force {
+		uploaded, err := r.isFinalDataUploaded(ctx, edgeDevice)
+		if err != nil {
+			return ctrl.Result{Requeue: true}, err
+		}
+		if uploaded {
+			finalDataUpload = "completed"
+		} else {
+			timeout := time.Duration(decommission.TimeoutSeconds) * time.Second
+			remaining := time.Until(edgeDevice.Status.Decommission.StartTime.Add(timeout))
+			if remaining > 0 {
+				if remaining > decommissionPollInterval {
+					remaining = decommissionPollInterval
+				}
+				return ctrl.Result{RequeueAfter: remaining}, nil
+			}
+			finalDataUpload = "timed out"
+		}
+	}
+	logger.Info("Decommissioning device", "finalDataUpload", finalDataUpload)
+
+	bucket := "no bucket"
+	if edgeDevice.Status.DataOBC != nil && len(*edgeDevice.Status.DataOBC) > 0 {
+		bucket = "bucket retained"
+		if decommission.DataRetention == managementv1alpha1.DeleteData {
+			err := r.Claimer.DeleteClaim(ctx, *edgeDevice.Status.DataOBC, edgeDevice.Namespace)
+			if err != nil && !errors.IsNotFound(err) {
+				logger.Error(err, "Cannot delete object bucket claim of the device")
+				return ctrl.Result{Requeue: true}, err
+			}
+			bucket = "bucket deleted"
+		}
+	}
+
+	err := r.Revocations.Revoke(ctx, edgeDevice.Namespace, edgeDevice.Name, time.Now())
+	if err != nil {
+		logger.Error(err, "Cannot revoke certificate of the device")
+		return ctrl.Result{Requeue: true}, err
+	}
+
+	r.Recorder.Eventf(edgeDevice, corev1.EventTypeNormal, "Decommissioned",
+		"Device decommissioned: final data upload %s, %s, certificate revoked", finalDataUpload, bucket)
+
+	// the device is not expected to acknowledge the deletion anymore
+	err = removeYggdrasilFinalizers(ctx, r.EdgeDeviceRepository, edgeDevice)
+	if err != nil && !errors.IsNotFound(err) {
+		return ctrl.Result{Requeue: true}, err
+	}
+
+	if edgeDevice.DeletionTimestamp == nil {
+		err = r.EdgeDeviceRepository.Delete(ctx, edgeDevice)
+		if err != nil && !errors.IsNotFound(err) {
+			return ctrl.Result{Requeue: true}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// removeYggdrasilFinalizers removes the finalizers that wait for the device to acknowledge the deletion of the EdgeDevice
+func removeYggdrasilFinalizers(ctx context.Context, repository edgedevice.Repository, edgeDevice *managementv1alpha1.EdgeDevice) error {
+	deviceCopy := edgeDevice.DeepCopy()
+	deviceCopy.Finalizers = nil
+	for _, finalizer := range edgeDevice.Finalizers {
+		if finalizer != yggdrasil.YggdrasilConnectionFinalizer && finalizer != yggdrasil.YggdrasilWorkloadFinalizer {
+			deviceCopy.Finalizers = append(deviceCopy.Finalizers, finalizer)
+		}
+	}
+	if len(deviceCopy.Finalizers) == len(edgeDevice.Finalizers) {
+		return nil
+	}
+	return repository.Patch(ctx, edgeDevice, deviceCopy)
+}
+
+// isFinalDataUploaded checks whether the device uploaded the data of all its deployments with data configuration
+// since the decommissioning started
+func (r *EdgeDeviceReconciler) isFinalDataUploaded(ctx context.Context, edgeDevice *managementv1alpha1.EdgeDevice) (bool, error) {
+	startTime := edgeDevice.Status.Decommission.StartTime
+	for _, deployment := range edgeDevice.Status.Deployments {
+		edgeDeployment, err := r.EdgeDeploymentRepository.Read(ctx, deployment.Name, edgeDevice.Namespace)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return false, err
+		}
+		if edgeDeployment.Spec.Data == nil || len(edgeDeployment.Spec.Data.Paths) == 0 {
+			continue
+		}
+		if deployment.LastDataUpload.Before(&startTime) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *EdgeDeviceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
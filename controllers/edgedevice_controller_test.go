@@ -2,6 +2,8 @@ package controllers_test
 
 import (
 	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"fmt"
 	"time"
 
@@ -11,13 +13,17 @@ import (
 	. "github.com/onsi/gomega"
 	"github.com/project-flotta/flotta-operator/api/v1alpha1"
 	"github.com/project-flotta/flotta-operator/controllers"
+	"github.com/project-flotta/flotta-operator/internal/mtls"
+	"github.com/project-flotta/flotta-operator/internal/repository/edgedeployment"
 	"github.com/project-flotta/flotta-operator/internal/repository/edgedevice"
 	"github.com/project-flotta/flotta-operator/internal/storage"
+	"github.com/project-flotta/flotta-operator/internal/yggdrasil"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
@@ -32,6 +38,7 @@ var _ = Describe("EdgeDevice controller", func() {
 		signalContext        context.Context
 
 		edgeDeviceRepoMock *edgedevice.MockRepository
+		deployRepoMock     *edgedeployment.MockRepository
 		k8sManager         manager.Manager
 	)
 
@@ -56,6 +63,7 @@ var _ = Describe("EdgeDevice controller", func() {
 		}()
 
 		edgeDeviceRepoMock = edgedevice.NewMockRepository(mockCtrl)
+		deployRepoMock = edgedeployment.NewMockRepository(mockCtrl)
 
 	})
 
@@ -78,11 +86,14 @@ var _ = Describe("EdgeDevice controller", func() {
 			}
 
 			edgeDeviceReconciler = &controllers.EdgeDeviceReconciler{
-				Client:               k8sClient,
-				Scheme:               k8sManager.GetScheme(),
-				EdgeDeviceRepository: edgeDeviceRepoMock,
-				Claimer:              storage.NewClaimer(k8sClient),
-				ObcAutoCreate:        false,
+				Client:                   k8sClient,
+				Scheme:                   k8sManager.GetScheme(),
+				EdgeDeviceRepository:     edgeDeviceRepoMock,
+				EdgeDeploymentRepository: deployRepoMock,
+				Claimer:                  storage.NewClaimer(k8sClient),
+				Revocations:              mtls.NewRevocationList(k8sClient, "default", "default", 30*24*time.Hour),
+				Recorder:                 record.NewFakeRecorder(10),
+				ObcAutoCreate:            false,
 			}
 		})
 
@@ -220,6 +231,154 @@ var _ = Describe("EdgeDevice controller", func() {
 			Expect(res.Requeue).To(BeFalse())
 		})
 
+		Context("Decommission", func() {
+			var (
+				device         *v1alpha1.EdgeDevice
+				edgeDeployment *v1alpha1.EdgeDeployment
+				recorder       *record.FakeRecorder
+			)
+
+			BeforeEach(func() {
+				device = getDevice("test")
+				device.Finalizers = []string{yggdrasil.YggdrasilConnectionFinalizer, yggdrasil.YggdrasilWorkloadFinalizer}
+				device.Spec.Decommission = &v1alpha1.Decommission{TimeoutSeconds: 3600, DataRetention: v1alpha1.RetainData}
+				device.Status.Deployments = []v1alpha1.Deployment{{Name: "workload", Phase: v1alpha1.Running}}
+
+				edgeDeployment = &v1alpha1.EdgeDeployment{
+					ObjectMeta: v1.ObjectMeta{Name: "workload", Namespace: "default"},
+					Spec: v1alpha1.EdgeDeploymentSpec{
+						Data: &v1alpha1.DataConfiguration{Paths: []v1alpha1.DataPath{{Source: "stats", Target: "statistics"}}},
+					},
+				}
+
+				recorder = record.NewFakeRecorder(10)
+				edgeDeviceReconciler.Recorder = recorder
+
+				edgeDeviceRepoMock.EXPECT().
+					Read(gomock.Any(), req.Name, req.Namespace).
+					Return(device, nil).
+					Times(1)
+			})
+
+			expectDeleted := func() {
+				edgeDeviceRepoMock.EXPECT().
+					Patch(gomock.Any(), gomock.Any(), gomock.Any()).
+					Do(func(ctx context.Context, old, new *v1alpha1.EdgeDevice) {
+						Expect(new.Finalizers).To(BeEmpty())
+					}).
+					Return(nil).
+					Times(1)
+				edgeDeviceRepoMock.EXPECT().
+					Delete(gomock.Any(), device).
+					Return(nil).
+					Times(1)
+			}
+
+			It("Starts decommission and waits for the final data upload", func() {
+				// given
+				edgeDeviceRepoMock.EXPECT().
+					PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).
+					Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, patch *client.Patch) {
+						Expect(edgeDevice.Status.Decommission).NotTo(BeNil())
+					}).
+					Return(nil).
+					Times(1)
+				deployRepoMock.EXPECT().
+					Read(gomock.Any(), "workload", "default").
+					Return(edgeDeployment, nil).
+					Times(1)
+
+				// when
+				res, err := edgeDeviceReconciler.Reconcile(context.TODO(), req)
+
+				// then
+				Expect(err).NotTo(HaveOccurred())
+				Expect(res.RequeueAfter).To(BeNumerically(">", 0))
+				Expect(recorder.Events).To(Receive(ContainSubstring("DecommissionStarted")))
+			})
+
+			It("Decommissions the device once the final data is uploaded", func() {
+				// given
+				startTime := v1.NewTime(time.Now().Add(-time.Minute))
+				device.Status.Decommission = &v1alpha1.DecommissionStatus{StartTime: startTime}
+				device.Status.Deployments[0].LastDataUpload = v1.Now()
+				deployRepoMock.EXPECT().
+					Read(gomock.Any(), "workload", "default").
+					Return(edgeDeployment, nil).
+					Times(1)
+				expectDeleted()
+
+				// when
+				res, err := edgeDeviceReconciler.Reconcile(context.TODO(), req)
+
+				// then
+				Expect(err).NotTo(HaveOccurred())
+				Expect(res).To(Equal(reconcile.Result{}))
+				Expect(recorder.Events).To(Receive(ContainSubstring("final data upload completed")))
+			})
+
+			It("Decommissions the device when the final data upload times out", func() {
+				// given
+				startTime := v1.NewTime(time.Now().Add(-2 * time.Hour))
+				device.Status.Decommission = &v1alpha1.DecommissionStatus{StartTime: startTime}
+				deployRepoMock.EXPECT().
+					Read(gomock.Any(), "workload", "default").
+					Return(edgeDeployment, nil).
+					Times(1)
+				expectDeleted()
+
+				// when
+				res, err := edgeDeviceReconciler.Reconcile(context.TODO(), req)
+
+				// then
+				Expect(err).NotTo(HaveOccurred())
+				Expect(res).To(Equal(reconcile.Result{}))
+				Expect(recorder.Events).To(Receive(ContainSubstring("final data upload timed out")))
+			})
+
+			It("Decommissions the device immediately when forced", func() {
+				// given
+				device.Spec.Decommission.Force = true
+				device.Status.Decommission = &v1alpha1.DecommissionStatus{StartTime: v1.Now()}
+				expectDeleted()
+
+				// when
+				res, err := edgeDeviceReconciler.Reconcile(context.TODO(), req)
+
+				// then
+				Expect(err).NotTo(HaveOccurred())
+				Expect(res).To(Equal(reconcile.Result{}))
+				Expect(recorder.Events).To(Receive(ContainSubstring("final data upload skipped")))
+
+				revoked, err := edgeDeviceReconciler.Revocations.IsRevoked(context.TODO(), []*x509.Certificate{{
+					Subject:   pkix.Name{CommonName: "test"},
+					NotBefore: time.Now().Add(-48 * time.Hour),
+				}})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(revoked).To(BeTrue())
+			})
+
+			It("Does not delete a device that is already being deleted", func() {
+				// given
+				device.Spec.Decommission.Force = true
+				device.Status.Decommission = &v1alpha1.DecommissionStatus{StartTime: v1.Now()}
+				deletionTimestamp := v1.Now()
+				device.DeletionTimestamp = &deletionTimestamp
+				edgeDeviceRepoMock.EXPECT().
+					Patch(gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(nil).
+					Times(1)
+				edgeDeviceRepoMock.EXPECT().
+					Delete(gomock.Any(), gomock.Any()).
+					Times(0)
+
+				// when
+				_, err := edgeDeviceReconciler.Reconcile(context.TODO(), req)
+
+				// then
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
 	})
 
 	It("should not attach OBC to EdgeDevice when OBC creation (manual and automatic) is disabled", func() {
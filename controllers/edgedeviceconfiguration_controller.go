@@ -0,0 +1,463 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	managementv1alpha1 "github.com/project-flotta/flotta-operator/api/v1alpha1"
+	flottalabels "github.com/project-flotta/flotta-operator/internal/labels"
+	"github.com/project-flotta/flotta-operator/internal/metrics"
+	"github.com/project-flotta/flotta-operator/internal/references"
+	"github.com/project-flotta/flotta-operator/internal/repository/edgedevice"
+	"github.com/project-flotta/flotta-operator/internal/repository/metricsallowlist"
+	"github.com/project-flotta/flotta-operator/internal/yggdrasil"
+	"github.com/project-flotta/flotta-operator/models"
+)
+
+const (
+	// secretsIndexKey indexes EdgeDevices, EdgeDeviceSets and EdgeDeployments by the Secrets they refer to
+	secretsIndexKey = "configuration.secrets"
+
+	// configMapsIndexKey indexes EdgeDevices, EdgeDeviceSets and EdgeDeployments by the ConfigMaps they refer to
+	configMapsIndexKey = "configuration.configmaps"
+
+	// Reasons of the ConfigurationSynced condition
+	configurationAppliedReason   = "Applied"
+	configurationPendingReason   = "Pending"
+	configurationOutOfSyncReason = "OutOfSync"
+)
+
+// ConfigurationRenderer renders the configuration delivered to a device
+type ConfigurationRenderer interface {
+	RenderConfiguration(ctx context.Context, name string, namespace string) (*models.DeviceConfigurationMessage, []yggdrasil.ResolutionError, error)
+}
+
+// EdgeDeviceConfigurationReconciler keeps the hash of the desired configuration of EdgeDevices up to date and compares
+// it with the version of the configuration the devices report in their heartbeats. It watches the EdgeDeployments and
+// EdgeDeviceSets of the devices and the Secrets, ConfigMaps and MetricsAllowLists they refer to.
+type EdgeDeviceConfigurationReconciler struct {
+	Client                  client.Client
+	EdgeDeviceRepository    edgedevice.Repository
+	Renderer                ConfigurationRenderer
+	Metrics                 metrics.Metrics
+	Recorder                record.EventRecorder
+	MaxConcurrentReconciles int
+
+	// Debounce is how long changes to the referenced objects are collected before the configuration of the affected
+	// devices is recomputed, so that a burst of changes recomputes it once
+	Debounce time.Duration
+
+	// OutOfSyncThreshold is how long a device can be out of sync before a warning event is emitted; 0 disables the
+	// event
+	OutOfSyncThreshold time.Duration
+}
+
+//+kubebuilder:rbac:groups=management.project-flotta.io,resources=edgedevices,verbs=get;list;watch
+//+kubebuilder:rbac:groups=management.project-flotta.io,resources=edgedevices/status,verbs=get;patch
+//+kubebuilder:rbac:groups=management.project-flotta.io,resources=edgedeployments,verbs=get;list;watch
+//+kubebuilder:rbac:groups=management.project-flotta.io,resources=edgedevicesets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups=management.project-flotta.io,resources=metricsallowlists,verbs=get;list;watch
+
+func (r *EdgeDeviceConfigurationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithName("configuration")
+
+	edgeDevice, err := r.EdgeDeviceRepository.Read(ctx, req.Name, req.Namespace)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			r.Metrics.SetEdgeDeviceOutOfSync(req.Namespace, req.Name, false)
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{Requeue: true}, err
+	}
+
+	if edgeDevice.DeletionTimestamp != nil {
+		r.Metrics.SetEdgeDeviceOutOfSync(req.Namespace, req.Name, false)
+		return ctrl.Result{}, nil
+	}
+
+	// items that cannot be resolved are left out of the hash: the device gets an error until they are fixed, and
+	// fixing them changes the hash
+	dc, _, err := r.Renderer.RenderConfiguration(ctx, req.Name, req.Namespace)
+	if err != nil {
+		return ctrl.Result{Requeue: true}, err
+	}
+	hash, err := yggdrasil.Hash(dc)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	original := edgeDevice.DeepCopy()
+	desired := edgeDevice.Status.DesiredConfiguration
+	if desired == nil || desired.Hash != hash {
+		edgeDevice.Status.DesiredConfiguration = &managementv1alpha1.DesiredConfiguration{
+			Hash:       hash,
+			ChangeTime: metav1.Now(),
+		}
+		logger.V(1).Info("Desired configuration changed", "hash", hash)
+	}
+	drift := r.setConfigurationSynced(edgeDevice, hash, time.Now())
+
+	if !reflect.DeepEqual(original.Status, edgeDevice.Status) {
+		patch := client.MergeFrom(original)
+		err = r.EdgeDeviceRepository.PatchStatus(ctx, edgeDevice, &patch)
+		if err != nil {
+			return ctrl.Result{Requeue: true}, err
+		}
+	}
+
+	synced := meta.FindStatusCondition(edgeDevice.Status.Conditions, managementv1alpha1.ConfigurationSyncedCondition)
+	r.Metrics.SetEdgeDeviceOutOfSync(req.Namespace, req.Name, synced.Status != metav1.ConditionTrue)
+	switch synced.Reason {
+	case configurationOutOfSyncReason:
+		previous := meta.FindStatusCondition(original.Status.Conditions, managementv1alpha1.ConfigurationSyncedCondition)
+		if previous == nil || previous.Reason != configurationOutOfSyncReason {
+			r.Recorder.Event(edgeDevice, corev1.EventTypeWarning, "ConfigurationOutOfSync", synced.Message)
+		}
+	case configurationPendingReason:
+		if r.OutOfSyncThreshold > 0 {
+			return ctrl.Result{RequeueAfter: r.OutOfSyncThreshold - drift}, nil
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// setConfigurationSynced sets the ConfigurationSynced condition of the device, comparing the version of the
+// configuration the device reported with the desired one, and returns how long the device has been out of sync
+func (r *EdgeDeviceConfigurationReconciler) setConfigurationSynced(edgeDevice *managementv1alpha1.EdgeDevice, hash string, now time.Time) time.Duration {
+	condition := metav1.Condition{
+		Type:               managementv1alpha1.ConfigurationSyncedCondition,
+		ObservedGeneration: edgeDevice.Generation,
+	}
+	if edgeDevice.Status.LastSyncedResourceVersion == hash {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = configurationAppliedReason
+		condition.Message = "The device runs its desired configuration"
+		meta.SetStatusCondition(&edgeDevice.Status.Conditions, condition)
+		return 0
+	}
+
+	var drift time.Duration
+	current := meta.FindStatusCondition(edgeDevice.Status.Conditions, managementv1alpha1.ConfigurationSyncedCondition)
+	if current != nil && current.Status == metav1.ConditionFalse {
+		drift = now.Sub(current.LastTransitionTime.Time)
+	}
+	condition.Status = metav1.ConditionFalse
+	condition.Reason = configurationPendingReason
+	condition.Message = "The device has not applied its desired configuration yet"
+	if r.OutOfSyncThreshold > 0 && drift >= r.OutOfSyncThreshold {
+		condition.Reason = configurationOutOfSyncReason
+		condition.Message = fmt.Sprintf("The device has not applied its desired configuration for more than %s", r.OutOfSyncThreshold)
+	}
+	meta.SetStatusCondition(&edgeDevice.Status.Conditions, condition)
+	return drift
+}
+
+// SetupWithManager sets up the controller with the Manager.
+//
+// Secrets and ConfigMaps are watched in all the namespaces: the referenced ones are found through the field indexes
+// only when an event is received. The watches share the informers of the manager cache the device API already reads
+// Secrets and ConfigMaps from, so they require no other permission than the cluster-wide get, list and watch the
+// operator already has, but the cache holds every Secret and ConfigMap of the cluster: the memory of the operator
+// grows with them. Updates that do not change the data, like the annotations renewed by leader elections, are ignored.
+func (r *EdgeDeviceConfigurationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := r.setupIndexes(mgr); err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("edgedeviceconfiguration").
+		For(&managementv1alpha1.EdgeDevice{}, builder.WithPredicates(configurationInputChanged())).
+		Watches(&source.Kind{Type: &managementv1alpha1.EdgeDeployment{}},
+			r.debounced(r.devicesOfDeployment),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&source.Kind{Type: &managementv1alpha1.EdgeDeviceSet{}},
+			r.debounced(r.devicesOfSet),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&source.Kind{Type: &corev1.Secret{}},
+			r.debounced(r.devicesReferring(secretsIndexKey)),
+			builder.WithPredicates(dataChanged())).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}},
+			r.debounced(r.devicesReferring(configMapsIndexKey)),
+			builder.WithPredicates(dataChanged())).
+		Watches(&source.Kind{Type: &managementv1alpha1.MetricsAllowList{}},
+			r.debounced(r.devicesReferringAllowList),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Complete(r)
+}
+
+// setupIndexes registers the field indexes of the references to Secrets, ConfigMaps and MetricsAllowLists, which the
+// MetricsAllowList controller relies on as well
+func (r *EdgeDeviceConfigurationReconciler) setupIndexes(mgr ctrl.Manager) error {
+	indexer := mgr.GetFieldIndexer()
+	ctx := context.Background()
+	indexes := []struct {
+		obj     client.Object
+		key     string
+		extract func(obj client.Object) []string
+	}{
+		{&managementv1alpha1.EdgeDevice{}, secretsIndexKey, func(obj client.Object) []string {
+			return references.EdgeDeviceSecrets(obj.(*managementv1alpha1.EdgeDevice))
+		}},
+		{&managementv1alpha1.EdgeDevice{}, configMapsIndexKey, func(obj client.Object) []string {
+			return references.EdgeDeviceConfigMaps(obj.(*managementv1alpha1.EdgeDevice))
+		}},
+		{&managementv1alpha1.EdgeDeviceSet{}, secretsIndexKey, func(obj client.Object) []string {
+			return references.EdgeDeviceSetSecrets(obj.(*managementv1alpha1.EdgeDeviceSet))
+		}},
+		{&managementv1alpha1.EdgeDeviceSet{}, configMapsIndexKey, func(obj client.Object) []string {
+			return references.EdgeDeviceSetConfigMaps(obj.(*managementv1alpha1.EdgeDeviceSet))
+		}},
+		{&managementv1alpha1.EdgeDeployment{}, secretsIndexKey, func(obj client.Object) []string {
+			return references.EdgeDeploymentSecrets(obj.(*managementv1alpha1.EdgeDeployment))
+		}},
+		{&managementv1alpha1.EdgeDeployment{}, configMapsIndexKey, func(obj client.Object) []string {
+			return references.EdgeDeploymentConfigMaps(obj.(*managementv1alpha1.EdgeDeployment))
+		}},
+		{&managementv1alpha1.EdgeDevice{}, metricsallowlist.ReferencesIndexKey, func(obj client.Object) []string {
+			return references.EdgeDeviceMetricsAllowLists(obj.(*managementv1alpha1.EdgeDevice))
+		}},
+		{&managementv1alpha1.EdgeDeviceSet{}, metricsallowlist.ReferencesIndexKey, func(obj client.Object) []string {
+			return references.EdgeDeviceSetMetricsAllowLists(obj.(*managementv1alpha1.EdgeDeviceSet))
+		}},
+		{&managementv1alpha1.EdgeDeployment{}, metricsallowlist.ReferencesIndexKey, func(obj client.Object) []string {
+			return references.EdgeDeploymentMetricsAllowLists(obj.(*managementv1alpha1.EdgeDeployment))
+		}},
+		{&managementv1alpha1.MetricsAllowList{}, metricsallowlist.ReferencesIndexKey, func(obj client.Object) []string {
+			return references.MetricsAllowListReferences(obj.(*managementv1alpha1.MetricsAllowList))
+		}},
+	}
+	for _, index := range indexes {
+		if err := indexer.IndexField(ctx, index.obj, index.key, index.extract); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// devicesReferring maps a Secret or ConfigMap to the devices whose configuration refers to it, directly or through
+// their EdgeDeviceSet or EdgeDeployments
+func (r *EdgeDeviceConfigurationReconciler) devicesReferring(indexKey string) handler.MapFunc {
+	return func(obj client.Object) []reconcile.Request {
+		return r.devicesReferringName(indexKey, obj.GetNamespace(), obj.GetName())
+	}
+}
+
+// devicesReferringAllowList maps a MetricsAllowList to the devices whose configuration refers to it or to the
+// MetricsAllowLists extending or including it
+func (r *EdgeDeviceConfigurationReconciler) devicesReferringAllowList(obj client.Object) []reconcile.Request {
+	var requests []reconcile.Request
+	for _, name := range r.allowListsReferring(obj.GetNamespace(), obj.GetName()) {
+		requests = append(requests, r.devicesReferringName(metricsallowlist.ReferencesIndexKey, obj.GetNamespace(), name)...)
+	}
+	return requests
+}
+
+// allowListsReferring returns the name of the MetricsAllowList and the names of the MetricsAllowLists extending or
+// including it, directly or through other MetricsAllowLists
+func (r *EdgeDeviceConfigurationReconciler) allowListsReferring(namespace, name string) []string {
+	names := []string{name}
+	seen := map[string]bool{name: true}
+	for i := 0; i < len(names); i++ {
+		allowLists := managementv1alpha1.MetricsAllowListList{}
+		err := r.Client.List(context.Background(), &allowLists,
+			client.InNamespace(namespace), client.MatchingFields{metricsallowlist.ReferencesIndexKey: names[i]})
+		if err != nil {
+			log.Log.Error(err, "cannot list referring MetricsAllowLists", "Name", names[i], "Namespace", namespace)
+			continue
+		}
+		for _, allowList := range allowLists.Items {
+			if !seen[allowList.Name] {
+				seen[allowList.Name] = true
+				names = append(names, allowList.Name)
+			}
+		}
+	}
+	return names
+}
+
+func (r *EdgeDeviceConfigurationReconciler) devicesReferringName(indexKey, namespace, name string) []reconcile.Request {
+	ctx := context.Background()
+	logger := log.FromContext(ctx, "Name", name, "Namespace", namespace)
+	referring := []client.ListOption{client.InNamespace(namespace), client.MatchingFields{indexKey: name}}
+
+	var requests []reconcile.Request
+	devices := managementv1alpha1.EdgeDeviceList{}
+	if err := r.Client.List(ctx, &devices, referring...); err != nil {
+		logger.Error(err, "cannot list referring EdgeDevices")
+	}
+	requests = append(requests, deviceRequests(devices.Items)...)
+
+	sets := managementv1alpha1.EdgeDeviceSetList{}
+	if err := r.Client.List(ctx, &sets, referring...); err != nil {
+		logger.Error(err, "cannot list referring EdgeDeviceSets")
+	}
+	for i := range sets.Items {
+		requests = append(requests, r.devicesOfSet(&sets.Items[i])...)
+	}
+
+	deployments := managementv1alpha1.EdgeDeploymentList{}
+	if err := r.Client.List(ctx, &deployments, referring...); err != nil {
+		logger.Error(err, "cannot list referring EdgeDeployments")
+	}
+	for i := range deployments.Items {
+		requests = append(requests, r.devicesOfDeployment(&deployments.Items[i])...)
+	}
+	return requests
+}
+
+func (r *EdgeDeviceConfigurationReconciler) devicesOfDeployment(obj client.Object) []reconcile.Request {
+	return r.devicesLabelled(obj.GetNamespace(), flottalabels.WorkloadLabel(obj.GetName()), "true")
+}
+
+func (r *EdgeDeviceConfigurationReconciler) devicesOfSet(obj client.Object) []reconcile.Request {
+	return r.devicesLabelled(obj.GetNamespace(), flottalabels.DeviceSetLabel, obj.GetName())
+}
+
+func (r *EdgeDeviceConfigurationReconciler) devicesLabelled(namespace, label, value string) []reconcile.Request {
+	devices := managementv1alpha1.EdgeDeviceList{}
+	err := r.Client.List(context.Background(), &devices, client.InNamespace(namespace), client.MatchingLabels{label: value})
+	if err != nil {
+		log.Log.Error(err, "cannot list EdgeDevices", "label", label, "value", value)
+		return nil
+	}
+	return deviceRequests(devices.Items)
+}
+
+func deviceRequests(devices []managementv1alpha1.EdgeDevice) []reconcile.Request {
+	var requests []reconcile.Request
+	for _, device := range devices {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: device.Name, Namespace: device.Namespace},
+		})
+	}
+	return requests
+}
+
+func (r *EdgeDeviceConfigurationReconciler) debounced(mapFunc handler.MapFunc) handler.EventHandler {
+	return &debouncedEnqueue{mapFunc: mapFunc, delay: r.Debounce}
+}
+
+// debouncedEnqueue enqueues the mapped requests after a delay. The queue keeps a single pending request per device,
+// so all the events of the delay are handled by one reconciliation.
+type debouncedEnqueue struct {
+	mapFunc handler.MapFunc
+	delay   time.Duration
+}
+
+func (d *debouncedEnqueue) Create(evt event.CreateEvent, q workqueue.RateLimitingInterface) {
+	d.enqueue(evt.Object, q)
+}
+
+func (d *debouncedEnqueue) Update(evt event.UpdateEvent, q workqueue.RateLimitingInterface) {
+	d.enqueue(evt.ObjectOld, q)
+	d.enqueue(evt.ObjectNew, q)
+}
+
+func (d *debouncedEnqueue) Delete(evt event.DeleteEvent, q workqueue.RateLimitingInterface) {
+	d.enqueue(evt.Object, q)
+}
+
+func (d *debouncedEnqueue) Generic(evt event.GenericEvent, q workqueue.RateLimitingInterface) {
+	d.enqueue(evt.Object, q)
+}
+
+func (d *debouncedEnqueue) enqueue(obj client.Object, q workqueue.RateLimitingInterface) {
+	if obj == nil {
+		return
+	}
+	for _, request := range d.mapFunc(obj) {
+		q.AddAfter(request, d.delay)
+	}
+}
+
+// dataChanged filters the Secret and ConfigMap updates that change their content
+func dataChanged() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			switch oldObj := e.ObjectOld.(type) {
+			case *corev1.Secret:
+				newObj, ok := e.ObjectNew.(*corev1.Secret)
+				return !ok || oldObj.Type != newObj.Type || !reflect.DeepEqual(oldObj.Data, newObj.Data) ||
+					!reflect.DeepEqual(oldObj.StringData, newObj.StringData)
+			case *corev1.ConfigMap:
+				newObj, ok := e.ObjectNew.(*corev1.ConfigMap)
+				return !ok || !reflect.DeepEqual(oldObj.Data, newObj.Data) || !reflect.DeepEqual(oldObj.BinaryData, newObj.BinaryData)
+			default:
+				return true
+			}
+		},
+	}
+}
+
+// configurationInputChanged filters the EdgeDevice updates that can change the configuration of the device
+func configurationInputChanged() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldDevice, ok := e.ObjectOld.(*managementv1alpha1.EdgeDevice)
+			if !ok {
+				return false
+			}
+			newDevice, ok := e.ObjectNew.(*managementv1alpha1.EdgeDevice)
+			if !ok {
+				return false
+			}
+			return oldDevice.Generation != newDevice.Generation ||
+				oldDevice.Status.LastSyncedResourceVersion != newDevice.Status.LastSyncedResourceVersion ||
+				oldDevice.Labels[flottalabels.DeviceSetLabel] != newDevice.Labels[flottalabels.DeviceSetLabel] ||
+				!reflect.DeepEqual(deploymentNames(oldDevice), deploymentNames(newDevice)) ||
+				!reflect.DeepEqual(oldDevice.Status.DeliveredConfiguration, newDevice.Status.DeliveredConfiguration) ||
+				!reflect.DeepEqual(oldDevice.Status.DataOBC, newDevice.Status.DataOBC)
+		},
+	}
+}
+
+// deploymentNames returns the names of the deployments of the device, sorted: the order of the status changes between
+// heartbeats
+func deploymentNames(device *managementv1alpha1.EdgeDevice) []string {
+	var names []string
+	for _, deployment := range device.Status.Deployments {
+		names = append(names, deployment.Name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,206 @@
+package controllers_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"github.com/project-flotta/flotta-operator/controllers"
+	"github.com/project-flotta/flotta-operator/internal/metrics"
+	"github.com/project-flotta/flotta-operator/internal/repository/edgedevice"
+	"github.com/project-flotta/flotta-operator/internal/yggdrasil"
+	"github.com/project-flotta/flotta-operator/models"
+)
+
+type configurationRenderer struct {
+	dc *models.DeviceConfigurationMessage
+}
+
+func (r *configurationRenderer) RenderConfiguration(ctx context.Context, name string, namespace string) (*models.DeviceConfigurationMessage, []yggdrasil.ResolutionError, error) {
+	return r.dc, nil, nil
+}
+
+var _ = Describe("EdgeDeviceConfiguration controller", func() {
+	var (
+		mockCtrl           *gomock.Controller
+		edgeDeviceRepoMock *edgedevice.MockRepository
+		metricsMock        *metrics.MockMetrics
+		eventsRecorder     *record.FakeRecorder
+		renderer           *configurationRenderer
+		reconciler         *controllers.EdgeDeviceConfigurationReconciler
+		device             *v1alpha1.EdgeDevice
+		req                = ctrl.Request{NamespacedName: types.NamespacedName{Name: "test", Namespace: "test"}}
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		edgeDeviceRepoMock = edgedevice.NewMockRepository(mockCtrl)
+		metricsMock = metrics.NewMockMetrics(mockCtrl)
+		eventsRecorder = record.NewFakeRecorder(1)
+		renderer = &configurationRenderer{dc: &models.DeviceConfigurationMessage{DeviceID: "test", Version: "1"}}
+		reconciler = &controllers.EdgeDeviceConfigurationReconciler{
+			EdgeDeviceRepository: edgeDeviceRepoMock,
+			Renderer:             renderer,
+			Metrics:              metricsMock,
+			Recorder:             eventsRecorder,
+			OutOfSyncThreshold:   10 * time.Minute,
+		}
+		device = &v1alpha1.EdgeDevice{ObjectMeta: v1.ObjectMeta{Name: "test", Namespace: "test"}}
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	It("EdgeDevice not found", func() {
+		// given
+		edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), "test", "test").
+			Return(nil, errors.NewNotFound(schema.GroupResource{}, "test"))
+		metricsMock.EXPECT().SetEdgeDeviceOutOfSync("test", "test", false)
+
+		// when
+		res, err := reconciler.Reconcile(context.TODO(), req)
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.Requeue).To(BeFalse())
+	})
+
+	It("Desired configuration hash is stored", func() {
+		// given
+		edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), "test", "test").Return(device, nil)
+		expectedHash, err := yggdrasil.Hash(renderer.dc)
+		Expect(err).NotTo(HaveOccurred())
+
+		edgeDeviceRepoMock.EXPECT().PatchStatus(gomock.Any(), device, gomock.Any()).
+			Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, patch *client.Patch) {
+				Expect(edgeDevice.Status.DesiredConfiguration).NotTo(BeNil())
+				Expect(edgeDevice.Status.DesiredConfiguration.Hash).To(Equal(expectedHash))
+				Expect(edgeDevice.Status.DesiredConfiguration.ChangeTime.IsZero()).To(BeFalse())
+			}).
+			Return(nil)
+		metricsMock.EXPECT().SetEdgeDeviceOutOfSync("test", "test", true)
+
+		// when
+		res, err := reconciler.Reconcile(context.TODO(), req)
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.RequeueAfter).To(Equal(10 * time.Minute))
+		condition := device.Status.Conditions[0]
+		Expect(condition.Type).To(Equal(v1alpha1.ConfigurationSyncedCondition))
+		Expect(condition.Status).To(Equal(v1.ConditionFalse))
+		Expect(condition.Reason).To(Equal("Pending"))
+	})
+
+	It("Unchanged synced configuration is not patched", func() {
+		// given
+		hash, err := yggdrasil.Hash(renderer.dc)
+		Expect(err).NotTo(HaveOccurred())
+		device.Status.DesiredConfiguration = &v1alpha1.DesiredConfiguration{Hash: hash}
+		device.Status.LastSyncedResourceVersion = hash
+		device.Status.Conditions = []v1.Condition{{
+			Type:               v1alpha1.ConfigurationSyncedCondition,
+			Status:             v1.ConditionTrue,
+			Reason:             "Applied",
+			Message:            "The device runs its desired configuration",
+			LastTransitionTime: v1.Now(),
+		}}
+		edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), "test", "test").Return(device, nil)
+		metricsMock.EXPECT().SetEdgeDeviceOutOfSync("test", "test", false)
+
+		// when
+		renderer.dc.Version = "2"
+		_, err = reconciler.Reconcile(context.TODO(), req)
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("Device running its workloads in another order stays in sync", func() {
+		// given
+		renderer.dc.Workloads = models.WorkloadList{{Name: "nginx"}, {Name: "camera"}}
+		applied, err := yggdrasil.Hash(&models.DeviceConfigurationMessage{
+			DeviceID:  "test",
+			Version:   "1",
+			Workloads: models.WorkloadList{{Name: "camera"}, {Name: "nginx"}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		device.Status.DesiredConfiguration = &v1alpha1.DesiredConfiguration{Hash: applied}
+		device.Status.LastSyncedResourceVersion = applied
+		device.Status.Conditions = []v1.Condition{{
+			Type:               v1alpha1.ConfigurationSyncedCondition,
+			Status:             v1.ConditionTrue,
+			Reason:             "Applied",
+			Message:            "The device runs its desired configuration",
+			LastTransitionTime: v1.Now(),
+		}}
+		edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), "test", "test").Return(device, nil)
+		edgeDeviceRepoMock.EXPECT().PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+		metricsMock.EXPECT().SetEdgeDeviceOutOfSync("test", "test", false)
+
+		// when
+		_, err = reconciler.Reconcile(context.TODO(), req)
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(device.Status.Conditions[0].Reason).To(Equal("Applied"))
+		Expect(eventsRecorder.Events).To(BeEmpty())
+	})
+
+	It("Deleted EdgeDevice is not rendered", func() {
+		// given
+		device.DeletionTimestamp = &v1.Time{}
+		edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), "test", "test").Return(device, nil)
+		renderer.dc = nil
+		metricsMock.EXPECT().SetEdgeDeviceOutOfSync("test", "test", false)
+
+		// when
+		_, err := reconciler.Reconcile(context.TODO(), req)
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("Device out of sync past the threshold gets a warning event once", func() {
+		// given
+		hash, err := yggdrasil.Hash(renderer.dc)
+		Expect(err).NotTo(HaveOccurred())
+		outOfSyncSince := v1.NewTime(time.Now().Add(-time.Hour))
+		device.Status.DesiredConfiguration = &v1alpha1.DesiredConfiguration{Hash: hash}
+		device.Status.LastSyncedResourceVersion = "previous"
+		device.Status.Conditions = []v1.Condition{{
+			Type:               v1alpha1.ConfigurationSyncedCondition,
+			Status:             v1.ConditionFalse,
+			Reason:             "Pending",
+			LastTransitionTime: outOfSyncSince,
+		}}
+		edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), "test", "test").Return(device, nil).Times(2)
+		edgeDeviceRepoMock.EXPECT().PatchStatus(gomock.Any(), device, gomock.Any()).Return(nil)
+		metricsMock.EXPECT().SetEdgeDeviceOutOfSync("test", "test", true).Times(2)
+
+		// when
+		res, err := reconciler.Reconcile(context.TODO(), req)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = reconciler.Reconcile(context.TODO(), req)
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.RequeueAfter).To(BeZero())
+		Expect(device.Status.Conditions[0].Reason).To(Equal("OutOfSync"))
+		Expect(device.Status.Conditions[0].LastTransitionTime).To(Equal(outOfSyncSince))
+		Expect(eventsRecorder.Events).To(HaveLen(1))
+		Expect(<-eventsRecorder.Events).To(ContainSubstring("ConfigurationOutOfSync"))
+	})
+})
@@ -18,15 +18,18 @@ package controllers
 
 import (
 	"context"
+	"sort"
+
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 
 	managementv1alpha1 "github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"github.com/project-flotta/flotta-operator/internal/capacity"
 	flottalabels "github.com/project-flotta/flotta-operator/internal/labels"
-	"github.com/project-flotta/flotta-operator/internal/repository/edgedeployment"
+	"github.com/project-flotta/flotta-operator/internal/placement"
 	"github.com/project-flotta/flotta-operator/internal/repository/edgedevice"
+	"github.com/project-flotta/flotta-operator/internal/selectorindex"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/labels"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -36,12 +39,12 @@ import (
 
 // EdgeDeviceReconciler reconciles a EdgeDevice object
 type EdgeDeviceLabelsReconciler struct {
-	EdgeDeviceRepository     edgedevice.Repository
-	EdgeDeploymentRepository edgedeployment.Repository
-	MaxConcurrentReconciles  int
+	EdgeDeviceRepository    edgedevice.Repository
+	SelectorIndex           *selectorindex.Index
+	MaxConcurrentReconciles int
 }
 
-//+kubebuilder:rbac:groups=management.project-flotta.io,resources=edgedevices,verbs=get;watch;patch
+//+kubebuilder:rbac:groups=management.project-flotta.io,resources=edgedevices,verbs=get;list;watch;patch
 //+kubebuilder:rbac:groups=management.project-flotta.io,resources=edgedevices/status,verbs=get;patch
 //+kubebuilder:rbac:groups=management.project-flotta.io,resources=edgedeployments,verbs=list
 
@@ -79,33 +82,30 @@ func (r *EdgeDeviceLabelsReconciler) SetupWithManager(mgr ctrl.Manager) error {
 }
 
 func (r *EdgeDeviceLabelsReconciler) updateDeployments(ctx context.Context, device *managementv1alpha1.EdgeDevice) error {
-	// create selector labels
-	selectorLabels := createSelectorLabelsMap(device)
-
-	// read deployments matching the labels and match to device
-	selectedDeployments := map[string]bool{} // each deployment we read is here. the value is true if the deployment matches the device
+	deployments, err := r.SelectorIndex.Matching(device)
+	if err != nil {
+		return err
+	}
 
-	for selectorLabel, labelValue := range selectorLabels {
-		deployments, err := r.EdgeDeploymentRepository.ListByLabel(ctx, selectorLabel, labelValue)
-		if err != nil {
-			return err
-		}
+	// each deployment matching the device is here. the value is false if the deployment cannot be deployed to it
+	selectedDeployments := map[string]bool{}
+	matchedDeployments := map[string]*managementv1alpha1.EdgeDeployment{}
+	dependencies := map[string][]string{}
+	for i := range deployments {
+		deployment := deployments[i]
+		selectedDeployments[deployment.Name] = true
+		matchedDeployments[deployment.Name] = &deployment
+		dependencies[deployment.Name] = deployment.Spec.DependsOn
+	}
 
-		for i := range deployments {
-			deployment := deployments[i]
-			if _, ok := selectedDeployments[deployment.Name]; ok {
-				continue
-			}
-			match, err := isDeploymentMatchDevice(&deployment, device)
-			if err != nil {
-				return err
-			}
-			selectedDeployments[deployment.Name] = match
-		}
+	err = r.rejectDeploymentsViolatingPlacement(ctx, device, selectedDeployments, matchedDeployments)
+	if err != nil {
+		return err
 	}
+	rejectDeploymentsExceedingCapacity(ctx, device, selectedDeployments, matchedDeployments)
 
 	// diff device deployments and matched deployments. update device if necessary
-	updatedDevice := createUpdatedDevice(selectedDeployments, device)
+	updatedDevice := createUpdatedDevice(selectedDeployments, dependencies, device)
 	if updatedDevice != nil {
 		patch := client.MergeFrom(device)
 		err := r.EdgeDeviceRepository.PatchStatus(ctx, updatedDevice, &patch)
@@ -121,37 +121,57 @@ func (r *EdgeDeviceLabelsReconciler) updateDeployments(ctx context.Context, devi
 	return nil
 }
 
-func isDeploymentMatchDevice(deployment *managementv1alpha1.EdgeDeployment, device *managementv1alpha1.EdgeDevice) (bool, error) {
-	if deployment.Spec.Device == device.Name {
-		return true, nil
-	} else if deployment.Spec.DeviceSelector != nil {
-		selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.DeviceSelector)
+// rejectDeploymentsViolatingPlacement unselects matching deployments that are not deployed to the device yet and
+// would exceed their placement constraints if deployed to the device
+func (r *EdgeDeviceLabelsReconciler) rejectDeploymentsViolatingPlacement(ctx context.Context, device *managementv1alpha1.EdgeDevice, selectedDeployments map[string]bool, matchedDeployments map[string]*managementv1alpha1.EdgeDeployment) error {
+	for name, edgeDeployment := range matchedDeployments {
+		if edgeDeployment.Spec.Placement == nil || hasDeployment(*device, name) {
+			continue
+		}
+		selector := metav1.LabelSelector{MatchLabels: map[string]string{flottalabels.WorkloadLabel(name): "true"}}
+		placed, err := r.EdgeDeviceRepository.ListForSelector(ctx, &selector, device.Namespace)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		err = placement.CheckConstraints(edgeDeployment, placed, device)
 		if err != nil {
-			return false, err
+			log.FromContext(ctx).Info("EdgeDeployment cannot be placed on the device", "edgeDeployment", name, "reason", err.Error())
+			selectedDeployments[name] = false
+			delete(matchedDeployments, name)
 		}
-		return selector.Matches(labels.Set(device.Labels)), nil
 	}
-	return false, nil
+	return nil
 }
 
-func createSelectorLabelsMap(device *managementv1alpha1.EdgeDevice) map[string]string {
-	result := map[string]string{
-		flottalabels.CreateSelectorLabel(flottalabels.DeviceNameLabel):   device.Name,
-		flottalabels.CreateSelectorLabel(flottalabels.DoesNotExistLabel): "true",
+// rejectDeploymentsExceedingCapacity unselects matching deployments that are not deployed to the device yet and
+// do not fit the device together with the deployments that are
+func rejectDeploymentsExceedingCapacity(ctx context.Context, device *managementv1alpha1.EdgeDevice, selectedDeployments map[string]bool, matchedDeployments map[string]*managementv1alpha1.EdgeDeployment) {
+	var deployed []managementv1alpha1.EdgeDeployment
+	for _, deployment := range device.Status.Deployments {
+		if edgeDeployment, ok := matchedDeployments[deployment.Name]; ok {
+			deployed = append(deployed, *edgeDeployment)
+			delete(matchedDeployments, deployment.Name)
+		}
 	}
 
-	for deviceLabel := range device.Labels {
-		if flottalabels.IsWorkloadLabel(deviceLabel) {
+	names := make([]string, 0, len(matchedDeployments))
+	for name := range matchedDeployments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		edgeDeployment := matchedDeployments[name]
+		err := capacity.Check(device, deployed, edgeDeployment)
+		if err != nil {
+			log.FromContext(ctx).Info("EdgeDeployment does not fit the device", "edgeDeployment", name, "reason", err.Error())
+			selectedDeployments[name] = false
 			continue
 		}
-		selectorLabel := flottalabels.CreateSelectorLabel(deviceLabel)
-		result[selectorLabel] = "true"
+		deployed = append(deployed, *edgeDeployment)
 	}
-
-	return result
 }
 
-func createUpdatedDevice(selectedDeployments map[string]bool, device *managementv1alpha1.EdgeDevice) *managementv1alpha1.EdgeDevice {
+func createUpdatedDevice(selectedDeployments map[string]bool, dependencies map[string][]string, device *managementv1alpha1.EdgeDevice) *managementv1alpha1.EdgeDevice {
 	// prepare a copy of the device for modifying
 	deviceCopy := device.DeepCopy()
 	deviceCopy.Status.Deployments = nil
@@ -180,8 +200,9 @@ func createUpdatedDevice(selectedDeployments map[string]bool, device *management
 		}
 		deviceUpdated = true
 		deviceCopy.Status.Deployments = append(deviceCopy.Status.Deployments, managementv1alpha1.Deployment{
-			Name:  name,
-			Phase: managementv1alpha1.Deploying,
+			Name:      name,
+			Phase:     managementv1alpha1.Deploying,
+			DependsOn: dependencies[name],
 		})
 		deviceCopy.Labels[flottalabels.WorkloadLabel(name)] = "true"
 	}
@@ -11,8 +11,8 @@ import (
 	"github.com/project-flotta/flotta-operator/api/v1alpha1"
 	"github.com/project-flotta/flotta-operator/controllers"
 	"github.com/project-flotta/flotta-operator/internal/labels"
-	"github.com/project-flotta/flotta-operator/internal/repository/edgedeployment"
 	"github.com/project-flotta/flotta-operator/internal/repository/edgedevice"
+	"github.com/project-flotta/flotta-operator/internal/selectorindex"
 	"k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -25,7 +25,6 @@ import (
 var _ = Describe("EdgeDeviceLabels controller/Reconcile", func() {
 	var (
 		mockCtrl                   *gomock.Controller
-		deployRepoMock             *edgedeployment.MockRepository
 		edgeDeviceRepoMock         *edgedevice.MockRepository
 		edgeDeviceLabelsReconciler *controllers.EdgeDeviceLabelsReconciler
 		req                        = ctrl.Request{
@@ -52,11 +51,10 @@ var _ = Describe("EdgeDeviceLabels controller/Reconcile", func() {
 
 	BeforeEach(func() {
 		mockCtrl = gomock.NewController(GinkgoT())
-		deployRepoMock = edgedeployment.NewMockRepository(mockCtrl)
 		edgeDeviceRepoMock = edgedevice.NewMockRepository(mockCtrl)
 		edgeDeviceLabelsReconciler = &controllers.EdgeDeviceLabelsReconciler{
-			EdgeDeviceRepository:     edgeDeviceRepoMock,
-			EdgeDeploymentRepository: deployRepoMock,
+			EdgeDeviceRepository: edgeDeviceRepoMock,
+			SelectorIndex:        selectorindex.NewSynced(),
 		}
 
 		device = &v1alpha1.EdgeDevice{
@@ -123,16 +121,13 @@ var _ = Describe("EdgeDeviceLabels controller/Reconcile", func() {
 		Expect(res).To(Equal(reconcile.Result{Requeue: true, RequeueAfter: 0}))
 	})
 
-	It("list EdgeDeployments failed", func() {
+	It("selector index not synced", func() {
 		// given
+		edgeDeviceLabelsReconciler.SelectorIndex = selectorindex.New()
 		edgeDeviceRepoMock.EXPECT().
 			Read(gomock.Any(), gomock.Any(), gomock.Any()).
 			Return(device, nil).
 			Times(1)
-		deployRepoMock.EXPECT().
-			ListByLabel(gomock.Any(), gomock.Any(), gomock.Any()).
-			Return(nil, fmt.Errorf("test")).
-			Times(1)
 
 		// when
 		res, err := edgeDeviceLabelsReconciler.Reconcile(context.TODO(), req)
@@ -142,7 +137,7 @@ var _ = Describe("EdgeDeviceLabels controller/Reconcile", func() {
 		Expect(res).To(Equal(reconcile.Result{Requeue: true, RequeueAfter: 0}))
 	})
 
-	It("invalid deviceSelector", func() {
+	It("deployment with invalid deviceSelector not matched", func() {
 		// given
 		deployment := getDeployment("test")
 		deployment.Spec.DeviceSelector = &v1.LabelSelector{
@@ -153,34 +148,26 @@ var _ = Describe("EdgeDeviceLabels controller/Reconcile", func() {
 				},
 			},
 		}
-		deployments := []v1alpha1.EdgeDeployment{*deployment}
+		edgeDeviceLabelsReconciler.SelectorIndex = selectorindex.NewSynced(*deployment)
 		edgeDeviceRepoMock.EXPECT().
 			Read(gomock.Any(), gomock.Any(), gomock.Any()).
 			Return(device, nil).
 			Times(1)
-		deployRepoMock.EXPECT().
-			ListByLabel(gomock.Any(), gomock.Any(), gomock.Any()).
-			Return(deployments, nil).
-			Times(1)
 
 		// when
 		res, err := edgeDeviceLabelsReconciler.Reconcile(context.TODO(), req)
 
 		// then
-		Expect(err).To(HaveOccurred())
-		Expect(res).To(Equal(reconcile.Result{Requeue: true, RequeueAfter: 0}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(Equal(reconcile.Result{Requeue: false, RequeueAfter: 0}))
 	})
 
-	It("list EdgeDeployments returned empty list", func() {
+	It("no EdgeDeployments", func() {
 		// given
 		edgeDeviceRepoMock.EXPECT().
 			Read(gomock.Any(), gomock.Any(), gomock.Any()).
 			Return(device, nil).
 			Times(1)
-		deployRepoMock.EXPECT().
-			ListByLabel(gomock.Any(), gomock.Any(), gomock.Any()).
-			Return(nil, nil).
-			Times(2)
 
 		// when
 		res, err := edgeDeviceLabelsReconciler.Reconcile(context.TODO(), req)
@@ -190,7 +177,7 @@ var _ = Describe("EdgeDeviceLabels controller/Reconcile", func() {
 		Expect(res).To(Equal(reconcile.Result{Requeue: false, RequeueAfter: 0}))
 	})
 
-	It("same EdgeDeployment listed multiple times", func() {
+	It("EdgeDeployment selecting several device labels", func() {
 		// given
 		device.Labels = map[string]string{
 			"label1": "",
@@ -209,28 +196,21 @@ var _ = Describe("EdgeDeviceLabels controller/Reconcile", func() {
 				},
 			},
 		}
-		controllers.UpdateSelectorLabels(deployment)
-		deploymentList := []v1alpha1.EdgeDeployment{*deployment}
+		deployment.Spec.DependsOn = []string{"database"}
+		edgeDeviceLabelsReconciler.SelectorIndex = selectorindex.NewSynced(*deployment)
 
 		edgeDeviceRepoMock.EXPECT().
 			Read(gomock.Any(), gomock.Any(), gomock.Any()).
 			Return(device, nil).
 			Times(1)
-		deployRepoMock.EXPECT().
-			ListByLabel(gomock.Any(), labels.CreateSelectorLabel(labels.DeviceNameLabel), gomock.Any()).
-			Return(nil, nil).
-			Times(1)
-		deployRepoMock.EXPECT().
-			ListByLabel(gomock.Any(), gomock.Any(), gomock.Any()).
-			Return(deploymentList, nil).
-			Times(2)
 		edgeDeviceRepoMock.EXPECT().
 			PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).
 			Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, patch *client.Patch) {
 				Expect(edgeDevice.Status.Deployments).To(Equal([]v1alpha1.Deployment{
 					{
-						Name:  "test",
-						Phase: v1alpha1.Deploying,
+						Name:      "test",
+						Phase:     v1alpha1.Deploying,
+						DependsOn: []string{"database"},
 					},
 				}))
 			}).Times(1)
@@ -255,8 +235,7 @@ var _ = Describe("EdgeDeviceLabels controller/Reconcile", func() {
 		// given
 		deployment := getDeployment("test")
 		deployment.Spec.Device = device.Name
-		controllers.UpdateSelectorLabels(deployment)
-		deploymentList := []v1alpha1.EdgeDeployment{*deployment}
+		edgeDeviceLabelsReconciler.SelectorIndex = selectorindex.NewSynced(*deployment)
 		device.Status.Deployments = []v1alpha1.Deployment{
 			{
 				Name:  "test",
@@ -269,14 +248,6 @@ var _ = Describe("EdgeDeviceLabels controller/Reconcile", func() {
 			Read(gomock.Any(), gomock.Any(), gomock.Any()).
 			Return(device, nil).
 			Times(1)
-		deployRepoMock.EXPECT().
-			ListByLabel(gomock.Any(), labels.CreateSelectorLabel(labels.DeviceNameLabel), gomock.Any()).
-			Return(deploymentList, nil).
-			Times(1)
-		deployRepoMock.EXPECT().
-			ListByLabel(gomock.Any(), gomock.Any(), gomock.Any()).
-			Return(nil, nil).
-			Times(1)
 
 		// when
 		res, err := edgeDeviceLabelsReconciler.Reconcile(context.TODO(), req)
@@ -322,7 +293,7 @@ var _ = Describe("EdgeDeviceLabels controller/Reconcile", func() {
 				},
 			},
 		}
-		controllers.UpdateSelectorLabels(deployment1, deployment2, deployment3, deployment4, deployment5)
+		edgeDeviceLabelsReconciler.SelectorIndex = selectorindex.NewSynced(*deployment1, *deployment2, *deployment3, *deployment4, *deployment5)
 
 		device.Labels = map[string]string{
 			"label1": "label1",
@@ -334,26 +305,6 @@ var _ = Describe("EdgeDeviceLabels controller/Reconcile", func() {
 			Read(gomock.Any(), gomock.Any(), gomock.Any()).
 			Return(device, nil).
 			Times(1)
-		deployRepoMock.EXPECT().
-			ListByLabel(gomock.Any(), labels.CreateSelectorLabel(labels.DeviceNameLabel), gomock.Any()).
-			Return([]v1alpha1.EdgeDeployment{*deployment1}, nil).
-			Times(1)
-		deployRepoMock.EXPECT().
-			ListByLabel(gomock.Any(), labels.CreateSelectorLabel("label1"), gomock.Any()).
-			Return([]v1alpha1.EdgeDeployment{*deployment2}, nil).
-			Times(1)
-		deployRepoMock.EXPECT().
-			ListByLabel(gomock.Any(), labels.CreateSelectorLabel(labels.DoesNotExistLabel), gomock.Any()).
-			Return([]v1alpha1.EdgeDeployment{*deployment3}, nil).
-			Times(1)
-		deployRepoMock.EXPECT().
-			ListByLabel(gomock.Any(), labels.CreateSelectorLabel("exist"), gomock.Any()).
-			Return([]v1alpha1.EdgeDeployment{*deployment4}, nil).
-			Times(1)
-		deployRepoMock.EXPECT().
-			ListByLabel(gomock.Any(), labels.CreateSelectorLabel("label2"), gomock.Any()).
-			Return([]v1alpha1.EdgeDeployment{*deployment5}, nil).
-			Times(1)
 		edgeDeviceRepoMock.EXPECT().
 			PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).
 			Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, patch *client.Patch) {
@@ -412,24 +363,12 @@ var _ = Describe("EdgeDeviceLabels controller/Reconcile", func() {
 		deploymentToAdd.Spec.DeviceSelector = &v1.LabelSelector{
 			MatchLabels: map[string]string{"toadd": "toadd"},
 		}
-		controllers.UpdateSelectorLabels(deploymentToAdd, deploymentToKeep)
+		edgeDeviceLabelsReconciler.SelectorIndex = selectorindex.NewSynced(*deploymentToAdd, *deploymentToKeep)
 
 		edgeDeviceRepoMock.EXPECT().
 			Read(gomock.Any(), gomock.Any(), gomock.Any()).
 			Return(device, nil).
 			Times(1)
-		deployRepoMock.EXPECT().
-			ListByLabel(gomock.Any(), labels.CreateSelectorLabel("toadd"), gomock.Any()).
-			Return([]v1alpha1.EdgeDeployment{*deploymentToAdd}, nil).
-			Times(1)
-		deployRepoMock.EXPECT().
-			ListByLabel(gomock.Any(), labels.CreateSelectorLabel("tokeep"), gomock.Any()).
-			Return([]v1alpha1.EdgeDeployment{*deploymentToKeep}, nil).
-			Times(1)
-		deployRepoMock.EXPECT().
-			ListByLabel(gomock.Any(), gomock.Any(), gomock.Any()).
-			Return(nil, nil).
-			Times(2)
 
 		edgeDeviceRepoMock.EXPECT().
 			PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).
@@ -458,6 +397,43 @@ var _ = Describe("EdgeDeviceLabels controller/Reconcile", func() {
 		Expect(res).To(Equal(reconcile.Result{Requeue: false, RequeueAfter: 0}))
 	})
 
+	It("deployment exceeding spread constraint not added", func() {
+		// given
+		device.Labels = map[string]string{"site": "x"}
+		deployment := getDeployment("test")
+		deployment.Spec.DeviceSelector = &v1.LabelSelector{MatchLabels: map[string]string{"site": "x"}}
+		deployment.Spec.Placement = &v1alpha1.PlacementConfiguration{
+			Spread: &v1alpha1.SpreadConstraint{LabelKey: "site", MaxPerValue: 1},
+		}
+		edgeDeviceLabelsReconciler.SelectorIndex = selectorindex.NewSynced(*deployment)
+		otherDevice := v1alpha1.EdgeDevice{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "other",
+				Namespace: "test",
+				Labels:    map[string]string{"site": "x", labels.WorkloadLabel("test"): "true"},
+			},
+		}
+
+		edgeDeviceRepoMock.EXPECT().
+			Read(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(device, nil).
+			Times(1)
+		edgeDeviceRepoMock.EXPECT().
+			ListForSelector(gomock.Any(), gomock.Any(), "test").
+			Do(func(ctx context.Context, selector *v1.LabelSelector, namespace string) {
+				Expect(selector.MatchLabels).To(Equal(map[string]string{labels.WorkloadLabel("test"): "true"}))
+			}).
+			Return([]v1alpha1.EdgeDevice{otherDevice}, nil).
+			Times(1)
+
+		// when
+		res, err := edgeDeviceLabelsReconciler.Reconcile(context.TODO(), req)
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(Equal(reconcile.Result{Requeue: false, RequeueAfter: 0}))
+	})
+
 })
 
 func sortDeployments(deployments []v1alpha1.Deployment) []v1alpha1.Deployment {
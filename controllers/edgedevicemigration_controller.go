@@ -0,0 +1,256 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	managementv1alpha1 "github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"github.com/project-flotta/flotta-operator/internal/migration"
+	"github.com/project-flotta/flotta-operator/internal/mtls"
+	"github.com/project-flotta/flotta-operator/internal/repository/edgedeployment"
+	"github.com/project-flotta/flotta-operator/internal/repository/edgedevice"
+	"github.com/project-flotta/flotta-operator/internal/repository/edgedevicemigration"
+	"github.com/project-flotta/flotta-operator/internal/selectorindex"
+	"github.com/project-flotta/flotta-operator/internal/storage"
+)
+
+const (
+	// migrationPendingInterval is how often a migration waits for its EdgeDevices to exist
+	migrationPendingInterval = 30 * time.Second
+)
+
+// EdgeDeviceMigrationReconciler reconciles a EdgeDeviceMigration object
+type EdgeDeviceMigrationReconciler struct {
+	EdgeDeviceMigrationRepository edgedevicemigration.Repository
+	EdgeDeviceRepository          edgedevice.Repository
+	EdgeDeploymentRepository      edgedeployment.Repository
+	SelectorIndex                 *selectorindex.Index
+	Revocations                   *mtls.RevocationList
+	Claimer                       *storage.Claimer
+	Recorder                      record.EventRecorder
+	MaxConcurrentReconciles       int
+}
+
+//+kubebuilder:rbac:groups=management.project-flotta.io,resources=edgedevicemigrations,verbs=get;list;watch
+//+kubebuilder:rbac:groups=management.project-flotta.io,resources=edgedevicemigrations/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=management.project-flotta.io,resources=edgedevices,verbs=get;patch;delete
+//+kubebuilder:rbac:groups=management.project-flotta.io,resources=edgedevices/status,verbs=get;patch
+//+kubebuilder:rbac:groups=management.project-flotta.io,resources=edgedeployments,verbs=list;patch
+//+kubebuilder:rbac:groups=objectbucket.io,resources=objectbucketclaims,verbs=delete
+
+// Reconcile transfers the identity of the source EdgeDevice to the target EdgeDevice once the migration is approved:
+// the spec, labels and storage binding are copied to the target device, EdgeDeployments targeting the source device
+// are targeted to the new one, and the source device is retired: its certificate is revoked and it is deleted.
+// The Transferred phase records that the identity was transferred, so that a missing source device is not waited for
+// once it has been retired.
+func (r *EdgeDeviceMigrationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	logger.Info("Reconciling", "edgeDeviceMigration", req)
+
+	edgeDeviceMigration, err := r.EdgeDeviceMigrationRepository.Read(ctx, req.Name, req.Namespace)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{Requeue: true}, err
+	}
+
+	phase := edgeDeviceMigration.Status.Phase
+	if phase == managementv1alpha1.MigrationCompleted || phase == managementv1alpha1.MigrationFailed {
+		return ctrl.Result{}, nil
+	}
+
+	spec := edgeDeviceMigration.Spec
+	if spec.Source == spec.Target {
+		err = r.updateStatus(ctx, edgeDeviceMigration, managementv1alpha1.MigrationFailed, "source and target EdgeDevices must be different")
+		return ctrl.Result{}, err
+	}
+
+	source, err := r.readDevice(ctx, spec.Source, req.Namespace)
+	if err != nil {
+		return ctrl.Result{Requeue: true}, err
+	}
+	if phase == managementv1alpha1.MigrationTransferred {
+		// the source device was retired, or failed to be, by an earlier reconciliation
+		return r.complete(ctx, edgeDeviceMigration, source)
+	}
+	target, err := r.readDevice(ctx, spec.Target, req.Namespace)
+	if err != nil {
+		return ctrl.Result{Requeue: true}, err
+	}
+	if source == nil || target == nil {
+		message := fmt.Sprintf("waiting for EdgeDevices %s and %s to exist", spec.Source, spec.Target)
+		err = r.updateStatus(ctx, edgeDeviceMigration, managementv1alpha1.MigrationPending, message)
+		return ctrl.Result{RequeueAfter: migrationPendingInterval}, err
+	}
+
+	if !spec.Approved {
+		err = r.updateStatus(ctx, edgeDeviceMigration, managementv1alpha1.MigrationWaitingForApproval, "waiting for the migration to be approved")
+		return ctrl.Result{}, err
+	}
+
+	err = r.transfer(ctx, source, target)
+	if err != nil {
+		logger.Error(err, "Cannot migrate EdgeDevice", "source", spec.Source, "target", spec.Target)
+		return ctrl.Result{Requeue: true}, err
+	}
+	err = r.updateStatus(ctx, edgeDeviceMigration, managementv1alpha1.MigrationTransferred, "identity transferred, retiring the source EdgeDevice")
+	if err != nil {
+		return ctrl.Result{Requeue: true}, err
+	}
+
+	return r.complete(ctx, edgeDeviceMigration, source)
+}
+
+// complete retires the source device, unless it is already gone, and completes the migration
+func (r *EdgeDeviceMigrationReconciler) complete(ctx context.Context, edgeDeviceMigration *managementv1alpha1.EdgeDeviceMigration,
+	source *managementv1alpha1.EdgeDevice) (ctrl.Result, error) {
+	spec := edgeDeviceMigration.Spec
+	if source != nil {
+		err := r.retire(ctx, source)
+		if err != nil {
+			log.FromContext(ctx).Error(err, "Cannot retire EdgeDevice", "source", spec.Source)
+			return ctrl.Result{Requeue: true}, err
+		}
+	}
+
+	r.Recorder.Eventf(edgeDeviceMigration, corev1.EventTypeNormal, "Migrated",
+		"Identity of EdgeDevice %s transferred to EdgeDevice %s, certificate of %s revoked", spec.Source, spec.Target, spec.Source)
+	err := r.updateStatus(ctx, edgeDeviceMigration, managementv1alpha1.MigrationCompleted, "identity transferred")
+	if err != nil {
+		return ctrl.Result{Requeue: true}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// readDevice returns nil when the device does not exist
+func (r *EdgeDeviceMigrationReconciler) readDevice(ctx context.Context, name string, namespace string) (*managementv1alpha1.EdgeDevice, error) {
+	edgeDevice, err := r.EdgeDeviceRepository.Read(ctx, name, namespace)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if edgeDevice.DeletionTimestamp != nil {
+		return nil, nil
+	}
+	return edgeDevice, nil
+}
+
+// transfer copies the identity of the source device to the target device. It can be done again when it fails midway.
+func (r *EdgeDeviceMigrationReconciler) transfer(ctx context.Context, source, target *managementv1alpha1.EdgeDevice) error {
+	err := r.EdgeDeviceRepository.Patch(ctx, target, migration.Transfer(source, target))
+	if err != nil {
+		return err
+	}
+
+	if source.Status.DataOBC != nil && len(*source.Status.DataOBC) > 0 {
+		// the claim of the target device is released before it is replaced, so that a transfer done again after a
+		// failure releases it as well
+		if target.Status.DataOBC != nil && len(*target.Status.DataOBC) > 0 && *target.Status.DataOBC != *source.Status.DataOBC {
+			err = r.Claimer.DeleteClaim(ctx, *target.Status.DataOBC, target.Namespace)
+			if err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+		}
+		patch := client.MergeFrom(target.DeepCopy())
+		dataOBC := *source.Status.DataOBC
+		target.Status.DataOBC = &dataOBC
+		err = r.EdgeDeviceRepository.PatchStatus(ctx, target, &patch)
+		if err != nil {
+			return err
+		}
+	}
+
+	return r.retargetDeployments(ctx, source, target)
+}
+
+// retargetDeployments targets the EdgeDeployments that target the source device by name to the target device
+func (r *EdgeDeviceMigrationReconciler) retargetDeployments(ctx context.Context, source, target *managementv1alpha1.EdgeDevice) error {
+	edgeDeployments, err := r.SelectorIndex.TargetingByName(source.Namespace, source.Name)
+	if err != nil {
+		return err
+	}
+	for i := range edgeDeployments {
+		edgeDeployment := edgeDeployments[i]
+		updated := edgeDeployment.DeepCopy()
+		updated.Spec.Device = target.Name
+		err = r.EdgeDeploymentRepository.Patch(ctx, &edgeDeployment, updated)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retire revokes the certificate of the device and deletes it without waiting for the device to acknowledge it.
+// The Object Bucket Claim of the device is not deleted, because it is bound to the new device.
+func (r *EdgeDeviceMigrationReconciler) retire(ctx context.Context, edgeDevice *managementv1alpha1.EdgeDevice) error {
+	err := r.Revocations.Revoke(ctx, edgeDevice.Namespace, edgeDevice.Name, time.Now())
+	if err != nil {
+		return err
+	}
+
+	err = removeYggdrasilFinalizers(ctx, r.EdgeDeviceRepository, edgeDevice)
+	if err != nil {
+		return err
+	}
+
+	err = r.EdgeDeviceRepository.Delete(ctx, edgeDevice)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func (r *EdgeDeviceMigrationReconciler) updateStatus(ctx context.Context, edgeDeviceMigration *managementv1alpha1.EdgeDeviceMigration,
+	phase managementv1alpha1.EdgeDeviceMigrationPhase, message string) error {
+	if edgeDeviceMigration.Status.Phase == phase && edgeDeviceMigration.Status.Message == message {
+		return nil
+	}
+	patch := client.MergeFrom(edgeDeviceMigration.DeepCopy())
+	edgeDeviceMigration.Status.Phase = phase
+	edgeDeviceMigration.Status.Message = message
+	if phase == managementv1alpha1.MigrationCompleted {
+		now := metav1.Now()
+		edgeDeviceMigration.Status.CompletionTime = &now
+	}
+	return r.EdgeDeviceMigrationRepository.PatchStatus(ctx, edgeDeviceMigration, &patch)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *EdgeDeviceMigrationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&managementv1alpha1.EdgeDeviceMigration{}).
+		WithEventFilter(predicate.GenerationChangedPredicate{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Complete(r)
+}
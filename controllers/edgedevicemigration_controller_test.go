@@ -0,0 +1,308 @@
+package controllers_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	obv1 "github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"github.com/project-flotta/flotta-operator/controllers"
+	"github.com/project-flotta/flotta-operator/internal/mtls"
+	"github.com/project-flotta/flotta-operator/internal/repository/edgedeployment"
+	"github.com/project-flotta/flotta-operator/internal/repository/edgedevice"
+	"github.com/project-flotta/flotta-operator/internal/repository/edgedevicemigration"
+	"github.com/project-flotta/flotta-operator/internal/selectorindex"
+	"github.com/project-flotta/flotta-operator/internal/storage"
+	"github.com/project-flotta/flotta-operator/internal/yggdrasil"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("EdgeDeviceMigration controller/Reconcile", func() {
+	var (
+		mockCtrl            *gomock.Controller
+		migrationRepoMock   *edgedevicemigration.MockRepository
+		deployRepoMock      *edgedeployment.MockRepository
+		edgeDeviceRepoMock  *edgedevice.MockRepository
+		recorder            *record.FakeRecorder
+		migrationReconciler *controllers.EdgeDeviceMigrationReconciler
+		req                 = ctrl.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      "migration",
+				Namespace: "default",
+			},
+		}
+		edgeDeviceMigration *v1alpha1.EdgeDeviceMigration
+		source              *v1alpha1.EdgeDevice
+		target              *v1alpha1.EdgeDevice
+		notFound            = errors.NewNotFound(schema.GroupResource{Group: "", Resource: "notfound"}, "notfound")
+	)
+
+	getDevice := func(name string) *v1alpha1.EdgeDevice {
+		return &v1alpha1.EdgeDevice{
+			ObjectMeta: v1.ObjectMeta{
+				Name:       name,
+				Namespace:  "default",
+				Finalizers: []string{yggdrasil.YggdrasilConnectionFinalizer, yggdrasil.YggdrasilWorkloadFinalizer},
+			},
+			Spec: v1alpha1.EdgeDeviceSpec{
+				RequestTime: &v1.Time{},
+			},
+		}
+	}
+
+	expectStatus := func(phase v1alpha1.EdgeDeviceMigrationPhase) {
+		migrationRepoMock.EXPECT().
+			PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).
+			Do(func(ctx context.Context, migration *v1alpha1.EdgeDeviceMigration, patch *client.Patch) {
+				Expect(migration.Status.Phase).To(Equal(phase))
+			}).
+			Return(nil).
+			Times(1)
+	}
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		migrationRepoMock = edgedevicemigration.NewMockRepository(mockCtrl)
+		deployRepoMock = edgedeployment.NewMockRepository(mockCtrl)
+		edgeDeviceRepoMock = edgedevice.NewMockRepository(mockCtrl)
+		recorder = record.NewFakeRecorder(10)
+		migrationReconciler = &controllers.EdgeDeviceMigrationReconciler{
+			EdgeDeviceMigrationRepository: migrationRepoMock,
+			EdgeDeviceRepository:          edgeDeviceRepoMock,
+			EdgeDeploymentRepository:      deployRepoMock,
+			SelectorIndex:                 selectorindex.NewSynced(),
+			Revocations:                   mtls.NewRevocationList(k8sClient, "default", "default", 30*24*time.Hour),
+			Claimer:                       storage.NewClaimer(k8sClient),
+			Recorder:                      recorder,
+		}
+
+		edgeDeviceMigration = &v1alpha1.EdgeDeviceMigration{
+			ObjectMeta: v1.ObjectMeta{Name: "migration", Namespace: "default"},
+			Spec:       v1alpha1.EdgeDeviceMigrationSpec{Source: "old", Target: "new"},
+		}
+		source = getDevice("old")
+		source.Labels = map[string]string{"site": "madrid", "device.hostname": "old-host"}
+		source.Spec.Heartbeat = &v1alpha1.HeartbeatConfiguration{PeriodSeconds: 30}
+		obc := "old"
+		source.Status.DataOBC = &obc
+		target = getDevice("new")
+		target.Labels = map[string]string{"device.hostname": "new-host"}
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	It("EdgeDeviceMigration does not exist", func() {
+		// given
+		migrationRepoMock.EXPECT().Read(gomock.Any(), req.Name, req.Namespace).Return(nil, notFound)
+
+		// when
+		res, err := migrationReconciler.Reconcile(context.TODO(), req)
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(Equal(reconcile.Result{}))
+	})
+
+	It("Cannot read EdgeDeviceMigration", func() {
+		// given
+		migrationRepoMock.EXPECT().Read(gomock.Any(), req.Name, req.Namespace).Return(nil, fmt.Errorf("failed"))
+
+		// when
+		res, err := migrationReconciler.Reconcile(context.TODO(), req)
+
+		// then
+		Expect(err).To(HaveOccurred())
+		Expect(res.Requeue).To(BeTrue())
+	})
+
+	It("Completed migration is not processed again", func() {
+		// given
+		edgeDeviceMigration.Status.Phase = v1alpha1.MigrationCompleted
+		migrationRepoMock.EXPECT().Read(gomock.Any(), req.Name, req.Namespace).Return(edgeDeviceMigration, nil)
+
+		// when
+		res, err := migrationReconciler.Reconcile(context.TODO(), req)
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(Equal(reconcile.Result{}))
+	})
+
+	It("Migration to the same device fails", func() {
+		// given
+		edgeDeviceMigration.Spec.Target = "old"
+		migrationRepoMock.EXPECT().Read(gomock.Any(), req.Name, req.Namespace).Return(edgeDeviceMigration, nil)
+		expectStatus(v1alpha1.MigrationFailed)
+
+		// when
+		_, err := migrationReconciler.Reconcile(context.TODO(), req)
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("Migration waits for the target device to register", func() {
+		// given
+		migrationRepoMock.EXPECT().Read(gomock.Any(), req.Name, req.Namespace).Return(edgeDeviceMigration, nil)
+		edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), "old", "default").Return(source, nil)
+		edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), "new", "default").Return(nil, notFound)
+		expectStatus(v1alpha1.MigrationPending)
+
+		// when
+		res, err := migrationReconciler.Reconcile(context.TODO(), req)
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.RequeueAfter).To(BeNumerically(">", 0))
+	})
+
+	It("Migration waits for approval", func() {
+		// given
+		migrationRepoMock.EXPECT().Read(gomock.Any(), req.Name, req.Namespace).Return(edgeDeviceMigration, nil)
+		edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), "old", "default").Return(source, nil)
+		edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), "new", "default").Return(target, nil)
+		expectStatus(v1alpha1.MigrationWaitingForApproval)
+
+		// when
+		res, err := migrationReconciler.Reconcile(context.TODO(), req)
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(Equal(reconcile.Result{}))
+	})
+
+	It("Approved migration transfers the identity and retires the source device", func() {
+		// given
+		edgeDeviceMigration.Spec.Approved = true
+		migrationRepoMock.EXPECT().Read(gomock.Any(), req.Name, req.Namespace).Return(edgeDeviceMigration, nil)
+		edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), "old", "default").Return(source, nil)
+		edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), "new", "default").Return(target, nil)
+
+		edgeDeviceRepoMock.EXPECT().
+			Patch(gomock.Any(), target, gomock.Any()).
+			Do(func(ctx context.Context, old, new *v1alpha1.EdgeDevice) {
+				Expect(new.Name).To(Equal("new"))
+				Expect(new.Spec.Heartbeat.PeriodSeconds).To(BeEquivalentTo(30))
+				Expect(new.Labels).To(Equal(map[string]string{"site": "madrid", "device.hostname": "new-host"}))
+			}).
+			Return(nil)
+		edgeDeviceRepoMock.EXPECT().
+			PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).
+			Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, patch *client.Patch) {
+				Expect(edgeDevice.Name).To(Equal("new"))
+				Expect(*edgeDevice.Status.DataOBC).To(Equal("old"))
+			}).
+			Return(nil)
+
+		deployment := v1alpha1.EdgeDeployment{
+			ObjectMeta: v1.ObjectMeta{Name: "camera", Namespace: "default"},
+			Spec:       v1alpha1.EdgeDeploymentSpec{Device: "old"},
+		}
+		migrationReconciler.SelectorIndex = selectorindex.NewSynced(deployment)
+		deployRepoMock.EXPECT().
+			Patch(gomock.Any(), gomock.Any(), gomock.Any()).
+			Do(func(ctx context.Context, old, new *v1alpha1.EdgeDeployment) {
+				Expect(new.Spec.Device).To(Equal("new"))
+			}).
+			Return(nil)
+
+		edgeDeviceRepoMock.EXPECT().
+			Patch(gomock.Any(), source, gomock.Any()).
+			Do(func(ctx context.Context, old, new *v1alpha1.EdgeDevice) {
+				Expect(new.Finalizers).To(BeEmpty())
+			}).
+			Return(nil)
+		edgeDeviceRepoMock.EXPECT().Delete(gomock.Any(), source).Return(nil)
+		expectStatus(v1alpha1.MigrationTransferred)
+		expectStatus(v1alpha1.MigrationCompleted)
+
+		// when
+		res, err := migrationReconciler.Reconcile(context.TODO(), req)
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(Equal(reconcile.Result{}))
+		Expect(recorder.Events).To(Receive(ContainSubstring("Migrated")))
+	})
+
+	It("Transferred migration completes once the source device is gone", func() {
+		// given
+		edgeDeviceMigration.Spec.Approved = true
+		edgeDeviceMigration.Status.Phase = v1alpha1.MigrationTransferred
+		migrationRepoMock.EXPECT().Read(gomock.Any(), req.Name, req.Namespace).Return(edgeDeviceMigration, nil)
+		edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), "old", "default").Return(nil, notFound)
+		expectStatus(v1alpha1.MigrationCompleted)
+
+		// when
+		res, err := migrationReconciler.Reconcile(context.TODO(), req)
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(Equal(reconcile.Result{}))
+		Expect(recorder.Events).To(Receive(ContainSubstring("Migrated")))
+	})
+
+	It("Transferred migration retires the source device again", func() {
+		// given
+		edgeDeviceMigration.Spec.Approved = true
+		edgeDeviceMigration.Status.Phase = v1alpha1.MigrationTransferred
+		migrationRepoMock.EXPECT().Read(gomock.Any(), req.Name, req.Namespace).Return(edgeDeviceMigration, nil)
+		edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), "old", "default").Return(source, nil)
+		edgeDeviceRepoMock.EXPECT().Patch(gomock.Any(), source, gomock.Any()).Return(nil)
+		edgeDeviceRepoMock.EXPECT().Delete(gomock.Any(), source).Return(fmt.Errorf("failed"))
+
+		// when
+		res, err := migrationReconciler.Reconcile(context.TODO(), req)
+
+		// then
+		Expect(err).To(HaveOccurred())
+		Expect(res.Requeue).To(BeTrue())
+	})
+
+	It("Approved migration deletes the Object Bucket Claim of the target device", func() {
+		// given
+		obc := &obv1.ObjectBucketClaim{
+			ObjectMeta: v1.ObjectMeta{Name: "migration-target-claim", Namespace: "default"},
+		}
+		Expect(k8sClient.Create(context.TODO(), obc)).To(Succeed())
+		targetOBC := obc.Name
+		target.Status.DataOBC = &targetOBC
+
+		edgeDeviceMigration.Spec.Approved = true
+		migrationRepoMock.EXPECT().Read(gomock.Any(), req.Name, req.Namespace).Return(edgeDeviceMigration, nil)
+		edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), "old", "default").Return(source, nil)
+		edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), "new", "default").Return(target, nil)
+		edgeDeviceRepoMock.EXPECT().Patch(gomock.Any(), target, gomock.Any()).Return(nil)
+		edgeDeviceRepoMock.EXPECT().
+			PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).
+			Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, patch *client.Patch) {
+				Expect(*edgeDevice.Status.DataOBC).To(Equal("old"))
+			}).
+			Return(nil)
+		edgeDeviceRepoMock.EXPECT().Patch(gomock.Any(), source, gomock.Any()).Return(nil)
+		edgeDeviceRepoMock.EXPECT().Delete(gomock.Any(), source).Return(nil)
+		expectStatus(v1alpha1.MigrationTransferred)
+		expectStatus(v1alpha1.MigrationCompleted)
+
+		// when
+		_, err := migrationReconciler.Reconcile(context.TODO(), req)
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		err = k8sClient.Get(context.TODO(), client.ObjectKeyFromObject(obc), &obv1.ObjectBucketClaim{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+})
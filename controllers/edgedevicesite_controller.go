@@ -0,0 +1,190 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	goerrors "errors"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	managementv1alpha1 "github.com/project-flotta/flotta-operator/api/v1alpha1"
+	flottalabels "github.com/project-flotta/flotta-operator/internal/labels"
+	"github.com/project-flotta/flotta-operator/internal/repository/edgedevice"
+	"github.com/project-flotta/flotta-operator/internal/repository/site"
+)
+
+// EdgeDeviceSiteReconciler sets the site labels of EdgeDevices: one for the Site the device is located at and one for
+// each Site above it, so that selectors can target a subtree of the site topology. The labels of a device are kept
+// while the parents of its Site cannot be resolved, rather than undeploying the workloads of a whole subtree because
+// of a missing parent; they are removed when the Site of the device does not exist.
+type EdgeDeviceSiteReconciler struct {
+	EdgeDeviceRepository    edgedevice.Repository
+	SiteRepository          site.Repository
+	MaxConcurrentReconciles int
+}
+
+//+kubebuilder:rbac:groups=management.project-flotta.io,resources=edgedevices,verbs=get;list;watch;patch
+//+kubebuilder:rbac:groups=management.project-flotta.io,resources=sites,verbs=get;list;watch
+
+func (r *EdgeDeviceSiteReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithName("site")
+
+	edgeDevice, err := r.EdgeDeviceRepository.Read(ctx, req.Name, req.Namespace)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{Requeue: true}, err
+	}
+	if edgeDevice.DeletionTimestamp != nil {
+		return ctrl.Result{}, nil
+	}
+
+	labels, err := r.expectedSiteLabels(ctx, edgeDevice)
+	if err != nil {
+		var topologyErr *siteTopologyError
+		if goerrors.As(err, &topologyErr) {
+			logger.Info("Site labels kept, the Site cannot be resolved", "site", edgeDevice.Spec.Site, "reason", err.Error())
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{Requeue: true}, err
+	}
+	if reflect.DeepEqual(labels, siteLabels(edgeDevice)) {
+		return ctrl.Result{}, nil
+	}
+
+	updated := edgeDevice.DeepCopy()
+	if updated.Labels == nil {
+		updated.Labels = map[string]string{}
+	}
+	for label := range updated.Labels {
+		if flottalabels.IsSiteLabel(label) {
+			delete(updated.Labels, label)
+		}
+	}
+	for label, value := range labels {
+		updated.Labels[label] = value
+	}
+	err = r.EdgeDeviceRepository.Patch(ctx, edgeDevice, updated)
+	if err != nil {
+		return ctrl.Result{Requeue: true}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// expectedSiteLabels returns the site labels of the Sites on the path of the Site of the device
+func (r *EdgeDeviceSiteReconciler) expectedSiteLabels(ctx context.Context, edgeDevice *managementv1alpha1.EdgeDevice) (map[string]string, error) {
+	labels := map[string]string{}
+	if edgeDevice.Spec.Site == "" {
+		return labels, nil
+	}
+	s, err := r.SiteRepository.Read(ctx, edgeDevice.Spec.Site, edgeDevice.Namespace)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return labels, nil
+		}
+		return nil, err
+	}
+	path, err := sitePath(ctx, r.SiteRepository, s)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range path {
+		labels[flottalabels.SiteLabel(name)] = "true"
+	}
+	return labels, nil
+}
+
+// SetupWithManager sets up the controller with the Manager. It registers the field index of the references to Sites,
+// which the SiteReconciler relies on as well.
+func (r *EdgeDeviceSiteReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := r.setupIndexes(mgr); err != nil {
+		return err
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("edgedevicesite").
+		For(&managementv1alpha1.EdgeDevice{},
+			builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.LabelChangedPredicate{}))).
+		Watches(&source.Kind{Type: &managementv1alpha1.Site{}},
+			handler.EnqueueRequestsFromMapFunc(r.devicesOfSite),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Complete(r)
+}
+
+func (r *EdgeDeviceSiteReconciler) setupIndexes(mgr ctrl.Manager) error {
+	indexer := mgr.GetFieldIndexer()
+	ctx := context.Background()
+	err := indexer.IndexField(ctx, &managementv1alpha1.EdgeDevice{}, site.ReferencesIndexKey, func(obj client.Object) []string {
+		if name := obj.(*managementv1alpha1.EdgeDevice).Spec.Site; name != "" {
+			return []string{name}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return indexer.IndexField(ctx, &managementv1alpha1.Site{}, site.ReferencesIndexKey, func(obj client.Object) []string {
+		if parent := obj.(*managementv1alpha1.Site).Spec.Parent; parent != "" {
+			return []string{parent}
+		}
+		return nil
+	})
+}
+
+// devicesOfSite maps a Site to the EdgeDevices located at it or below it: the ones labelled with its site label, whose
+// labels may be outdated, and the ones referring to it or to a Site below it, which may not be labelled yet
+func (r *EdgeDeviceSiteReconciler) devicesOfSite(obj client.Object) []reconcile.Request {
+	ctx := context.Background()
+	namespace := obj.GetNamespace()
+	selector := metav1.LabelSelector{MatchLabels: map[string]string{flottalabels.SiteLabel(obj.GetName()): "true"}}
+	devices, err := r.EdgeDeviceRepository.ListForSelector(ctx, &selector, namespace)
+	if err != nil {
+		log.Log.Error(err, "cannot list EdgeDevices of the Site", "Name", obj.GetName(), "Namespace", namespace)
+	}
+	for _, name := range siteSubtree(ctx, r.SiteRepository, obj.GetName(), namespace) {
+		referring, err := r.SiteRepository.ListReferringEdgeDevices(ctx, name, namespace)
+		if err != nil {
+			log.Log.Error(err, "cannot list EdgeDevices referring to the Site", "Name", name, "Namespace", namespace)
+			continue
+		}
+		devices = append(devices, referring...)
+	}
+
+	var requests []reconcile.Request
+	seen := map[string]bool{}
+	for _, device := range devices {
+		if !seen[device.Name] {
+			seen[device.Name] = true
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: device.Name, Namespace: namespace}})
+		}
+	}
+	return requests
+}
@@ -0,0 +1,241 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	managementv1alpha1 "github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"github.com/project-flotta/flotta-operator/internal/devicemetrics"
+	flottalabels "github.com/project-flotta/flotta-operator/internal/labels"
+	"github.com/project-flotta/flotta-operator/internal/references"
+	"github.com/project-flotta/flotta-operator/internal/repository/edgedevice"
+	"github.com/project-flotta/flotta-operator/internal/repository/edgedeviceset"
+	"github.com/project-flotta/flotta-operator/internal/repository/metricsallowlist"
+)
+
+const (
+	// Reasons of the Resolved condition
+	allowListResolvedReason         = "Resolved"
+	allowListResolutionFailedReason = "ResolutionFailed"
+)
+
+// MetricsAllowListReconciler keeps the status of MetricsAllowLists up to date: the number of EdgeDevices and
+// EdgeDeployments referring to them and whether the allow-lists they extend and include can be resolved. It relies on
+// the field indexes registered by the EdgeDeviceConfigurationReconciler.
+type MetricsAllowListReconciler struct {
+	MetricsAllowListRepository metricsallowlist.Repository
+	EdgeDeviceRepository       edgedevice.Repository
+	EdgeDeviceSetRepository    edgedeviceset.Repository
+	AllowLists                 devicemetrics.AllowListGenerator
+	MaxConcurrentReconciles    int
+}
+
+//+kubebuilder:rbac:groups=management.project-flotta.io,resources=metricsallowlists,verbs=get;list;watch
+//+kubebuilder:rbac:groups=management.project-flotta.io,resources=metricsallowlists/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=management.project-flotta.io,resources=edgedevices,verbs=get;list;watch
+//+kubebuilder:rbac:groups=management.project-flotta.io,resources=edgedevicesets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=management.project-flotta.io,resources=edgedeployments,verbs=get;list;watch
+
+func (r *MetricsAllowListReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	allowList, err := r.MetricsAllowListRepository.Read(ctx, req.Name, req.Namespace)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{Requeue: true}, err
+	}
+	if allowList.DeletionTimestamp != nil {
+		return ctrl.Result{}, nil
+	}
+
+	devices, err := r.countDevices(ctx, allowList)
+	if err != nil {
+		return ctrl.Result{Requeue: true}, err
+	}
+	deployments, err := r.MetricsAllowListRepository.ListReferringEdgeDeployments(ctx, allowList.Name, allowList.Namespace)
+	if err != nil {
+		return ctrl.Result{Requeue: true}, err
+	}
+
+	original := allowList.DeepCopy()
+	allowList.Status.Devices = devices
+	allowList.Status.Deployments = int32(len(deployments))
+	condition := metav1.Condition{
+		Type:               managementv1alpha1.MetricsAllowListResolvedCondition,
+		Status:             metav1.ConditionTrue,
+		Reason:             allowListResolvedReason,
+		Message:            "The allow-lists extended and included are resolved",
+		ObservedGeneration: allowList.Generation,
+	}
+	if _, err = r.AllowLists.GenerateFromAllowList(ctx, allowList.Name, allowList.Namespace); err != nil {
+		logger.V(1).Info("MetricsAllowList cannot be resolved", "reason", err.Error())
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = allowListResolutionFailedReason
+		condition.Message = err.Error()
+	}
+	meta.SetStatusCondition(&allowList.Status.Conditions, condition)
+
+	if !reflect.DeepEqual(original.Status, allowList.Status) {
+		patch := client.MergeFrom(original)
+		err = r.MetricsAllowListRepository.PatchStatus(ctx, allowList, &patch)
+		if err != nil {
+			return ctrl.Result{Requeue: true}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// countDevices returns the number of EdgeDevices referring to the allow-list, directly or through their EdgeDeviceSet
+func (r *MetricsAllowListReconciler) countDevices(ctx context.Context, allowList *managementv1alpha1.MetricsAllowList) (int32, error) {
+	devices := map[string]struct{}{}
+	referring, err := r.MetricsAllowListRepository.ListReferringEdgeDevices(ctx, allowList.Name, allowList.Namespace)
+	if err != nil {
+		return 0, err
+	}
+	for _, device := range referring {
+		devices[device.Name] = struct{}{}
+	}
+
+	sets, err := r.MetricsAllowListRepository.ListReferringEdgeDeviceSets(ctx, allowList.Name, allowList.Namespace)
+	if err != nil {
+		return 0, err
+	}
+	for _, set := range sets {
+		selector := metav1.LabelSelector{MatchLabels: map[string]string{flottalabels.DeviceSetLabel: set.Name}}
+		members, err := r.EdgeDeviceRepository.ListForSelector(ctx, &selector, allowList.Namespace)
+		if err != nil {
+			return 0, err
+		}
+		for _, device := range members {
+			devices[device.Name] = struct{}{}
+		}
+	}
+	return int32(len(devices)), nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *MetricsAllowListReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&managementv1alpha1.MetricsAllowList{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&source.Kind{Type: &managementv1alpha1.MetricsAllowList{}},
+			handler.EnqueueRequestsFromMapFunc(r.allowListsReferringAllowList),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&source.Kind{Type: &managementv1alpha1.EdgeDevice{}},
+			handler.EnqueueRequestsFromMapFunc(r.allowListsOfDevice),
+			builder.WithPredicates(allowListReferencesChanged(func(obj client.Object) []string {
+				device := obj.(*managementv1alpha1.EdgeDevice)
+				return append(references.EdgeDeviceMetricsAllowLists(device), device.Labels[flottalabels.DeviceSetLabel])
+			}))).
+		Watches(&source.Kind{Type: &managementv1alpha1.EdgeDeviceSet{}},
+			handler.EnqueueRequestsFromMapFunc(r.allowListsOfSet),
+			builder.WithPredicates(allowListReferencesChanged(func(obj client.Object) []string {
+				return references.EdgeDeviceSetMetricsAllowLists(obj.(*managementv1alpha1.EdgeDeviceSet))
+			}))).
+		Watches(&source.Kind{Type: &managementv1alpha1.EdgeDeployment{}},
+			handler.EnqueueRequestsFromMapFunc(r.allowListsOfDeployment),
+			builder.WithPredicates(allowListReferencesChanged(func(obj client.Object) []string {
+				return references.EdgeDeploymentMetricsAllowLists(obj.(*managementv1alpha1.EdgeDeployment))
+			}))).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Complete(r)
+}
+
+// allowListsReferringAllowList maps a MetricsAllowList to the MetricsAllowLists extending or including it, directly or
+// through other MetricsAllowLists, whose resolution depends on it
+func (r *MetricsAllowListReconciler) allowListsReferringAllowList(obj client.Object) []reconcile.Request {
+	ctx := context.Background()
+	var requests []reconcile.Request
+	names := []string{obj.GetName()}
+	seen := map[string]bool{obj.GetName(): true}
+	for i := 0; i < len(names); i++ {
+		referring, err := r.MetricsAllowListRepository.ListReferring(ctx, names[i], obj.GetNamespace())
+		if err != nil {
+			log.Log.Error(err, "cannot list referring MetricsAllowLists", "Name", names[i], "Namespace", obj.GetNamespace())
+			continue
+		}
+		for _, allowList := range referring {
+			if !seen[allowList.Name] {
+				seen[allowList.Name] = true
+				names = append(names, allowList.Name)
+				requests = append(requests, allowListRequest(allowList.Namespace, allowList.Name))
+			}
+		}
+	}
+	return requests
+}
+
+func (r *MetricsAllowListReconciler) allowListsOfDevice(obj client.Object) []reconcile.Request {
+	device := obj.(*managementv1alpha1.EdgeDevice)
+	names := references.EdgeDeviceMetricsAllowLists(device)
+	if setName, ok := device.Labels[flottalabels.DeviceSetLabel]; ok {
+		set, err := r.EdgeDeviceSetRepository.Read(context.Background(), setName, device.Namespace)
+		if err == nil {
+			names = append(names, references.EdgeDeviceSetMetricsAllowLists(set)...)
+		} else if !errors.IsNotFound(err) {
+			log.Log.Error(err, "cannot read EdgeDeviceSet", "Name", setName, "Namespace", device.Namespace)
+		}
+	}
+	return allowListRequests(device.Namespace, names)
+}
+
+func (r *MetricsAllowListReconciler) allowListsOfSet(obj client.Object) []reconcile.Request {
+	return allowListRequests(obj.GetNamespace(), references.EdgeDeviceSetMetricsAllowLists(obj.(*managementv1alpha1.EdgeDeviceSet)))
+}
+
+func (r *MetricsAllowListReconciler) allowListsOfDeployment(obj client.Object) []reconcile.Request {
+	return allowListRequests(obj.GetNamespace(), references.EdgeDeploymentMetricsAllowLists(obj.(*managementv1alpha1.EdgeDeployment)))
+}
+
+func allowListRequests(namespace string, names []string) []reconcile.Request {
+	var requests []reconcile.Request
+	for _, name := range names {
+		requests = append(requests, allowListRequest(namespace, name))
+	}
+	return requests
+}
+
+func allowListRequest(namespace, name string) reconcile.Request {
+	return reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: namespace}}
+}
+
+// allowListReferencesChanged filters the updates that change the MetricsAllowLists the object refers to
+func allowListReferencesChanged(referencesOf func(obj client.Object) []string) predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return !reflect.DeepEqual(referencesOf(e.ObjectOld), referencesOf(e.ObjectNew))
+		},
+	}
+}
@@ -0,0 +1,165 @@
+package controllers_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"github.com/project-flotta/flotta-operator/controllers"
+	"github.com/project-flotta/flotta-operator/internal/devicemetrics"
+	"github.com/project-flotta/flotta-operator/internal/repository/edgedevice"
+	"github.com/project-flotta/flotta-operator/internal/repository/edgedeviceset"
+	"github.com/project-flotta/flotta-operator/internal/repository/metricsallowlist"
+	"github.com/project-flotta/flotta-operator/models"
+)
+
+var _ = Describe("MetricsAllowList controller", func() {
+	var (
+		mockCtrl           *gomock.Controller
+		allowListRepoMock  *metricsallowlist.MockRepository
+		edgeDeviceRepoMock *edgedevice.MockRepository
+		allowListsMock     *devicemetrics.MockAllowListGenerator
+		reconciler         *controllers.MetricsAllowListReconciler
+		allowList          *v1alpha1.MetricsAllowList
+		req                = ctrl.Request{NamespacedName: types.NamespacedName{Name: "test", Namespace: "test"}}
+	)
+
+	device := func(name string) v1alpha1.EdgeDevice {
+		return v1alpha1.EdgeDevice{ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "test"}}
+	}
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		allowListRepoMock = metricsallowlist.NewMockRepository(mockCtrl)
+		edgeDeviceRepoMock = edgedevice.NewMockRepository(mockCtrl)
+		allowListsMock = devicemetrics.NewMockAllowListGenerator(mockCtrl)
+		reconciler = &controllers.MetricsAllowListReconciler{
+			MetricsAllowListRepository: allowListRepoMock,
+			EdgeDeviceRepository:       edgeDeviceRepoMock,
+			EdgeDeviceSetRepository:    edgedeviceset.NewMockRepository(mockCtrl),
+			AllowLists:                 allowListsMock,
+		}
+		allowList = &v1alpha1.MetricsAllowList{ObjectMeta: v1.ObjectMeta{Name: "test", Namespace: "test", Generation: 2}}
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	expectReferences := func(devices []v1alpha1.EdgeDevice, sets []v1alpha1.EdgeDeviceSet, deployments []v1alpha1.EdgeDeployment) {
+		allowListRepoMock.EXPECT().Read(gomock.Any(), "test", "test").Return(allowList, nil)
+		allowListRepoMock.EXPECT().ListReferringEdgeDevices(gomock.Any(), "test", "test").Return(devices, nil)
+		allowListRepoMock.EXPECT().ListReferringEdgeDeviceSets(gomock.Any(), "test", "test").Return(sets, nil)
+		allowListRepoMock.EXPECT().ListReferringEdgeDeployments(gomock.Any(), "test", "test").Return(deployments, nil)
+	}
+
+	It("should ignore missing allow-lists", func() {
+		// given
+		allowListRepoMock.EXPECT().Read(gomock.Any(), "test", "test").
+			Return(nil, errors.NewNotFound(schema.GroupResource{}, "test"))
+
+		// when
+		res, err := reconciler.Reconcile(context.TODO(), req)
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(Equal(ctrl.Result{}))
+	})
+
+	It("should count the devices referring directly and through their set, and the deployments", func() {
+		// given
+		expectReferences(
+			[]v1alpha1.EdgeDevice{device("device1"), device("device2")},
+			[]v1alpha1.EdgeDeviceSet{{ObjectMeta: v1.ObjectMeta{Name: "set", Namespace: "test"}}},
+			[]v1alpha1.EdgeDeployment{{ObjectMeta: v1.ObjectMeta{Name: "workload", Namespace: "test"}}},
+		)
+		edgeDeviceRepoMock.EXPECT().ListForSelector(gomock.Any(), gomock.Any(), "test").
+			Return([]v1alpha1.EdgeDevice{device("device2"), device("device3")}, nil)
+		allowListsMock.EXPECT().GenerateFromAllowList(gomock.Any(), "test", "test").Return(&models.MetricsAllowList{}, nil)
+
+		var patched *v1alpha1.MetricsAllowList
+		allowListRepoMock.EXPECT().PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).
+			Do(func(_ context.Context, allowList *v1alpha1.MetricsAllowList, _ *client.Patch) {
+				patched = allowList
+			}).Return(nil)
+
+		// when
+		_, err := reconciler.Reconcile(context.TODO(), req)
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(patched.Status.Devices).To(Equal(int32(3)))
+		Expect(patched.Status.Deployments).To(Equal(int32(1)))
+		resolved := meta.FindStatusCondition(patched.Status.Conditions, v1alpha1.MetricsAllowListResolvedCondition)
+		Expect(resolved).NotTo(BeNil())
+		Expect(resolved.Status).To(Equal(v1.ConditionTrue))
+		Expect(resolved.ObservedGeneration).To(Equal(int64(2)))
+	})
+
+	It("should report allow-lists that cannot be resolved", func() {
+		// given
+		expectReferences(nil, nil, nil)
+		allowListsMock.EXPECT().GenerateFromAllowList(gomock.Any(), "test", "test").Return(nil, fmt.Errorf("cycle"))
+
+		var patched *v1alpha1.MetricsAllowList
+		allowListRepoMock.EXPECT().PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).
+			Do(func(_ context.Context, allowList *v1alpha1.MetricsAllowList, _ *client.Patch) {
+				patched = allowList
+			}).Return(nil)
+
+		// when
+		_, err := reconciler.Reconcile(context.TODO(), req)
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		resolved := meta.FindStatusCondition(patched.Status.Conditions, v1alpha1.MetricsAllowListResolvedCondition)
+		Expect(resolved.Status).To(Equal(v1.ConditionFalse))
+		Expect(resolved.Message).To(Equal("cycle"))
+	})
+
+	It("should not patch an unchanged status", func() {
+		// given
+		allowList.Status = v1alpha1.MetricsAllowListStatus{
+			Devices: 1,
+			Conditions: []v1.Condition{{
+				Type:               v1alpha1.MetricsAllowListResolvedCondition,
+				Status:             v1.ConditionTrue,
+				Reason:             "Resolved",
+				Message:            "The allow-lists extended and included are resolved",
+				ObservedGeneration: 2,
+			}},
+		}
+		expectReferences([]v1alpha1.EdgeDevice{device("device1")}, nil, nil)
+		allowListsMock.EXPECT().GenerateFromAllowList(gomock.Any(), "test", "test").Return(&models.MetricsAllowList{}, nil)
+
+		// when
+		_, err := reconciler.Reconcile(context.TODO(), req)
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should retry when the referring devices cannot be listed", func() {
+		// given
+		allowListRepoMock.EXPECT().Read(gomock.Any(), "test", "test").Return(allowList, nil)
+		allowListRepoMock.EXPECT().ListReferringEdgeDevices(gomock.Any(), "test", "test").Return(nil, fmt.Errorf("boom"))
+
+		// when
+		res, err := reconciler.Reconcile(context.TODO(), req)
+
+		// then
+		Expect(err).To(HaveOccurred())
+		Expect(res.Requeue).To(BeTrue())
+	})
+})
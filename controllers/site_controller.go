@@ -0,0 +1,318 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	managementv1alpha1 "github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"github.com/project-flotta/flotta-operator/internal/fleet"
+	flottalabels "github.com/project-flotta/flotta-operator/internal/labels"
+	"github.com/project-flotta/flotta-operator/internal/repository/edgedevice"
+	"github.com/project-flotta/flotta-operator/internal/repository/site"
+)
+
+const (
+	// maxSiteDepth limits the number of levels of the site topology
+	maxSiteDepth = 16
+
+	// defaultSiteRefreshPeriod is how often the status of a site is refreshed when nothing changes, so that devices
+	// going offline, which does not trigger any event, are accounted for
+	defaultSiteRefreshPeriod = time.Minute
+
+	// Reasons of the Resolved condition of Sites
+	siteResolvedReason       = "Resolved"
+	siteParentNotFoundReason = "ParentNotFound"
+	siteCycleReason          = "Cycle"
+	siteTooDeepReason        = "TooDeep"
+	siteNameTooLongReason    = "NameTooLong"
+)
+
+// SiteReconciler keeps the status of Sites up to date: their path in the site topology and the rollup of the
+// EdgeDevices located at them or below them, which the EdgeDeviceSiteReconciler labels with the site labels.
+type SiteReconciler struct {
+	SiteRepository       site.Repository
+	EdgeDeviceRepository edgedevice.Repository
+
+	// RefreshPeriod is how often the status of a site is refreshed when nothing changes; one minute when not set
+	RefreshPeriod           time.Duration
+	MaxConcurrentReconciles int
+}
+
+//+kubebuilder:rbac:groups=management.project-flotta.io,resources=sites,verbs=get;list;watch
+//+kubebuilder:rbac:groups=management.project-flotta.io,resources=sites/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=management.project-flotta.io,resources=edgedevices,verbs=get;list;watch
+
+func (r *SiteReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	s, err := r.SiteRepository.Read(ctx, req.Name, req.Namespace)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{Requeue: true}, err
+	}
+	if s.DeletionTimestamp != nil {
+		return ctrl.Result{}, nil
+	}
+
+	original := s.DeepCopy()
+	condition := metav1.Condition{
+		Type:               managementv1alpha1.SiteResolvedCondition,
+		Status:             metav1.ConditionTrue,
+		Reason:             siteResolvedReason,
+		Message:            "The parents of the site are resolved",
+		ObservedGeneration: s.Generation,
+	}
+	path, err := sitePath(ctx, r.SiteRepository, s)
+	if err != nil {
+		var topologyErr *siteTopologyError
+		if !goerrors.As(err, &topologyErr) {
+			return ctrl.Result{Requeue: true}, err
+		}
+		logger.V(1).Info("Site cannot be resolved", "reason", err.Error())
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = topologyErr.reason
+		condition.Message = topologyErr.message
+	}
+	s.Status.Path = path
+	meta.SetStatusCondition(&s.Status.Conditions, condition)
+
+	var devices []managementv1alpha1.EdgeDevice
+	// no device can be labelled with the site label of a name too long for a label
+	if condition.Reason != siteNameTooLongReason {
+		selector := metav1.LabelSelector{MatchLabels: map[string]string{flottalabels.SiteLabel(s.Name): "true"}}
+		devices, err = r.EdgeDeviceRepository.ListForSelector(ctx, &selector, s.Namespace)
+		if err != nil && !errors.IsNotFound(err) {
+			return ctrl.Result{Requeue: true}, err
+		}
+	}
+	rollUp(&s.Status, devices, time.Now())
+
+	if !reflect.DeepEqual(original.Status, s.Status) {
+		patch := client.MergeFrom(original)
+		err = r.SiteRepository.PatchStatus(ctx, s, &patch)
+		if err != nil {
+			return ctrl.Result{Requeue: true}, err
+		}
+	}
+	return ctrl.Result{RequeueAfter: r.refreshPeriod()}, nil
+}
+
+func (r *SiteReconciler) refreshPeriod() time.Duration {
+	if r.RefreshPeriod <= 0 {
+		return defaultSiteRefreshPeriod
+	}
+	return r.RefreshPeriod
+}
+
+// rollUp sets the number of devices, online devices and workloads by phase of the status
+func rollUp(status *managementv1alpha1.SiteStatus, devices []managementv1alpha1.EdgeDevice, now time.Time) {
+	status.Devices = int32(len(devices))
+	status.OnlineDevices = 0
+	status.WorkloadPhases = nil
+	for i := range devices {
+		if fleet.IsOnline(&devices[i], now) {
+			status.OnlineDevices++
+		}
+		for _, deployment := range devices[i].Status.Deployments {
+			if deployment.Phase == "" {
+				continue
+			}
+			if status.WorkloadPhases == nil {
+				status.WorkloadPhases = map[string]int32{}
+			}
+			status.WorkloadPhases[string(deployment.Phase)]++
+		}
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *SiteReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&managementv1alpha1.Site{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&source.Kind{Type: &managementv1alpha1.Site{}},
+			handler.EnqueueRequestsFromMapFunc(r.sitesBelow),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&source.Kind{Type: &managementv1alpha1.EdgeDevice{}},
+			handler.EnqueueRequestsFromMapFunc(sitesOfDevice),
+			builder.WithPredicates(siteRollupInputChanged())).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Complete(r)
+}
+
+// sitesBelow maps a Site to the Sites below it, whose path depends on it
+func (r *SiteReconciler) sitesBelow(obj client.Object) []reconcile.Request {
+	var requests []reconcile.Request
+	for _, name := range siteSubtree(context.Background(), r.SiteRepository, obj.GetName(), obj.GetNamespace())[1:] {
+		requests = append(requests, siteRequest(obj.GetNamespace(), name))
+	}
+	return requests
+}
+
+// sitesOfDevice maps an EdgeDevice to the Sites it is located at or below, according to its site labels
+func sitesOfDevice(obj client.Object) []reconcile.Request {
+	var requests []reconcile.Request
+	for label := range obj.GetLabels() {
+		if flottalabels.IsSiteLabel(label) {
+			requests = append(requests, siteRequest(obj.GetNamespace(), flottalabels.SiteOfLabel(label)))
+		}
+	}
+	return requests
+}
+
+func siteRequest(namespace, name string) reconcile.Request {
+	return reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: namespace}}
+}
+
+// siteRollupInputChanged filters the EdgeDevice updates that change the rollup of the sites of the device: its site
+// labels and the phases of its workloads
+func siteRollupInputChanged() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldDevice, ok := e.ObjectOld.(*managementv1alpha1.EdgeDevice)
+			if !ok {
+				return false
+			}
+			newDevice, ok := e.ObjectNew.(*managementv1alpha1.EdgeDevice)
+			if !ok {
+				return false
+			}
+			return !reflect.DeepEqual(siteLabels(oldDevice), siteLabels(newDevice)) ||
+				!reflect.DeepEqual(workloadPhases(oldDevice), workloadPhases(newDevice))
+		},
+	}
+}
+
+func workloadPhases(device *managementv1alpha1.EdgeDevice) map[string]managementv1alpha1.EdgeDeploymentPhase {
+	phases := map[string]managementv1alpha1.EdgeDeploymentPhase{}
+	for _, deployment := range device.Status.Deployments {
+		phases[deployment.Name] = deployment.Phase
+	}
+	return phases
+}
+
+// siteLabels returns the site labels of the device
+func siteLabels(device *managementv1alpha1.EdgeDevice) map[string]string {
+	labels := map[string]string{}
+	for label, value := range device.Labels {
+		if flottalabels.IsSiteLabel(label) {
+			labels[label] = value
+		}
+	}
+	return labels
+}
+
+// siteTopologyError tells why the path of a site cannot be resolved; reason is the one of the Resolved condition
+type siteTopologyError struct {
+	reason  string
+	message string
+}
+
+func (e *siteTopologyError) Error() string {
+	return e.message
+}
+
+// sitePath returns the names of the sites from the root of the topology down to the site
+func sitePath(ctx context.Context, repository site.Repository, s *managementv1alpha1.Site) ([]string, error) {
+	// the names are used in the site labels of the devices; sites with longer names may predate the webhook rejecting them
+	if len(s.Name) > managementv1alpha1.MaxSiteNameLength {
+		return nil, &siteTopologyError{
+			reason:  siteNameTooLongReason,
+			message: fmt.Sprintf("the site name is longer than %d characters", managementv1alpha1.MaxSiteNameLength),
+		}
+	}
+	path := []string{s.Name}
+	seen := map[string]bool{s.Name: true}
+	for parent := s.Spec.Parent; parent != ""; {
+		if len(parent) > managementv1alpha1.MaxSiteNameLength {
+			return nil, &siteTopologyError{
+				reason:  siteNameTooLongReason,
+				message: fmt.Sprintf("the name of parent Site %s is longer than %d characters", parent, managementv1alpha1.MaxSiteNameLength),
+			}
+		}
+		if seen[parent] {
+			return nil, &siteTopologyError{
+				reason:  siteCycleReason,
+				message: fmt.Sprintf("the parents of the site form a cycle through Site %s", parent),
+			}
+		}
+		if len(path) == maxSiteDepth {
+			return nil, &siteTopologyError{
+				reason:  siteTooDeepReason,
+				message: fmt.Sprintf("the site is more than %d levels deep", maxSiteDepth),
+			}
+		}
+		parentSite, err := repository.Read(ctx, parent, s.Namespace)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return nil, &siteTopologyError{
+					reason:  siteParentNotFoundReason,
+					message: fmt.Sprintf("parent Site %s not found", parent),
+				}
+			}
+			return nil, err
+		}
+		seen[parent] = true
+		path = append(path, parent)
+		parent = parentSite.Spec.Parent
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, nil
+}
+
+// siteSubtree returns the name of the site followed by the names of the sites below it
+func siteSubtree(ctx context.Context, repository site.Repository, name, namespace string) []string {
+	names := []string{name}
+	seen := map[string]bool{name: true}
+	for i := 0; i < len(names); i++ {
+		children, err := repository.ListChildren(ctx, names[i], namespace)
+		if err != nil {
+			log.Log.Error(err, "cannot list child Sites", "Name", names[i], "Namespace", namespace)
+			continue
+		}
+		for _, child := range children {
+			if !seen[child.Name] {
+				seen[child.Name] = true
+				names = append(names, child.Name)
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,363 @@
+package controllers_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"github.com/project-flotta/flotta-operator/controllers"
+	"github.com/project-flotta/flotta-operator/internal/repository/edgedevice"
+	"github.com/project-flotta/flotta-operator/internal/repository/site"
+)
+
+var _ = Describe("Site controllers", func() {
+	var (
+		mockCtrl           *gomock.Controller
+		siteRepoMock       *site.MockRepository
+		edgeDeviceRepoMock *edgedevice.MockRepository
+		sites              map[string]*v1alpha1.Site
+	)
+
+	newSite := func(name, parent string) *v1alpha1.Site {
+		s := &v1alpha1.Site{
+			ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "test", Generation: 1},
+			Spec:       v1alpha1.SiteSpec{Parent: parent},
+		}
+		sites[name] = s
+		return s
+	}
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		siteRepoMock = site.NewMockRepository(mockCtrl)
+		edgeDeviceRepoMock = edgedevice.NewMockRepository(mockCtrl)
+		sites = map[string]*v1alpha1.Site{}
+		siteRepoMock.EXPECT().Read(gomock.Any(), gomock.Any(), "test").
+			DoAndReturn(func(_ context.Context, name, _ string) (*v1alpha1.Site, error) {
+				if s, ok := sites[name]; ok {
+					return s.DeepCopy(), nil
+				}
+				return nil, errors.NewNotFound(schema.GroupResource{}, name)
+			}).AnyTimes()
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	Context("SiteReconciler", func() {
+		var (
+			reconciler *controllers.SiteReconciler
+			req        = ctrl.Request{NamespacedName: types.NamespacedName{Name: "madrid", Namespace: "test"}}
+		)
+
+		BeforeEach(func() {
+			reconciler = &controllers.SiteReconciler{
+				SiteRepository:       siteRepoMock,
+				EdgeDeviceRepository: edgeDeviceRepoMock,
+				RefreshPeriod:        30 * time.Second,
+			}
+		})
+
+		expectPatch := func() *v1alpha1.Site {
+			patched := &v1alpha1.Site{}
+			siteRepoMock.EXPECT().PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).
+				Do(func(_ context.Context, s *v1alpha1.Site, _ *client.Patch) {
+					s.DeepCopyInto(patched)
+				}).Return(nil)
+			return patched
+		}
+
+		It("should ignore missing sites", func() {
+			// when
+			res, err := reconciler.Reconcile(context.TODO(), req)
+
+			// then
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res).To(Equal(ctrl.Result{}))
+		})
+
+		It("should roll up the devices located at the site or below it", func() {
+			// given
+			newSite("emea", "")
+			newSite("madrid", "emea")
+			online := v1alpha1.EdgeDevice{
+				ObjectMeta: v1.ObjectMeta{Name: "online", Namespace: "test"},
+				Status: v1alpha1.EdgeDeviceStatus{
+					LastSeenTime: v1.Now(),
+					Deployments: []v1alpha1.Deployment{
+						{Name: "nginx", Phase: v1alpha1.Running},
+						{Name: "redis", Phase: v1alpha1.Deploying},
+					},
+				},
+			}
+			offline := v1alpha1.EdgeDevice{
+				ObjectMeta: v1.ObjectMeta{Name: "offline", Namespace: "test"},
+				Status: v1alpha1.EdgeDeviceStatus{
+					LastSeenTime: v1.NewTime(time.Now().Add(-time.Hour)),
+					Deployments:  []v1alpha1.Deployment{{Name: "nginx", Phase: v1alpha1.Running}},
+				},
+			}
+			edgeDeviceRepoMock.EXPECT().ListForSelector(gomock.Any(), &v1.LabelSelector{MatchLabels: map[string]string{"site/madrid": "true"}}, "test").
+				Return([]v1alpha1.EdgeDevice{online, offline}, nil)
+			patched := expectPatch()
+
+			// when
+			res, err := reconciler.Reconcile(context.TODO(), req)
+
+			// then
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.RequeueAfter).To(Equal(30 * time.Second))
+			Expect(patched.Status.Path).To(Equal([]string{"emea", "madrid"}))
+			Expect(patched.Status.Devices).To(Equal(int32(2)))
+			Expect(patched.Status.OnlineDevices).To(Equal(int32(1)))
+			Expect(patched.Status.WorkloadPhases).To(Equal(map[string]int32{"Running": 2, "Deploying": 1}))
+			resolved := meta.FindStatusCondition(patched.Status.Conditions, v1alpha1.SiteResolvedCondition)
+			Expect(resolved).NotTo(BeNil())
+			Expect(resolved.Status).To(Equal(v1.ConditionTrue))
+		})
+
+		It("should report a missing parent", func() {
+			// given
+			newSite("madrid", "emea")
+			edgeDeviceRepoMock.EXPECT().ListForSelector(gomock.Any(), gomock.Any(), "test").Return(nil, nil)
+			patched := expectPatch()
+
+			// when
+			_, err := reconciler.Reconcile(context.TODO(), req)
+
+			// then
+			Expect(err).NotTo(HaveOccurred())
+			Expect(patched.Status.Path).To(BeEmpty())
+			resolved := meta.FindStatusCondition(patched.Status.Conditions, v1alpha1.SiteResolvedCondition)
+			Expect(resolved.Status).To(Equal(v1.ConditionFalse))
+			Expect(resolved.Reason).To(Equal("ParentNotFound"))
+		})
+
+		It("should report a cycle of parents", func() {
+			// given
+			newSite("madrid", "emea")
+			newSite("emea", "madrid")
+			edgeDeviceRepoMock.EXPECT().ListForSelector(gomock.Any(), gomock.Any(), "test").Return(nil, nil)
+			patched := expectPatch()
+
+			// when
+			_, err := reconciler.Reconcile(context.TODO(), req)
+
+			// then
+			Expect(err).NotTo(HaveOccurred())
+			resolved := meta.FindStatusCondition(patched.Status.Conditions, v1alpha1.SiteResolvedCondition)
+			Expect(resolved.Status).To(Equal(v1.ConditionFalse))
+			Expect(resolved.Reason).To(Equal("Cycle"))
+		})
+
+		It("should report a site too deep in the topology", func() {
+			// given
+			parent := ""
+			for i := 0; i < 16; i++ {
+				name := fmt.Sprintf("level%d", i)
+				newSite(name, parent)
+				parent = name
+			}
+			newSite("madrid", parent)
+			edgeDeviceRepoMock.EXPECT().ListForSelector(gomock.Any(), gomock.Any(), "test").Return(nil, nil)
+			patched := expectPatch()
+
+			// when
+			_, err := reconciler.Reconcile(context.TODO(), req)
+
+			// then
+			Expect(err).NotTo(HaveOccurred())
+			resolved := meta.FindStatusCondition(patched.Status.Conditions, v1alpha1.SiteResolvedCondition)
+			Expect(resolved.Reason).To(Equal("TooDeep"))
+		})
+
+		It("should report a site name too long for the site labels", func() {
+			// given
+			name := strings.Repeat("m", 64)
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: "test"}}
+			newSite(name, "")
+			edgeDeviceRepoMock.EXPECT().ListForSelector(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			patched := expectPatch()
+
+			// when
+			_, err := reconciler.Reconcile(context.TODO(), req)
+
+			// then
+			Expect(err).NotTo(HaveOccurred())
+			Expect(patched.Status.Path).To(BeEmpty())
+			resolved := meta.FindStatusCondition(patched.Status.Conditions, v1alpha1.SiteResolvedCondition)
+			Expect(resolved.Status).To(Equal(v1.ConditionFalse))
+			Expect(resolved.Reason).To(Equal("NameTooLong"))
+		})
+
+		It("should not patch an unchanged status", func() {
+			// given
+			s := newSite("madrid", "")
+			s.Status = v1alpha1.SiteStatus{
+				Path: []string{"madrid"},
+				Conditions: []v1.Condition{{
+					Type:               v1alpha1.SiteResolvedCondition,
+					Status:             v1.ConditionTrue,
+					Reason:             "Resolved",
+					Message:            "The parents of the site are resolved",
+					ObservedGeneration: 1,
+				}},
+			}
+			edgeDeviceRepoMock.EXPECT().ListForSelector(gomock.Any(), gomock.Any(), "test").Return(nil, nil)
+
+			// when
+			_, err := reconciler.Reconcile(context.TODO(), req)
+
+			// then
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should retry when the devices cannot be listed", func() {
+			// given
+			newSite("madrid", "")
+			edgeDeviceRepoMock.EXPECT().ListForSelector(gomock.Any(), gomock.Any(), "test").Return(nil, fmt.Errorf("boom"))
+
+			// when
+			res, err := reconciler.Reconcile(context.TODO(), req)
+
+			// then
+			Expect(err).To(HaveOccurred())
+			Expect(res.Requeue).To(BeTrue())
+		})
+	})
+
+	Context("EdgeDeviceSiteReconciler", func() {
+		var (
+			reconciler *controllers.EdgeDeviceSiteReconciler
+			device     *v1alpha1.EdgeDevice
+			req        = ctrl.Request{NamespacedName: types.NamespacedName{Name: "device", Namespace: "test"}}
+		)
+
+		BeforeEach(func() {
+			reconciler = &controllers.EdgeDeviceSiteReconciler{
+				EdgeDeviceRepository: edgeDeviceRepoMock,
+				SiteRepository:       siteRepoMock,
+			}
+			device = &v1alpha1.EdgeDevice{
+				ObjectMeta: v1.ObjectMeta{Name: "device", Namespace: "test", Labels: map[string]string{"dc": "home"}},
+				Spec:       v1alpha1.EdgeDeviceSpec{Site: "madrid"},
+			}
+			edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), "device", "test").Return(device, nil)
+		})
+
+		expectPatch := func() *v1alpha1.EdgeDevice {
+			patched := &v1alpha1.EdgeDevice{}
+			edgeDeviceRepoMock.EXPECT().Patch(gomock.Any(), device, gomock.Any()).
+				Do(func(_ context.Context, _, new *v1alpha1.EdgeDevice) {
+					new.DeepCopyInto(patched)
+				}).Return(nil)
+			return patched
+		}
+
+		It("should label the device with the sites on the path of its site", func() {
+			// given
+			newSite("emea", "")
+			newSite("madrid", "emea")
+			patched := expectPatch()
+
+			// when
+			_, err := reconciler.Reconcile(context.TODO(), req)
+
+			// then
+			Expect(err).NotTo(HaveOccurred())
+			Expect(patched.Labels).To(Equal(map[string]string{
+				"dc":          "home",
+				"site/emea":   "true",
+				"site/madrid": "true",
+			}))
+		})
+
+		It("should replace the labels of the sites the device was moved from", func() {
+			// given
+			newSite("emea", "")
+			newSite("madrid", "emea")
+			device.Labels["site/amer"] = "true"
+			device.Labels["site/boston"] = "true"
+			device.Labels["site/emea"] = "true"
+			patched := expectPatch()
+
+			// when
+			_, err := reconciler.Reconcile(context.TODO(), req)
+
+			// then
+			Expect(err).NotTo(HaveOccurred())
+			Expect(patched.Labels).To(Equal(map[string]string{
+				"dc":          "home",
+				"site/emea":   "true",
+				"site/madrid": "true",
+			}))
+		})
+
+		It("should not patch a device already labelled", func() {
+			// given
+			newSite("madrid", "")
+			device.Labels["site/madrid"] = "true"
+
+			// when
+			_, err := reconciler.Reconcile(context.TODO(), req)
+
+			// then
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should remove the labels when the site does not exist", func() {
+			// given
+			device.Labels["site/madrid"] = "true"
+			patched := expectPatch()
+
+			// when
+			_, err := reconciler.Reconcile(context.TODO(), req)
+
+			// then
+			Expect(err).NotTo(HaveOccurred())
+			Expect(patched.Labels).To(Equal(map[string]string{"dc": "home"}))
+		})
+
+		It("should remove the labels when the device is not located at a site", func() {
+			// given
+			device.Spec.Site = ""
+			device.Labels["site/madrid"] = "true"
+			patched := expectPatch()
+
+			// when
+			_, err := reconciler.Reconcile(context.TODO(), req)
+
+			// then
+			Expect(err).NotTo(HaveOccurred())
+			Expect(patched.Labels).To(Equal(map[string]string{"dc": "home"}))
+		})
+
+		It("should keep the labels when a parent of the site does not exist", func() {
+			// given
+			newSite("madrid", "emea")
+			device.Labels["site/emea"] = "true"
+			device.Labels["site/madrid"] = "true"
+
+			// when
+			res, err := reconciler.Reconcile(context.TODO(), req)
+
+			// then
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res).To(Equal(ctrl.Result{}))
+		})
+	})
+})
@@ -0,0 +1,171 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"github.com/project-flotta/flotta-operator/internal/yggdrasil"
+	"github.com/project-flotta/flotta-operator/models"
+)
+
+const (
+	// ConfigurationSubresource is the subresource of EdgeDevices users are authorized on to render their configuration
+	ConfigurationSubresource = "configuration"
+
+	edgeDevicesResource = "edgedevices"
+)
+
+var configurationPath = regexp.MustCompile(`^/api/flotta-management/v1/admin/namespaces/([^/]+)/edgedevices/([^/]+)/configuration$`)
+
+// ConfigurationRenderer renders the configuration delivered to a device
+type ConfigurationRenderer interface {
+	RenderConfiguration(ctx context.Context, name string, namespace string) (*models.DeviceConfigurationMessage, []yggdrasil.ResolutionError, error)
+}
+
+// Reviewer authenticates and authorizes the users of the admin API
+type Reviewer interface {
+	// Authenticate returns the user of the token, nil if the token is not valid
+	Authenticate(ctx context.Context, token string) (*authenticationv1.UserInfo, error)
+
+	// Authorize tells whether the user is allowed to get the subresource of the EdgeDevice
+	Authorize(ctx context.Context, user *authenticationv1.UserInfo, namespace, name, subresource string) (bool, error)
+}
+
+// RenderedConfiguration is the response of the configuration endpoint
+type RenderedConfiguration struct {
+	// Configuration the device receives, with its secret values redacted
+	Configuration *models.DeviceConfigurationMessage `json:"configuration"`
+
+	// Errors are the items of the configuration that cannot be resolved and are left out of the configuration. The
+	// device receives an error instead of the configuration until they are fixed.
+	Errors []yggdrasil.ResolutionError `json:"errors"`
+}
+
+// Handler serves the admin API. Its users are authenticated with their Kubernetes bearer token and authorized with
+// the Kubernetes RBAC, not with device client certificates.
+type Handler struct {
+	renderer ConfigurationRenderer
+	reviewer Reviewer
+}
+
+func NewHandler(renderer ConfigurationRenderer, reviewer Reviewer) *Handler {
+	return &Handler{renderer: renderer, reviewer: reviewer}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	match := configurationPath.FindStringSubmatch(r.URL.Path)
+	if match == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	namespace, name := match[1], match[2]
+	logger := log.FromContext(r.Context(), "namespace", namespace, "name", name)
+
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	user, err := h.reviewer.Authenticate(r.Context(), strings.TrimPrefix(authorization, "Bearer "))
+	if err != nil {
+		logger.Error(err, "cannot authenticate user")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	allowed, err := h.reviewer.Authorize(r.Context(), user, namespace, name, ConfigurationSubresource)
+	if err != nil {
+		logger.Error(err, "cannot authorize user", "user", user.Username)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !allowed {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	dc, resolutionErrors, err := h.renderer.RenderConfiguration(r.Context(), name, namespace)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		logger.Error(err, "cannot render configuration")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	yggdrasil.Redact(dc)
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(RenderedConfiguration{Configuration: dc, Errors: resolutionErrors})
+	if err != nil {
+		logger.Error(err, "cannot write configuration")
+	}
+}
+
+//+kubebuilder:rbac:groups=authentication.k8s.io,resources=tokenreviews,verbs=create
+//+kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+// KubernetesReviewer authenticates with TokenReviews and authorizes with SubjectAccessReviews
+type KubernetesReviewer struct {
+	client client.Client
+}
+
+func NewKubernetesReviewer(client client.Client) *KubernetesReviewer {
+	return &KubernetesReviewer{client: client}
+}
+
+func (k *KubernetesReviewer) Authenticate(ctx context.Context, token string) (*authenticationv1.UserInfo, error) {
+	review := &authenticationv1.TokenReview{Spec: authenticationv1.TokenReviewSpec{Token: token}}
+	if err := k.client.Create(ctx, review); err != nil {
+		return nil, err
+	}
+	if !review.Status.Authenticated {
+		return nil, nil
+	}
+	return &review.Status.User, nil
+}
+
+func (k *KubernetesReviewer) Authorize(ctx context.Context, user *authenticationv1.UserInfo, namespace, name, subresource string) (bool, error) {
+	extra := map[string]authorizationv1.ExtraValue{}
+	for key, value := range user.Extra {
+		extra[key] = authorizationv1.ExtraValue(value)
+	}
+	review := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace:   namespace,
+				Verb:        "get",
+				Group:       v1alpha1.GroupVersion.Group,
+				Resource:    edgeDevicesResource,
+				Subresource: subresource,
+				Name:        name,
+			},
+			User:   user.Username,
+			Groups: user.Groups,
+			UID:    user.UID,
+			Extra:  extra,
+		},
+	}
+	if err := k.client.Create(ctx, review); err != nil {
+		return false, err
+	}
+	return review.Status.Allowed, nil
+}
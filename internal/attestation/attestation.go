@@ -0,0 +1,342 @@
+package attestation
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	_ "github.com/golang/mock/mockgen/model"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"github.com/project-flotta/flotta-operator/internal/k8sclient"
+	"github.com/project-flotta/flotta-operator/models"
+)
+
+const (
+	// CABundleKey is the key of the policy ConfigMap holding the PEM encoded certificates of the TPM manufacturer CAs
+	CABundleKey = "ca-bundle.pem"
+
+	// PCRsKey is the key of the policy ConfigMap holding the expected PCR values: a YAML map of SHA-256 PCR indexes to
+	// the lists of their allowed hex encoded values
+	PCRsKey = "pcrs.yaml"
+
+	// CredentialKeySecretName is the Secret of the operator namespace holding the key deriving the credentials of the
+	// challenges. The key is shared by the replicas of the operator, so that a challenge issued by one of them can be
+	// answered to another one, or after a restart.
+	CredentialKeySecretName = "flotta-attestation-key"
+
+	credentialKeySecretKey = "key"
+	credentialKeySize      = 32
+)
+
+// ErrInvalidAttestation is returned when the attestation of a device cannot be verified
+var ErrInvalidAttestation = errors.New("invalid attestation")
+
+//go:generate mockgen -package=attestation -destination=mock_attestation.go . Verifier
+type Verifier interface {
+	// Verify verifies the TPM attestation of a registering device and binds it to the key of the certificate request.
+	// Until the device proves that its attestation key is resident in the TPM of its endorsement key, it returns the
+	// challenge the device has to answer with TPM2_ActivateCredential.
+	Verify(ctx context.Context, deviceID string, certificateRequest string, attestation *models.Attestation) (*v1alpha1.DeviceAttestation, *models.AttestationChallenge, error)
+}
+
+// Policy is what attested devices are expected to be
+type Policy struct {
+	// Roots are the TPM manufacturer CAs endorsement key certificates are issued by
+	Roots *x509.CertPool
+
+	// PCRs are the allowed values of SHA-256 PCRs, by index; PCRs without values are not verified
+	PCRs map[int][][]byte
+}
+
+// TPMVerifier verifies attestations with the Policy read from a ConfigMap, so that the policy can be updated, e.g.
+// when a firmware update changes the expected PCR values, without restarting the operator
+type TPMVerifier struct {
+	client    k8sclient.K8sClient
+	configMap client.ObjectKey
+	// key derives the credentials of the challenges, so that they do not have to be stored between the registration
+	// requests of a device
+	key []byte
+}
+
+func NewTPMVerifier(k8sClient k8sclient.K8sClient, namespace, configMapName string, key []byte) *TPMVerifier {
+	return &TPMVerifier{
+		client:    k8sClient,
+		configMap: client.ObjectKey{Namespace: namespace, Name: configMapName},
+		key:       key,
+	}
+}
+
+// SecretClient reads and creates the Secret of the credential key
+type SecretClient interface {
+	Get(ctx context.Context, key client.ObjectKey, obj client.Object) error
+	Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error
+}
+
+// CredentialKey returns the key of the CredentialKeySecretName Secret of the namespace, creating the Secret with a
+// random key when it does not exist
+func CredentialKey(ctx context.Context, c SecretClient, namespace string) ([]byte, error) {
+	secretKey := client.ObjectKey{Namespace: namespace, Name: CredentialKeySecretName}
+	secret := corev1.Secret{}
+	err := c.Get(ctx, secretKey, &secret)
+	if apierrors.IsNotFound(err) {
+		key := make([]byte, credentialKeySize)
+		if _, err = rand.Read(key); err != nil {
+			return nil, err
+		}
+		secret = corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: CredentialKeySecretName},
+			Data:       map[string][]byte{credentialKeySecretKey: key},
+		}
+		err = c.Create(ctx, &secret)
+		if err == nil {
+			return key, nil
+		}
+		if apierrors.IsAlreadyExists(err) {
+			// another replica created it first
+			secret = corev1.Secret{}
+			err = c.Get(ctx, secretKey, &secret)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	key := secret.Data[credentialKeySecretKey]
+	if len(key) < credentialKeySize {
+		return nil, fmt.Errorf("secret %s has no %d bytes %s key", secretKey, credentialKeySize, credentialKeySecretKey)
+	}
+	return key, nil
+}
+
+func (v *TPMVerifier) Verify(ctx context.Context, deviceID string, certificateRequest string, attestation *models.Attestation) (*v1alpha1.DeviceAttestation, *models.AttestationChallenge, error) {
+	policy, err := v.policy(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ekCert, err := verifyEndorsementKey(attestation.EkCertificate, policy.Roots)
+	if err != nil {
+		return nil, nil, invalid("endorsement key certificate: %v", err)
+	}
+	akPublic, err := decode(attestation.AttestationKey)
+	if err != nil {
+		return nil, nil, invalid("attestation key: %v", err)
+	}
+	ak, err := ParsePublic(akPublic)
+	if err != nil {
+		return nil, nil, invalid("attestation key: %v", err)
+	}
+	if ak.Attributes&attestationKeyAttrs != attestationKeyAttrs {
+		return nil, nil, invalid("attestation key is not a restricted signing key resident in the TPM")
+	}
+
+	credential := v.credential(deviceID, ekCert, ak)
+	if attestation.ActivatedCredential == "" {
+		credentialBlob, encryptedSecret, err := MakeCredential(ekCert.PublicKey, ak.Name, credential)
+		if err != nil {
+			return nil, nil, invalid("cannot make credential: %v", err)
+		}
+		return nil, &models.AttestationChallenge{
+			CredentialBlob:  base64.StdEncoding.EncodeToString(credentialBlob),
+			EncryptedSecret: base64.StdEncoding.EncodeToString(encryptedSecret),
+		}, nil
+	}
+	activated, err := decode(attestation.ActivatedCredential)
+	if err != nil || subtle.ConstantTimeCompare(activated, credential) != 1 {
+		return nil, nil, invalid("the activated credential does not match the challenge")
+	}
+
+	csr, err := parseCertificateRequest(certificateRequest)
+	if err != nil {
+		return nil, nil, invalid("certificate request: %v", err)
+	}
+	quoted, err := decode(attestation.Quote)
+	if err != nil {
+		return nil, nil, invalid("quote: %v", err)
+	}
+	signature, err := decode(attestation.QuoteSignature)
+	if err != nil {
+		return nil, nil, invalid("quote signature: %v", err)
+	}
+	if err = VerifySignature(ak.Key, quoted, signature); err != nil {
+		return nil, nil, invalid("quote signature: %v", err)
+	}
+	quote, err := ParseQuote(quoted)
+	if err != nil {
+		return nil, nil, invalid("quote: %v", err)
+	}
+	if !bytes.Equal(quote.ExtraData, Nonce(credential, csr)) {
+		return nil, nil, invalid("the quote is not bound to the certificate request")
+	}
+	pcrs, err := verifyPCRs(quote, attestation.Pcrs, policy.PCRs)
+	if err != nil {
+		return nil, nil, invalid("PCRs: %v", err)
+	}
+
+	ekDigest := sha256.Sum256(ekCert.Raw)
+	return &v1alpha1.DeviceAttestation{
+		Time:                        metav1.NewTime(time.Now()),
+		EndorsementKeyCertificate:   hex.EncodeToString(ekDigest[:]),
+		EndorsementKeyCertificateCA: ekCert.Issuer.String(),
+		AttestationKeyName:          hex.EncodeToString(ak.Name),
+		PCRs:                        pcrs,
+	}, nil, nil
+}
+
+// Nonce is the extra data of the quote of a device: it binds the attestation to the credential of the challenge and to
+// the key the client certificate of the device is issued for
+func Nonce(credential []byte, csr *x509.CertificateRequest) []byte {
+	h := sha256.New()
+	h.Write(credential)
+	h.Write(csr.RawSubjectPublicKeyInfo)
+	return h.Sum(nil)
+}
+
+func (v *TPMVerifier) credential(deviceID string, ekCert *x509.Certificate, ak *Public) []byte {
+	mac := hmac.New(sha256.New, v.key)
+	mac.Write([]byte(deviceID))
+	mac.Write([]byte{0})
+	mac.Write(ekCert.Raw)
+	mac.Write(ak.Name)
+	return mac.Sum(nil)[:seedSize]
+}
+
+func (v *TPMVerifier) policy(ctx context.Context) (*Policy, error) {
+	configMap := corev1.ConfigMap{}
+	if err := v.client.Get(ctx, v.configMap, &configMap); err != nil {
+		return nil, fmt.Errorf("cannot read attestation policy ConfigMap %s: %w", v.configMap, err)
+	}
+	return ParsePolicy(configMap.Data)
+}
+
+// ParsePolicy parses the data of a policy ConfigMap
+func ParsePolicy(data map[string]string) (*Policy, error) {
+	policy := &Policy{Roots: x509.NewCertPool(), PCRs: map[int][][]byte{}}
+	if !policy.Roots.AppendCertsFromPEM([]byte(data[CABundleKey])) {
+		return nil, fmt.Errorf("the attestation policy has no manufacturer CA certificate")
+	}
+	var pcrs map[string][]string
+	if err := yaml.Unmarshal([]byte(data[PCRsKey]), &pcrs); err != nil {
+		return nil, fmt.Errorf("cannot parse the PCRs of the attestation policy: %w", err)
+	}
+	for index, values := range pcrs {
+		i, err := strconv.Atoi(index)
+		if err != nil || i < 0 || i > 23 {
+			return nil, fmt.Errorf("invalid PCR index %s", index)
+		}
+		for _, value := range values {
+			digest, err := hex.DecodeString(value)
+			if err != nil || len(digest) != sha256.Size {
+				return nil, fmt.Errorf("invalid value of PCR %d: %s", i, value)
+			}
+			policy.PCRs[i] = append(policy.PCRs[i], digest)
+		}
+	}
+	return policy, nil
+}
+
+// verifyEndorsementKey parses the endorsement key certificate, followed by its intermediate CA certificates, and
+// verifies it is issued by a manufacturer CA
+func verifyEndorsementKey(certificates string, roots *x509.CertPool) (*x509.Certificate, error) {
+	var chain []*x509.Certificate
+	rest := []byte(certificates)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, cert)
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("no certificate")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := chain[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return chain[0], nil
+}
+
+// verifyPCRs checks that the values reported by the device are the ones quoted and allowed by the policy, and
+// returns the quoted values by index
+func verifyPCRs(quote *Quote, reported map[string]string, allowed map[int][][]byte) (map[string]string, error) {
+	quoted := map[string]string{}
+	h := sha256.New()
+	for _, index := range quote.PCRs {
+		key := strconv.Itoa(index)
+		value, err := hex.DecodeString(reported[key])
+		if err != nil || len(value) != sha256.Size {
+			return nil, fmt.Errorf("no valid value reported for PCR %d", index)
+		}
+		h.Write(value)
+		quoted[key] = reported[key]
+	}
+	if !bytes.Equal(h.Sum(nil), quote.PCRDigest) {
+		return nil, fmt.Errorf("the reported values do not match the quoted digest")
+	}
+	for index, values := range allowed {
+		value, ok := quoted[strconv.Itoa(index)]
+		if !ok {
+			return nil, fmt.Errorf("PCR %d is not quoted", index)
+		}
+		digest, _ := hex.DecodeString(value)
+		if !containsDigest(values, digest) {
+			return nil, fmt.Errorf("PCR %d has an unexpected value %s", index, value)
+		}
+	}
+	return quoted, nil
+}
+
+func containsDigest(digests [][]byte, digest []byte) bool {
+	for _, d := range digests {
+		if bytes.Equal(d, digest) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseCertificateRequest(certificateRequest string) (*x509.CertificateRequest, error) {
+	block, _ := pem.Decode([]byte(certificateRequest))
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data")
+	}
+	return x509.ParseCertificateRequest(block.Bytes)
+}
+
+func decode(value string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(value)
+}
+
+func invalid(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidAttestation, fmt.Sprintf(format, args...))
+}
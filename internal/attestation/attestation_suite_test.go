@@ -0,0 +1,13 @@
+package attestation_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestAttestation(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Attestation Suite")
+}
@@ -0,0 +1,653 @@
+package attestation_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"hash"
+	"math/big"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/project-flotta/flotta-operator/internal/attestation"
+	"github.com/project-flotta/flotta-operator/internal/k8sclient"
+	"github.com/project-flotta/flotta-operator/models"
+)
+
+const (
+	deviceID      = "device-1"
+	namespace     = "flotta"
+	configMapName = "flotta-attestation-policy"
+
+	attestationKeyAttrs = 0x00000002 | 0x00000010 | 0x00000020 | 0x00010000 | 0x00040000
+)
+
+var _ = Describe("TPM attestation", func() {
+	var (
+		mockCtrl      *gomock.Controller
+		k8sClientMock *k8sclient.MockK8sClient
+		verifier      *attestation.TPMVerifier
+		ca            *testCA
+		tpm           *softwareTPM
+		policy        map[string]string
+		csr           string
+		pcrs          map[int][]byte
+		credentialKey []byte
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		k8sClientMock = k8sclient.NewMockK8sClient(mockCtrl)
+		credentialKey = make([]byte, 32)
+		_, err := rand.Read(credentialKey)
+		Expect(err).ToNot(HaveOccurred())
+		verifier = attestation.NewTPMVerifier(k8sClientMock, namespace, configMapName, credentialKey)
+
+		ca = newTestCA()
+		tpm = newSoftwareTPM(ca, rsaKey())
+		csr = certificateRequest()
+		pcrs = map[int][]byte{0: digest("firmware"), 7: digest("secure boot")}
+		policy = map[string]string{
+			attestation.CABundleKey: ca.pem,
+			attestation.PCRsKey:     fmt.Sprintf("\"0\":\n- %x\n\"7\":\n- %x\n- %x\n", pcrs[0], digest("other"), pcrs[7]),
+		}
+		k8sClientMock.EXPECT().Get(gomock.Any(), types.NamespacedName{Namespace: namespace, Name: configMapName}, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+				obj.(*corev1.ConfigMap).Data = policy
+				return nil
+			}).AnyTimes()
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	// attest goes through the two steps of the registration of a device
+	attest := func() (*models.Attestation, error) {
+		info := tpm.attestation()
+		_, challenge, err := verifier.Verify(context.TODO(), deviceID, csr, info)
+		if err != nil {
+			return nil, err
+		}
+		Expect(challenge).ToNot(BeNil())
+		credential, err := tpm.activateCredential(challenge)
+		if err != nil {
+			return nil, err
+		}
+		tpm.quote(info, credential, csr, pcrs)
+		return info, nil
+	}
+
+	table.DescribeTable("should verify the attestation of a device", func(newKey func() crypto.Signer) {
+		// given
+		tpm = newSoftwareTPM(ca, newKey())
+		info, err := attest()
+		Expect(err).ToNot(HaveOccurred())
+
+		// when
+		deviceAttestation, challenge, err := verifier.Verify(context.TODO(), deviceID, csr, info)
+
+		// then
+		Expect(err).ToNot(HaveOccurred())
+		Expect(challenge).To(BeNil())
+		fingerprint := sha256.Sum256(tpm.ekCert.Raw)
+		Expect(deviceAttestation.EndorsementKeyCertificate).To(Equal(hex.EncodeToString(fingerprint[:])))
+		Expect(deviceAttestation.EndorsementKeyCertificateCA).To(Equal("CN=TPM Manufacturer CA"))
+		Expect(deviceAttestation.AttestationKeyName).To(Equal(hex.EncodeToString(tpm.akName())))
+		Expect(deviceAttestation.PCRs).To(Equal(map[string]string{
+			"0": hex.EncodeToString(pcrs[0]),
+			"7": hex.EncodeToString(pcrs[7]),
+		}))
+	},
+		table.Entry("RSA endorsement key", rsaKey),
+		table.Entry("ECC endorsement key", eccKey),
+	)
+
+	It("should not issue challenges for endorsement keys of unknown manufacturers", func() {
+		// given
+		tpm = newSoftwareTPM(newTestCA(), rsaKey())
+
+		// when
+		_, _, err := verifier.Verify(context.TODO(), deviceID, csr, tpm.attestation())
+
+		// then
+		Expect(err).To(MatchError(attestation.ErrInvalidAttestation))
+	})
+
+	It("should not issue challenges for attestation keys that are not restricted", func() {
+		// given
+		tpm.akAttributes = attestationKeyAttrs &^ 0x00010000
+
+		// when
+		_, _, err := verifier.Verify(context.TODO(), deviceID, csr, tpm.attestation())
+
+		// then
+		Expect(err).To(MatchError(attestation.ErrInvalidAttestation))
+	})
+
+	It("should not activate the credential with another endorsement key", func() {
+		// given
+		info := tpm.attestation()
+		_, challenge, err := verifier.Verify(context.TODO(), deviceID, csr, info)
+		Expect(err).ToNot(HaveOccurred())
+		tpm.ek = rsaKey()
+
+		// when
+		_, err = tpm.activateCredential(challenge)
+
+		// then
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should not activate the credential with another attestation key", func() {
+		// given
+		info := tpm.attestation()
+		_, challenge, err := verifier.Verify(context.TODO(), deviceID, csr, info)
+		Expect(err).ToNot(HaveOccurred())
+		tpm.ak = eccKey().(*ecdsa.PrivateKey)
+
+		// when
+		_, err = tpm.activateCredential(challenge)
+
+		// then
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should reject wrong credentials", func() {
+		// given
+		info, err := attest()
+		Expect(err).ToNot(HaveOccurred())
+		info.ActivatedCredential = base64.StdEncoding.EncodeToString(make([]byte, 16))
+
+		// when
+		_, _, err = verifier.Verify(context.TODO(), deviceID, csr, info)
+
+		// then
+		Expect(err).To(MatchError(attestation.ErrInvalidAttestation))
+	})
+
+	It("should verify credentials of challenges issued by another verifier with the same key", func() {
+		// given
+		info, err := attest()
+		Expect(err).ToNot(HaveOccurred())
+		verifier = attestation.NewTPMVerifier(k8sClientMock, namespace, configMapName, credentialKey)
+
+		// when
+		deviceAttestation, challenge, err := verifier.Verify(context.TODO(), deviceID, csr, info)
+
+		// then
+		Expect(err).ToNot(HaveOccurred())
+		Expect(challenge).To(BeNil())
+		Expect(deviceAttestation).ToNot(BeNil())
+	})
+
+	It("should reject credentials of challenges issued by a verifier with another key", func() {
+		// given
+		info, err := attest()
+		Expect(err).ToNot(HaveOccurred())
+		verifier = attestation.NewTPMVerifier(k8sClientMock, namespace, configMapName, make([]byte, 32))
+
+		// when
+		_, _, err = verifier.Verify(context.TODO(), deviceID, csr, info)
+
+		// then
+		Expect(err).To(MatchError(attestation.ErrInvalidAttestation))
+	})
+
+	It("should reject credentials activated for another device", func() {
+		// given
+		info, err := attest()
+		Expect(err).ToNot(HaveOccurred())
+
+		// when
+		_, _, err = verifier.Verify(context.TODO(), "device-2", csr, info)
+
+		// then
+		Expect(err).To(MatchError(attestation.ErrInvalidAttestation))
+	})
+
+	It("should reject quotes bound to another certificate request", func() {
+		// given
+		info, err := attest()
+		Expect(err).ToNot(HaveOccurred())
+
+		// when
+		_, _, err = verifier.Verify(context.TODO(), deviceID, certificateRequest(), info)
+
+		// then
+		Expect(err).To(MatchError(attestation.ErrInvalidAttestation))
+	})
+
+	It("should reject quotes not signed by the attestation key", func() {
+		// given
+		info, err := attest()
+		Expect(err).ToNot(HaveOccurred())
+		quoted, _ := base64.StdEncoding.DecodeString(info.Quote)
+		info.QuoteSignature = base64.StdEncoding.EncodeToString(signECDSA(eccKey().(*ecdsa.PrivateKey), quoted))
+
+		// when
+		_, _, err = verifier.Verify(context.TODO(), deviceID, csr, info)
+
+		// then
+		Expect(err).To(MatchError(attestation.ErrInvalidAttestation))
+	})
+
+	It("should reject PCR values that were not quoted", func() {
+		// given
+		info, err := attest()
+		Expect(err).ToNot(HaveOccurred())
+		info.Pcrs["7"] = hex.EncodeToString(digest("other"))
+
+		// when
+		_, _, err = verifier.Verify(context.TODO(), deviceID, csr, info)
+
+		// then
+		Expect(err).To(MatchError(attestation.ErrInvalidAttestation))
+	})
+
+	It("should reject PCR values not allowed by the policy", func() {
+		// given
+		pcrs[0] = digest("tampered firmware")
+		info, err := attest()
+		Expect(err).ToNot(HaveOccurred())
+
+		// when
+		_, _, err = verifier.Verify(context.TODO(), deviceID, csr, info)
+
+		// then
+		Expect(err).To(MatchError(attestation.ErrInvalidAttestation))
+	})
+
+	It("should reject quotes missing PCRs of the policy", func() {
+		// given
+		delete(pcrs, 7)
+		info, err := attest()
+		Expect(err).ToNot(HaveOccurred())
+
+		// when
+		_, _, err = verifier.Verify(context.TODO(), deviceID, csr, info)
+
+		// then
+		Expect(err).To(MatchError(attestation.ErrInvalidAttestation))
+	})
+
+	It("should fail without policy", func() {
+		// given
+		k8sClientMock = k8sclient.NewMockK8sClient(mockCtrl)
+		k8sClientMock.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(errors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, configMapName))
+		verifier = attestation.NewTPMVerifier(k8sClientMock, namespace, configMapName, credentialKey)
+
+		// when
+		_, _, err := verifier.Verify(context.TODO(), deviceID, csr, tpm.attestation())
+
+		// then
+		Expect(err).To(HaveOccurred())
+		Expect(err).ToNot(MatchError(attestation.ErrInvalidAttestation))
+	})
+
+	table.DescribeTable("should reject invalid policies", func(data map[string]string) {
+		// when
+		_, err := attestation.ParsePolicy(data)
+
+		// then
+		Expect(err).To(HaveOccurred())
+	},
+		table.Entry("no CA", map[string]string{}),
+		table.Entry("invalid PCR index", map[string]string{
+			attestation.CABundleKey: newTestCA().pem,
+			attestation.PCRsKey:     "\"24\":\n- " + hex.EncodeToString(digest("firmware")),
+		}),
+		table.Entry("invalid PCR value", map[string]string{
+			attestation.CABundleKey: newTestCA().pem,
+			attestation.PCRsKey:     "\"0\":\n- 00",
+		}),
+	)
+})
+
+var _ = Describe("Credential key", func() {
+	var secrets *secretClientStub
+
+	BeforeEach(func() {
+		secrets = &secretClientStub{secrets: map[client.ObjectKey]*corev1.Secret{}}
+	})
+
+	It("should create the secret of the key", func() {
+		// when
+		key, err := attestation.CredentialKey(context.TODO(), secrets, namespace)
+
+		// then
+		Expect(err).ToNot(HaveOccurred())
+		Expect(key).To(HaveLen(32))
+		secret := secrets.secrets[client.ObjectKey{Namespace: namespace, Name: attestation.CredentialKeySecretName}]
+		Expect(secret).ToNot(BeNil())
+		Expect(secret.Data).To(HaveKeyWithValue("key", key))
+	})
+
+	It("should return the key of the secret", func() {
+		// given
+		key, err := attestation.CredentialKey(context.TODO(), secrets, namespace)
+		Expect(err).ToNot(HaveOccurred())
+
+		// when
+		sameKey, err := attestation.CredentialKey(context.TODO(), secrets, namespace)
+
+		// then
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sameKey).To(Equal(key))
+	})
+
+	It("should return the key of a secret created concurrently", func() {
+		// given
+		key := make([]byte, 32)
+		secrets.createdConcurrently = &corev1.Secret{Data: map[string][]byte{"key": key}}
+
+		// when
+		concurrentKey, err := attestation.CredentialKey(context.TODO(), secrets, namespace)
+
+		// then
+		Expect(err).ToNot(HaveOccurred())
+		Expect(concurrentKey).To(Equal(key))
+	})
+
+	It("should fail for secrets without a valid key", func() {
+		// given
+		secrets.secrets[client.ObjectKey{Namespace: namespace, Name: attestation.CredentialKeySecretName}] =
+			&corev1.Secret{Data: map[string][]byte{"key": []byte("short")}}
+
+		// when
+		_, err := attestation.CredentialKey(context.TODO(), secrets, namespace)
+
+		// then
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+// secretClientStub keeps secrets in memory
+type secretClientStub struct {
+	secrets map[client.ObjectKey]*corev1.Secret
+	// createdConcurrently is created by another client when the secret is created
+	createdConcurrently *corev1.Secret
+}
+
+func (c *secretClientStub) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	secret, ok := c.secrets[key]
+	if !ok {
+		return errors.NewNotFound(schema.GroupResource{Resource: "secrets"}, key.Name)
+	}
+	secret.DeepCopyInto(obj.(*corev1.Secret))
+	return nil
+}
+
+func (c *secretClientStub) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	key := client.ObjectKeyFromObject(obj)
+	if c.createdConcurrently != nil {
+		c.secrets[key] = c.createdConcurrently
+		c.createdConcurrently = nil
+	}
+	if _, ok := c.secrets[key]; ok {
+		return errors.NewAlreadyExists(schema.GroupResource{Resource: "secrets"}, key.Name)
+	}
+	c.secrets[key] = obj.(*corev1.Secret).DeepCopy()
+	return nil
+}
+
+type testCA struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+	pem  string
+}
+
+func newTestCA() *testCA {
+	key := eccKey()
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "TPM Manufacturer CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	Expect(err).ToNot(HaveOccurred())
+	cert, err := x509.ParseCertificate(der)
+	Expect(err).ToNot(HaveOccurred())
+	return &testCA{
+		cert: cert,
+		key:  key,
+		pem:  string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+	}
+}
+
+// softwareTPM implements the TPM operations of a registering device: it holds the endorsement key, with its
+// certificate issued by the CA, and an attestation key
+type softwareTPM struct {
+	ek           crypto.Signer
+	ekCert       *x509.Certificate
+	ak           *ecdsa.PrivateKey
+	akAttributes uint32
+}
+
+func newSoftwareTPM(ca *testCA, ek crypto.Signer) *softwareTPM {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "EK"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageKeyEncipherment,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, ek.Public(), ca.key)
+	Expect(err).ToNot(HaveOccurred())
+	ekCert, err := x509.ParseCertificate(der)
+	Expect(err).ToNot(HaveOccurred())
+	return &softwareTPM{
+		ek:           ek,
+		ekCert:       ekCert,
+		ak:           eccKey().(*ecdsa.PrivateKey),
+		akAttributes: attestationKeyAttrs,
+	}
+}
+
+func (t *softwareTPM) attestation() *models.Attestation {
+	return &models.Attestation{
+		EkCertificate:  string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: t.ekCert.Raw})),
+		AttestationKey: base64.StdEncoding.EncodeToString(t.akPublic()),
+	}
+}
+
+// akPublic is the TPMT_PUBLIC of the ECDSA P-256 attestation key
+func (t *softwareTPM) akPublic() []byte {
+	var out []byte
+	out = appendUint16(out, 0x0023, 0x000b)
+	out = appendUint32(out, t.akAttributes)
+	out = append(out, sized(nil)...)                        // authPolicy
+	out = appendUint16(out, 0x0010, 0x0018, 0x000b, 0x0003) // symmetric, scheme, curve
+	out = appendUint16(out, 0x0010)                         // kdf
+	out = append(out, sized(t.ak.X.FillBytes(make([]byte, 32)))...)
+	return append(out, sized(t.ak.Y.FillBytes(make([]byte, 32)))...)
+}
+
+func (t *softwareTPM) akName() []byte {
+	digest := sha256.Sum256(t.akPublic())
+	return append([]byte{0x00, 0x0b}, digest[:]...)
+}
+
+// activateCredential is TPM2_ActivateCredential, see TPM 2.0 Part 1, section 24
+func (t *softwareTPM) activateCredential(challenge *models.AttestationChallenge) ([]byte, error) {
+	idObject := unsized(decode(challenge.CredentialBlob))
+	encryptedSecret := unsized(decode(challenge.EncryptedSecret))
+
+	var seed []byte
+	switch ek := t.ek.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		seed, err = rsa.DecryptOAEP(sha256.New(), nil, ek, encryptedSecret, []byte("IDENTITY\x00"))
+		if err != nil {
+			return nil, err
+		}
+	case *ecdsa.PrivateKey:
+		x := unsized(encryptedSecret)
+		y := unsized(encryptedSecret[2+len(x):])
+		z, _ := ek.Curve.ScalarMult(new(big.Int).SetBytes(x), new(big.Int).SetBytes(y), ek.D.Bytes())
+		seed = kdf(sha256.New(), 256, func(h hash.Hash) {
+			h.Write(z.FillBytes(make([]byte, 32)))
+			h.Write([]byte("IDENTITY\x00"))
+			h.Write(x)
+			h.Write(ek.X.FillBytes(make([]byte, 32)))
+		})
+	}
+
+	integrity := unsized(idObject)
+	encIdentity := idObject[2+len(integrity):]
+	mac := hmac.New(sha256.New, kdfa(seed, "INTEGRITY", nil, 256))
+	mac.Write(encIdentity)
+	mac.Write(t.akName())
+	if !hmac.Equal(mac.Sum(nil), integrity) {
+		return nil, fmt.Errorf("integrity check failed")
+	}
+	block, err := aes.NewCipher(kdfa(seed, "STORAGE", t.akName(), 128))
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, len(encIdentity))
+	cipher.NewCFBDecrypter(block, make([]byte, block.BlockSize())).XORKeyStream(plain, encIdentity)
+	return unsized(plain), nil
+}
+
+// quote is TPM2_Quote of the SHA-256 PCRs with the nonce binding the credential and the certificate request
+func (t *softwareTPM) quote(info *models.Attestation, credential []byte, csr string, pcrs map[int][]byte) {
+	block, _ := pem.Decode([]byte(csr))
+	request, err := x509.ParseCertificateRequest(block.Bytes)
+	Expect(err).ToNot(HaveOccurred())
+
+	bitmap := make([]byte, 3)
+	info.Pcrs = map[string]string{}
+	for index, value := range pcrs {
+		bitmap[index/8] |= 1 << (index % 8)
+		info.Pcrs[fmt.Sprint(index)] = hex.EncodeToString(value)
+	}
+	pcrDigest := sha256.New()
+	for index := 0; index < 24; index++ {
+		if value, ok := pcrs[index]; ok {
+			pcrDigest.Write(value)
+		}
+	}
+
+	var quoted []byte
+	quoted = appendUint32(quoted, 0xff544347)
+	quoted = appendUint16(quoted, 0x8018)
+	quoted = append(quoted, sized(t.akName())...)
+	quoted = append(quoted, sized(attestation.Nonce(credential, request))...)
+	quoted = append(quoted, make([]byte, 8+4+4+1+8)...) // clockInfo and firmwareVersion
+	quoted = appendUint32(quoted, 1)
+	quoted = appendUint16(quoted, 0x000b)
+	quoted = append(quoted, byte(len(bitmap)))
+	quoted = append(quoted, bitmap...)
+	quoted = append(quoted, sized(pcrDigest.Sum(nil))...)
+
+	info.ActivatedCredential = base64.StdEncoding.EncodeToString(credential)
+	info.Quote = base64.StdEncoding.EncodeToString(quoted)
+	info.QuoteSignature = base64.StdEncoding.EncodeToString(signECDSA(t.ak, quoted))
+}
+
+// signECDSA returns the TPMT_SIGNATURE of the data
+func signECDSA(key *ecdsa.PrivateKey, data []byte) []byte {
+	digest := sha256.Sum256(data)
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	Expect(err).ToNot(HaveOccurred())
+	out := appendUint16(nil, 0x0018, 0x000b)
+	out = append(out, sized(r.FillBytes(make([]byte, 32)))...)
+	return append(out, sized(s.FillBytes(make([]byte, 32)))...)
+}
+
+func certificateRequest() string {
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{CommonName: deviceID}}, eccKey())
+	Expect(err).ToNot(HaveOccurred())
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}))
+}
+
+func kdfa(key []byte, label string, contextU []byte, bits int) []byte {
+	return kdf(hmac.New(sha256.New, key), bits, func(h hash.Hash) {
+		h.Write([]byte(label + "\x00"))
+		h.Write(contextU)
+		_ = binary.Write(h, binary.BigEndian, uint32(bits))
+	})
+}
+
+func kdf(h hash.Hash, bits int, update func(h hash.Hash)) []byte {
+	var out []byte
+	for counter := uint32(1); len(out) < bits/8; counter++ {
+		h.Reset()
+		_ = binary.Write(h, binary.BigEndian, counter)
+		update(h)
+		out = h.Sum(out)
+	}
+	return out[:bits/8]
+}
+
+func rsaKey() crypto.Signer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	Expect(err).ToNot(HaveOccurred())
+	return key
+}
+
+func eccKey() crypto.Signer {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+	return key
+}
+
+func digest(value string) []byte {
+	sum := sha256.Sum256([]byte(value))
+	return sum[:]
+}
+
+func decode(value string) []byte {
+	data, err := base64.StdEncoding.DecodeString(value)
+	Expect(err).ToNot(HaveOccurred())
+	return data
+}
+
+func appendUint16(out []byte, values ...uint16) []byte {
+	for _, value := range values {
+		out = append(out, byte(value>>8), byte(value))
+	}
+	return out
+}
+
+func appendUint32(out []byte, value uint32) []byte {
+	return append(out, byte(value>>24), byte(value>>16), byte(value>>8), byte(value))
+}
+
+func sized(data []byte) []byte {
+	return append(appendUint16(nil, uint16(len(data))), data...)
+}
+
+func unsized(data []byte) []byte {
+	size := binary.BigEndian.Uint16(data)
+	return data[2 : 2+size]
+}
@@ -0,0 +1,397 @@
+package attestation
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+)
+
+// TPM 2.0 constants, see TPM 2.0 Part 2: Structures
+const (
+	tpmGeneratedValue uint32 = 0xff544347
+	tpmSTAttestQuote  uint16 = 0x8018
+
+	tpmAlgRSA    uint16 = 0x0001
+	tpmAlgSHA256 uint16 = 0x000b
+	tpmAlgNull   uint16 = 0x0010
+	tpmAlgRSASSA uint16 = 0x0014
+	tpmAlgECDSA  uint16 = 0x0018
+	tpmAlgECC    uint16 = 0x0023
+
+	tpmECCNistP256 uint16 = 0x0003
+	tpmECCNistP384 uint16 = 0x0004
+
+	// attributes of attestation keys: the key cannot leave the TPM it was created in and only signs data hashed by
+	// the TPM, so that quotes cannot be forged with it
+	attrFixedTPM            uint32 = 0x00000002
+	attrFixedParent         uint32 = 0x00000010
+	attrSensitiveDataOrigin uint32 = 0x00000020
+	attrRestricted          uint32 = 0x00010000
+	attrSign                uint32 = 0x00040000
+	attestationKeyAttrs            = attrFixedTPM | attrFixedParent | attrSensitiveDataOrigin | attrRestricted | attrSign
+
+	// seedSize is the size of the seed of RSA credentials and of the credentials themselves, the key size of the
+	// AES-128 symmetric cipher of the EK templates of the TCG EK Credential Profile
+	seedSize = 16
+
+	labelIdentity  = "IDENTITY"
+	labelStorage   = "STORAGE"
+	labelIntegrity = "INTEGRITY"
+)
+
+// Public is a parsed TPMT_PUBLIC structure
+type Public struct {
+	NameAlg    uint16
+	Attributes uint32
+	Key        crypto.PublicKey
+	// Name is the TPM name of the key: the name algorithm followed by the digest of the structure
+	Name []byte
+}
+
+// Quote is a parsed TPMS_ATTEST structure of type TPM_ST_ATTEST_QUOTE
+type Quote struct {
+	ExtraData []byte
+	// PCRs are the indexes of the SHA-256 PCRs quoted, in the order of the digest
+	PCRs      []int
+	PCRDigest []byte
+}
+
+// ParsePublic parses a TPMT_PUBLIC structure of an RSA or ECC key with the SHA-256 name algorithm
+func ParsePublic(data []byte) (*Public, error) {
+	r := bytes.NewReader(data)
+	var header struct {
+		Type       uint16
+		NameAlg    uint16
+		Attributes uint32
+	}
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return nil, fmt.Errorf("cannot read public area: %w", err)
+	}
+	if header.NameAlg != tpmAlgSHA256 {
+		return nil, fmt.Errorf("unsupported name algorithm 0x%x", header.NameAlg)
+	}
+	if _, err := readSized(r); err != nil { // authPolicy
+		return nil, err
+	}
+	if err := skipSymmetric(r); err != nil {
+		return nil, err
+	}
+	if err := skipScheme(r); err != nil {
+		return nil, err
+	}
+
+	var key crypto.PublicKey
+	switch header.Type {
+	case tpmAlgRSA:
+		var params struct {
+			KeyBits  uint16
+			Exponent uint32
+		}
+		if err := binary.Read(r, binary.BigEndian, &params); err != nil {
+			return nil, fmt.Errorf("cannot read RSA parameters: %w", err)
+		}
+		modulus, err := readSized(r)
+		if err != nil {
+			return nil, err
+		}
+		exponent := int(params.Exponent)
+		if exponent == 0 {
+			exponent = 65537
+		}
+		key = &rsa.PublicKey{N: new(big.Int).SetBytes(modulus), E: exponent}
+	case tpmAlgECC:
+		var curveID uint16
+		if err := binary.Read(r, binary.BigEndian, &curveID); err != nil {
+			return nil, fmt.Errorf("cannot read ECC curve: %w", err)
+		}
+		if err := skipScheme(r); err != nil { // kdf
+			return nil, err
+		}
+		curve, err := eccCurve(curveID)
+		if err != nil {
+			return nil, err
+		}
+		x, err := readSized(r)
+		if err != nil {
+			return nil, err
+		}
+		y, err := readSized(r)
+		if err != nil {
+			return nil, err
+		}
+		key = &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	default:
+		return nil, fmt.Errorf("unsupported key type 0x%x", header.Type)
+	}
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("unexpected %d bytes after the public area", r.Len())
+	}
+
+	digest := sha256.Sum256(data)
+	name := make([]byte, 2, 2+len(digest))
+	binary.BigEndian.PutUint16(name, tpmAlgSHA256)
+	return &Public{
+		NameAlg:    header.NameAlg,
+		Attributes: header.Attributes,
+		Key:        key,
+		Name:       append(name, digest[:]...),
+	}, nil
+}
+
+// ParseQuote parses a TPMS_ATTEST structure of a quote of SHA-256 PCRs
+func ParseQuote(data []byte) (*Quote, error) {
+	r := bytes.NewReader(data)
+	var header struct {
+		Magic uint32
+		Type  uint16
+	}
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return nil, fmt.Errorf("cannot read quote: %w", err)
+	}
+	if header.Magic != tpmGeneratedValue || header.Type != tpmSTAttestQuote {
+		return nil, fmt.Errorf("not a quote generated by a TPM")
+	}
+	if _, err := readSized(r); err != nil { // qualifiedSigner
+		return nil, err
+	}
+	extraData, err := readSized(r)
+	if err != nil {
+		return nil, err
+	}
+	// clockInfo (clock, resetCount, restartCount, safe) and firmwareVersion
+	if _, err := r.Seek(8+4+4+1+8, io.SeekCurrent); err != nil {
+		return nil, err
+	}
+
+	var count uint32
+	if err := binary.Read(r, binary.BigEndian, &count); err != nil {
+		return nil, fmt.Errorf("cannot read PCR selection: %w", err)
+	}
+	quote := &Quote{ExtraData: extraData}
+	for i := uint32(0); i < count; i++ {
+		var selection struct {
+			Hash uint16
+			Size uint8
+		}
+		if err := binary.Read(r, binary.BigEndian, &selection); err != nil {
+			return nil, fmt.Errorf("cannot read PCR selection: %w", err)
+		}
+		bitmap := make([]byte, selection.Size)
+		if _, err := io.ReadFull(r, bitmap); err != nil {
+			return nil, fmt.Errorf("cannot read PCR selection: %w", err)
+		}
+		if selection.Hash != tpmAlgSHA256 {
+			return nil, fmt.Errorf("unsupported PCR bank 0x%x", selection.Hash)
+		}
+		for index := 0; index < len(bitmap)*8; index++ {
+			if bitmap[index/8]&(1<<(index%8)) != 0 {
+				quote.PCRs = append(quote.PCRs, index)
+			}
+		}
+	}
+	if quote.PCRDigest, err = readSized(r); err != nil {
+		return nil, err
+	}
+	return quote, nil
+}
+
+// VerifySignature verifies the TPMT_SIGNATURE of the data, an RSASSA or ECDSA signature with SHA-256
+func VerifySignature(key crypto.PublicKey, data, signature []byte) error {
+	r := bytes.NewReader(signature)
+	var header struct {
+		Alg  uint16
+		Hash uint16
+	}
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return fmt.Errorf("cannot read signature: %w", err)
+	}
+	if header.Hash != tpmAlgSHA256 {
+		return fmt.Errorf("unsupported signature hash 0x%x", header.Hash)
+	}
+	digest := sha256.Sum256(data)
+	switch header.Alg {
+	case tpmAlgRSASSA:
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("RSA signature for a %T key", key)
+		}
+		sig, err := readSized(r)
+		if err != nil {
+			return err
+		}
+		return rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, digest[:], sig)
+	case tpmAlgECDSA:
+		ecdsaKey, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("ECDSA signature for a %T key", key)
+		}
+		sigR, err := readSized(r)
+		if err != nil {
+			return err
+		}
+		sigS, err := readSized(r)
+		if err != nil {
+			return err
+		}
+		if !ecdsa.Verify(ecdsaKey, digest[:], new(big.Int).SetBytes(sigR), new(big.Int).SetBytes(sigS)) {
+			return fmt.Errorf("invalid ECDSA signature")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported signature algorithm 0x%x", header.Alg)
+	}
+}
+
+// MakeCredential protects the secret so that only the TPM holding the endorsement key, and the key with the name
+// loaded in it, can recover it with TPM2_ActivateCredential. It returns the TPM2B_ID_OBJECT credential blob and the
+// TPM2B_ENCRYPTED_SECRET, see TPM 2.0 Part 1, section 24.
+func MakeCredential(ek crypto.PublicKey, name, secret []byte) ([]byte, []byte, error) {
+	var seed, encryptedSecret []byte
+	switch key := ek.(type) {
+	case *rsa.PublicKey:
+		seed = make([]byte, seedSize)
+		if _, err := rand.Read(seed); err != nil {
+			return nil, nil, err
+		}
+		var err error
+		encryptedSecret, err = rsa.EncryptOAEP(sha256.New(), rand.Reader, key, seed, append([]byte(labelIdentity), 0))
+		if err != nil {
+			return nil, nil, err
+		}
+	case *ecdsa.PublicKey:
+		ephemeral, x, y, err := elliptic.GenerateKey(key.Curve, rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		z, _ := key.Curve.ScalarMult(key.X, key.Y, ephemeral)
+		size := (key.Curve.Params().BitSize + 7) / 8
+		// the TPM derives the seed of ECC keys with the size of the digest of their name algorithm
+		seed = kdfe(pad(z, size), labelIdentity, pad(x, size), pad(key.X, size), sha256.Size*8)
+		encryptedSecret = append(sized(pad(x, size)), sized(pad(y, size))...)
+	default:
+		return nil, nil, fmt.Errorf("unsupported endorsement key %T", ek)
+	}
+
+	symmetricKey := kdfa(seed, labelStorage, name, nil, seedSize*8)
+	block, err := aes.NewCipher(symmetricKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	plain := sized(secret)
+	encIdentity := make([]byte, len(plain))
+	cipher.NewCFBEncrypter(block, make([]byte, block.BlockSize())).XORKeyStream(encIdentity, plain)
+
+	mac := hmac.New(sha256.New, kdfa(seed, labelIntegrity, nil, nil, sha256.Size*8))
+	mac.Write(encIdentity)
+	mac.Write(name)
+	idObject := append(sized(mac.Sum(nil)), encIdentity...)
+	return sized(idObject), sized(encryptedSecret), nil
+}
+
+// kdfa is the SP800-108 counter mode KDF with HMAC-SHA256 of TPM 2.0 Part 1, section 11.4.10.2
+func kdfa(key []byte, label string, contextU, contextV []byte, bits int) []byte {
+	mac := hmac.New(sha256.New, key)
+	return kdf(mac, bits, func() {
+		mac.Write([]byte(label))
+		mac.Write([]byte{0})
+		mac.Write(contextU)
+		mac.Write(contextV)
+		_ = binary.Write(mac, binary.BigEndian, uint32(bits))
+	})
+}
+
+// kdfe is the SP800-56A concatenation KDF with SHA-256 of TPM 2.0 Part 1, section 11.4.10.3
+func kdfe(z []byte, label string, partyUInfo, partyVInfo []byte, bits int) []byte {
+	h := sha256.New()
+	return kdf(h, bits, func() {
+		h.Write(z)
+		h.Write([]byte(label))
+		h.Write([]byte{0})
+		h.Write(partyUInfo)
+		h.Write(partyVInfo)
+	})
+}
+
+func kdf(h hash.Hash, bits int, update func()) []byte {
+	size := (bits + 7) / 8
+	var out []byte
+	for counter := uint32(1); len(out) < size; counter++ {
+		h.Reset()
+		_ = binary.Write(h, binary.BigEndian, counter)
+		update()
+		out = h.Sum(out)
+	}
+	return out[:size]
+}
+
+func eccCurve(id uint16) (elliptic.Curve, error) {
+	switch id {
+	case tpmECCNistP256:
+		return elliptic.P256(), nil
+	case tpmECCNistP384:
+		return elliptic.P384(), nil
+	}
+	return nil, fmt.Errorf("unsupported ECC curve 0x%x", id)
+}
+
+// skipSymmetric skips a TPMT_SYM_DEF_OBJECT: the algorithm, then the key bits and the mode unless it is TPM_ALG_NULL
+func skipSymmetric(r *bytes.Reader) error {
+	var alg uint16
+	if err := binary.Read(r, binary.BigEndian, &alg); err != nil {
+		return fmt.Errorf("cannot read symmetric algorithm: %w", err)
+	}
+	if alg == tpmAlgNull {
+		return nil
+	}
+	_, err := r.Seek(4, io.SeekCurrent)
+	return err
+}
+
+// skipScheme skips a scheme: the algorithm, then the hash algorithm unless it is TPM_ALG_NULL
+func skipScheme(r *bytes.Reader) error {
+	var alg uint16
+	if err := binary.Read(r, binary.BigEndian, &alg); err != nil {
+		return fmt.Errorf("cannot read scheme: %w", err)
+	}
+	if alg == tpmAlgNull {
+		return nil
+	}
+	_, err := r.Seek(2, io.SeekCurrent)
+	return err
+}
+
+// readSized reads a TPM2B structure: a 16 bits size followed by the bytes
+func readSized(r *bytes.Reader) ([]byte, error) {
+	var size uint16
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		return nil, fmt.Errorf("cannot read size: %w", err)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("cannot read %d bytes: %w", size, err)
+	}
+	return data, nil
+}
+
+func sized(data []byte) []byte {
+	out := make([]byte, 2, 2+len(data))
+	binary.BigEndian.PutUint16(out, uint16(len(data)))
+	return append(out, data...)
+}
+
+func pad(n *big.Int, size int) []byte {
+	out := make([]byte, size)
+	return n.FillBytes(out)
+}
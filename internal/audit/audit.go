@@ -0,0 +1,223 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-logr/logr"
+	_ "github.com/golang/mock/mockgen/model"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	// SinkStdout, SinkFile and SinkWebhook are the kinds of sinks the audit entries are written to
+	SinkStdout  = "stdout"
+	SinkFile    = "file"
+	SinkWebhook = "webhook"
+
+	// ActionRegistration, ActionCertificateSigning, ActionConfiguration and ActionHeartbeat are the device API
+	// interactions recorded in the audit trail
+	ActionRegistration       = "registration"
+	ActionCertificateSigning = "certificate-signing"
+	ActionConfiguration      = "configuration"
+	ActionHeartbeat          = "heartbeat"
+
+	// OutcomeSuccess, OutcomeRejected and OutcomeError are the outcomes of the interactions: OutcomeRejected for
+	// requests rejected because of the device, OutcomeError for requests failed because of the operator
+	OutcomeSuccess  = "success"
+	OutcomeRejected = "rejected"
+	OutcomeError    = "error"
+
+	queueSize   = 1024
+	batchSize   = 100
+	sinkTimeout = 10 * time.Second
+)
+
+// Entry is a record of the audit trail
+type Entry struct {
+	Time              time.Time         `json:"time"`
+	Action            string            `json:"action"`
+	DeviceID          string            `json:"deviceID"`
+	Namespace         string            `json:"namespace,omitempty"`
+	CertificateSerial string            `json:"certificateSerial,omitempty"`
+	RemoteAddress     string            `json:"remoteAddress,omitempty"`
+	Outcome           string            `json:"outcome"`
+	StatusCode        int               `json:"statusCode,omitempty"`
+	Details           map[string]string `json:"details,omitempty"`
+}
+
+//go:generate mockgen -package=audit -destination=mock_audit.go . Auditor
+type Auditor interface {
+	// Record adds the entry to the audit trail; it does not wait for the entry to be written
+	Record(entry *Entry)
+}
+
+// Sink writes audit entries
+type Sink interface {
+	Write(ctx context.Context, entries []*Entry) error
+}
+
+// SinkConfig configures the sink the audit entries are written to
+type SinkConfig struct {
+	// Kind is one of SinkStdout, SinkFile or SinkWebhook
+	Kind string
+
+	// File is the path of the file the entries are appended to, as JSON lines
+	File string
+
+	// WebhookURL is the URL the entries are posted to, as JSON arrays
+	WebhookURL string
+}
+
+func NewSink(config SinkConfig) (Sink, error) {
+	switch config.Kind {
+	case SinkStdout:
+		return NewStdoutSink(), nil
+	case SinkFile:
+		return NewFileSink(config.File)
+	case SinkWebhook:
+		return NewWebhookSink(config.WebhookURL)
+	default:
+		return nil, fmt.Errorf("unknown audit sink %q", config.Kind)
+	}
+}
+
+// Trail queues the entries recorded by the device API handlers and writes them to the sink in the background, so that
+// a slow sink does not slow the devices down. Entries recorded while the queue is full are dropped.
+type Trail struct {
+	sink    Sink
+	entries chan *Entry
+	logger  logr.Logger
+
+	// heartbeatSampling records one of every heartbeatSampling heartbeat entries; 0 records none
+	heartbeatSampling uint64
+	heartbeats        uint64
+	dropped           uint64
+
+	// shutdownTimeout bounds the writing of the entries still queued once the trail is stopped
+	shutdownTimeout time.Duration
+}
+
+func NewTrail(sink Sink, heartbeatSampling uint, shutdownTimeout time.Duration) *Trail {
+	return &Trail{
+		sink:              sink,
+		entries:           make(chan *Entry, queueSize),
+		logger:            ctrl.Log.WithName("audit"),
+		heartbeatSampling: uint64(heartbeatSampling),
+		shutdownTimeout:   shutdownTimeout,
+	}
+}
+
+func (t *Trail) Record(entry *Entry) {
+	if entry.Action == ActionHeartbeat && !t.sampled() {
+		return
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	select {
+	case t.entries <- entry:
+	default:
+		if atomic.AddUint64(&t.dropped, 1)%queueSize == 1 {
+			t.logger.Info("audit entries dropped, the sink is too slow", "dropped", atomic.LoadUint64(&t.dropped))
+		}
+	}
+}
+
+// Run writes the recorded entries to the sink until the context is done, then writes the entries still queued until
+// the shutdown timeout has elapsed
+func (t *Trail) Run(ctx context.Context) {
+	writeCtx, cancel := t.writeContext(ctx)
+	defer cancel()
+	for writeCtx.Err() == nil {
+		select {
+		case entry := <-t.entries:
+			t.write(writeCtx, t.batch(entry))
+		case <-ctx.Done():
+			select {
+			case entry := <-t.entries:
+				t.write(writeCtx, t.batch(entry))
+			default:
+				return
+			}
+		}
+	}
+	t.logger.Info("audit entries dropped on shutdown, the sink is too slow", "dropped", len(t.entries))
+}
+
+// writeContext returns the context of the writes to the sink, done once the shutdown timeout has elapsed after the
+// context of the trail is done, so that the writes in progress on shutdown are bounded as well
+func (t *Trail) writeContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	writeCtx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-writeCtx.Done():
+			return
+		}
+		timer := time.NewTimer(t.shutdownTimeout)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			cancel()
+		case <-writeCtx.Done():
+		}
+	}()
+	return writeCtx, cancel
+}
+
+// Start runs the trail as a manager.Runnable: the manager stops it on shutdown and waits for the queued entries to be
+// written before exiting, within its graceful shutdown timeout
+func (t *Trail) Start(ctx context.Context) error {
+	t.Run(ctx)
+	return nil
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable: every replica serves the device API and records its
+// entries
+func (t *Trail) NeedLeaderElection() bool {
+	return false
+}
+
+// batch returns the entry followed by the entries already queued
+func (t *Trail) batch(entry *Entry) []*Entry {
+	entries := []*Entry{entry}
+	for len(entries) < batchSize {
+		select {
+		case entry := <-t.entries:
+			entries = append(entries, entry)
+		default:
+			return entries
+		}
+	}
+	return entries
+}
+
+func (t *Trail) write(ctx context.Context, entries []*Entry) {
+	ctx, cancel := context.WithTimeout(ctx, sinkTimeout)
+	defer cancel()
+	if err := t.sink.Write(ctx, entries); err != nil {
+		t.logger.Error(err, "cannot write audit entries", "count", len(entries))
+	}
+}
+
+func (t *Trail) sampled() bool {
+	if t.heartbeatSampling == 0 {
+		return false
+	}
+	return (atomic.AddUint64(&t.heartbeats, 1)-1)%t.heartbeatSampling == 0
+}
+
+// OutcomeOf returns the outcome of a request answered with the HTTP status code
+func OutcomeOf(statusCode int) string {
+	switch {
+	case statusCode >= 500:
+		return OutcomeError
+	case statusCode >= 400:
+		return OutcomeRejected
+	default:
+		return OutcomeSuccess
+	}
+}
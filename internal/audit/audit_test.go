@@ -0,0 +1,205 @@
+package audit_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/project-flotta/flotta-operator/internal/audit"
+)
+
+type memorySink struct {
+	lock    sync.Mutex
+	entries []*audit.Entry
+	err     error
+}
+
+func (m *memorySink) Write(_ context.Context, entries []*audit.Entry) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.entries = append(m.entries, entries...)
+	return m.err
+}
+
+func (m *memorySink) written() []*audit.Entry {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return append([]*audit.Entry{}, m.entries...)
+}
+
+// blockingSink waits for the context to be done on every write
+type blockingSink struct {
+	lock  sync.Mutex
+	count int
+}
+
+func (b *blockingSink) Write(ctx context.Context, _ []*audit.Entry) error {
+	b.lock.Lock()
+	b.count++
+	b.lock.Unlock()
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (b *blockingSink) writes() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.count
+}
+
+var _ = Describe("Trail", func() {
+	var (
+		sink   *memorySink
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+
+	BeforeEach(func() {
+		sink = &memorySink{}
+		ctx, cancel = context.WithCancel(context.Background())
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	It("should write the recorded entries to the sink", func() {
+		// given
+		trail := audit.NewTrail(sink, 1, time.Minute)
+		go trail.Run(ctx)
+
+		// when
+		trail.Record(&audit.Entry{Action: audit.ActionRegistration, DeviceID: "device1", Outcome: audit.OutcomeSuccess})
+		trail.Record(&audit.Entry{Action: audit.ActionConfiguration, DeviceID: "device1", Outcome: audit.OutcomeSuccess})
+
+		// then
+		Eventually(sink.written).Should(HaveLen(2))
+		Expect(sink.written()[0].Action).To(Equal(audit.ActionRegistration))
+		Expect(sink.written()[0].Time).NotTo(BeZero())
+	})
+
+	It("should sample heartbeats", func() {
+		// given
+		trail := audit.NewTrail(sink, 3, time.Minute)
+
+		// when
+		for i := 0; i < 7; i++ {
+			trail.Record(&audit.Entry{Action: audit.ActionHeartbeat, DeviceID: fmt.Sprintf("device%d", i)})
+		}
+		trail.Record(&audit.Entry{Action: audit.ActionRegistration, DeviceID: "device1"})
+
+		// then
+		cancel()
+		trail.Run(ctx)
+		Expect(sink.written()).To(HaveLen(4))
+		Expect(sink.written()[0].DeviceID).To(Equal("device0"))
+		Expect(sink.written()[1].DeviceID).To(Equal("device3"))
+		Expect(sink.written()[2].DeviceID).To(Equal("device6"))
+	})
+
+	It("should not record heartbeats without sampling", func() {
+		// given
+		trail := audit.NewTrail(sink, 0, time.Minute)
+
+		// when
+		trail.Record(&audit.Entry{Action: audit.ActionHeartbeat, DeviceID: "device1"})
+
+		// then
+		cancel()
+		trail.Run(ctx)
+		Expect(sink.written()).To(BeEmpty())
+	})
+
+	It("should keep writing after sink errors", func() {
+		// given
+		sink.err = fmt.Errorf("unavailable")
+		trail := audit.NewTrail(sink, 1, time.Minute)
+		go trail.Run(ctx)
+
+		// when
+		trail.Record(&audit.Entry{Action: audit.ActionRegistration, DeviceID: "device1"})
+		Eventually(sink.written).Should(HaveLen(1))
+		trail.Record(&audit.Entry{Action: audit.ActionRegistration, DeviceID: "device2"})
+
+		// then
+		Eventually(sink.written).Should(HaveLen(2))
+	})
+
+	It("should not block when the sink is too slow", func() {
+		// given
+		trail := audit.NewTrail(sink, 1, time.Minute)
+
+		// when
+		for i := 0; i < 2000; i++ {
+			trail.Record(&audit.Entry{Action: audit.ActionRegistration, DeviceID: "device1"})
+		}
+
+		// then
+		cancel()
+		trail.Run(ctx)
+		Expect(sink.written()).To(HaveLen(1024))
+	})
+
+	It("should write the queued entries when stopped as a runnable", func() {
+		// given
+		trail := audit.NewTrail(sink, 1, time.Minute)
+		var runnable manager.Runnable = trail
+		done := make(chan error)
+		trail.Record(&audit.Entry{Action: audit.ActionRegistration, DeviceID: "device1"})
+
+		// when
+		cancel()
+		go func() {
+			done <- runnable.Start(ctx)
+		}()
+
+		// then
+		Eventually(done).Should(Receive(BeNil()))
+		Expect(sink.written()).To(HaveLen(1))
+		Expect(trail.NeedLeaderElection()).To(BeFalse())
+	})
+
+	It("should stop writing the queued entries after the shutdown timeout", func() {
+		// given
+		blocked := &blockingSink{}
+		trail := audit.NewTrail(blocked, 1, 50*time.Millisecond)
+		for i := 0; i < 200; i++ {
+			trail.Record(&audit.Entry{Action: audit.ActionRegistration, DeviceID: "device1"})
+		}
+
+		// when
+		cancel()
+		done := make(chan struct{})
+		go func() {
+			trail.Run(ctx)
+			close(done)
+		}()
+
+		// then
+		Eventually(done, time.Second).Should(BeClosed())
+		Expect(blocked.writes()).To(Equal(1))
+	})
+
+	It("should reject unknown sinks", func() {
+		// when
+		_, err := audit.NewSink(audit.SinkConfig{Kind: "kafka"})
+
+		// then
+		Expect(err).To(HaveOccurred())
+	})
+
+	table.DescribeTable("should map status codes to outcomes", func(statusCode int, outcome string) {
+		Expect(audit.OutcomeOf(statusCode)).To(Equal(outcome))
+	},
+		table.Entry("OK", 200, audit.OutcomeSuccess),
+		table.Entry("Unauthorized", 401, audit.OutcomeRejected),
+		table.Entry("Not Found", 404, audit.OutcomeRejected),
+		table.Entry("Internal Server Error", 500, audit.OutcomeError),
+	)
+})
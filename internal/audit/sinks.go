@@ -0,0 +1,89 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+)
+
+// WriterSink writes the entries as JSON lines
+type WriterSink struct {
+	writer io.Writer
+	lock   sync.Mutex
+}
+
+func NewStdoutSink() *WriterSink {
+	return &WriterSink{writer: os.Stdout}
+}
+
+// NewFileSink returns a sink appending the entries to the file, created if it does not exist
+func NewFileSink(path string) (*WriterSink, error) {
+	if path == "" {
+		return nil, fmt.Errorf("audit file is not set")
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open audit file: %w", err)
+	}
+	return &WriterSink{writer: file}, nil
+}
+
+func (w *WriterSink) Write(_ context.Context, entries []*Entry) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	writer := bufio.NewWriter(w.writer)
+	encoder := json.NewEncoder(writer)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// WebhookSink posts the entries to a webhook, as a JSON array
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookSink(webhookURL string) (*WebhookSink, error) {
+	if webhookURL == "" {
+		return nil, fmt.Errorf("audit webhook URL is not set")
+	}
+	if _, err := url.Parse(webhookURL); err != nil {
+		return nil, fmt.Errorf("invalid audit webhook URL: %w", err)
+	}
+	return &WebhookSink{
+		url:    webhookURL,
+		client: &http.Client{Timeout: sinkTimeout},
+	}, nil
+}
+
+func (w *WebhookSink) Write(ctx context.Context, entries []*Entry) error {
+	body, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot post audit entries: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("cannot post audit entries: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package audit_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/project-flotta/flotta-operator/internal/audit"
+)
+
+var _ = Describe("Sinks", func() {
+	var entries []*audit.Entry
+
+	BeforeEach(func() {
+		entries = []*audit.Entry{
+			{
+				Time:              time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC),
+				Action:            audit.ActionCertificateSigning,
+				DeviceID:          "device1",
+				Namespace:         "default",
+				CertificateSerial: "2A",
+				RemoteAddress:     "192.0.2.10:51234",
+				Outcome:           audit.OutcomeSuccess,
+				StatusCode:        200,
+				Details:           map[string]string{"issuedCertificateSerial": "2B"},
+			},
+			{
+				Time:     time.Date(2022, 5, 1, 10, 0, 1, 0, time.UTC),
+				Action:   audit.ActionConfiguration,
+				DeviceID: "device2",
+				Outcome:  audit.OutcomeRejected,
+			},
+		}
+	})
+
+	Context("File sink", func() {
+		var directory string
+
+		BeforeEach(func() {
+			var err error
+			directory, err = ioutil.TempDir("", "audit")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(directory)
+		})
+
+		It("should append the entries as JSON lines", func() {
+			// given
+			path := filepath.Join(directory, "audit.log")
+			sink, err := audit.NewFileSink(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sink.Write(context.TODO(), entries[:1])).To(Succeed())
+
+			// when
+			err = sink.Write(context.TODO(), entries[1:])
+
+			// then
+			Expect(err).NotTo(HaveOccurred())
+			content, err := ioutil.ReadFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+			Expect(lines).To(Equal([]string{
+				`{"time":"2022-05-01T10:00:00Z","action":"certificate-signing","deviceID":"device1","namespace":"default",` +
+					`"certificateSerial":"2A","remoteAddress":"192.0.2.10:51234","outcome":"success","statusCode":200,` +
+					`"details":{"issuedCertificateSerial":"2B"}}`,
+				`{"time":"2022-05-01T10:00:01Z","action":"configuration","deviceID":"device2","outcome":"rejected"}`,
+			}))
+		})
+
+		It("should fail without file", func() {
+			// when
+			_, err := audit.NewFileSink("")
+
+			// then
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("Webhook sink", func() {
+		var (
+			server *httptest.Server
+			status int
+			body   []byte
+		)
+
+		BeforeEach(func() {
+			status = http.StatusOK
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ = ioutil.ReadAll(r.Body)
+				w.WriteHeader(status)
+			}))
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("should post the entries as a JSON array", func() {
+			// given
+			sink, err := audit.NewWebhookSink(server.URL)
+			Expect(err).NotTo(HaveOccurred())
+
+			// when
+			err = sink.Write(context.TODO(), entries)
+
+			// then
+			Expect(err).NotTo(HaveOccurred())
+			var posted []*audit.Entry
+			Expect(json.Unmarshal(body, &posted)).To(Succeed())
+			Expect(posted).To(Equal(entries))
+		})
+
+		It("should fail when the webhook rejects the entries", func() {
+			// given
+			status = http.StatusServiceUnavailable
+			sink, err := audit.NewWebhookSink(server.URL)
+			Expect(err).NotTo(HaveOccurred())
+
+			// when
+			err = sink.Write(context.TODO(), entries)
+
+			// then
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,147 @@
+package capacity
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const gpuResourceSuffix = "/gpu"
+
+// Resources is an amount of compute resources
+type Resources struct {
+	MilliCPU    int64
+	MemoryBytes int64
+	GPUs        int64
+}
+
+func (r *Resources) add(other Resources) {
+	r.MilliCPU += other.MilliCPU
+	r.MemoryBytes += other.MemoryBytes
+	r.GPUs += other.GPUs
+}
+
+func (r *Resources) max(other Resources) {
+	if other.MilliCPU > r.MilliCPU {
+		r.MilliCPU = other.MilliCPU
+	}
+	if other.MemoryBytes > r.MemoryBytes {
+		r.MemoryBytes = other.MemoryBytes
+	}
+	if other.GPUs > r.GPUs {
+		r.GPUs = other.GPUs
+	}
+}
+
+// IsZero returns true when no resources are declared
+func (r Resources) IsZero() bool {
+	return r.MilliCPU == 0 && r.MemoryBytes == 0 && r.GPUs == 0
+}
+
+// Required returns resources declared by the pod of the deployment: requests of the containers or limits when
+// requests are not set. Init containers run one by one before the containers, so the bigger of the two is used.
+func Required(edgeDeployment *v1alpha1.EdgeDeployment) Resources {
+	podSpec := edgeDeployment.Spec.Pod.Spec
+
+	var containers Resources
+	for i := range podSpec.Containers {
+		containers.add(containerResources(&podSpec.Containers[i]))
+	}
+	var initContainers Resources
+	for i := range podSpec.InitContainers {
+		initContainers.max(containerResources(&podSpec.InitContainers[i]))
+	}
+	containers.max(initContainers)
+	return containers
+}
+
+// Allocatable returns resources of the device according to the hardware reported in its heartbeat.
+// Resources not reported by the device are zero; GPUs are counted whenever the hardware is reported.
+func Allocatable(edgeDevice *v1alpha1.EdgeDevice) Resources {
+	var allocatable Resources
+	hardware := edgeDevice.Status.Hardware
+	if hardware == nil {
+		return allocatable
+	}
+	if hardware.CPU != nil {
+		allocatable.MilliCPU = hardware.CPU.Count * 1000
+	}
+	if hardware.Memory != nil {
+		allocatable.MemoryBytes = hardware.Memory.UsableBytes
+		if allocatable.MemoryBytes == 0 {
+			allocatable.MemoryBytes = hardware.Memory.PhysicalBytes
+		}
+	}
+	allocatable.GPUs = int64(len(hardware.Gpus))
+	return allocatable
+}
+
+// Check verifies that the deployment fits the device together with the deployments already deployed there.
+// CPU and memory the device did not report are not checked, GPUs are not checked until the device reports its hardware.
+func Check(edgeDevice *v1alpha1.EdgeDevice, deployed []v1alpha1.EdgeDeployment, edgeDeployment *v1alpha1.EdgeDeployment) error {
+	required := Required(edgeDeployment)
+	if required.IsZero() {
+		return nil
+	}
+	for i := range deployed {
+		if deployed[i].Name != edgeDeployment.Name {
+			required.add(Required(&deployed[i]))
+		}
+	}
+
+	allocatable := Allocatable(edgeDevice)
+	var insufficient []string
+	if allocatable.MilliCPU > 0 && required.MilliCPU > allocatable.MilliCPU {
+		insufficient = append(insufficient, fmt.Sprintf("cpu (required %dm, available %dm)",
+			required.MilliCPU, allocatable.MilliCPU))
+	}
+	if allocatable.MemoryBytes > 0 && required.MemoryBytes > allocatable.MemoryBytes {
+		insufficient = append(insufficient, fmt.Sprintf("memory (required %d bytes, available %d bytes)",
+			required.MemoryBytes, allocatable.MemoryBytes))
+	}
+	if edgeDevice.Status.Hardware != nil && required.GPUs > allocatable.GPUs {
+		insufficient = append(insufficient, fmt.Sprintf("gpu (required %d, available %d)",
+			required.GPUs, allocatable.GPUs))
+	}
+	if len(insufficient) != 0 {
+		return fmt.Errorf("insufficient %s on device %s", strings.Join(insufficient, ", "), edgeDevice.Name)
+	}
+	return nil
+}
+
+func containerResources(container *corev1.Container) Resources {
+	return Resources{
+		MilliCPU:    quantity(container.Resources, corev1.ResourceCPU).MilliValue(),
+		MemoryBytes: quantity(container.Resources, corev1.ResourceMemory).Value(),
+		GPUs:        gpus(container.Resources),
+	}
+}
+
+// gpus returns the number of GPUs declared by the container with extended resources like nvidia.com/gpu
+func gpus(requirements corev1.ResourceRequirements) int64 {
+	var count int64
+	for name, q := range requirements.Limits {
+		if strings.HasSuffix(string(name), gpuResourceSuffix) {
+			count += q.Value()
+		}
+	}
+	for name, q := range requirements.Requests {
+		if _, ok := requirements.Limits[name]; !ok && strings.HasSuffix(string(name), gpuResourceSuffix) {
+			count += q.Value()
+		}
+	}
+	return count
+}
+
+func quantity(requirements corev1.ResourceRequirements, name corev1.ResourceName) *resource.Quantity {
+	if q, ok := requirements.Requests[name]; ok {
+		return &q
+	}
+	if q, ok := requirements.Limits[name]; ok {
+		return &q
+	}
+	return &resource.Quantity{}
+}
@@ -0,0 +1,193 @@
+package capacity_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"github.com/project-flotta/flotta-operator/internal/capacity"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Capacity", func() {
+	var (
+		device *v1alpha1.EdgeDevice
+	)
+
+	deployment := func(name string, containers []corev1.Container, initContainers ...corev1.Container) *v1alpha1.EdgeDeployment {
+		return &v1alpha1.EdgeDeployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: v1alpha1.EdgeDeploymentSpec{
+				Pod: v1alpha1.Pod{Spec: corev1.PodSpec{Containers: containers, InitContainers: initContainers}},
+			},
+		}
+	}
+
+	container := func(requests, limits corev1.ResourceList) corev1.Container {
+		return corev1.Container{Resources: corev1.ResourceRequirements{Requests: requests, Limits: limits}}
+	}
+
+	resources := func(cpu, memory string) corev1.ResourceList {
+		list := corev1.ResourceList{}
+		if cpu != "" {
+			list[corev1.ResourceCPU] = resource.MustParse(cpu)
+		}
+		if memory != "" {
+			list[corev1.ResourceMemory] = resource.MustParse(memory)
+		}
+		return list
+	}
+
+	BeforeEach(func() {
+		device = &v1alpha1.EdgeDevice{
+			ObjectMeta: metav1.ObjectMeta{Name: "device"},
+			Status: v1alpha1.EdgeDeviceStatus{
+				Hardware: &v1alpha1.Hardware{
+					CPU:    &v1alpha1.CPU{Count: 2},
+					Memory: &v1alpha1.Memory{PhysicalBytes: 2 * 1024 * 1024 * 1024, UsableBytes: 1024 * 1024 * 1024},
+				},
+			},
+		}
+	})
+
+	Context("Required", func() {
+		It("should prefer requests over limits", func() {
+			// given
+			d := deployment("d", []corev1.Container{
+				container(resources("500m", ""), resources("1", "256Mi")),
+				container(nil, resources("250m", "128Mi")),
+			})
+
+			// when
+			required := capacity.Required(d)
+
+			// then
+			Expect(required).To(Equal(capacity.Resources{MilliCPU: 750, MemoryBytes: 384 * 1024 * 1024}))
+		})
+
+		It("should use the biggest init container when it exceeds containers", func() {
+			// given
+			d := deployment("d",
+				[]corev1.Container{container(resources("100m", "64Mi"), nil), container(resources("100m", "64Mi"), nil)},
+				container(resources("1", "32Mi"), nil),
+				container(resources("500m", "32Mi"), nil),
+			)
+
+			// when
+			required := capacity.Required(d)
+
+			// then
+			Expect(required).To(Equal(capacity.Resources{MilliCPU: 1000, MemoryBytes: 128 * 1024 * 1024}))
+		})
+
+		It("should count GPU extended resources", func() {
+			// given
+			d := deployment("d", []corev1.Container{
+				container(nil, corev1.ResourceList{"nvidia.com/gpu": resource.MustParse("1")}),
+				container(corev1.ResourceList{"amd.com/gpu": resource.MustParse("2")}, nil),
+				container(nil, corev1.ResourceList{"example.com/fpga": resource.MustParse("1")}),
+			})
+
+			// when
+			required := capacity.Required(d)
+
+			// then
+			Expect(required).To(Equal(capacity.Resources{GPUs: 3}))
+		})
+	})
+
+	Context("Allocatable", func() {
+		It("should use usable memory and CPU count", func() {
+			Expect(capacity.Allocatable(device)).To(Equal(capacity.Resources{MilliCPU: 2000, MemoryBytes: 1024 * 1024 * 1024}))
+		})
+
+		It("should count GPUs", func() {
+			// given
+			device.Status.Hardware.Gpus = []*v1alpha1.Gpu{{}, {}}
+
+			// then
+			Expect(capacity.Allocatable(device).GPUs).To(BeEquivalentTo(2))
+		})
+
+		It("should fall back to physical memory", func() {
+			// given
+			device.Status.Hardware.Memory.UsableBytes = 0
+
+			// then
+			Expect(capacity.Allocatable(device).MemoryBytes).To(BeEquivalentTo(2 * 1024 * 1024 * 1024))
+		})
+
+		It("should be zero without hardware", func() {
+			// given
+			device.Status.Hardware = nil
+
+			// then
+			Expect(capacity.Allocatable(device).IsZero()).To(BeTrue())
+		})
+	})
+
+	Context("Check", func() {
+		It("should accept deployment without resources", func() {
+			Expect(capacity.Check(device, nil, deployment("d", []corev1.Container{{}}))).To(Succeed())
+		})
+
+		It("should accept deployment that fits", func() {
+			// given
+			deployed := []v1alpha1.EdgeDeployment{
+				*deployment("other", []corev1.Container{container(resources("1", "512Mi"), nil)}),
+			}
+
+			// then
+			Expect(capacity.Check(device, deployed,
+				deployment("d", []corev1.Container{container(resources("1", "512Mi"), nil)}))).To(Succeed())
+		})
+
+		It("should reject deployment exceeding the device together with deployed ones", func() {
+			// given
+			deployed := []v1alpha1.EdgeDeployment{
+				*deployment("other", []corev1.Container{container(resources("1500m", "512Mi"), nil)}),
+			}
+
+			// when
+			err := capacity.Check(device, deployed,
+				deployment("d", []corev1.Container{container(resources("1", "768Mi"), nil)}))
+
+			// then
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("cpu"))
+			Expect(err.Error()).To(ContainSubstring("memory"))
+		})
+
+		It("should not count the deployment twice", func() {
+			// given
+			d := deployment("d", []corev1.Container{container(resources("2", ""), nil)})
+
+			// then
+			Expect(capacity.Check(device, []v1alpha1.EdgeDeployment{*d}, d)).To(Succeed())
+		})
+
+		It("should not check resources unknown on the device", func() {
+			// given
+			device.Status.Hardware = nil
+
+			// then
+			Expect(capacity.Check(device, nil,
+				deployment("d", []corev1.Container{container(resources("64", "1Ti"), nil)}))).To(Succeed())
+		})
+
+		It("should reject deployment requiring GPU on device without GPUs", func() {
+			// given
+			d := deployment("d", []corev1.Container{
+				container(nil, corev1.ResourceList{"nvidia.com/gpu": resource.MustParse("1")}),
+			})
+
+			// when
+			err := capacity.Check(device, nil, d)
+
+			// then
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("gpu"))
+		})
+	})
+})
@@ -0,0 +1,13 @@
+package devicelogs_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestDeviceLogs(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Device Logs Suite")
+}
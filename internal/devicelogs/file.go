@@ -0,0 +1,74 @@
+package devicelogs
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/project-flotta/flotta-operator/models"
+)
+
+// FileSink appends logs to files laid out as <directory>/<namespace>/<device>/<workload>.log, the logs of the device
+// itself going to system.log. Each line is the timestamp of the entry followed by its message.
+type FileSink struct {
+	directory string
+	lock      sync.Mutex
+	now       func() time.Time
+}
+
+func NewFileSink(directory string) (*FileSink, error) {
+	if directory == "" {
+		return nil, fmt.Errorf("file sink directory is not set")
+	}
+	info, err := os.Stat(directory)
+	if err != nil {
+		return nil, fmt.Errorf("cannot use file sink directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("file sink directory %s is not a directory", directory)
+	}
+	return &FileSink{directory: directory, now: time.Now}, nil
+}
+
+func (f *FileSink) Forward(_ context.Context, deviceID, namespace string, message *models.LogsMessage) error {
+	workload := workloadOf(message)
+	for _, name := range []string{namespace, deviceID, workload} {
+		if !isPathElement(name) {
+			return fmt.Errorf("%w: %q cannot be used as a file name", ErrInvalidMessage, name)
+		}
+	}
+	if len(message.Entries) == 0 {
+		return nil
+	}
+
+	dir := filepath.Join(f.directory, namespace, deviceID)
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(dir, workload+".log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	now := f.now()
+	writer := bufio.NewWriter(file)
+	for _, entry := range message.Entries {
+		if entry == nil {
+			continue
+		}
+		fmt.Fprintf(writer, "%s %s\n", timestampOf(entry, now).UTC().Format(time.RFC3339Nano), lineOf(entry))
+	}
+	return writer.Flush()
+}
+
+func isPathElement(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
@@ -0,0 +1,93 @@
+package devicelogs_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/project-flotta/flotta-operator/internal/devicelogs"
+	"github.com/project-flotta/flotta-operator/models"
+)
+
+var _ = Describe("File sink", func() {
+	var (
+		directory string
+		sink      *devicelogs.FileSink
+		timestamp = strfmt.DateTime(time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC))
+	)
+
+	BeforeEach(func() {
+		var err error
+		directory, err = ioutil.TempDir("", "devicelogs")
+		Expect(err).NotTo(HaveOccurred())
+		sink, err = devicelogs.NewFileSink(directory)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(directory)
+	})
+
+	read := func(elem ...string) string {
+		content, err := ioutil.ReadFile(filepath.Join(append([]string{directory}, elem...)...))
+		Expect(err).NotTo(HaveOccurred())
+		return string(content)
+	}
+
+	It("should append the entries to the file of the workload", func() {
+		// given
+		Expect(sink.Forward(context.TODO(), "device1", "default", &models.LogsMessage{
+			Workload: "camera",
+			Entries:  []*models.LogEntry{{Timestamp: timestamp, Message: "started\n"}},
+		})).To(Succeed())
+
+		// when
+		err := sink.Forward(context.TODO(), "device1", "default", &models.LogsMessage{
+			Workload: "camera",
+			Entries:  []*models.LogEntry{{Timestamp: timestamp, Message: "running"}},
+		})
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(read("default", "device1", "camera.log")).To(Equal(
+			"2022-05-01T10:00:00Z started\n2022-05-01T10:00:00Z running\n"))
+	})
+
+	It("should write the logs of the device to the system file", func() {
+		// when
+		err := sink.Forward(context.TODO(), "device1", "default", &models.LogsMessage{
+			Entries: []*models.LogEntry{{Timestamp: timestamp, Message: "booted"}},
+		})
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(read("default", "device1", "system.log")).To(Equal("2022-05-01T10:00:00Z booted\n"))
+	})
+
+	It("should reject workloads escaping the directory of the device", func() {
+		// when
+		err := sink.Forward(context.TODO(), "device1", "default", &models.LogsMessage{
+			Workload: "../device2/camera",
+			Entries:  []*models.LogEntry{{Timestamp: timestamp, Message: "spoofed"}},
+		})
+
+		// then
+		Expect(errors.Is(err, devicelogs.ErrInvalidMessage)).To(BeTrue())
+		Expect(filepath.Join(directory, "default", "device2")).NotTo(BeADirectory())
+	})
+
+	It("should fail to start without directory", func() {
+		// when
+		_, err := devicelogs.NewFileSink(filepath.Join(directory, "missing"))
+
+		// then
+		Expect(err).To(HaveOccurred())
+	})
+})
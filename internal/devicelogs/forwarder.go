@@ -0,0 +1,83 @@
+package devicelogs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/project-flotta/flotta-operator/models"
+)
+
+const (
+	// SinkSyslog, SinkLoki and SinkFile are the kinds of sinks the logs sent by devices can be forwarded to
+	SinkSyslog = "syslog"
+	SinkLoki   = "loki"
+	SinkFile   = "file"
+
+	// systemWorkload names the source of the logs of the device itself, sent without workload
+	systemWorkload = "system"
+
+	sinkTimeout = 10 * time.Second
+)
+
+// ErrInvalidMessage is returned when a logs message cannot be forwarded whatever the state of the sink
+var ErrInvalidMessage = errors.New("invalid logs message")
+
+//go:generate mockgen -package=devicelogs -destination=mock_forwarder.go . Forwarder
+type Forwarder interface {
+	// Forward sends the entries of the message to the sink, labelled with the device, its namespace and the workload
+	Forward(ctx context.Context, deviceID, namespace string, message *models.LogsMessage) error
+}
+
+// SinkConfig configures the sink the logs sent by devices are forwarded to
+type SinkConfig struct {
+	// Kind is one of SinkSyslog, SinkLoki or SinkFile
+	Kind string
+
+	// SyslogAddress is the host:port of the syslog server
+	SyslogAddress string
+
+	// SyslogProtocol is tcp or udp
+	SyslogProtocol string
+
+	// LokiURL is the base URL of the Loki server; logs are pushed to its /loki/api/v1/push endpoint
+	LokiURL string
+
+	// Directory is where the files of the logs are written, usually the mount point of a PVC
+	Directory string
+}
+
+func NewForwarder(config SinkConfig) (Forwarder, error) {
+	switch config.Kind {
+	case SinkSyslog:
+		return NewSyslogSink(config.SyslogAddress, config.SyslogProtocol)
+	case SinkLoki:
+		return NewLokiSink(config.LokiURL)
+	case SinkFile:
+		return NewFileSink(config.Directory)
+	default:
+		return nil, fmt.Errorf("unknown device logs sink %q", config.Kind)
+	}
+}
+
+func workloadOf(message *models.LogsMessage) string {
+	if message.Workload == "" {
+		return systemWorkload
+	}
+	return message.Workload
+}
+
+func timestampOf(entry *models.LogEntry, now time.Time) time.Time {
+	timestamp := time.Time(entry.Timestamp)
+	if timestamp.IsZero() {
+		return now
+	}
+	return timestamp
+}
+
+// lineOf returns the message of the entry without trailing line breaks
+func lineOf(entry *models.LogEntry) string {
+	return strings.TrimRight(entry.Message, "\r\n")
+}
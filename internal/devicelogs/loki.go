@@ -0,0 +1,95 @@
+package devicelogs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/project-flotta/flotta-operator/models"
+)
+
+const (
+	// DeviceIDLabel, NamespaceLabel and WorkloadLabel are the labels of the Loki streams of the device logs
+	DeviceIDLabel  = "device_id"
+	NamespaceLabel = "namespace"
+	WorkloadLabel  = "workload"
+
+	lokiPushPath = "/loki/api/v1/push"
+)
+
+type lokiPushRequest struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	// Values are pairs of a timestamp in nanoseconds since epoch and a log line
+	Values [][2]string `json:"values"`
+}
+
+// LokiSink pushes logs to Loki, in a stream for each device workload
+type LokiSink struct {
+	pushURL string
+	client  *http.Client
+	now     func() time.Time
+}
+
+func NewLokiSink(lokiURL string) (*LokiSink, error) {
+	if lokiURL == "" {
+		return nil, fmt.Errorf("loki sink URL is not set")
+	}
+	if _, err := url.Parse(lokiURL); err != nil {
+		return nil, fmt.Errorf("invalid loki sink URL: %w", err)
+	}
+	return &LokiSink{
+		pushURL: strings.TrimSuffix(lokiURL, "/") + lokiPushPath,
+		client:  &http.Client{Timeout: sinkTimeout},
+		now:     time.Now,
+	}, nil
+}
+
+func (l *LokiSink) Forward(ctx context.Context, deviceID, namespace string, message *models.LogsMessage) error {
+	now := l.now()
+	stream := lokiStream{
+		Stream: map[string]string{
+			DeviceIDLabel:  deviceID,
+			NamespaceLabel: namespace,
+			WorkloadLabel:  workloadOf(message),
+		},
+	}
+	for _, entry := range message.Entries {
+		if entry == nil {
+			continue
+		}
+		timestamp := strconv.FormatInt(timestampOf(entry, now).UnixNano(), 10)
+		stream.Values = append(stream.Values, [2]string{timestamp, lineOf(entry)})
+	}
+	if len(stream.Values) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(lokiPushRequest{Streams: []lokiStream{stream}})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.pushURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot push logs to loki: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("cannot push logs to loki: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package devicelogs_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/project-flotta/flotta-operator/internal/devicelogs"
+	"github.com/project-flotta/flotta-operator/models"
+)
+
+var _ = Describe("Loki sink", func() {
+	var (
+		server *httptest.Server
+		status int
+		path   string
+		body   string
+	)
+
+	BeforeEach(func() {
+		status = http.StatusNoContent
+		path = ""
+		body = ""
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path = r.URL.Path
+			content, _ := ioutil.ReadAll(r.Body)
+			body = string(content)
+			w.WriteHeader(status)
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should push the entries in a stream labelled with the device and workload", func() {
+		// given
+		sink, err := devicelogs.NewLokiSink(server.URL + "/")
+		Expect(err).NotTo(HaveOccurred())
+		timestamp := strfmt.DateTime(time.Unix(1651399200, 5))
+
+		// when
+		err = sink.Forward(context.TODO(), "device1", "default", &models.LogsMessage{
+			Workload: "camera",
+			Entries: []*models.LogEntry{
+				{Timestamp: timestamp, Message: "started\n"},
+				{Timestamp: timestamp, Message: "running"},
+			},
+		})
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(path).To(Equal("/loki/api/v1/push"))
+		Expect(body).To(MatchJSON(`{"streams": [{
+			"stream": {"device_id": "device1", "namespace": "default", "workload": "camera"},
+			"values": [["1651399200000000005", "started"], ["1651399200000000005", "running"]]
+		}]}`))
+	})
+
+	It("should not push empty messages", func() {
+		// given
+		sink, err := devicelogs.NewLokiSink(server.URL)
+		Expect(err).NotTo(HaveOccurred())
+
+		// when
+		err = sink.Forward(context.TODO(), "device1", "default", &models.LogsMessage{})
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(path).To(BeEmpty())
+	})
+
+	It("should fail when loki rejects the entries", func() {
+		// given
+		status = http.StatusBadRequest
+		sink, err := devicelogs.NewLokiSink(server.URL)
+		Expect(err).NotTo(HaveOccurred())
+
+		// when
+		err = sink.Forward(context.TODO(), "device1", "default", &models.LogsMessage{
+			Entries: []*models.LogEntry{{Message: "booted"}},
+		})
+
+		// then
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,102 @@
+package devicelogs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/project-flotta/flotta-operator/models"
+)
+
+const (
+	// syslogPriority is the user facility with the informational severity
+	syslogPriority = 14
+
+	// syslogSDID identifies the structured data element carrying the labels of the entries
+	syslogSDID = "flotta@32473"
+
+	// nilValue stands for an empty RFC 5424 header field
+	nilValue = "-"
+)
+
+// SyslogSink forwards logs to a syslog server as RFC 5424 messages. The device is the HOSTNAME and the workload the
+// APP-NAME of the messages, and all the labels are in their structured data.
+type SyslogSink struct {
+	address  string
+	protocol string
+	now      func() time.Time
+}
+
+func NewSyslogSink(address, protocol string) (*SyslogSink, error) {
+	if address == "" {
+		return nil, fmt.Errorf("syslog sink address is not set")
+	}
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	if protocol != "tcp" && protocol != "udp" {
+		return nil, fmt.Errorf("protocol '%s' is not valid for syslog server", protocol)
+	}
+	return &SyslogSink{address: address, protocol: protocol, now: time.Now}, nil
+}
+
+func (s *SyslogSink) Forward(ctx context.Context, deviceID, namespace string, message *models.LogsMessage) error {
+	if len(message.Entries) == 0 {
+		return nil
+	}
+	dialer := net.Dialer{Timeout: sinkTimeout}
+	conn, err := dialer.DialContext(ctx, s.protocol, s.address)
+	if err != nil {
+		return fmt.Errorf("cannot connect to syslog server %s: %w", s.address, err)
+	}
+	defer conn.Close()
+	_ = conn.SetWriteDeadline(time.Now().Add(sinkTimeout))
+
+	now := s.now()
+	workload := workloadOf(message)
+	structuredData := fmt.Sprintf(`[%s device_id="%s" namespace="%s" workload="%s"]`, syslogSDID,
+		escapeParam(deviceID), escapeParam(namespace), escapeParam(workload))
+	for _, entry := range message.Entries {
+		if entry == nil {
+			continue
+		}
+		line := fmt.Sprintf("<%d>1 %s %s %s %s %s %s %s", syslogPriority,
+			timestampOf(entry, now).UTC().Format(time.RFC3339Nano), headerField(deviceID, 255),
+			headerField(workload, 48), nilValue, nilValue, structuredData, lineOf(entry))
+		var frame bytes.Buffer
+		if s.protocol == "tcp" {
+			// octet counting framing, RFC 6587
+			fmt.Fprintf(&frame, "%d ", len(line))
+		}
+		frame.WriteString(line)
+		if _, err := conn.Write(frame.Bytes()); err != nil {
+			return fmt.Errorf("cannot send logs to syslog server %s: %w", s.address, err)
+		}
+	}
+	return nil
+}
+
+// headerField returns the value as a valid header field: printable ASCII without spaces, truncated to the length
+func headerField(value string, length int) string {
+	field := strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, value)
+	if field == "" {
+		return nilValue
+	}
+	if len(field) > length {
+		field = field[:length]
+	}
+	return field
+}
+
+// escapeParam escapes the characters not allowed in structured data parameter values
+func escapeParam(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
@@ -0,0 +1,90 @@
+package devicelogs_test
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/project-flotta/flotta-operator/internal/devicelogs"
+	"github.com/project-flotta/flotta-operator/models"
+)
+
+var _ = Describe("Syslog sink", func() {
+	var (
+		timestamp = strfmt.DateTime(time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC))
+		message   = &models.LogsMessage{
+			Workload: "camera",
+			Entries: []*models.LogEntry{
+				{Timestamp: timestamp, Message: "started\n"},
+				{Timestamp: timestamp, Message: `say "hi"]`},
+			},
+		}
+	)
+
+	It("should send RFC 5424 messages over TCP", func() {
+		// given
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		defer listener.Close()
+		received := make(chan string)
+		go func() {
+			defer GinkgoRecover()
+			conn, err := listener.Accept()
+			Expect(err).NotTo(HaveOccurred())
+			defer conn.Close()
+			var out strings.Builder
+			_, _ = bufio.NewReader(conn).WriteTo(&out)
+			received <- out.String()
+		}()
+		sink, err := devicelogs.NewSyslogSink(listener.Addr().String(), "tcp")
+		Expect(err).NotTo(HaveOccurred())
+
+		// when
+		err = sink.Forward(context.TODO(), "device1", "default", message)
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		first := `<14>1 2022-05-01T10:00:00Z device1 camera - - [flotta@32473 device_id="device1" namespace="default" workload="camera"] started`
+		second := `<14>1 2022-05-01T10:00:00Z device1 camera - - [flotta@32473 device_id="device1" namespace="default" workload="camera"] say "hi"]`
+		Eventually(received).Should(Receive(Equal(
+			"126 " + first + "128 " + second,
+		)))
+	})
+
+	It("should send a datagram per entry over UDP", func() {
+		// given
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		defer conn.Close()
+		sink, err := devicelogs.NewSyslogSink(conn.LocalAddr().String(), "udp")
+		Expect(err).NotTo(HaveOccurred())
+
+		// when
+		err = sink.Forward(context.TODO(), "device1", "default", &models.LogsMessage{
+			Entries: []*models.LogEntry{{Timestamp: timestamp, Message: "booted"}},
+		})
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		buf := make([]byte, 1024)
+		Expect(conn.SetReadDeadline(time.Now().Add(5 * time.Second))).To(Succeed())
+		n, _, err := conn.ReadFrom(buf)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(buf[:n])).To(Equal(
+			`<14>1 2022-05-01T10:00:00Z device1 system - - [flotta@32473 device_id="device1" namespace="default" workload="system"] booted`))
+	})
+
+	It("should reject unknown protocols", func() {
+		// when
+		_, err := devicelogs.NewSyslogSink("localhost:514", "http")
+
+		// then
+		Expect(err).To(HaveOccurred())
+	})
+})
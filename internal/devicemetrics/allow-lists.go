@@ -3,7 +3,10 @@ package devicemetrics
 import (
 	"context"
 	"fmt"
+	"regexp"
+	"strings"
 
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
 	"github.com/project-flotta/flotta-operator/internal/k8sclient"
 	"github.com/project-flotta/flotta-operator/models"
 	corev1 "k8s.io/api/core/v1"
@@ -14,6 +17,9 @@ import (
 //go:generate mockgen -package=devicemetrics -destination=mock_allowlists.go . AllowListGenerator
 type AllowListGenerator interface {
 	GenerateFromConfigMap(ctx context.Context, name, namespace string) (*models.MetricsAllowList, error)
+
+	// GenerateFromAllowList resolves the MetricsAllowList with the allow-lists it extends and includes
+	GenerateFromAllowList(ctx context.Context, name, namespace string) (*models.MetricsAllowList, error)
 }
 
 type allowListGenerator struct {
@@ -41,3 +47,139 @@ func (g *allowListGenerator) GenerateFromConfigMap(ctx context.Context, name, na
 	}
 	return mal, nil
 }
+
+func (g *allowListGenerator) GenerateFromAllowList(ctx context.Context, name, namespace string) (*models.MetricsAllowList, error) {
+	return g.resolve(ctx, namespace, name, nil)
+}
+
+// resolve returns the metrics of the allow-list: the ones of the allow-list it extends, then the ones of the
+// allow-lists it includes, without the excluded names, then its own. path holds the allow-lists being resolved.
+func (g *allowListGenerator) resolve(ctx context.Context, namespace, name string, path []string) (*models.MetricsAllowList, error) {
+	for _, resolving := range path {
+		if resolving == name {
+			return nil, fmt.Errorf("metrics allow-lists form a cycle: %s -> %s", strings.Join(path, " -> "), name)
+		}
+	}
+	path = append(path, name)
+
+	allowList := v1alpha1.MetricsAllowList{}
+	err := g.client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, &allowList)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get metrics allow-list %s: %w", name, err)
+	}
+
+	var inherited []*models.MetricsAllowList
+	references := allowList.Spec.Includes
+	if allowList.Spec.Extends != nil {
+		references = append([]v1alpha1.NameRef{*allowList.Spec.Extends}, references...)
+	}
+	for _, reference := range references {
+		resolved, err := g.resolve(ctx, namespace, reference.Name, path)
+		if err != nil {
+			return nil, err
+		}
+		inherited = append(inherited, resolved)
+	}
+
+	own := &models.MetricsAllowList{Names: allowList.Spec.Names}
+	for _, pattern := range allowList.Spec.Patterns {
+		expression, err := patternExpression(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("metrics allow-list %s: %w", name, err)
+		}
+		own.Patterns = append(own.Patterns, expression)
+	}
+	result := Override(Merge(inherited...), &v1alpha1.MetricsAllowListOverrides{Remove: allowList.Spec.Exclude})
+	return Merge(result, own), nil
+}
+
+// Merge returns the names and patterns of the allow-lists, in order and without duplicates
+func Merge(allowLists ...*models.MetricsAllowList) *models.MetricsAllowList {
+	result := &models.MetricsAllowList{}
+	names := map[string]struct{}{}
+	patterns := map[string]struct{}{}
+	for _, allowList := range allowLists {
+		if allowList == nil {
+			continue
+		}
+		result.Names = appendNew(result.Names, names, allowList.Names...)
+		result.Patterns = appendNew(result.Patterns, patterns, allowList.Patterns...)
+	}
+	return result
+}
+
+// Override returns the allow-list with the names added and removed by the overrides
+func Override(allowList *models.MetricsAllowList, overrides *v1alpha1.MetricsAllowListOverrides) *models.MetricsAllowList {
+	if overrides == nil {
+		return allowList
+	}
+	removed := map[string]struct{}{}
+	for _, name := range overrides.Remove {
+		removed[name] = struct{}{}
+	}
+	result := &models.MetricsAllowList{Patterns: allowList.Patterns}
+	for _, name := range allowList.Names {
+		if _, ok := removed[name]; !ok {
+			result.Names = append(result.Names, name)
+		}
+	}
+	return Merge(result, &models.MetricsAllowList{Names: overrides.Add})
+}
+
+func appendNew(values []string, seen map[string]struct{}, added ...string) []string {
+	for _, value := range added {
+		if _, ok := seen[value]; !ok {
+			seen[value] = struct{}{}
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// patternExpression returns the regular expression matching the names the pattern matches, anchored to match whole
+// names
+func patternExpression(pattern v1alpha1.MetricNamePattern) (string, error) {
+	expression := pattern.Pattern
+	if pattern.Type != v1alpha1.MetricNameRegex {
+		expression = globExpression(pattern.Pattern)
+	}
+	expression = "^(?:" + expression + ")$"
+	if _, err := regexp.Compile(expression); err != nil {
+		return "", fmt.Errorf("pattern '%s' is not valid: %v", pattern.Pattern, err)
+	}
+	return expression, nil
+}
+
+// globExpression converts a path.Match pattern to a regular expression
+func globExpression(glob string) string {
+	var expression strings.Builder
+	inClass := false
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case inClass:
+			if c == ']' {
+				inClass = false
+			}
+			if c == '\\' && i+1 < len(glob) {
+				i++
+				expression.WriteString(regexp.QuoteMeta(string(glob[i])))
+				continue
+			}
+			expression.WriteByte(c)
+		case c == '*':
+			expression.WriteString(".*")
+		case c == '?':
+			expression.WriteString(".")
+		case c == '[':
+			inClass = true
+			expression.WriteByte(c)
+		case c == '\\' && i+1 < len(glob):
+			i++
+			expression.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			expression.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return expression.String()
+}
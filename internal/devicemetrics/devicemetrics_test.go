@@ -6,8 +6,10 @@ import (
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
 	"github.com/project-flotta/flotta-operator/internal/devicemetrics"
 	"github.com/project-flotta/flotta-operator/internal/k8sclient"
+	"github.com/project-flotta/flotta-operator/models"
 	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -109,6 +111,131 @@ var _ = Describe("Device Metrics", func() {
 			Expect(err).To(HaveOccurred())
 		})
 	})
+
+	Context("MetricsAllowList resolution", func() {
+		var (
+			alGenerator devicemetrics.AllowListGenerator
+			allowLists  map[string]v1alpha1.MetricsAllowListSpec
+		)
+
+		BeforeEach(func() {
+			alGenerator = devicemetrics.NewAllowListGenerator(k8sClient)
+			allowLists = map[string]v1alpha1.MetricsAllowListSpec{}
+			k8sClient.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.AssignableToTypeOf(&v1alpha1.MetricsAllowList{})).
+				DoAndReturn(func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+					spec, ok := allowLists[key.Name]
+					if !ok {
+						return errors.NotFound("Not found")
+					}
+					allowList := obj.(*v1alpha1.MetricsAllowList)
+					allowList.Name = key.Name
+					allowList.Namespace = key.Namespace
+					allowList.Spec = spec
+					return nil
+				}).AnyTimes()
+		})
+
+		It("should convert glob and regex patterns", func() {
+			// given
+			allowLists["base"] = v1alpha1.MetricsAllowListSpec{
+				Names: []string{"free_mem"},
+				Patterns: []v1alpha1.MetricNamePattern{
+					{Type: v1alpha1.MetricNameGlob, Pattern: "node_cpu_*"},
+					{Type: v1alpha1.MetricNameRegex, Pattern: "go_(gc|memstats)_.+"},
+					{Pattern: "disk.io?"},
+				},
+			}
+
+			// when
+			allowList, err := alGenerator.GenerateFromAllowList(context.TODO(), "base", mapNamespace)
+
+			// then
+			Expect(err).ToNot(HaveOccurred())
+			Expect(allowList.Names).To(Equal([]string{"free_mem"}))
+			Expect(allowList.Patterns).To(Equal([]string{
+				`^(?:node_cpu_.*)$`,
+				`^(?:go_(gc|memstats)_.+)$`,
+				`^(?:disk\.io.)$`,
+			}))
+		})
+
+		It("should compose extended and included allow-lists", func() {
+			// given
+			allowLists["base"] = v1alpha1.MetricsAllowListSpec{Names: []string{"cpu_cores", "free_mem"}}
+			allowLists["disk"] = v1alpha1.MetricsAllowListSpec{
+				Names:    []string{"disk_io", "free_mem"},
+				Patterns: []v1alpha1.MetricNamePattern{{Pattern: "disk_*"}},
+			}
+			allowLists["edge"] = v1alpha1.MetricsAllowListSpec{
+				Names:    []string{"uptime"},
+				Extends:  &v1alpha1.NameRef{Name: "base"},
+				Includes: []v1alpha1.NameRef{{Name: "disk"}},
+				Exclude:  []string{"cpu_cores"},
+			}
+
+			// when
+			allowList, err := alGenerator.GenerateFromAllowList(context.TODO(), "edge", mapNamespace)
+
+			// then
+			Expect(err).ToNot(HaveOccurred())
+			Expect(allowList.Names).To(Equal([]string{"free_mem", "disk_io", "uptime"}))
+			Expect(allowList.Patterns).To(Equal([]string{`^(?:disk_.*)$`}))
+		})
+
+		It("should fail when allow-lists form a cycle", func() {
+			// given
+			allowLists["a"] = v1alpha1.MetricsAllowListSpec{Extends: &v1alpha1.NameRef{Name: "b"}}
+			allowLists["b"] = v1alpha1.MetricsAllowListSpec{Includes: []v1alpha1.NameRef{{Name: "a"}}}
+
+			// when
+			_, err := alGenerator.GenerateFromAllowList(context.TODO(), "a", mapNamespace)
+
+			// then
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("a -> b -> a"))
+		})
+
+		It("should fail when an extended allow-list is missing", func() {
+			// given
+			allowLists["edge"] = v1alpha1.MetricsAllowListSpec{Extends: &v1alpha1.NameRef{Name: "base"}}
+
+			// when
+			_, err := alGenerator.GenerateFromAllowList(context.TODO(), "edge", mapNamespace)
+
+			// then
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("Overrides", func() {
+		It("should add and remove names", func() {
+			// given
+			allowList := &models.MetricsAllowList{Names: []string{"cpu_cores", "free_mem"}, Patterns: []string{"^(?:disk_.*)$"}}
+
+			// when
+			result := devicemetrics.Override(allowList, &v1alpha1.MetricsAllowListOverrides{
+				Add:    []string{"uptime", "free_mem"},
+				Remove: []string{"cpu_cores"},
+			})
+
+			// then
+			Expect(result.Names).To(Equal([]string{"free_mem", "uptime"}))
+			Expect(result.Patterns).To(Equal(allowList.Patterns))
+		})
+
+		It("should merge allow-lists without duplicates", func() {
+			// when
+			result := devicemetrics.Merge(
+				&models.MetricsAllowList{Names: []string{"cpu_cores"}, Patterns: []string{"^(?:a)$"}},
+				nil,
+				&models.MetricsAllowList{Names: []string{"cpu_cores", "free_mem"}, Patterns: []string{"^(?:a)$"}},
+			)
+
+			// then
+			Expect(result.Names).To(Equal([]string{"cpu_cores", "free_mem"}))
+			Expect(result.Patterns).To(Equal([]string{"^(?:a)$"}))
+		})
+	})
 })
 
 func configMapGenerator(name, namespace string, data map[string]string) func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
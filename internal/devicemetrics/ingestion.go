@@ -0,0 +1,317 @@
+package devicemetrics
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"golang.org/x/time/rate"
+
+	"github.com/project-flotta/flotta-operator/models"
+)
+
+const (
+	// DeviceIDLabel, NamespaceLabel and WorkloadLabel are set on the samples ingested from devices
+	DeviceIDLabel  = "device_id"
+	NamespaceLabel = "namespace"
+	WorkloadLabel  = "workload"
+
+	// exportedLabelPrefix is prepended to the labels of ingested samples that clash with the labels set by the operator
+	exportedLabelPrefix = "exported_"
+)
+
+var (
+	// ErrRateLimited is returned when a device sends batches faster than allowed
+	ErrRateLimited = errors.New("metrics batch rate limit exceeded")
+
+	// ErrInvalidBatch is returned when a batch cannot be parsed or exceeds the series limit
+	ErrInvalidBatch = errors.New("invalid metrics batch")
+)
+
+//go:generate mockgen -package=devicemetrics -destination=mock_ingester.go . Ingester
+type Ingester interface {
+	// Ingest replaces the samples the device sent before for the same workload with the samples of the message
+	Ingest(deviceID, namespace string, message *models.MetricsMessage) error
+}
+
+// IngestionConfig limits the samples ingested from each device
+type IngestionConfig struct {
+	// Retention is how long the samples of a device are exposed after the last batch of the device
+	Retention time.Duration
+
+	// BatchesPerMinute is the number of batches each device can send per minute
+	BatchesPerMinute uint
+
+	// MaxSeries is the number of series exposed for each device; 0 does not limit the series
+	MaxSeries uint
+}
+
+// source is a device workload, or the device itself for system metrics
+type source struct {
+	namespace string
+	deviceID  string
+	workload  string
+}
+
+type batch struct {
+	families []*dto.MetricFamily
+	series   int
+	received time.Time
+}
+
+type device struct {
+	limiter *rate.Limiter
+	last    time.Time
+}
+
+// Store keeps the latest batch of samples of each device workload and exposes them for Prometheus federation.
+// It implements prometheus.Gatherer.
+type Store struct {
+	config  IngestionConfig
+	lock    sync.Mutex
+	batches map[source]*batch
+	devices map[source]*device
+	now     func() time.Time
+}
+
+func NewStore(config IngestionConfig) *Store {
+	return &Store{
+		config:  config,
+		batches: map[source]*batch{},
+		devices: map[source]*device{},
+		now:     time.Now,
+	}
+}
+
+// Handler serves the ingested samples in the Prometheus exposition format
+func (s *Store) Handler() http.Handler {
+	return promhttp.HandlerFor(s, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError})
+}
+
+func (s *Store) Ingest(deviceID, namespace string, message *models.MetricsMessage) error {
+	families, err := parse(message)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBatch, err)
+	}
+	labels := []*dto.LabelPair{
+		labelPair(DeviceIDLabel, deviceID),
+		labelPair(NamespaceLabel, namespace),
+	}
+	if message.Workload != "" {
+		labels = append(labels, labelPair(WorkloadLabel, message.Workload))
+	}
+	series := relabel(families, labels)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := s.now()
+	s.expire(now)
+
+	deviceKey := source{namespace: namespace, deviceID: deviceID}
+	d, ok := s.devices[deviceKey]
+	if !ok {
+		d = &device{limiter: s.newLimiter()}
+		s.devices[deviceKey] = d
+	}
+	if !d.limiter.AllowN(now, 1) {
+		return ErrRateLimited
+	}
+
+	key := source{namespace: namespace, deviceID: deviceID, workload: message.Workload}
+	total := series
+	for k, b := range s.batches {
+		if k != key && k.namespace == namespace && k.deviceID == deviceID {
+			total += b.series
+		}
+	}
+	if s.config.MaxSeries > 0 && total > int(s.config.MaxSeries) {
+		return fmt.Errorf("%w: device exposes %d series, more than %d", ErrInvalidBatch, total, s.config.MaxSeries)
+	}
+
+	b := &batch{series: series, received: now}
+	for _, family := range families {
+		b.families = append(b.families, family)
+	}
+	s.batches[key] = b
+	d.last = now
+	return nil
+}
+
+// newLimiter allows BatchesPerMinute batches per minute, all of them at once at most; 0 does not limit the batches
+func (s *Store) newLimiter() *rate.Limiter {
+	if s.config.BatchesPerMinute == 0 {
+		return rate.NewLimiter(rate.Inf, 0)
+	}
+	perMinute := int(s.config.BatchesPerMinute)
+	return rate.NewLimiter(rate.Every(time.Minute/time.Duration(perMinute)), perMinute)
+}
+
+// Gather returns the samples of all the devices, merged by metric name
+func (s *Store) Gather() ([]*dto.MetricFamily, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.expire(s.now())
+
+	merged := map[string]*dto.MetricFamily{}
+	var conflicts prometheus.MultiError
+	for key, b := range s.batches {
+		for _, family := range b.families {
+			current, ok := merged[family.GetName()]
+			if !ok {
+				current = &dto.MetricFamily{Name: family.Name, Help: family.Help, Type: family.Type}
+				merged[family.GetName()] = current
+			}
+			if current.GetType() != family.GetType() {
+				conflicts = append(conflicts, fmt.Errorf("metric %s of device %s/%s has type %s, other devices %s",
+					family.GetName(), key.namespace, key.deviceID, family.GetType(), current.GetType()))
+				continue
+			}
+			current.Metric = append(current.Metric, family.Metric...)
+		}
+	}
+
+	result := make([]*dto.MetricFamily, 0, len(merged))
+	for _, family := range merged {
+		result = append(result, family)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].GetName() < result[j].GetName()
+	})
+	return result, conflicts.MaybeUnwrap()
+}
+
+// expire drops the samples of the devices that did not send any batch during the retention
+func (s *Store) expire(now time.Time) {
+	for key, b := range s.batches {
+		if now.Sub(b.received) > s.config.Retention {
+			delete(s.batches, key)
+		}
+	}
+	for key, d := range s.devices {
+		if now.Sub(d.last) > s.config.Retention {
+			delete(s.devices, key)
+		}
+	}
+}
+
+func parse(message *models.MetricsMessage) (map[string]*dto.MetricFamily, error) {
+	data := message.Data
+	switch message.Format {
+	case models.MetricsMessageFormatPrometheusText, "":
+	case models.MetricsMessageFormatOpenmetricsText:
+		data = fromOpenMetrics(data)
+	default:
+		return nil, fmt.Errorf("unsupported format %s", message.Format)
+	}
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(strings.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	for name, family := range families {
+		if len(family.Metric) == 0 {
+			delete(families, name)
+		}
+	}
+	return families, nil
+}
+
+// fromOpenMetrics converts OpenMetrics text to the Prometheus text format: counters are named after their _total
+// samples, types without a Prometheus equivalent are untyped, and exemplars and timestamps are dropped
+func fromOpenMetrics(data string) string {
+	lines := strings.Split(data, "\n")
+	counters := map[string]bool{}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 4 && fields[0] == "#" && fields[1] == "TYPE" && fields[3] == "counter" {
+			counters[fields[2]] = true
+		}
+	}
+
+	var result strings.Builder
+	for _, line := range lines {
+		if strings.HasPrefix(line, "#") {
+			fields := strings.SplitN(line, " ", 4)
+			if len(fields) >= 3 && (fields[1] == "TYPE" || fields[1] == "HELP") {
+				if counters[fields[2]] {
+					fields[2] += "_total"
+				}
+				if fields[1] == "TYPE" && len(fields) == 4 {
+					switch fields[3] {
+					case "unknown", "info", "stateset", "gaugehistogram":
+						fields[3] = "untyped"
+					}
+				}
+				line = strings.Join(fields, " ")
+			}
+		} else {
+			line = dropExemplarAndTimestamp(line)
+		}
+		result.WriteString(line)
+		result.WriteString("\n")
+	}
+	return result.String()
+}
+
+func dropExemplarAndTimestamp(line string) string {
+	if i := strings.Index(line, " # {"); i >= 0 {
+		line = line[:i]
+	}
+	labelsEnd := strings.LastIndex(line, "}") + 1
+	fields := strings.Fields(line[labelsEnd:])
+	if labelsEnd == 0 {
+		// name value [timestamp]
+		if len(fields) == 3 {
+			return fields[0] + " " + fields[1]
+		}
+		return line
+	}
+	if len(fields) == 2 {
+		return line[:labelsEnd] + " " + fields[0]
+	}
+	return line
+}
+
+// relabel sets the labels on every sample, renaming the sample labels with the same names, drops the sample
+// timestamps, and returns the number of series
+func relabel(families map[string]*dto.MetricFamily, labels []*dto.LabelPair) int {
+	reserved := map[string]bool{}
+	for _, label := range labels {
+		reserved[label.GetName()] = true
+	}
+	series := 0
+	for _, family := range families {
+		for _, metric := range family.Metric {
+			for _, label := range metric.Label {
+				if reserved[label.GetName()] {
+					label.Name = stringPtr(exportedLabelPrefix + label.GetName())
+				}
+			}
+			for _, label := range labels {
+				metric.Label = append(metric.Label, labelPair(label.GetName(), label.GetValue()))
+			}
+			sort.Slice(metric.Label, func(i, j int) bool {
+				return metric.Label[i].GetName() < metric.Label[j].GetName()
+			})
+			metric.TimestampMs = nil
+			series++
+		}
+	}
+	return series
+}
+
+func labelPair(name, value string) *dto.LabelPair {
+	return &dto.LabelPair{Name: stringPtr(name), Value: stringPtr(value)}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,197 @@
+package devicemetrics_test
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/project-flotta/flotta-operator/internal/devicemetrics"
+	"github.com/project-flotta/flotta-operator/models"
+)
+
+const (
+	prometheusText = `# HELP cpu_seconds CPU time
+# TYPE cpu_seconds counter
+cpu_seconds{mode="user"} 10 1650000000000
+cpu_seconds{mode="system",device_id="spoofed"} 5
+# TYPE free_mem gauge
+free_mem 1024
+`
+
+	openMetricsText = `# TYPE requests counter
+# HELP requests Requests served
+requests_total{code="200"} 7 1650000000.5 # {trace_id="abc"} 1.0
+# TYPE build info
+build_info{version="1.0"} 1
+# EOF
+`
+)
+
+var _ = Describe("Metrics ingestion", func() {
+	var (
+		store *devicemetrics.Store
+	)
+
+	gather := func() string {
+		families, err := store.Gather()
+		Expect(err).NotTo(HaveOccurred())
+		var out strings.Builder
+		for _, family := range families {
+			_, err := expfmt.MetricFamilyToText(&out, family)
+			Expect(err).NotTo(HaveOccurred())
+		}
+		return out.String()
+	}
+
+	BeforeEach(func() {
+		store = devicemetrics.NewStore(devicemetrics.IngestionConfig{
+			Retention:        time.Minute,
+			BatchesPerMinute: 2,
+			MaxSeries:        5,
+		})
+	})
+
+	It("should relabel Prometheus text samples with the device", func() {
+		// when
+		err := store.Ingest("device1", "default", &models.MetricsMessage{
+			Format: models.MetricsMessageFormatPrometheusText,
+			Data:   prometheusText,
+		})
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(gather()).To(Equal(`# HELP cpu_seconds CPU time
+# TYPE cpu_seconds counter
+cpu_seconds{device_id="device1",mode="user",namespace="default"} 10
+cpu_seconds{device_id="device1",exported_device_id="spoofed",mode="system",namespace="default"} 5
+# TYPE free_mem gauge
+free_mem{device_id="device1",namespace="default"} 1024
+`))
+	})
+
+	It("should convert OpenMetrics text samples", func() {
+		// when
+		err := store.Ingest("device1", "default", &models.MetricsMessage{
+			Format:   models.MetricsMessageFormatOpenmetricsText,
+			Data:     openMetricsText,
+			Workload: "camera",
+		})
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(gather()).To(Equal(`# TYPE build_info untyped
+build_info{device_id="device1",namespace="default",version="1.0",workload="camera"} 1
+# HELP requests_total Requests served
+# TYPE requests_total counter
+requests_total{code="200",device_id="device1",namespace="default",workload="camera"} 7
+`))
+	})
+
+	It("should merge the samples of several devices", func() {
+		// given
+		message := &models.MetricsMessage{Data: "free_mem 1\n"}
+		Expect(store.Ingest("device1", "default", message)).To(Succeed())
+
+		// when
+		err := store.Ingest("device2", "default", message)
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		families, err := store.Gather()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(families).To(HaveLen(1))
+		Expect(families[0].Metric).To(HaveLen(2))
+	})
+
+	It("should replace the previous batch of the workload", func() {
+		// given
+		Expect(store.Ingest("device1", "default", &models.MetricsMessage{Data: "free_mem 1\n"})).To(Succeed())
+
+		// when
+		err := store.Ingest("device1", "default", &models.MetricsMessage{Data: "used_mem 1\n"})
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(gather()).To(Equal(`# TYPE used_mem untyped
+used_mem{device_id="device1",namespace="default"} 1
+`))
+	})
+
+	It("should reject invalid batches", func() {
+		// when
+		err := store.Ingest("device1", "default", &models.MetricsMessage{Data: "free_mem{ 1\n"})
+
+		// then
+		Expect(errors.Is(err, devicemetrics.ErrInvalidBatch)).To(BeTrue())
+	})
+
+	It("should reject unsupported formats", func() {
+		// when
+		err := store.Ingest("device1", "default", &models.MetricsMessage{Format: "remote-write", Data: "free_mem 1\n"})
+
+		// then
+		Expect(errors.Is(err, devicemetrics.ErrInvalidBatch)).To(BeTrue())
+	})
+
+	It("should reject batches exceeding the series of the device", func() {
+		// given
+		Expect(store.Ingest("device1", "default", &models.MetricsMessage{Data: prometheusText})).To(Succeed())
+
+		// when
+		err := store.Ingest("device1", "default", &models.MetricsMessage{
+			Workload: "camera",
+			Data:     "a 1\nb 1\nc 1\n",
+		})
+
+		// then
+		Expect(errors.Is(err, devicemetrics.ErrInvalidBatch)).To(BeTrue())
+	})
+
+	It("should rate limit the batches of each device", func() {
+		// given
+		message := &models.MetricsMessage{Data: "free_mem 1\n"}
+		Expect(store.Ingest("device1", "default", message)).To(Succeed())
+		Expect(store.Ingest("device1", "default", message)).To(Succeed())
+
+		// when
+		err := store.Ingest("device1", "default", message)
+
+		// then
+		Expect(err).To(Equal(devicemetrics.ErrRateLimited))
+		Expect(store.Ingest("device2", "default", message)).To(Succeed())
+	})
+
+	It("should expire the samples of devices not sending batches", func() {
+		// given
+		store = devicemetrics.NewStore(devicemetrics.IngestionConfig{Retention: time.Millisecond})
+		Expect(store.Ingest("device1", "default", &models.MetricsMessage{Data: "free_mem 1\n"})).To(Succeed())
+
+		// when
+		time.Sleep(5 * time.Millisecond)
+
+		// then
+		families, err := store.Gather()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(families).To(BeEmpty())
+	})
+
+	It("should report metrics with conflicting types", func() {
+		// given
+		Expect(store.Ingest("device1", "default", &models.MetricsMessage{Data: "# TYPE free_mem gauge\nfree_mem 1\n"})).To(Succeed())
+		Expect(store.Ingest("device2", "default", &models.MetricsMessage{Data: "# TYPE free_mem counter\nfree_mem 1\n"})).To(Succeed())
+
+		// when
+		families, err := store.Gather()
+
+		// then
+		Expect(err).To(HaveOccurred())
+		Expect(families).To(HaveLen(1))
+		Expect(families[0].Metric).To(HaveLen(1))
+		Expect(families[0].GetType()).To(BeElementOf(dto.MetricType_GAUGE, dto.MetricType_COUNTER))
+	})
+})
@@ -49,3 +49,18 @@ func (mr *MockAllowListGeneratorMockRecorder) GenerateFromConfigMap(arg0, arg1,
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GenerateFromConfigMap", reflect.TypeOf((*MockAllowListGenerator)(nil).GenerateFromConfigMap), arg0, arg1, arg2)
 }
+
+// GenerateFromAllowList mocks base method.
+func (m *MockAllowListGenerator) GenerateFromAllowList(arg0 context.Context, arg1, arg2 string) (*models.MetricsAllowList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GenerateFromAllowList", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*models.MetricsAllowList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GenerateFromAllowList indicates an expected call of GenerateFromAllowList.
+func (mr *MockAllowListGeneratorMockRecorder) GenerateFromAllowList(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GenerateFromAllowList", reflect.TypeOf((*MockAllowListGenerator)(nil).GenerateFromAllowList), arg0, arg1, arg2)
+}
@@ -0,0 +1,76 @@
+package deviceset
+
+import (
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+)
+
+// EffectiveConfiguration merges the configuration of the EdgeDeviceSet with the configuration of the member device.
+// Device configuration takes precedence:
+//   - heartbeat, storage and maintenance window of the device replace the ones of the set;
+//   - metrics retention and system metrics of the device replace the ones of the set independently;
+//   - log collection entries are merged by name, the entry of the device replaces the one of the set.
+func EffectiveConfiguration(device *v1alpha1.EdgeDevice, set *v1alpha1.EdgeDeviceSet) *v1alpha1.EffectiveConfiguration {
+	deviceSpec := device.Spec.DeepCopy()
+	setSpec := set.Spec.DeepCopy()
+
+	config := v1alpha1.EffectiveConfiguration{
+		DeviceSet:         set.Name,
+		Heartbeat:         setSpec.Heartbeat,
+		Storage:           setSpec.Storage,
+		Metrics:           mergeMetrics(deviceSpec.Metrics, setSpec.Metrics),
+		MaintenanceWindow: setSpec.MaintenanceWindow,
+	}
+	if deviceSpec.Heartbeat != nil {
+		config.Heartbeat = deviceSpec.Heartbeat
+	}
+	if deviceSpec.Storage != nil {
+		config.Storage = deviceSpec.Storage
+	}
+	if deviceSpec.MaintenanceWindow != nil {
+		config.MaintenanceWindow = deviceSpec.MaintenanceWindow
+	}
+
+	if len(deviceSpec.LogCollection)+len(setSpec.LogCollection) > 0 {
+		config.LogCollection = map[string]*v1alpha1.LogCollectionConfig{}
+		for name, logConfig := range setSpec.LogCollection {
+			config.LogCollection[name] = logConfig
+		}
+		for name, logConfig := range deviceSpec.LogCollection {
+			config.LogCollection[name] = logConfig
+		}
+	}
+
+	return &config
+}
+
+// Apply returns a copy of the device specification with the effective configuration applied
+func Apply(spec *v1alpha1.EdgeDeviceSpec, config *v1alpha1.EffectiveConfiguration) *v1alpha1.EdgeDeviceSpec {
+	result := spec.DeepCopy()
+	if config == nil {
+		return result
+	}
+	config = config.DeepCopy()
+	result.Heartbeat = config.Heartbeat
+	result.Storage = config.Storage
+	result.Metrics = config.Metrics
+	result.LogCollection = config.LogCollection
+	result.MaintenanceWindow = config.MaintenanceWindow
+	return result
+}
+
+func mergeMetrics(deviceMetrics, setMetrics *v1alpha1.MetricsConfiguration) *v1alpha1.MetricsConfiguration {
+	if deviceMetrics == nil {
+		return setMetrics
+	}
+	if setMetrics == nil {
+		return deviceMetrics
+	}
+	metrics := *setMetrics
+	if deviceMetrics.Retention != nil {
+		metrics.Retention = deviceMetrics.Retention
+	}
+	if deviceMetrics.SystemMetrics != nil {
+		metrics.SystemMetrics = deviceMetrics.SystemMetrics
+	}
+	return &metrics
+}
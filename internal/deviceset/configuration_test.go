@@ -0,0 +1,132 @@
+package deviceset_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"github.com/project-flotta/flotta-operator/internal/deviceset"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Configuration", func() {
+	var (
+		device *v1alpha1.EdgeDevice
+		set    *v1alpha1.EdgeDeviceSet
+	)
+
+	BeforeEach(func() {
+		device = &v1alpha1.EdgeDevice{
+			ObjectMeta: metav1.ObjectMeta{Name: "device", Namespace: "default"},
+		}
+		set = &v1alpha1.EdgeDeviceSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "set", Namespace: "default"},
+			Spec: v1alpha1.EdgeDeviceSetSpec{
+				Heartbeat: &v1alpha1.HeartbeatConfiguration{PeriodSeconds: 30},
+				Storage:   &v1alpha1.Storage{S3: &v1alpha1.S3Storage{SecretName: "set-secret"}},
+				Metrics: &v1alpha1.MetricsConfiguration{
+					Retention:     &v1alpha1.Retention{MaxHours: 24},
+					SystemMetrics: &v1alpha1.SystemMetricsConfiguration{Interval: 120},
+				},
+				LogCollection: map[string]*v1alpha1.LogCollectionConfig{
+					"syslog": {Kind: "syslog", BufferSize: 10},
+					"remote": {Kind: "syslog", BufferSize: 20},
+				},
+			},
+		}
+	})
+
+	Context("EffectiveConfiguration", func() {
+		It("should use the configuration of the set when the device has none", func() {
+			// when
+			config := deviceset.EffectiveConfiguration(device, set)
+
+			// then
+			Expect(config.DeviceSet).To(Equal("set"))
+			Expect(config.Heartbeat).To(Equal(set.Spec.Heartbeat))
+			Expect(config.Storage).To(Equal(set.Spec.Storage))
+			Expect(config.Metrics).To(Equal(set.Spec.Metrics))
+			Expect(config.LogCollection).To(Equal(set.Spec.LogCollection))
+		})
+
+		It("should prefer the configuration of the device", func() {
+			// given
+			device.Spec.Heartbeat = &v1alpha1.HeartbeatConfiguration{PeriodSeconds: 5}
+			device.Spec.Storage = &v1alpha1.Storage{S3: &v1alpha1.S3Storage{CreateOBC: true}}
+			device.Spec.Metrics = &v1alpha1.MetricsConfiguration{
+				SystemMetrics: &v1alpha1.SystemMetricsConfiguration{Disabled: true},
+			}
+			device.Spec.LogCollection = map[string]*v1alpha1.LogCollectionConfig{
+				"syslog": {Kind: "syslog", BufferSize: 1},
+			}
+
+			// when
+			config := deviceset.EffectiveConfiguration(device, set)
+
+			// then
+			Expect(config.Heartbeat.PeriodSeconds).To(BeEquivalentTo(5))
+			Expect(config.Storage.S3.CreateOBC).To(BeTrue())
+			Expect(config.Storage.S3.SecretName).To(BeEmpty())
+			Expect(config.Metrics.Retention.MaxHours).To(BeEquivalentTo(24))
+			Expect(config.Metrics.SystemMetrics.Disabled).To(BeTrue())
+			Expect(config.Metrics.SystemMetrics.Interval).To(BeZero())
+			Expect(config.LogCollection).To(HaveLen(2))
+			Expect(config.LogCollection["syslog"].BufferSize).To(BeEquivalentTo(1))
+			Expect(config.LogCollection["remote"].BufferSize).To(BeEquivalentTo(20))
+		})
+
+		It("should prefer the maintenance window of the device", func() {
+			// given
+			set.Spec.MaintenanceWindow = &v1alpha1.MaintenanceWindow{Schedule: "0 2 * * *", DurationMinutes: 60}
+
+			// when
+			setConfig := deviceset.EffectiveConfiguration(device, set)
+			device.Spec.MaintenanceWindow = &v1alpha1.MaintenanceWindow{Schedule: "0 3 * * 6", DurationMinutes: 30}
+			deviceConfig := deviceset.EffectiveConfiguration(device, set)
+
+			// then
+			Expect(setConfig.MaintenanceWindow).To(Equal(set.Spec.MaintenanceWindow))
+			Expect(deviceConfig.MaintenanceWindow).To(Equal(device.Spec.MaintenanceWindow))
+		})
+
+		It("should not share data with the set", func() {
+			// when
+			config := deviceset.EffectiveConfiguration(device, set)
+			config.Heartbeat.PeriodSeconds = 1
+			config.LogCollection["syslog"].BufferSize = 1
+
+			// then
+			Expect(set.Spec.Heartbeat.PeriodSeconds).To(BeEquivalentTo(30))
+			Expect(set.Spec.LogCollection["syslog"].BufferSize).To(BeEquivalentTo(10))
+		})
+	})
+
+	Context("Apply", func() {
+		It("should return the device specification when there is no effective configuration", func() {
+			// given
+			device.Spec.Heartbeat = &v1alpha1.HeartbeatConfiguration{PeriodSeconds: 5}
+
+			// when
+			spec := deviceset.Apply(&device.Spec, nil)
+
+			// then
+			Expect(spec).To(Equal(&device.Spec))
+		})
+
+		It("should replace the configuration of the device", func() {
+			// given
+			device.Spec.OsInformation = &v1alpha1.OsInformation{CommitID: "commit"}
+			device.Spec.Heartbeat = &v1alpha1.HeartbeatConfiguration{PeriodSeconds: 5}
+			config := deviceset.EffectiveConfiguration(device, set)
+
+			// when
+			spec := deviceset.Apply(&device.Spec, config)
+
+			// then
+			Expect(spec.OsInformation).To(Equal(device.Spec.OsInformation))
+			Expect(spec.Heartbeat.PeriodSeconds).To(BeEquivalentTo(5))
+			Expect(spec.Storage).To(Equal(set.Spec.Storage))
+			Expect(spec.Metrics).To(Equal(set.Spec.Metrics))
+			Expect(spec.LogCollection).To(Equal(set.Spec.LogCollection))
+		})
+	})
+})
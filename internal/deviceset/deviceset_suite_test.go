@@ -0,0 +1,13 @@
+package deviceset_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestDeviceSet(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "DeviceSet Spec")
+}
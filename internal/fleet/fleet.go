@@ -0,0 +1,364 @@
+package fleet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"github.com/project-flotta/flotta-operator/internal/capacity"
+	"github.com/project-flotta/flotta-operator/internal/labels"
+	"github.com/project-flotta/flotta-operator/internal/matching"
+	"github.com/project-flotta/flotta-operator/internal/placement"
+	"github.com/project-flotta/flotta-operator/internal/repository/edgedeployment"
+	"github.com/project-flotta/flotta-operator/internal/repository/edgedevice"
+	"github.com/project-flotta/flotta-operator/internal/repository/edgedevicemigration"
+	"github.com/project-flotta/flotta-operator/internal/twin"
+	"github.com/project-flotta/flotta-operator/internal/yggdrasil"
+	"github.com/project-flotta/flotta-operator/models"
+)
+
+const (
+	// defaultHeartbeatPeriodSeconds is the heartbeat period of devices without heartbeat configuration
+	defaultHeartbeatPeriodSeconds = 60
+
+	// offlineHeartbeats is the number of heartbeat periods without heartbeat after which a device is offline
+	offlineHeartbeats = 3
+)
+
+// ConfigurationRenderer renders the configuration delivered to a device
+type ConfigurationRenderer interface {
+	RenderConfiguration(ctx context.Context, name string, namespace string) (*models.DeviceConfigurationMessage, []yggdrasil.ResolutionError, error)
+}
+
+// Commands are the fleet operations of the CLI, run in one namespace
+type Commands struct {
+	Namespace                     string
+	EdgeDeviceRepository          edgedevice.Repository
+	EdgeDeploymentRepository      edgedeployment.Repository
+	EdgeDeviceMigrationRepository edgedevicemigration.Repository
+	Renderer                      ConfigurationRenderer
+	Out                           io.Writer
+}
+
+// ListDevices lists the devices with their online state, last seen time, configuration sync state and the phases of
+// their deployments
+func (c *Commands) ListDevices(ctx context.Context) error {
+	devices, err := c.EdgeDeviceRepository.ListForSelector(ctx, &metav1.LabelSelector{}, c.Namespace)
+	if err != nil {
+		return err
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Name < devices[j].Name })
+
+	now := time.Now()
+	w := tabwriter.NewWriter(c.Out, 0, 4, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tPHASE\tONLINE\tLAST SEEN\tSYNCED\tDEPLOYMENTS")
+	for i := range devices {
+		device := &devices[i]
+		fmt.Fprintf(w, "%s\t%s\t%t\t%s\t%s\t%s\n",
+			device.Name, valueOrNone(device.Status.Phase), IsOnline(device, now), lastSeen(device, now),
+			configurationSynced(device, now), deployments(device))
+	}
+	return w.Flush()
+}
+
+// IsOnline tells whether the device has sent a heartbeat within the last heartbeat periods
+func IsOnline(device *v1alpha1.EdgeDevice, now time.Time) bool {
+	if device.Status.LastSeenTime.IsZero() {
+		return false
+	}
+	period := time.Duration(heartbeatPeriodSeconds(device)) * time.Second
+	return now.Sub(device.Status.LastSeenTime.Time) <= offlineHeartbeats*period
+}
+
+func heartbeatPeriodSeconds(device *v1alpha1.EdgeDevice) int64 {
+	heartbeat := device.Spec.Heartbeat
+	if effective := device.Status.EffectiveConfiguration; effective != nil && effective.Heartbeat != nil {
+		heartbeat = effective.Heartbeat
+	}
+	if heartbeat == nil || heartbeat.PeriodSeconds <= 0 {
+		return defaultHeartbeatPeriodSeconds
+	}
+	return heartbeat.PeriodSeconds
+}
+
+func lastSeen(device *v1alpha1.EdgeDevice, now time.Time) string {
+	if device.Status.LastSeenTime.IsZero() {
+		return "<never>"
+	}
+	return now.Sub(device.Status.LastSeenTime.Time).Round(time.Second).String() + " ago"
+}
+
+// configurationSynced tells whether the device runs its desired configuration and, when it does not, for how long
+func configurationSynced(device *v1alpha1.EdgeDevice, now time.Time) string {
+	condition := meta.FindStatusCondition(device.Status.Conditions, v1alpha1.ConfigurationSyncedCondition)
+	if condition == nil {
+		return "<unknown>"
+	}
+	if condition.Status == metav1.ConditionTrue {
+		return "true"
+	}
+	return fmt.Sprintf("false (%s)", now.Sub(condition.LastTransitionTime.Time).Round(time.Second))
+}
+
+func deployments(device *v1alpha1.EdgeDevice) string {
+	var result []string
+	for _, deployment := range device.Status.Deployments {
+		result = append(result, fmt.Sprintf("%s:%s", deployment.Name, valueOrNone(string(deployment.Phase))))
+	}
+	return valueOrNone(strings.Join(result, ","))
+}
+
+func valueOrNone(value string) string {
+	if value == "" {
+		return "<none>"
+	}
+	return value
+}
+
+// ApproveMigration approves the EdgeDeviceMigration
+func (c *Commands) ApproveMigration(ctx context.Context, name string) error {
+	edgeDeviceMigration, err := c.EdgeDeviceMigrationRepository.Read(ctx, name, c.Namespace)
+	if err != nil {
+		return err
+	}
+	if !edgeDeviceMigration.Spec.Approved {
+		approved := edgeDeviceMigration.DeepCopy()
+		approved.Spec.Approved = true
+		err = c.EdgeDeviceMigrationRepository.Patch(ctx, edgeDeviceMigration, approved)
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(c.Out, "edgedevicemigration/%s approved\n", name)
+	return nil
+}
+
+// ApproveRegistration approves the registration of a device registered with a RegistrationToken requiring approval:
+// the device gets its certificate when it retries its registration
+func (c *Commands) ApproveRegistration(ctx context.Context, deviceName string) error {
+	device, err := c.EdgeDeviceRepository.Read(ctx, deviceName, c.Namespace)
+	if err != nil {
+		return err
+	}
+	if !device.Spec.RegistrationApproved {
+		approved := device.DeepCopy()
+		approved.Spec.RegistrationApproved = true
+		err = c.EdgeDeviceRepository.Patch(ctx, device, approved)
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(c.Out, "edgedevice/%s registration approved\n", deviceName)
+	return nil
+}
+
+// ShowConfiguration prints the configuration delivered to the device, with its secret values redacted, followed by
+// the items of the configuration that cannot be resolved
+func (c *Commands) ShowConfiguration(ctx context.Context, deviceName string) error {
+	dc, resolutionErrors, err := c.Renderer.RenderConfiguration(ctx, deviceName, c.Namespace)
+	if err != nil {
+		return err
+	}
+	yggdrasil.Redact(dc)
+	data, err := json.MarshalIndent(dc, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.Out, string(data))
+	if len(resolutionErrors) > 0 {
+		fmt.Fprintln(c.Out, "Unresolved items, not delivered to the device until they are fixed:")
+		for _, resolutionError := range resolutionErrors {
+			fmt.Fprintf(c.Out, "  %s: %s\n", resolutionError.Item, resolutionError.Error)
+		}
+	}
+	return nil
+}
+
+// ShowTwin prints the versions of the desired properties of the device and, for each desired or reported property,
+// its desired and reported values
+func (c *Commands) ShowTwin(ctx context.Context, deviceName string) error {
+	device, err := c.EdgeDeviceRepository.Read(ctx, deviceName, c.Namespace)
+	if err != nil {
+		return err
+	}
+	var desired, reported map[string]string
+	if device.Spec.Twin != nil {
+		desired = device.Spec.Twin.Desired
+	}
+	status := device.Status.Twin
+	if status == nil {
+		status = &v1alpha1.DeviceTwinStatus{}
+	}
+	reported = status.Reported
+	desiredVersion := twin.Version(desired)
+
+	fmt.Fprintf(c.Out, "Desired version:          %s\n", valueOrNone(desiredVersion))
+	fmt.Fprintf(c.Out, "Applied desired version:  %s\n", valueOrNone(status.AppliedDesiredVersion))
+	fmt.Fprintf(c.Out, "Reported version:         %s\n", valueOrNone(status.ReportedVersion))
+	if status.ReportedChangeTime != nil {
+		fmt.Fprintf(c.Out, "Reported changed:         %s ago\n", time.Since(status.ReportedChangeTime.Time).Round(time.Second))
+	}
+	diff := twin.Diff(desired, reported)
+	fmt.Fprintf(c.Out, "In sync:                  %t\n", status.AppliedDesiredVersion == desiredVersion && len(diff) == 0)
+
+	names := make([]string, 0, len(desired)+len(reported))
+	for name := range desired {
+		names = append(names, name)
+	}
+	for name := range reported {
+		if _, ok := desired[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+	fmt.Fprintln(c.Out)
+	w := tabwriter.NewWriter(c.Out, 0, 4, 3, ' ', 0)
+	fmt.Fprintln(w, "PROPERTY\tDESIRED\tREPORTED\tIN SYNC")
+	for _, name := range names {
+		desiredValue, isDesired := desired[name]
+		reportedValue, isReported := reported[name]
+		inSync := "-"
+		if isDesired {
+			inSync = fmt.Sprint(isReported && reportedValue == desiredValue)
+		} else {
+			desiredValue = "<none>"
+		}
+		if !isReported {
+			reportedValue = "<none>"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, desiredValue, reportedValue, inSync)
+	}
+	return w.Flush()
+}
+
+// Disconnect deletes the device: its workloads are removed and it is sent the disconnect command
+func (c *Commands) Disconnect(ctx context.Context, deviceName string) error {
+	device, err := c.EdgeDeviceRepository.Read(ctx, deviceName, c.Namespace)
+	if err != nil {
+		return err
+	}
+	err = c.EdgeDeviceRepository.Delete(ctx, device)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.Out, "edgedevice/%s deleted, the device is disconnected once its workloads are removed\n", deviceName)
+	return nil
+}
+
+// Decommission requests the decommissioning of the device
+func (c *Commands) Decommission(ctx context.Context, deviceName string, force bool, deleteData bool) error {
+	device, err := c.EdgeDeviceRepository.Read(ctx, deviceName, c.Namespace)
+	if err != nil {
+		return err
+	}
+	decommission := &v1alpha1.Decommission{Force: force, DataRetention: v1alpha1.RetainData}
+	if deleteData {
+		decommission.DataRetention = v1alpha1.DeleteData
+	}
+	updated := device.DeepCopy()
+	updated.Spec.Decommission = decommission
+	err = c.EdgeDeviceRepository.Patch(ctx, device, updated)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.Out, "edgedevice/%s decommissioning\n", deviceName)
+	return nil
+}
+
+// ExplainMatch prints why the deployment is or is not deployed to the device: the requirements of the deployment on
+// the devices it targets and, for a targeted device the deployment is not deployed to, the placement constraint or
+// capacity it violates
+func (c *Commands) ExplainMatch(ctx context.Context, deploymentName string, deviceName string) error {
+	edgeDeployment, err := c.EdgeDeploymentRepository.Read(ctx, deploymentName, c.Namespace)
+	if err != nil {
+		return err
+	}
+	device, err := c.EdgeDeviceRepository.Read(ctx, deviceName, c.Namespace)
+	if err != nil {
+		return err
+	}
+
+	requirements, err := matching.Explain(edgeDeployment, device)
+	if err != nil {
+		return err
+	}
+	match, err := matching.Matches(edgeDeployment, device)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(c.Out, 0, 4, 3, ' ', 0)
+	fmt.Fprintln(w, "REQUIREMENT\tSATISFIED")
+	for _, requirement := range requirements {
+		fmt.Fprintf(w, "%s\t%t\n", requirement.Requirement, requirement.Satisfied)
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+
+	if !match {
+		fmt.Fprintf(c.Out, "EdgeDeployment %s does not target EdgeDevice %s\n", deploymentName, deviceName)
+		return nil
+	}
+	for _, deployment := range device.Status.Deployments {
+		if deployment.Name == deploymentName {
+			fmt.Fprintf(c.Out, "EdgeDeployment %s is deployed to EdgeDevice %s, phase %s\n", deploymentName, deviceName, valueOrNone(string(deployment.Phase)))
+			return nil
+		}
+	}
+
+	reason, err := c.rejectionReason(ctx, edgeDeployment, device)
+	if err != nil {
+		return err
+	}
+	if reason == "" {
+		fmt.Fprintf(c.Out, "EdgeDeployment %s targets EdgeDevice %s and is not deployed yet\n", deploymentName, deviceName)
+		return nil
+	}
+	fmt.Fprintf(c.Out, "EdgeDeployment %s targets EdgeDevice %s but is not deployed: %s\n", deploymentName, deviceName, reason)
+	return nil
+}
+
+// rejectionReason checks the placement constraints and the capacity the way the operator does before deploying a
+// deployment to a device
+func (c *Commands) rejectionReason(ctx context.Context, edgeDeployment *v1alpha1.EdgeDeployment, device *v1alpha1.EdgeDevice) (string, error) {
+	if edgeDeployment.Spec.Placement != nil {
+		selector := metav1.LabelSelector{MatchLabels: map[string]string{labels.WorkloadLabel(edgeDeployment.Name): "true"}}
+		placed, err := c.EdgeDeviceRepository.ListForSelector(ctx, &selector, c.Namespace)
+		if err != nil {
+			return "", err
+		}
+		if err = placement.CheckConstraints(edgeDeployment, placed, device); err != nil {
+			return err.Error(), nil
+		}
+	}
+
+	var deployed []v1alpha1.EdgeDeployment
+	for _, deployment := range device.Status.Deployments {
+		other, err := c.EdgeDeploymentRepository.Read(ctx, deployment.Name, c.Namespace)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return "", err
+		}
+		deployed = append(deployed, *other)
+	}
+	if err := capacity.Check(device, deployed, edgeDeployment); err != nil {
+		return err.Error(), nil
+	}
+	return "", nil
+}
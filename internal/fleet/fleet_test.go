@@ -0,0 +1,295 @@
+package fleet_test
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"github.com/project-flotta/flotta-operator/internal/fleet"
+	"github.com/project-flotta/flotta-operator/internal/repository/edgedeployment"
+	"github.com/project-flotta/flotta-operator/internal/repository/edgedevice"
+	"github.com/project-flotta/flotta-operator/internal/repository/edgedevicemigration"
+	"github.com/project-flotta/flotta-operator/internal/yggdrasil"
+	"github.com/project-flotta/flotta-operator/models"
+)
+
+type renderer struct {
+	dc               *models.DeviceConfigurationMessage
+	resolutionErrors []yggdrasil.ResolutionError
+}
+
+func (r *renderer) RenderConfiguration(ctx context.Context, name string, namespace string) (*models.DeviceConfigurationMessage, []yggdrasil.ResolutionError, error) {
+	return r.dc, r.resolutionErrors, nil
+}
+
+var _ = Describe("Fleet commands", func() {
+	const namespace = "default"
+
+	var (
+		mockCtrl          *gomock.Controller
+		deviceRepoMock    *edgedevice.MockRepository
+		deployRepoMock    *edgedeployment.MockRepository
+		migrationRepoMock *edgedevicemigration.MockRepository
+		out               *bytes.Buffer
+		commands          *fleet.Commands
+		device            *v1alpha1.EdgeDevice
+		deployment        *v1alpha1.EdgeDeployment
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		deviceRepoMock = edgedevice.NewMockRepository(mockCtrl)
+		deployRepoMock = edgedeployment.NewMockRepository(mockCtrl)
+		migrationRepoMock = edgedevicemigration.NewMockRepository(mockCtrl)
+		out = &bytes.Buffer{}
+		commands = &fleet.Commands{
+			Namespace:                     namespace,
+			EdgeDeviceRepository:          deviceRepoMock,
+			EdgeDeploymentRepository:      deployRepoMock,
+			EdgeDeviceMigrationRepository: migrationRepoMock,
+			Renderer:                      &renderer{},
+			Out:                           out,
+		}
+
+		device = &v1alpha1.EdgeDevice{
+			ObjectMeta: metav1.ObjectMeta{Name: "device", Namespace: namespace, Labels: map[string]string{"site": "madrid"}},
+		}
+		deployment = &v1alpha1.EdgeDeployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "camera", Namespace: namespace},
+			Spec: v1alpha1.EdgeDeploymentSpec{
+				DeviceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"site": "madrid"}},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	Context("Online state", func() {
+		It("Device that never sent a heartbeat is offline", func() {
+			Expect(fleet.IsOnline(device, time.Now())).To(BeFalse())
+		})
+
+		It("Device is online within three heartbeat periods", func() {
+			// given
+			now := time.Now()
+			device.Spec.Heartbeat = &v1alpha1.HeartbeatConfiguration{PeriodSeconds: 10}
+			device.Status.LastSeenTime = metav1.NewTime(now.Add(-25 * time.Second))
+
+			// then
+			Expect(fleet.IsOnline(device, now)).To(BeTrue())
+			Expect(fleet.IsOnline(device, now.Add(10*time.Second))).To(BeFalse())
+		})
+
+		It("Heartbeat period of the device set is used", func() {
+			// given
+			now := time.Now()
+			device.Spec.Heartbeat = &v1alpha1.HeartbeatConfiguration{PeriodSeconds: 10}
+			device.Status.EffectiveConfiguration = &v1alpha1.EffectiveConfiguration{
+				Heartbeat: &v1alpha1.HeartbeatConfiguration{PeriodSeconds: 60},
+			}
+			device.Status.LastSeenTime = metav1.NewTime(now.Add(-time.Minute))
+
+			// then
+			Expect(fleet.IsOnline(device, now)).To(BeTrue())
+		})
+	})
+
+	It("Devices are listed with their deployments", func() {
+		// given
+		device.Status.Phase = "up"
+		device.Status.LastSeenTime = metav1.Now()
+		device.Status.Deployments = []v1alpha1.Deployment{{Name: "camera", Phase: v1alpha1.Running}}
+		device.Status.Conditions = []metav1.Condition{{
+			Type:               v1alpha1.ConfigurationSyncedCondition,
+			Status:             metav1.ConditionFalse,
+			LastTransitionTime: metav1.NewTime(time.Now().Add(-5 * time.Minute)),
+		}}
+		offline := v1alpha1.EdgeDevice{ObjectMeta: metav1.ObjectMeta{Name: "another", Namespace: namespace}}
+		deviceRepoMock.EXPECT().
+			ListForSelector(gomock.Any(), &metav1.LabelSelector{}, namespace).
+			Return([]v1alpha1.EdgeDevice{*device, offline}, nil)
+
+		// when
+		err := commands.ListDevices(context.TODO())
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+		Expect(lines).To(HaveLen(3))
+		Expect(string(lines[1])).To(MatchRegexp(`^another\s+<none>\s+false\s+<never>\s+<unknown>\s+<none>$`))
+		Expect(string(lines[2])).To(MatchRegexp(`^device\s+up\s+true\s+\S+ ago\s+false \(5m0s\)\s+camera:Running$`))
+	})
+
+	It("Migration is approved", func() {
+		// given
+		migration := &v1alpha1.EdgeDeviceMigration{ObjectMeta: metav1.ObjectMeta{Name: "migration", Namespace: namespace}}
+		migrationRepoMock.EXPECT().Read(gomock.Any(), "migration", namespace).Return(migration, nil)
+		migrationRepoMock.EXPECT().
+			Patch(gomock.Any(), migration, gomock.Any()).
+			Do(func(ctx context.Context, old, new *v1alpha1.EdgeDeviceMigration) {
+				Expect(new.Spec.Approved).To(BeTrue())
+			}).
+			Return(nil)
+
+		// when
+		err := commands.ApproveMigration(context.TODO(), "migration")
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("Registration is approved", func() {
+		// given
+		deviceRepoMock.EXPECT().Read(gomock.Any(), "device", namespace).Return(device, nil)
+		deviceRepoMock.EXPECT().
+			Patch(gomock.Any(), device, gomock.Any()).
+			Do(func(ctx context.Context, old, new *v1alpha1.EdgeDevice) {
+				Expect(new.Spec.RegistrationApproved).To(BeTrue())
+			}).
+			Return(nil)
+
+		// when
+		err := commands.ApproveRegistration(context.TODO(), "device")
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out.String()).To(ContainSubstring("edgedevice/device registration approved"))
+	})
+
+	It("Registration approved already is not patched", func() {
+		// given
+		device.Spec.RegistrationApproved = true
+		deviceRepoMock.EXPECT().Read(gomock.Any(), "device", namespace).Return(device, nil)
+
+		// when
+		err := commands.ApproveRegistration(context.TODO(), "device")
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("Device is decommissioned", func() {
+		// given
+		deviceRepoMock.EXPECT().Read(gomock.Any(), "device", namespace).Return(device, nil)
+		deviceRepoMock.EXPECT().
+			Patch(gomock.Any(), device, gomock.Any()).
+			Do(func(ctx context.Context, old, new *v1alpha1.EdgeDevice) {
+				Expect(new.Spec.Decommission).To(Equal(&v1alpha1.Decommission{Force: true, DataRetention: v1alpha1.DeleteData}))
+			}).
+			Return(nil)
+
+		// when
+		err := commands.Decommission(context.TODO(), "device", true, true)
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("Configuration is shown with secrets redacted", func() {
+		// given
+		commands.Renderer = &renderer{dc: &models.DeviceConfigurationMessage{
+			DeviceID: "device",
+			Secrets:  models.SecretList{{Name: "db", Data: `{"password":"cGFzc3dvcmQ="}`}},
+		}}
+
+		// when
+		err := commands.ShowConfiguration(context.TODO(), "device")
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out.String()).To(ContainSubstring("password"))
+		Expect(out.String()).NotTo(ContainSubstring("cGFzc3dvcmQ="))
+	})
+
+	It("Configuration is shown with unresolved items", func() {
+		// given
+		commands.Renderer = &renderer{
+			dc:               &models.DeviceConfigurationMessage{DeviceID: "device"},
+			resolutionErrors: []yggdrasil.ResolutionError{{Item: "secret db", Error: "secrets \"db\" not found"}},
+		}
+
+		// when
+		err := commands.ShowConfiguration(context.TODO(), "device")
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out.String()).To(ContainSubstring(`secret db: secrets "db" not found`))
+	})
+
+	It("Twin is shown with desired and reported properties", func() {
+		// given
+		device.Spec.Twin = &v1alpha1.DeviceTwin{Desired: map[string]string{"interval": "10s", "mode": "eco"}}
+		device.Status.Twin = &v1alpha1.DeviceTwinStatus{
+			AppliedDesiredVersion: "old",
+			Reported:              map[string]string{"interval": "10s", "mode": "full", "firmware": "1.2"},
+		}
+		deviceRepoMock.EXPECT().Read(gomock.Any(), "device", namespace).Return(device, nil)
+
+		// when
+		err := commands.ShowTwin(context.TODO(), "device")
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out.String()).To(ContainSubstring("In sync:                  false"))
+		Expect(out.String()).To(MatchRegexp(`firmware\s+<none>\s+1.2\s+-`))
+		Expect(out.String()).To(MatchRegexp(`interval\s+10s\s+10s\s+true`))
+		Expect(out.String()).To(MatchRegexp(`mode\s+eco\s+full\s+false`))
+	})
+
+	Context("Explain", func() {
+		BeforeEach(func() {
+			deployRepoMock.EXPECT().Read(gomock.Any(), "camera", namespace).Return(deployment, nil)
+			deviceRepoMock.EXPECT().Read(gomock.Any(), "device", namespace).Return(device, nil)
+		})
+
+		It("Unsatisfied requirement is shown", func() {
+			// given
+			device.Labels = map[string]string{"site": "paris"}
+
+			// when
+			err := commands.ExplainMatch(context.TODO(), "camera", "device")
+
+			// then
+			Expect(err).NotTo(HaveOccurred())
+			Expect(out.String()).To(MatchRegexp(`site=madrid\s+false`))
+			Expect(out.String()).To(ContainSubstring("does not target"))
+		})
+
+		It("Deployed deployment is shown with its phase", func() {
+			// given
+			device.Status.Deployments = []v1alpha1.Deployment{{Name: "camera", Phase: v1alpha1.Running}}
+
+			// when
+			err := commands.ExplainMatch(context.TODO(), "camera", "device")
+
+			// then
+			Expect(err).NotTo(HaveOccurred())
+			Expect(out.String()).To(MatchRegexp(`site=madrid\s+true`))
+			Expect(out.String()).To(ContainSubstring("is deployed to EdgeDevice device, phase Running"))
+		})
+
+		It("Violated placement constraint is shown", func() {
+			// given
+			deployment.Spec.Placement = &v1alpha1.PlacementConfiguration{MaxDevices: 1}
+			other := v1alpha1.EdgeDevice{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: namespace}}
+			deviceRepoMock.EXPECT().
+				ListForSelector(gomock.Any(), gomock.Any(), namespace).
+				Return([]v1alpha1.EdgeDevice{other}, nil)
+
+			// when
+			err := commands.ExplainMatch(context.TODO(), "camera", "device")
+
+			// then
+			Expect(err).NotTo(HaveOccurred())
+			Expect(out.String()).To(ContainSubstring("is not deployed: maximum number of devices (1) reached"))
+		})
+	})
+})
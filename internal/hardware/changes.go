@@ -0,0 +1,273 @@
+package hardware
+
+import (
+	"fmt"
+
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// MaxHardwareChanges is the number of hardware changes kept in the EdgeDevice status
+	MaxHardwareChanges = 20
+
+	ComponentHostname     = "hostname"
+	ComponentBoot         = "boot"
+	ComponentCPU          = "cpu"
+	ComponentMemory       = "memory"
+	ComponentSystemVendor = "systemVendor"
+	ComponentDisk         = "disk"
+	ComponentInterface    = "interface"
+	ComponentGpu          = "gpu"
+)
+
+// Diff compares previously known hardware of a device with the newly reported one and returns the list of changes.
+// Changes to the system vendor identity (serial number, manufacturer, product) are flagged as sensitive.
+func Diff(oldHw, newHw *v1alpha1.Hardware, now metav1.Time) []v1alpha1.HardwareChange {
+	if oldHw == nil || newHw == nil {
+		return nil
+	}
+	d := differ{now: now}
+
+	if oldHw.Hostname != newHw.Hostname {
+		d.modified(ComponentHostname, "", false, "hostname changed from %q to %q", oldHw.Hostname, newHw.Hostname)
+	}
+	d.diffBoot(oldHw.Boot, newHw.Boot)
+	d.diffCPU(oldHw.CPU, newHw.CPU)
+	d.diffMemory(oldHw.Memory, newHw.Memory)
+	d.diffSystemVendor(oldHw.SystemVendor, newHw.SystemVendor)
+	d.diffDisks(oldHw.Disks, newHw.Disks)
+	d.diffInterfaces(oldHw.Interfaces, newHw.Interfaces)
+	d.diffGpus(oldHw.Gpus, newHw.Gpus)
+
+	return d.changes
+}
+
+// Merge returns the newly reported hardware completed with the sections of the previously known hardware it does not
+// report. Devices with a delta hardware profile scope report only some sections, the others are left unchanged.
+func Merge(oldHw, newHw *v1alpha1.Hardware) *v1alpha1.Hardware {
+	if oldHw == nil || newHw == nil {
+		return newHw
+	}
+	merged := newHw.DeepCopy()
+	if merged.Hostname == "" {
+		merged.Hostname = oldHw.Hostname
+	}
+	if merged.Boot == nil {
+		merged.Boot = oldHw.Boot.DeepCopy()
+	}
+	if merged.CPU == nil {
+		merged.CPU = oldHw.CPU.DeepCopy()
+	}
+	if merged.Memory == nil {
+		merged.Memory = oldHw.Memory.DeepCopy()
+	}
+	if merged.SystemVendor == nil {
+		merged.SystemVendor = oldHw.SystemVendor.DeepCopy()
+	}
+	if len(merged.Disks) == 0 {
+		merged.Disks = oldHw.DeepCopy().Disks
+	}
+	if len(merged.Interfaces) == 0 {
+		merged.Interfaces = oldHw.DeepCopy().Interfaces
+	}
+	if len(merged.Gpus) == 0 {
+		merged.Gpus = oldHw.DeepCopy().Gpus
+	}
+	return merged
+}
+
+// AppendChanges adds changes to the history, keeping only the latest MaxHardwareChanges entries
+func AppendChanges(history []v1alpha1.HardwareChange, changes []v1alpha1.HardwareChange) []v1alpha1.HardwareChange {
+	history = append(history, changes...)
+	if len(history) > MaxHardwareChanges {
+		history = history[len(history)-MaxHardwareChanges:]
+	}
+	return history
+}
+
+// FirstSensitive returns the first change that may indicate device tampering, nil if there is none
+func FirstSensitive(changes []v1alpha1.HardwareChange) *v1alpha1.HardwareChange {
+	for i := range changes {
+		if changes[i].Sensitive {
+			return &changes[i]
+		}
+	}
+	return nil
+}
+
+type differ struct {
+	now     metav1.Time
+	changes []v1alpha1.HardwareChange
+}
+
+func (d *differ) add(changeType v1alpha1.HardwareChangeType, component, id string, sensitive bool, format string, args ...interface{}) {
+	d.changes = append(d.changes, v1alpha1.HardwareChange{
+		Time:        d.now,
+		Component:   component,
+		ID:          id,
+		Type:        changeType,
+		Description: fmt.Sprintf(format, args...),
+		Sensitive:   sensitive,
+	})
+}
+
+func (d *differ) modified(component, id string, sensitive bool, format string, args ...interface{}) {
+	d.add(v1alpha1.HardwareModified, component, id, sensitive, format, args...)
+}
+
+func (d *differ) diffBoot(oldBoot, newBoot *v1alpha1.Boot) {
+	if oldBoot == nil || newBoot == nil {
+		return
+	}
+	if oldBoot.CurrentBootMode != newBoot.CurrentBootMode {
+		d.modified(ComponentBoot, "", false, "boot mode changed from %q to %q", oldBoot.CurrentBootMode, newBoot.CurrentBootMode)
+	}
+}
+
+func (d *differ) diffCPU(oldCPU, newCPU *v1alpha1.CPU) {
+	if oldCPU == nil || newCPU == nil {
+		return
+	}
+	if oldCPU.Architecture != newCPU.Architecture {
+		d.modified(ComponentCPU, "", false, "CPU architecture changed from %q to %q", oldCPU.Architecture, newCPU.Architecture)
+	}
+	if oldCPU.ModelName != newCPU.ModelName {
+		d.modified(ComponentCPU, "", false, "CPU model changed from %q to %q", oldCPU.ModelName, newCPU.ModelName)
+	}
+	if oldCPU.Count != newCPU.Count {
+		d.modified(ComponentCPU, "", false, "CPU count changed from %d to %d", oldCPU.Count, newCPU.Count)
+	}
+}
+
+func (d *differ) diffMemory(oldMemory, newMemory *v1alpha1.Memory) {
+	if oldMemory == nil || newMemory == nil {
+		return
+	}
+	if oldMemory.PhysicalBytes != newMemory.PhysicalBytes {
+		d.modified(ComponentMemory, "", false, "physical memory changed from %d to %d bytes", oldMemory.PhysicalBytes, newMemory.PhysicalBytes)
+	}
+}
+
+func (d *differ) diffSystemVendor(oldVendor, newVendor *v1alpha1.SystemVendor) {
+	if oldVendor == nil || newVendor == nil {
+		return
+	}
+	if oldVendor.SerialNumber != newVendor.SerialNumber {
+		d.modified(ComponentSystemVendor, "", true, "system serial number changed from %q to %q", oldVendor.SerialNumber, newVendor.SerialNumber)
+	}
+	if oldVendor.Manufacturer != newVendor.Manufacturer {
+		d.modified(ComponentSystemVendor, "", true, "system manufacturer changed from %q to %q", oldVendor.Manufacturer, newVendor.Manufacturer)
+	}
+	if oldVendor.ProductName != newVendor.ProductName {
+		d.modified(ComponentSystemVendor, "", true, "system product changed from %q to %q", oldVendor.ProductName, newVendor.ProductName)
+	}
+}
+
+func (d *differ) diffDisks(oldDisks, newDisks []*v1alpha1.Disk) {
+	oldMap := map[string]*v1alpha1.Disk{}
+	for _, disk := range oldDisks {
+		if disk != nil {
+			oldMap[diskID(disk)] = disk
+		}
+	}
+	for _, disk := range newDisks {
+		if disk == nil {
+			continue
+		}
+		id := diskID(disk)
+		oldDisk, ok := oldMap[id]
+		if !ok {
+			d.add(v1alpha1.HardwareAdded, ComponentDisk, id, false, "disk %s (%d bytes) added", disk.Name, disk.SizeBytes)
+			continue
+		}
+		delete(oldMap, id)
+		if oldDisk.Serial != disk.Serial {
+			d.modified(ComponentDisk, id, false, "disk %s serial changed from %q to %q", disk.Name, oldDisk.Serial, disk.Serial)
+		}
+		if oldDisk.SizeBytes != disk.SizeBytes {
+			d.modified(ComponentDisk, id, false, "disk %s size changed from %d to %d bytes", disk.Name, oldDisk.SizeBytes, disk.SizeBytes)
+		}
+	}
+	for _, disk := range oldDisks {
+		if disk == nil {
+			continue
+		}
+		if _, ok := oldMap[diskID(disk)]; ok {
+			d.add(v1alpha1.HardwareRemoved, ComponentDisk, diskID(disk), false, "disk %s (%d bytes) removed", disk.Name, disk.SizeBytes)
+		}
+	}
+}
+
+func (d *differ) diffInterfaces(oldInterfaces, newInterfaces []*v1alpha1.Interface) {
+	oldMap := map[string]*v1alpha1.Interface{}
+	for _, i := range oldInterfaces {
+		if i != nil {
+			oldMap[interfaceID(i)] = i
+		}
+	}
+	for _, i := range newInterfaces {
+		if i == nil {
+			continue
+		}
+		id := interfaceID(i)
+		if _, ok := oldMap[id]; !ok {
+			d.add(v1alpha1.HardwareAdded, ComponentInterface, id, false, "network interface %s added", i.Name)
+			continue
+		}
+		delete(oldMap, id)
+	}
+	for _, i := range oldInterfaces {
+		if i == nil {
+			continue
+		}
+		if _, ok := oldMap[interfaceID(i)]; ok {
+			d.add(v1alpha1.HardwareRemoved, ComponentInterface, interfaceID(i), false, "network interface %s removed", i.Name)
+		}
+	}
+}
+
+func (d *differ) diffGpus(oldGpus, newGpus []*v1alpha1.Gpu) {
+	oldMap := map[string]*v1alpha1.Gpu{}
+	for _, g := range oldGpus {
+		if g != nil {
+			oldMap[g.Address] = g
+		}
+	}
+	for _, g := range newGpus {
+		if g == nil {
+			continue
+		}
+		oldGpu, ok := oldMap[g.Address]
+		if !ok {
+			d.add(v1alpha1.HardwareAdded, ComponentGpu, g.Address, false, "GPU %s added", g.Name)
+			continue
+		}
+		delete(oldMap, g.Address)
+		if oldGpu.VendorID != g.VendorID || oldGpu.DeviceID != g.DeviceID {
+			d.modified(ComponentGpu, g.Address, false, "GPU changed from %q to %q", oldGpu.Name, g.Name)
+		}
+	}
+	for _, g := range oldGpus {
+		if g == nil {
+			continue
+		}
+		if _, ok := oldMap[g.Address]; ok {
+			d.add(v1alpha1.HardwareRemoved, ComponentGpu, g.Address, false, "GPU %s removed", g.Name)
+		}
+	}
+}
+
+func diskID(disk *v1alpha1.Disk) string {
+	if disk.ID != "" {
+		return disk.ID
+	}
+	return disk.Name
+}
+
+func interfaceID(i *v1alpha1.Interface) string {
+	if i.MacAddress != "" {
+		return i.MacAddress
+	}
+	return i.Name
+}
@@ -0,0 +1,156 @@
+package hardware_test
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"github.com/project-flotta/flotta-operator/internal/hardware"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Hardware changes", func() {
+	var (
+		now   = metav1.Now()
+		oldHw *v1alpha1.Hardware
+	)
+
+	BeforeEach(func() {
+		oldHw = &v1alpha1.Hardware{
+			Hostname: "host",
+			CPU:      &v1alpha1.CPU{Count: 4, ModelName: "xeon", Architecture: "x86_64"},
+			Memory:   &v1alpha1.Memory{PhysicalBytes: 8000},
+			SystemVendor: &v1alpha1.SystemVendor{
+				SerialNumber: "serial",
+				Manufacturer: "manufacturer",
+				ProductName:  "product",
+			},
+			Disks:      []*v1alpha1.Disk{{ID: "disk-1", Name: "sda", SizeBytes: 100, Serial: "s1"}},
+			Interfaces: []*v1alpha1.Interface{{Name: "eth0", MacAddress: "00:00:00:00:00:01"}},
+			Gpus:       []*v1alpha1.Gpu{{Address: "0000:00:02.0", Name: "gpu", DeviceID: "3ea0"}},
+		}
+	})
+
+	Context("Diff", func() {
+		It("should accept nil input", func() {
+			Expect(hardware.Diff(nil, oldHw, now)).To(BeEmpty())
+			Expect(hardware.Diff(oldHw, nil, now)).To(BeEmpty())
+		})
+
+		It("should not report changes for the same hardware", func() {
+			// when
+			changes := hardware.Diff(oldHw, oldHw.DeepCopy(), now)
+
+			// then
+			Expect(changes).To(BeEmpty())
+		})
+
+		It("should report modified components", func() {
+			// given
+			newHw := oldHw.DeepCopy()
+			newHw.Hostname = "other-host"
+			newHw.CPU.Count = 2
+			newHw.Memory.PhysicalBytes = 4000
+			newHw.Disks[0].SizeBytes = 200
+			newHw.Gpus[0].DeviceID = "3ea1"
+
+			// when
+			changes := hardware.Diff(oldHw, newHw, now)
+
+			// then
+			Expect(changes).To(HaveLen(5))
+			for _, change := range changes {
+				Expect(change.Type).To(Equal(v1alpha1.HardwareModified))
+				Expect(change.Sensitive).To(BeFalse())
+				Expect(change.Time).To(Equal(now))
+			}
+			Expect(hardware.FirstSensitive(changes)).To(BeNil())
+		})
+
+		It("should report added and removed components", func() {
+			// given
+			newHw := oldHw.DeepCopy()
+			newHw.Disks = []*v1alpha1.Disk{{ID: "disk-2", Name: "sdb", SizeBytes: 100}}
+			newHw.Interfaces = append(newHw.Interfaces, &v1alpha1.Interface{Name: "eth1", MacAddress: "00:00:00:00:00:02"})
+			newHw.Gpus = nil
+
+			// when
+			changes := hardware.Diff(oldHw, newHw, now)
+
+			// then
+			var summary []string
+			for _, change := range changes {
+				summary = append(summary, fmt.Sprintf("%s/%s/%s", change.Component, change.ID, change.Type))
+			}
+			Expect(summary).To(ConsistOf(
+				"disk/disk-2/Added",
+				"disk/disk-1/Removed",
+				"interface/00:00:00:00:00:02/Added",
+				"gpu/0000:00:02.0/Removed",
+			))
+		})
+
+		It("should flag system identity changes as sensitive", func() {
+			// given
+			newHw := oldHw.DeepCopy()
+			newHw.SystemVendor.SerialNumber = "other-serial"
+
+			// when
+			changes := hardware.Diff(oldHw, newHw, now)
+
+			// then
+			Expect(changes).To(HaveLen(1))
+			Expect(changes[0].Component).To(Equal(hardware.ComponentSystemVendor))
+			Expect(changes[0].Sensitive).To(BeTrue())
+			Expect(hardware.FirstSensitive(changes)).To(Equal(&changes[0]))
+		})
+	})
+
+	Context("Merge", func() {
+		It("should accept nil input", func() {
+			Expect(hardware.Merge(nil, oldHw)).To(Equal(oldHw))
+			Expect(hardware.Merge(oldHw, nil)).To(BeNil())
+		})
+
+		It("should keep the sections that are not reported", func() {
+			// given
+			newHw := &v1alpha1.Hardware{
+				Interfaces: []*v1alpha1.Interface{{Name: "eth1", MacAddress: "00:00:00:00:00:02"}},
+			}
+
+			// when
+			merged := hardware.Merge(oldHw, newHw)
+
+			// then
+			expected := oldHw.DeepCopy()
+			expected.Interfaces = newHw.Interfaces
+			Expect(merged).To(Equal(expected))
+			var summary []string
+			for _, change := range hardware.Diff(oldHw, merged, now) {
+				summary = append(summary, fmt.Sprintf("%s/%s/%s", change.Component, change.ID, change.Type))
+			}
+			Expect(summary).To(ConsistOf(
+				"interface/00:00:00:00:00:02/Added",
+				"interface/00:00:00:00:00:01/Removed",
+			))
+		})
+	})
+
+	Context("AppendChanges", func() {
+		It("should keep only the latest changes", func() {
+			// given
+			var history []v1alpha1.HardwareChange
+			for i := 0; i < hardware.MaxHardwareChanges; i++ {
+				history = append(history, v1alpha1.HardwareChange{Component: "old"})
+			}
+
+			// when
+			history = hardware.AppendChanges(history, []v1alpha1.HardwareChange{{Component: "new"}})
+
+			// then
+			Expect(history).To(HaveLen(hardware.MaxHardwareChanges))
+			Expect(history[len(history)-1].Component).To(Equal("new"))
+		})
+	})
+})
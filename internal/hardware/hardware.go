@@ -2,12 +2,21 @@ package hardware
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/project-flotta/flotta-operator/api/v1alpha1"
 	"github.com/project-flotta/flotta-operator/internal/utils"
 	"github.com/project-flotta/flotta-operator/models"
 )
 
+// labelPrefix prefixes the labels set from the hardware of the device at registration
+const labelPrefix = "device."
+
+// IsHardwareLabel checks whether the label is set from the hardware of the device
+func IsHardwareLabel(label string) bool {
+	return strings.HasPrefix(label, labelPrefix)
+}
+
 func MapHardware(hardware *models.HardwareInfo) *v1alpha1.Hardware {
 	if hardware == nil {
 		return nil
@@ -99,18 +108,18 @@ func MapLabels(hardware *models.HardwareInfo) map[string]string {
 
 	hostname, err := utils.NormalizeLabel(hardware.Hostname)
 	if err == nil {
-		labels["device.hostname"] = hostname
+		labels[labelPrefix+"hostname"] = hostname
 	}
 
 	cpu := hardware.CPU
 	if cpu != nil {
 		arch, err := utils.NormalizeLabel(cpu.Architecture)
 		if err == nil {
-			labels["device.cpu-architecture"] = arch
+			labels[labelPrefix+"cpu-architecture"] = arch
 		}
 		model, err := utils.NormalizeLabel(cpu.ModelName)
 		if err == nil {
-			labels["device.cpu-model"] = model
+			labels[labelPrefix+"cpu-model"] = model
 		}
 	}
 
@@ -118,15 +127,15 @@ func MapLabels(hardware *models.HardwareInfo) map[string]string {
 	if systemVendor != nil {
 		manufacturer, err := utils.NormalizeLabel(systemVendor.Manufacturer)
 		if err == nil {
-			labels["device.system-manufacturer"] = manufacturer
+			labels[labelPrefix+"system-manufacturer"] = manufacturer
 		}
 		productName, err := utils.NormalizeLabel(systemVendor.ProductName)
 		if err == nil {
-			labels["device.system-product"] = productName
+			labels[labelPrefix+"system-product"] = productName
 		}
 		serialNumber, err := utils.NormalizeLabel(systemVendor.SerialNumber)
 		if err == nil {
-			labels["device.system-serial"] = serialNumber
+			labels[labelPrefix+"system-serial"] = serialNumber
 		}
 	}
 
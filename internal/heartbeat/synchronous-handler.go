@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/project-flotta/flotta-operator/internal/repository/edgedeployment"
 	"github.com/project-flotta/flotta-operator/internal/repository/edgedevice"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/client-go/tools/record"
@@ -15,12 +16,13 @@ type SynchronousHandler struct {
 	updater          Updater
 }
 
-func NewSynchronousHandler(deviceRepository edgedevice.Repository, recorder record.EventRecorder) *SynchronousHandler {
+func NewSynchronousHandler(deviceRepository edgedevice.Repository, deploymentRepository edgedeployment.Repository, recorder record.EventRecorder) *SynchronousHandler {
 	return &SynchronousHandler{
 		deviceRepository: deviceRepository,
 		updater: Updater{
-			deviceRepository: deviceRepository,
-			recorder:         recorder,
+			deviceRepository:     deviceRepository,
+			deploymentRepository: deploymentRepository,
+			recorder:             recorder,
 		},
 	}
 }
@@ -2,21 +2,30 @@ package heartbeat
 
 import (
 	"context"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"github.com/project-flotta/flotta-operator/internal/deviceset"
 	"github.com/project-flotta/flotta-operator/internal/hardware"
+	"github.com/project-flotta/flotta-operator/internal/repository/edgedeployment"
 	"github.com/project-flotta/flotta-operator/internal/repository/edgedevice"
+	"github.com/project-flotta/flotta-operator/internal/twin"
 	"github.com/project-flotta/flotta-operator/models"
 	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 type Updater struct {
-	deviceRepository edgedevice.Repository
-	recorder         record.EventRecorder
+	deviceRepository     edgedevice.Repository
+	deploymentRepository edgedeployment.Repository
+	recorder             record.EventRecorder
 }
 
 func (u *Updater) updateStatus(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, heartbeat *models.Heartbeat) error {
@@ -25,15 +34,29 @@ func (u *Updater) updateStatus(ctx context.Context, edgeDevice *v1alpha1.EdgeDev
 	edgeDevice.Status.LastSyncedResourceVersion = heartbeat.Version
 	edgeDevice.Status.LastSeenTime = v1.NewTime(time.Now())
 	edgeDevice.Status.Phase = heartbeat.Status
+	var hardwareChanges []v1alpha1.HardwareChange
 	if heartbeat.Hardware != nil {
-		edgeDevice.Status.Hardware = hardware.MapHardware(heartbeat.Hardware)
+		newHardware := hardware.MapHardware(heartbeat.Hardware)
+		if hardwareScope(edgeDevice) == models.HardwareProfileConfigurationScopeDelta {
+			// sections the device does not report are not removed
+			newHardware = hardware.Merge(edgeDevice.Status.Hardware, newHardware)
+		}
+		hardwareChanges = hardware.Diff(edgeDevice.Status.Hardware, newHardware, v1.Now())
+		edgeDevice.Status.Hardware = newHardware
+		updateHardwareChanges(edgeDevice, hardwareChanges)
 	}
-	deployments := updateDeploymentStatuses(edgeDevice.Status.Deployments, heartbeat.Workloads)
+	deployments := updateDeploymentStatuses(edgeDevice.Status.Deployments, heartbeat.Workloads, getDependencies(edgeDevice.Status.Deployments))
+	u.updateDataUploadErrors(ctx, edgeDevice, deployments)
 	edgeDevice.Status.Deployments = deployments
 	edgeDevice.Status.UpgradeInformation = (*v1alpha1.UpgradeInformation)(heartbeat.Upgrade)
+	u.updateTwin(edgeDevice, heartbeat)
 
 	err := u.deviceRepository.PatchStatus(ctx, edgeDevice, &patch)
-	return err
+	if err != nil {
+		return err
+	}
+	u.processHardwareChanges(edgeDevice, hardwareChanges)
+	return nil
 }
 
 func (u *Updater) updateLabels(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, heartbeat *models.Heartbeat) error {
@@ -54,24 +77,180 @@ func (u *Updater) processEvents(edgeDevice *v1alpha1.EdgeDevice, events []*model
 	}
 }
 
-func updateDeploymentStatuses(oldDeployments []v1alpha1.Deployment, workloads []*models.WorkloadStatus) []v1alpha1.Deployment {
+func (u *Updater) processHardwareChanges(edgeDevice *v1alpha1.EdgeDevice, changes []v1alpha1.HardwareChange) {
+	for _, change := range changes {
+		if change.Sensitive {
+			u.recorder.Event(edgeDevice, v12.EventTypeWarning, "HardwareTampering", change.Description)
+		} else {
+			u.recorder.Event(edgeDevice, v12.EventTypeNormal, "Hardware"+string(change.Type), change.Description)
+		}
+	}
+}
+
+// updateTwin records the desired properties the device applied and the properties it reports; invalid reported
+// properties are ignored
+func (u *Updater) updateTwin(edgeDevice *v1alpha1.EdgeDevice, heartbeat *models.Heartbeat) {
+	reported := heartbeat.ReportedProperties
+	if err := twin.Validate(reported); err != nil {
+		u.recorder.Event(edgeDevice, v12.EventTypeWarning, "InvalidReportedProperties", err.Error())
+		reported = nil
+	}
+	edgeDevice.Status.Twin = twin.Status(edgeDevice, heartbeat.DesiredPropertiesVersion, reported, v1.Now())
+}
+
+// hardwareScope returns the hardware profile scope configured for the device, directly or by its EdgeDeviceSet
+func hardwareScope(edgeDevice *v1alpha1.EdgeDevice) string {
+	spec := deviceset.Apply(&edgeDevice.Spec, edgeDevice.Status.EffectiveConfiguration)
+	if spec.Heartbeat == nil || spec.Heartbeat.HardwareProfile == nil {
+		return ""
+	}
+	return spec.Heartbeat.HardwareProfile.Scope
+}
+
+func updateHardwareChanges(edgeDevice *v1alpha1.EdgeDevice, changes []v1alpha1.HardwareChange) {
+	if len(changes) == 0 {
+		return
+	}
+	edgeDevice.Status.HardwareChanges = hardware.AppendChanges(edgeDevice.Status.HardwareChanges, changes)
+	if sensitive := hardware.FirstSensitive(changes); sensitive != nil && edgeDevice.Status.HardwareTampering == nil {
+		edgeDevice.Status.HardwareTampering = &v1alpha1.HardwareTampering{
+			DetectionTime: sensitive.Time,
+			Reason:        sensitive.Description,
+		}
+	}
+}
+
+// dataUploadErrors returns the data upload errors of the deployments by name
+func dataUploadErrors(deployments []v1alpha1.Deployment) map[string]string {
+	uploadErrors := map[string]string{}
+	for _, deployment := range deployments {
+		uploadErrors[deployment.Name] = deployment.LastDataUploadError
+	}
+	return uploadErrors
+}
+
+// updateDataUploadErrors records the data upload errors of the device that changed in the EdgeDeployments. Many devices
+// update the same EdgeDeployment concurrently: updates conflicting are retried with the EdgeDeployment read again. An
+// error that still cannot be recorded is left out of the deployments of the device, so that its next heartbeat records
+// it instead of failing the heartbeat.
+func (u *Updater) updateDataUploadErrors(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, deployments []v1alpha1.Deployment) {
+	oldErrors := dataUploadErrors(edgeDevice.Status.Deployments)
+	for i, deployment := range deployments {
+		oldError := oldErrors[deployment.Name]
+		if oldError == deployment.LastDataUploadError {
+			continue
+		}
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			edgeDeployment, err := u.deploymentRepository.Read(ctx, deployment.Name, edgeDevice.Namespace)
+			if err != nil {
+				if errors.IsNotFound(err) {
+					return nil
+				}
+				return err
+			}
+			return u.setDataUploadError(ctx, edgeDeployment, edgeDevice.Name, deployment.LastDataUploadError)
+		})
+		if err != nil {
+			log.FromContext(ctx).Info("cannot record data upload error, retrying on next heartbeat", "deployment", deployment.Name, "reason", err.Error())
+			deployments[i].LastDataUploadError = oldError
+		}
+	}
+}
+
+func (u *Updater) setDataUploadError(ctx context.Context, edgeDeployment *v1alpha1.EdgeDeployment, deviceName string, message string) error {
+	var uploadErrors []v1alpha1.DataUploadError
+	recorded := ""
+	for _, uploadError := range edgeDeployment.Status.DataUploadErrors {
+		if uploadError.Device != deviceName {
+			uploadErrors = append(uploadErrors, uploadError)
+		} else {
+			recorded = uploadError.Message
+		}
+	}
+	if recorded == message {
+		return nil
+	}
+	// many devices report errors of the same deployment concurrently
+	patch := client.MergeFromWithOptions(edgeDeployment.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	if message != "" {
+		uploadErrors = append(uploadErrors, v1alpha1.DataUploadError{
+			Device:  deviceName,
+			Message: message,
+			Time:    v1.Now(),
+		})
+	}
+	edgeDeployment.Status.DataUploadErrors = uploadErrors
+	return u.deploymentRepository.PatchStatus(ctx, edgeDeployment, &patch)
+}
+
+// getDependencies returns the dependencies of the deployments by name, as recorded in the device status by the
+// EdgeDeployment controller
+func getDependencies(deployments []v1alpha1.Deployment) map[string][]string {
+	dependencies := make(map[string][]string)
+	for _, deployment := range deployments {
+		if len(deployment.DependsOn) > 0 {
+			dependencies[deployment.Name] = deployment.DependsOn
+		}
+	}
+	return dependencies
+}
+
+func updateDeploymentStatuses(oldDeployments []v1alpha1.Deployment, workloads []*models.WorkloadStatus, dependencies map[string][]string) []v1alpha1.Deployment {
 	deploymentMap := make(map[string]v1alpha1.Deployment)
+	phases := make(map[string]v1alpha1.EdgeDeploymentPhase)
 	for _, deploymentStatus := range oldDeployments {
 		deploymentMap[deploymentStatus.Name] = deploymentStatus
+		phases[deploymentStatus.Name] = deploymentStatus.Phase
 	}
 	for _, status := range workloads {
 		if deployment, ok := deploymentMap[status.Name]; ok {
-			if string(deployment.Phase) != status.Status {
-				deployment.Phase = v1alpha1.EdgeDeploymentPhase(status.Status)
-				deployment.LastTransitionTime = v1.Now()
-			}
+			phases[status.Name] = v1alpha1.EdgeDeploymentPhase(status.Status)
 			deployment.LastDataUpload = v1.NewTime(time.Time(status.LastDataUpload))
+			deployment.LastDataUploadError = status.LastDataUploadError
 			deploymentMap[status.Name] = deployment
 		}
 	}
+	blocked := getBlockedDeployments(phases, dependencies)
 	var deployments []v1alpha1.Deployment
-	for _, deployment := range deploymentMap {
+	for name, deployment := range deploymentMap {
+		phase := phases[name]
+		if blocked[name] {
+			phase = v1alpha1.Blocked
+		} else if phase == v1alpha1.Blocked {
+			phase = v1alpha1.Deploying
+		}
+		if deployment.Phase != phase {
+			deployment.Phase = phase
+			deployment.LastTransitionTime = v1.Now()
+		}
 		deployments = append(deployments, deployment)
 	}
+	// a stable order keeps the status, and the configuration rendered from it, unchanged between heartbeats
+	sort.Slice(deployments, func(i, j int) bool {
+		return deployments[i].Name < deployments[j].Name
+	})
 	return deployments
 }
+
+// getBlockedDeployments returns deployments depending on a deployment that is not running on the device,
+// either directly or through another blocked deployment
+func getBlockedDeployments(phases map[string]v1alpha1.EdgeDeploymentPhase, dependencies map[string][]string) map[string]bool {
+	blocked := make(map[string]bool)
+	for changed := true; changed; {
+		changed = false
+		for name, dependsOn := range dependencies {
+			if blocked[name] {
+				continue
+			}
+			for _, dependency := range dependsOn {
+				phase, ok := phases[dependency]
+				if !ok || blocked[dependency] || !strings.EqualFold(string(phase), string(v1alpha1.Running)) {
+					blocked[name] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return blocked
+}
@@ -3,10 +3,12 @@ package labels
 import "strings"
 
 const (
-	DeviceNameLabel     = "devicename"
-	DoesNotExistLabel   = "doesnotexist"
+	// DeviceSetLabel is set on an EdgeDevice to the name of the EdgeDeviceSet the device is a member of.
+	DeviceSetLabel = "device-set"
+
 	workloadLabelPrefix = "workload/"
 	selectorLabelPrefix = "selector/"
+	siteLabelPrefix     = "site/"
 )
 
 func WorkloadLabel(workloadName string) string {
@@ -17,10 +19,23 @@ func IsWorkloadLabel(label string) bool {
 	return strings.HasPrefix(label, workloadLabelPrefix)
 }
 
-func IsSelectorLabel(label string) bool {
-	return strings.HasPrefix(label, selectorLabelPrefix)
+// SiteLabel is set to "true" on the EdgeDevices located at the site or at a site below it, so that selectors can
+// target a subtree of the site topology
+func SiteLabel(siteName string) string {
+	return siteLabelPrefix + siteName
+}
+
+func IsSiteLabel(label string) bool {
+	return strings.HasPrefix(label, siteLabelPrefix)
 }
 
-func CreateSelectorLabel(label string) string {
-	return selectorLabelPrefix + label
+// SiteOfLabel returns the name of the site of a site label
+func SiteOfLabel(label string) string {
+	return strings.TrimPrefix(label, siteLabelPrefix)
+}
+
+// IsSelectorLabel tells whether the label is one of the selector labels previous versions of the operator set on
+// EdgeDeployments
+func IsSelectorLabel(label string) bool {
+	return strings.HasPrefix(label, selectorLabelPrefix)
 }
@@ -0,0 +1,107 @@
+package maintenance
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"github.com/robfig/cron/v3"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MaxSnapshotSize is the largest delivered configuration recorded in the status of a device, in bytes. The status is
+// patched on every heartbeat and shares the etcd object size limit with the rest of the device, so it is kept small.
+const MaxSnapshotSize = 64 * 1024
+
+// Window is a recurring period in which workload and OS changes are delivered to a device
+type Window struct {
+	schedule cron.Schedule
+	duration time.Duration
+	location *time.Location
+}
+
+// NewWindow parses the maintenance window configuration
+func NewWindow(config *v1alpha1.MaintenanceWindow) (*Window, error) {
+	schedule, err := cron.ParseStandard(config.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("maintenance window schedule '%s' is not valid: %v", config.Schedule, err)
+	}
+	if config.DurationMinutes < 1 {
+		return nil, fmt.Errorf("maintenance window duration has to be at least one minute")
+	}
+	location := time.UTC
+	if config.TimeZone != "" {
+		location, err = time.LoadLocation(config.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("maintenance window time zone '%s' is not valid: %v", config.TimeZone, err)
+		}
+	}
+	return &Window{
+		schedule: schedule,
+		duration: time.Duration(config.DurationMinutes) * time.Minute,
+		location: location,
+	}, nil
+}
+
+// IsOpen returns true when the window started less than its duration before now
+func (w *Window) IsOpen(now time.Time) bool {
+	start := w.schedule.Next(now.In(w.location).Add(-w.duration))
+	return !start.After(now)
+}
+
+// NextStart returns the start of the first window after now
+func (w *Window) NextStart(now time.Time) time.Time {
+	return w.schedule.Next(now.In(w.location))
+}
+
+// Snapshot returns the configuration delivered to a device for the given deployments and OS information, with the
+// deployments sorted by name
+func Snapshot(edgeDeployments []v1alpha1.EdgeDeployment, osInformation *v1alpha1.OsInformation) *v1alpha1.DeliveredConfiguration {
+	delivered := v1alpha1.DeliveredConfiguration{}
+	for _, edgeDeployment := range edgeDeployments {
+		delivered.Deployments = append(delivered.Deployments, v1alpha1.DeliveredDeployment{
+			Name: edgeDeployment.Name,
+			Spec: *edgeDeployment.Spec.DeepCopy(),
+		})
+	}
+	sortDeployments(delivered.Deployments)
+	if osInformation != nil {
+		delivered.OsInformation = osInformation.DeepCopy()
+	}
+	return &delivered
+}
+
+// Size returns the size of the delivered configuration once stored, in bytes
+func Size(delivered *v1alpha1.DeliveredConfiguration) int {
+	data, err := json.Marshal(delivered)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
+
+// Deployments returns the EdgeDeployments of the delivered configuration
+func Deployments(delivered *v1alpha1.DeliveredConfiguration, namespace string) []v1alpha1.EdgeDeployment {
+	var edgeDeployments []v1alpha1.EdgeDeployment
+	for _, deployment := range delivered.Deployments {
+		edgeDeployments = append(edgeDeployments, v1alpha1.EdgeDeployment{
+			ObjectMeta: metav1.ObjectMeta{Name: deployment.Name, Namespace: namespace},
+			Spec:       *deployment.Spec.DeepCopy(),
+		})
+	}
+	return edgeDeployments
+}
+
+// Equal returns true when both delivered configurations carry the same deployments and OS information
+func Equal(delivered1, delivered2 *v1alpha1.DeliveredConfiguration) bool {
+	return equality.Semantic.DeepEqual(delivered1, delivered2)
+}
+
+func sortDeployments(deployments []v1alpha1.DeliveredDeployment) {
+	sort.Slice(deployments, func(i, j int) bool {
+		return deployments[i].Name < deployments[j].Name
+	})
+}
@@ -0,0 +1,143 @@
+package maintenance_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"github.com/project-flotta/flotta-operator/internal/maintenance"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Maintenance", func() {
+	Context("Window", func() {
+		// every day from 02:00 to 03:30
+		config := &v1alpha1.MaintenanceWindow{Schedule: "0 2 * * *", DurationMinutes: 90}
+
+		table.DescribeTable("should be open only during the window",
+			func(now string, open bool) {
+				// given
+				window, err := maintenance.NewWindow(config)
+				Expect(err).NotTo(HaveOccurred())
+				t, err := time.Parse(time.RFC3339, now)
+				Expect(err).NotTo(HaveOccurred())
+
+				// then
+				Expect(window.IsOpen(t)).To(Equal(open))
+			},
+			table.Entry("before the window", "2022-03-01T01:59:59Z", false),
+			table.Entry("at the start", "2022-03-01T02:00:00Z", true),
+			table.Entry("inside the window", "2022-03-01T03:00:00Z", true),
+			table.Entry("at the end", "2022-03-01T03:30:00Z", false),
+			table.Entry("after the window", "2022-03-01T12:00:00Z", false),
+		)
+
+		It("should evaluate the schedule in the time zone", func() {
+			// given
+			window, err := maintenance.NewWindow(&v1alpha1.MaintenanceWindow{
+				Schedule: "0 2 * * *", DurationMinutes: 60, TimeZone: "Europe/Madrid",
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			// then
+			Expect(window.IsOpen(time.Date(2022, 3, 1, 1, 30, 0, 0, time.UTC))).To(BeTrue())
+			Expect(window.IsOpen(time.Date(2022, 3, 1, 2, 30, 0, 0, time.UTC))).To(BeFalse())
+		})
+
+		It("should return the start of the next window", func() {
+			// given
+			window, err := maintenance.NewWindow(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			// when
+			next := window.NextStart(time.Date(2022, 3, 1, 2, 30, 0, 0, time.UTC))
+
+			// then
+			Expect(next.Equal(time.Date(2022, 3, 2, 2, 0, 0, 0, time.UTC))).To(BeTrue())
+		})
+
+		table.DescribeTable("should reject invalid configuration",
+			func(config *v1alpha1.MaintenanceWindow) {
+				// when
+				_, err := maintenance.NewWindow(config)
+
+				// then
+				Expect(err).To(HaveOccurred())
+			},
+			table.Entry("invalid schedule", &v1alpha1.MaintenanceWindow{Schedule: "every night", DurationMinutes: 60}),
+			table.Entry("zero duration", &v1alpha1.MaintenanceWindow{Schedule: "0 2 * * *"}),
+			table.Entry("invalid time zone", &v1alpha1.MaintenanceWindow{Schedule: "0 2 * * *", DurationMinutes: 60, TimeZone: "Mars/Olympus"}),
+		)
+	})
+
+	Context("Delivered configuration", func() {
+		var edgeDeployments []v1alpha1.EdgeDeployment
+
+		BeforeEach(func() {
+			edgeDeployments = []v1alpha1.EdgeDeployment{{
+				ObjectMeta: metav1.ObjectMeta{Name: "workload", Namespace: "ns", ResourceVersion: "10"},
+				Spec: v1alpha1.EdgeDeploymentSpec{
+					Type: "pod",
+					Pod: v1alpha1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+						Name:  "container",
+						Image: "quay.io/project-flotta/nginx:1.21.6",
+						Resources: corev1.ResourceRequirements{
+							Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("128Mi")},
+						},
+					}}}},
+				},
+			}}
+		})
+
+		It("should restore deployments from the snapshot", func() {
+			// given
+			delivered := maintenance.Snapshot(edgeDeployments, &v1alpha1.OsInformation{CommitID: "commit"})
+
+			// when
+			restored := maintenance.Deployments(delivered, "ns")
+
+			// then
+			Expect(restored).To(HaveLen(1))
+			Expect(restored[0].Name).To(Equal("workload"))
+			Expect(restored[0].Namespace).To(Equal("ns"))
+			Expect(restored[0].Spec).To(Equal(edgeDeployments[0].Spec))
+			Expect(delivered.OsInformation.CommitID).To(Equal("commit"))
+		})
+
+		It("should compare snapshots semantically", func() {
+			// given
+			delivered := maintenance.Snapshot(edgeDeployments, nil)
+			edgeDeployments[0].Spec.Pod.Spec.Containers[0].Resources.Limits[corev1.ResourceMemory] = resource.MustParse("134217728")
+
+			// then
+			Expect(maintenance.Equal(delivered, maintenance.Snapshot(edgeDeployments, nil))).To(BeTrue())
+		})
+
+		It("should ignore the order of the deployments", func() {
+			// given
+			other := edgeDeployments[0].DeepCopy()
+			other.Name = "another"
+			delivered := maintenance.Snapshot([]v1alpha1.EdgeDeployment{edgeDeployments[0], *other}, nil)
+
+			// then
+			Expect(delivered.Deployments[0].Name).To(Equal("another"))
+			Expect(maintenance.Equal(delivered, maintenance.Snapshot([]v1alpha1.EdgeDeployment{*other, edgeDeployments[0]}, nil))).To(BeTrue())
+		})
+
+		It("should detect changed deployments and OS", func() {
+			// given
+			delivered := maintenance.Snapshot(edgeDeployments, nil)
+			changed := edgeDeployments[0].DeepCopy()
+			changed.Spec.Pod.Spec.Containers[0].Image = "quay.io/project-flotta/nginx:1.22.0"
+
+			// then
+			Expect(maintenance.Equal(delivered, maintenance.Snapshot([]v1alpha1.EdgeDeployment{*changed}, nil))).To(BeFalse())
+			Expect(maintenance.Equal(delivered, maintenance.Snapshot(edgeDeployments, &v1alpha1.OsInformation{CommitID: "commit"}))).To(BeFalse())
+			Expect(maintenance.Equal(delivered, maintenance.Snapshot(nil, nil))).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,63 @@
+package matching
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+)
+
+// Requirement of an EdgeDeployment on the EdgeDevices it targets
+type Requirement struct {
+	// Requirement is the device name or a requirement of the device selector, e.g. "site in (madrid)"
+	Requirement string
+
+	// Satisfied tells whether the EdgeDevice satisfies the requirement
+	Satisfied bool
+}
+
+// Matches returns whether the EdgeDeployment targets the EdgeDevice, either by name or by device selector
+func Matches(deployment *v1alpha1.EdgeDeployment, device *v1alpha1.EdgeDevice) (bool, error) {
+	if deployment.Spec.Device == device.Name {
+		return true, nil
+	} else if deployment.Spec.DeviceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.DeviceSelector)
+		if err != nil {
+			return false, err
+		}
+		return selector.Matches(labels.Set(device.Labels)), nil
+	}
+	return false, nil
+}
+
+// Explain returns the requirements of the EdgeDeployment on the devices it targets and whether the EdgeDevice
+// satisfies each of them. The device is targeted when it satisfies the device name requirement, or all the
+// requirements of the device selector.
+func Explain(deployment *v1alpha1.EdgeDeployment, device *v1alpha1.EdgeDevice) ([]Requirement, error) {
+	var requirements []Requirement
+	if deployment.Spec.Device != "" {
+		requirements = append(requirements, Requirement{
+			Requirement: fmt.Sprintf("device name is %s", deployment.Spec.Device),
+			Satisfied:   deployment.Spec.Device == device.Name,
+		})
+	}
+	if deployment.Spec.DeviceSelector == nil {
+		return requirements, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.DeviceSelector)
+	if err != nil {
+		return nil, err
+	}
+	selectorRequirements, _ := selector.Requirements()
+	deviceLabels := labels.Set(device.Labels)
+	for _, requirement := range selectorRequirements {
+		requirements = append(requirements, Requirement{
+			Requirement: requirement.String(),
+			Satisfied:   requirement.Matches(deviceLabels),
+		})
+	}
+	return requirements, nil
+}
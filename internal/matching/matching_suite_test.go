@@ -0,0 +1,13 @@
+package matching_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestMatching(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Matching Spec")
+}
@@ -0,0 +1,108 @@
+package matching_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"github.com/project-flotta/flotta-operator/internal/matching"
+)
+
+var _ = Describe("Matching", func() {
+	var (
+		deployment *v1alpha1.EdgeDeployment
+		device     *v1alpha1.EdgeDevice
+	)
+
+	BeforeEach(func() {
+		deployment = &v1alpha1.EdgeDeployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "camera", Namespace: "default"},
+		}
+		device = &v1alpha1.EdgeDevice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "device",
+				Namespace: "default",
+				Labels:    map[string]string{"site": "madrid", "device.cpu-architecture": "x86_64"},
+			},
+		}
+	})
+
+	It("Device is matched by name", func() {
+		// given
+		deployment.Spec.Device = "device"
+
+		// when
+		match, err := matching.Matches(deployment, device)
+		requirements, explainErr := matching.Explain(deployment, device)
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(match).To(BeTrue())
+		Expect(explainErr).NotTo(HaveOccurred())
+		Expect(requirements).To(Equal([]matching.Requirement{{Requirement: "device name is device", Satisfied: true}}))
+	})
+
+	It("Device is matched by selector", func() {
+		// given
+		deployment.Spec.DeviceSelector = &metav1.LabelSelector{
+			MatchLabels: map[string]string{"site": "madrid"},
+			MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "device.cpu-architecture", Operator: metav1.LabelSelectorOpIn, Values: []string{"x86_64", "aarch64"}},
+			},
+		}
+
+		// when
+		match, err := matching.Matches(deployment, device)
+		requirements, explainErr := matching.Explain(deployment, device)
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(match).To(BeTrue())
+		Expect(explainErr).NotTo(HaveOccurred())
+		Expect(requirements).To(ConsistOf(
+			matching.Requirement{Requirement: "site=madrid", Satisfied: true},
+			matching.Requirement{Requirement: "device.cpu-architecture in (aarch64,x86_64)", Satisfied: true},
+		))
+	})
+
+	It("Unsatisfied requirements are reported", func() {
+		// given
+		deployment.Spec.DeviceSelector = &metav1.LabelSelector{
+			MatchLabels: map[string]string{"site": "madrid"},
+			MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "gpu", Operator: metav1.LabelSelectorOpExists},
+			},
+		}
+
+		// when
+		match, err := matching.Matches(deployment, device)
+		requirements, explainErr := matching.Explain(deployment, device)
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(match).To(BeFalse())
+		Expect(explainErr).NotTo(HaveOccurred())
+		Expect(requirements).To(ConsistOf(
+			matching.Requirement{Requirement: "site=madrid", Satisfied: true},
+			matching.Requirement{Requirement: "gpu", Satisfied: false},
+		))
+	})
+
+	It("Invalid selector fails", func() {
+		// given
+		deployment.Spec.DeviceSelector = &metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "site", Operator: "Unknown"},
+			},
+		}
+
+		// when
+		_, err := matching.Matches(deployment, device)
+		_, explainErr := matching.Explain(deployment, device)
+
+		// then
+		Expect(err).To(HaveOccurred())
+		Expect(explainErr).To(HaveOccurred())
+	})
+})
@@ -1,6 +1,8 @@
 package metrics
 
 import (
+	"sync"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
@@ -10,6 +12,8 @@ const (
 	EdgeDeviceSuccessfulRegistrationQuery = "flotta_operator_edge_devices_successful_registration"
 	EdgeDeviceFailedRegistrationQuery     = "flotta_operator_edge_devices_failed_registration"
 	EdgeDeviceUnregistrationQuery         = "flotta_operator_edge_devices_unregistration"
+	EdgeDeviceOutOfSyncQuery              = "flotta_operator_edge_devices_out_of_sync"
+	EdgeDeviceThrottledRequestsQuery      = "flotta_operator_edge_devices_throttled_requests"
 )
 
 var (
@@ -31,6 +35,23 @@ var (
 			Help: "Number of unregistered EdgeDevices",
 		},
 	)
+	outOfSyncEdgeDevices = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: EdgeDeviceOutOfSyncQuery,
+			Help: "Number of EdgeDevices that do not run their desired configuration",
+		},
+	)
+	throttledEdgeDeviceRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: EdgeDeviceThrottledRequestsQuery,
+			Help: "Number of device API requests rejected by rate limiting, by limit",
+		},
+		[]string{"limit"},
+	)
+
+	// outOfSync are the namespaced names of the EdgeDevices counted by outOfSyncEdgeDevices
+	outOfSync     = map[string]struct{}{}
+	outOfSyncLock sync.Mutex
 )
 
 func init() {
@@ -39,6 +60,8 @@ func init() {
 		registeredEdgeDevices,
 		failedToCompleteRegistrationEdgeDevices,
 		unregisteredEdgeDevices,
+		outOfSyncEdgeDevices,
+		throttledEdgeDeviceRequests,
 	)
 }
 
@@ -49,6 +72,13 @@ type Metrics interface {
 	IncEdgeDeviceSuccessfulRegistration()
 	IncEdgeDeviceFailedRegistration()
 	IncEdgeDeviceUnregistration()
+
+	// SetEdgeDeviceOutOfSync records whether the device does not run its desired configuration; a deleted device is
+	// recorded as not out of sync
+	SetEdgeDeviceOutOfSync(namespace, name string, outOfSync bool)
+
+	// IncEdgeDeviceThrottledRequest records a device API request rejected by the limit, e.g. device or registration
+	IncEdgeDeviceThrottledRequest(limit string)
 }
 
 func New() Metrics {
@@ -66,3 +96,19 @@ func (m *metricsImpl) IncEdgeDeviceFailedRegistration() {
 func (m *metricsImpl) IncEdgeDeviceUnregistration() {
 	unregisteredEdgeDevices.Inc()
 }
+
+func (m *metricsImpl) SetEdgeDeviceOutOfSync(namespace, name string, isOutOfSync bool) {
+	outOfSyncLock.Lock()
+	defer outOfSyncLock.Unlock()
+	key := namespace + "/" + name
+	if isOutOfSync {
+		outOfSync[key] = struct{}{}
+	} else {
+		delete(outOfSync, key)
+	}
+	outOfSyncEdgeDevices.Set(float64(len(outOfSync)))
+}
+
+func (m *metricsImpl) IncEdgeDeviceThrottledRequest(limit string) {
+	throttledEdgeDeviceRequests.WithLabelValues(limit).Inc()
+}
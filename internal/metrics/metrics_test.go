@@ -80,5 +80,38 @@ var _ = Describe("Metrics", func() {
 			//then
 			validateMetric(metrics.EdgeDeviceFailedRegistrationQuery, numberOfEdgeDevicesFailedToRegisterValue)
 		})
+
+		It("counts the out of sync devices once", func() {
+			//when
+			m.SetEdgeDeviceOutOfSync("ns", "a", true)
+			m.SetEdgeDeviceOutOfSync("ns", "a", true)
+			m.SetEdgeDeviceOutOfSync("ns", "b", true)
+			m.SetEdgeDeviceOutOfSync("other", "a", true)
+			m.SetEdgeDeviceOutOfSync("ns", "b", false)
+
+			//then
+			data, err := ctrlmetrics.Registry.Gather()
+			Expect(err).NotTo(HaveOccurred())
+			gauge := findMetric(data, metrics.EdgeDeviceOutOfSyncQuery)
+			Expect(gauge).NotTo(BeNil())
+			Expect(*gauge.Metric[0].Gauge.Value).To(BeEquivalentTo(2))
+		})
+
+		It("counts the throttled requests by limit", func() {
+			//when
+			m.IncEdgeDeviceThrottledRequest("device")
+			m.IncEdgeDeviceThrottledRequest("device")
+			m.IncEdgeDeviceThrottledRequest("registration")
+
+			//then
+			data, err := ctrlmetrics.Registry.Gather()
+			Expect(err).NotTo(HaveOccurred())
+			counter := findMetric(data, metrics.EdgeDeviceThrottledRequestsQuery)
+			Expect(counter).NotTo(BeNil())
+			Expect(counter.Metric).To(HaveLen(2))
+			Expect(counter.Metric[0].Label[0].GetValue()).To(Equal("device"))
+			Expect(*counter.Metric[0].Counter.Value).To(BeEquivalentTo(2))
+			Expect(*counter.Metric[1].Counter.Value).To(BeEquivalentTo(1))
+		})
 	})
 })
@@ -57,6 +57,18 @@ func (mr *MockMetricsMockRecorder) IncEdgeDeviceSuccessfulRegistration() *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncEdgeDeviceSuccessfulRegistration", reflect.TypeOf((*MockMetrics)(nil).IncEdgeDeviceSuccessfulRegistration))
 }
 
+// IncEdgeDeviceThrottledRequest mocks base method.
+func (m *MockMetrics) IncEdgeDeviceThrottledRequest(limit string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "IncEdgeDeviceThrottledRequest", limit)
+}
+
+// IncEdgeDeviceThrottledRequest indicates an expected call of IncEdgeDeviceThrottledRequest.
+func (mr *MockMetricsMockRecorder) IncEdgeDeviceThrottledRequest(limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncEdgeDeviceThrottledRequest", reflect.TypeOf((*MockMetrics)(nil).IncEdgeDeviceThrottledRequest), limit)
+}
+
 // IncEdgeDeviceUnregistration mocks base method.
 func (m *MockMetrics) IncEdgeDeviceUnregistration() {
 	m.ctrl.T.Helper()
@@ -68,3 +80,15 @@ func (mr *MockMetricsMockRecorder) IncEdgeDeviceUnregistration() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncEdgeDeviceUnregistration", reflect.TypeOf((*MockMetrics)(nil).IncEdgeDeviceUnregistration))
 }
+
+// SetEdgeDeviceOutOfSync mocks base method.
+func (m *MockMetrics) SetEdgeDeviceOutOfSync(namespace, name string, outOfSync bool) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetEdgeDeviceOutOfSync", namespace, name, outOfSync)
+}
+
+// SetEdgeDeviceOutOfSync indicates an expected call of SetEdgeDeviceOutOfSync.
+func (mr *MockMetricsMockRecorder) SetEdgeDeviceOutOfSync(namespace, name, outOfSync interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetEdgeDeviceOutOfSync", reflect.TypeOf((*MockMetrics)(nil).SetEdgeDeviceOutOfSync), namespace, name, outOfSync)
+}
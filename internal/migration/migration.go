@@ -0,0 +1,31 @@
+package migration
+
+import (
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"github.com/project-flotta/flotta-operator/internal/hardware"
+	"github.com/project-flotta/flotta-operator/internal/labels"
+)
+
+// Transfer returns a copy of the target device that has the identity of the source device:
+//   - the spec of the source device, apart from the registration time and a decommission request;
+//   - the labels of the source device, apart from the ones set from the hardware of the device and the workload labels,
+//     which are kept from the target device.
+func Transfer(source, target *v1alpha1.EdgeDevice) *v1alpha1.EdgeDevice {
+	updated := target.DeepCopy()
+
+	spec := source.Spec.DeepCopy()
+	spec.RequestTime = target.Spec.RequestTime
+	spec.Decommission = nil
+	updated.Spec = *spec
+
+	if updated.Labels == nil {
+		updated.Labels = map[string]string{}
+	}
+	for key, value := range source.Labels {
+		if hardware.IsHardwareLabel(key) || labels.IsWorkloadLabel(key) {
+			continue
+		}
+		updated.Labels[key] = value
+	}
+	return updated
+}
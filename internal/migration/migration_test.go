@@ -0,0 +1,99 @@
+package migration_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"github.com/project-flotta/flotta-operator/internal/migration"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Migration", func() {
+	var (
+		source *v1alpha1.EdgeDevice
+		target *v1alpha1.EdgeDevice
+	)
+
+	BeforeEach(func() {
+		sourceRequestTime := metav1.NewTime(metav1.Now().AddDate(-1, 0, 0))
+		targetRequestTime := metav1.Now()
+		source = &v1alpha1.EdgeDevice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "old",
+				Namespace: "default",
+				Labels: map[string]string{
+					"site":             "madrid",
+					"device-set":       "cameras",
+					"device.hostname":  "old-host",
+					"workload/nginx":   "true",
+					"device.cpu-model": "old-cpu",
+				},
+			},
+			Spec: v1alpha1.EdgeDeviceSpec{
+				RequestTime:   &sourceRequestTime,
+				Heartbeat:     &v1alpha1.HeartbeatConfiguration{PeriodSeconds: 30},
+				OsInformation: &v1alpha1.OsInformation{CommitID: "abc"},
+				Decommission:  &v1alpha1.Decommission{Force: true},
+			},
+		}
+		target = &v1alpha1.EdgeDevice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "new",
+				Namespace: "default",
+				Labels: map[string]string{
+					"device.hostname": "new-host",
+					"workload/redis":  "true",
+				},
+			},
+			Spec: v1alpha1.EdgeDeviceSpec{
+				RequestTime: &targetRequestTime,
+			},
+		}
+	})
+
+	It("should copy the spec of the source device", func() {
+		// when
+		updated := migration.Transfer(source, target)
+
+		// then
+		Expect(updated.Name).To(Equal("new"))
+		Expect(updated.Spec.Heartbeat).To(Equal(source.Spec.Heartbeat))
+		Expect(updated.Spec.OsInformation).To(Equal(source.Spec.OsInformation))
+		Expect(updated.Spec.RequestTime).To(Equal(target.Spec.RequestTime))
+		Expect(updated.Spec.Decommission).To(BeNil())
+	})
+
+	It("should copy the labels of the source device but hardware and workload labels", func() {
+		// when
+		updated := migration.Transfer(source, target)
+
+		// then
+		Expect(updated.Labels).To(Equal(map[string]string{
+			"site":            "madrid",
+			"device-set":      "cameras",
+			"device.hostname": "new-host",
+			"workload/redis":  "true",
+		}))
+	})
+
+	It("should not modify the devices", func() {
+		// when
+		updated := migration.Transfer(source, target)
+		updated.Spec.Heartbeat.PeriodSeconds = 60
+
+		// then
+		Expect(source.Spec.Heartbeat.PeriodSeconds).To(BeEquivalentTo(30))
+		Expect(target.Labels).To(HaveLen(2))
+	})
+
+	It("should add labels to a target device without labels", func() {
+		// given
+		target.Labels = nil
+
+		// when
+		updated := migration.Transfer(source, target)
+
+		// then
+		Expect(updated.Labels).To(HaveKeyWithValue("site", "madrid"))
+	})
+})
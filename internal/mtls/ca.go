@@ -36,7 +36,7 @@ type CAProvider interface {
 	GetName() string
 	GetCACertificate() (*CertificateGroup, error)
 	CreateRegistrationCertificate(name string) (map[string][]byte, error)
-	SignCSR(CSRPem string, commonName string, expiration time.Time) ([]byte, error)
+	SignCSR(CSRPem string, commonName string, namespace string, expiration time.Time) ([]byte, error)
 	GetServerCertificate(dnsNames []string, localhostEnabled bool) (*CertificateGroup, error)
 }
 
@@ -76,14 +76,16 @@ func (conf *TLSConfig) SetClientExpiration(days int) error {
 	return nil
 }
 
-// SignCSR sign the given CSRPem using the first CA provider in use.
-func (conf *TLSConfig) SignCSR(CSRPem string, commonName string) ([]byte, error) {
+// SignCSR sign the given CSRPem using the first CA provider in use. The namespace of the device is recorded in the
+// certificate, see DeviceNamespace.
+func (conf *TLSConfig) SignCSR(CSRPem string, commonName string, namespace string) ([]byte, error) {
 	if len(conf.caProvider) <= 0 {
 		return nil, fmt.Errorf("Cannot get caProvider to sign the CSR")
 	}
 	return conf.caProvider[0].SignCSR(
 		CSRPem,
 		commonName,
+		namespace,
 		time.Now().AddDate(0, 0, conf.clientExpirationDays))
 }
 
@@ -189,10 +191,64 @@ func isClientCertificateSigned(PeerCertificates []*x509.Certificate, CAChain []*
 	return false
 }
 
+// IsRegistrationRequest tells whether the request is authenticated with the registration certificate shared by the
+// devices that are not registered yet
+func IsRegistrationRequest(r *http.Request) bool {
+	if r.TLS == nil {
+		return false
+	}
+	for _, cert := range r.TLS.PeerCertificates {
+		if cert.Subject.CommonName == certRegisterCN {
+			return true
+		}
+	}
+	return false
+}
+
+// DeviceNamespace returns the namespace of the device recorded in the client certificate of the request, or an empty
+// string for requests authenticated with the registration certificate and certificates signed without a namespace
+func DeviceNamespace(r *http.Request) string {
+	if r == nil || r.TLS == nil {
+		return ""
+	}
+	for _, cert := range r.TLS.PeerCertificates {
+		if cert.Subject.CommonName == certRegisterCN {
+			return ""
+		}
+		if len(cert.Subject.OrganizationalUnit) > 0 {
+			return cert.Subject.OrganizationalUnit[0]
+		}
+	}
+	return ""
+}
+
+// DeviceID returns the ID of the device the client certificate of the request is issued to, or an empty string for
+// requests authenticated with the registration certificate and requests without client certificate
+func DeviceID(r *http.Request) string {
+	if r == nil || r.TLS == nil || len(r.TLS.PeerCertificates) == 0 || IsRegistrationRequest(r) {
+		return ""
+	}
+	return r.TLS.PeerCertificates[0].Subject.CommonName
+}
+
+// CertificateSerial returns the serial number of the client certificate of the request, or an empty string for
+// requests without client certificate
+func CertificateSerial(r *http.Request) string {
+	if r == nil || r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return ""
+	}
+	return SerialNumber(r.TLS.PeerCertificates[0])
+}
+
+// SerialNumber returns the serial number of the certificate, hex encoded the way openssl prints it
+func SerialNumber(cert *x509.Certificate) string {
+	return fmt.Sprintf("%X", cert.SerialNumber)
+}
+
 // VerifyRequest check certificate based on the scenario needed:
 // registration endpoint: Any cert signed, even if it's expired.
 // All endpoints: checking that it's valid certificate.
-// @TODO check here the list of rejected certificates.
+// Revoked certificates are checked with RevocationList.
 func VerifyRequest(r *http.Request, verifyType int, verifyOpts x509.VerifyOptions, CACertChain []*x509.Certificate) bool {
 
 	if len(r.TLS.PeerCertificates) == 0 {
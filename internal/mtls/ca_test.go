@@ -402,7 +402,7 @@ KVwIKwl1tEGP
 -----END CERTIFICATE REQUEST-----`
 
 				// when
-				pemCert, err := config.SignCSR(csr, "test")
+				pemCert, err := config.SignCSR(csr, "test", "")
 
 				// then
 				Expect(err).NotTo(HaveOccurred())
@@ -431,7 +431,7 @@ MIIBhTCB7wIBADAdMQwwCgYDVQQKEwNrNGUxDTALBgNVBAMTBHRlc3QwgZ8wDQYJ
 KoZIhvcNAQEBBQA-----END CERTIFICATE REQUEST-----
 `
 					// when
-					pemCert, err := config.SignCSR(csr, "test")
+					pemCert, err := config.SignCSR(csr, "test", "")
 
 					//  then
 					Expect(err).To(HaveOccurred())
@@ -446,7 +446,7 @@ KoZIhvcNAQEBBQA-----END CERTIFICATE REQUEST-----
 					})
 
 					// when
-					pemCert, err := config.SignCSR(string(givenCert), "test")
+					pemCert, err := config.SignCSR(string(givenCert), "test", "")
 
 					// then
 					Expect(err).To(HaveOccurred())
@@ -462,7 +462,7 @@ KoZIhvcNAQEBBQA-----END CERTIFICATE REQUEST-----
 					})
 
 					// when
-					pemCert, err := config.SignCSR(string(givenCert), "test")
+					pemCert, err := config.SignCSR(string(givenCert), "test", "")
 
 					// then
 					Expect(err).To(HaveOccurred())
@@ -483,7 +483,7 @@ KoZIhvcNAQEBBQA-----END CERTIFICATE REQUEST-----
 					date := time.Now().AddDate(0, 0, 1)
 					// when
 
-					pemCert, err := config.SignCSR(string(givenCert), "test")
+					pemCert, err := config.SignCSR(string(givenCert), "test", "")
 					// then
 
 					Expect(err).NotTo(HaveOccurred())
@@ -498,10 +498,69 @@ KoZIhvcNAQEBBQA-----END CERTIFICATE REQUEST-----
 					Expect(cert.NotAfter.Month()).To(Equal(date.Month()))
 					Expect(cert.NotAfter.Day()).To(Equal(date.Day()))
 				})
+
+				It("Records the namespace of the device", func() {
+					// given
+					csr := pem.EncodeToMemory(&pem.Block{
+						Type:  "CERTIFICATE REQUEST",
+						Bytes: createCSR(),
+					})
+
+					// when
+					pemCert, err := config.SignCSR(string(csr), "test", "site-a")
+
+					// then
+					Expect(err).NotTo(HaveOccurred())
+					block, _ := pem.Decode(pemCert)
+					Expect(block).NotTo(BeNil())
+					cert, err := x509.ParseCertificate(block.Bytes)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(cert.Subject.OrganizationalUnit).To(Equal([]string{"site-a"}))
+					request := &http.Request{TLS: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}}
+					Expect(mtls.DeviceNamespace(request)).To(Equal("site-a"))
+				})
+
+				It("Does not keep the organizational unit requested by the device", func() {
+					// given
+					keys, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+					Expect(err).NotTo(HaveOccurred())
+					csrBytes, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+						Subject:            pkix.Name{CommonName: "test", OrganizationalUnit: []string{"other"}},
+						SignatureAlgorithm: x509.ECDSAWithSHA256,
+					}, keys)
+					Expect(err).NotTo(HaveOccurred())
+					csr := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrBytes})
+
+					// when
+					pemCert, err := config.SignCSR(string(csr), "test", "")
+
+					// then
+					Expect(err).NotTo(HaveOccurred())
+					block, _ := pem.Decode(pemCert)
+					Expect(block).NotTo(BeNil())
+					cert, err := x509.ParseCertificate(block.Bytes)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(cert.Subject.OrganizationalUnit).To(BeEmpty())
+				})
 			})
 		})
 	})
 
+	Context("CertificateSerial", func() {
+		It("Returns the serial number of the client certificate", func() {
+			// given
+			cert := &x509.Certificate{SerialNumber: big.NewInt(0x62a1f0c3)}
+			request := &http.Request{TLS: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}}
+
+			// then
+			Expect(mtls.CertificateSerial(request)).To(Equal("62A1F0C3"))
+		})
+
+		It("Returns an empty serial number without client certificate", func() {
+			Expect(mtls.CertificateSerial(&http.Request{})).To(BeEmpty())
+		})
+	})
+
 	Context("VerifyRequest", func() {
 		var (
 			ca         []*certificate
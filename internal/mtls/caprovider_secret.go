@@ -34,6 +34,7 @@ const (
 	certOrganization       = "flotta-operator"
 	certRegisterCN         = "register"
 	certDefaultExpiration  = 1 // years
+	clientCertBackdate     = 24 * time.Hour
 	serverCertOrganization = "flotta-operator"
 )
 
@@ -199,7 +200,7 @@ func (config *CASecretProvider) CreateRegistrationCertificate(name string) (map[
 // This function is going to be used a lot, so using config.latestCA ensure
 // that APIServer is not overloaded with that.
 // Because the CM is always managed by this, should be safe to use that one.
-func (config *CASecretProvider) SignCSR(CSRPem string, commonName string, expiration time.Time) ([]byte, error) {
+func (config *CASecretProvider) SignCSR(CSRPem string, commonName string, namespace string, expiration time.Time) ([]byte, error) {
 	if config.latestCA == nil {
 		return nil, fmt.Errorf("Cannot get CA certificate")
 	}
@@ -222,7 +223,7 @@ func (config *CASecretProvider) SignCSR(CSRPem string, commonName string, expira
 		PublicKey:          CSR.PublicKey,
 		SerialNumber:       big.NewInt(time.Now().Unix()),
 		Subject:            CSR.Subject,
-		NotBefore:          time.Now().AddDate(0, 0, -1), // 1 day before for time drift issues
+		NotBefore:          time.Now().Add(-clientCertBackdate), // 1 day before for time drift issues
 		NotAfter:           expiration,
 		KeyUsage:           x509.KeyUsageDigitalSignature,
 		ExtKeyUsage:        []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
@@ -232,6 +233,11 @@ func (config *CASecretProvider) SignCSR(CSRPem string, commonName string, expira
 	// get access to another device.
 	clientCert.Subject.CommonName = commonName
 	clientCert.Subject.Organization = []string{certOrganization}
+	// The namespace is set by the operator only, so that devices cannot claim the one of another device.
+	clientCert.Subject.OrganizationalUnit = nil
+	if namespace != "" {
+		clientCert.Subject.OrganizationalUnit = []string{namespace}
+	}
 
 	certBytes, err := x509.CreateCertificate(
 		rand.Reader, clientCert, config.latestCA.cert, CSR.PublicKey, config.latestCA.privKey)
@@ -0,0 +1,137 @@
+package mtls
+
+import (
+	"context"
+	"crypto/x509"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// RevokedCertificatesConfigMapName is the name of the ConfigMap, in the operator namespace, that holds
+	// the revocation time of each revoked device
+	RevokedCertificatesConfigMapName = "flotta-revoked-certificates"
+)
+
+// RevocationList keeps track of revoked device certificates. A device
+// certificate is identified by its CommonName, that is the device ID, and the
+// namespace recorded in it, and is revoked when it was issued before the
+// revocation time of the device. A device that registers again afterwards gets
+// a new certificate that is accepted.
+type RevocationList struct {
+	client    client.Client
+	namespace string
+	// initialNamespace is the namespace of the devices whose certificates do not record a namespace
+	initialNamespace string
+	// certificateLifetime is how long client certificates are valid: revocations older than that only concern expired
+	// certificates and are dropped
+	certificateLifetime time.Duration
+	now                 func() time.Time
+}
+
+func NewRevocationList(client client.Client, namespace, initialNamespace string, certificateLifetime time.Duration) *RevocationList {
+	return &RevocationList{
+		client:              client,
+		namespace:           namespace,
+		initialNamespace:    initialNamespace,
+		certificateLifetime: certificateLifetime,
+		now:                 time.Now,
+	}
+}
+
+// Revoke revokes all the certificates issued to the device of the namespace up to the given time
+func (r *RevocationList) Revoke(ctx context.Context, namespace, deviceID string, revocationTime time.Time) error {
+	key := revocationKey(namespace, deviceID)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm := corev1.ConfigMap{}
+		err := r.client.Get(ctx, client.ObjectKey{Namespace: r.namespace, Name: RevokedCertificatesConfigMapName}, &cm)
+		if err != nil {
+			if !errors.IsNotFound(err) {
+				return err
+			}
+			cm = corev1.ConfigMap{
+				ObjectMeta: v1.ObjectMeta{
+					Name:      RevokedCertificatesConfigMapName,
+					Namespace: r.namespace,
+				},
+				Data: map[string]string{key: revocationTime.UTC().Format(time.RFC3339)},
+			}
+			return r.client.Create(ctx, &cm)
+		}
+
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		r.prune(cm.Data)
+		cm.Data[key] = revocationTime.UTC().Format(time.RFC3339)
+		return r.client.Update(ctx, &cm)
+	})
+}
+
+// prune drops the revocations older than the lifetime of client certificates, so that the ConfigMap does not grow
+// with every device ever revoked. Certificates issued before them are expired, and are only accepted to register the
+// device again, like the registration certificate.
+func (r *RevocationList) prune(revocations map[string]string) {
+	if r.certificateLifetime <= 0 {
+		return
+	}
+	oldest := r.now().Add(-r.certificateLifetime)
+	for key, value := range revocations {
+		revocationTime, err := time.Parse(time.RFC3339, value)
+		if err == nil && revocationTime.Before(oldest) {
+			delete(revocations, key)
+		}
+	}
+}
+
+// IsRevoked checks whether any of the device certificates is revoked
+func (r *RevocationList) IsRevoked(ctx context.Context, certificates []*x509.Certificate) (bool, error) {
+	cm := corev1.ConfigMap{}
+	err := r.client.Get(ctx, client.ObjectKey{Namespace: r.namespace, Name: RevokedCertificatesConfigMapName}, &cm)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	for _, cert := range certificates {
+		if r.isRevoked(cert, cm.Data) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *RevocationList) isRevoked(cert *x509.Certificate, revocations map[string]string) bool {
+	if cert.Subject.CommonName == certRegisterCN {
+		return false
+	}
+	namespace := r.initialNamespace
+	if len(cert.Subject.OrganizationalUnit) > 0 {
+		namespace = cert.Subject.OrganizationalUnit[0]
+	}
+	value, ok := revocations[revocationKey(namespace, cert.Subject.CommonName)]
+	if !ok {
+		return false
+	}
+	revocationTime, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		// an unreadable entry still revokes the device
+		return true
+	}
+	// NotBefore of client certificates is set back to tolerate clock drift
+	issueTime := cert.NotBefore.Add(clientCertBackdate)
+	return !issueTime.After(revocationTime)
+}
+
+// revocationKey is the ConfigMap key of the revocation of a device. Namespaces have no dots, so that the keys of
+// different devices cannot be the same.
+func revocationKey(namespace, deviceID string) string {
+	return namespace + "." + deviceID
+}
@@ -0,0 +1,176 @@
+package mtls_test
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+
+	"github.com/project-flotta/flotta-operator/internal/mtls"
+)
+
+var _ = Describe("RevocationList", func() {
+
+	const (
+		// clientCertBackdate is the time NotBefore of client certificates is set back; keep a copy here.
+		clientCertBackdate = 24 * time.Hour
+
+		initialNamespace    = "default"
+		certificateLifetime = 30 * 24 * time.Hour
+	)
+
+	var (
+		k8sClient      client.Client
+		namespace      = "test"
+		testEnv        *envtest.Environment
+		revocations    *mtls.RevocationList
+		revocationTime time.Time
+	)
+
+	deviceCert := func(commonName string, issueTime time.Time) *x509.Certificate {
+		return &x509.Certificate{
+			Subject:   pkix.Name{CommonName: commonName},
+			NotBefore: issueTime.Add(-clientCertBackdate),
+		}
+	}
+
+	BeforeEach(func() {
+		testEnv = &envtest.Environment{
+			CRDDirectoryPaths: []string{
+				filepath.Join("../..", "config", "crd", "bases"),
+				filepath.Join("../..", "config", "test", "crd"),
+			},
+			ErrorIfCRDPathMissing: true,
+		}
+		cfg, err := testEnv.Start()
+		Expect(err).NotTo(HaveOccurred())
+
+		k8sClient, err = client.New(cfg, client.Options{Scheme: scheme.Scheme})
+		Expect(err).NotTo(HaveOccurred())
+
+		nsSpec := corev1.Namespace{ObjectMeta: v1.ObjectMeta{Name: namespace}}
+		err = k8sClient.Create(context.TODO(), &nsSpec)
+		Expect(err).NotTo(HaveOccurred())
+
+		revocations = mtls.NewRevocationList(k8sClient, namespace, initialNamespace, certificateLifetime)
+		revocationTime = time.Now().Truncate(time.Second)
+	})
+
+	AfterEach(func() {
+		err := testEnv.Stop()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("Nothing is revoked when no device has been revoked", func() {
+		// when
+		revoked, err := revocations.IsRevoked(context.TODO(), []*x509.Certificate{deviceCert("device-UUID", revocationTime)})
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(revoked).To(BeFalse())
+	})
+
+	It("Certificate issued before the revocation is revoked", func() {
+		// given
+		err := revocations.Revoke(context.TODO(), initialNamespace, "device-UUID", revocationTime)
+		Expect(err).NotTo(HaveOccurred())
+
+		// when
+		revoked, err := revocations.IsRevoked(context.TODO(), []*x509.Certificate{deviceCert("device-UUID", revocationTime.Add(-time.Minute))})
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(revoked).To(BeTrue())
+	})
+
+	It("Certificate issued after the revocation is not revoked", func() {
+		// given
+		err := revocations.Revoke(context.TODO(), initialNamespace, "device-UUID", revocationTime)
+		Expect(err).NotTo(HaveOccurred())
+
+		// when
+		revoked, err := revocations.IsRevoked(context.TODO(), []*x509.Certificate{deviceCert("device-UUID", revocationTime.Add(time.Minute))})
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(revoked).To(BeFalse())
+	})
+
+	It("Certificates of other devices and registration certificates are not revoked", func() {
+		// given
+		err := revocations.Revoke(context.TODO(), initialNamespace, "device-UUID", revocationTime)
+		Expect(err).NotTo(HaveOccurred())
+		certs := []*x509.Certificate{
+			deviceCert("other-device", revocationTime.Add(-time.Minute)),
+			deviceCert(certRegisterCN, revocationTime.Add(-time.Minute)),
+		}
+
+		// when
+		revoked, err := revocations.IsRevoked(context.TODO(), certs)
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(revoked).To(BeFalse())
+	})
+
+	It("Revocations of several devices are kept", func() {
+		// given
+		err := revocations.Revoke(context.TODO(), initialNamespace, "device-UUID", revocationTime)
+		Expect(err).NotTo(HaveOccurred())
+
+		// when
+		err = revocations.Revoke(context.TODO(), initialNamespace, "other-device", revocationTime)
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		cm := corev1.ConfigMap{}
+		err = k8sClient.Get(context.TODO(), client.ObjectKey{Namespace: namespace, Name: mtls.RevokedCertificatesConfigMapName}, &cm)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cm.Data).To(HaveKey(initialNamespace + ".device-UUID"))
+		Expect(cm.Data).To(HaveKey(initialNamespace + ".other-device"))
+	})
+
+	It("Certificates of devices of another namespace with the same ID are not revoked", func() {
+		// given
+		err := revocations.Revoke(context.TODO(), "site-a", "device-UUID", revocationTime)
+		Expect(err).NotTo(HaveOccurred())
+		siteCert := deviceCert("device-UUID", revocationTime.Add(-time.Minute))
+		siteCert.Subject.OrganizationalUnit = []string{"site-a"}
+
+		// when
+		revokedInitial, err := revocations.IsRevoked(context.TODO(), []*x509.Certificate{deviceCert("device-UUID", revocationTime.Add(-time.Minute))})
+		Expect(err).NotTo(HaveOccurred())
+		revokedSite, err := revocations.IsRevoked(context.TODO(), []*x509.Certificate{siteCert})
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(revokedInitial).To(BeFalse())
+		Expect(revokedSite).To(BeTrue())
+	})
+
+	It("Revocations older than the certificate lifetime are dropped", func() {
+		// given
+		err := revocations.Revoke(context.TODO(), initialNamespace, "old-device", revocationTime.Add(-certificateLifetime-time.Hour))
+		Expect(err).NotTo(HaveOccurred())
+
+		// when
+		err = revocations.Revoke(context.TODO(), initialNamespace, "device-UUID", revocationTime)
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		cm := corev1.ConfigMap{}
+		err = k8sClient.Get(context.TODO(), client.ObjectKey{Namespace: namespace, Name: mtls.RevokedCertificatesConfigMapName}, &cm)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cm.Data).To(HaveKey(initialNamespace + ".device-UUID"))
+		Expect(cm.Data).ToNot(HaveKey(initialNamespace + ".old-device"))
+	})
+})
@@ -0,0 +1,149 @@
+package placement
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"github.com/project-flotta/flotta-operator/internal/capacity"
+)
+
+// MaxUnplacedDevices limits the number of unplaced devices reported in the EdgeDeployment status
+const MaxUnplacedDevices = 100
+
+// DeployedFunc returns the EdgeDeployments already deployed to the device
+type DeployedFunc func(edgeDevice *v1alpha1.EdgeDevice) ([]v1alpha1.EdgeDeployment, error)
+
+// Result of the placement of an EdgeDeployment
+type Result struct {
+	// Devices the deployment is deployed to
+	Devices []v1alpha1.EdgeDevice
+
+	// Unplaced are the devices the deployment is not deployed to, sorted by name
+	Unplaced []v1alpha1.UnplacedDevice
+}
+
+// Place selects the devices, out of the ones matching the selector, the deployment is deployed to.
+// Devices the deployment is already deployed to are kept as long as the placement constraints allow it;
+// other devices are considered in name order and have to fit the deployment together with the deployments
+// already deployed there.
+func Place(edgeDeployment *v1alpha1.EdgeDeployment, edgeDevices []v1alpha1.EdgeDevice, deployed DeployedFunc) (*Result, error) {
+	var current, candidates []v1alpha1.EdgeDevice
+	for _, edgeDevice := range edgeDevices {
+		if hasDeployment(&edgeDevice, edgeDeployment.Name) {
+			current = append(current, edgeDevice)
+		} else {
+			candidates = append(candidates, edgeDevice)
+		}
+	}
+	sortByName(current)
+	sortByName(candidates)
+
+	checkCapacity := !capacity.Required(edgeDeployment).IsZero()
+	result := Result{}
+	c := newConstraints(edgeDeployment)
+	for _, edgeDevice := range append(current, candidates...) {
+		if reason := c.reason(&edgeDevice); reason != "" {
+			result.Unplaced = append(result.Unplaced, v1alpha1.UnplacedDevice{Device: edgeDevice.Name, Reason: reason})
+			continue
+		}
+		if checkCapacity && !hasDeployment(&edgeDevice, edgeDeployment.Name) {
+			deployedDeployments, err := deployed(&edgeDevice)
+			if err != nil {
+				return nil, err
+			}
+			err = capacity.Check(&edgeDevice, deployedDeployments, edgeDeployment)
+			if err != nil {
+				result.Unplaced = append(result.Unplaced, v1alpha1.UnplacedDevice{Device: edgeDevice.Name, Reason: err.Error()})
+				continue
+			}
+		}
+		c.add(&edgeDevice)
+		result.Devices = append(result.Devices, edgeDevice)
+	}
+
+	sort.Slice(result.Unplaced, func(i, j int) bool {
+		return result.Unplaced[i].Device < result.Unplaced[j].Device
+	})
+	return &result, nil
+}
+
+// CheckConstraints verifies that the deployment can be deployed to the device in addition to the devices it is
+// already placed on, according to the placement constraints of the deployment. Capacity is not checked.
+func CheckConstraints(edgeDeployment *v1alpha1.EdgeDeployment, placed []v1alpha1.EdgeDevice, edgeDevice *v1alpha1.EdgeDevice) error {
+	c := newConstraints(edgeDeployment)
+	for i := range placed {
+		if placed[i].Name != edgeDevice.Name {
+			c.add(&placed[i])
+		}
+	}
+	if reason := c.reason(edgeDevice); reason != "" {
+		return errors.New(reason)
+	}
+	return nil
+}
+
+// constraints tracks devices placed so far against the placement configuration of a deployment
+type constraints struct {
+	placement *v1alpha1.PlacementConfiguration
+	placed    int32
+	perValue  map[string]int32
+}
+
+func newConstraints(edgeDeployment *v1alpha1.EdgeDeployment) *constraints {
+	return &constraints{
+		placement: edgeDeployment.Spec.Placement,
+		perValue:  map[string]int32{},
+	}
+}
+
+// reason returns why the device cannot be added, or an empty string when it can
+func (c *constraints) reason(edgeDevice *v1alpha1.EdgeDevice) string {
+	if c.placement == nil {
+		return ""
+	}
+	if c.placement.MaxDevices > 0 && c.placed >= c.placement.MaxDevices {
+		return fmt.Sprintf("maximum number of devices (%d) reached", c.placement.MaxDevices)
+	}
+	if spread := c.placement.Spread; spread != nil {
+		value, ok := edgeDevice.Labels[spread.LabelKey]
+		if !ok {
+			return fmt.Sprintf("device does not have label %s", spread.LabelKey)
+		}
+		if c.perValue[value] >= maxPerValue(spread) {
+			return fmt.Sprintf("maximum number of devices (%d) with label %s=%s reached",
+				maxPerValue(spread), spread.LabelKey, value)
+		}
+	}
+	return ""
+}
+
+func (c *constraints) add(edgeDevice *v1alpha1.EdgeDevice) {
+	c.placed++
+	if c.placement != nil && c.placement.Spread != nil {
+		c.perValue[edgeDevice.Labels[c.placement.Spread.LabelKey]]++
+	}
+}
+
+func maxPerValue(spread *v1alpha1.SpreadConstraint) int32 {
+	if spread.MaxPerValue < 1 {
+		return 1
+	}
+	return spread.MaxPerValue
+}
+
+func hasDeployment(edgeDevice *v1alpha1.EdgeDevice, name string) bool {
+	for _, deployment := range edgeDevice.Status.Deployments {
+		if deployment.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func sortByName(edgeDevices []v1alpha1.EdgeDevice) {
+	sort.Slice(edgeDevices, func(i, j int) bool {
+		return edgeDevices[i].Name < edgeDevices[j].Name
+	})
+}
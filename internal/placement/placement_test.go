@@ -0,0 +1,199 @@
+package placement_test
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"github.com/project-flotta/flotta-operator/internal/placement"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Placement", func() {
+	var (
+		edgeDeployment *v1alpha1.EdgeDeployment
+		noDeployed     placement.DeployedFunc
+	)
+
+	device := func(name string, labels map[string]string, deployments ...string) v1alpha1.EdgeDevice {
+		edgeDevice := v1alpha1.EdgeDevice{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+		for _, deployment := range deployments {
+			edgeDevice.Status.Deployments = append(edgeDevice.Status.Deployments, v1alpha1.Deployment{Name: deployment})
+		}
+		return edgeDevice
+	}
+
+	names := func(edgeDevices []v1alpha1.EdgeDevice) []string {
+		var result []string
+		for _, edgeDevice := range edgeDevices {
+			result = append(result, edgeDevice.Name)
+		}
+		return result
+	}
+
+	BeforeEach(func() {
+		edgeDeployment = &v1alpha1.EdgeDeployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "workload"},
+			Spec: v1alpha1.EdgeDeploymentSpec{
+				Pod: v1alpha1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{}}}},
+			},
+		}
+		noDeployed = func(edgeDevice *v1alpha1.EdgeDevice) ([]v1alpha1.EdgeDeployment, error) {
+			return nil, nil
+		}
+	})
+
+	Context("Place", func() {
+		It("should place on all devices without constraints", func() {
+			// when
+			result, err := placement.Place(edgeDeployment, []v1alpha1.EdgeDevice{device("b", nil), device("a", nil)}, noDeployed)
+
+			// then
+			Expect(err).NotTo(HaveOccurred())
+			Expect(names(result.Devices)).To(Equal([]string{"a", "b"}))
+			Expect(result.Unplaced).To(BeEmpty())
+		})
+
+		It("should limit the number of devices and keep current ones", func() {
+			// given
+			edgeDeployment.Spec.Placement = &v1alpha1.PlacementConfiguration{MaxDevices: 2}
+			devices := []v1alpha1.EdgeDevice{device("a", nil), device("b", nil), device("c", nil, "workload")}
+
+			// when
+			result, err := placement.Place(edgeDeployment, devices, noDeployed)
+
+			// then
+			Expect(err).NotTo(HaveOccurred())
+			Expect(names(result.Devices)).To(Equal([]string{"c", "a"}))
+			Expect(result.Unplaced).To(Equal([]v1alpha1.UnplacedDevice{
+				{Device: "b", Reason: "maximum number of devices (2) reached"},
+			}))
+		})
+
+		It("should spread devices by label", func() {
+			// given
+			edgeDeployment.Spec.Placement = &v1alpha1.PlacementConfiguration{
+				Spread: &v1alpha1.SpreadConstraint{LabelKey: "site", MaxPerValue: 1},
+			}
+			devices := []v1alpha1.EdgeDevice{
+				device("a", map[string]string{"site": "x"}),
+				device("b", map[string]string{"site": "x"}),
+				device("c", map[string]string{"site": "y"}),
+				device("d", nil),
+			}
+
+			// when
+			result, err := placement.Place(edgeDeployment, devices, noDeployed)
+
+			// then
+			Expect(err).NotTo(HaveOccurred())
+			Expect(names(result.Devices)).To(Equal([]string{"a", "c"}))
+			Expect(result.Unplaced).To(Equal([]v1alpha1.UnplacedDevice{
+				{Device: "b", Reason: "maximum number of devices (1) with label site=x reached"},
+				{Device: "d", Reason: "device does not have label site"},
+			}))
+		})
+
+		It("should skip devices without enough capacity", func() {
+			// given
+			edgeDeployment.Spec.Pod.Spec.Containers[0].Resources.Requests = corev1.ResourceList{
+				corev1.ResourceMemory: resource.MustParse("1Gi"),
+			}
+			small := device("small", nil)
+			small.Status.Hardware = &v1alpha1.Hardware{Memory: &v1alpha1.Memory{UsableBytes: 512 * 1024 * 1024}}
+			big := device("big", nil)
+			big.Status.Hardware = &v1alpha1.Hardware{Memory: &v1alpha1.Memory{UsableBytes: 2 * 1024 * 1024 * 1024}}
+
+			// when
+			result, err := placement.Place(edgeDeployment, []v1alpha1.EdgeDevice{small, big}, noDeployed)
+
+			// then
+			Expect(err).NotTo(HaveOccurred())
+			Expect(names(result.Devices)).To(Equal([]string{"big"}))
+			Expect(result.Unplaced).To(HaveLen(1))
+			Expect(result.Unplaced[0].Device).To(Equal("small"))
+			Expect(result.Unplaced[0].Reason).To(ContainSubstring("insufficient memory"))
+		})
+
+		It("should not read deployed deployments when no resources are declared", func() {
+			// given
+			failing := func(edgeDevice *v1alpha1.EdgeDevice) ([]v1alpha1.EdgeDeployment, error) {
+				return nil, fmt.Errorf("unexpected call")
+			}
+
+			// when
+			result, err := placement.Place(edgeDeployment, []v1alpha1.EdgeDevice{device("a", nil)}, failing)
+
+			// then
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Devices).To(HaveLen(1))
+		})
+
+		It("should fail when deployed deployments cannot be read", func() {
+			// given
+			edgeDeployment.Spec.Pod.Spec.Containers[0].Resources.Requests = corev1.ResourceList{
+				corev1.ResourceCPU: resource.MustParse("1"),
+			}
+			failing := func(edgeDevice *v1alpha1.EdgeDevice) ([]v1alpha1.EdgeDeployment, error) {
+				return nil, fmt.Errorf("boom")
+			}
+
+			// when
+			_, err := placement.Place(edgeDeployment, []v1alpha1.EdgeDevice{device("a", nil)}, failing)
+
+			// then
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("CheckConstraints", func() {
+		BeforeEach(func() {
+			edgeDeployment.Spec.Placement = &v1alpha1.PlacementConfiguration{
+				MaxDevices: 2,
+				Spread:     &v1alpha1.SpreadConstraint{LabelKey: "site", MaxPerValue: 1},
+			}
+		})
+
+		It("should accept device within constraints", func() {
+			// given
+			placed := []v1alpha1.EdgeDevice{device("a", map[string]string{"site": "x"})}
+			edgeDevice := device("b", map[string]string{"site": "y"})
+
+			// then
+			Expect(placement.CheckConstraints(edgeDeployment, placed, &edgeDevice)).To(Succeed())
+		})
+
+		It("should not count the device itself", func() {
+			// given
+			edgeDevice := device("a", map[string]string{"site": "x"})
+			placed := []v1alpha1.EdgeDevice{edgeDevice, device("b", map[string]string{"site": "y"})}
+
+			// then
+			Expect(placement.CheckConstraints(edgeDeployment, placed, &edgeDevice)).To(Succeed())
+		})
+
+		It("should reject device exceeding spread", func() {
+			// given
+			placed := []v1alpha1.EdgeDevice{device("a", map[string]string{"site": "x"})}
+			edgeDevice := device("b", map[string]string{"site": "x"})
+
+			// then
+			Expect(placement.CheckConstraints(edgeDeployment, placed, &edgeDevice)).NotTo(Succeed())
+		})
+
+		It("should reject device exceeding maximum number of devices", func() {
+			// given
+			placed := []v1alpha1.EdgeDevice{
+				device("a", map[string]string{"site": "x"}),
+				device("b", map[string]string{"site": "y"}),
+			}
+			edgeDevice := device("c", map[string]string{"site": "z"})
+
+			// then
+			Expect(placement.CheckConstraints(edgeDeployment, placed, &edgeDevice)).NotTo(Succeed())
+		})
+	})
+})
@@ -0,0 +1,149 @@
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/project-flotta/flotta-operator/internal/metrics"
+	"github.com/project-flotta/flotta-operator/internal/mtls"
+)
+
+const (
+	// DeviceLimit and RegistrationLimit label the throttled requests metric
+	DeviceLimit       = "device"
+	RegistrationLimit = "registration"
+
+	// idleTimeout is how long the bucket of a key is kept after its last request
+	idleTimeout = 10 * time.Minute
+)
+
+// Limits of a token bucket: PerMinute tokens are added every minute, up to Burst tokens, at least 1. PerMinute 0 does
+// not limit the requests.
+type Limits struct {
+	PerMinute uint
+	Burst     uint
+}
+
+// Config of the rate limiting of the device API
+type Config struct {
+	// Device limits the requests of registered devices per device, identified by the device ID and the namespace of
+	// their client certificate
+	Device Limits
+
+	// Registration limits the registration requests per source IP
+	Registration Limits
+}
+
+// Limiter rate limits the requests of the device API with a token bucket per key. It protects the operator, and the
+// Kubernetes API the requests are served from, from devices polling in a tight loop.
+type Limiter struct {
+	config  Config
+	metrics metrics.Metrics
+	lock    sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+	now     func() time.Time
+}
+
+type bucket struct {
+	limiter *rate.Limiter
+	last    time.Time
+}
+
+func New(config Config, metrics metrics.Metrics) *Limiter {
+	return &Limiter{
+		config:  config,
+		metrics: metrics,
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+// Middleware rejects the requests exceeding their limits with 429 Too Many Requests and a Retry-After header.
+// isRegistration tells the registration requests apart, which are limited per source IP instead of per device.
+func (l *Limiter) Middleware(isRegistration func(r *http.Request) bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit, limits, key := DeviceLimit, l.config.Device, deviceKey(r)
+			if isRegistration(r) {
+				limit, limits, key = RegistrationLimit, l.config.Registration, "ip:"+sourceIP(r)
+			}
+			if wait := l.reserve(limits, key); wait > 0 {
+				l.metrics.IncEdgeDeviceThrottledRequest(limit)
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// reserve takes a token from the bucket of the key, and returns how long to wait for the request to be allowed when
+// the bucket is empty; in that case no token is taken
+func (l *Limiter) reserve(limits Limits, key string) time.Duration {
+	if limits.PerMinute == 0 || key == "" {
+		return 0
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		burst := int(limits.Burst)
+		if burst < 1 {
+			burst = 1
+		}
+		b = &bucket{limiter: rate.NewLimiter(rate.Every(time.Minute/time.Duration(limits.PerMinute)), burst)}
+		l.buckets[key] = b
+	}
+	b.last = now
+	reservation := b.limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		return time.Minute
+	}
+	wait := reservation.DelayFrom(now)
+	if wait > 0 {
+		reservation.CancelAt(now)
+	}
+	return wait
+}
+
+// sweep forgets the buckets of the keys without requests for the idle timeout, at most once per idle timeout
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < idleTimeout {
+		return
+	}
+	l.swept = now
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > idleTimeout {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// deviceKey returns the key the request of a registered device is limited by: the device ID and the namespace recorded
+// in its client certificate, as devices of different namespaces can have the same ID. The device ID of the path is not
+// verified yet, and would let a device exhaust the limit of another device.
+func deviceKey(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return ""
+	}
+	return "device:" + mtls.DeviceNamespace(r) + "/" + r.TLS.PeerCertificates[0].Subject.CommonName
+}
+
+func sourceIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,171 @@
+package ratelimit_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/project-flotta/flotta-operator/internal/metrics"
+	"github.com/project-flotta/flotta-operator/internal/ratelimit"
+)
+
+var _ = Describe("Rate limiting", func() {
+	var (
+		mockCtrl    *gomock.Controller
+		metricsMock *metrics.MockMetrics
+		handler     http.Handler
+	)
+
+	isRegistration := func(r *http.Request) bool {
+		return r.Header.Get("X-Registration") != ""
+	}
+
+	request := func(deviceID, commonName, remoteAddr string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/api/flotta-management/v1/data/"+deviceID+"/in", nil)
+		r.RemoteAddr = remoteAddr
+		if commonName != "" {
+			r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: commonName}}}}
+		}
+		return r
+	}
+
+	registration := func(remoteAddr string) *http.Request {
+		r := request("new-device", "register", remoteAddr)
+		r.Header.Set("X-Registration", "true")
+		return r
+	}
+
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, r)
+		return recorder
+	}
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		metricsMock = metrics.NewMockMetrics(mockCtrl)
+		limiter := ratelimit.New(ratelimit.Config{
+			Device:       ratelimit.Limits{PerMinute: 1, Burst: 2},
+			Registration: ratelimit.Limits{PerMinute: 1, Burst: 1},
+		}, metricsMock)
+		handler = limiter.Middleware(isRegistration)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	It("should allow the burst of a device and then throttle it", func() {
+		// given
+		Expect(serve(request("device1", "device1", "10.0.0.1:1000")).Code).To(Equal(http.StatusOK))
+		Expect(serve(request("device1", "device1", "10.0.0.1:1000")).Code).To(Equal(http.StatusOK))
+		metricsMock.EXPECT().IncEdgeDeviceThrottledRequest(ratelimit.DeviceLimit).Times(1)
+
+		// when
+		res := serve(request("device1", "device1", "10.0.0.1:1000"))
+
+		// then
+		Expect(res.Code).To(Equal(http.StatusTooManyRequests))
+		Expect(res.Header().Get("Retry-After")).To(Equal("60"))
+	})
+
+	It("should limit each device separately", func() {
+		// given
+		Expect(serve(request("device1", "device1", "10.0.0.1:1000")).Code).To(Equal(http.StatusOK))
+		Expect(serve(request("device1", "device1", "10.0.0.1:1000")).Code).To(Equal(http.StatusOK))
+
+		// when
+		res := serve(request("device2", "device2", "10.0.0.1:1000"))
+
+		// then
+		Expect(res.Code).To(Equal(http.StatusOK))
+	})
+
+	It("should limit a certificate used for several device IDs", func() {
+		// given
+		Expect(serve(request("device1", "device1", "10.0.0.1:1000")).Code).To(Equal(http.StatusOK))
+		Expect(serve(request("device2", "device1", "10.0.0.1:1000")).Code).To(Equal(http.StatusOK))
+		metricsMock.EXPECT().IncEdgeDeviceThrottledRequest(ratelimit.DeviceLimit).Times(1)
+
+		// when
+		res := serve(request("device3", "device1", "10.0.0.1:1000"))
+
+		// then
+		Expect(res.Code).To(Equal(http.StatusTooManyRequests))
+	})
+
+	It("should limit devices with the same ID in different namespaces separately", func() {
+		// given
+		Expect(serve(request("device1", "device1", "10.0.0.1:1000")).Code).To(Equal(http.StatusOK))
+		Expect(serve(request("device1", "device1", "10.0.0.1:1000")).Code).To(Equal(http.StatusOK))
+		r := request("device1", "device1", "10.0.0.1:1000")
+		r.TLS.PeerCertificates[0].Subject.OrganizationalUnit = []string{"site-a"}
+
+		// when
+		res := serve(r)
+
+		// then
+		Expect(res.Code).To(Equal(http.StatusOK))
+	})
+
+	It("should not limit a device with the requests of another device for its ID", func() {
+		// given
+		Expect(serve(request("device1", "device2", "10.0.0.1:1000")).Code).To(Equal(http.StatusOK))
+		Expect(serve(request("device1", "device2", "10.0.0.1:1000")).Code).To(Equal(http.StatusOK))
+
+		// when
+		res := serve(request("device1", "device1", "10.0.0.1:1000"))
+
+		// then
+		Expect(res.Code).To(Equal(http.StatusOK))
+	})
+
+	It("should limit registrations per source IP", func() {
+		// given
+		Expect(serve(registration("10.0.0.1:1000")).Code).To(Equal(http.StatusOK))
+		metricsMock.EXPECT().IncEdgeDeviceThrottledRequest(ratelimit.RegistrationLimit).Times(1)
+
+		// when
+		throttled := serve(registration("10.0.0.1:2000"))
+		other := serve(registration("10.0.0.2:1000"))
+
+		// then
+		Expect(throttled.Code).To(Equal(http.StatusTooManyRequests))
+		Expect(throttled.Header().Get("Retry-After")).To(Equal("60"))
+		Expect(other.Code).To(Equal(http.StatusOK))
+	})
+
+	It("should not limit registrations with device requests", func() {
+		// given
+		Expect(serve(request("device1", "device1", "10.0.0.1:1000")).Code).To(Equal(http.StatusOK))
+		Expect(serve(request("device1", "device1", "10.0.0.1:1000")).Code).To(Equal(http.StatusOK))
+
+		// when
+		res := serve(registration("10.0.0.1:1000"))
+
+		// then
+		Expect(res.Code).To(Equal(http.StatusOK))
+	})
+
+	It("should not limit requests when the rate is not set", func() {
+		// given
+		handler = ratelimit.New(ratelimit.Config{}, metricsMock).Middleware(isRegistration)(
+			http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+		// then
+		for i := 0; i < 10; i++ {
+			Expect(serve(request("device1", "device1", "10.0.0.1:1000")).Code).To(Equal(http.StatusOK))
+			Expect(serve(registration("10.0.0.1:1000")).Code).To(Equal(http.StatusOK))
+		}
+	})
+})
@@ -0,0 +1,204 @@
+package references
+
+import (
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+)
+
+// The references collected here are the Secrets, ConfigMaps and MetricsAllowLists, in the namespace of the referring
+// object, read when the configuration of a device is rendered. They are a superset of what is actually read, e.g. a
+// volume secret, so that no change to the configuration is missed.
+
+// EdgeDeploymentSecrets returns the names of the Secrets the EdgeDeployment refers to
+func EdgeDeploymentSecrets(deployment *v1alpha1.EdgeDeployment) []string {
+	names := nameSet{}
+	forEachContainer(&deployment.Spec.Pod.Spec, func(container *corev1.Container) {
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.SecretRef != nil {
+				names.add(envFrom.SecretRef.Name)
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+				names.add(env.ValueFrom.SecretKeyRef.Name)
+			}
+		}
+	})
+	for _, volume := range deployment.Spec.Pod.Spec.Volumes {
+		if volume.Secret != nil {
+			names.add(volume.Secret.SecretName)
+		}
+	}
+	if registries := deployment.Spec.ImageRegistries; registries != nil && registries.AuthFileSecret != nil {
+		names.add(registries.AuthFileSecret.Name)
+	}
+	return names.list()
+}
+
+// EdgeDeploymentConfigMaps returns the names of the ConfigMaps the EdgeDeployment refers to, including its metrics
+// allow-list
+func EdgeDeploymentConfigMaps(deployment *v1alpha1.EdgeDeployment) []string {
+	names := nameSet{}
+	forEachContainer(&deployment.Spec.Pod.Spec, func(container *corev1.Container) {
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.ConfigMapRef != nil {
+				names.add(envFrom.ConfigMapRef.Name)
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom != nil && env.ValueFrom.ConfigMapKeyRef != nil {
+				names.add(env.ValueFrom.ConfigMapKeyRef.Name)
+			}
+		}
+	})
+	for _, volume := range deployment.Spec.Pod.Spec.Volumes {
+		if volume.ConfigMap != nil {
+			names.add(volume.ConfigMap.Name)
+		}
+	}
+	if metrics := deployment.Spec.Metrics; metrics != nil && metrics.AllowList != nil {
+		names.add(metrics.AllowList.Name)
+	}
+	return names.list()
+}
+
+// EdgeDeviceSecrets returns the names of the Secrets the EdgeDevice refers to: its storage credentials and syslog TLS
+// credentials
+func EdgeDeviceSecrets(device *v1alpha1.EdgeDevice) []string {
+	names := nameSet{}
+	addStorageSecrets(names, device.Spec.Storage)
+	addLogCollectionSecrets(names, device.Spec.LogCollection)
+	if device.Status.DataOBC != nil {
+		names.add(*device.Status.DataOBC)
+	}
+	return names.list()
+}
+
+// EdgeDeviceConfigMaps returns the names of the ConfigMaps the EdgeDevice refers to: its storage, metrics allow-list
+// and syslog configurations
+func EdgeDeviceConfigMaps(device *v1alpha1.EdgeDevice) []string {
+	names := nameSet{}
+	addDeviceConfigMaps(names, device.Spec.Storage, device.Spec.Metrics, device.Spec.LogCollection)
+	if device.Status.DataOBC != nil {
+		names.add(*device.Status.DataOBC)
+	}
+	return names.list()
+}
+
+// EdgeDeviceSetSecrets returns the names of the Secrets the EdgeDeviceSet refers to: the storage and syslog TLS
+// credentials of its members
+func EdgeDeviceSetSecrets(set *v1alpha1.EdgeDeviceSet) []string {
+	names := nameSet{}
+	addStorageSecrets(names, set.Spec.Storage)
+	addLogCollectionSecrets(names, set.Spec.LogCollection)
+	return names.list()
+}
+
+// EdgeDeviceSetConfigMaps returns the names of the ConfigMaps the EdgeDeviceSet refers to: the storage, metrics
+// allow-list and syslog configurations of its members
+func EdgeDeviceSetConfigMaps(set *v1alpha1.EdgeDeviceSet) []string {
+	names := nameSet{}
+	addDeviceConfigMaps(names, set.Spec.Storage, set.Spec.Metrics, set.Spec.LogCollection)
+	return names.list()
+}
+
+// EdgeDeviceMetricsAllowLists returns the names of the MetricsAllowLists the system metrics of the EdgeDevice refer to
+func EdgeDeviceMetricsAllowLists(device *v1alpha1.EdgeDevice) []string {
+	names := nameSet{}
+	addSystemMetricsAllowLists(names, device.Spec.Metrics)
+	return names.list()
+}
+
+// EdgeDeviceSetMetricsAllowLists returns the names of the MetricsAllowLists the system metrics of the EdgeDeviceSet
+// refer to
+func EdgeDeviceSetMetricsAllowLists(set *v1alpha1.EdgeDeviceSet) []string {
+	names := nameSet{}
+	addSystemMetricsAllowLists(names, set.Spec.Metrics)
+	return names.list()
+}
+
+// EdgeDeploymentMetricsAllowLists returns the names of the MetricsAllowLists the workload metrics of the
+// EdgeDeployment refer to
+func EdgeDeploymentMetricsAllowLists(deployment *v1alpha1.EdgeDeployment) []string {
+	names := nameSet{}
+	if metrics := deployment.Spec.Metrics; metrics != nil && metrics.MetricsAllowList != nil {
+		names.add(metrics.MetricsAllowList.Name)
+	}
+	return names.list()
+}
+
+// MetricsAllowListReferences returns the names of the MetricsAllowLists the MetricsAllowList extends and includes
+func MetricsAllowListReferences(allowList *v1alpha1.MetricsAllowList) []string {
+	names := nameSet{}
+	if allowList.Spec.Extends != nil {
+		names.add(allowList.Spec.Extends.Name)
+	}
+	for _, include := range allowList.Spec.Includes {
+		names.add(include.Name)
+	}
+	return names.list()
+}
+
+func addSystemMetricsAllowLists(names nameSet, metrics *v1alpha1.MetricsConfiguration) {
+	if metrics != nil && metrics.SystemMetrics != nil && metrics.SystemMetrics.MetricsAllowList != nil {
+		names.add(metrics.SystemMetrics.MetricsAllowList.Name)
+	}
+}
+
+func addStorageSecrets(names nameSet, storage *v1alpha1.Storage) {
+	if storage != nil && storage.S3 != nil {
+		names.add(storage.S3.SecretName)
+	}
+}
+
+func addLogCollectionSecrets(names nameSet, logCollection map[string]*v1alpha1.LogCollectionConfig) {
+	for _, config := range logCollection {
+		if config != nil && config.SyslogOptions != nil && config.SyslogOptions.TLS != nil {
+			names.add(config.SyslogOptions.TLS.SecretRef.Name)
+		}
+	}
+}
+
+func addDeviceConfigMaps(names nameSet, storage *v1alpha1.Storage, metrics *v1alpha1.MetricsConfiguration,
+	logCollection map[string]*v1alpha1.LogCollectionConfig) {
+	if storage != nil && storage.S3 != nil {
+		names.add(storage.S3.ConfigMapName)
+	}
+	if metrics != nil && metrics.SystemMetrics != nil && metrics.SystemMetrics.AllowList != nil {
+		names.add(metrics.SystemMetrics.AllowList.Name)
+	}
+	for _, config := range logCollection {
+		if config != nil && config.SyslogConfig != nil {
+			names.add(config.SyslogConfig.Name)
+		}
+	}
+}
+
+func forEachContainer(spec *corev1.PodSpec, f func(container *corev1.Container)) {
+	for i := range spec.InitContainers {
+		f(&spec.InitContainers[i])
+	}
+	for i := range spec.Containers {
+		f(&spec.Containers[i])
+	}
+}
+
+type nameSet map[string]struct{}
+
+func (s nameSet) add(name string) {
+	if name != "" {
+		s[name] = struct{}{}
+	}
+}
+
+func (s nameSet) list() []string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
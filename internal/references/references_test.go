@@ -0,0 +1,109 @@
+package references_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"github.com/project-flotta/flotta-operator/internal/references"
+)
+
+var _ = Describe("References", func() {
+
+	It("EdgeDeployment references are collected from containers, volumes, registries and metrics", func() {
+		// given
+		deployment := &v1alpha1.EdgeDeployment{
+			Spec: v1alpha1.EdgeDeploymentSpec{
+				Pod: v1alpha1.Pod{Spec: corev1.PodSpec{
+					InitContainers: []corev1.Container{{
+						EnvFrom: []corev1.EnvFromSource{
+							{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "init-secret"}}},
+						},
+					}},
+					Containers: []corev1.Container{{
+						EnvFrom: []corev1.EnvFromSource{
+							{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "env-config"}}},
+						},
+						Env: []corev1.EnvVar{
+							{Name: "A", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+								LocalObjectReference: corev1.LocalObjectReference{Name: "key-secret"}, Key: "a"}}},
+							{Name: "B", ValueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+								LocalObjectReference: corev1.LocalObjectReference{Name: "env-config"}, Key: "b"}}},
+							{Name: "C", Value: "c"},
+						},
+					}},
+					Volumes: []corev1.Volume{
+						{VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "volume-secret"}}},
+						{VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+							LocalObjectReference: corev1.LocalObjectReference{Name: "volume-config"}}}},
+					},
+				}},
+				ImageRegistries: &v1alpha1.ImageRegistriesConfiguration{AuthFileSecret: &v1alpha1.NameRef{Name: "auth"}},
+				Metrics: &v1alpha1.ContainerMetricsConfiguration{
+					AllowList:        &v1alpha1.NameRef{Name: "allow-list"},
+					MetricsAllowList: &v1alpha1.NameRef{Name: "workload-metrics"},
+				},
+			},
+		}
+
+		// then
+		Expect(references.EdgeDeploymentSecrets(deployment)).To(Equal([]string{"auth", "init-secret", "key-secret", "volume-secret"}))
+		Expect(references.EdgeDeploymentConfigMaps(deployment)).To(Equal([]string{"allow-list", "env-config", "volume-config"}))
+		Expect(references.EdgeDeploymentMetricsAllowLists(deployment)).To(Equal([]string{"workload-metrics"}))
+	})
+
+	It("EdgeDevice references are collected from storage, metrics and log collection", func() {
+		// given
+		obc := "device-obc"
+		device := &v1alpha1.EdgeDevice{
+			Spec: v1alpha1.EdgeDeviceSpec{
+				Storage: &v1alpha1.Storage{S3: &v1alpha1.S3Storage{SecretName: "s3-secret", ConfigMapName: "s3-config"}},
+				Metrics: &v1alpha1.MetricsConfiguration{
+					SystemMetrics: &v1alpha1.SystemMetricsConfiguration{
+						AllowList:        &v1alpha1.NameRef{Name: "system-allow-list"},
+						MetricsAllowList: &v1alpha1.NameRef{Name: "system-metrics"},
+					},
+				},
+				LogCollection: map[string]*v1alpha1.LogCollectionConfig{
+					"syslog": {
+						Kind:         "syslog",
+						SyslogConfig: &v1alpha1.NameRef{Name: "syslog-config"},
+						SyslogOptions: &v1alpha1.SyslogOptions{
+							TLS: &v1alpha1.SyslogTLS{SecretRef: v1alpha1.NameRef{Name: "syslog-tls"}},
+						},
+					},
+				},
+			},
+			Status: v1alpha1.EdgeDeviceStatus{DataOBC: &obc},
+		}
+
+		// then
+		Expect(references.EdgeDeviceSecrets(device)).To(Equal([]string{"device-obc", "s3-secret", "syslog-tls"}))
+		Expect(references.EdgeDeviceConfigMaps(device)).To(Equal([]string{"device-obc", "s3-config", "syslog-config", "system-allow-list"}))
+		Expect(references.EdgeDeviceMetricsAllowLists(device)).To(Equal([]string{"system-metrics"}))
+	})
+
+	It("EdgeDeviceSet without references", func() {
+		// given
+		set := &v1alpha1.EdgeDeviceSet{}
+
+		// then
+		Expect(references.EdgeDeviceSetSecrets(set)).To(BeEmpty())
+		Expect(references.EdgeDeviceSetConfigMaps(set)).To(BeEmpty())
+		Expect(references.EdgeDeviceSetMetricsAllowLists(set)).To(BeEmpty())
+	})
+
+	It("MetricsAllowList references are collected from extends and includes", func() {
+		// given
+		allowList := &v1alpha1.MetricsAllowList{
+			Spec: v1alpha1.MetricsAllowListSpec{
+				Extends:  &v1alpha1.NameRef{Name: "base"},
+				Includes: []v1alpha1.NameRef{{Name: "disk"}, {Name: "base"}},
+			},
+		}
+
+		// then
+		Expect(references.MetricsAllowListReferences(allowList)).To(Equal([]string{"base", "disk"}))
+	})
+})
@@ -14,6 +14,7 @@ import (
 type Repository interface {
 	Read(ctx context.Context, name string, namespace string) (*v1alpha1.EdgeDeployment, error)
 	Patch(ctx context.Context, old, new *v1alpha1.EdgeDeployment) error
+	PatchStatus(ctx context.Context, edgeDeployment *v1alpha1.EdgeDeployment, patch *client.Patch) error
 	RemoveFinalizer(ctx context.Context, edgeDeployment *v1alpha1.EdgeDeployment, finalizer string) error
 	ListByLabel(ctx context.Context, labelName, labelValue string) ([]v1alpha1.EdgeDeployment, error)
 }
@@ -37,6 +38,10 @@ func (r *CRRespository) Patch(ctx context.Context, old, new *v1alpha1.EdgeDeploy
 	return r.client.Patch(ctx, new, patch)
 }
 
+func (r *CRRespository) PatchStatus(ctx context.Context, edgeDeployment *v1alpha1.EdgeDeployment, patch *client.Patch) error {
+	return r.client.Status().Patch(ctx, edgeDeployment, *patch)
+}
+
 func (r *CRRespository) RemoveFinalizer(ctx context.Context, edgeDeployment *v1alpha1.EdgeDeployment, finalizer string) error {
 	cp := edgeDeployment.DeepCopy()
 
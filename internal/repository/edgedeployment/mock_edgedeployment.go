@@ -10,6 +10,7 @@ import (
 
 	gomock "github.com/golang/mock/gomock"
 	v1alpha1 "github.com/project-flotta/flotta-operator/api/v1alpha1"
+	client "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // MockRepository is a mock of Repository interface.
@@ -64,6 +65,20 @@ func (mr *MockRepositoryMockRecorder) Patch(arg0, arg1, arg2 interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Patch", reflect.TypeOf((*MockRepository)(nil).Patch), arg0, arg1, arg2)
 }
 
+// PatchStatus mocks base method.
+func (m *MockRepository) PatchStatus(arg0 context.Context, arg1 *v1alpha1.EdgeDeployment, arg2 *client.Patch) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PatchStatus", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PatchStatus indicates an expected call of PatchStatus.
+func (mr *MockRepositoryMockRecorder) PatchStatus(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PatchStatus", reflect.TypeOf((*MockRepository)(nil).PatchStatus), arg0, arg1, arg2)
+}
+
 // Read mocks base method.
 func (m *MockRepository) Read(arg0 context.Context, arg1, arg2 string) (*v1alpha1.EdgeDeployment, error) {
 	m.ctrl.T.Helper()
@@ -16,6 +16,7 @@ type Repository interface {
 	Create(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice) error
 	PatchStatus(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, patch *client.Patch) error
 	Patch(ctx context.Context, old, new *v1alpha1.EdgeDevice) error
+	Delete(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice) error
 	ListForSelector(ctx context.Context, selector *metav1.LabelSelector, namespace string) ([]v1alpha1.EdgeDevice, error)
 	RemoveFinalizer(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, finalizer string) error
 	UpdateLabels(ctx context.Context, device *v1alpha1.EdgeDevice, labels map[string]string) error
@@ -48,6 +49,10 @@ func (r *CRRepository) Patch(ctx context.Context, old, new *v1alpha1.EdgeDevice)
 	return r.client.Patch(ctx, new, patch)
 }
 
+func (r *CRRepository) Delete(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice) error {
+	return r.client.Delete(ctx, edgeDevice)
+}
+
 func (r CRRepository) ListForSelector(ctx context.Context, selector *metav1.LabelSelector, namespace string) ([]v1alpha1.EdgeDevice, error) {
 	s, err := metav1.LabelSelectorAsSelector(selector)
 	if err != nil {
@@ -0,0 +1,39 @@
+package edgedevicemigration
+
+import (
+	"context"
+
+	_ "github.com/golang/mock/mockgen/model"
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+//go:generate mockgen -package=edgedevicemigration -destination=mock_edgedevicemigration.go . Repository
+type Repository interface {
+	Read(ctx context.Context, name string, namespace string) (*v1alpha1.EdgeDeviceMigration, error)
+	Patch(ctx context.Context, old, new *v1alpha1.EdgeDeviceMigration) error
+	PatchStatus(ctx context.Context, edgeDeviceMigration *v1alpha1.EdgeDeviceMigration, patch *client.Patch) error
+}
+
+type CRRepository struct {
+	client client.Client
+}
+
+func NewEdgeDeviceMigrationRepository(client client.Client) *CRRepository {
+	return &CRRepository{client: client}
+}
+
+func (r *CRRepository) Read(ctx context.Context, name string, namespace string) (*v1alpha1.EdgeDeviceMigration, error) {
+	edgeDeviceMigration := v1alpha1.EdgeDeviceMigration{}
+	err := r.client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, &edgeDeviceMigration)
+	return &edgeDeviceMigration, err
+}
+
+func (r *CRRepository) Patch(ctx context.Context, old, new *v1alpha1.EdgeDeviceMigration) error {
+	patch := client.MergeFrom(old)
+	return r.client.Patch(ctx, new, patch)
+}
+
+func (r *CRRepository) PatchStatus(ctx context.Context, edgeDeviceMigration *v1alpha1.EdgeDeviceMigration, patch *client.Patch) error {
+	return r.client.Status().Patch(ctx, edgeDeviceMigration, *patch)
+}
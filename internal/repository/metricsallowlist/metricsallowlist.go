@@ -0,0 +1,78 @@
+package metricsallowlist
+
+import (
+	"context"
+
+	_ "github.com/golang/mock/mockgen/model"
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ReferencesIndexKey indexes EdgeDevices, EdgeDeviceSets, EdgeDeployments and MetricsAllowLists by the
+// MetricsAllowLists they refer to
+const ReferencesIndexKey = "configuration.metricsallowlists"
+
+//go:generate mockgen -package=metricsallowlist -destination=mock_metricsallowlist.go . Repository
+type Repository interface {
+	Read(ctx context.Context, name string, namespace string) (*v1alpha1.MetricsAllowList, error)
+	PatchStatus(ctx context.Context, allowList *v1alpha1.MetricsAllowList, patch *client.Patch) error
+
+	// ListReferring returns the MetricsAllowLists extending or including the MetricsAllowList
+	ListReferring(ctx context.Context, name string, namespace string) ([]v1alpha1.MetricsAllowList, error)
+
+	// ListReferringEdgeDevices returns the EdgeDevices whose system metrics refer to the MetricsAllowList
+	ListReferringEdgeDevices(ctx context.Context, name string, namespace string) ([]v1alpha1.EdgeDevice, error)
+
+	// ListReferringEdgeDeviceSets returns the EdgeDeviceSets whose system metrics refer to the MetricsAllowList
+	ListReferringEdgeDeviceSets(ctx context.Context, name string, namespace string) ([]v1alpha1.EdgeDeviceSet, error)
+
+	// ListReferringEdgeDeployments returns the EdgeDeployments whose workload metrics refer to the MetricsAllowList
+	ListReferringEdgeDeployments(ctx context.Context, name string, namespace string) ([]v1alpha1.EdgeDeployment, error)
+}
+
+// CRRepository relies on the ReferencesIndexKey field index to list the objects referring to a MetricsAllowList
+type CRRepository struct {
+	client client.Client
+}
+
+func NewMetricsAllowListRepository(client client.Client) *CRRepository {
+	return &CRRepository{client: client}
+}
+
+func (r *CRRepository) Read(ctx context.Context, name string, namespace string) (*v1alpha1.MetricsAllowList, error) {
+	allowList := v1alpha1.MetricsAllowList{}
+	err := r.client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, &allowList)
+	return &allowList, err
+}
+
+func (r *CRRepository) PatchStatus(ctx context.Context, allowList *v1alpha1.MetricsAllowList, patch *client.Patch) error {
+	return r.client.Status().Patch(ctx, allowList, *patch)
+}
+
+func (r *CRRepository) ListReferring(ctx context.Context, name string, namespace string) ([]v1alpha1.MetricsAllowList, error) {
+	var list v1alpha1.MetricsAllowListList
+	err := r.client.List(ctx, &list, referring(name, namespace)...)
+	return list.Items, err
+}
+
+func (r *CRRepository) ListReferringEdgeDevices(ctx context.Context, name string, namespace string) ([]v1alpha1.EdgeDevice, error) {
+	var list v1alpha1.EdgeDeviceList
+	err := r.client.List(ctx, &list, referring(name, namespace)...)
+	return list.Items, err
+}
+
+func (r *CRRepository) ListReferringEdgeDeviceSets(ctx context.Context, name string, namespace string) ([]v1alpha1.EdgeDeviceSet, error) {
+	var list v1alpha1.EdgeDeviceSetList
+	err := r.client.List(ctx, &list, referring(name, namespace)...)
+	return list.Items, err
+}
+
+func (r *CRRepository) ListReferringEdgeDeployments(ctx context.Context, name string, namespace string) ([]v1alpha1.EdgeDeployment, error) {
+	var list v1alpha1.EdgeDeploymentList
+	err := r.client.List(ctx, &list, referring(name, namespace)...)
+	return list.Items, err
+}
+
+func referring(name, namespace string) []client.ListOption {
+	return []client.ListOption{client.InNamespace(namespace), client.MatchingFields{ReferencesIndexKey: name}}
+}
@@ -0,0 +1,33 @@
+package registrationtoken
+
+import (
+	"context"
+
+	_ "github.com/golang/mock/mockgen/model"
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+//go:generate mockgen -package=registrationtoken -destination=mock_registrationtoken.go . Repository
+type Repository interface {
+	Read(ctx context.Context, name string, namespace string) (*v1alpha1.RegistrationToken, error)
+	PatchStatus(ctx context.Context, token *v1alpha1.RegistrationToken, patch *client.Patch) error
+}
+
+type CRRepository struct {
+	client client.Client
+}
+
+func NewRegistrationTokenRepository(client client.Client) *CRRepository {
+	return &CRRepository{client: client}
+}
+
+func (r *CRRepository) Read(ctx context.Context, name string, namespace string) (*v1alpha1.RegistrationToken, error) {
+	token := v1alpha1.RegistrationToken{}
+	err := r.client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, &token)
+	return &token, err
+}
+
+func (r *CRRepository) PatchStatus(ctx context.Context, token *v1alpha1.RegistrationToken, patch *client.Patch) error {
+	return r.client.Status().Patch(ctx, token, *patch)
+}
@@ -0,0 +1,59 @@
+package site
+
+import (
+	"context"
+
+	_ "github.com/golang/mock/mockgen/model"
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ReferencesIndexKey indexes EdgeDevices by the Site they are located at, and Sites by their parent
+const ReferencesIndexKey = "site.references"
+
+//go:generate mockgen -package=site -destination=mock_site.go . Repository
+type Repository interface {
+	Read(ctx context.Context, name string, namespace string) (*v1alpha1.Site, error)
+	PatchStatus(ctx context.Context, site *v1alpha1.Site, patch *client.Patch) error
+
+	// ListChildren returns the Sites whose parent is the Site
+	ListChildren(ctx context.Context, name string, namespace string) ([]v1alpha1.Site, error)
+
+	// ListReferringEdgeDevices returns the EdgeDevices located at the Site, not at the Sites below it
+	ListReferringEdgeDevices(ctx context.Context, name string, namespace string) ([]v1alpha1.EdgeDevice, error)
+}
+
+// CRRepository relies on the ReferencesIndexKey field index to list the objects referring to a Site
+type CRRepository struct {
+	client client.Client
+}
+
+func NewSiteRepository(client client.Client) *CRRepository {
+	return &CRRepository{client: client}
+}
+
+func (r *CRRepository) Read(ctx context.Context, name string, namespace string) (*v1alpha1.Site, error) {
+	site := v1alpha1.Site{}
+	err := r.client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, &site)
+	return &site, err
+}
+
+func (r *CRRepository) PatchStatus(ctx context.Context, site *v1alpha1.Site, patch *client.Patch) error {
+	return r.client.Status().Patch(ctx, site, *patch)
+}
+
+func (r *CRRepository) ListChildren(ctx context.Context, name string, namespace string) ([]v1alpha1.Site, error) {
+	var list v1alpha1.SiteList
+	err := r.client.List(ctx, &list, referring(name, namespace)...)
+	return list.Items, err
+}
+
+func (r *CRRepository) ListReferringEdgeDevices(ctx context.Context, name string, namespace string) ([]v1alpha1.EdgeDevice, error) {
+	var list v1alpha1.EdgeDeviceList
+	err := r.client.List(ctx, &list, referring(name, namespace)...)
+	return list.Items, err
+}
+
+func referring(name, namespace string) []client.ListOption {
+	return []client.ListOption{client.InNamespace(namespace), client.MatchingFields{ReferencesIndexKey: name}}
+}
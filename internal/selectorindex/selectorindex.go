@@ -0,0 +1,301 @@
+package selectorindex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	toolscache "k8s.io/client-go/tools/cache"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+)
+
+// ErrNotSynced is returned by lookups done before the index is populated with all the EdgeDeployments
+var ErrNotSynced = errors.New("EdgeDeployment selector index is not synced yet")
+
+// entry is an indexed EdgeDeployment
+type entry struct {
+	deployment *v1alpha1.EdgeDeployment
+	// selector is nil when the deployment targets the device by name only or its device selector is not valid
+	selector labels.Selector
+}
+
+// namespaceIndex indexes the EdgeDeployments of a namespace
+type namespaceIndex struct {
+	// entries by deployment name
+	entries map[string]*entry
+	// byDevice holds the deployments targeting a device by name, by device name
+	byDevice map[string]map[string]*entry
+	// byKey holds the deployments whose selector requires a label key to be present on the device, by that key
+	byKey map[string]map[string]*entry
+	// unkeyed holds the deployments whose selector can match devices without any label, e.g. a NotIn selector
+	unkeyed map[string]*entry
+}
+
+// Index answers which EdgeDeployments target an EdgeDevice, either by name or by device selector, without listing
+// EdgeDeployments. Selectors are indexed by a label key devices must have to match them, so a lookup only evaluates
+// the selectors using the keys of the device labels.
+type Index struct {
+	lock       sync.RWMutex
+	namespaces map[string]*namespaceIndex
+	synced     bool
+	cache      cache.Cache
+}
+
+func New() *Index {
+	return &Index{namespaces: map[string]*namespaceIndex{}}
+}
+
+// NewSynced returns a synced index of the EdgeDeployments, for users of the index that do not run a manager
+func NewSynced(edgeDeployments ...v1alpha1.EdgeDeployment) *Index {
+	index := New()
+	for i := range edgeDeployments {
+		index.Update(&edgeDeployments[i])
+	}
+	index.synced = true
+	return index
+}
+
+// SetupWithManager populates the index from the EdgeDeployment informer of the manager cache once the manager starts
+func (i *Index) SetupWithManager(mgr ctrl.Manager) error {
+	i.cache = mgr.GetCache()
+	return mgr.Add(i)
+}
+
+// Start keeps the index up to date with the EdgeDeployment informer. It implements manager.Runnable.
+func (i *Index) Start(ctx context.Context) error {
+	informer, err := i.cache.GetInformer(ctx, &v1alpha1.EdgeDeployment{})
+	if err != nil {
+		return err
+	}
+	informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: i.onUpdate,
+		UpdateFunc: func(_, newObj interface{}) {
+			i.onUpdate(newObj)
+		},
+		DeleteFunc: i.onDelete,
+	})
+	if !i.cache.WaitForCacheSync(ctx) {
+		return fmt.Errorf("cannot sync the EdgeDeployment cache")
+	}
+
+	// the informer replays the existing deployments to the handler asynchronously; listing them marks the point
+	// from which the index is complete
+	var edgeDeployments v1alpha1.EdgeDeploymentList
+	err = i.cache.List(ctx, &edgeDeployments)
+	if err != nil {
+		return err
+	}
+	for j := range edgeDeployments.Items {
+		i.Update(&edgeDeployments.Items[j])
+	}
+	i.lock.Lock()
+	i.synced = true
+	i.lock.Unlock()
+	log.FromContext(ctx).Info("EdgeDeployment selector index synced", "edgeDeployments", len(edgeDeployments.Items))
+
+	<-ctx.Done()
+	return nil
+}
+
+// NeedLeaderElection tells the manager to populate the index on every replica, so that it is ready when the
+// replica becomes the leader
+func (i *Index) NeedLeaderElection() bool {
+	return false
+}
+
+func (i *Index) onUpdate(obj interface{}) {
+	if edgeDeployment, ok := obj.(*v1alpha1.EdgeDeployment); ok {
+		i.Update(edgeDeployment)
+	}
+}
+
+func (i *Index) onDelete(obj interface{}) {
+	if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if edgeDeployment, ok := obj.(*v1alpha1.EdgeDeployment); ok {
+		i.Remove(edgeDeployment.Namespace, edgeDeployment.Name)
+	}
+}
+
+// Update indexes the EdgeDeployment, replacing its previous version. Versions older than the indexed one, delivered
+// late by the informer, are ignored.
+func (i *Index) Update(edgeDeployment *v1alpha1.EdgeDeployment) {
+	e := &entry{deployment: edgeDeployment.DeepCopy()}
+	if edgeDeployment.Spec.DeviceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(edgeDeployment.Spec.DeviceSelector)
+		if err == nil {
+			e.selector = selector
+		}
+	}
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	ns := i.namespaces[edgeDeployment.Namespace]
+	if ns == nil {
+		ns = &namespaceIndex{
+			entries:  map[string]*entry{},
+			byDevice: map[string]map[string]*entry{},
+			byKey:    map[string]map[string]*entry{},
+			unkeyed:  map[string]*entry{},
+		}
+		i.namespaces[edgeDeployment.Namespace] = ns
+	}
+	if current, ok := ns.entries[edgeDeployment.Name]; ok {
+		if isStale(edgeDeployment, current.deployment) {
+			return
+		}
+		ns.remove(current)
+	}
+	ns.add(e)
+}
+
+// Remove removes the EdgeDeployment from the index
+func (i *Index) Remove(namespace, name string) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	ns := i.namespaces[namespace]
+	if ns == nil {
+		return
+	}
+	if current, ok := ns.entries[name]; ok {
+		ns.remove(current)
+	}
+	if len(ns.entries) == 0 {
+		delete(i.namespaces, namespace)
+	}
+}
+
+// Matching returns the EdgeDeployments of the device namespace targeting the device, sorted by name
+func (i *Index) Matching(edgeDevice *v1alpha1.EdgeDevice) ([]v1alpha1.EdgeDeployment, error) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	if !i.synced {
+		return nil, ErrNotSynced
+	}
+	ns := i.namespaces[edgeDevice.Namespace]
+	if ns == nil {
+		return nil, nil
+	}
+
+	matched := map[string]*entry{}
+	for name, e := range ns.byDevice[edgeDevice.Name] {
+		matched[name] = e
+	}
+	deviceLabels := labels.Set(edgeDevice.Labels)
+	evaluate := func(candidates map[string]*entry) {
+		for name, e := range candidates {
+			if _, ok := matched[name]; !ok && e.selector.Matches(deviceLabels) {
+				matched[name] = e
+			}
+		}
+	}
+	evaluate(ns.unkeyed)
+	for key := range edgeDevice.Labels {
+		evaluate(ns.byKey[key])
+	}
+
+	result := make([]v1alpha1.EdgeDeployment, 0, len(matched))
+	for _, e := range matched {
+		result = append(result, *e.deployment.DeepCopy())
+	}
+	sort.Slice(result, func(a, b int) bool {
+		return result[a].Name < result[b].Name
+	})
+	return result, nil
+}
+
+// TargetingByName returns the EdgeDeployments targeting the device by name, sorted by name
+func (i *Index) TargetingByName(namespace, deviceName string) ([]v1alpha1.EdgeDeployment, error) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	if !i.synced {
+		return nil, ErrNotSynced
+	}
+	ns := i.namespaces[namespace]
+	if ns == nil {
+		return nil, nil
+	}
+	var result []v1alpha1.EdgeDeployment
+	for _, e := range ns.byDevice[deviceName] {
+		result = append(result, *e.deployment.DeepCopy())
+	}
+	sort.Slice(result, func(a, b int) bool {
+		return result[a].Name < result[b].Name
+	})
+	return result, nil
+}
+
+func (ns *namespaceIndex) add(e *entry) {
+	name := e.deployment.Name
+	ns.entries[name] = e
+	if device := e.deployment.Spec.Device; device != "" {
+		addTo(ns.byDevice, device, e)
+	}
+	if e.selector == nil {
+		return
+	}
+	if key, ok := requiredKey(e.selector); ok {
+		addTo(ns.byKey, key, e)
+	} else {
+		ns.unkeyed[name] = e
+	}
+}
+
+func (ns *namespaceIndex) remove(e *entry) {
+	name := e.deployment.Name
+	delete(ns.entries, name)
+	if device := e.deployment.Spec.Device; device != "" {
+		removeFrom(ns.byDevice, device, name)
+	}
+	if e.selector == nil {
+		return
+	}
+	if key, ok := requiredKey(e.selector); ok {
+		removeFrom(ns.byKey, key, name)
+	} else {
+		delete(ns.unkeyed, name)
+	}
+}
+
+// requiredKey returns a label key devices must have to match the selector, if any
+func requiredKey(selector labels.Selector) (string, bool) {
+	requirements, _ := selector.Requirements()
+	for _, requirement := range requirements {
+		switch requirement.Operator() {
+		case selection.Equals, selection.DoubleEquals, selection.In, selection.Exists:
+			return requirement.Key(), true
+		}
+	}
+	return "", false
+}
+
+// isStale tells whether the EdgeDeployment is an older version of the indexed one
+func isStale(edgeDeployment, indexed *v1alpha1.EdgeDeployment) bool {
+	return edgeDeployment.UID != "" && edgeDeployment.UID == indexed.UID && edgeDeployment.Generation < indexed.Generation
+}
+
+func addTo(index map[string]map[string]*entry, key string, e *entry) {
+	entries := index[key]
+	if entries == nil {
+		entries = map[string]*entry{}
+		index[key] = entries
+	}
+	entries[e.deployment.Name] = e
+}
+
+func removeFrom(index map[string]map[string]*entry, key, name string) {
+	delete(index[key], name)
+	if len(index[key]) == 0 {
+		delete(index, key)
+	}
+}
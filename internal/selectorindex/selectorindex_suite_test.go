@@ -0,0 +1,13 @@
+package selectorindex_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestSelectorIndex(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Selector Index Spec")
+}
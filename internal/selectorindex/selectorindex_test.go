@@ -0,0 +1,163 @@
+package selectorindex_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"github.com/project-flotta/flotta-operator/internal/selectorindex"
+)
+
+var _ = Describe("Selector index", func() {
+	var (
+		device *v1alpha1.EdgeDevice
+	)
+
+	deployment := func(name string, selector *metav1.LabelSelector) v1alpha1.EdgeDeployment {
+		return v1alpha1.EdgeDeployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       v1alpha1.EdgeDeploymentSpec{DeviceSelector: selector},
+		}
+	}
+
+	names := func(edgeDeployments []v1alpha1.EdgeDeployment) []string {
+		var result []string
+		for _, edgeDeployment := range edgeDeployments {
+			result = append(result, edgeDeployment.Name)
+		}
+		return result
+	}
+
+	BeforeEach(func() {
+		device = &v1alpha1.EdgeDevice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "device",
+				Namespace: "default",
+				Labels:    map[string]string{"site": "madrid", "tier": "gold"},
+			},
+		}
+	})
+
+	It("Lookup fails until the index is synced", func() {
+		// given
+		index := selectorindex.New()
+
+		// when
+		_, err := index.Matching(device)
+
+		// then
+		Expect(err).To(Equal(selectorindex.ErrNotSynced))
+	})
+
+	It("Deployments are matched by name and by selector", func() {
+		// given
+		byName := deployment("by-name", nil)
+		byName.Spec.Device = "device"
+		otherDevice := deployment("other-device", nil)
+		otherDevice.Spec.Device = "other"
+		index := selectorindex.NewSynced(
+			byName,
+			otherDevice,
+			deployment("match-labels", &metav1.LabelSelector{MatchLabels: map[string]string{"site": "madrid"}}),
+			deployment("other-site", &metav1.LabelSelector{MatchLabels: map[string]string{"site": "paris"}}),
+			deployment("in", &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "tier", Operator: metav1.LabelSelectorOpIn, Values: []string{"gold", "silver"}},
+			}}),
+			deployment("not-in", &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "tier", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"gold"}},
+			}}),
+			deployment("does-not-exist", &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "gpu", Operator: metav1.LabelSelectorOpDoesNotExist},
+			}}),
+			deployment("all", &metav1.LabelSelector{}),
+			deployment("missing-key", &metav1.LabelSelector{MatchLabels: map[string]string{"gpu": "true"}}),
+		)
+
+		// when
+		matching, err := index.Matching(device)
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(matching)).To(Equal([]string{"all", "by-name", "does-not-exist", "in", "match-labels"}))
+	})
+
+	It("Deployments of other namespaces are not matched", func() {
+		// given
+		edgeDeployment := deployment("all", &metav1.LabelSelector{})
+		edgeDeployment.Namespace = "other"
+		index := selectorindex.NewSynced(edgeDeployment)
+
+		// when
+		matching, err := index.Matching(device)
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(matching).To(BeEmpty())
+	})
+
+	It("Updated selector replaces the previous one", func() {
+		// given
+		edgeDeployment := deployment("camera", &metav1.LabelSelector{MatchLabels: map[string]string{"site": "madrid"}})
+		index := selectorindex.NewSynced(edgeDeployment)
+		edgeDeployment.Spec.DeviceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"site": "paris"}}
+
+		// when
+		index.Update(&edgeDeployment)
+
+		// then
+		matching, err := index.Matching(device)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(matching).To(BeEmpty())
+	})
+
+	It("Older version of a deployment is ignored", func() {
+		// given
+		edgeDeployment := deployment("camera", &metav1.LabelSelector{MatchLabels: map[string]string{"site": "madrid"}})
+		edgeDeployment.UID = "uid"
+		edgeDeployment.Generation = 2
+		index := selectorindex.NewSynced(edgeDeployment)
+		edgeDeployment.Generation = 1
+		edgeDeployment.Spec.DeviceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"site": "paris"}}
+
+		// when
+		index.Update(&edgeDeployment)
+
+		// then
+		matching, err := index.Matching(device)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(matching)).To(Equal([]string{"camera"}))
+	})
+
+	It("Removed deployment is not matched", func() {
+		// given
+		byName := deployment("camera", &metav1.LabelSelector{MatchLabels: map[string]string{"site": "madrid"}})
+		byName.Spec.Device = "device"
+		index := selectorindex.NewSynced(byName)
+
+		// when
+		index.Remove("default", "camera")
+
+		// then
+		matching, err := index.Matching(device)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(matching).To(BeEmpty())
+		targeting, err := index.TargetingByName("default", "device")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(targeting).To(BeEmpty())
+	})
+
+	It("Deployments targeting the device by name are returned", func() {
+		// given
+		byName := deployment("camera", nil)
+		byName.Spec.Device = "device"
+		index := selectorindex.NewSynced(byName, deployment("all", &metav1.LabelSelector{}))
+
+		// when
+		targeting, err := index.TargetingByName("default", "device")
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(targeting)).To(Equal([]string{"camera"}))
+	})
+})
@@ -0,0 +1,246 @@
+package simulator
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	"github.com/google/uuid"
+
+	"github.com/project-flotta/flotta-operator/client"
+	"github.com/project-flotta/flotta-operator/client/yggdrasil"
+	"github.com/project-flotta/flotta-operator/models"
+)
+
+// Device is a virtual device that talks to the yggdrasil API like the device agent does
+type Device struct {
+	ID       string
+	sim      *Simulator
+	client   *client.FlottaManagement
+	key      *ecdsa.PrivateKey
+	hardware *models.HardwareInfo
+
+	// configuration is the last configuration received from the operator
+	configuration *models.DeviceConfigurationMessage
+}
+
+func newDevice(sim *Simulator, index int) *Device {
+	id := fmt.Sprintf("%s-%d", sim.config.DevicePrefix, index)
+	return &Device{
+		ID:       id,
+		sim:      sim,
+		hardware: syntheticHardware(id, index),
+	}
+}
+
+// Run registers the device and then polls its configuration and sends heartbeats until the context is done
+func (d *Device) Run(ctx context.Context) {
+	config := d.sim.config
+	for {
+		err := d.register(ctx)
+		if err == nil {
+			break
+		}
+		if !sleep(ctx, config.HeartbeatPeriod) {
+			return
+		}
+	}
+
+	d.getConfiguration(ctx)
+	d.sendHeartbeat(ctx)
+
+	configurationTicker := time.NewTicker(config.ConfigurationPeriod)
+	defer configurationTicker.Stop()
+	heartbeatTicker := time.NewTicker(config.HeartbeatPeriod)
+	defer heartbeatTicker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-configurationTicker.C:
+			d.getConfiguration(ctx)
+		case <-heartbeatTicker.C:
+			d.sendHeartbeat(ctx)
+		}
+	}
+}
+
+func (d *Device) register(ctx context.Context) error {
+	start := time.Now()
+	err := d.doRegister(ctx)
+	d.record(ctx, OperationRegistration, start, err)
+	return err
+}
+
+func (d *Device) doRegister(ctx context.Context) error {
+	if d.key == nil {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return err
+		}
+		d.key = key
+	}
+	csr, err := d.certificateRequest()
+	if err != nil {
+		return err
+	}
+
+	message := newMessage("registration", models.RegistrationInfo{
+		CertificateRequest: string(csr),
+		Hardware:           d.hardware,
+	})
+	params := yggdrasil.NewPostDataMessageForDeviceParamsWithContext(ctx).
+		WithDeviceID(d.ID).
+		WithMessage(message)
+	res, err := d.sim.registrationClient.Yggdrasil.PostDataMessageForDevice(ctx, params)
+	if err != nil {
+		return err
+	}
+
+	if !d.sim.useTLS() {
+		d.client = d.sim.registrationClient
+		return nil
+	}
+
+	response := models.RegistrationResponse{}
+	if res.Payload != nil {
+		err = convert(res.Payload.Content, &response)
+		if err != nil {
+			return err
+		}
+	}
+	if response.Certificate == "" {
+		return fmt.Errorf("no certificate issued to device %s", d.ID)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(d.key)
+	if err != nil {
+		return err
+	}
+	cert, err := tls.X509KeyPair(
+		[]byte(response.Certificate),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	if err != nil {
+		return err
+	}
+	d.client = d.sim.newClient(&cert)
+	return nil
+}
+
+// record ignores the requests interrupted by the end of the simulation
+func (d *Device) record(ctx context.Context, operation string, start time.Time, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	d.sim.stats.Record(operation, time.Since(start), err)
+	if err != nil {
+		d.sim.config.Log.V(1).Info("request failed", "deviceID", d.ID, "operation", operation, "error", err.Error())
+	}
+}
+
+func (d *Device) certificateRequest() ([]byte, error) {
+	template := x509.CertificateRequest{
+		Subject:            pkix.Name{CommonName: d.ID},
+		SignatureAlgorithm: x509.ECDSAWithSHA256,
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &template, d.key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr}), nil
+}
+
+func (d *Device) getConfiguration(ctx context.Context) {
+	start := time.Now()
+	params := yggdrasil.NewGetDataMessageForDeviceParamsWithContext(ctx).WithDeviceID(d.ID)
+	res, err := d.client.Yggdrasil.GetDataMessageForDevice(ctx, params)
+	if err == nil && res.Payload != nil {
+		configuration := models.DeviceConfigurationMessage{}
+		err = convert(res.Payload.Content, &configuration)
+		if err == nil {
+			d.configuration = &configuration
+		}
+	}
+	d.record(ctx, OperationConfiguration, start, err)
+}
+
+func (d *Device) sendHeartbeat(ctx context.Context) {
+	start := time.Now()
+	params := yggdrasil.NewPostDataMessageForDeviceParamsWithContext(ctx).
+		WithDeviceID(d.ID).
+		WithMessage(newMessage("heartbeat", d.heartbeat()))
+	_, err := d.client.Yggdrasil.PostDataMessageForDevice(ctx, params)
+	d.record(ctx, OperationHeartbeat, start, err)
+}
+
+// heartbeat reports every workload of the last configuration as running
+func (d *Device) heartbeat() *models.Heartbeat {
+	hb := models.Heartbeat{
+		Status:    models.HeartbeatStatusUp,
+		Hardware:  d.hardware,
+		Workloads: []*models.WorkloadStatus{},
+		Events:    []*models.EventInfo{},
+	}
+	if d.configuration == nil {
+		return &hb
+	}
+	hb.Version = d.configuration.Version
+	if desired := d.configuration.DesiredProperties; desired != nil {
+		// simulated devices apply the desired properties as they are
+		hb.DesiredPropertiesVersion = desired.Version
+		hb.ReportedProperties = &models.TwinProperties{
+			Version:    desired.Version,
+			Properties: desired.Properties,
+		}
+	}
+	for _, workload := range d.configuration.Workloads {
+		if workload == nil {
+			continue
+		}
+		hb.Workloads = append(hb.Workloads, &models.WorkloadStatus{
+			Name:   workload.Name,
+			Status: models.WorkloadStatusStatusRunning,
+		})
+	}
+	return &hb
+}
+
+func newMessage(directive string, content interface{}) *models.Message {
+	return &models.Message{
+		Type:      models.MessageTypeData,
+		Directive: directive,
+		MessageID: uuid.New().String(),
+		Version:   1,
+		Sent:      strfmt.DateTime(time.Now()),
+		Content:   content,
+	}
+}
+
+// convert decodes the generic content of a message into the given model
+func convert(content interface{}, target interface{}) error {
+	data, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
+
+// sleep returns false when the context is done before the duration elapses
+func sleep(ctx context.Context, duration time.Duration) bool {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
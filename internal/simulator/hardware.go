@@ -0,0 +1,44 @@
+package simulator
+
+import (
+	"fmt"
+
+	"github.com/project-flotta/flotta-operator/models"
+)
+
+// syntheticHardware returns hardware information that is stable for a given device, so that the operator does not
+// consider the hardware of a simulated device to have been tampered with between heartbeats
+func syntheticHardware(deviceID string, index int) *models.HardwareInfo {
+	return &models.HardwareInfo{
+		Hostname: deviceID,
+		CPU: &models.CPU{
+			Architecture: "x86_64",
+			Count:        4,
+			Frequency:    2400,
+			ModelName:    "Simulated CPU",
+			Flags:        []string{},
+		},
+		Memory: &models.Memory{
+			PhysicalBytes: 8 * 1024 * 1024 * 1024,
+			UsableBytes:   8 * 1024 * 1024 * 1024,
+		},
+		SystemVendor: &models.SystemVendor{
+			Manufacturer: "flotta",
+			ProductName:  "device-simulator",
+			SerialNumber: deviceID,
+			Virtual:      true,
+		},
+		Interfaces: []*models.Interface{
+			{
+				Name:          "eth0",
+				HasCarrier:    true,
+				MacAddress:    fmt.Sprintf("02:00:%02x:%02x:%02x:%02x", byte(index>>24), byte(index>>16), byte(index>>8), byte(index)),
+				IPV4Addresses: []string{fmt.Sprintf("10.%d.%d.%d/8", byte(index>>16), byte(index>>8), byte(index))},
+				IPV6Addresses: []string{},
+				Flags:         []string{},
+			},
+		},
+		Disks: []*models.Disk{},
+		Gpus:  []*models.Gpu{},
+	}
+}
@@ -0,0 +1,105 @@
+package simulator
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"github.com/project-flotta/flotta-operator/client"
+)
+
+// Config of a simulation
+type Config struct {
+	// URL of the yggdrasil API, including the base path
+	URL *url.URL
+
+	// RootCAs verify the server certificate when URL uses https
+	RootCAs *x509.CertPool
+
+	// RegistrationCertificate authenticates the registration requests when URL uses https
+	RegistrationCertificate *tls.Certificate
+
+	// Devices is the number of simulated devices
+	Devices int
+
+	// DevicePrefix is the prefix of the ID of the simulated devices
+	DevicePrefix string
+
+	ConfigurationPeriod time.Duration
+	HeartbeatPeriod     time.Duration
+
+	// RampUp is the time over which the start of the devices is spread
+	RampUp time.Duration
+
+	Log logr.Logger
+}
+
+// Simulator runs a fleet of virtual devices against the yggdrasil API
+type Simulator struct {
+	config             Config
+	stats              *Stats
+	registrationClient *client.FlottaManagement
+}
+
+func New(config Config) *Simulator {
+	if config.Log == nil {
+		config.Log = logr.Discard()
+	}
+	sim := &Simulator{
+		config: config,
+		stats:  NewStats(),
+	}
+	sim.registrationClient = sim.newClient(config.RegistrationCertificate)
+	return sim
+}
+
+func (s *Simulator) Stats() *Stats {
+	return s.stats
+}
+
+// Run starts all the devices and blocks until the context is done and all the devices are stopped
+func (s *Simulator) Run(ctx context.Context) {
+	var interval time.Duration
+	if s.config.Devices > 0 {
+		interval = s.config.RampUp / time.Duration(s.config.Devices)
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < s.config.Devices; i++ {
+		if i > 0 && interval > 0 && !sleep(ctx, interval) {
+			break
+		}
+		device := newDevice(s, i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			device.Run(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (s *Simulator) useTLS() bool {
+	return s.config.URL.Scheme == "https"
+}
+
+// newClient returns a client with its own connections, the way every device has its own connection to the operator
+func (s *Simulator) newClient(cert *tls.Certificate) *client.FlottaManagement {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if s.useTLS() {
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs:    s.config.RootCAs,
+			MinVersion: tls.VersionTLS12,
+		}
+		if cert != nil {
+			transport.TLSClientConfig.Certificates = []tls.Certificate{*cert}
+		}
+	}
+	return client.New(client.Config{URL: s.config.URL, Transport: transport})
+}
@@ -0,0 +1,157 @@
+package simulator_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/go-openapi/runtime/middleware"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/project-flotta/flotta-operator/client"
+	"github.com/project-flotta/flotta-operator/internal/simulator"
+	"github.com/project-flotta/flotta-operator/models"
+	"github.com/project-flotta/flotta-operator/restapi"
+	operations "github.com/project-flotta/flotta-operator/restapi/operations/yggdrasil"
+)
+
+// fakeAPI registers devices and records their heartbeats
+type fakeAPI struct {
+	lock       sync.Mutex
+	registered map[string]*models.RegistrationInfo
+	heartbeats map[string][]*models.Heartbeat
+	// unknownDevices are answered with Not Found when they poll their configuration
+	unknownDevices map[string]bool
+}
+
+func (f *fakeAPI) GetControlMessageForDevice(ctx context.Context, params operations.GetControlMessageForDeviceParams) middleware.Responder {
+	return operations.NewGetControlMessageForDeviceOK()
+}
+
+func (f *fakeAPI) PostControlMessageForDevice(ctx context.Context, params operations.PostControlMessageForDeviceParams) middleware.Responder {
+	return operations.NewPostControlMessageForDeviceOK()
+}
+
+func (f *fakeAPI) GetDataMessageForDevice(ctx context.Context, params operations.GetDataMessageForDeviceParams) middleware.Responder {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.unknownDevices[params.DeviceID] {
+		return operations.NewGetDataMessageForDeviceNotFound()
+	}
+	return operations.NewGetDataMessageForDeviceOK().WithPayload(&models.Message{
+		Directive: "device",
+		Content: models.DeviceConfigurationMessage{
+			DeviceID:  params.DeviceID,
+			Version:   "3",
+			Workloads: models.WorkloadList{{Name: "nginx"}},
+		},
+	})
+}
+
+func (f *fakeAPI) PostDataMessageForDevice(ctx context.Context, params operations.PostDataMessageForDeviceParams) middleware.Responder {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	content, _ := json.Marshal(params.Message.Content)
+	switch params.Message.Directive {
+	case "registration":
+		info := models.RegistrationInfo{}
+		_ = json.Unmarshal(content, &info)
+		f.registered[params.DeviceID] = &info
+	case "heartbeat":
+		hb := models.Heartbeat{}
+		_ = json.Unmarshal(content, &hb)
+		f.heartbeats[params.DeviceID] = append(f.heartbeats[params.DeviceID], &hb)
+	}
+	return operations.NewPostDataMessageForDeviceOK()
+}
+
+var _ = Describe("Simulator", func() {
+	var (
+		api    *fakeAPI
+		server *httptest.Server
+		config simulator.Config
+	)
+
+	BeforeEach(func() {
+		api = &fakeAPI{
+			registered:     map[string]*models.RegistrationInfo{},
+			heartbeats:     map[string][]*models.Heartbeat{},
+			unknownDevices: map[string]bool{},
+		}
+		handler, err := restapi.Handler(restapi.Config{YggdrasilAPI: api})
+		Expect(err).NotTo(HaveOccurred())
+		server = httptest.NewServer(handler)
+
+		serverURL, err := url.Parse(server.URL + client.DefaultBasePath)
+		Expect(err).NotTo(HaveOccurred())
+		config = simulator.Config{
+			URL:                 serverURL,
+			Devices:             3,
+			DevicePrefix:        "sim",
+			HeartbeatPeriod:     50 * time.Millisecond,
+			ConfigurationPeriod: 50 * time.Millisecond,
+			RampUp:              30 * time.Millisecond,
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	run := func() *simulator.Simulator {
+		sim := simulator.New(config)
+		ctx, cancel := context.WithTimeout(context.TODO(), 300*time.Millisecond)
+		defer cancel()
+		sim.Run(ctx)
+		return sim
+	}
+
+	It("Devices register and report their workloads", func() {
+		// when
+		sim := run()
+
+		// then
+		api.lock.Lock()
+		defer api.lock.Unlock()
+		Expect(api.registered).To(HaveLen(3))
+		for _, id := range []string{"sim-0", "sim-1", "sim-2"} {
+			Expect(api.registered).To(HaveKey(id))
+			Expect(api.registered[id].CertificateRequest).To(ContainSubstring("CERTIFICATE REQUEST"))
+			Expect(api.registered[id].Hardware.Hostname).To(Equal(id))
+
+			Expect(api.heartbeats[id]).NotTo(BeEmpty())
+			hb := api.heartbeats[id][len(api.heartbeats[id])-1]
+			Expect(hb.Status).To(Equal(models.HeartbeatStatusUp))
+			Expect(hb.Version).To(Equal("3"))
+			Expect(hb.Workloads).To(HaveLen(1))
+			Expect(hb.Workloads[0].Name).To(Equal("nginx"))
+			Expect(hb.Workloads[0].Status).To(Equal(models.WorkloadStatusStatusRunning))
+		}
+
+		summaries := sim.Stats().Summaries()
+		Expect(summaries).To(HaveLen(3))
+		for _, summary := range summaries {
+			Expect(summary.Count).To(BeNumerically(">", 0))
+			Expect(summary.Errors).To(BeZero())
+		}
+	})
+
+	It("Failed requests are counted as errors", func() {
+		// given
+		api.unknownDevices["sim-0"] = true
+		config.Devices = 1
+
+		// when
+		sim := run()
+
+		// then
+		summaries := sim.Stats().Summaries()
+		Expect(summaries[0].Operation).To(Equal(simulator.OperationConfiguration))
+		Expect(summaries[0].Errors).To(BeNumerically(">", 0))
+		Expect(summaries[0].Errors).To(Equal(summaries[0].Count))
+	})
+})
@@ -0,0 +1,123 @@
+package simulator
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	OperationRegistration  = "registration"
+	OperationConfiguration = "configuration"
+	OperationHeartbeat     = "heartbeat"
+)
+
+// Stats collects the latency and the errors of the requests sent by the simulated devices
+type Stats struct {
+	lock       sync.Mutex
+	operations map[string]*operationStats
+}
+
+type operationStats struct {
+	latencies []time.Duration
+	errors    int
+}
+
+// Summary is the outcome of one kind of operation
+type Summary struct {
+	Operation string
+	Count     int
+	Errors    int
+	Min       time.Duration
+	Mean      time.Duration
+	P50       time.Duration
+	P95       time.Duration
+	P99       time.Duration
+	Max       time.Duration
+}
+
+func NewStats() *Stats {
+	return &Stats{operations: map[string]*operationStats{}}
+}
+
+// Record stores the latency of an operation; failed operations are counted as errors and their latency is ignored
+func (s *Stats) Record(operation string, latency time.Duration, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	op, ok := s.operations[operation]
+	if !ok {
+		op = &operationStats{}
+		s.operations[operation] = op
+	}
+	if err != nil {
+		op.errors++
+		return
+	}
+	op.latencies = append(op.latencies, latency)
+}
+
+// Summaries returns the summary of every recorded operation, sorted by operation name
+func (s *Stats) Summaries() []Summary {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var summaries []Summary
+	for name, op := range s.operations {
+		summaries = append(summaries, op.summary(name))
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Operation < summaries[j].Operation
+	})
+	return summaries
+}
+
+// Print writes the summaries as a table
+func (s *Stats) Print(out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "OPERATION\tCOUNT\tERRORS\tMIN\tMEAN\tP50\tP95\tP99\tMAX")
+	for _, s := range s.Summaries() {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%v\t%v\t%v\t%v\t%v\t%v\n",
+			s.Operation, s.Count, s.Errors, s.Min, s.Mean, s.P50, s.P95, s.P99, s.Max)
+	}
+	return w.Flush()
+}
+
+func (o *operationStats) summary(name string) Summary {
+	summary := Summary{
+		Operation: name,
+		Count:     len(o.latencies) + o.errors,
+		Errors:    o.errors,
+	}
+	if len(o.latencies) == 0 {
+		return summary
+	}
+
+	latencies := make([]time.Duration, len(o.latencies))
+	copy(latencies, o.latencies)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	var total time.Duration
+	for _, latency := range latencies {
+		total += latency
+	}
+	summary.Min = latencies[0]
+	summary.Max = latencies[len(latencies)-1]
+	summary.Mean = total / time.Duration(len(latencies))
+	summary.P50 = percentile(latencies, 50)
+	summary.P95 = percentile(latencies, 95)
+	summary.P99 = percentile(latencies, 99)
+	return summary
+}
+
+// percentile uses the nearest-rank method on sorted latencies
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,78 @@
+package simulator_test
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/project-flotta/flotta-operator/internal/simulator"
+)
+
+var _ = Describe("Stats", func() {
+	var stats *simulator.Stats
+
+	BeforeEach(func() {
+		stats = simulator.NewStats()
+	})
+
+	It("Latencies are summarized", func() {
+		// given
+		for i := 100; i > 0; i-- {
+			stats.Record(simulator.OperationHeartbeat, time.Duration(i)*time.Millisecond, nil)
+		}
+
+		// when
+		summaries := stats.Summaries()
+
+		// then
+		Expect(summaries).To(HaveLen(1))
+		summary := summaries[0]
+		Expect(summary.Operation).To(Equal(simulator.OperationHeartbeat))
+		Expect(summary.Count).To(Equal(100))
+		Expect(summary.Errors).To(BeZero())
+		Expect(summary.Min).To(Equal(1 * time.Millisecond))
+		Expect(summary.Max).To(Equal(100 * time.Millisecond))
+		Expect(summary.Mean).To(Equal(50500 * time.Microsecond))
+		Expect(summary.P50).To(Equal(50 * time.Millisecond))
+		Expect(summary.P95).To(Equal(95 * time.Millisecond))
+		Expect(summary.P99).To(Equal(99 * time.Millisecond))
+	})
+
+	It("Errors are counted without latency", func() {
+		// given
+		stats.Record(simulator.OperationRegistration, time.Second, fmt.Errorf("failed"))
+		stats.Record(simulator.OperationRegistration, time.Millisecond, nil)
+		stats.Record(simulator.OperationConfiguration, time.Second, fmt.Errorf("failed"))
+
+		// when
+		summaries := stats.Summaries()
+
+		// then
+		Expect(summaries).To(HaveLen(2))
+		Expect(summaries[0].Operation).To(Equal(simulator.OperationConfiguration))
+		Expect(summaries[0].Count).To(Equal(1))
+		Expect(summaries[0].Errors).To(Equal(1))
+		Expect(summaries[0].Max).To(BeZero())
+		Expect(summaries[1].Operation).To(Equal(simulator.OperationRegistration))
+		Expect(summaries[1].Count).To(Equal(2))
+		Expect(summaries[1].Errors).To(Equal(1))
+		Expect(summaries[1].Max).To(Equal(time.Millisecond))
+	})
+
+	It("Summaries are printed as a table", func() {
+		// given
+		stats.Record(simulator.OperationHeartbeat, time.Millisecond, nil)
+		out := bytes.Buffer{}
+
+		// when
+		err := stats.Print(&out)
+
+		// then
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out.String()).To(ContainSubstring("OPERATION"))
+		Expect(out.String()).To(ContainSubstring(simulator.OperationHeartbeat))
+	})
+})
@@ -46,6 +46,16 @@ func (c *Claimer) CreateClaim(ctx context.Context, device *v1alpha1.EdgeDevice)
 	return &obc, err
 }
 
+func (c *Claimer) DeleteClaim(ctx context.Context, name string, namespace string) error {
+	obc := obv1.ObjectBucketClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+	return c.client.Delete(ctx, &obc)
+}
+
 func (c *Claimer) GetStorageConfiguration(ctx context.Context, device *v1alpha1.EdgeDevice) (*models.S3StorageConfiguration, error) {
 
 	if device == nil {
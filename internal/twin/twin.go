@@ -0,0 +1,130 @@
+package twin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"github.com/project-flotta/flotta-operator/models"
+)
+
+const (
+	// MaxProperties limits the number of properties a device reports
+	MaxProperties = 256
+
+	// MaxPropertySize limits the length of the names and values of the properties a device reports
+	MaxPropertySize = 1024
+)
+
+// Version returns the version of the properties: a hash of their content, empty when there are none
+func Version(properties map[string]string) string {
+	if len(properties) == 0 {
+		return ""
+	}
+	// maps are marshalled with their keys sorted
+	data, err := json.Marshal(properties)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Desired returns the desired properties delivered to the device, nil when it has none
+func Desired(device *v1alpha1.EdgeDevice) *models.TwinProperties {
+	if device.Spec.Twin == nil || len(device.Spec.Twin.Desired) == 0 {
+		return nil
+	}
+	properties := make(map[string]string, len(device.Spec.Twin.Desired))
+	for name, value := range device.Spec.Twin.Desired {
+		properties[name] = value
+	}
+	return &models.TwinProperties{
+		Version:    Version(properties),
+		Properties: properties,
+	}
+}
+
+// Validate checks that the reported properties are within the limits of the status of the device
+func Validate(reported *models.TwinProperties) error {
+	if reported == nil {
+		return nil
+	}
+	if len(reported.Properties) > MaxProperties {
+		return fmt.Errorf("%d reported properties, more than %d", len(reported.Properties), MaxProperties)
+	}
+	if len(reported.Version) > MaxPropertySize {
+		return fmt.Errorf("version of the reported properties longer than %d", MaxPropertySize)
+	}
+	for name, value := range reported.Properties {
+		if name == "" {
+			return fmt.Errorf("reported property without name")
+		}
+		if len(name) > MaxPropertySize || len(value) > MaxPropertySize {
+			return fmt.Errorf("reported property %.32q longer than %d", name, MaxPropertySize)
+		}
+	}
+	return nil
+}
+
+// Diff returns the desired properties that are not reported with their desired value, sorted by name. Reported
+// properties that are not desired, e.g. firmware versions, are not part of the diff.
+func Diff(desired, reported map[string]string) []v1alpha1.TwinPropertyDiff {
+	var diff []v1alpha1.TwinPropertyDiff
+	for name, value := range desired {
+		reportedValue, ok := reported[name]
+		if ok && reportedValue == value {
+			continue
+		}
+		propertyDiff := v1alpha1.TwinPropertyDiff{Name: name, Desired: value}
+		if ok {
+			propertyDiff.Reported = &reportedValue
+		}
+		diff = append(diff, propertyDiff)
+	}
+	sort.Slice(diff, func(i, j int) bool {
+		return diff[i].Name < diff[j].Name
+	})
+	return diff
+}
+
+// Status returns the twin status of the device after a heartbeat carrying the version of the desired properties the
+// device applied and its reported properties; properties that are not reported keep their previous value. It returns
+// nil for devices without desired nor reported properties.
+func Status(device *v1alpha1.EdgeDevice, appliedDesiredVersion string, reported *models.TwinProperties, now metav1.Time) *v1alpha1.DeviceTwinStatus {
+	var desired map[string]string
+	if device.Spec.Twin != nil {
+		desired = device.Spec.Twin.Desired
+	}
+	if len(desired) == 0 && appliedDesiredVersion == "" && reported == nil && device.Status.Twin == nil {
+		return nil
+	}
+
+	status := &v1alpha1.DeviceTwinStatus{}
+	if device.Status.Twin != nil {
+		status = device.Status.Twin.DeepCopy()
+	}
+	status.DesiredVersion = Version(desired)
+	status.AppliedDesiredVersion = appliedDesiredVersion
+	if reported != nil {
+		var properties map[string]string
+		if len(reported.Properties) > 0 {
+			properties = reported.Properties
+		}
+		if status.ReportedChangeTime == nil || status.ReportedVersion != reported.Version ||
+			!reflect.DeepEqual(status.Reported, properties) {
+			status.ReportedChangeTime = &now
+		}
+		status.ReportedVersion = reported.Version
+		status.Reported = properties
+	}
+	status.Diff = Diff(desired, status.Reported)
+	status.InSync = status.AppliedDesiredVersion == status.DesiredVersion && len(status.Diff) == 0
+	return status
+}
@@ -0,0 +1,13 @@
+package twin_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestTwin(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Twin Suite")
+}
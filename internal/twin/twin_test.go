@@ -0,0 +1,168 @@
+package twin_test
+
+import (
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"github.com/project-flotta/flotta-operator/internal/twin"
+	"github.com/project-flotta/flotta-operator/models"
+)
+
+var _ = Describe("Twin", func() {
+	var (
+		device *v1alpha1.EdgeDevice
+		now    metav1.Time
+	)
+
+	BeforeEach(func() {
+		device = &v1alpha1.EdgeDevice{
+			Spec: v1alpha1.EdgeDeviceSpec{
+				Twin: &v1alpha1.DeviceTwin{Desired: map[string]string{"interval": "10s", "mode": "eco"}},
+			},
+		}
+		now = metav1.NewTime(time.Now().Truncate(time.Second))
+	})
+
+	Context("Version", func() {
+		It("should be empty without properties", func() {
+			Expect(twin.Version(nil)).To(BeEmpty())
+			Expect(twin.Version(map[string]string{})).To(BeEmpty())
+		})
+
+		It("should depend on the content of the properties only", func() {
+			version := twin.Version(map[string]string{"interval": "10s", "mode": "eco"})
+
+			Expect(version).NotTo(BeEmpty())
+			Expect(twin.Version(map[string]string{"mode": "eco", "interval": "10s"})).To(Equal(version))
+			Expect(twin.Version(map[string]string{"interval": "10s", "mode": "full"})).NotTo(Equal(version))
+		})
+	})
+
+	Context("Desired", func() {
+		It("should be nil without desired properties", func() {
+			Expect(twin.Desired(&v1alpha1.EdgeDevice{})).To(BeNil())
+		})
+
+		It("should carry the desired properties with their version", func() {
+			// when
+			desired := twin.Desired(device)
+
+			// then
+			Expect(desired.Properties).To(Equal(device.Spec.Twin.Desired))
+			Expect(desired.Version).To(Equal(twin.Version(device.Spec.Twin.Desired)))
+		})
+	})
+
+	Context("Validate", func() {
+		It("should accept properties within the limits", func() {
+			Expect(twin.Validate(nil)).To(Succeed())
+			Expect(twin.Validate(&models.TwinProperties{Properties: map[string]string{"firmware": "1.2"}})).To(Succeed())
+		})
+
+		It("should reject too many properties", func() {
+			properties := map[string]string{}
+			for i := 0; i <= twin.MaxProperties; i++ {
+				properties[strings.Repeat("p", i+1)] = "value"
+			}
+			Expect(twin.Validate(&models.TwinProperties{Properties: properties})).NotTo(Succeed())
+		})
+
+		It("should reject too long values and empty names", func() {
+			long := strings.Repeat("v", twin.MaxPropertySize+1)
+			Expect(twin.Validate(&models.TwinProperties{Properties: map[string]string{"firmware": long}})).NotTo(Succeed())
+			Expect(twin.Validate(&models.TwinProperties{Properties: map[string]string{"": "1.2"}})).NotTo(Succeed())
+		})
+	})
+
+	Context("Diff", func() {
+		It("should report missing and different desired properties only", func() {
+			// when
+			diff := twin.Diff(
+				map[string]string{"interval": "10s", "mode": "eco", "level": "debug"},
+				map[string]string{"interval": "10s", "mode": "full", "firmware": "1.2"})
+
+			// then
+			full := "full"
+			Expect(diff).To(Equal([]v1alpha1.TwinPropertyDiff{
+				{Name: "level", Desired: "debug"},
+				{Name: "mode", Desired: "eco", Reported: &full},
+			}))
+		})
+	})
+
+	Context("Status", func() {
+		It("should be nil for devices without properties", func() {
+			Expect(twin.Status(&v1alpha1.EdgeDevice{}, "", nil, now)).To(BeNil())
+		})
+
+		It("should not be in sync before the device applies the desired properties", func() {
+			// when
+			status := twin.Status(device, "", nil, now)
+
+			// then
+			Expect(status.DesiredVersion).To(Equal(twin.Version(device.Spec.Twin.Desired)))
+			Expect(status.InSync).To(BeFalse())
+			Expect(status.Diff).To(HaveLen(2))
+		})
+
+		It("should be in sync once the device reports the desired properties", func() {
+			// given
+			version := twin.Version(device.Spec.Twin.Desired)
+			reported := &models.TwinProperties{
+				Version:    "r1",
+				Properties: map[string]string{"interval": "10s", "mode": "eco", "firmware": "1.2"},
+			}
+
+			// when
+			status := twin.Status(device, version, reported, now)
+
+			// then
+			Expect(status.InSync).To(BeTrue())
+			Expect(status.Diff).To(BeEmpty())
+			Expect(status.Reported).To(Equal(reported.Properties))
+			Expect(status.ReportedVersion).To(Equal("r1"))
+			Expect(status.ReportedChangeTime).To(Equal(&now))
+		})
+
+		It("should keep the reported properties when the heartbeat does not carry them", func() {
+			// given
+			changeTime := metav1.NewTime(now.Add(-time.Hour))
+			device.Status.Twin = &v1alpha1.DeviceTwinStatus{
+				ReportedVersion:    "r1",
+				Reported:           map[string]string{"interval": "10s", "mode": "eco"},
+				ReportedChangeTime: &changeTime,
+			}
+
+			// when
+			status := twin.Status(device, twin.Version(device.Spec.Twin.Desired), nil, now)
+
+			// then
+			Expect(status.InSync).To(BeTrue())
+			Expect(status.ReportedVersion).To(Equal("r1"))
+			Expect(status.ReportedChangeTime).To(Equal(&changeTime))
+		})
+
+		It("should update the change time when the reported properties change", func() {
+			// given
+			changeTime := metav1.NewTime(now.Add(-time.Hour))
+			device.Status.Twin = &v1alpha1.DeviceTwinStatus{
+				ReportedVersion:    "r1",
+				Reported:           map[string]string{"interval": "10s", "mode": "eco"},
+				ReportedChangeTime: &changeTime,
+			}
+			unchanged := &models.TwinProperties{Version: "r1", Properties: map[string]string{"interval": "10s", "mode": "eco"}}
+			changed := &models.TwinProperties{Version: "r2", Properties: map[string]string{"interval": "10s", "mode": "full"}}
+
+			// then
+			Expect(twin.Status(device, "", unchanged, now).ReportedChangeTime).To(Equal(&changeTime))
+			status := twin.Status(device, "", changed, now)
+			Expect(status.ReportedChangeTime).To(Equal(&now))
+			Expect(status.InSync).To(BeFalse())
+		})
+	})
+})
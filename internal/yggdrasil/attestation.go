@@ -0,0 +1,13 @@
+package yggdrasil
+
+import (
+	"github.com/project-flotta/flotta-operator/internal/attestation"
+)
+
+// Attestation configures the TPM attestation of registering devices
+type Attestation struct {
+	Verifier attestation.Verifier
+
+	// Required rejects the registration of new devices not presenting an attestation
+	Required bool
+}
@@ -0,0 +1,108 @@
+package yggdrasil
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+
+	"github.com/go-openapi/runtime/middleware"
+
+	"github.com/project-flotta/flotta-operator/internal/audit"
+	"github.com/project-flotta/flotta-operator/internal/mtls"
+	"github.com/project-flotta/flotta-operator/models"
+	operations "github.com/project-flotta/flotta-operator/restapi/operations/yggdrasil"
+)
+
+// audit records an interaction of the device with the API, answered with the status code
+func (h *Handler) audit(r *http.Request, action, deviceID, namespace string, statusCode int, details map[string]string) {
+	if h.auditor == nil {
+		return
+	}
+	entry := &audit.Entry{
+		Action:            action,
+		DeviceID:          deviceID,
+		Namespace:         namespace,
+		CertificateSerial: mtls.CertificateSerial(r),
+		Outcome:           audit.OutcomeOf(statusCode),
+		StatusCode:        statusCode,
+	}
+	if r != nil {
+		entry.RemoteAddress = r.RemoteAddr
+	}
+	if len(details) > 0 {
+		entry.Details = details
+	}
+	h.auditor.Record(entry)
+}
+
+// signCertificate signs the certificate request of the device and records the certificate issued in the audit trail
+func (h *Handler) signCertificate(r *http.Request, certificateRequest, deviceID, namespace string) ([]byte, error) {
+	cert, err := h.mtlsConfig.SignCSR(certificateRequest, deviceID, h.certificateNamespace(namespace))
+	if h.auditor == nil {
+		return cert, err
+	}
+	details := map[string]string{}
+	statusCode := operations.PostDataMessageForDeviceOKCode
+	if err != nil {
+		statusCode = operations.PostDataMessageForDeviceBadRequestCode
+		details["reason"] = err.Error()
+	} else if block, _ := pem.Decode(cert); block != nil {
+		if issued, err := x509.ParseCertificate(block.Bytes); err == nil {
+			details["issuedCertificateSerial"] = mtls.SerialNumber(issued)
+		}
+	}
+	h.audit(r, audit.ActionCertificateSigning, deviceID, namespace, statusCode, details)
+	return cert, err
+}
+
+// statusChanges returns the changes of the status of the device the heartbeat reports: its phase, the version of
+// its configuration and the phases of its workloads
+func (h *Handler) statusChanges(ctx context.Context, deviceID, namespace string, heartbeat *models.Heartbeat) []string {
+	edgeDevice, err := h.deviceRepository.Read(ctx, deviceID, namespace)
+	if err != nil {
+		// the heartbeat handler reports the error
+		return nil
+	}
+	var changes []string
+	if edgeDevice.Status.Phase != heartbeat.Status {
+		changes = append(changes, fmt.Sprintf("phase %q -> %q", edgeDevice.Status.Phase, heartbeat.Status))
+	}
+	if edgeDevice.Status.LastSyncedResourceVersion != heartbeat.Version {
+		changes = append(changes, fmt.Sprintf("configuration version %q -> %q", edgeDevice.Status.LastSyncedResourceVersion, heartbeat.Version))
+	}
+	phases := map[string]string{}
+	for _, deployment := range edgeDevice.Status.Deployments {
+		phases[deployment.Name] = string(deployment.Phase)
+	}
+	for _, workload := range heartbeat.Workloads {
+		if workload == nil {
+			continue
+		}
+		if phase, ok := phases[workload.Name]; ok && phase != workload.Status {
+			changes = append(changes, fmt.Sprintf("workload %s %q -> %q", workload.Name, phase, workload.Status))
+		}
+	}
+	return changes
+}
+
+// statusCodeOf returns the HTTP status code of the response to a device
+func statusCodeOf(response middleware.Responder) int {
+	switch response.(type) {
+	case *operations.GetDataMessageForDeviceOK, *operations.PostDataMessageForDeviceOK:
+		return operations.PostDataMessageForDeviceOKCode
+	case *operations.PostDataMessageForDeviceBadRequest:
+		return operations.PostDataMessageForDeviceBadRequestCode
+	case *operations.GetDataMessageForDeviceUnauthorized, *operations.PostDataMessageForDeviceUnauthorized:
+		return operations.PostDataMessageForDeviceUnauthorizedCode
+	case *operations.GetDataMessageForDeviceForbidden, *operations.PostDataMessageForDeviceForbidden:
+		return operations.PostDataMessageForDeviceForbiddenCode
+	case *operations.GetDataMessageForDeviceNotFound, *operations.PostDataMessageForDeviceNotFound:
+		return operations.PostDataMessageForDeviceNotFoundCode
+	case *operations.PostDataMessageForDeviceTooManyRequests:
+		return operations.PostDataMessageForDeviceTooManyRequestsCode
+	default:
+		return operations.PostDataMessageForDeviceInternalServerErrorCode
+	}
+}
@@ -0,0 +1,51 @@
+package yggdrasil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+
+	"github.com/project-flotta/flotta-operator/models"
+)
+
+// Hash identifies the content of the device configuration. It ignores the version of the configuration, that changes
+// on every update of the device, and the order of the workloads, secrets and configmaps, that are collected from the
+// status of the device and from maps.
+func Hash(dc *models.DeviceConfigurationMessage) (string, error) {
+	data, err := json.Marshal(dc)
+	if err != nil {
+		return "", err
+	}
+	normalized := models.DeviceConfigurationMessage{}
+	if err = json.Unmarshal(data, &normalized); err != nil {
+		return "", err
+	}
+
+	normalized.Version = ""
+	sort.Slice(normalized.Workloads, func(i, j int) bool {
+		return workloadName(normalized.Workloads[i]) < workloadName(normalized.Workloads[j])
+	})
+	sort.Slice(normalized.Secrets, func(i, j int) bool {
+		return normalized.Secrets[i].Name < normalized.Secrets[j].Name
+	})
+	for _, workload := range normalized.Workloads {
+		if workload != nil {
+			sort.Strings(workload.Configmaps)
+		}
+	}
+
+	data, err = json.Marshal(normalized)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func workloadName(workload *models.Workload) string {
+	if workload == nil {
+		return ""
+	}
+	return workload.Name
+}
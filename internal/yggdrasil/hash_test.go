@@ -0,0 +1,78 @@
+package yggdrasil_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/project-flotta/flotta-operator/internal/yggdrasil"
+	"github.com/project-flotta/flotta-operator/models"
+)
+
+var _ = Describe("Hash", func() {
+	var dc *models.DeviceConfigurationMessage
+
+	BeforeEach(func() {
+		dc = &models.DeviceConfigurationMessage{
+			DeviceID: "device",
+			Version:  "10",
+			Secrets: models.SecretList{
+				{Name: "db", Data: `{"password":"cGFzc3dvcmQ="}`},
+				{Name: "api", Data: `{"token":"dG9rZW4="}`},
+			},
+			Workloads: models.WorkloadList{
+				{Name: "nginx", Configmaps: models.ConfigmapList{"a", "b"}},
+			},
+		}
+	})
+
+	hash := func(dc *models.DeviceConfigurationMessage) string {
+		h, err := yggdrasil.Hash(dc)
+		Expect(err).NotTo(HaveOccurred())
+		return h
+	}
+
+	It("Version and order of secrets and configmaps are ignored", func() {
+		// given
+		reordered := &models.DeviceConfigurationMessage{
+			DeviceID: "device",
+			Version:  "11",
+			Secrets: models.SecretList{
+				{Name: "api", Data: `{"token":"dG9rZW4="}`},
+				{Name: "db", Data: `{"password":"cGFzc3dvcmQ="}`},
+			},
+			Workloads: models.WorkloadList{
+				{Name: "nginx", Configmaps: models.ConfigmapList{"b", "a"}},
+			},
+		}
+
+		// then
+		Expect(hash(reordered)).To(Equal(hash(dc)))
+		Expect(dc.Version).To(Equal("10"))
+		Expect(dc.Secrets[0].Name).To(Equal("db"))
+	})
+
+	It("Order of workloads is ignored", func() {
+		// given
+		dc.Workloads = models.WorkloadList{{Name: "nginx"}, {Name: "camera"}, {Name: "db"}}
+		shuffled := &models.DeviceConfigurationMessage{
+			DeviceID:  dc.DeviceID,
+			Secrets:   dc.Secrets,
+			Workloads: models.WorkloadList{{Name: "db"}, {Name: "nginx"}, {Name: "camera"}},
+		}
+
+		// then
+		Expect(hash(shuffled)).To(Equal(hash(dc)))
+		Expect(dc.Workloads[0].Name).To(Equal("nginx"))
+	})
+
+	It("Secret value changes the hash", func() {
+		// given
+		before := hash(dc)
+
+		// when
+		dc.Secrets[0].Data = `{"password":"b3RoZXI="}`
+
+		// then
+		Expect(hash(dc)).NotTo(Equal(before))
+	})
+})
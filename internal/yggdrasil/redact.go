@@ -0,0 +1,55 @@
+package yggdrasil
+
+import (
+	"encoding/json"
+
+	"github.com/project-flotta/flotta-operator/models"
+)
+
+// RedactedValue replaces sensitive values in redacted device configurations
+const RedactedValue = "<redacted>"
+
+// Redact replaces the secret values, image registry credentials, storage credentials and syslog client keys of the
+// device configuration, keeping the names of the secret keys
+func Redact(dc *models.DeviceConfigurationMessage) {
+	for _, secret := range dc.Secrets {
+		if secret == nil {
+			continue
+		}
+		data := map[string]string{}
+		if err := json.Unmarshal([]byte(secret.Data), &data); err != nil {
+			secret.Data = RedactedValue
+			continue
+		}
+		for key := range data {
+			data[key] = RedactedValue
+		}
+		redacted, _ := json.Marshal(data)
+		secret.Data = string(redacted)
+	}
+
+	for _, workload := range dc.Workloads {
+		if workload != nil && workload.ImageRegistries != nil && workload.ImageRegistries.AuthFile != "" {
+			workload.ImageRegistries.AuthFile = RedactedValue
+		}
+	}
+
+	if dc.Configuration != nil && dc.Configuration.Storage != nil && dc.Configuration.Storage.S3 != nil {
+		s3 := dc.Configuration.Storage.S3
+		if s3.AwsAccessKeyID != "" {
+			s3.AwsAccessKeyID = RedactedValue
+		}
+		if s3.AwsSecretAccessKey != "" {
+			s3.AwsSecretAccessKey = RedactedValue
+		}
+	}
+
+	if dc.Configuration != nil {
+		for _, logCollection := range dc.Configuration.LogCollection {
+			syslog := logCollection.SyslogConfig
+			if syslog != nil && syslog.TLS != nil && syslog.TLS.ClientKey != "" {
+				syslog.TLS.ClientKey = RedactedValue
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package yggdrasil_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/project-flotta/flotta-operator/internal/yggdrasil"
+	"github.com/project-flotta/flotta-operator/models"
+)
+
+var _ = Describe("Redact", func() {
+
+	It("Sensitive values are redacted", func() {
+		// given
+		dc := &models.DeviceConfigurationMessage{
+			Configuration: &models.DeviceConfiguration{
+				Storage: &models.StorageConfiguration{
+					S3: &models.S3StorageConfiguration{
+						AwsAccessKeyID:     "key-id",
+						AwsSecretAccessKey: "secret-key",
+						BucketName:         "bucket",
+					},
+				},
+				LogCollection: map[string]models.LogsCollectionInformation{
+					"syslog": {SyslogConfig: &models.LogsCollectionInformationSyslogConfig{
+						TLS: &models.SyslogTLSConfiguration{ClientCertificate: "certificate", ClientKey: "key"},
+					}},
+				},
+			},
+			Secrets: models.SecretList{
+				{Name: "db", Data: `{"password":"cGFzc3dvcmQ=","user":"dXNlcg=="}`},
+			},
+			Workloads: models.WorkloadList{
+				{Name: "camera", ImageRegistries: &models.ImageRegistries{AuthFile: "auth"}},
+				{Name: "nginx"},
+			},
+		}
+
+		// when
+		yggdrasil.Redact(dc)
+
+		// then
+		Expect(dc.Secrets[0].Name).To(Equal("db"))
+		Expect(dc.Secrets[0].Data).To(MatchJSON(`{"password":"<redacted>","user":"<redacted>"}`))
+		Expect(dc.Workloads[0].ImageRegistries.AuthFile).To(Equal(yggdrasil.RedactedValue))
+		Expect(dc.Workloads[1].ImageRegistries).To(BeNil())
+		Expect(dc.Configuration.Storage.S3.AwsAccessKeyID).To(Equal(yggdrasil.RedactedValue))
+		Expect(dc.Configuration.Storage.S3.AwsSecretAccessKey).To(Equal(yggdrasil.RedactedValue))
+		Expect(dc.Configuration.Storage.S3.BucketName).To(Equal("bucket"))
+		Expect(dc.Configuration.LogCollection["syslog"].SyslogConfig.TLS.ClientKey).To(Equal(yggdrasil.RedactedValue))
+		Expect(dc.Configuration.LogCollection["syslog"].SyslogConfig.TLS.ClientCertificate).To(Equal("certificate"))
+	})
+})
@@ -0,0 +1,118 @@
+package yggdrasil
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	goerrors "errors"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"github.com/project-flotta/flotta-operator/internal/repository/registrationtoken"
+)
+
+// ErrInvalidRegistrationToken is returned for tokens that do not exist, do not match, have expired or have been used
+// by as many devices as allowed
+var ErrInvalidRegistrationToken = goerrors.New("invalid registration token")
+
+// RegistrationTokens configures the registration of devices with RegistrationTokens
+type RegistrationTokens struct {
+	Repository registrationtoken.Repository
+
+	// Namespace holds the RegistrationTokens
+	Namespace string
+
+	// Required rejects the registration of new devices not presenting a token
+	Required bool
+}
+
+// lookup returns the token presented by a device, if it matches and has not expired. Tokens used up are returned as
+// well, so that the devices registered with them can be recognized; see usedUp.
+func (t *RegistrationTokens) lookup(ctx context.Context, value string) (*v1alpha1.RegistrationToken, error) {
+	separator := strings.LastIndex(value, ".")
+	if separator <= 0 {
+		return nil, ErrInvalidRegistrationToken
+	}
+	name, secret := value[:separator], value[separator+1:]
+	token, err := t.Repository.Read(ctx, name, t.Namespace)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, ErrInvalidRegistrationToken
+		}
+		return nil, err
+	}
+	if !isValid(token, secret) {
+		return nil, ErrInvalidRegistrationToken
+	}
+	return token, nil
+}
+
+// consume counts a use of the token presented by a device. The status of the token is patched with optimistic
+// locking, so that concurrent registrations cannot exceed the uses allowed.
+func (t *RegistrationTokens) consume(ctx context.Context, value string) error {
+	return t.patchUses(ctx, value, func(token *v1alpha1.RegistrationToken) error {
+		if usedUp(token) {
+			return ErrInvalidRegistrationToken
+		}
+		now := metav1.Now()
+		token.Status.Uses++
+		token.Status.LastUsedTime = &now
+		return nil
+	})
+}
+
+// release gives back a use of the token consumed by a registration that failed afterwards
+func (t *RegistrationTokens) release(ctx context.Context, value string) error {
+	return t.patchUses(ctx, value, func(token *v1alpha1.RegistrationToken) error {
+		if token.Status.Uses > 0 {
+			token.Status.Uses--
+		}
+		return nil
+	})
+}
+
+func (t *RegistrationTokens) patchUses(ctx context.Context, value string, updateFunc func(token *v1alpha1.RegistrationToken) error) error {
+	var err error
+	for i := 0; i < 4; i++ {
+		if i > 0 {
+			time.Sleep(time.Duration(i*50) * time.Millisecond)
+		}
+		var token *v1alpha1.RegistrationToken
+		token, err = t.lookup(ctx, value)
+		if err != nil {
+			return err
+		}
+		patch := client.MergeFromWithOptions(token.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		err = updateFunc(token)
+		if err != nil {
+			return err
+		}
+		err = t.Repository.PatchStatus(ctx, token, &patch)
+		if !errors.IsConflict(err) {
+			return err
+		}
+	}
+	return err
+}
+
+func isValid(token *v1alpha1.RegistrationToken, secret string) bool {
+	hash := sha256.Sum256([]byte(secret))
+	if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(hash[:])), []byte(token.Spec.SecretHash)) != 1 {
+		return false
+	}
+	if token.Spec.Expiration != nil && !time.Now().Before(token.Spec.Expiration.Time) {
+		return false
+	}
+	return true
+}
+
+// usedUp tells whether as many devices as allowed have registered with the token
+func usedUp(token *v1alpha1.RegistrationToken) bool {
+	return token.Spec.MaxUses != nil && token.Status.Uses >= *token.Spec.MaxUses
+}
@@ -2,16 +2,22 @@ package yggdrasil
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	goerrors "errors"
 	"fmt"
 
 	"github.com/project-flotta/flotta-operator/internal/configmaps"
+	"github.com/project-flotta/flotta-operator/internal/devicelogs"
 	"github.com/project-flotta/flotta-operator/internal/devicemetrics"
+	"github.com/project-flotta/flotta-operator/internal/deviceset"
 	"github.com/project-flotta/flotta-operator/internal/heartbeat"
 	"github.com/project-flotta/flotta-operator/internal/mtls"
 
 	"net/http"
 	"net/url"
+	"reflect"
 	"strings"
 
 	"time"
@@ -24,12 +30,18 @@ import (
 	"github.com/go-openapi/strfmt"
 	"github.com/google/uuid"
 	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"github.com/project-flotta/flotta-operator/internal/attestation"
+	"github.com/project-flotta/flotta-operator/internal/audit"
 	"github.com/project-flotta/flotta-operator/internal/hardware"
 	"github.com/project-flotta/flotta-operator/internal/images"
+	"github.com/project-flotta/flotta-operator/internal/labels"
+	"github.com/project-flotta/flotta-operator/internal/maintenance"
 	"github.com/project-flotta/flotta-operator/internal/metrics"
 	"github.com/project-flotta/flotta-operator/internal/repository/edgedeployment"
 	"github.com/project-flotta/flotta-operator/internal/repository/edgedevice"
+	"github.com/project-flotta/flotta-operator/internal/repository/edgedeviceset"
 	"github.com/project-flotta/flotta-operator/internal/storage"
+	"github.com/project-flotta/flotta-operator/internal/twin"
 	"github.com/project-flotta/flotta-operator/internal/utils"
 	"github.com/project-flotta/flotta-operator/models"
 	"github.com/project-flotta/flotta-operator/restapi/operations/yggdrasil"
@@ -59,6 +71,7 @@ var (
 type Handler struct {
 	deviceRepository       edgedevice.Repository
 	deploymentRepository   edgedeployment.Repository
+	deviceSetRepository    edgedeviceset.Repository
 	claimer                *storage.Claimer
 	client                 k8sclient.K8sClient
 	initialNamespace       string
@@ -69,18 +82,38 @@ type Handler struct {
 	heartbeatHandler       heartbeat.Handler
 	configMaps             configmaps.ConfigMap
 	mtlsConfig             *mtls.TLSConfig
+	metricsIngester        devicemetrics.Ingester
+	logsForwarder          devicelogs.Forwarder
+	registrationTokens     *RegistrationTokens
+	attestation            *Attestation
+	auditor                audit.Auditor
+	// dryRun handlers render the configuration of devices without updating them or recording events
+	dryRun bool
+	// resolutionErrors collects the errors of the items of the configuration rendered in dry-run
+	resolutionErrors *[]ResolutionError
+}
+
+// ResolutionError is an item of the configuration of a device that cannot be resolved, e.g. a missing secret
+type ResolutionError struct {
+	// Item of the configuration, e.g. "secret db-credentials"
+	Item string `json:"item"`
+
+	Error string `json:"error"`
 }
 
 type keyMapType = map[string]interface{}
 type secretMapType = map[string]keyMapType
 
 func NewYggdrasilHandler(deviceRepository edgedevice.Repository, deploymentRepository edgedeployment.Repository,
-	claimer *storage.Claimer, k8sClient k8sclient.K8sClient, initialNamespace string, recorder record.EventRecorder,
+	deviceSetRepository edgedeviceset.Repository, claimer *storage.Claimer, k8sClient k8sclient.K8sClient, initialNamespace string, recorder record.EventRecorder,
 	registryAuth images.RegistryAuthAPI, metrics metrics.Metrics, allowLists devicemetrics.AllowListGenerator,
-	configMaps configmaps.ConfigMap, mtlsConfig *mtls.TLSConfig) *Handler {
+	configMaps configmaps.ConfigMap, mtlsConfig *mtls.TLSConfig, metricsIngester devicemetrics.Ingester,
+	logsForwarder devicelogs.Forwarder, registrationTokens *RegistrationTokens, attestation *Attestation,
+	auditor audit.Auditor) *Handler {
 	return &Handler{
 		deviceRepository:       deviceRepository,
 		deploymentRepository:   deploymentRepository,
+		deviceSetRepository:    deviceSetRepository,
 		claimer:                claimer,
 		client:                 k8sClient,
 		initialNamespace:       initialNamespace,
@@ -88,9 +121,14 @@ func NewYggdrasilHandler(deviceRepository edgedevice.Repository, deploymentRepos
 		registryAuthRepository: registryAuth,
 		metrics:                metrics,
 		allowLists:             allowLists,
-		heartbeatHandler:       heartbeat.NewSynchronousHandler(deviceRepository, recorder),
+		heartbeatHandler:       heartbeat.NewSynchronousHandler(deviceRepository, deploymentRepository, recorder),
 		configMaps:             configMaps,
 		mtlsConfig:             mtlsConfig,
+		metricsIngester:        metricsIngester,
+		logsForwarder:          logsForwarder,
+		registrationTokens:     registrationTokens,
+		attestation:            attestation,
+		auditor:                auditor,
 	}
 }
 
@@ -104,6 +142,15 @@ func isRegistrationURL(url *url.URL) bool {
 	return last == "registration"
 }
 
+// deviceNamespace returns the namespace of the device sending the request: the one recorded in its client
+// certificate, or the initial namespace for devices registered without a RegistrationToken
+func (h *Handler) deviceNamespace(r *http.Request) string {
+	if namespace := mtls.DeviceNamespace(r); namespace != "" {
+		return namespace
+	}
+	return h.initialNamespace
+}
+
 func (h *Handler) GetAuthType(r *http.Request) int {
 	res := YggdrasilCompleteAuth
 	if isRegistrationURL(r.URL) {
@@ -115,7 +162,7 @@ func (h *Handler) GetAuthType(r *http.Request) int {
 func (h *Handler) GetControlMessageForDevice(ctx context.Context, params yggdrasil.GetControlMessageForDeviceParams) middleware.Responder {
 	deviceID := params.DeviceID
 	logger := log.FromContext(ctx, "DeviceID", deviceID)
-	edgeDevice, err := h.deviceRepository.Read(ctx, deviceID, h.initialNamespace)
+	edgeDevice, err := h.deviceRepository.Read(ctx, deviceID, h.deviceNamespace(params.HTTPRequest))
 	if err != nil {
 		if errors.IsNotFound(err) {
 			logger.Info("edge device is not found")
@@ -139,10 +186,15 @@ func (h *Handler) GetControlMessageForDevice(ctx context.Context, params yggdras
 	return operations.NewGetControlMessageForDeviceOK()
 }
 
-func (h *Handler) GetDataMessageForDevice(ctx context.Context, params yggdrasil.GetDataMessageForDeviceParams) middleware.Responder {
+func (h *Handler) GetDataMessageForDevice(ctx context.Context, params yggdrasil.GetDataMessageForDeviceParams) (res middleware.Responder) {
 	deviceID := params.DeviceID
 	logger := log.FromContext(ctx, "DeviceID", deviceID)
-	edgeDevice, err := h.deviceRepository.Read(ctx, deviceID, h.initialNamespace)
+	namespace := h.deviceNamespace(params.HTTPRequest)
+	details := map[string]string{}
+	defer func() {
+		h.audit(params.HTTPRequest, audit.ActionConfiguration, deviceID, namespace, statusCodeOf(res), details)
+	}()
+	edgeDevice, err := h.deviceRepository.Read(ctx, deviceID, namespace)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			logger.Info("edge device is not found")
@@ -151,18 +203,96 @@ func (h *Handler) GetDataMessageForDevice(ctx context.Context, params yggdrasil.
 		logger.Error(err, "failed to get edge device")
 		return operations.NewGetDataMessageForDeviceInternalServerError()
 	}
+
+	if edgeDevice.DeletionTimestamp == nil && edgeDevice.Status.HardwareTampering != nil {
+		logger.Info("configuration is blocked because of hardware tampering", "reason", edgeDevice.Status.HardwareTampering.Reason)
+		return operations.NewGetDataMessageForDeviceForbidden()
+	}
+
+	dc, err := h.deviceConfiguration(ctx, logger, edgeDevice)
+	if err != nil {
+		return operations.NewGetDataMessageForDeviceInternalServerError()
+	}
+	details["configurationVersion"] = dc.Version
+
+	// TODO: Network optimization: Decide whether there is a need to return any payload based on difference between last applied configuration and current state in the cluster.
+	message := models.Message{
+		Type:      models.MessageTypeData,
+		Directive: "device",
+		MessageID: uuid.New().String(),
+		Version:   1,
+		Sent:      strfmt.DateTime(time.Now()),
+		Content:   *dc,
+	}
+	return operations.NewGetDataMessageForDeviceOK().WithPayload(&message)
+}
+
+// RenderConfiguration returns the configuration the device receives on its next request, without updating the
+// device or recording events. Items of the configuration that cannot be resolved are left out of the configuration
+// and returned as resolution errors, whereas a device that receives the configuration gets an error instead.
+func (h *Handler) RenderConfiguration(ctx context.Context, name string, namespace string) (*models.DeviceConfigurationMessage, []ResolutionError, error) {
+	edgeDevice, err := h.deviceRepository.Read(ctx, name, namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+	resolutionErrors := []ResolutionError{}
+	dryRun := *h
+	dryRun.dryRun = true
+	dryRun.resolutionErrors = &resolutionErrors
+	dryRun.recorder = &record.FakeRecorder{}
+	dc, err := dryRun.deviceConfiguration(ctx, log.FromContext(ctx, "DeviceID", name), edgeDevice)
+	if err != nil {
+		return nil, nil, err
+	}
+	return dc, resolutionErrors, nil
+}
+
+// resolve returns the error of an item of the configuration. In dry-run the error is recorded instead, so that the
+// rest of the configuration is rendered.
+func (h *Handler) resolve(item string, err error) error {
+	if err == nil || h.resolutionErrors == nil {
+		return err
+	}
+	*h.resolutionErrors = append(*h.resolutionErrors, ResolutionError{Item: item, Error: err.Error()})
+	return nil
+}
+
+func (h *Handler) deviceConfiguration(ctx context.Context, logger logr.Logger, edgeDevice *v1alpha1.EdgeDevice) (*models.DeviceConfigurationMessage, error) {
 	var workloadList models.WorkloadList
 	var secretList models.SecretList
+	// configDevice carries the configuration of the device merged with the one of its EdgeDeviceSet
+	configDevice := edgeDevice
+	osInformation := edgeDevice.Spec.OsInformation
 
 	if edgeDevice.DeletionTimestamp == nil {
+		effectiveConfiguration, err := h.getEffectiveConfiguration(ctx, edgeDevice)
+		if err = h.resolve("device set", err); err != nil {
+			logger.Error(err, "cannot retrieve Edge Device Set")
+			return nil, err
+		}
+		if !reflect.DeepEqual(effectiveConfiguration, edgeDevice.Status.EffectiveConfiguration) {
+			err = h.updateDeviceStatus(ctx, edgeDevice, func(device *v1alpha1.EdgeDevice) {
+				device.Status.EffectiveConfiguration = effectiveConfiguration
+			})
+			if err != nil {
+				logger.Error(err, "cannot update effective configuration of the device")
+				return nil, err
+			}
+		}
+		configDevice = edgeDevice.DeepCopy()
+		configDevice.Spec = *deviceset.Apply(&edgeDevice.Spec, effectiveConfiguration)
+
 		var edgeDeployments []v1alpha1.EdgeDeployment
 
 		for _, deployment := range edgeDevice.Status.Deployments {
 			edgeDeployment, err := h.deploymentRepository.Read(ctx, deployment.Name, edgeDevice.Namespace)
 			if err != nil {
-				if !errors.IsNotFound(err) {
+				if errors.IsNotFound(err) {
+					continue
+				}
+				if err = h.resolve("workload "+deployment.Name, err); err != nil {
 					logger.Error(err, "cannot retrieve Edge Deployments")
-					return operations.NewGetDataMessageForDeviceInternalServerError()
+					return nil, err
 				}
 				continue
 			}
@@ -171,40 +301,46 @@ func (h *Handler) GetDataMessageForDevice(ctx context.Context, params yggdrasil.
 			}
 		}
 
+		edgeDeployments, osInformation, err = h.applyMaintenanceWindow(ctx, edgeDevice, configDevice.Spec.MaintenanceWindow, edgeDeployments)
+		if err != nil {
+			logger.Error(err, "cannot update delivered configuration of the device")
+			return nil, err
+		}
+
 		workloadList, err = h.toWorkloadList(ctx, logger, edgeDeployments, edgeDevice)
 		if err != nil {
-			return operations.NewGetDataMessageForDeviceInternalServerError()
+			return nil, err
 		}
 		secretList, err = h.createSecretList(ctx, logger, edgeDeployments, edgeDevice)
 		if err != nil {
 			logger.Error(err, "failed reading secrets for device deployments")
-			return operations.NewGetDataMessageForDeviceInternalServerError()
+			return nil, err
 		}
 	} else {
-		if utils.HasFinalizer(&edgeDevice.ObjectMeta, YggdrasilWorkloadFinalizer) {
+		if !h.dryRun && utils.HasFinalizer(&edgeDevice.ObjectMeta, YggdrasilWorkloadFinalizer) {
 			err := h.deviceRepository.RemoveFinalizer(ctx, edgeDevice, YggdrasilWorkloadFinalizer)
 			if err != nil {
-				return operations.NewGetDataMessageForDeviceInternalServerError()
+				return nil, err
 			}
 		}
 	}
 
 	dc := models.DeviceConfigurationMessage{
-		DeviceID:      deviceID,
-		Version:       edgeDevice.ResourceVersion,
-		Configuration: &models.DeviceConfiguration{},
-		Workloads:     workloadList,
-		Secrets:       secretList,
+		DeviceID:          edgeDevice.Name,
+		Configuration:     &models.DeviceConfiguration{},
+		Workloads:         workloadList,
+		Secrets:           secretList,
+		DesiredProperties: twin.Desired(edgeDevice),
 	}
 
-	if edgeDevice.Spec.Heartbeat != nil {
+	if configDevice.Spec.Heartbeat != nil {
 		configuration := models.HeartbeatConfiguration{
-			PeriodSeconds: edgeDevice.Spec.Heartbeat.PeriodSeconds,
+			PeriodSeconds: configDevice.Spec.Heartbeat.PeriodSeconds,
 		}
-		if edgeDevice.Spec.Heartbeat.HardwareProfile != nil {
+		if configDevice.Spec.Heartbeat.HardwareProfile != nil {
 			configuration.HardwareProfile = &models.HardwareProfileConfiguration{
-				Include: edgeDevice.Spec.Heartbeat.HardwareProfile.Include,
-				Scope:   edgeDevice.Spec.Heartbeat.HardwareProfile.Scope,
+				Include: configDevice.Spec.Heartbeat.HardwareProfile.Include,
+				Scope:   configDevice.Spec.Heartbeat.HardwareProfile.Scope,
 			}
 		} else {
 			configuration.HardwareProfile = defaultHeartbeatConfiguration.HardwareProfile
@@ -214,37 +350,121 @@ func (h *Handler) GetDataMessageForDevice(ctx context.Context, params yggdrasil.
 		dc.Configuration.Heartbeat = &defaultHeartbeatConfiguration
 	}
 
-	if edgeDevice.Spec.OsInformation != nil {
-		dc.Configuration.Os = (*models.OsInformation)(edgeDevice.Spec.OsInformation)
+	if osInformation != nil {
+		dc.Configuration.Os = (*models.OsInformation)(osInformation)
 	}
 
-	err = h.setStorageConfiguration(ctx, edgeDevice, &dc)
-	if err != nil {
+	if edgeDevice.DeletionTimestamp == nil && edgeDevice.Spec.Decommission != nil {
+		dc.Configuration.FinalDataUpload = true
+	}
+
+	err := h.setStorageConfiguration(ctx, configDevice, &dc)
+	if err = h.resolve("storage", err); err != nil {
 		logger.Error(err, "failed to get storage configuration for device")
 	}
 
-	dc.Configuration.Metrics, err = h.getDeviceMetricsConfiguration(ctx, edgeDevice)
+	dc.Configuration.Metrics, err = h.getDeviceMetricsConfiguration(ctx, configDevice)
 	if err != nil {
 		logger.Error(err, "failed getting device metrics configuration")
-		return operations.NewGetDataMessageForDeviceInternalServerError()
+		return nil, err
 	}
 
-	dc.Configuration.LogCollection, err = h.getDeviceLogConfig(ctx, edgeDevice)
+	dc.Configuration.LogCollection, err = h.getDeviceLogConfig(ctx, configDevice)
 	if err != nil {
 		logger.Error(err, "failed getting device log configuration")
-		return operations.NewGetDataMessageForDeviceInternalServerError()
+		return nil, err
 	}
 
-	// TODO: Network optimization: Decide whether there is a need to return any payload based on difference between last applied configuration and current state in the cluster.
-	message := models.Message{
-		Type:      models.MessageTypeData,
-		Directive: "device",
-		MessageID: uuid.New().String(),
-		Version:   1,
-		Sent:      strfmt.DateTime(time.Now()),
-		Content:   dc,
+	// the device reports the version in its heartbeats: it is compared with the desired configuration of the device
+	dc.Version, err = Hash(&dc)
+	if err != nil {
+		return nil, err
 	}
-	return operations.NewGetDataMessageForDeviceOK().WithPayload(&message)
+
+	return &dc, nil
+}
+
+// applyMaintenanceWindow returns the deployments and OS information to deliver to the device. Outside the maintenance
+// window of the device the configuration delivered last is returned, and pending changes are recorded in the status.
+func (h *Handler) applyMaintenanceWindow(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, windowConfig *v1alpha1.MaintenanceWindow,
+	edgeDeployments []v1alpha1.EdgeDeployment) ([]v1alpha1.EdgeDeployment, *v1alpha1.OsInformation, error) {
+	osInformation := edgeDevice.Spec.OsInformation
+	status := &edgeDevice.Status
+	if windowConfig == nil {
+		return edgeDeployments, osInformation, h.clearDeliveredConfiguration(ctx, edgeDevice)
+	}
+
+	window, err := maintenance.NewWindow(windowConfig)
+	if err != nil {
+		h.recorder.Event(edgeDevice, corev1.EventTypeWarning, "Misconfiguration", err.Error())
+		log.FromContext(ctx).Error(err, "maintenance window is ignored")
+		// the window is ignored in any case
+		_ = h.resolve("maintenance window", err)
+		return edgeDeployments, osInformation, nil
+	}
+
+	now := time.Now()
+	desired := maintenance.Snapshot(edgeDeployments, osInformation)
+	if size := maintenance.Size(desired); size > maintenance.MaxSnapshotSize {
+		err = fmt.Errorf("maintenance window is ignored: the delivered configuration of %d bytes exceeds %d bytes",
+			size, maintenance.MaxSnapshotSize)
+		h.recorder.Event(edgeDevice, corev1.EventTypeWarning, "Misconfiguration", err.Error())
+		_ = h.resolve("maintenance window", err)
+		return edgeDeployments, osInformation, h.clearDeliveredConfiguration(ctx, edgeDevice)
+	}
+	delivered := status.DeliveredConfiguration
+	if delivered == nil || window.IsOpen(now) || maintenance.Equal(desired, delivered) {
+		if maintenance.Equal(desired, delivered) && status.PendingChanges == nil {
+			return edgeDeployments, osInformation, nil
+		}
+		err = h.updateDeviceStatus(ctx, edgeDevice, func(device *v1alpha1.EdgeDevice) {
+			device.Status.DeliveredConfiguration = desired
+			device.Status.PendingChanges = nil
+		})
+		return edgeDeployments, osInformation, err
+	}
+
+	if status.PendingChanges == nil {
+		nextWindow := metav1.NewTime(window.NextStart(now))
+		pendingChanges := &v1alpha1.PendingChanges{Since: metav1.NewTime(now), NextWindow: &nextWindow}
+		err = h.updateDeviceStatus(ctx, edgeDevice, func(device *v1alpha1.EdgeDevice) {
+			device.Status.PendingChanges = pendingChanges
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return maintenance.Deployments(delivered, edgeDevice.Namespace), delivered.OsInformation, nil
+}
+
+// clearDeliveredConfiguration removes the delivered configuration and pending changes of a device that gets its changes
+// immediately
+func (h *Handler) clearDeliveredConfiguration(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice) error {
+	if edgeDevice.Status.DeliveredConfiguration == nil && edgeDevice.Status.PendingChanges == nil {
+		return nil
+	}
+	return h.updateDeviceStatus(ctx, edgeDevice, func(device *v1alpha1.EdgeDevice) {
+		device.Status.DeliveredConfiguration = nil
+		device.Status.PendingChanges = nil
+	})
+}
+
+// getEffectiveConfiguration returns the configuration of the EdgeDeviceSet the device is a member of merged with the
+// configuration of the device; nil when the device is not a member of any set
+func (h *Handler) getEffectiveConfiguration(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice) (*v1alpha1.EffectiveConfiguration, error) {
+	setName := edgeDevice.Labels[labels.DeviceSetLabel]
+	if setName == "" {
+		return nil, nil
+	}
+	edgeDeviceSet, err := h.deviceSetRepository.Read(ctx, setName, edgeDevice.Namespace)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			log.FromContext(ctx).Info("edge device set is not found", "deviceSet", setName)
+			return nil, nil
+		}
+		return nil, err
+	}
+	return deviceset.EffectiveConfiguration(edgeDevice, edgeDeviceSet), nil
 }
 
 func (h *Handler) getDeviceMetricsConfiguration(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice) (*models.MetricsConfiguration, error) {
@@ -270,24 +490,54 @@ func (h *Handler) getDeviceMetricsConfiguration(ctx context.Context, edgeDevice
 			Disabled: systemMetrics.Disabled,
 		}
 
-		allowListSpec := systemMetrics.AllowList
-		if allowListSpec != nil {
-			allowList, err := h.allowLists.GenerateFromConfigMap(ctx, allowListSpec.Name, edgeDevice.Namespace)
-			if err != nil {
-				return nil, err
-			}
-			metricsConfig.System.AllowList = allowList
+		allowList, err := h.getMetricsAllowList(ctx, "system metrics", edgeDevice.Namespace,
+			systemMetrics.AllowList, systemMetrics.MetricsAllowList, systemMetrics.AllowListOverrides)
+		if err != nil {
+			return nil, err
 		}
+		metricsConfig.System.AllowList = allowList
 	}
 
 	return &metricsConfig, nil
 }
 
+// getMetricsAllowList merges the allow-list of the config map with the one of the MetricsAllowList and applies the
+// overrides to the result. It returns nil when neither allow-list is set.
+func (h *Handler) getMetricsAllowList(ctx context.Context, subject, namespace string, configMap, metricsAllowList *v1alpha1.NameRef,
+	overrides *v1alpha1.MetricsAllowListOverrides) (*models.MetricsAllowList, error) {
+	var allowLists []*models.MetricsAllowList
+	if configMap != nil {
+		allowList, err := h.allowLists.GenerateFromConfigMap(ctx, configMap.Name, namespace)
+		if err = h.resolve(fmt.Sprintf("%s allow-list %s", subject, configMap.Name), err); err != nil {
+			return nil, err
+		}
+		allowLists = append(allowLists, allowList)
+	}
+	if metricsAllowList != nil {
+		allowList, err := h.allowLists.GenerateFromAllowList(ctx, metricsAllowList.Name, namespace)
+		if err = h.resolve(fmt.Sprintf("%s MetricsAllowList %s", subject, metricsAllowList.Name), err); err != nil {
+			return nil, err
+		}
+		allowLists = append(allowLists, allowList)
+	}
+	switch {
+	case metricsAllowList == nil && overrides == nil:
+		// the config map allow-list is sent as is
+		if len(allowLists) == 0 {
+			return nil, nil
+		}
+		return allowLists[0], nil
+	case len(allowLists) == 0:
+		return nil, nil
+	}
+	return devicemetrics.Override(devicemetrics.Merge(allowLists...), overrides), nil
+}
+
 func (h *Handler) PostControlMessageForDevice(ctx context.Context, params yggdrasil.PostControlMessageForDeviceParams) middleware.Responder {
 	return operations.NewPostControlMessageForDeviceOK()
 }
 
-func (h *Handler) PostDataMessageForDevice(ctx context.Context, params yggdrasil.PostDataMessageForDeviceParams) middleware.Responder {
+func (h *Handler) PostDataMessageForDevice(ctx context.Context, params yggdrasil.PostDataMessageForDeviceParams) (res middleware.Responder) {
 	deviceID := params.DeviceID
 	logger := log.FromContext(ctx, "DeviceID", deviceID)
 	msg := params.Message
@@ -299,9 +549,19 @@ func (h *Handler) PostDataMessageForDevice(ctx context.Context, params yggdrasil
 		if err != nil {
 			return operations.NewPostDataMessageForDeviceBadRequest()
 		}
+		namespace := h.deviceNamespace(params.HTTPRequest)
+		if h.auditor != nil {
+			// only the heartbeats changing the status of the device are recorded
+			if changes := h.statusChanges(ctx, deviceID, namespace, &hb); len(changes) > 0 {
+				defer func() {
+					h.audit(params.HTTPRequest, audit.ActionHeartbeat, deviceID, namespace, statusCodeOf(res),
+						map[string]string{"changes": strings.Join(changes, ", ")})
+				}()
+			}
+		}
 		err = h.heartbeatHandler.Process(ctx, heartbeat.Notification{
 			DeviceID:  deviceID,
-			Namespace: h.initialNamespace,
+			Namespace: namespace,
 			Heartbeat: &hb,
 		})
 		if err != nil {
@@ -312,6 +572,59 @@ func (h *Handler) PostDataMessageForDevice(ctx context.Context, params yggdrasil
 			logger.Error(err, "Device not found")
 			return operations.NewPostDataMessageForDeviceInternalServerError()
 		}
+	case "metrics":
+		if h.metricsIngester == nil {
+			logger.Info("received metrics while metrics ingestion is disabled")
+			return operations.NewPostDataMessageForDeviceBadRequest()
+		}
+		metricsMessage := models.MetricsMessage{}
+		contentJson, _ := json.Marshal(msg.Content)
+		err := json.Unmarshal(contentJson, &metricsMessage)
+		if err != nil {
+			return operations.NewPostDataMessageForDeviceBadRequest()
+		}
+		edgeDevice, err := h.deviceRepository.Read(ctx, deviceID, h.deviceNamespace(params.HTTPRequest))
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return operations.NewPostDataMessageForDeviceNotFound()
+			}
+			return operations.NewPostDataMessageForDeviceInternalServerError()
+		}
+		err = h.metricsIngester.Ingest(deviceID, edgeDevice.Namespace, &metricsMessage)
+		if err != nil {
+			logger.V(1).Info("metrics batch rejected", "reason", err.Error())
+			if goerrors.Is(err, devicemetrics.ErrRateLimited) {
+				return operations.NewPostDataMessageForDeviceTooManyRequests()
+			}
+			return operations.NewPostDataMessageForDeviceBadRequest()
+		}
+	case "logs":
+		if h.logsForwarder == nil {
+			logger.Info("received logs while logs forwarding is disabled")
+			return operations.NewPostDataMessageForDeviceBadRequest()
+		}
+		logsMessage := models.LogsMessage{}
+		contentJson, _ := json.Marshal(msg.Content)
+		err := json.Unmarshal(contentJson, &logsMessage)
+		if err != nil {
+			return operations.NewPostDataMessageForDeviceBadRequest()
+		}
+		edgeDevice, err := h.deviceRepository.Read(ctx, deviceID, h.deviceNamespace(params.HTTPRequest))
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return operations.NewPostDataMessageForDeviceNotFound()
+			}
+			return operations.NewPostDataMessageForDeviceInternalServerError()
+		}
+		err = h.logsForwarder.Forward(ctx, deviceID, edgeDevice.Namespace, &logsMessage)
+		if err != nil {
+			if goerrors.Is(err, devicelogs.ErrInvalidMessage) {
+				logger.V(1).Info("logs rejected", "reason", err.Error())
+				return operations.NewPostDataMessageForDeviceBadRequest()
+			}
+			logger.Error(err, "cannot forward logs")
+			return operations.NewPostDataMessageForDeviceInternalServerError()
+		}
 	case "registration":
 		// register new edge device
 		contentJson, _ := json.Marshal(msg.Content)
@@ -320,40 +633,115 @@ func (h *Handler) PostDataMessageForDevice(ctx context.Context, params yggdrasil
 		if err != nil {
 			return operations.NewPostDataMessageForDeviceBadRequest()
 		}
-		logger.V(1).Info("received registration info", "content", registrationInfo)
+		loggedInfo := registrationInfo
+		if loggedInfo.Token != "" {
+			loggedInfo.Token = RedactedValue
+		}
+		logger.V(1).Info("received registration info", "content", loggedInfo)
 
-		res := models.MessageResponse{
+		response := models.MessageResponse{
 			Directive: msg.Directive,
 			MessageID: msg.MessageID,
 		}
 		content := models.RegistrationResponse{}
 
-		_, err = h.deviceRepository.Read(ctx, deviceID, h.initialNamespace)
+		// tokens are only consumed by devices authenticated with the registration certificate; registered devices
+		// renewing their certificate stay in the namespace recorded in it
+		namespace := h.deviceNamespace(params.HTTPRequest)
+		details := map[string]string{}
+		defer func() {
+			h.audit(params.HTTPRequest, audit.ActionRegistration, deviceID, namespace, statusCodeOf(res), details)
+		}()
+		var token *v1alpha1.RegistrationToken
+		if h.registrationTokens != nil && registrationInfo.Token != "" && mtls.DeviceNamespace(params.HTTPRequest) == "" {
+			token, err = h.registrationTokens.lookup(ctx, registrationInfo.Token)
+			if err != nil {
+				return h.registrationTokenError(logger, err)
+			}
+			if token.Spec.TargetNamespace != "" {
+				namespace = token.Spec.TargetNamespace
+			}
+			details["registrationToken"] = token.Name
+		}
+
+		edgeDevice, err := h.deviceRepository.Read(ctx, deviceID, namespace)
 		if err == nil {
+			details["registered"] = "true"
 			// @TODO remove this IF when MTLS is finished
 			if registrationInfo.CertificateRequest != "" {
-				cert, err := h.mtlsConfig.SignCSR(registrationInfo.CertificateRequest, deviceID)
+				// the registration certificate and the registration tokens are shared by devices: they do not prove
+				// the identity of a registered device
+				if !h.isDeviceRequest(params.HTTPRequest, deviceID, namespace) && !isRegistrationRetry(edgeDevice, token) {
+					if res := h.reattest(ctx, logger, edgeDevice, &registrationInfo, &response, details); res != nil {
+						return res
+					}
+				}
+				if isAwaitingApproval(edgeDevice, token) {
+					details["approval"] = "pending"
+					return operations.NewPostDataMessageForDeviceForbidden()
+				}
+				cert, err := h.signCertificate(params.HTTPRequest, registrationInfo.CertificateRequest, deviceID, namespace)
 				if err != nil {
 					return operations.NewPostDataMessageForDeviceBadRequest()
 				}
 				content.Certificate = string(cert)
 			}
-			res.Content = content
-			return operations.NewPostDataMessageForDeviceOK().WithPayload(&res)
+			response.Content = content
+			return operations.NewPostDataMessageForDeviceOK().WithPayload(&response)
 		}
 
 		if !errors.IsNotFound(err) {
 			return operations.NewPostDataMessageForDeviceInternalServerError()
 		}
 
+		if token == nil && h.registrationTokens != nil && h.registrationTokens.Required {
+			logger.Info("registration rejected", "reason", "no registration token")
+			h.metrics.IncEdgeDeviceFailedRegistration()
+			return operations.NewPostDataMessageForDeviceUnauthorized()
+		}
+		if token != nil && usedUp(token) {
+			return h.registrationTokenError(logger, ErrInvalidRegistrationToken)
+		}
+		var deviceAttestation *v1alpha1.DeviceAttestation
+		if h.attestation != nil && registrationInfo.Attestation != nil {
+			var challenge *models.AttestationChallenge
+			deviceAttestation, challenge, err = h.attestation.Verifier.Verify(ctx, deviceID, registrationInfo.CertificateRequest, registrationInfo.Attestation)
+			if err != nil {
+				return h.attestationError(logger, err)
+			}
+			if challenge != nil {
+				// the device is registered once it answers the challenge
+				details["attestation"] = "challenged"
+				content.AttestationChallenge = challenge
+				response.Content = content
+				return operations.NewPostDataMessageForDeviceOK().WithPayload(&response)
+			}
+			details["attestation"] = "verified"
+		}
+		if deviceAttestation == nil && h.attestation != nil && h.attestation.Required {
+			logger.Info("registration rejected", "reason", "no attestation")
+			h.metrics.IncEdgeDeviceFailedRegistration()
+			return operations.NewPostDataMessageForDeviceUnauthorized()
+		}
+
 		// @TODO remove this IF when MTLS is finished
-		if registrationInfo.CertificateRequest != "" {
-			cert, err := h.mtlsConfig.SignCSR(registrationInfo.CertificateRequest, deviceID)
+		approvalRequired := token != nil && token.Spec.ApprovalRequired
+		if registrationInfo.CertificateRequest != "" && !approvalRequired {
+			cert, err := h.signCertificate(params.HTTPRequest, registrationInfo.CertificateRequest, deviceID, namespace)
 			if err != nil {
 				return operations.NewPostDataMessageForDeviceBadRequest()
 			}
 			content.Certificate = string(cert)
-			res.Content = content
+			response.Content = content
+		}
+
+		if token != nil {
+			// the token is consumed by new devices only, so that devices retrying their registration do not use it
+			// up; the use is given back when the device cannot be registered
+			err = h.registrationTokens.consume(ctx, registrationInfo.Token)
+			if err != nil {
+				return h.registrationTokenError(logger, err)
+			}
 		}
 
 		now := metav1.Now()
@@ -363,11 +751,18 @@ func (h *Handler) PostDataMessageForDevice(ctx context.Context, params yggdrasil
 			},
 		}
 		device.Name = deviceID
-		device.Namespace = h.initialNamespace
+		device.Namespace = namespace
 		device.Finalizers = []string{YggdrasilConnectionFinalizer, YggdrasilWorkloadFinalizer}
+		if token != nil && len(token.Spec.Labels) > 0 {
+			device.Labels = map[string]string{}
+			for key, value := range token.Spec.Labels {
+				device.Labels[key] = value
+			}
+		}
 		err = h.deviceRepository.Create(ctx, &device)
 		if err != nil {
 			logger.Error(err, "cannot save EdgeDevice")
+			h.releaseRegistrationToken(ctx, logger, token, registrationInfo.Token)
 			h.metrics.IncEdgeDeviceFailedRegistration()
 			return operations.NewPostDataMessageForDeviceInternalServerError()
 		}
@@ -375,10 +770,18 @@ func (h *Handler) PostDataMessageForDevice(ctx context.Context, params yggdrasil
 			device.Status = v1alpha1.EdgeDeviceStatus{
 				Hardware: hardware.MapHardware(registrationInfo.Hardware),
 			}
+			if token != nil {
+				device.Status.RegistrationToken = token.Name
+			}
+			device.Status.Attestation = deviceAttestation
 		})
 
 		if err != nil {
+			// the token and the attestation recorded in the status authenticate the retries of the device: a device
+			// registered without them could not get its certificate anymore
 			logger.Error(err, "cannot update EdgeDevice status")
+			h.discardDevice(ctx, logger, &device)
+			h.releaseRegistrationToken(ctx, logger, token, registrationInfo.Token)
 			h.metrics.IncEdgeDeviceFailedRegistration()
 			return operations.NewPostDataMessageForDeviceInternalServerError()
 		}
@@ -388,10 +791,15 @@ func (h *Handler) PostDataMessageForDevice(ctx context.Context, params yggdrasil
 			h.metrics.IncEdgeDeviceFailedRegistration()
 			return operations.NewPostDataMessageForDeviceInternalServerError()
 		}
-		logger.Info("EdgeDevice created")
+		logger.Info("EdgeDevice created", "namespace", namespace)
 		h.metrics.IncEdgeDeviceSuccessfulRegistration()
 
-		return operations.NewPostDataMessageForDeviceOK().WithPayload(&res)
+		if approvalRequired {
+			// the device retries its registration until it is approved and gets its certificate
+			details["approval"] = "pending"
+			return operations.NewPostDataMessageForDeviceForbidden()
+		}
+		return operations.NewPostDataMessageForDeviceOK().WithPayload(&response)
 	default:
 		logger.Info("received unknown message", "message", msg)
 		return operations.NewPostDataMessageForDeviceBadRequest()
@@ -399,7 +807,113 @@ func (h *Handler) PostDataMessageForDevice(ctx context.Context, params yggdrasil
 	return operations.NewPostDataMessageForDeviceOK()
 }
 
+func (h *Handler) registrationTokenError(logger logr.Logger, err error) middleware.Responder {
+	h.metrics.IncEdgeDeviceFailedRegistration()
+	if goerrors.Is(err, ErrInvalidRegistrationToken) {
+		logger.Info("registration rejected", "reason", err.Error())
+		return operations.NewPostDataMessageForDeviceUnauthorized()
+	}
+	logger.Error(err, "cannot consume registration token")
+	return operations.NewPostDataMessageForDeviceInternalServerError()
+}
+
+// releaseRegistrationToken gives back the use of the token consumed by a registration that failed
+func (h *Handler) releaseRegistrationToken(ctx context.Context, logger logr.Logger, token *v1alpha1.RegistrationToken, value string) {
+	if token == nil {
+		return
+	}
+	if err := h.registrationTokens.release(ctx, value); err != nil {
+		logger.Error(err, "cannot release registration token", "registrationToken", token.Name)
+	}
+}
+
+// discardDevice deletes the EdgeDevice created by a registration that failed, so that the device can register again
+func (h *Handler) discardDevice(ctx context.Context, logger logr.Logger, device *v1alpha1.EdgeDevice) {
+	if h.dryRun {
+		return
+	}
+	withoutFinalizers := device.DeepCopy()
+	withoutFinalizers.Finalizers = nil
+	err := h.deviceRepository.Patch(ctx, device, withoutFinalizers)
+	if err == nil {
+		err = h.deviceRepository.Delete(ctx, withoutFinalizers)
+	}
+	if err != nil {
+		logger.Error(err, "cannot delete EdgeDevice of failed registration")
+	}
+}
+
+// isRegistrationRetry tells whether a registered device that has never sent a heartbeat presents the token it registered
+// with: the device did not get the response to its registration, e.g. because of a network failure, and retries it
+func isRegistrationRetry(edgeDevice *v1alpha1.EdgeDevice, token *v1alpha1.RegistrationToken) bool {
+	return token != nil && edgeDevice.Status.LastSeenTime.IsZero() && edgeDevice.Status.Attestation == nil &&
+		edgeDevice.Status.RegistrationToken == token.Name
+}
+
+// isAwaitingApproval tells whether a device retrying its registration registered with a token requiring approval and
+// has not been approved yet
+func isAwaitingApproval(edgeDevice *v1alpha1.EdgeDevice, token *v1alpha1.RegistrationToken) bool {
+	return isRegistrationRetry(edgeDevice, token) && token.Spec.ApprovalRequired && !edgeDevice.Spec.RegistrationApproved
+}
+
+// reattest returns the response to a request for a new certificate of a registered device that is not authenticated
+// with a certificate of the device, or nil when the certificate can be issued: when the device was attested at
+// registration and attests again with the same endorsement key
+func (h *Handler) reattest(ctx context.Context, logger logr.Logger, edgeDevice *v1alpha1.EdgeDevice, registrationInfo *models.RegistrationInfo,
+	response *models.MessageResponse, details map[string]string) middleware.Responder {
+	recorded := edgeDevice.Status.Attestation
+	if h.attestation == nil || recorded == nil || registrationInfo.Attestation == nil {
+		logger.Info("certificate renewal rejected", "reason", "not authenticated with the certificate of the device")
+		details["reason"] = "not authenticated with the certificate of the device"
+		return operations.NewPostDataMessageForDeviceUnauthorized()
+	}
+	deviceAttestation, challenge, err := h.attestation.Verifier.Verify(ctx, edgeDevice.Name, registrationInfo.CertificateRequest, registrationInfo.Attestation)
+	if err != nil {
+		return h.attestationError(logger, err)
+	}
+	if challenge != nil {
+		details["attestation"] = "challenged"
+		response.Content = models.RegistrationResponse{AttestationChallenge: challenge}
+		return operations.NewPostDataMessageForDeviceOK().WithPayload(response)
+	}
+	if deviceAttestation.EndorsementKeyCertificate != recorded.EndorsementKeyCertificate {
+		logger.Info("certificate renewal rejected", "reason", "endorsement key differs from the attested one")
+		details["reason"] = "endorsement key differs from the attested one"
+		h.metrics.IncEdgeDeviceFailedRegistration()
+		return operations.NewPostDataMessageForDeviceUnauthorized()
+	}
+	details["attestation"] = "verified"
+	return nil
+}
+
+func (h *Handler) attestationError(logger logr.Logger, err error) middleware.Responder {
+	h.metrics.IncEdgeDeviceFailedRegistration()
+	if goerrors.Is(err, attestation.ErrInvalidAttestation) {
+		logger.Info("registration rejected", "reason", err.Error())
+		return operations.NewPostDataMessageForDeviceUnauthorized()
+	}
+	logger.Error(err, "cannot verify attestation")
+	return operations.NewPostDataMessageForDeviceInternalServerError()
+}
+
+// isDeviceRequest tells whether the request is authenticated with a certificate issued to the device of the namespace
+func (h *Handler) isDeviceRequest(r *http.Request, deviceID, namespace string) bool {
+	return mtls.DeviceID(r) == deviceID && mtls.DeviceNamespace(r) == h.certificateNamespace(namespace)
+}
+
+// certificateNamespace is the namespace recorded in the certificate of a device of the namespace. It is left empty
+// for the initial namespace, the namespace of the devices registered without a RegistrationToken.
+func (h *Handler) certificateNamespace(namespace string) string {
+	if namespace == h.initialNamespace {
+		return ""
+	}
+	return namespace
+}
+
 func (h *Handler) updateDeviceStatus(ctx context.Context, device *v1alpha1.EdgeDevice, updateFunc func(d *v1alpha1.EdgeDevice)) error {
+	if h.dryRun {
+		return nil
+	}
 	patch := client.MergeFrom(device.DeepCopy())
 	updateFunc(device)
 	err := h.deviceRepository.PatchStatus(ctx, device, &patch)
@@ -436,27 +950,21 @@ func (h *Handler) toWorkloadList(ctx context.Context, logger logr.Logger, deploy
 			logger.Error(err, "cannot marshal pod specification", "deployment name", deployment.Name)
 			continue
 		}
-		var data *models.DataConfiguration
-		if spec.Data != nil && len(spec.Data.Paths) > 0 {
-			var paths []*models.DataPath
-			for _, path := range spec.Data.Paths {
-				paths = append(paths, &models.DataPath{Source: path.Source, Target: path.Target})
-			}
-			data = &models.DataConfiguration{Paths: paths}
-		}
-
 		workload := models.Workload{
 			Name:          deployment.Name,
 			Specification: string(podSpec),
-			Data:          data,
+			Data:          toDataConfiguration(spec.Data),
 			LogCollection: spec.LogCollection,
+			DependsOn:     spec.DependsOn,
 		}
 		authFile, err := h.getAuthFile(ctx, spec.ImageRegistries, deployment.Namespace)
 		if err != nil {
 			msg := fmt.Sprintf("Auth file secret %s used by deployment %s/%s is missing", spec.ImageRegistries.AuthFileSecret.Name, deployment.Namespace, deployment.Name)
 			h.recorder.Event(device, corev1.EventTypeWarning, "Misconfiguration", msg)
 			logger.Error(err, msg)
-			return nil, err
+			if err = h.resolve(fmt.Sprintf("workload %s image registry auth file", deployment.Name), err); err != nil {
+				return nil, err
+			}
 		}
 		if authFile != "" {
 			workload.ImageRegistries = &models.ImageRegistries{
@@ -472,13 +980,12 @@ func (h *Handler) toWorkloadList(ctx context.Context, logger logr.Logger, deploy
 				Interval: spec.Metrics.Interval,
 			}
 
-			if allowListSpec := spec.Metrics.AllowList; allowListSpec != nil {
-				allowList, err := h.allowLists.GenerateFromConfigMap(ctx, allowListSpec.Name, deployment.Namespace)
-				if err != nil {
-					return nil, fmt.Errorf("Cannot get AllowList Metrics Confimap for %v: %v", deployment.Name, err)
-				}
-				workload.Metrics.AllowList = allowList
+			allowList, err := h.getMetricsAllowList(ctx, fmt.Sprintf("workload %s metrics", deployment.Name), deployment.Namespace,
+				spec.Metrics.AllowList, spec.Metrics.MetricsAllowList, spec.Metrics.AllowListOverrides)
+			if err != nil {
+				return nil, fmt.Errorf("Cannot get metrics allow-list for %v: %v", deployment.Name, err)
 			}
+			workload.Metrics.AllowList = allowList
 
 			addedContainers := false
 			containers := map[string]models.ContainerMetrics{}
@@ -498,7 +1005,9 @@ func (h *Handler) toWorkloadList(ctx context.Context, logger logr.Logger, deploy
 		configmapList, err := h.configMaps.Fetch(ctx, deployment, device.Namespace)
 		if err != nil {
 			logger.Error(err, "Faled to fetch configmaps")
-			return nil, err
+			if err = h.resolve(fmt.Sprintf("workload %s configmaps", deployment.Name), err); err != nil {
+				return nil, err
+			}
 		}
 		workload.Configmaps = configmapList
 		list = append(list, &workload)
@@ -506,6 +1015,32 @@ func (h *Handler) toWorkloadList(ctx context.Context, logger logr.Logger, deploy
 	return list, nil
 }
 
+func toDataConfiguration(dataSpec *v1alpha1.DataConfiguration) *models.DataConfiguration {
+	if dataSpec == nil || len(dataSpec.Paths) == 0 {
+		return nil
+	}
+	var paths []*models.DataPath
+	for _, path := range dataSpec.Paths {
+		paths = append(paths, &models.DataPath{Source: path.Source, Target: path.Target})
+	}
+	data := &models.DataConfiguration{
+		Paths:             paths,
+		Include:           dataSpec.Include,
+		Exclude:           dataSpec.Exclude,
+		MaxSizeMib:        dataSpec.MaxSizeMiB,
+		Compression:       dataSpec.Compression,
+		DeleteAfterUpload: dataSpec.DeleteAfterUpload,
+		BucketPrefix:      dataSpec.BucketPrefix,
+	}
+	if dataSpec.Schedule != nil {
+		data.Schedule = &models.DataUploadSchedule{
+			Interval: dataSpec.Schedule.IntervalSeconds,
+			Cron:     dataSpec.Schedule.Cron,
+		}
+	}
+	return data
+}
+
 func (h *Handler) getAuthFile(ctx context.Context, imageRegistries *v1alpha1.ImageRegistriesConfiguration, namespace string) (string, error) {
 	if imageRegistries != nil {
 		if secretRef := imageRegistries.AuthFileSecret; secretRef != nil {
@@ -612,13 +1147,16 @@ func (h *Handler) createSecretList(ctx context.Context, logger logr.Logger, depl
 	for name, keys := range secretMap {
 		secretObj, err := h.readAndValidateSecret(ctx, name, device.Namespace, keys)
 		if err != nil {
-			return nil, err
+			if err = h.resolve("secret "+name, err); err != nil {
+				return nil, err
+			}
+			continue
 		}
 		if secretObj == nil {
 			continue
 		}
 		err = addSecretToSecretList(&list, secretObj)
-		if err != nil {
+		if err = h.resolve("secret "+name, err); err != nil {
 			return nil, err
 		}
 	}
@@ -696,7 +1234,7 @@ func (h *Handler) getDeviceLogConfig(ctx context.Context, edgeDevice *v1alpha1.E
 		}
 		if val.SyslogConfig != nil {
 			syslogConfig, err := h.getDeviceSyslogLogConfig(ctx, edgeDevice, val)
-			if err != nil {
+			if err = h.resolve("log collection "+key, err); err != nil {
 				return nil, err
 			}
 			logConfig.SyslogConfig = syslogConfig
@@ -723,8 +1261,50 @@ func (h *Handler) getDeviceSyslogLogConfig(ctx context.Context, edgeDevice *v1al
 		}
 	}
 
-	return &models.LogsCollectionInformationSyslogConfig{
+	syslogConfig := &models.LogsCollectionInformationSyslogConfig{
 		Address:  cm.Data["Address"],
 		Protocol: proto,
+	}
+	options := val.SyslogOptions
+	if options == nil {
+		return syslogConfig, nil
+	}
+	syslogConfig.Format = options.Format
+	syslogConfig.Framing = options.Framing
+	syslogConfig.Facility = options.Facility
+	syslogConfig.Tag = options.Tag
+	if options.TLS != nil {
+		if proto != "tcp" {
+			return nil, fmt.Errorf("syslog over TLS requires the tcp protocol, not %s", proto)
+		}
+		syslogConfig.TLS, err = h.getDeviceSyslogTLSConfig(ctx, edgeDevice.Namespace, options.TLS.SecretRef.Name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return syslogConfig, nil
+}
+
+func (h *Handler) getDeviceSyslogTLSConfig(ctx context.Context, namespace, secretName string) (*models.SyslogTLSConfiguration, error) {
+	secret := corev1.Secret{}
+	err := h.client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: secretName}, &secret)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get syslog TLS secret %s: %v", secretName, err)
+	}
+	caBundle := secret.Data[corev1.ServiceAccountRootCAKey]
+	if !x509.NewCertPool().AppendCertsFromPEM(caBundle) {
+		return nil, fmt.Errorf("syslog TLS secret %s has no CA certificate in its %s key", secretName, corev1.ServiceAccountRootCAKey)
+	}
+	certificate := secret.Data[corev1.TLSCertKey]
+	key := secret.Data[corev1.TLSPrivateKeyKey]
+	if len(certificate) > 0 || len(key) > 0 {
+		if _, err := tls.X509KeyPair(certificate, key); err != nil {
+			return nil, fmt.Errorf("syslog TLS secret %s has no valid client certificate and key: %v", secretName, err)
+		}
+	}
+	return &models.SyslogTLSConfiguration{
+		CaBundle:          string(caBundle),
+		ClientCertificate: string(certificate),
+		ClientKey:         string(key),
 	}, nil
 }
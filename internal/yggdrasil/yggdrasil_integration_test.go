@@ -5,20 +5,27 @@ import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/hex"
 	"encoding/pem"
 	"fmt"
+	"math/big"
+	"net/http"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/project-flotta/flotta-operator/internal/configmaps"
+	"github.com/project-flotta/flotta-operator/internal/devicelogs"
 	"github.com/project-flotta/flotta-operator/internal/devicemetrics"
 	"github.com/project-flotta/flotta-operator/internal/mtls"
 
 	"github.com/project-flotta/flotta-operator/internal/images"
 	"github.com/project-flotta/flotta-operator/internal/k8sclient"
+	"github.com/project-flotta/flotta-operator/internal/maintenance"
 
 	"github.com/go-openapi/runtime/middleware"
 	"github.com/golang/mock/gomock"
@@ -34,9 +41,14 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 
 	"github.com/project-flotta/flotta-operator/api/v1alpha1"
+	"github.com/project-flotta/flotta-operator/internal/attestation"
+	"github.com/project-flotta/flotta-operator/internal/audit"
 	"github.com/project-flotta/flotta-operator/internal/metrics"
 	"github.com/project-flotta/flotta-operator/internal/repository/edgedeployment"
 	"github.com/project-flotta/flotta-operator/internal/repository/edgedevice"
+	"github.com/project-flotta/flotta-operator/internal/repository/edgedeviceset"
+	"github.com/project-flotta/flotta-operator/internal/repository/registrationtoken"
+	"github.com/project-flotta/flotta-operator/internal/twin"
 	"github.com/project-flotta/flotta-operator/internal/yggdrasil"
 	"github.com/project-flotta/flotta-operator/models"
 	api "github.com/project-flotta/flotta-operator/restapi/operations/yggdrasil"
@@ -62,6 +74,7 @@ var _ = Describe("Yggdrasil", func() {
 		mockCtrl           *gomock.Controller
 		deployRepoMock     *edgedeployment.MockRepository
 		edgeDeviceRepoMock *edgedevice.MockRepository
+		deviceSetRepoMock  *edgedeviceset.MockRepository
 		metricsMock        *metrics.MockMetrics
 		registryAuth       *images.MockRegistryAuthAPI
 		handler            *yggdrasil.Handler
@@ -103,6 +116,7 @@ var _ = Describe("Yggdrasil", func() {
 		mockCtrl = gomock.NewController(GinkgoT())
 		deployRepoMock = edgedeployment.NewMockRepository(mockCtrl)
 		edgeDeviceRepoMock = edgedevice.NewMockRepository(mockCtrl)
+		deviceSetRepoMock = edgedeviceset.NewMockRepository(mockCtrl)
 		metricsMock = metrics.NewMockMetrics(mockCtrl)
 		registryAuth = images.NewMockRegistryAuthAPI(mockCtrl)
 		eventsRecorder = record.NewFakeRecorder(1)
@@ -110,8 +124,8 @@ var _ = Describe("Yggdrasil", func() {
 		allowListsMock = devicemetrics.NewMockAllowListGenerator(mockCtrl)
 		configMap = configmaps.NewMockConfigMap(mockCtrl)
 
-		handler = yggdrasil.NewYggdrasilHandler(edgeDeviceRepoMock, deployRepoMock, nil, Mockk8sClient, testNamespace,
-			eventsRecorder, registryAuth, metricsMock, allowListsMock, configMap, nil)
+		handler = yggdrasil.NewYggdrasilHandler(edgeDeviceRepoMock, deployRepoMock, deviceSetRepoMock, nil, Mockk8sClient, testNamespace,
+			eventsRecorder, registryAuth, metricsMock, allowListsMock, configMap, nil, nil, nil, nil, nil, nil)
 	})
 
 	AfterEach(func() {
@@ -314,6 +328,26 @@ var _ = Describe("Yggdrasil", func() {
 			Expect(res).To(Equal(operations.NewGetDataMessageForDeviceInternalServerError()))
 		})
 
+		It("Configuration is blocked when hardware tampering is detected", func() {
+			// given
+			device := getDevice("foo")
+			device.Status.HardwareTampering = &v1alpha1.HardwareTampering{
+				DetectionTime: v1.Now(),
+				Reason:        "system serial number changed",
+			}
+
+			edgeDeviceRepoMock.EXPECT().
+				Read(gomock.Any(), "foo", testNamespace).
+				Return(device, nil).
+				Times(1)
+
+			// when
+			res := handler.GetDataMessageForDevice(context.TODO(), params)
+
+			// then
+			Expect(res).To(Equal(operations.NewGetDataMessageForDeviceForbidden()))
+		})
+
 		It("Delete without finalizer", func() {
 			// given
 			device := getDevice("foo")
@@ -334,6 +368,27 @@ var _ = Describe("Yggdrasil", func() {
 			Expect(config.Workloads).To(HaveLen(0))
 		})
 
+		It("Configuration version is the hash of the configuration", func() {
+			// given
+			device := getDevice("foo")
+			device.DeletionTimestamp = &v1.Time{Time: time.Now()}
+			device.ResourceVersion = "10"
+
+			edgeDeviceRepoMock.EXPECT().
+				Read(gomock.Any(), "foo", testNamespace).
+				Return(device, nil).
+				Times(1)
+
+			// when
+			res := handler.GetDataMessageForDevice(context.TODO(), params)
+
+			// then
+			config := validateAndGetDeviceConfig(res)
+			hash, err := yggdrasil.Hash(&config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config.Version).To(Equal(hash))
+		})
+
 		It("Delete with invalid finalizer", func() {
 			// given
 			device := getDevice("foo")
@@ -404,6 +459,27 @@ var _ = Describe("Yggdrasil", func() {
 			Expect(config.Workloads).To(HaveLen(0))
 		})
 
+		It("Rendering the configuration of a deleted device keeps its finalizer", func() {
+			// given
+			device := getDevice("foo")
+			device.DeletionTimestamp = &v1.Time{Time: time.Now()}
+			device.Finalizers = []string{YggdrasilWorkloadFinalizer}
+
+			edgeDeviceRepoMock.EXPECT().
+				Read(gomock.Any(), "foo", testNamespace).
+				Return(device, nil).
+				Times(1)
+
+			// when
+			config, resolutionErrors, err := handler.RenderConfiguration(context.TODO(), "foo", testNamespace)
+
+			// then
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resolutionErrors).To(BeEmpty())
+			Expect(config.Workloads).To(HaveLen(0))
+			Expect(device.Finalizers).To(ConsistOf(YggdrasilWorkloadFinalizer))
+		})
+
 		It("Retrieval of deployment failed", func() {
 			// given
 			device := getDevice("foo")
@@ -450,6 +526,44 @@ var _ = Describe("Yggdrasil", func() {
 			Expect(config.Workloads).To(HaveLen(0))
 		})
 
+		It("Final data upload is requested from a device being decommissioned", func() {
+			// given
+			device := getDevice("foo")
+			device.Spec.Decommission = &v1alpha1.Decommission{TimeoutSeconds: 3600}
+
+			edgeDeviceRepoMock.EXPECT().
+				Read(gomock.Any(), "foo", testNamespace).
+				Return(device, nil).
+				Times(1)
+
+			// when
+			res := handler.GetDataMessageForDevice(context.TODO(), params)
+
+			// then
+			config := validateAndGetDeviceConfig(res)
+			Expect(config.Configuration.FinalDataUpload).To(BeTrue())
+		})
+
+		It("Desired properties are delivered with their version", func() {
+			// given
+			device := getDevice("foo")
+			device.Spec.Twin = &v1alpha1.DeviceTwin{Desired: map[string]string{"interval": "10s"}}
+
+			edgeDeviceRepoMock.EXPECT().
+				Read(gomock.Any(), "foo", testNamespace).
+				Return(device, nil).
+				Times(1)
+
+			// when
+			res := handler.GetDataMessageForDevice(context.TODO(), params)
+
+			// then
+			config := validateAndGetDeviceConfig(res)
+			Expect(config.DesiredProperties).NotTo(BeNil())
+			Expect(config.DesiredProperties.Properties).To(Equal(map[string]string{"interval": "10s"}))
+			Expect(config.DesiredProperties.Version).To(Equal(twin.Version(device.Spec.Twin.Desired)))
+		})
+
 		It("Deployment status reported correctly on device status", func() {
 			// given
 			deviceName := "foo"
@@ -494,142 +608,235 @@ var _ = Describe("Yggdrasil", func() {
 			Expect(workload.ImageRegistries).To(BeNil())
 		})
 
-		Context("Logs", func() {
+		It("Data upload configuration is mapped to the workload", func() {
+			// given
+			deviceName := "foo"
+			device := getDevice(deviceName)
+			device.Status.Deployments = []v1alpha1.Deployment{{Name: "workload1"}}
+
+			edgeDeviceRepoMock.EXPECT().
+				Read(gomock.Any(), deviceName, testNamespace).
+				Return(device, nil).
+				Times(1)
+
+			deploymentData := &v1alpha1.EdgeDeployment{
+				ObjectMeta: v1.ObjectMeta{
+					Name:      "workload1",
+					Namespace: "default",
+				},
+				Spec: v1alpha1.EdgeDeploymentSpec{
+					Type: "pod",
+					Pod:  v1alpha1.Pod{},
+					Data: &v1alpha1.DataConfiguration{
+						Paths:             []v1alpha1.DataPath{{Source: "/export", Target: "workload1"}},
+						Schedule:          &v1alpha1.DataUploadSchedule{Cron: "*/5 * * * *"},
+						Include:           []string{"*.csv"},
+						Exclude:           []string{"*.tmp"},
+						MaxSizeMiB:        10,
+						Compression:       "gzip",
+						DeleteAfterUpload: true,
+						BucketPrefix:      "site-a",
+					},
+				}}
+
+			configMap.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.ConfigmapList{}, nil)
+			deployRepoMock.EXPECT().
+				Read(gomock.Any(), "workload1", testNamespace).
+				Return(deploymentData, nil)
+
+			// when
+			res := handler.GetDataMessageForDevice(context.TODO(), params)
+
+			// then
+			Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceOK{}))
+			config := validateAndGetDeviceConfig(res)
+
+			Expect(config.Workloads).To(HaveLen(1))
+			data := config.Workloads[0].Data
+			Expect(data).NotTo(BeNil())
+			Expect(data.Paths).To(ConsistOf(&models.DataPath{Source: "/export", Target: "workload1"}))
+			Expect(data.Schedule).To(Equal(&models.DataUploadSchedule{Cron: "*/5 * * * *"}))
+			Expect(data.Include).To(ConsistOf("*.csv"))
+			Expect(data.Exclude).To(ConsistOf("*.tmp"))
+			Expect(data.MaxSizeMib).To(BeEquivalentTo(10))
+			Expect(data.Compression).To(Equal("gzip"))
+			Expect(data.DeleteAfterUpload).To(BeTrue())
+			Expect(data.BucketPrefix).To(Equal("site-a"))
+		})
+
+		It("Workload dependencies are included", func() {
+			// given
+			deviceName := "foo"
+			device := getDevice(deviceName)
+			device.Status.Deployments = []v1alpha1.Deployment{{Name: "workload1"}}
+
+			edgeDeviceRepoMock.EXPECT().
+				Read(gomock.Any(), deviceName, testNamespace).
+				Return(device, nil).
+				Times(1)
+
+			deploymentData := &v1alpha1.EdgeDeployment{
+				ObjectMeta: v1.ObjectMeta{
+					Name:      "workload1",
+					Namespace: "default",
+				},
+				Spec: v1alpha1.EdgeDeploymentSpec{
+					Type:      "pod",
+					Pod:       v1alpha1.Pod{},
+					DependsOn: []string{"database", "cache"},
+				}}
+
+			configMap.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.ConfigmapList{}, nil)
+			deployRepoMock.EXPECT().
+				Read(gomock.Any(), "workload1", testNamespace).
+				Return(deploymentData, nil)
+
+			// when
+			res := handler.GetDataMessageForDevice(context.TODO(), params)
+
+			// then
+			Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceOK{}))
+			config := validateAndGetDeviceConfig(res)
+
+			Expect(config.Workloads).To(HaveLen(1))
+			Expect(config.Workloads[0].DependsOn).To(Equal([]string{"database", "cache"}))
+		})
 
+		Context("EdgeDeviceSet", func() {
 			var (
-				deviceName string = "foo"
+				deviceName = "foo"
 				device     *v1alpha1.EdgeDevice
-				deploy     *v1alpha1.EdgeDeployment
+				deviceSet  *v1alpha1.EdgeDeviceSet
 			)
 
-			getDeployment := func(name string, ns string) *v1alpha1.EdgeDeployment {
-				return &v1alpha1.EdgeDeployment{
-					ObjectMeta: v1.ObjectMeta{
-						Name:      name,
-						Namespace: ns,
-					},
-					Spec: v1alpha1.EdgeDeploymentSpec{
-						Type: "pod",
-						Pod:  v1alpha1.Pod{},
-					},
-				}
-			}
-
 			BeforeEach(func() {
-				deviceName = "foo"
 				device = getDevice(deviceName)
-				device.Status.Deployments = []v1alpha1.Deployment{{Name: "workload1"}}
+				device.Labels = map[string]string{"device-set": "fleet"}
+				device.Spec.Heartbeat = nil
+				device.Spec.LogCollection = map[string]*v1alpha1.LogCollectionConfig{
+					"syslog": {Kind: "syslog", BufferSize: 5},
+				}
+				deviceSet = &v1alpha1.EdgeDeviceSet{
+					ObjectMeta: v1.ObjectMeta{Name: "fleet", Namespace: testNamespace},
+					Spec: v1alpha1.EdgeDeviceSetSpec{
+						Heartbeat: &v1alpha1.HeartbeatConfiguration{PeriodSeconds: 120},
+						Metrics: &v1alpha1.MetricsConfiguration{
+							Retention: &v1alpha1.Retention{MaxHours: 12},
+						},
+						LogCollection: map[string]*v1alpha1.LogCollectionConfig{
+							"syslog": {Kind: "syslog", BufferSize: 20},
+						},
+					},
+				}
 
 				edgeDeviceRepoMock.EXPECT().
 					Read(gomock.Any(), deviceName, testNamespace).
 					Return(device, nil).
 					Times(1)
-
-				deploy = getDeployment("workload1", testNamespace)
-				deployRepoMock.EXPECT().
-					Read(gomock.Any(), "workload1", testNamespace).
-					Return(deploy, nil)
-
-				configMap.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.ConfigmapList{}, nil)
 			})
 
-			It("LogCollection is defined as expected", func() {
-
+			It("Configuration of the set is merged and recorded in status", func() {
 				// given
-				Mockk8sClient.EXPECT().Get(
-					gomock.Any(),
-					types.NamespacedName{Namespace: testNamespace, Name: "syslog-config"},
-					gomock.Any()).
-					Do(func(ctx context.Context, key client.ObjectKey, obj client.Object) {
-						obj.(*corev1.ConfigMap).Data = map[string]string{
-							"Address": "127.0.0.1:512",
-						}
+				deviceSetRepoMock.EXPECT().
+					Read(gomock.Any(), "fleet", testNamespace).
+					Return(deviceSet, nil).
+					Times(1)
+
+				edgeDeviceRepoMock.EXPECT().
+					PatchStatus(gomock.Any(), device, gomock.Any()).
+					Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, patch *client.Patch) {
+						config := edgeDevice.Status.EffectiveConfiguration
+						Expect(config).NotTo(BeNil())
+						Expect(config.DeviceSet).To(Equal("fleet"))
+						Expect(config.Heartbeat.PeriodSeconds).To(BeEquivalentTo(120))
+						Expect(config.LogCollection["syslog"].BufferSize).To(BeEquivalentTo(5))
 					}).
 					Return(nil).
 					Times(1)
 
-				device.Spec.LogCollection = map[string]*v1alpha1.LogCollectionConfig{
-					"syslog": {
-						Kind:         "syslog",
-						BufferSize:   10,
-						SyslogConfig: &v1alpha1.NameRef{Name: "syslog-config"},
-					},
-				}
-				deploy.Spec.LogCollection = "syslog"
 				// when
 				res := handler.GetDataMessageForDevice(context.TODO(), params)
 
 				// then
 				Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceOK{}))
 				config := validateAndGetDeviceConfig(res)
-
-				Expect(config.DeviceID).To(Equal(deviceName))
-
-				// Device Log config
+				Expect(config.Configuration.Heartbeat.PeriodSeconds).To(BeEquivalentTo(120))
+				Expect(config.Configuration.Metrics.Retention.MaxHours).To(BeEquivalentTo(12))
 				Expect(config.Configuration.LogCollection).To(HaveKey("syslog"))
-				logConfig := config.Configuration.LogCollection["syslog"]
-				Expect(logConfig.Kind).To(Equal("syslog"))
-				Expect(logConfig.BufferSize).To(Equal(int32(10)))
-				Expect(logConfig.SyslogConfig.Address).To(Equal("127.0.0.1:512"))
-				Expect(logConfig.SyslogConfig.Protocol).To(Equal("tcp"))
-
-				Expect(config.Workloads).To(HaveLen(1))
-				workload := config.Workloads[0]
-				Expect(workload.Name).To(Equal("workload1"))
-				Expect(workload.LogCollection).To(Equal("syslog"))
-				Expect(workload.ImageRegistries).To(BeNil())
+				Expect(config.Configuration.LogCollection["syslog"].BufferSize).To(BeEquivalentTo(5))
 			})
 
-			It("CM with invalid protocol", func() {
-
+			It("Unchanged effective configuration is not patched", func() {
 				// given
-				Mockk8sClient.EXPECT().Get(
-					gomock.Any(),
-					types.NamespacedName{Namespace: testNamespace, Name: "syslog-config"},
-					gomock.Any()).
-					Do(func(ctx context.Context, key client.ObjectKey, obj client.Object) {
-						obj.(*corev1.ConfigMap).Data = map[string]string{
-							"Address":  "127.0.0.1:512",
-							"Protocol": "invalid",
-						}
-					}).
-					Return(nil).
+				deviceSetRepoMock.EXPECT().
+					Read(gomock.Any(), "fleet", testNamespace).
+					Return(deviceSet, nil).
 					Times(1)
-
-				device.Spec.LogCollection = map[string]*v1alpha1.LogCollectionConfig{
-					"syslog": {
-						Kind:         "syslog",
-						BufferSize:   10,
-						SyslogConfig: &v1alpha1.NameRef{Name: "syslog-config"},
-					},
+				device.Status.EffectiveConfiguration = &v1alpha1.EffectiveConfiguration{
+					DeviceSet:     "fleet",
+					Heartbeat:     deviceSet.Spec.Heartbeat.DeepCopy(),
+					Metrics:       deviceSet.Spec.Metrics.DeepCopy(),
+					LogCollection: device.Spec.LogCollection,
 				}
-				deploy.Spec.LogCollection = "syslog"
+
 				// when
 				res := handler.GetDataMessageForDevice(context.TODO(), params)
 
 				// then
-
-				Expect(res).To(Equal(operations.NewGetDataMessageForDeviceInternalServerError()))
+				Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceOK{}))
+				config := validateAndGetDeviceConfig(res)
+				Expect(config.Configuration.Heartbeat.PeriodSeconds).To(BeEquivalentTo(120))
 			})
 
-			It("No valid cm", func() {
-
+			It("Rendered configuration is merged without patching the status", func() {
 				// given
-				Mockk8sClient.EXPECT().Get(
-					gomock.Any(),
-					types.NamespacedName{Namespace: testNamespace, Name: "syslog-config"},
-					gomock.Any()).
-					Return(fmt.Errorf("Invalid error")).
+				deviceSetRepoMock.EXPECT().
+					Read(gomock.Any(), "fleet", testNamespace).
+					Return(deviceSet, nil).
+					Times(1)
+
+				// when
+				config, _, err := handler.RenderConfiguration(context.TODO(), deviceName, testNamespace)
+
+				// then
+				Expect(err).NotTo(HaveOccurred())
+				Expect(config.DeviceID).To(Equal(deviceName))
+				Expect(config.Configuration.Heartbeat.PeriodSeconds).To(BeEquivalentTo(120))
+				Expect(device.Status.EffectiveConfiguration).To(BeNil())
+			})
+
+			It("Missing set removes effective configuration", func() {
+				// given
+				deviceSetRepoMock.EXPECT().
+					Read(gomock.Any(), "fleet", testNamespace).
+					Return(nil, errorNotFound).
+					Times(1)
+				device.Status.EffectiveConfiguration = &v1alpha1.EffectiveConfiguration{DeviceSet: "fleet"}
+
+				edgeDeviceRepoMock.EXPECT().
+					PatchStatus(gomock.Any(), device, gomock.Any()).
+					Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, patch *client.Patch) {
+						Expect(edgeDevice.Status.EffectiveConfiguration).To(BeNil())
+					}).
+					Return(nil).
+					Times(1)
+
+				// when
+				res := handler.GetDataMessageForDevice(context.TODO(), params)
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceOK{}))
+				config := validateAndGetDeviceConfig(res)
+				Expect(config.Configuration.Heartbeat.PeriodSeconds).To(BeEquivalentTo(60))
+			})
+
+			It("Set retrieval failed", func() {
+				// given
+				deviceSetRepoMock.EXPECT().
+					Read(gomock.Any(), "fleet", testNamespace).
+					Return(nil, fmt.Errorf("failed")).
 					Times(1)
 
-				device.Spec.LogCollection = map[string]*v1alpha1.LogCollectionConfig{
-					"syslog": {
-						Kind:         "syslog",
-						BufferSize:   10,
-						SyslogConfig: &v1alpha1.NameRef{Name: "syslog-config"},
-					},
-				}
-				deploy.Spec.LogCollection = "syslog"
 				// when
 				res := handler.GetDataMessageForDevice(context.TODO(), params)
 
@@ -638,61 +845,87 @@ var _ = Describe("Yggdrasil", func() {
 			})
 		})
 
-		Context("Metrics", func() {
+		Context("MaintenanceWindow", func() {
 			var (
-				deviceName    string = "foo"
-				device        *v1alpha1.EdgeDevice
-				allowList     = models.MetricsAllowList{Names: []string{"foo", "bar"}}
-				allowListName = "foo"
+				deviceName     = "foo"
+				device         *v1alpha1.EdgeDevice
+				deploymentData *v1alpha1.EdgeDeployment
+				// open for a minute every four years
+				closedWindow = &v1alpha1.MaintenanceWindow{Schedule: "0 0 29 2 *", DurationMinutes: 1}
+				openWindow   = &v1alpha1.MaintenanceWindow{Schedule: "* * * * *", DurationMinutes: 60}
 			)
 
 			BeforeEach(func() {
-				deviceName = "foo"
 				device = getDevice(deviceName)
+				device.Spec.OsInformation = &v1alpha1.OsInformation{CommitID: "new-commit"}
 				device.Status.Deployments = []v1alpha1.Deployment{{Name: "workload1"}}
+				deploymentData = &v1alpha1.EdgeDeployment{
+					ObjectMeta: v1.ObjectMeta{Name: "workload1", Namespace: testNamespace},
+					Spec: v1alpha1.EdgeDeploymentSpec{
+						Type:      "pod",
+						DependsOn: []string{"database"},
+					}}
 
 				edgeDeviceRepoMock.EXPECT().
 					Read(gomock.Any(), deviceName, testNamespace).
 					Return(device, nil).
 					Times(1)
+				deployRepoMock.EXPECT().
+					Read(gomock.Any(), "workload1", testNamespace).
+					Return(deploymentData, nil).
+					Times(1)
+				configMap.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.ConfigmapList{}, nil).AnyTimes()
 			})
 
-			getDeployment := func(name string, ns string) *v1alpha1.EdgeDeployment {
-				return &v1alpha1.EdgeDeployment{
-					ObjectMeta: v1.ObjectMeta{
-						Name:      name,
-						Namespace: ns,
-					},
-					Spec: v1alpha1.EdgeDeploymentSpec{
-						Type: "pod",
-						Pod:  v1alpha1.Pod{},
-					},
-				}
-			}
-
-			It("Path, port, interval, allowList is honored", func() {
+			It("Changes are delivered and recorded during the window", func() {
 				// given
-				expectedResult := &models.Metrics{
-					Path:      "/metrics",
-					Port:      9999,
-					Interval:  55,
-					AllowList: &allowList,
+				device.Spec.MaintenanceWindow = openWindow
+				device.Status.DeliveredConfiguration = &v1alpha1.DeliveredConfiguration{
+					OsInformation: &v1alpha1.OsInformation{CommitID: "old-commit"},
 				}
 
-				deploy := getDeployment("workload1", testNamespace)
-				deploy.Spec.Metrics = &v1alpha1.ContainerMetricsConfiguration{
-					Path: "/metrics", Port: 9999, Interval: 55, AllowList: &v1alpha1.NameRef{
-						Name: allowListName,
-					}}
+				edgeDeviceRepoMock.EXPECT().
+					PatchStatus(gomock.Any(), device, gomock.Any()).
+					Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, patch *client.Patch) {
+						delivered := edgeDevice.Status.DeliveredConfiguration
+						Expect(delivered.OsInformation.CommitID).To(Equal("new-commit"))
+						Expect(delivered.Deployments).To(HaveLen(1))
+						Expect(delivered.Deployments[0].Name).To(Equal("workload1"))
+						Expect(edgeDevice.Status.PendingChanges).To(BeNil())
+					}).
+					Return(nil).
+					Times(1)
 
-				configMap.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.ConfigmapList{}, nil)
-				deployRepoMock.EXPECT().
-					Read(gomock.Any(), "workload1", testNamespace).
-					Return(deploy, nil)
+				// when
+				res := handler.GetDataMessageForDevice(context.TODO(), params)
 
-				allowListsMock.EXPECT().
-					GenerateFromConfigMap(gomock.Any(), allowListName, testNamespace).
-					Return(&allowList, nil).Times(1)
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceOK{}))
+				config := validateAndGetDeviceConfig(res)
+				Expect(config.Configuration.Os.CommitID).To(Equal("new-commit"))
+				Expect(config.Workloads).To(HaveLen(1))
+			})
+
+			It("Delivered configuration is served outside the window", func() {
+				// given
+				device.Spec.MaintenanceWindow = closedWindow
+				device.Status.DeliveredConfiguration = &v1alpha1.DeliveredConfiguration{
+					Deployments: []v1alpha1.DeliveredDeployment{{
+						Name: "workload1",
+						Spec: v1alpha1.EdgeDeploymentSpec{Type: "pod", DependsOn: []string{"cache"}},
+					}},
+					OsInformation: &v1alpha1.OsInformation{CommitID: "old-commit"},
+				}
+
+				edgeDeviceRepoMock.EXPECT().
+					PatchStatus(gomock.Any(), device, gomock.Any()).
+					Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, patch *client.Patch) {
+						Expect(edgeDevice.Status.PendingChanges).NotTo(BeNil())
+						Expect(edgeDevice.Status.PendingChanges.NextWindow).NotTo(BeNil())
+						Expect(edgeDevice.Status.DeliveredConfiguration.OsInformation.CommitID).To(Equal("old-commit"))
+					}).
+					Return(nil).
+					Times(1)
 
 				// when
 				res := handler.GetDataMessageForDevice(context.TODO(), params)
@@ -700,66 +933,62 @@ var _ = Describe("Yggdrasil", func() {
 				// then
 				Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceOK{}))
 				config := validateAndGetDeviceConfig(res)
-
-				Expect(config.DeviceID).To(Equal(deviceName))
+				Expect(config.Configuration.Os.CommitID).To(Equal("old-commit"))
 				Expect(config.Workloads).To(HaveLen(1))
-				Expect(config.Workloads[0].Metrics).To(Equal(expectedResult))
+				Expect(config.Workloads[0].DependsOn).To(Equal([]string{"cache"}))
 			})
 
-			It("AllowList configmap retrival error", func() {
-
+			It("Pending changes are recorded only once", func() {
 				// given
-				deploy := getDeployment("workload1", testNamespace)
-				deploy.Spec.Metrics = &v1alpha1.ContainerMetricsConfiguration{
-					Path: "/metrics", Port: 9999, Interval: 55, AllowList: &v1alpha1.NameRef{
-						Name: allowListName,
-					}}
+				device.Spec.MaintenanceWindow = closedWindow
+				device.Status.DeliveredConfiguration = &v1alpha1.DeliveredConfiguration{}
+				device.Status.PendingChanges = &v1alpha1.PendingChanges{Since: v1.Now()}
 
-				deployRepoMock.EXPECT().
-					Read(gomock.Any(), "workload1", testNamespace).
-					Return(deploy, nil)
+				// when
+				res := handler.GetDataMessageForDevice(context.TODO(), params)
 
-				allowListsMock.EXPECT().
-					GenerateFromConfigMap(gomock.Any(), allowListName, testNamespace).
-					Return(nil, fmt.Errorf("Failed to get CM")).Times(1)
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceOK{}))
+				config := validateAndGetDeviceConfig(res)
+				Expect(config.Configuration.Os).To(BeNil())
+				Expect(config.Workloads).To(BeEmpty())
+			})
+
+			It("First configuration is delivered outside the window", func() {
+				// given
+				device.Spec.MaintenanceWindow = closedWindow
+
+				edgeDeviceRepoMock.EXPECT().
+					PatchStatus(gomock.Any(), device, gomock.Any()).
+					Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, patch *client.Patch) {
+						Expect(edgeDevice.Status.DeliveredConfiguration).NotTo(BeNil())
+						Expect(edgeDevice.Status.PendingChanges).To(BeNil())
+					}).
+					Return(nil).
+					Times(1)
 
 				// when
 				res := handler.GetDataMessageForDevice(context.TODO(), params)
 
 				// then
-				Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceInternalServerError{}))
+				Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceOK{}))
+				config := validateAndGetDeviceConfig(res)
+				Expect(config.Configuration.Os.CommitID).To(Equal("new-commit"))
 			})
 
-			It("Path and port in containers is honored", func() {
-
+			It("Removed window clears delivered configuration", func() {
 				// given
-				expectedResult := &models.Metrics{
-					Path: "/metrics",
-					Port: 9999,
-					Containers: map[string]models.ContainerMetrics{
-						"test": {
-							Disabled: false,
-							Port:     int32(8899),
-							Path:     "/test/",
-						},
-					},
-				}
-
-				deploy := getDeployment("workload1", testNamespace)
-				deploy.Spec.Metrics = &v1alpha1.ContainerMetricsConfiguration{
-					Path: "/metrics", Port: 9999, Containers: map[string]*v1alpha1.MetricsConfigEntity{
-						"test": {
-							Port:     8899,
-							Path:     "/test/",
-							Disabled: false,
-						},
-					},
-				}
+				device.Status.DeliveredConfiguration = &v1alpha1.DeliveredConfiguration{}
+				device.Status.PendingChanges = &v1alpha1.PendingChanges{Since: v1.Now()}
 
-				configMap.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.ConfigmapList{}, nil)
-				deployRepoMock.EXPECT().
-					Read(gomock.Any(), "workload1", testNamespace).
-					Return(deploy, nil)
+				edgeDeviceRepoMock.EXPECT().
+					PatchStatus(gomock.Any(), device, gomock.Any()).
+					Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, patch *client.Patch) {
+						Expect(edgeDevice.Status.DeliveredConfiguration).To(BeNil())
+						Expect(edgeDevice.Status.PendingChanges).To(BeNil())
+					}).
+					Return(nil).
+					Times(1)
 
 				// when
 				res := handler.GetDataMessageForDevice(context.TODO(), params)
@@ -767,25 +996,26 @@ var _ = Describe("Yggdrasil", func() {
 				// then
 				Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceOK{}))
 				config := validateAndGetDeviceConfig(res)
-
-				Expect(config.DeviceID).To(Equal(deviceName))
-				Expect(config.Workloads).To(HaveLen(1))
-				Expect(config.Workloads[0].Metrics).To(Equal(expectedResult))
+				Expect(config.Configuration.Os.CommitID).To(Equal("new-commit"))
 			})
 
-			It("Negative port is not considered", func() {
-
+			It("Window is ignored when the delivered configuration is too large to be recorded", func() {
 				// given
-				deploy := getDeployment("workload1", testNamespace)
-				deploy.Spec.Metrics = &v1alpha1.ContainerMetricsConfiguration{
-					Path: "/metrics",
-					Port: -1,
-				}
+				device.Spec.MaintenanceWindow = closedWindow
+				device.Status.DeliveredConfiguration = &v1alpha1.DeliveredConfiguration{}
+				deploymentData.Spec.Pod.Spec.Containers = []corev1.Container{{
+					Name: "large",
+					Env:  []corev1.EnvVar{{Name: "DATA", Value: strings.Repeat("x", maintenance.MaxSnapshotSize)}},
+				}}
 
-				configMap.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.ConfigmapList{}, nil)
-				deployRepoMock.EXPECT().
-					Read(gomock.Any(), "workload1", testNamespace).
-					Return(deploy, nil)
+				edgeDeviceRepoMock.EXPECT().
+					PatchStatus(gomock.Any(), device, gomock.Any()).
+					Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, patch *client.Patch) {
+						Expect(edgeDevice.Status.DeliveredConfiguration).To(BeNil())
+						Expect(edgeDevice.Status.PendingChanges).To(BeNil())
+					}).
+					Return(nil).
+					Times(1)
 
 				// when
 				res := handler.GetDataMessageForDevice(context.TODO(), params)
@@ -793,631 +1023,679 @@ var _ = Describe("Yggdrasil", func() {
 				// then
 				Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceOK{}))
 				config := validateAndGetDeviceConfig(res)
-
-				Expect(config.DeviceID).To(Equal(deviceName))
-				Expect(config.Workloads).To(HaveLen(1))
-				Expect(config.Workloads[0].Metrics).To(BeNil())
+				Expect(config.Configuration.Os.CommitID).To(Equal("new-commit"))
 			})
-
 		})
 
-		It("Image registry authfile is included", func() {
-			// given
-			deviceName := "foo"
-			device := getDevice(deviceName)
-			device.Status.Deployments = []v1alpha1.Deployment{{Name: "workload1"}}
+		Context("Logs", func() {
 
-			edgeDeviceRepoMock.EXPECT().
-				Read(gomock.Any(), deviceName, testNamespace).
-				Return(device, nil).
-				Times(1)
+			var (
+				deviceName string = "foo"
+				device     *v1alpha1.EdgeDevice
+				deploy     *v1alpha1.EdgeDeployment
+			)
 
-			deploymentData := &v1alpha1.EdgeDeployment{
-				ObjectMeta: v1.ObjectMeta{
-					Name:      "workload1",
-					Namespace: "default",
-				},
-				Spec: v1alpha1.EdgeDeploymentSpec{
-					Type: "pod",
-					Pod:  v1alpha1.Pod{},
-					ImageRegistries: &v1alpha1.ImageRegistriesConfiguration{
-						AuthFileSecret: &v1alpha1.NameRef{
-							Name: "fooSecret",
-						},
+			getDeployment := func(name string, ns string) *v1alpha1.EdgeDeployment {
+				return &v1alpha1.EdgeDeployment{
+					ObjectMeta: v1.ObjectMeta{
+						Name:      name,
+						Namespace: ns,
 					},
-				}}
-			configMap.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.ConfigmapList{}, nil)
-			deployRepoMock.EXPECT().
-				Read(gomock.Any(), "workload1", testNamespace).
-				Return(deploymentData, nil)
+					Spec: v1alpha1.EdgeDeploymentSpec{
+						Type: "pod",
+						Pod:  v1alpha1.Pod{},
+					},
+				}
+			}
 
-			authFileContent := "authfile-content"
-			registryAuth.EXPECT().
-				GetAuthFileFromSecret(gomock.Any(), gomock.Eq(deploymentData.Namespace), gomock.Eq("fooSecret")).
-				Return(authFileContent, nil)
+			BeforeEach(func() {
+				deviceName = "foo"
+				device = getDevice(deviceName)
+				device.Status.Deployments = []v1alpha1.Deployment{{Name: "workload1"}}
 
-			// when
-			res := handler.GetDataMessageForDevice(context.TODO(), params)
+				edgeDeviceRepoMock.EXPECT().
+					Read(gomock.Any(), deviceName, testNamespace).
+					Return(device, nil).
+					Times(1)
 
-			// then
-			Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceOK{}))
-			config := validateAndGetDeviceConfig(res)
+				deploy = getDeployment("workload1", testNamespace)
+				deployRepoMock.EXPECT().
+					Read(gomock.Any(), "workload1", testNamespace).
+					Return(deploy, nil)
 
-			Expect(config.DeviceID).To(Equal(deviceName))
-			Expect(config.Workloads).To(HaveLen(1))
-			workload := config.Workloads[0]
-			Expect(workload.Name).To(Equal("workload1"))
-			Expect(workload.ImageRegistries).To(Not(BeNil()))
-			Expect(workload.ImageRegistries.AuthFile).To(Equal(authFileContent))
-
-			Expect(eventsRecorder.Events).ToNot(Receive())
-		})
+				configMap.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.ConfigmapList{}, nil)
+			})
 
-		It("Image registry authfile retrieval error", func() {
-			// given
-			deviceName := "foo"
-			device := getDevice(deviceName)
-			device.Status.Deployments = []v1alpha1.Deployment{{Name: "workload1"}}
+			It("LogCollection is defined as expected", func() {
 
-			edgeDeviceRepoMock.EXPECT().
-				Read(gomock.Any(), deviceName, testNamespace).
-				Return(device, nil).
-				Times(1)
+				// given
+				Mockk8sClient.EXPECT().Get(
+					gomock.Any(),
+					types.NamespacedName{Namespace: testNamespace, Name: "syslog-config"},
+					gomock.Any()).
+					Do(func(ctx context.Context, key client.ObjectKey, obj client.Object) {
+						obj.(*corev1.ConfigMap).Data = map[string]string{
+							"Address": "127.0.0.1:512",
+						}
+					}).
+					Return(nil).
+					Times(1)
 
-			deploymentData := &v1alpha1.EdgeDeployment{
-				ObjectMeta: v1.ObjectMeta{
-					Name:      "workload1",
-					Namespace: "default",
-				},
-				Spec: v1alpha1.EdgeDeploymentSpec{
-					Type: "pod",
-					Pod:  v1alpha1.Pod{},
-					ImageRegistries: &v1alpha1.ImageRegistriesConfiguration{
-						AuthFileSecret: &v1alpha1.NameRef{
-							Name: "fooSecret",
-						},
+				device.Spec.LogCollection = map[string]*v1alpha1.LogCollectionConfig{
+					"syslog": {
+						Kind:         "syslog",
+						BufferSize:   10,
+						SyslogConfig: &v1alpha1.NameRef{Name: "syslog-config"},
 					},
-				}}
-			deployRepoMock.EXPECT().
-				Read(gomock.Any(), "workload1", testNamespace).
-				Return(deploymentData, nil)
+				}
+				deploy.Spec.LogCollection = "syslog"
+				// when
+				res := handler.GetDataMessageForDevice(context.TODO(), params)
 
-			registryAuth.EXPECT().
-				GetAuthFileFromSecret(gomock.Any(), gomock.Eq("default"), gomock.Eq("fooSecret")).
-				Return("", fmt.Errorf("failure"))
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceOK{}))
+				config := validateAndGetDeviceConfig(res)
 
-			// when
-			res := handler.GetDataMessageForDevice(context.TODO(), params)
+				Expect(config.DeviceID).To(Equal(deviceName))
 
-			// then
-			Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceInternalServerError{}))
+				// Device Log config
+				Expect(config.Configuration.LogCollection).To(HaveKey("syslog"))
+				logConfig := config.Configuration.LogCollection["syslog"]
+				Expect(logConfig.Kind).To(Equal("syslog"))
+				Expect(logConfig.BufferSize).To(Equal(int32(10)))
+				Expect(logConfig.SyslogConfig.Address).To(Equal("127.0.0.1:512"))
+				Expect(logConfig.SyslogConfig.Protocol).To(Equal("tcp"))
 
-			Expect(eventsRecorder.Events).To(HaveLen(1))
-			Expect(eventsRecorder.Events).To(Receive(ContainSubstring("Auth file secret")))
-		})
+				Expect(config.Workloads).To(HaveLen(1))
+				workload := config.Workloads[0]
+				Expect(workload.Name).To(Equal("workload1"))
+				Expect(workload.LogCollection).To(Equal("syslog"))
+				Expect(workload.ImageRegistries).To(BeNil())
+			})
 
-		It("Secrets reading failed", func() {
-			// given
-			deviceName := "foo"
-			device := getDevice(deviceName)
-			device.Status.Deployments = []v1alpha1.Deployment{{Name: "workload1"}}
+			It("CM with invalid protocol", func() {
 
-			edgeDeviceRepoMock.EXPECT().
-				Read(gomock.Any(), deviceName, testNamespace).
-				Return(device, nil).
-				Times(1)
+				// given
+				Mockk8sClient.EXPECT().Get(
+					gomock.Any(),
+					types.NamespacedName{Namespace: testNamespace, Name: "syslog-config"},
+					gomock.Any()).
+					Do(func(ctx context.Context, key client.ObjectKey, obj client.Object) {
+						obj.(*corev1.ConfigMap).Data = map[string]string{
+							"Address":  "127.0.0.1:512",
+							"Protocol": "invalid",
+						}
+					}).
+					Return(nil).
+					Times(1)
 
-			secretName := "test"
-			secretNamespacedName := types.NamespacedName{Namespace: device.Namespace, Name: secretName}
-			podData := v1alpha1.Pod{
-				Spec: corev1.PodSpec{
-					Containers: []corev1.Container{
-						{
-							Name:  "test",
-							Image: "test",
-							EnvFrom: []corev1.EnvFromSource{
-								{
-									SecretRef: &corev1.SecretEnvSource{
-										LocalObjectReference: corev1.LocalObjectReference{
-											Name: secretName,
-										},
-									},
-								},
-							},
-						},
+				device.Spec.LogCollection = map[string]*v1alpha1.LogCollectionConfig{
+					"syslog": {
+						Kind:         "syslog",
+						BufferSize:   10,
+						SyslogConfig: &v1alpha1.NameRef{Name: "syslog-config"},
 					},
-				},
-			}
+				}
+				deploy.Spec.LogCollection = "syslog"
+				// when
+				res := handler.GetDataMessageForDevice(context.TODO(), params)
 
-			deploymentData := &v1alpha1.EdgeDeployment{
-				ObjectMeta: v1.ObjectMeta{
-					Name:      "workload1",
-					Namespace: "default",
-				},
-				Spec: v1alpha1.EdgeDeploymentSpec{
-					DeviceSelector: &v1.LabelSelector{
-						MatchLabels: map[string]string{"test": "test"},
-					},
-					Type: "pod",
-					Pod:  podData,
-					Data: &v1alpha1.DataConfiguration{},
-				}}
-			configMap.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.ConfigmapList{}, nil)
-			deployRepoMock.EXPECT().
-				Read(gomock.Any(), "workload1", testNamespace).
-				Return(deploymentData, nil)
-			Mockk8sClient.EXPECT().
-				Get(gomock.Any(), secretNamespacedName, gomock.Any()).
-				Return(fmt.Errorf("test"))
+				// then
 
-			// when
-			res := handler.GetDataMessageForDevice(context.TODO(), params)
+				Expect(res).To(Equal(operations.NewGetDataMessageForDeviceInternalServerError()))
+			})
 
-			// then
-			Expect(res).To(Equal(operations.NewGetDataMessageForDeviceInternalServerError()))
-		})
+			It("No valid cm", func() {
 
-		It("Secrets missing secret", func() {
-			// given
-			deviceName := "foo"
-			device := getDevice(deviceName)
-			device.Status.Deployments = []v1alpha1.Deployment{{Name: "workload1"}}
+				// given
+				Mockk8sClient.EXPECT().Get(
+					gomock.Any(),
+					types.NamespacedName{Namespace: testNamespace, Name: "syslog-config"},
+					gomock.Any()).
+					Return(fmt.Errorf("Invalid error")).
+					Times(1)
 
-			edgeDeviceRepoMock.EXPECT().
-				Read(gomock.Any(), deviceName, testNamespace).
-				Return(device, nil).
-				Times(1)
+				device.Spec.LogCollection = map[string]*v1alpha1.LogCollectionConfig{
+					"syslog": {
+						Kind:         "syslog",
+						BufferSize:   10,
+						SyslogConfig: &v1alpha1.NameRef{Name: "syslog-config"},
+					},
+				}
+				deploy.Spec.LogCollection = "syslog"
+				// when
+				res := handler.GetDataMessageForDevice(context.TODO(), params)
 
-			secretName := "test"
-			secretNamespacedName := types.NamespacedName{Namespace: device.Namespace, Name: secretName}
-			podData := v1alpha1.Pod{
-				Spec: corev1.PodSpec{
-					Containers: []corev1.Container{
-						{
-							Name:  "test",
-							Image: "test",
-							EnvFrom: []corev1.EnvFromSource{
-								{
-									SecretRef: &corev1.SecretEnvSource{
-										LocalObjectReference: corev1.LocalObjectReference{
-											Name: secretName,
-										},
-									},
-								},
+				// then
+				Expect(res).To(Equal(operations.NewGetDataMessageForDeviceInternalServerError()))
+			})
+
+			Context("Syslog options", func() {
+				var (
+					certificatePEM, keyPEM []byte
+				)
+
+				givenSyslogConfigMap := func(protocol string) {
+					Mockk8sClient.EXPECT().Get(
+						gomock.Any(),
+						types.NamespacedName{Namespace: testNamespace, Name: "syslog-config"},
+						gomock.Any()).
+						Do(func(ctx context.Context, key client.ObjectKey, obj client.Object) {
+							obj.(*corev1.ConfigMap).Data = map[string]string{
+								"Address":  "syslog.example.com:6514",
+								"Protocol": protocol,
+							}
+						}).
+						Return(nil).
+						Times(1)
+				}
+
+				givenTLSSecret := func(data map[string][]byte) {
+					Mockk8sClient.EXPECT().Get(
+						gomock.Any(),
+						types.NamespacedName{Namespace: testNamespace, Name: "syslog-tls"},
+						gomock.Any()).
+						Do(func(ctx context.Context, key client.ObjectKey, obj client.Object) {
+							obj.(*corev1.Secret).Data = data
+						}).
+						Return(nil).
+						Times(1)
+				}
+
+				BeforeEach(func() {
+					key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+					Expect(err).NotTo(HaveOccurred())
+					template := x509.Certificate{
+						SerialNumber:          big.NewInt(1),
+						Subject:               pkix.Name{CommonName: "syslog-ca"},
+						NotBefore:             time.Now(),
+						NotAfter:              time.Now().Add(time.Hour),
+						IsCA:                  true,
+						BasicConstraintsValid: true,
+					}
+					certificate, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+					Expect(err).NotTo(HaveOccurred())
+					certificatePEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate})
+					keyDER, err := x509.MarshalECPrivateKey(key)
+					Expect(err).NotTo(HaveOccurred())
+					keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+					device.Spec.LogCollection = map[string]*v1alpha1.LogCollectionConfig{
+						"syslog": {
+							Kind:         "syslog",
+							BufferSize:   10,
+							SyslogConfig: &v1alpha1.NameRef{Name: "syslog-config"},
+							SyslogOptions: &v1alpha1.SyslogOptions{
+								Format:   "rfc3164",
+								Framing:  "octet-counting",
+								Facility: "local3",
+								Tag:      "camera",
+								TLS:      &v1alpha1.SyslogTLS{SecretRef: v1alpha1.NameRef{Name: "syslog-tls"}},
 							},
 						},
-					},
-				},
-			}
+					}
+					deploy.Spec.LogCollection = "syslog"
+				})
 
-			deploymentData := &v1alpha1.EdgeDeployment{
-				ObjectMeta: v1.ObjectMeta{
-					Name:      "workload1",
-					Namespace: "default",
-				},
-				Spec: v1alpha1.EdgeDeploymentSpec{
-					DeviceSelector: &v1.LabelSelector{
-						MatchLabels: map[string]string{"test": "test"},
-					},
-					Type: "pod",
-					Pod:  podData,
-					Data: &v1alpha1.DataConfiguration{},
-				}}
-			configMap.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.ConfigmapList{}, nil)
-			deployRepoMock.EXPECT().
-				Read(gomock.Any(), "workload1", testNamespace).
-				Return(deploymentData, nil)
-			Mockk8sClient.EXPECT().
-				Get(gomock.Any(), secretNamespacedName, gomock.Any()).
-				Return(errorNotFound)
+				It("Options and TLS credentials are propagated", func() {
+					// given
+					givenSyslogConfigMap("tcp")
+					givenTLSSecret(map[string][]byte{
+						"ca.crt":  certificatePEM,
+						"tls.crt": certificatePEM,
+						"tls.key": keyPEM,
+					})
 
-			// when
-			res := handler.GetDataMessageForDevice(context.TODO(), params)
+					// when
+					res := handler.GetDataMessageForDevice(context.TODO(), params)
 
-			// then
-			Expect(res).To(Equal(operations.NewGetDataMessageForDeviceInternalServerError()))
-		})
+					// then
+					Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceOK{}))
+					config := validateAndGetDeviceConfig(res)
+					syslogConfig := config.Configuration.LogCollection["syslog"].SyslogConfig
+					Expect(syslogConfig).To(Equal(&models.LogsCollectionInformationSyslogConfig{
+						Address:  "syslog.example.com:6514",
+						Protocol: "tcp",
+						Format:   models.LogsCollectionInformationSyslogConfigFormatRfc3164,
+						Framing:  models.LogsCollectionInformationSyslogConfigFramingOctetCounting,
+						Facility: "local3",
+						Tag:      "camera",
+						TLS: &models.SyslogTLSConfiguration{
+							CaBundle:          string(certificatePEM),
+							ClientCertificate: string(certificatePEM),
+							ClientKey:         string(keyPEM),
+						},
+					}))
+				})
 
-		It("Secrets partially optional secret", func() {
-			// given
-			deviceName := "foo"
-			device := getDevice(deviceName)
-			device.Status.Deployments = []v1alpha1.Deployment{{Name: "workload1"}}
+				It("Client certificate is optional", func() {
+					// given
+					givenSyslogConfigMap("tcp")
+					givenTLSSecret(map[string][]byte{"ca.crt": certificatePEM})
 
-			edgeDeviceRepoMock.EXPECT().
-				Read(gomock.Any(), deviceName, testNamespace).
-				Return(device, nil).
-				Times(1)
+					// when
+					res := handler.GetDataMessageForDevice(context.TODO(), params)
 
-			secretName := "test"
-			secretNamespacedName := types.NamespacedName{Namespace: device.Namespace, Name: secretName}
-			podData := v1alpha1.Pod{
-				Spec: corev1.PodSpec{
-					Containers: []corev1.Container{
-						{
-							Name:  "test",
-							Image: "test",
-							EnvFrom: []corev1.EnvFromSource{
-								{
-									SecretRef: &corev1.SecretEnvSource{
-										LocalObjectReference: corev1.LocalObjectReference{
-											Name: secretName,
-										},
-									},
-								},
-							},
-							Env: []corev1.EnvVar{
-								{
-									Name: "test",
-									ValueFrom: &corev1.EnvVarSource{
-										SecretKeyRef: &corev1.SecretKeySelector{
-											LocalObjectReference: corev1.LocalObjectReference{
-												Name: secretName,
-											},
-											Key:      "key",
-											Optional: &boolTrue,
-										},
-									},
-								},
-							},
-						},
-					},
-				},
-			}
+					// then
+					Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceOK{}))
+					config := validateAndGetDeviceConfig(res)
+					tlsConfig := config.Configuration.LogCollection["syslog"].SyslogConfig.TLS
+					Expect(tlsConfig.CaBundle).To(Equal(string(certificatePEM)))
+					Expect(tlsConfig.ClientCertificate).To(BeEmpty())
+					Expect(tlsConfig.ClientKey).To(BeEmpty())
+				})
 
-			deploymentData := &v1alpha1.EdgeDeployment{
-				ObjectMeta: v1.ObjectMeta{
-					Name:      "workload1",
-					Namespace: "default",
-				},
-				Spec: v1alpha1.EdgeDeploymentSpec{
-					DeviceSelector: &v1.LabelSelector{
-						MatchLabels: map[string]string{"test": "test"},
-					},
-					Type: "pod",
-					Pod:  podData,
-					Data: &v1alpha1.DataConfiguration{},
-				}}
-			configMap.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.ConfigmapList{}, nil)
-			deployRepoMock.EXPECT().
-				Read(gomock.Any(), "workload1", testNamespace).
-				Return(deploymentData, nil)
-			Mockk8sClient.EXPECT().
-				Get(gomock.Any(), secretNamespacedName, gomock.Any()).
-				Return(errorNotFound)
+				It("Secret without CA bundle", func() {
+					// given
+					givenSyslogConfigMap("tcp")
+					givenTLSSecret(map[string][]byte{"tls.crt": certificatePEM, "tls.key": keyPEM})
 
-			// when
-			res := handler.GetDataMessageForDevice(context.TODO(), params)
+					// when
+					res := handler.GetDataMessageForDevice(context.TODO(), params)
 
-			// then
-			Expect(res).To(Equal(operations.NewGetDataMessageForDeviceInternalServerError()))
+					// then
+					Expect(res).To(Equal(operations.NewGetDataMessageForDeviceInternalServerError()))
+				})
+
+				It("Client certificate without key", func() {
+					// given
+					givenSyslogConfigMap("tcp")
+					givenTLSSecret(map[string][]byte{"ca.crt": certificatePEM, "tls.crt": certificatePEM})
+
+					// when
+					res := handler.GetDataMessageForDevice(context.TODO(), params)
+
+					// then
+					Expect(res).To(Equal(operations.NewGetDataMessageForDeviceInternalServerError()))
+				})
+
+				It("TLS over UDP", func() {
+					// given
+					givenSyslogConfigMap("udp")
+
+					// when
+					res := handler.GetDataMessageForDevice(context.TODO(), params)
+
+					// then
+					Expect(res).To(Equal(operations.NewGetDataMessageForDeviceInternalServerError()))
+				})
+			})
 		})
-		Context("Secrets missing secret key", func() {
-			podData1 := v1alpha1.Pod{
-				Spec: corev1.PodSpec{
-					Containers: []corev1.Container{
-						{
-							Name:  "test",
-							Image: "test",
-							Env: []corev1.EnvVar{
-								{
-									Name: "test",
-									ValueFrom: &corev1.EnvVarSource{
-										SecretKeyRef: &corev1.SecretKeySelector{
-											LocalObjectReference: corev1.LocalObjectReference{
-												Name: "secret",
-											},
-											Key: "key",
-										},
-									},
-								},
-								{
-									Name: "test",
-									ValueFrom: &corev1.EnvVarSource{
-										SecretKeyRef: &corev1.SecretKeySelector{
-											LocalObjectReference: corev1.LocalObjectReference{
-												Name: "secret",
-											},
-											Key: "key1",
-										},
-									},
-								},
-							},
-						},
-					},
-				},
-			}
-			podData2 := v1alpha1.Pod{
-				Spec: corev1.PodSpec{
-					Containers: []corev1.Container{
-						{
-							Name:  "test",
-							Image: "test",
-							Env: []corev1.EnvVar{
-								{
-									Name: "test",
-									ValueFrom: &corev1.EnvVarSource{
-										SecretKeyRef: &corev1.SecretKeySelector{
-											LocalObjectReference: corev1.LocalObjectReference{
-												Name: "secret",
-											},
-											Key: "key",
-										},
-									},
-								},
-								{
-									Name: "test",
-									ValueFrom: &corev1.EnvVarSource{
-										SecretKeyRef: &corev1.SecretKeySelector{
-											LocalObjectReference: corev1.LocalObjectReference{
-												Name: "secret",
-											},
-											Key:      "key",
-											Optional: &boolTrue,
-										},
-									},
-								},
-							},
-						},
-					},
-				},
-			}
-			podData3 := v1alpha1.Pod{
-				Spec: corev1.PodSpec{
-					Containers: []corev1.Container{
-						{
-							Name:  "test",
-							Image: "test",
-							EnvFrom: []corev1.EnvFromSource{
-								{
-									SecretRef: &corev1.SecretEnvSource{
-										LocalObjectReference: corev1.LocalObjectReference{
-											Name: "secret",
-										},
-									},
-								},
-							},
-							Env: []corev1.EnvVar{
-								{
-									Name: "test",
-									ValueFrom: &corev1.EnvVarSource{
-										SecretKeyRef: &corev1.SecretKeySelector{
-											LocalObjectReference: corev1.LocalObjectReference{
-												Name: "secret",
-											},
-											Key:      "key",
-											Optional: &boolTrue,
-										},
-									},
-								},
-								{
-									Name: "test",
-									ValueFrom: &corev1.EnvVarSource{
-										SecretKeyRef: &corev1.SecretKeySelector{
-											LocalObjectReference: corev1.LocalObjectReference{
-												Name: "secret",
-											},
-											Key: "key",
-										},
-									},
-								},
-							},
-						},
-					},
-				},
-			}
-			table.DescribeTable("Test table", func(podData *v1alpha1.Pod) {
-				// given
-				deviceName := "foo"
-				device := getDevice(deviceName)
+
+		Context("Metrics", func() {
+			var (
+				deviceName    string = "foo"
+				device        *v1alpha1.EdgeDevice
+				allowList     = models.MetricsAllowList{Names: []string{"foo", "bar"}}
+				allowListName = "foo"
+			)
+
+			BeforeEach(func() {
+				deviceName = "foo"
+				device = getDevice(deviceName)
 				device.Status.Deployments = []v1alpha1.Deployment{{Name: "workload1"}}
 
 				edgeDeviceRepoMock.EXPECT().
 					Read(gomock.Any(), deviceName, testNamespace).
 					Return(device, nil).
 					Times(1)
+			})
 
-				deploymentData := &v1alpha1.EdgeDeployment{
+			getDeployment := func(name string, ns string) *v1alpha1.EdgeDeployment {
+				return &v1alpha1.EdgeDeployment{
 					ObjectMeta: v1.ObjectMeta{
-						Name:      "workload1",
-						Namespace: "default",
+						Name:      name,
+						Namespace: ns,
 					},
 					Spec: v1alpha1.EdgeDeploymentSpec{
-						DeviceSelector: &v1.LabelSelector{
-							MatchLabels: map[string]string{"test": "test"},
-						},
 						Type: "pod",
-						Pod:  *podData,
-						Data: &v1alpha1.DataConfiguration{},
-					}}
-				configMap.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.ConfigmapList{}, nil)
-				deployRepoMock.EXPECT().
-					Read(gomock.Any(), "workload1", testNamespace).
-					Return(deploymentData, nil)
-				secretDataMap := map[string][]byte{"key1": []byte("username"), "key2": []byte("password")}
-				Mockk8sClient.EXPECT().
-					Get(gomock.Any(), types.NamespacedName{Namespace: device.Namespace, Name: "secret"}, gomock.Any()).
-					Do(func(ctx context.Context, key client.ObjectKey, obj client.Object) {
-						obj.(*corev1.Secret).Data = secretDataMap
-					}).
-					Return(nil).Times(1)
+						Pod:  v1alpha1.Pod{},
+					},
+				}
+			}
 
-				// when
+			It("Path, port, interval, allowList is honored", func() {
+				// given
+				expectedResult := &models.Metrics{
+					Path:      "/metrics",
+					Port:      9999,
+					Interval:  55,
+					AllowList: &allowList,
+				}
+
+				deploy := getDeployment("workload1", testNamespace)
+				deploy.Spec.Metrics = &v1alpha1.ContainerMetricsConfiguration{
+					Path: "/metrics", Port: 9999, Interval: 55, AllowList: &v1alpha1.NameRef{
+						Name: allowListName,
+					}}
+
+				configMap.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.ConfigmapList{}, nil)
+				deployRepoMock.EXPECT().
+					Read(gomock.Any(), "workload1", testNamespace).
+					Return(deploy, nil)
+
+				allowListsMock.EXPECT().
+					GenerateFromConfigMap(gomock.Any(), allowListName, testNamespace).
+					Return(&allowList, nil).Times(1)
+
+				// when
 				res := handler.GetDataMessageForDevice(context.TODO(), params)
 
 				// then
-				Expect(res).To(Equal(operations.NewGetDataMessageForDeviceInternalServerError()))
-			},
-				table.Entry("missing secret key", &podData1),
-				table.Entry("partially optional secret key - mandatory appears first", &podData2),
-				table.Entry("partially optional secret key - optional appears first", &podData3),
-			)
-		})
+				Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceOK{}))
+				config := validateAndGetDeviceConfig(res)
 
-		It("Secrets reading succeeded", func() {
-			// This test covers:
-			// multiple deployment
-			// init containers and regular containers
-			// secretRef and secretKeyRef
-			// optional secretRef
-			// optional secretKeyRef missing secret
-			// optional secretKeyRef missing key
-			// duplicate secret references
+				Expect(config.DeviceID).To(Equal(deviceName))
+				Expect(config.Workloads).To(HaveLen(1))
+				Expect(config.Workloads[0].Metrics).To(Equal(expectedResult))
+			})
+
+			It("MetricsAllowList with overrides is honored", func() {
+				// given
+				deploy := getDeployment("workload1", testNamespace)
+				deploy.Spec.Metrics = &v1alpha1.ContainerMetricsConfiguration{
+					Path: "/metrics", Port: 9999,
+					MetricsAllowList:   &v1alpha1.NameRef{Name: "an-allow-list"},
+					AllowListOverrides: &v1alpha1.MetricsAllowListOverrides{Add: []string{"uptime"}},
+				}
+
+				configMap.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.ConfigmapList{}, nil)
+				deployRepoMock.EXPECT().
+					Read(gomock.Any(), "workload1", testNamespace).
+					Return(deploy, nil)
+
+				allowListsMock.EXPECT().
+					GenerateFromAllowList(gomock.Any(), "an-allow-list", testNamespace).
+					Return(&models.MetricsAllowList{Names: []string{"requests_total"}}, nil).Times(1)
+
+				// when
+				res := handler.GetDataMessageForDevice(context.TODO(), params)
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceOK{}))
+				config := validateAndGetDeviceConfig(res)
+
+				Expect(config.Workloads).To(HaveLen(1))
+				Expect(config.Workloads[0].Metrics.AllowList).To(Equal(&models.MetricsAllowList{
+					Names: []string{"requests_total", "uptime"},
+				}))
+			})
+
+			It("AllowList configmap retrival error", func() {
+
+				// given
+				deploy := getDeployment("workload1", testNamespace)
+				deploy.Spec.Metrics = &v1alpha1.ContainerMetricsConfiguration{
+					Path: "/metrics", Port: 9999, Interval: 55, AllowList: &v1alpha1.NameRef{
+						Name: allowListName,
+					}}
+
+				deployRepoMock.EXPECT().
+					Read(gomock.Any(), "workload1", testNamespace).
+					Return(deploy, nil)
+
+				allowListsMock.EXPECT().
+					GenerateFromConfigMap(gomock.Any(), allowListName, testNamespace).
+					Return(nil, fmt.Errorf("Failed to get CM")).Times(1)
+
+				// when
+				res := handler.GetDataMessageForDevice(context.TODO(), params)
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceInternalServerError{}))
+			})
+
+			It("Path and port in containers is honored", func() {
+
+				// given
+				expectedResult := &models.Metrics{
+					Path: "/metrics",
+					Port: 9999,
+					Containers: map[string]models.ContainerMetrics{
+						"test": {
+							Disabled: false,
+							Port:     int32(8899),
+							Path:     "/test/",
+						},
+					},
+				}
+
+				deploy := getDeployment("workload1", testNamespace)
+				deploy.Spec.Metrics = &v1alpha1.ContainerMetricsConfiguration{
+					Path: "/metrics", Port: 9999, Containers: map[string]*v1alpha1.MetricsConfigEntity{
+						"test": {
+							Port:     8899,
+							Path:     "/test/",
+							Disabled: false,
+						},
+					},
+				}
+
+				configMap.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.ConfigmapList{}, nil)
+				deployRepoMock.EXPECT().
+					Read(gomock.Any(), "workload1", testNamespace).
+					Return(deploy, nil)
+
+				// when
+				res := handler.GetDataMessageForDevice(context.TODO(), params)
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceOK{}))
+				config := validateAndGetDeviceConfig(res)
+
+				Expect(config.DeviceID).To(Equal(deviceName))
+				Expect(config.Workloads).To(HaveLen(1))
+				Expect(config.Workloads[0].Metrics).To(Equal(expectedResult))
+			})
+
+			It("Negative port is not considered", func() {
+
+				// given
+				deploy := getDeployment("workload1", testNamespace)
+				deploy.Spec.Metrics = &v1alpha1.ContainerMetricsConfiguration{
+					Path: "/metrics",
+					Port: -1,
+				}
+
+				configMap.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.ConfigmapList{}, nil)
+				deployRepoMock.EXPECT().
+					Read(gomock.Any(), "workload1", testNamespace).
+					Return(deploy, nil)
+
+				// when
+				res := handler.GetDataMessageForDevice(context.TODO(), params)
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceOK{}))
+				config := validateAndGetDeviceConfig(res)
+
+				Expect(config.DeviceID).To(Equal(deviceName))
+				Expect(config.Workloads).To(HaveLen(1))
+				Expect(config.Workloads[0].Metrics).To(BeNil())
+			})
+
+		})
 
+		It("Image registry authfile is included", func() {
 			// given
 			deviceName := "foo"
 			device := getDevice(deviceName)
-			device.Status.Deployments = []v1alpha1.Deployment{{Name: "workload1"}, {Name: "workload2"}}
+			device.Status.Deployments = []v1alpha1.Deployment{{Name: "workload1"}}
 
 			edgeDeviceRepoMock.EXPECT().
 				Read(gomock.Any(), deviceName, testNamespace).
 				Return(device, nil).
 				Times(1)
 
-			podData1 := v1alpha1.Pod{
-				Spec: corev1.PodSpec{
-					InitContainers: []corev1.Container{
-						{
-							Name:  "ic1",
-							Image: "test",
-							EnvFrom: []corev1.EnvFromSource{
-								{
-									SecretRef: &corev1.SecretEnvSource{
-										LocalObjectReference: corev1.LocalObjectReference{
-											Name: "secret1",
-										},
-									},
-								},
-							},
-							Env: []corev1.EnvVar{
-								{
-									Name: "test",
-									ValueFrom: &corev1.EnvVarSource{
-										SecretKeyRef: &corev1.SecretKeySelector{
-											LocalObjectReference: corev1.LocalObjectReference{
-												Name: "secret1",
-											},
-											Key:      "notexist",
-											Optional: &boolTrue,
-										},
-									},
-								},
-							},
-						},
-					},
-					Containers: []corev1.Container{
-						{
-							Name:  "c1",
-							Image: "test",
-							EnvFrom: []corev1.EnvFromSource{
-								{
-									SecretRef: &corev1.SecretEnvSource{
-										LocalObjectReference: corev1.LocalObjectReference{
-											Name: "secret2",
-										},
-									},
-								},
-							},
-							Env: []corev1.EnvVar{
-								{
-									Name: "test",
-									ValueFrom: &corev1.EnvVarSource{
-										SecretKeyRef: &corev1.SecretKeySelector{
-											LocalObjectReference: corev1.LocalObjectReference{
-												Name: "secret3",
-											},
-											Key: "key1",
-										},
-									},
-								},
-							},
-						},
-						{
-							Name:  "c2",
-							Image: "test",
-							Env: []corev1.EnvVar{
-								{
-									Name: "test",
-									ValueFrom: &corev1.EnvVarSource{
-										SecretKeyRef: &corev1.SecretKeySelector{
-											LocalObjectReference: corev1.LocalObjectReference{
-												Name: "secret4",
-											},
-											Key: "key1",
-										},
-									},
-								},
-							},
+			deploymentData := &v1alpha1.EdgeDeployment{
+				ObjectMeta: v1.ObjectMeta{
+					Name:      "workload1",
+					Namespace: "default",
+				},
+				Spec: v1alpha1.EdgeDeploymentSpec{
+					Type: "pod",
+					Pod:  v1alpha1.Pod{},
+					ImageRegistries: &v1alpha1.ImageRegistriesConfiguration{
+						AuthFileSecret: &v1alpha1.NameRef{
+							Name: "fooSecret",
 						},
 					},
-				},
-			}
-			podData2 := v1alpha1.Pod{
-				Spec: corev1.PodSpec{
-					InitContainers: []corev1.Container{
-						{
-							Name:  "ic1",
-							Image: "test",
-							Env: []corev1.EnvVar{
-								{
-									Name: "test",
-									ValueFrom: &corev1.EnvVarSource{
-										SecretKeyRef: &corev1.SecretKeySelector{
-											LocalObjectReference: corev1.LocalObjectReference{
-												Name: "optional1",
-											},
-											Key:      "key1",
-											Optional: &boolTrue,
-										},
-									},
-								},
-							},
+				}}
+			configMap.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.ConfigmapList{}, nil)
+			deployRepoMock.EXPECT().
+				Read(gomock.Any(), "workload1", testNamespace).
+				Return(deploymentData, nil)
+
+			authFileContent := "authfile-content"
+			registryAuth.EXPECT().
+				GetAuthFileFromSecret(gomock.Any(), gomock.Eq(deploymentData.Namespace), gomock.Eq("fooSecret")).
+				Return(authFileContent, nil)
+
+			// when
+			res := handler.GetDataMessageForDevice(context.TODO(), params)
+
+			// then
+			Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceOK{}))
+			config := validateAndGetDeviceConfig(res)
+
+			Expect(config.DeviceID).To(Equal(deviceName))
+			Expect(config.Workloads).To(HaveLen(1))
+			workload := config.Workloads[0]
+			Expect(workload.Name).To(Equal("workload1"))
+			Expect(workload.ImageRegistries).To(Not(BeNil()))
+			Expect(workload.ImageRegistries.AuthFile).To(Equal(authFileContent))
+
+			Expect(eventsRecorder.Events).ToNot(Receive())
+		})
+
+		It("Image registry authfile retrieval error", func() {
+			// given
+			deviceName := "foo"
+			device := getDevice(deviceName)
+			device.Status.Deployments = []v1alpha1.Deployment{{Name: "workload1"}}
+
+			edgeDeviceRepoMock.EXPECT().
+				Read(gomock.Any(), deviceName, testNamespace).
+				Return(device, nil).
+				Times(1)
+
+			deploymentData := &v1alpha1.EdgeDeployment{
+				ObjectMeta: v1.ObjectMeta{
+					Name:      "workload1",
+					Namespace: "default",
+				},
+				Spec: v1alpha1.EdgeDeploymentSpec{
+					Type: "pod",
+					Pod:  v1alpha1.Pod{},
+					ImageRegistries: &v1alpha1.ImageRegistriesConfiguration{
+						AuthFileSecret: &v1alpha1.NameRef{
+							Name: "fooSecret",
 						},
 					},
+				}}
+			deployRepoMock.EXPECT().
+				Read(gomock.Any(), "workload1", testNamespace).
+				Return(deploymentData, nil)
+
+			registryAuth.EXPECT().
+				GetAuthFileFromSecret(gomock.Any(), gomock.Eq("default"), gomock.Eq("fooSecret")).
+				Return("", fmt.Errorf("failure"))
+
+			// when
+			res := handler.GetDataMessageForDevice(context.TODO(), params)
+
+			// then
+			Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceInternalServerError{}))
+
+			Expect(eventsRecorder.Events).To(HaveLen(1))
+			Expect(eventsRecorder.Events).To(Receive(ContainSubstring("Auth file secret")))
+		})
+
+		It("Secrets reading failed", func() {
+			// given
+			deviceName := "foo"
+			device := getDevice(deviceName)
+			device.Status.Deployments = []v1alpha1.Deployment{{Name: "workload1"}}
+
+			edgeDeviceRepoMock.EXPECT().
+				Read(gomock.Any(), deviceName, testNamespace).
+				Return(device, nil).
+				Times(1)
+
+			secretName := "test"
+			secretNamespacedName := types.NamespacedName{Namespace: device.Namespace, Name: secretName}
+			podData := v1alpha1.Pod{
+				Spec: corev1.PodSpec{
 					Containers: []corev1.Container{
 						{
-							Name:  "c1",
+							Name:  "test",
 							Image: "test",
 							EnvFrom: []corev1.EnvFromSource{
 								{
 									SecretRef: &corev1.SecretEnvSource{
 										LocalObjectReference: corev1.LocalObjectReference{
-											Name: "optional2",
+											Name: secretName,
 										},
-										Optional: &boolTrue,
 									},
 								},
 							},
 						},
+					},
+				},
+			}
+
+			deploymentData := &v1alpha1.EdgeDeployment{
+				ObjectMeta: v1.ObjectMeta{
+					Name:      "workload1",
+					Namespace: "default",
+				},
+				Spec: v1alpha1.EdgeDeploymentSpec{
+					DeviceSelector: &v1.LabelSelector{
+						MatchLabels: map[string]string{"test": "test"},
+					},
+					Type: "pod",
+					Pod:  podData,
+					Data: &v1alpha1.DataConfiguration{},
+				}}
+			configMap.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.ConfigmapList{}, nil)
+			deployRepoMock.EXPECT().
+				Read(gomock.Any(), "workload1", testNamespace).
+				Return(deploymentData, nil)
+			Mockk8sClient.EXPECT().
+				Get(gomock.Any(), secretNamespacedName, gomock.Any()).
+				Return(fmt.Errorf("test"))
+
+			// when
+			res := handler.GetDataMessageForDevice(context.TODO(), params)
+
+			// then
+			Expect(res).To(Equal(operations.NewGetDataMessageForDeviceInternalServerError()))
+		})
+
+		It("Secrets missing secret", func() {
+			// given
+			deviceName := "foo"
+			device := getDevice(deviceName)
+			device.Status.Deployments = []v1alpha1.Deployment{{Name: "workload1"}}
+
+			edgeDeviceRepoMock.EXPECT().
+				Read(gomock.Any(), deviceName, testNamespace).
+				Return(device, nil).
+				Times(1)
+
+			secretName := "test"
+			secretNamespacedName := types.NamespacedName{Namespace: device.Namespace, Name: secretName}
+			podData := v1alpha1.Pod{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
 						{
-							Name:  "c2",
+							Name:  "test",
 							Image: "test",
 							EnvFrom: []corev1.EnvFromSource{
 								{
 									SecretRef: &corev1.SecretEnvSource{
 										LocalObjectReference: corev1.LocalObjectReference{
-											Name: "secret1",
-										},
-									},
-								},
-								{
-									SecretRef: &corev1.SecretEnvSource{
-										LocalObjectReference: corev1.LocalObjectReference{
-											Name: "secret5",
-										},
-									},
-								},
-							},
-							Env: []corev1.EnvVar{
-								{
-									Name: "test",
-									ValueFrom: &corev1.EnvVarSource{
-										SecretKeyRef: &corev1.SecretKeySelector{
-											LocalObjectReference: corev1.LocalObjectReference{
-												Name: "optional1",
-											},
-											Key:      "key1",
-											Optional: &boolTrue,
+											Name: secretName,
 										},
 									},
 								},
@@ -1427,7 +1705,7 @@ var _ = Describe("Yggdrasil", func() {
 				},
 			}
 
-			deploymentData1 := &v1alpha1.EdgeDeployment{
+			deploymentData := &v1alpha1.EdgeDeployment{
 				ObjectMeta: v1.ObjectMeta{
 					Name:      "workload1",
 					Namespace: "default",
@@ -1437,12 +1715,60 @@ var _ = Describe("Yggdrasil", func() {
 						MatchLabels: map[string]string{"test": "test"},
 					},
 					Type: "pod",
-					Pod:  podData1,
+					Pod:  podData,
 					Data: &v1alpha1.DataConfiguration{},
 				}}
-			deploymentData2 := &v1alpha1.EdgeDeployment{
+			configMap.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.ConfigmapList{}, nil)
+			deployRepoMock.EXPECT().
+				Read(gomock.Any(), "workload1", testNamespace).
+				Return(deploymentData, nil)
+			Mockk8sClient.EXPECT().
+				Get(gomock.Any(), secretNamespacedName, gomock.Any()).
+				Return(errorNotFound)
+
+			// when
+			res := handler.GetDataMessageForDevice(context.TODO(), params)
+
+			// then
+			Expect(res).To(Equal(operations.NewGetDataMessageForDeviceInternalServerError()))
+		})
+
+		It("Rendering reports missing secret as resolution error", func() {
+			// given
+			deviceName := "foo"
+			device := getDevice(deviceName)
+			device.Status.Deployments = []v1alpha1.Deployment{{Name: "workload1"}}
+
+			edgeDeviceRepoMock.EXPECT().
+				Read(gomock.Any(), deviceName, testNamespace).
+				Return(device, nil).
+				Times(1)
+
+			secretName := "test"
+			secretNamespacedName := types.NamespacedName{Namespace: device.Namespace, Name: secretName}
+			podData := v1alpha1.Pod{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  "test",
+							Image: "test",
+							EnvFrom: []corev1.EnvFromSource{
+								{
+									SecretRef: &corev1.SecretEnvSource{
+										LocalObjectReference: corev1.LocalObjectReference{
+											Name: secretName,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			}
+
+			deploymentData := &v1alpha1.EdgeDeployment{
 				ObjectMeta: v1.ObjectMeta{
-					Name:      "workload2",
+					Name:      "workload1",
 					Namespace: "default",
 				},
 				Spec: v1alpha1.EdgeDeploymentSpec{
@@ -1450,802 +1776,2647 @@ var _ = Describe("Yggdrasil", func() {
 						MatchLabels: map[string]string{"test": "test"},
 					},
 					Type: "pod",
-					Pod:  podData2,
+					Pod:  podData,
 					Data: &v1alpha1.DataConfiguration{},
 				}}
-
-			configMap.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.ConfigmapList{}, nil).AnyTimes()
+			configMap.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.ConfigmapList{}, nil)
 			deployRepoMock.EXPECT().
 				Read(gomock.Any(), "workload1", testNamespace).
-				Return(deploymentData1, nil)
-			deployRepoMock.EXPECT().
-				Read(gomock.Any(), "workload2", testNamespace).
-				Return(deploymentData2, nil)
-
-			secretName := types.NamespacedName{
-				Namespace: device.Namespace,
-			}
-			secretDataMap := map[string][]byte{"key1": []byte("username"), "key2": []byte("password")}
-			secretDataJson := `{"key1":"dXNlcm5hbWU=","key2":"cGFzc3dvcmQ="}`
-			secretName.Name = "secret1"
-			Mockk8sClient.EXPECT().
-				Get(gomock.Any(), secretName, gomock.Any()).
-				Return(nil).Times(1)
-			secretName.Name = "secret2"
+				Return(deploymentData, nil)
 			Mockk8sClient.EXPECT().
-				Get(gomock.Any(), secretName, gomock.Any()).
-				Return(nil).Times(1)
-			secretName.Name = "secret3"
-			Mockk8sClient.EXPECT().
-				Get(gomock.Any(), secretName, gomock.Any()).
-				Do(func(ctx context.Context, key client.ObjectKey, obj client.Object) {
-					obj.(*corev1.Secret).Data = secretDataMap
-				}).
-				Return(nil).Times(1)
-			secretName.Name = "secret4"
-			Mockk8sClient.EXPECT().
-				Get(gomock.Any(), secretName, gomock.Any()).
-				Do(func(ctx context.Context, key client.ObjectKey, obj client.Object) {
-					obj.(*corev1.Secret).Data = secretDataMap
-				}).
-				Return(nil).Times(1)
-			secretName.Name = "secret5"
-			Mockk8sClient.EXPECT().
-				Get(gomock.Any(), secretName, gomock.Any()).
-				Return(nil).Times(1)
-			secretName.Name = "optional1"
-			Mockk8sClient.EXPECT().
-				Get(gomock.Any(), secretName, gomock.Any()).
-				Return(errorNotFound).Times(1)
-			secretName.Name = "optional2"
-			Mockk8sClient.EXPECT().
-				Get(gomock.Any(), secretName, gomock.Any()).
-				Return(errorNotFound).Times(1)
+				Get(gomock.Any(), secretNamespacedName, gomock.Any()).
+				Return(errorNotFound)
 
 			// when
-			res := handler.GetDataMessageForDevice(context.TODO(), params)
+			config, resolutionErrors, err := handler.RenderConfiguration(context.TODO(), deviceName, testNamespace)
 
 			// then
-			Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceOK{}))
-			config := validateAndGetDeviceConfig(res)
-			expectedList := []interface{}{
-				&models.Secret{
-					Name: "secret1",
-					Data: "{}",
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config.Workloads).To(HaveLen(1))
+			Expect(config.Secrets).To(BeEmpty())
+			Expect(resolutionErrors).To(HaveLen(1))
+			Expect(resolutionErrors[0].Item).To(Equal("secret " + secretName))
+		})
+
+		It("Secrets partially optional secret", func() {
+			// given
+			deviceName := "foo"
+			device := getDevice(deviceName)
+			device.Status.Deployments = []v1alpha1.Deployment{{Name: "workload1"}}
+
+			edgeDeviceRepoMock.EXPECT().
+				Read(gomock.Any(), deviceName, testNamespace).
+				Return(device, nil).
+				Times(1)
+
+			secretName := "test"
+			secretNamespacedName := types.NamespacedName{Namespace: device.Namespace, Name: secretName}
+			podData := v1alpha1.Pod{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  "test",
+							Image: "test",
+							EnvFrom: []corev1.EnvFromSource{
+								{
+									SecretRef: &corev1.SecretEnvSource{
+										LocalObjectReference: corev1.LocalObjectReference{
+											Name: secretName,
+										},
+									},
+								},
+							},
+							Env: []corev1.EnvVar{
+								{
+									Name: "test",
+									ValueFrom: &corev1.EnvVarSource{
+										SecretKeyRef: &corev1.SecretKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{
+												Name: secretName,
+											},
+											Key:      "key",
+											Optional: &boolTrue,
+										},
+									},
+								},
+							},
+						},
+					},
 				},
-				&models.Secret{
-					Name: "secret2",
-					Data: "{}",
+			}
+
+			deploymentData := &v1alpha1.EdgeDeployment{
+				ObjectMeta: v1.ObjectMeta{
+					Name:      "workload1",
+					Namespace: "default",
 				},
-				&models.Secret{
-					Name: "secret3",
-					Data: secretDataJson,
+				Spec: v1alpha1.EdgeDeploymentSpec{
+					DeviceSelector: &v1.LabelSelector{
+						MatchLabels: map[string]string{"test": "test"},
+					},
+					Type: "pod",
+					Pod:  podData,
+					Data: &v1alpha1.DataConfiguration{},
+				}}
+			configMap.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.ConfigmapList{}, nil)
+			deployRepoMock.EXPECT().
+				Read(gomock.Any(), "workload1", testNamespace).
+				Return(deploymentData, nil)
+			Mockk8sClient.EXPECT().
+				Get(gomock.Any(), secretNamespacedName, gomock.Any()).
+				Return(errorNotFound)
+
+			// when
+			res := handler.GetDataMessageForDevice(context.TODO(), params)
+
+			// then
+			Expect(res).To(Equal(operations.NewGetDataMessageForDeviceInternalServerError()))
+		})
+		Context("Secrets missing secret key", func() {
+			podData1 := v1alpha1.Pod{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  "test",
+							Image: "test",
+							Env: []corev1.EnvVar{
+								{
+									Name: "test",
+									ValueFrom: &corev1.EnvVarSource{
+										SecretKeyRef: &corev1.SecretKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{
+												Name: "secret",
+											},
+											Key: "key",
+										},
+									},
+								},
+								{
+									Name: "test",
+									ValueFrom: &corev1.EnvVarSource{
+										SecretKeyRef: &corev1.SecretKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{
+												Name: "secret",
+											},
+											Key: "key1",
+										},
+									},
+								},
+							},
+						},
+					},
 				},
-				&models.Secret{
-					Name: "secret4",
-					Data: secretDataJson,
+			}
+			podData2 := v1alpha1.Pod{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  "test",
+							Image: "test",
+							Env: []corev1.EnvVar{
+								{
+									Name: "test",
+									ValueFrom: &corev1.EnvVarSource{
+										SecretKeyRef: &corev1.SecretKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{
+												Name: "secret",
+											},
+											Key: "key",
+										},
+									},
+								},
+								{
+									Name: "test",
+									ValueFrom: &corev1.EnvVarSource{
+										SecretKeyRef: &corev1.SecretKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{
+												Name: "secret",
+											},
+											Key:      "key",
+											Optional: &boolTrue,
+										},
+									},
+								},
+							},
+						},
+					},
 				},
-				&models.Secret{
-					Name: "secret5",
-					Data: "{}",
+			}
+			podData3 := v1alpha1.Pod{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  "test",
+							Image: "test",
+							EnvFrom: []corev1.EnvFromSource{
+								{
+									SecretRef: &corev1.SecretEnvSource{
+										LocalObjectReference: corev1.LocalObjectReference{
+											Name: "secret",
+										},
+									},
+								},
+							},
+							Env: []corev1.EnvVar{
+								{
+									Name: "test",
+									ValueFrom: &corev1.EnvVarSource{
+										SecretKeyRef: &corev1.SecretKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{
+												Name: "secret",
+											},
+											Key:      "key",
+											Optional: &boolTrue,
+										},
+									},
+								},
+								{
+									Name: "test",
+									ValueFrom: &corev1.EnvVarSource{
+										SecretKeyRef: &corev1.SecretKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{
+												Name: "secret",
+											},
+											Key: "key",
+										},
+									},
+								},
+							},
+						},
+					},
 				},
 			}
-			Expect(config.Secrets).To(HaveLen(len(expectedList)))
-			Expect(config.Secrets).To(ContainElements(expectedList...))
-		})
+			table.DescribeTable("Test table", func(podData *v1alpha1.Pod) {
+				// given
+				deviceName := "foo"
+				device := getDevice(deviceName)
+				device.Status.Deployments = []v1alpha1.Deployment{{Name: "workload1"}}
 
-		It("should map metrics retention configuration", func() {
-			// given
-			maxMiB := int32(123)
-			maxHours := int32(24)
+				edgeDeviceRepoMock.EXPECT().
+					Read(gomock.Any(), deviceName, testNamespace).
+					Return(device, nil).
+					Times(1)
 
-			device := getDevice("foo")
-			device.Spec.Metrics = &v1alpha1.MetricsConfiguration{
-				Retention: &v1alpha1.Retention{
-					MaxMiB:   maxMiB,
-					MaxHours: maxHours,
-				},
+				deploymentData := &v1alpha1.EdgeDeployment{
+					ObjectMeta: v1.ObjectMeta{
+						Name:      "workload1",
+						Namespace: "default",
+					},
+					Spec: v1alpha1.EdgeDeploymentSpec{
+						DeviceSelector: &v1.LabelSelector{
+							MatchLabels: map[string]string{"test": "test"},
+						},
+						Type: "pod",
+						Pod:  *podData,
+						Data: &v1alpha1.DataConfiguration{},
+					}}
+				configMap.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.ConfigmapList{}, nil)
+				deployRepoMock.EXPECT().
+					Read(gomock.Any(), "workload1", testNamespace).
+					Return(deploymentData, nil)
+				secretDataMap := map[string][]byte{"key1": []byte("username"), "key2": []byte("password")}
+				Mockk8sClient.EXPECT().
+					Get(gomock.Any(), types.NamespacedName{Namespace: device.Namespace, Name: "secret"}, gomock.Any()).
+					Do(func(ctx context.Context, key client.ObjectKey, obj client.Object) {
+						obj.(*corev1.Secret).Data = secretDataMap
+					}).
+					Return(nil).Times(1)
+
+				// when
+				res := handler.GetDataMessageForDevice(context.TODO(), params)
+
+				// then
+				Expect(res).To(Equal(operations.NewGetDataMessageForDeviceInternalServerError()))
+			},
+				table.Entry("missing secret key", &podData1),
+				table.Entry("partially optional secret key - mandatory appears first", &podData2),
+				table.Entry("partially optional secret key - optional appears first", &podData3),
+			)
+		})
+
+		It("Secrets reading succeeded", func() {
+			// This test covers:
+			// multiple deployment
+			// init containers and regular containers
+			// secretRef and secretKeyRef
+			// optional secretRef
+			// optional secretKeyRef missing secret
+			// optional secretKeyRef missing key
+			// duplicate secret references
+
+			// given
+			deviceName := "foo"
+			device := getDevice(deviceName)
+			device.Status.Deployments = []v1alpha1.Deployment{{Name: "workload1"}, {Name: "workload2"}}
+
+			edgeDeviceRepoMock.EXPECT().
+				Read(gomock.Any(), deviceName, testNamespace).
+				Return(device, nil).
+				Times(1)
+
+			podData1 := v1alpha1.Pod{
+				Spec: corev1.PodSpec{
+					InitContainers: []corev1.Container{
+						{
+							Name:  "ic1",
+							Image: "test",
+							EnvFrom: []corev1.EnvFromSource{
+								{
+									SecretRef: &corev1.SecretEnvSource{
+										LocalObjectReference: corev1.LocalObjectReference{
+											Name: "secret1",
+										},
+									},
+								},
+							},
+							Env: []corev1.EnvVar{
+								{
+									Name: "test",
+									ValueFrom: &corev1.EnvVarSource{
+										SecretKeyRef: &corev1.SecretKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{
+												Name: "secret1",
+											},
+											Key:      "notexist",
+											Optional: &boolTrue,
+										},
+									},
+								},
+							},
+						},
+					},
+					Containers: []corev1.Container{
+						{
+							Name:  "c1",
+							Image: "test",
+							EnvFrom: []corev1.EnvFromSource{
+								{
+									SecretRef: &corev1.SecretEnvSource{
+										LocalObjectReference: corev1.LocalObjectReference{
+											Name: "secret2",
+										},
+									},
+								},
+							},
+							Env: []corev1.EnvVar{
+								{
+									Name: "test",
+									ValueFrom: &corev1.EnvVarSource{
+										SecretKeyRef: &corev1.SecretKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{
+												Name: "secret3",
+											},
+											Key: "key1",
+										},
+									},
+								},
+							},
+						},
+						{
+							Name:  "c2",
+							Image: "test",
+							Env: []corev1.EnvVar{
+								{
+									Name: "test",
+									ValueFrom: &corev1.EnvVarSource{
+										SecretKeyRef: &corev1.SecretKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{
+												Name: "secret4",
+											},
+											Key: "key1",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			}
+			podData2 := v1alpha1.Pod{
+				Spec: corev1.PodSpec{
+					InitContainers: []corev1.Container{
+						{
+							Name:  "ic1",
+							Image: "test",
+							Env: []corev1.EnvVar{
+								{
+									Name: "test",
+									ValueFrom: &corev1.EnvVarSource{
+										SecretKeyRef: &corev1.SecretKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{
+												Name: "optional1",
+											},
+											Key:      "key1",
+											Optional: &boolTrue,
+										},
+									},
+								},
+							},
+						},
+					},
+					Containers: []corev1.Container{
+						{
+							Name:  "c1",
+							Image: "test",
+							EnvFrom: []corev1.EnvFromSource{
+								{
+									SecretRef: &corev1.SecretEnvSource{
+										LocalObjectReference: corev1.LocalObjectReference{
+											Name: "optional2",
+										},
+										Optional: &boolTrue,
+									},
+								},
+							},
+						},
+						{
+							Name:  "c2",
+							Image: "test",
+							EnvFrom: []corev1.EnvFromSource{
+								{
+									SecretRef: &corev1.SecretEnvSource{
+										LocalObjectReference: corev1.LocalObjectReference{
+											Name: "secret1",
+										},
+									},
+								},
+								{
+									SecretRef: &corev1.SecretEnvSource{
+										LocalObjectReference: corev1.LocalObjectReference{
+											Name: "secret5",
+										},
+									},
+								},
+							},
+							Env: []corev1.EnvVar{
+								{
+									Name: "test",
+									ValueFrom: &corev1.EnvVarSource{
+										SecretKeyRef: &corev1.SecretKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{
+												Name: "optional1",
+											},
+											Key:      "key1",
+											Optional: &boolTrue,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			}
+
+			deploymentData1 := &v1alpha1.EdgeDeployment{
+				ObjectMeta: v1.ObjectMeta{
+					Name:      "workload1",
+					Namespace: "default",
+				},
+				Spec: v1alpha1.EdgeDeploymentSpec{
+					DeviceSelector: &v1.LabelSelector{
+						MatchLabels: map[string]string{"test": "test"},
+					},
+					Type: "pod",
+					Pod:  podData1,
+					Data: &v1alpha1.DataConfiguration{},
+				}}
+			deploymentData2 := &v1alpha1.EdgeDeployment{
+				ObjectMeta: v1.ObjectMeta{
+					Name:      "workload2",
+					Namespace: "default",
+				},
+				Spec: v1alpha1.EdgeDeploymentSpec{
+					DeviceSelector: &v1.LabelSelector{
+						MatchLabels: map[string]string{"test": "test"},
+					},
+					Type: "pod",
+					Pod:  podData2,
+					Data: &v1alpha1.DataConfiguration{},
+				}}
+
+			configMap.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.ConfigmapList{}, nil).AnyTimes()
+			deployRepoMock.EXPECT().
+				Read(gomock.Any(), "workload1", testNamespace).
+				Return(deploymentData1, nil)
+			deployRepoMock.EXPECT().
+				Read(gomock.Any(), "workload2", testNamespace).
+				Return(deploymentData2, nil)
+
+			secretName := types.NamespacedName{
+				Namespace: device.Namespace,
 			}
+			secretDataMap := map[string][]byte{"key1": []byte("username"), "key2": []byte("password")}
+			secretDataJson := `{"key1":"dXNlcm5hbWU=","key2":"cGFzc3dvcmQ="}`
+			secretName.Name = "secret1"
+			Mockk8sClient.EXPECT().
+				Get(gomock.Any(), secretName, gomock.Any()).
+				Return(nil).Times(1)
+			secretName.Name = "secret2"
+			Mockk8sClient.EXPECT().
+				Get(gomock.Any(), secretName, gomock.Any()).
+				Return(nil).Times(1)
+			secretName.Name = "secret3"
+			Mockk8sClient.EXPECT().
+				Get(gomock.Any(), secretName, gomock.Any()).
+				Do(func(ctx context.Context, key client.ObjectKey, obj client.Object) {
+					obj.(*corev1.Secret).Data = secretDataMap
+				}).
+				Return(nil).Times(1)
+			secretName.Name = "secret4"
+			Mockk8sClient.EXPECT().
+				Get(gomock.Any(), secretName, gomock.Any()).
+				Do(func(ctx context.Context, key client.ObjectKey, obj client.Object) {
+					obj.(*corev1.Secret).Data = secretDataMap
+				}).
+				Return(nil).Times(1)
+			secretName.Name = "secret5"
+			Mockk8sClient.EXPECT().
+				Get(gomock.Any(), secretName, gomock.Any()).
+				Return(nil).Times(1)
+			secretName.Name = "optional1"
+			Mockk8sClient.EXPECT().
+				Get(gomock.Any(), secretName, gomock.Any()).
+				Return(errorNotFound).Times(1)
+			secretName.Name = "optional2"
+			Mockk8sClient.EXPECT().
+				Get(gomock.Any(), secretName, gomock.Any()).
+				Return(errorNotFound).Times(1)
+
+			// when
+			res := handler.GetDataMessageForDevice(context.TODO(), params)
+
+			// then
+			Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceOK{}))
+			config := validateAndGetDeviceConfig(res)
+			expectedList := []interface{}{
+				&models.Secret{
+					Name: "secret1",
+					Data: "{}",
+				},
+				&models.Secret{
+					Name: "secret2",
+					Data: "{}",
+				},
+				&models.Secret{
+					Name: "secret3",
+					Data: secretDataJson,
+				},
+				&models.Secret{
+					Name: "secret4",
+					Data: secretDataJson,
+				},
+				&models.Secret{
+					Name: "secret5",
+					Data: "{}",
+				},
+			}
+			Expect(config.Secrets).To(HaveLen(len(expectedList)))
+			Expect(config.Secrets).To(ContainElements(expectedList...))
+		})
+
+		It("should map metrics retention configuration", func() {
+			// given
+			maxMiB := int32(123)
+			maxHours := int32(24)
+
+			device := getDevice("foo")
+			device.Spec.Metrics = &v1alpha1.MetricsConfiguration{
+				Retention: &v1alpha1.Retention{
+					MaxMiB:   maxMiB,
+					MaxHours: maxHours,
+				},
+			}
+
+			edgeDeviceRepoMock.EXPECT().
+				Read(gomock.Any(), "foo", testNamespace).
+				Return(device, nil).
+				Times(1)
+
+			// when
+			res := handler.GetDataMessageForDevice(context.TODO(), params)
+
+			// then
+			Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceOK{}))
+			config := validateAndGetDeviceConfig(res)
+
+			Expect(config.Configuration.Metrics).ToNot(BeNil())
+			Expect(config.Configuration.Metrics.Retention).ToNot(BeNil())
+			Expect(config.Configuration.Metrics.Retention.MaxMib).To(Equal(maxMiB))
+			Expect(config.Configuration.Metrics.Retention.MaxHours).To(Equal(maxHours))
+		})
+
+		It("should map system metrics", func() {
+			// given
+			const allowListName = "a-name"
+			interval := int32(3600)
+
+			device := getDevice("foo")
+			device.Spec.Metrics = &v1alpha1.MetricsConfiguration{
+				SystemMetrics: &v1alpha1.SystemMetricsConfiguration{
+					Interval: interval,
+					Disabled: true,
+					AllowList: &v1alpha1.NameRef{
+						Name: allowListName,
+					},
+				},
+			}
+
+			edgeDeviceRepoMock.EXPECT().
+				Read(gomock.Any(), "foo", testNamespace).
+				Return(device, nil).
+				Times(1)
+
+			allowList := models.MetricsAllowList{Names: []string{"fizz", "buzz"}}
+			allowListsMock.EXPECT().GenerateFromConfigMap(gomock.Any(), allowListName, device.Namespace).
+				Return(&allowList, nil)
+
+			// when
+			res := handler.GetDataMessageForDevice(context.TODO(), params)
+
+			// then
+			Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceOK{}))
+			config := validateAndGetDeviceConfig(res)
+
+			Expect(config.Configuration.Metrics).ToNot(BeNil())
+			Expect(config.Configuration.Metrics.System).ToNot(BeNil())
+
+			Expect(config.Configuration.Metrics.System.Interval).To(Equal(interval))
+
+			Expect(config.Configuration.Metrics.System.Disabled).To(BeTrue())
+
+			Expect(config.Configuration.Metrics.System.AllowList).ToNot(BeNil())
+			Expect(*config.Configuration.Metrics.System.AllowList).To(Equal(allowList))
+		})
+
+		It("should merge the MetricsAllowList with the config map allow-list and apply the overrides", func() {
+			// given
+			device := getDevice("foo")
+			device.Spec.Metrics = &v1alpha1.MetricsConfiguration{
+				SystemMetrics: &v1alpha1.SystemMetricsConfiguration{
+					AllowList:        &v1alpha1.NameRef{Name: "a-config-map"},
+					MetricsAllowList: &v1alpha1.NameRef{Name: "an-allow-list"},
+					AllowListOverrides: &v1alpha1.MetricsAllowListOverrides{
+						Add:    []string{"uptime"},
+						Remove: []string{"fizz"},
+					},
+				},
+			}
+
+			edgeDeviceRepoMock.EXPECT().
+				Read(gomock.Any(), "foo", testNamespace).
+				Return(device, nil).
+				Times(1)
+
+			allowListsMock.EXPECT().GenerateFromConfigMap(gomock.Any(), "a-config-map", device.Namespace).
+				Return(&models.MetricsAllowList{Names: []string{"fizz", "buzz"}}, nil)
+			allowListsMock.EXPECT().GenerateFromAllowList(gomock.Any(), "an-allow-list", device.Namespace).
+				Return(&models.MetricsAllowList{Names: []string{"buzz", "cpu_cores"}, Patterns: []string{"^(?:disk_.*)$"}}, nil)
+
+			// when
+			res := handler.GetDataMessageForDevice(context.TODO(), params)
+
+			// then
+			Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceOK{}))
+			config := validateAndGetDeviceConfig(res)
+
+			Expect(config.Configuration.Metrics.System.AllowList).To(Equal(&models.MetricsAllowList{
+				Names:    []string{"buzz", "cpu_cores", "uptime"},
+				Patterns: []string{"^(?:disk_.*)$"},
+			}))
+		})
+
+		It("should fail when MetricsAllowList resolution fails", func() {
+			// given
+			device := getDevice("foo")
+			device.Spec.Metrics = &v1alpha1.MetricsConfiguration{
+				SystemMetrics: &v1alpha1.SystemMetricsConfiguration{
+					MetricsAllowList: &v1alpha1.NameRef{Name: "an-allow-list"},
+				},
+			}
+
+			allowListsMock.EXPECT().GenerateFromAllowList(gomock.Any(), "an-allow-list", device.Namespace).
+				Return(nil, fmt.Errorf("boom!"))
+
+			edgeDeviceRepoMock.EXPECT().
+				Read(gomock.Any(), "foo", testNamespace).
+				Return(device, nil).
+				Times(1)
+
+			// when
+			res := handler.GetDataMessageForDevice(context.TODO(), params)
+
+			// then
+			Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceInternalServerError{}))
+		})
+
+		It("should fail when allow-list generation fails", func() {
+			// given
+			const allowListName = "a-name"
+
+			device := getDevice("foo")
+			device.Spec.Metrics = &v1alpha1.MetricsConfiguration{
+				SystemMetrics: &v1alpha1.SystemMetricsConfiguration{
+					AllowList: &v1alpha1.NameRef{
+						Name: allowListName,
+					},
+				},
+			}
+
+			allowListsMock.EXPECT().GenerateFromConfigMap(gomock.Any(), allowListName, device.Namespace).
+				Return(nil, fmt.Errorf("boom!"))
+
+			edgeDeviceRepoMock.EXPECT().
+				Read(gomock.Any(), "foo", testNamespace).
+				Return(device, nil).
+				Times(1)
+
+			// when
+			res := handler.GetDataMessageForDevice(context.TODO(), params)
+
+			// then
+			Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceInternalServerError{}))
+		})
+	})
+
+	Context("PostDataMessageForDevice", func() {
+
+		var (
+			deviceName string
+			device     *v1alpha1.EdgeDevice
+		)
+
+		BeforeEach(func() {
+			deviceName = "foo"
+			device = getDevice(deviceName)
+		})
+
+		It("Invalid params", func() {
+			// given
+			params := api.PostDataMessageForDeviceParams{
+				DeviceID: deviceName,
+				Message: &models.Message{
+					Directive: "NOT VALID ONE",
+				},
+			}
+
+			// when
+			res := handler.PostDataMessageForDevice(context.TODO(), params)
+
+			// then
+			Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceBadRequest{}))
+		})
+
+		Context("Metrics", func() {
+			var (
+				ingesterMock *devicemetrics.MockIngester
+				params       api.PostDataMessageForDeviceParams
+			)
+
+			BeforeEach(func() {
+				ingesterMock = devicemetrics.NewMockIngester(mockCtrl)
+				handler = yggdrasil.NewYggdrasilHandler(edgeDeviceRepoMock, deployRepoMock, deviceSetRepoMock, nil, Mockk8sClient, testNamespace,
+					eventsRecorder, registryAuth, metricsMock, allowListsMock, configMap, nil, ingesterMock, nil, nil, nil, nil)
+				params = api.PostDataMessageForDeviceParams{
+					DeviceID: deviceName,
+					Message: &models.Message{
+						Directive: "metrics",
+						Content:   models.MetricsMessage{Format: models.MetricsMessageFormatPrometheusText, Data: "free_mem 1\n", Workload: "camera"},
+					},
+				}
+			})
+
+			It("Metrics ingested", func() {
+				// given
+				edgeDeviceRepoMock.EXPECT().
+					Read(gomock.Any(), deviceName, testNamespace).
+					Return(device, nil).
+					Times(1)
+				ingesterMock.EXPECT().
+					Ingest(deviceName, testNamespace, &models.MetricsMessage{
+						Format: models.MetricsMessageFormatPrometheusText, Data: "free_mem 1\n", Workload: "camera",
+					}).
+					Return(nil).
+					Times(1)
+
+				// when
+				res := handler.PostDataMessageForDevice(context.TODO(), params)
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
+			})
+
+			It("Device not found", func() {
+				// given
+				edgeDeviceRepoMock.EXPECT().
+					Read(gomock.Any(), deviceName, testNamespace).
+					Return(nil, errorNotFound).
+					Times(1)
+
+				// when
+				res := handler.PostDataMessageForDevice(context.TODO(), params)
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceNotFound{}))
+			})
+
+			It("Rate limited", func() {
+				// given
+				edgeDeviceRepoMock.EXPECT().
+					Read(gomock.Any(), deviceName, testNamespace).
+					Return(device, nil).
+					Times(1)
+				ingesterMock.EXPECT().
+					Ingest(gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(devicemetrics.ErrRateLimited).
+					Times(1)
+
+				// when
+				res := handler.PostDataMessageForDevice(context.TODO(), params)
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceTooManyRequests{}))
+			})
+
+			It("Invalid batch", func() {
+				// given
+				edgeDeviceRepoMock.EXPECT().
+					Read(gomock.Any(), deviceName, testNamespace).
+					Return(device, nil).
+					Times(1)
+				ingesterMock.EXPECT().
+					Ingest(gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(fmt.Errorf("%w: cannot parse", devicemetrics.ErrInvalidBatch)).
+					Times(1)
+
+				// when
+				res := handler.PostDataMessageForDevice(context.TODO(), params)
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceBadRequest{}))
+			})
+
+			It("Ingestion disabled", func() {
+				// given
+				handler = yggdrasil.NewYggdrasilHandler(edgeDeviceRepoMock, deployRepoMock, deviceSetRepoMock, nil, Mockk8sClient, testNamespace,
+					eventsRecorder, registryAuth, metricsMock, allowListsMock, configMap, nil, nil, nil, nil, nil, nil)
+
+				// when
+				res := handler.PostDataMessageForDevice(context.TODO(), params)
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceBadRequest{}))
+			})
+		})
+
+		Context("Logs", func() {
+			var (
+				forwarderMock *devicelogs.MockForwarder
+				params        api.PostDataMessageForDeviceParams
+				logsMessage   = &models.LogsMessage{
+					Workload: "camera",
+					Entries:  []*models.LogEntry{{Message: "started"}},
+				}
+			)
+
+			BeforeEach(func() {
+				forwarderMock = devicelogs.NewMockForwarder(mockCtrl)
+				handler = yggdrasil.NewYggdrasilHandler(edgeDeviceRepoMock, deployRepoMock, deviceSetRepoMock, nil, Mockk8sClient, testNamespace,
+					eventsRecorder, registryAuth, metricsMock, allowListsMock, configMap, nil, nil, forwarderMock, nil, nil, nil)
+				params = api.PostDataMessageForDeviceParams{
+					DeviceID: deviceName,
+					Message: &models.Message{
+						Directive: "logs",
+						Content:   logsMessage,
+					},
+				}
+			})
+
+			It("Logs forwarded", func() {
+				// given
+				edgeDeviceRepoMock.EXPECT().
+					Read(gomock.Any(), deviceName, testNamespace).
+					Return(device, nil).
+					Times(1)
+				forwarderMock.EXPECT().
+					Forward(gomock.Any(), deviceName, testNamespace, logsMessage).
+					Return(nil).
+					Times(1)
+
+				// when
+				res := handler.PostDataMessageForDevice(context.TODO(), params)
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
+			})
+
+			It("Device not found", func() {
+				// given
+				edgeDeviceRepoMock.EXPECT().
+					Read(gomock.Any(), deviceName, testNamespace).
+					Return(nil, errorNotFound).
+					Times(1)
+
+				// when
+				res := handler.PostDataMessageForDevice(context.TODO(), params)
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceNotFound{}))
+			})
+
+			It("Invalid message", func() {
+				// given
+				edgeDeviceRepoMock.EXPECT().
+					Read(gomock.Any(), deviceName, testNamespace).
+					Return(device, nil).
+					Times(1)
+				forwarderMock.EXPECT().
+					Forward(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(fmt.Errorf("%w: invalid workload", devicelogs.ErrInvalidMessage)).
+					Times(1)
+
+				// when
+				res := handler.PostDataMessageForDevice(context.TODO(), params)
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceBadRequest{}))
+			})
+
+			It("Sink unavailable", func() {
+				// given
+				edgeDeviceRepoMock.EXPECT().
+					Read(gomock.Any(), deviceName, testNamespace).
+					Return(device, nil).
+					Times(1)
+				forwarderMock.EXPECT().
+					Forward(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(fmt.Errorf("connection refused")).
+					Times(1)
+
+				// when
+				res := handler.PostDataMessageForDevice(context.TODO(), params)
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceInternalServerError{}))
+			})
+
+			It("Forwarding disabled", func() {
+				// given
+				handler = yggdrasil.NewYggdrasilHandler(edgeDeviceRepoMock, deployRepoMock, deviceSetRepoMock, nil, Mockk8sClient, testNamespace,
+					eventsRecorder, registryAuth, metricsMock, allowListsMock, configMap, nil, nil, nil, nil, nil, nil)
+
+				// when
+				res := handler.PostDataMessageForDevice(context.TODO(), params)
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceBadRequest{}))
+			})
+		})
+
+		Context("Heartbeat", func() {
+			var directiveName = "heartbeat"
+
+			It("Device not found", func() {
+				// given
+				edgeDeviceRepoMock.EXPECT().
+					Read(gomock.Any(), deviceName, testNamespace).
+					Return(nil, errorNotFound).
+					Times(1)
+
+				params := api.PostDataMessageForDeviceParams{
+					DeviceID: deviceName,
+					Message: &models.Message{
+						Directive: directiveName,
+					},
+				}
+
+				// when
+				res := handler.PostDataMessageForDevice(context.TODO(), params)
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceNotFound{}))
+			})
+
+			It("Device cannot be retrieved", func() {
+				// given
+				edgeDeviceRepoMock.EXPECT().
+					Read(gomock.Any(), deviceName, testNamespace).
+					Return(nil, fmt.Errorf("failed")).
+					Times(4)
+
+				params := api.PostDataMessageForDeviceParams{
+					DeviceID: deviceName,
+					Message: &models.Message{
+						Directive: directiveName,
+					},
+				}
+
+				// when
+				res := handler.PostDataMessageForDevice(context.TODO(), params)
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceInternalServerError{}))
+			})
+
+			It("Work without content", func() {
+				// given
+				edgeDeviceRepoMock.EXPECT().
+					Read(gomock.Any(), deviceName, testNamespace).
+					Return(device, nil).
+					Times(1)
+
+				edgeDeviceRepoMock.EXPECT().
+					PatchStatus(gomock.Any(), device, gomock.Any()).
+					Return(nil).
+					Times(1)
+
+				edgeDeviceRepoMock.EXPECT().
+					UpdateLabels(gomock.Any(), device, gomock.Any()).
+					Return(nil).
+					Times(1)
+
+				params := api.PostDataMessageForDeviceParams{
+					DeviceID: deviceName,
+					Message: &models.Message{
+						Directive: directiveName,
+					},
+				}
+
+				// when
+				res := handler.PostDataMessageForDevice(context.TODO(), params)
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
+			})
+
+			It("Work with content", func() {
+				// given
+				content := models.Heartbeat{
+					Status:  "running",
+					Version: "1",
+					Workloads: []*models.WorkloadStatus{
+						{Name: "workload-1", Status: "running"}},
+					Hardware: &models.HardwareInfo{
+						Hostname: "test-hostname",
+					},
+				}
+
+				device.Status.Deployments = []v1alpha1.Deployment{{
+					Name:  "workload-1",
+					Phase: "failing",
+				}}
+
+				edgeDeviceRepoMock.EXPECT().
+					Read(gomock.Any(), deviceName, testNamespace).
+					Return(device, nil).
+					Times(1)
+
+				deployRepoMock.EXPECT().
+					Read(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(0)
+
+				edgeDeviceRepoMock.EXPECT().
+					UpdateLabels(gomock.Any(), device, gomock.Any()).
+					Return(nil).
+					Times(1)
+
+				edgeDeviceRepoMock.EXPECT().
+					PatchStatus(gomock.Any(), device, gomock.Any()).
+					Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, patch *client.Patch) {
+						Expect(edgeDevice.Status.Deployments).To(HaveLen(1))
+						Expect(edgeDevice.Status.Deployments[0].Phase).To(
+							Equal(v1alpha1.EdgeDeploymentPhase("running")))
+						Expect(edgeDevice.Status.Deployments[0].Name).To(Equal("workload-1"))
+					}).
+					Return(nil).
+					Times(1)
+
+				params := api.PostDataMessageForDeviceParams{
+					DeviceID: deviceName,
+					Message: &models.Message{
+						Directive: directiveName,
+						Content:   content,
+					},
+				}
+
+				// when
+				res := handler.PostDataMessageForDevice(context.TODO(), params)
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
+			})
+
+			It("Work with data upload errors", func() {
+				// given
+				content := models.Heartbeat{
+					Status:  "running",
+					Version: "1",
+					Workloads: []*models.WorkloadStatus{
+						{Name: "workload-1", Status: "running", LastDataUploadError: "bucket not found"}},
+				}
+
+				device.Status.Deployments = []v1alpha1.Deployment{{
+					Name:  "workload-1",
+					Phase: "running",
+				}}
+
+				deploymentData := &v1alpha1.EdgeDeployment{
+					ObjectMeta: v1.ObjectMeta{Name: "workload-1", Namespace: testNamespace},
+					Status: v1alpha1.EdgeDeploymentStatus{
+						DataUploadErrors: []v1alpha1.DataUploadError{
+							{Device: "other-device", Message: "timeout"},
+							{Device: deviceName, Message: "old error"},
+						},
+					},
+				}
+
+				edgeDeviceRepoMock.EXPECT().
+					Read(gomock.Any(), deviceName, testNamespace).
+					Return(device, nil).
+					Times(1)
+
+				edgeDeviceRepoMock.EXPECT().
+					UpdateLabels(gomock.Any(), device, gomock.Any()).
+					Return(nil).
+					Times(1)
+
+				deployRepoMock.EXPECT().
+					Read(gomock.Any(), "workload-1", testNamespace).
+					Return(deploymentData, nil).
+					Times(1)
+
+				deployRepoMock.EXPECT().
+					PatchStatus(gomock.Any(), deploymentData, gomock.Any()).
+					Do(func(ctx context.Context, edgeDeployment *v1alpha1.EdgeDeployment, patch *client.Patch) {
+						var summary []string
+						for _, uploadError := range edgeDeployment.Status.DataUploadErrors {
+							summary = append(summary, uploadError.Device+": "+uploadError.Message)
+						}
+						Expect(summary).To(ConsistOf("other-device: timeout", deviceName+": bucket not found"))
+					}).
+					Return(nil).
+					Times(1)
+
+				edgeDeviceRepoMock.EXPECT().
+					PatchStatus(gomock.Any(), device, gomock.Any()).
+					Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, patch *client.Patch) {
+						Expect(edgeDevice.Status.Deployments).To(HaveLen(1))
+						Expect(edgeDevice.Status.Deployments[0].LastDataUploadError).To(Equal("bucket not found"))
+					}).
+					Return(nil).
+					Times(1)
+
+				params := api.PostDataMessageForDeviceParams{
+					DeviceID: deviceName,
+					Message: &models.Message{
+						Directive: directiveName,
+						Content:   content,
+					},
+				}
+
+				// when
+				res := handler.PostDataMessageForDevice(context.TODO(), params)
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
+			})
+
+			It("Work with data upload errors conflicting with other devices", func() {
+				// given
+				content := models.Heartbeat{
+					Status:  "running",
+					Version: "1",
+					Workloads: []*models.WorkloadStatus{
+						{Name: "workload-1", Status: "running", LastDataUploadError: "bucket not found"}},
+				}
+
+				device.Status.Deployments = []v1alpha1.Deployment{{
+					Name:  "workload-1",
+					Phase: "running",
+				}}
+
+				deploymentData := &v1alpha1.EdgeDeployment{
+					ObjectMeta: v1.ObjectMeta{Name: "workload-1", Namespace: testNamespace},
+				}
+				conflict := errors.NewConflict(schema.GroupResource{Resource: "edgedeployments"}, "workload-1", fmt.Errorf("modified"))
+
+				edgeDeviceRepoMock.EXPECT().
+					Read(gomock.Any(), deviceName, testNamespace).
+					Return(device, nil).
+					Times(1)
+
+				edgeDeviceRepoMock.EXPECT().
+					UpdateLabels(gomock.Any(), device, gomock.Any()).
+					Return(nil).
+					Times(1)
+
+				edgeDeviceRepoMock.EXPECT().
+					PatchStatus(gomock.Any(), device, gomock.Any()).
+					Return(nil).
+					Times(1)
+
+				deployRepoMock.EXPECT().
+					Read(gomock.Any(), "workload-1", testNamespace).
+					DoAndReturn(func(ctx context.Context, name, namespace string) (*v1alpha1.EdgeDeployment, error) {
+						return deploymentData.DeepCopy(), nil
+					}).
+					Times(2)
+
+				gomock.InOrder(
+					deployRepoMock.EXPECT().
+						PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).
+						Return(conflict),
+					deployRepoMock.EXPECT().
+						PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).
+						Do(func(ctx context.Context, edgeDeployment *v1alpha1.EdgeDeployment, patch *client.Patch) {
+							Expect(edgeDeployment.Status.DataUploadErrors).To(HaveLen(1))
+							Expect(edgeDeployment.Status.DataUploadErrors[0].Message).To(Equal("bucket not found"))
+						}).
+						Return(nil),
+				)
+
+				params := api.PostDataMessageForDeviceParams{
+					DeviceID: deviceName,
+					Message: &models.Message{
+						Directive: directiveName,
+						Content:   content,
+					},
+				}
+
+				// when
+				res := handler.PostDataMessageForDevice(context.TODO(), params)
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
+			})
+
+			It("Work with data upload errors that cannot be recorded", func() {
+				// given
+				content := models.Heartbeat{
+					Status:  "running",
+					Version: "1",
+					Workloads: []*models.WorkloadStatus{
+						{Name: "workload-1", Status: "running", LastDataUploadError: "bucket not found"}},
+				}
+
+				device.Status.Deployments = []v1alpha1.Deployment{{
+					Name:  "workload-1",
+					Phase: "running",
+				}}
+
+				edgeDeviceRepoMock.EXPECT().
+					Read(gomock.Any(), deviceName, testNamespace).
+					Return(device, nil).
+					Times(1)
+
+				edgeDeviceRepoMock.EXPECT().
+					UpdateLabels(gomock.Any(), device, gomock.Any()).
+					Return(nil).
+					Times(1)
+
+				deployRepoMock.EXPECT().
+					Read(gomock.Any(), "workload-1", testNamespace).
+					Return(nil, fmt.Errorf("unavailable")).
+					Times(1)
+
+				edgeDeviceRepoMock.EXPECT().
+					PatchStatus(gomock.Any(), device, gomock.Any()).
+					Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, patch *client.Patch) {
+						// the error is recorded on the next heartbeat
+						Expect(edgeDevice.Status.Deployments).To(HaveLen(1))
+						Expect(edgeDevice.Status.Deployments[0].Phase).To(BeEquivalentTo("running"))
+						Expect(edgeDevice.Status.Deployments[0].LastDataUploadError).To(BeEmpty())
+					}).
+					Return(nil).
+					Times(1)
+
+				params := api.PostDataMessageForDeviceParams{
+					DeviceID: deviceName,
+					Message: &models.Message{
+						Directive: directiveName,
+						Content:   content,
+					},
+				}
+
+				// when
+				res := handler.PostDataMessageForDevice(context.TODO(), params)
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
+			})
+
+			It("Work with workload dependencies", func() {
+				// given
+				content := models.Heartbeat{
+					Status:  "running",
+					Version: "1",
+					Workloads: []*models.WorkloadStatus{
+						{Name: "database", Status: "deploying"},
+						{Name: "backend", Status: "running"},
+						{Name: "cache", Status: "running"},
+					},
+				}
+
+				device.Status.Deployments = []v1alpha1.Deployment{
+					{Name: "database", Phase: "running"},
+					{Name: "backend", Phase: "running", DependsOn: []string{"database"}},
+					{Name: "frontend", Phase: v1alpha1.Blocked, DependsOn: []string{"backend", "cache"}},
+					{Name: "cache", Phase: "running"},
+					{Name: "monitoring", Phase: v1alpha1.Blocked, DependsOn: []string{"cache"}},
+				}
+
+				edgeDeviceRepoMock.EXPECT().
+					Read(gomock.Any(), deviceName, testNamespace).
+					Return(device, nil).
+					Times(1)
+
+				deployRepoMock.EXPECT().
+					Read(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(0)
+
+				edgeDeviceRepoMock.EXPECT().
+					UpdateLabels(gomock.Any(), device, gomock.Any()).
+					Return(nil).
+					Times(1)
+
+				edgeDeviceRepoMock.EXPECT().
+					PatchStatus(gomock.Any(), device, gomock.Any()).
+					Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, patch *client.Patch) {
+						phases := map[string]v1alpha1.EdgeDeploymentPhase{}
+						for _, deployment := range edgeDevice.Status.Deployments {
+							phases[deployment.Name] = deployment.Phase
+						}
+						Expect(phases).To(Equal(map[string]v1alpha1.EdgeDeploymentPhase{
+							"database":   "deploying",
+							"backend":    v1alpha1.Blocked,
+							"frontend":   v1alpha1.Blocked,
+							"cache":      "running",
+							"monitoring": v1alpha1.Deploying,
+						}))
+						Expect(edgeDevice.Status.Deployments[1].Name).To(Equal("cache"))
+						Expect(edgeDevice.Status.Deployments[4].DependsOn).To(Equal([]string{"cache"}))
+					}).
+					Return(nil).
+					Times(1)
+
+				params := api.PostDataMessageForDeviceParams{
+					DeviceID: deviceName,
+					Message: &models.Message{
+						Directive: directiveName,
+						Content:   content,
+					},
+				}
+
+				// when
+				res := handler.PostDataMessageForDevice(context.TODO(), params)
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
+			})
+
+			It("Work with content and events", func() {
+				// given
+				content := models.Heartbeat{
+					Status:  "running",
+					Version: "1",
+					Workloads: []*models.WorkloadStatus{
+						{Name: "workload-1", Status: "created"}},
+					Hardware: &models.HardwareInfo{
+						Hostname: "test-hostname",
+					},
+					Events: []*models.EventInfo{{
+						Message: "failed to start container",
+						Reason:  "Started",
+						Type:    models.EventInfoTypeWarn,
+					}},
+				}
+
+				device.Status.Deployments = []v1alpha1.Deployment{{
+					Name:  "workload-1",
+					Phase: "failing",
+				}}
+
+				edgeDeviceRepoMock.EXPECT().
+					Read(gomock.Any(), deviceName, testNamespace).
+					Return(device, nil).
+					Times(1)
+
+				edgeDeviceRepoMock.EXPECT().
+					UpdateLabels(gomock.Any(), device, gomock.Any()).
+					Return(nil).
+					Times(1)
+
+				edgeDeviceRepoMock.EXPECT().
+					PatchStatus(gomock.Any(), device, gomock.Any()).
+					Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, patch *client.Patch) {
+						Expect(edgeDevice.Status.Deployments).To(HaveLen(1))
+						Expect(edgeDevice.Status.Deployments[0].Phase).To(
+							Equal(v1alpha1.EdgeDeploymentPhase("created")))
+						Expect(edgeDevice.Status.Deployments[0].Name).To(Equal("workload-1"))
+					}).
+					Return(nil).
+					Times(1)
+
+				params := api.PostDataMessageForDeviceParams{
+					DeviceID: deviceName,
+					Message: &models.Message{
+						Directive: directiveName,
+						Content:   content,
+					},
+				}
+
+				// when
+				res := handler.PostDataMessageForDevice(context.TODO(), params)
+
+				// test emmiting the events:
+				close(eventsRecorder.Events)
+				found := false
+				for event := range eventsRecorder.Events {
+					if strings.Contains(event, "failed to start container") {
+						found = true
+					}
+				}
+				Expect(found).To(BeTrue())
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
+			})
+
+			It("Work with hardware changes", func() {
+				// given
+				eventsRecorder = record.NewFakeRecorder(10)
+				handler = yggdrasil.NewYggdrasilHandler(edgeDeviceRepoMock, deployRepoMock, deviceSetRepoMock, nil, Mockk8sClient, testNamespace,
+					eventsRecorder, registryAuth, metricsMock, allowListsMock, configMap, nil, nil, nil, nil, nil, nil)
+
+				content := models.Heartbeat{
+					Status:  "running",
+					Version: "1",
+					Hardware: &models.HardwareInfo{
+						Hostname: "test-hostname",
+						SystemVendor: &models.SystemVendor{
+							SerialNumber: "new-serial",
+						},
+						Disks: []*models.Disk{{ID: "disk-1", Name: "sda", SizeBytes: 100}},
+					},
+				}
+
+				device.Status.Hardware = &v1alpha1.Hardware{
+					Hostname: "test-hostname",
+					SystemVendor: &v1alpha1.SystemVendor{
+						SerialNumber: "old-serial",
+					},
+					Disks: []*v1alpha1.Disk{{ID: "disk-2", Name: "sdb", SizeBytes: 100}},
+				}
+
+				edgeDeviceRepoMock.EXPECT().
+					Read(gomock.Any(), deviceName, testNamespace).
+					Return(device, nil).
+					Times(1)
+
+				edgeDeviceRepoMock.EXPECT().
+					UpdateLabels(gomock.Any(), device, gomock.Any()).
+					Return(nil).
+					Times(1)
+
+				edgeDeviceRepoMock.EXPECT().
+					PatchStatus(gomock.Any(), device, gomock.Any()).
+					Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, patch *client.Patch) {
+						Expect(edgeDevice.Status.Hardware.SystemVendor.SerialNumber).To(Equal("new-serial"))
+						Expect(edgeDevice.Status.HardwareChanges).To(HaveLen(3))
+						Expect(edgeDevice.Status.HardwareTampering).NotTo(BeNil())
+						Expect(edgeDevice.Status.HardwareTampering.Reason).To(ContainSubstring("new-serial"))
+					}).
+					Return(nil).
+					Times(1)
+
+				params := api.PostDataMessageForDeviceParams{
+					DeviceID: deviceName,
+					Message: &models.Message{
+						Directive: directiveName,
+						Content:   content,
+					},
+				}
+
+				// when
+				res := handler.PostDataMessageForDevice(context.TODO(), params)
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
+				close(eventsRecorder.Events)
+				var events []string
+				for event := range eventsRecorder.Events {
+					events = append(events, event)
+				}
+				Expect(events).To(HaveLen(3))
+				Expect(events).To(ContainElement(ContainSubstring("HardwareTampering")))
+			})
+
+			It("Work with hardware changes of devices reporting a delta hardware profile", func() {
+				// given
+				eventsRecorder = record.NewFakeRecorder(10)
+				handler = yggdrasil.NewYggdrasilHandler(edgeDeviceRepoMock, deployRepoMock, deviceSetRepoMock, nil, Mockk8sClient, testNamespace,
+					eventsRecorder, registryAuth, metricsMock, allowListsMock, configMap, nil, nil, nil, nil, nil, nil)
+
+				content := models.Heartbeat{
+					Status:  "running",
+					Version: "1",
+					Hardware: &models.HardwareInfo{
+						Hostname:   "test-hostname",
+						Interfaces: []*models.Interface{{Name: "eth0", MacAddress: "00:00:00:00:00:01"}},
+					},
+				}
+
+				device.Spec.Heartbeat = &v1alpha1.HeartbeatConfiguration{
+					HardwareProfile: &v1alpha1.HardwareProfileConfiguration{Include: true, Scope: "delta"},
+				}
+				device.Status.Hardware = &v1alpha1.Hardware{
+					Hostname: "test-hostname",
+					SystemVendor: &v1alpha1.SystemVendor{
+						SerialNumber: "serial",
+					},
+					Disks: []*v1alpha1.Disk{{ID: "disk-1", Name: "sda", SizeBytes: 100}},
+				}
+
+				edgeDeviceRepoMock.EXPECT().
+					Read(gomock.Any(), deviceName, testNamespace).
+					Return(device, nil).
+					Times(1)
+
+				edgeDeviceRepoMock.EXPECT().
+					UpdateLabels(gomock.Any(), device, gomock.Any()).
+					Return(nil).
+					Times(1)
+
+				edgeDeviceRepoMock.EXPECT().
+					PatchStatus(gomock.Any(), device, gomock.Any()).
+					Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, patch *client.Patch) {
+						Expect(edgeDevice.Status.Hardware.SystemVendor.SerialNumber).To(Equal("serial"))
+						Expect(edgeDevice.Status.Hardware.Disks).To(HaveLen(1))
+						Expect(edgeDevice.Status.Hardware.Interfaces).To(HaveLen(1))
+						Expect(edgeDevice.Status.HardwareChanges).To(HaveLen(1))
+						Expect(edgeDevice.Status.HardwareChanges[0].Component).To(Equal("interface"))
+						Expect(edgeDevice.Status.HardwareTampering).To(BeNil())
+					}).
+					Return(nil).
+					Times(1)
+
+				params := api.PostDataMessageForDeviceParams{
+					DeviceID: deviceName,
+					Message: &models.Message{
+						Directive: directiveName,
+						Content:   content,
+					},
+				}
+
+				// when
+				res := handler.PostDataMessageForDevice(context.TODO(), params)
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
+			})
+
+			It("Work with reported properties", func() {
+				// given
+				device.Spec.Twin = &v1alpha1.DeviceTwin{Desired: map[string]string{"interval": "10s"}}
+				content := models.Heartbeat{
+					Status:                   "running",
+					Version:                  "1",
+					DesiredPropertiesVersion: twin.Version(device.Spec.Twin.Desired),
+					ReportedProperties: &models.TwinProperties{
+						Version:    "r1",
+						Properties: map[string]string{"interval": "10s", "firmware": "1.2"},
+					},
+				}
+
+				edgeDeviceRepoMock.EXPECT().
+					Read(gomock.Any(), deviceName, testNamespace).
+					Return(device, nil).
+					Times(1)
+
+				edgeDeviceRepoMock.EXPECT().
+					UpdateLabels(gomock.Any(), device, gomock.Any()).
+					Return(nil).
+					Times(1)
+
+				edgeDeviceRepoMock.EXPECT().
+					PatchStatus(gomock.Any(), device, gomock.Any()).
+					Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, patch *client.Patch) {
+						Expect(edgeDevice.Status.Twin).NotTo(BeNil())
+						Expect(edgeDevice.Status.Twin.ReportedVersion).To(Equal("r1"))
+						Expect(edgeDevice.Status.Twin.Reported).To(HaveKeyWithValue("firmware", "1.2"))
+						Expect(edgeDevice.Status.Twin.InSync).To(BeTrue())
+					}).
+					Return(nil).
+					Times(1)
+
+				params := api.PostDataMessageForDeviceParams{
+					DeviceID: deviceName,
+					Message: &models.Message{
+						Directive: directiveName,
+						Content:   content,
+					},
+				}
+
+				// when
+				res := handler.PostDataMessageForDevice(context.TODO(), params)
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
+			})
+
+			It("Work with invalid reported properties", func() {
+				// given
+				eventsRecorder = record.NewFakeRecorder(10)
+				handler = yggdrasil.NewYggdrasilHandler(edgeDeviceRepoMock, deployRepoMock, deviceSetRepoMock, nil, Mockk8sClient, testNamespace,
+					eventsRecorder, registryAuth, metricsMock, allowListsMock, configMap, nil, nil, nil, nil, nil, nil)
+
+				content := models.Heartbeat{
+					Status:  "running",
+					Version: "1",
+					ReportedProperties: &models.TwinProperties{
+						Properties: map[string]string{"firmware": strings.Repeat("v", twin.MaxPropertySize+1)},
+					},
+				}
+
+				edgeDeviceRepoMock.EXPECT().
+					Read(gomock.Any(), deviceName, testNamespace).
+					Return(device, nil).
+					Times(1)
+
+				edgeDeviceRepoMock.EXPECT().
+					UpdateLabels(gomock.Any(), device, gomock.Any()).
+					Return(nil).
+					Times(1)
+
+				edgeDeviceRepoMock.EXPECT().
+					PatchStatus(gomock.Any(), device, gomock.Any()).
+					Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, patch *client.Patch) {
+						Expect(edgeDevice.Status.Twin).To(BeNil())
+					}).
+					Return(nil).
+					Times(1)
+
+				params := api.PostDataMessageForDeviceParams{
+					DeviceID: deviceName,
+					Message: &models.Message{
+						Directive: directiveName,
+						Content:   content,
+					},
+				}
+
+				// when
+				res := handler.PostDataMessageForDevice(context.TODO(), params)
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
+				close(eventsRecorder.Events)
+				var events []string
+				for event := range eventsRecorder.Events {
+					events = append(events, event)
+				}
+				Expect(events).To(ContainElement(ContainSubstring("InvalidReportedProperties")))
+			})
+
+			It("Fail on invalid content", func() {
+				// given
+				content := "invalid"
+
+				params := api.PostDataMessageForDeviceParams{
+					DeviceID: deviceName,
+					Message: &models.Message{
+						Directive: directiveName,
+						Content:   content,
+					},
+				}
+
+				// when
+				res := handler.PostDataMessageForDevice(context.TODO(), params)
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceBadRequest{}))
+			})
+
+			It("Fail on update device status", func() {
+				// given
+				// updateDeviceStatus try to patch the status 4 times, and Read the
+				// device from repo too.
+				edgeDeviceRepoMock.EXPECT().
+					Read(gomock.Any(), deviceName, testNamespace).
+					Return(device, nil).
+					Times(4)
+
+				edgeDeviceRepoMock.EXPECT().
+					PatchStatus(gomock.Any(), device, gomock.Any()).
+					Return(fmt.Errorf("Failed")).
+					Times(4)
+
+				params := api.PostDataMessageForDeviceParams{
+					DeviceID: deviceName,
+					Message: &models.Message{
+						Directive: directiveName,
+					},
+				}
+
+				// when
+				res := handler.PostDataMessageForDevice(context.TODO(), params)
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceInternalServerError{}))
+			})
 
-			edgeDeviceRepoMock.EXPECT().
-				Read(gomock.Any(), "foo", testNamespace).
-				Return(device, nil).
-				Times(1)
+			It("Update device status retries if any error", func() {
+				// given
+				// updateDeviceStatus try to patch the status 4 times, and Read the
+				// device from repo too, in this case will retry 2 times.
 
-			// when
-			res := handler.GetDataMessageForDevice(context.TODO(), params)
+				edgeDeviceRepoMock.EXPECT().
+					Read(gomock.Any(), deviceName, testNamespace).
+					Return(device, nil).
+					Times(4)
 
-			// then
-			Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceOK{}))
-			config := validateAndGetDeviceConfig(res)
+				edgeDeviceRepoMock.EXPECT().
+					PatchStatus(gomock.Any(), device, gomock.Any()).
+					Return(fmt.Errorf("Failed")).
+					Times(3)
 
-			Expect(config.Configuration.Metrics).ToNot(BeNil())
-			Expect(config.Configuration.Metrics.Retention).ToNot(BeNil())
-			Expect(config.Configuration.Metrics.Retention.MaxMib).To(Equal(maxMiB))
-			Expect(config.Configuration.Metrics.Retention.MaxHours).To(Equal(maxHours))
-		})
+				edgeDeviceRepoMock.EXPECT().
+					PatchStatus(gomock.Any(), device, gomock.Any()).
+					Return(nil).
+					Times(1)
 
-		It("should map system metrics", func() {
-			// given
-			const allowListName = "a-name"
-			interval := int32(3600)
+				edgeDeviceRepoMock.EXPECT().
+					UpdateLabels(gomock.Any(), device, gomock.Any()).
+					Return(nil).
+					Times(1)
 
-			device := getDevice("foo")
-			device.Spec.Metrics = &v1alpha1.MetricsConfiguration{
-				SystemMetrics: &v1alpha1.SystemMetricsConfiguration{
-					Interval: interval,
-					Disabled: true,
-					AllowList: &v1alpha1.NameRef{
-						Name: allowListName,
+				params := api.PostDataMessageForDeviceParams{
+					DeviceID: deviceName,
+					Message: &models.Message{
+						Directive: directiveName,
 					},
-				},
-			}
+				}
 
-			edgeDeviceRepoMock.EXPECT().
-				Read(gomock.Any(), "foo", testNamespace).
-				Return(device, nil).
-				Times(1)
+				// when
+				res := handler.PostDataMessageForDevice(context.TODO(), params)
 
-			allowList := models.MetricsAllowList{Names: []string{"fizz", "buzz"}}
-			allowListsMock.EXPECT().GenerateFromConfigMap(gomock.Any(), allowListName, device.Namespace).
-				Return(&allowList, nil)
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
+			})
+		})
 
-			// when
-			res := handler.GetDataMessageForDevice(context.TODO(), params)
+		Context("Registration", func() {
 
-			// then
-			Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceOK{}))
-			config := validateAndGetDeviceConfig(res)
+			// @TODO: Missing test:
+			// Device is already registered, and does not send a valid CSR
+			// Device is sending invalid CN that does not match device-id request.
 
-			Expect(config.Configuration.Metrics).ToNot(BeNil())
-			Expect(config.Configuration.Metrics.System).ToNot(BeNil())
+			var directiveName = "registration"
 
-			Expect(config.Configuration.Metrics.System.Interval).To(Equal(interval))
+			Context("With certificate", func() {
 
-			Expect(config.Configuration.Metrics.System.Disabled).To(BeTrue())
+				createCSR := func() []byte {
+					keys, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+					ExpectWithOffset(1, err).NotTo(HaveOccurred(), "Cannot create key")
+					var csrTemplate = x509.CertificateRequest{
+						Version: 0,
+						Subject: pkix.Name{
+							CommonName:   "test",
+							Organization: []string{"k4e"},
+						},
+						SignatureAlgorithm: x509.ECDSAWithSHA256,
+					}
+					// step: generate the csr request
+					csrCertificate, err := x509.CreateCertificateRequest(rand.Reader, &csrTemplate, keys)
+					Expect(err).NotTo(HaveOccurred())
+					return csrCertificate
+				}
 
-			Expect(config.Configuration.Metrics.System.AllowList).ToNot(BeNil())
-			Expect(*config.Configuration.Metrics.System.AllowList).To(Equal(allowList))
-		})
+				var givenCert string
 
-		It("should fail when allow-list generation fails", func() {
-			// given
-			const allowListName = "a-name"
+				BeforeEach(func() {
+					initKubeConfig()
+					MTLSConfig := mtls.NewMTLSConfig(k8sClient, testNamespace, []string{"foo.com"}, true)
+					handler = yggdrasil.NewYggdrasilHandler(
+						edgeDeviceRepoMock,
+						deployRepoMock,
+						deviceSetRepoMock,
+						nil,
+						Mockk8sClient,
+						testNamespace,
+						eventsRecorder,
+						registryAuth,
+						metricsMock,
+						allowListsMock,
+						configMap,
+						MTLSConfig,
+						nil,
+						nil,
+						nil,
+						nil,
+						nil,
+					)
+					_, _, err := MTLSConfig.InitCertificates()
+					Expect(err).ToNot(HaveOccurred())
 
-			device := getDevice("foo")
-			device.Spec.Metrics = &v1alpha1.MetricsConfiguration{
-				SystemMetrics: &v1alpha1.SystemMetricsConfiguration{
-					AllowList: &v1alpha1.NameRef{
-						Name: allowListName,
-					},
-				},
-			}
+					givenCert = string(pem.EncodeToMemory(&pem.Block{
+						Type:  "CERTIFICATE",
+						Bytes: createCSR(),
+					}))
 
-			allowListsMock.EXPECT().GenerateFromConfigMap(gomock.Any(), allowListName, device.Namespace).
-				Return(nil, fmt.Errorf("boom!"))
+				})
 
-			edgeDeviceRepoMock.EXPECT().
-				Read(gomock.Any(), "foo", testNamespace).
-				Return(device, nil).
-				Times(1)
+				AfterEach(func() {
+					err := testEnv.Stop()
+					Expect(err).NotTo(HaveOccurred())
+				})
 
-			// when
-			res := handler.GetDataMessageForDevice(context.TODO(), params)
+				It("Device is already register, and send a CSR to renew", func() {
+					// given
+					edgeDeviceRepoMock.EXPECT().
+						Read(gomock.Any(), deviceName, testNamespace).
+						Return(device, nil).
+						Times(1)
 
-			// then
-			Expect(res).To(BeAssignableToTypeOf(&operations.GetDataMessageForDeviceInternalServerError{}))
-		})
-	})
+					cert := &x509.Certificate{Subject: pkix.Name{CommonName: deviceName}}
+					params := api.PostDataMessageForDeviceParams{
+						HTTPRequest: &http.Request{TLS: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}},
+						DeviceID:    deviceName,
+						Message: &models.Message{
+							Directive: directiveName,
+							Content: models.RegistrationInfo{
+								CertificateRequest: string(givenCert),
+								Hardware:           nil,
+							},
+						},
+					}
 
-	Context("PostDataMessageForDevice", func() {
+					// when
+					res := handler.PostDataMessageForDevice(context.TODO(), params)
 
-		var (
-			deviceName string
-			device     *v1alpha1.EdgeDevice
-		)
+					// then
+					Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
+					data, ok := res.(*operations.PostDataMessageForDeviceOK)
+					Expect(ok).To(BeTrue())
+					Expect(data.Payload.Content).NotTo(BeNil())
+				})
 
-		BeforeEach(func() {
-			deviceName = "foo"
-			device = getDevice(deviceName)
-		})
+				It("Device is not registered, and send a valid CSR", func() {
 
-		It("Invalid params", func() {
-			// given
-			params := api.PostDataMessageForDeviceParams{
-				DeviceID: deviceName,
-				Message: &models.Message{
-					Directive: "NOT VALID ONE",
-				},
-			}
+					// given
+					edgeDeviceRepoMock.EXPECT().
+						Read(gomock.Any(), deviceName, testNamespace).
+						Return(nil, errorNotFound).
+						Times(1)
 
-			// when
-			res := handler.PostDataMessageForDevice(context.TODO(), params)
+					edgeDeviceRepoMock.EXPECT().
+						Create(gomock.Any(), gomock.Any()).
+						Return(nil).
+						Times(1)
 
-			// then
-			Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceBadRequest{}))
-		})
+					edgeDeviceRepoMock.EXPECT().
+						PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).
+						Return(nil).
+						Times(1)
 
-		Context("Heartbeat", func() {
-			var directiveName = "heartbeat"
+					edgeDeviceRepoMock.EXPECT().
+						UpdateLabels(gomock.Any(), gomock.Any(), gomock.Any()).
+						Return(nil).
+						Times(1)
 
-			It("Device not found", func() {
-				// given
-				edgeDeviceRepoMock.EXPECT().
-					Read(gomock.Any(), deviceName, testNamespace).
-					Return(nil, errorNotFound).
-					Times(1)
+					metricsMock.EXPECT().
+						IncEdgeDeviceSuccessfulRegistration().
+						AnyTimes()
 
-				params := api.PostDataMessageForDeviceParams{
-					DeviceID: deviceName,
-					Message: &models.Message{
-						Directive: directiveName,
-					},
-				}
+					params := api.PostDataMessageForDeviceParams{
+						DeviceID: deviceName,
+						Message: &models.Message{
+							Directive: directiveName,
+							Content: models.RegistrationInfo{
+								CertificateRequest: string(givenCert),
+								Hardware:           nil,
+							},
+						},
+					}
+
+					// when
+					res := handler.PostDataMessageForDevice(context.TODO(), params)
+
+					// then
+					Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
+					data, ok := res.(*operations.PostDataMessageForDeviceOK)
+					Expect(ok).To(BeTrue())
+					Expect(data.Payload.Content).NotTo(BeNil())
 
-				// when
-				res := handler.PostDataMessageForDevice(context.TODO(), params)
+					// // checked that response is valid certificate.
+					parsedResponse, ok := data.Payload.Content.(models.RegistrationResponse)
+					Expect(ok).To(BeTrue())
 
-				// then
-				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceNotFound{}))
-			})
+					block, nextCert := pem.Decode([]byte(parsedResponse.Certificate))
+					Expect(block).NotTo(BeNil())
+					Expect(nextCert).To(HaveLen(0))
 
-			It("Device cannot be retrieved", func() {
-				// given
-				edgeDeviceRepoMock.EXPECT().
-					Read(gomock.Any(), deviceName, testNamespace).
-					Return(nil, fmt.Errorf("failed")).
-					Times(4)
+					cert, err := x509.ParseCertificate(block.Bytes)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(cert.Subject.CommonName).To(Equal(deviceName))
+				})
 
-				params := api.PostDataMessageForDeviceParams{
-					DeviceID: deviceName,
-					Message: &models.Message{
-						Directive: directiveName,
-					},
-				}
+				It("Create device with valid content", func() {
+					// given
+					content := models.RegistrationInfo{
+						Hardware:           &models.HardwareInfo{Hostname: "fooHostname"},
+						CertificateRequest: givenCert,
+					}
 
-				// when
-				res := handler.PostDataMessageForDevice(context.TODO(), params)
+					edgeDeviceRepoMock.EXPECT().
+						Read(gomock.Any(), deviceName, testNamespace).
+						Return(nil, errorNotFound).
+						Times(1)
 
-				// then
-				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceInternalServerError{}))
-			})
+					edgeDeviceRepoMock.EXPECT().
+						Create(gomock.Any(), gomock.Any()).
+						Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice) {
+							Expect(edgeDevice.Name).To(Equal(deviceName))
+							Expect(edgeDevice.Namespace).To(Equal(testNamespace))
+							Expect(edgeDevice.Finalizers).To(HaveLen(2))
+						}).
+						Return(nil).
+						Times(1)
 
-			It("Work without content", func() {
-				// given
-				edgeDeviceRepoMock.EXPECT().
-					Read(gomock.Any(), deviceName, testNamespace).
-					Return(device, nil).
-					Times(1)
+					edgeDeviceRepoMock.EXPECT().
+						PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).
+						Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, patch *client.Patch) {
+							Expect(edgeDevice.Name).To(Equal(deviceName))
+							Expect(edgeDevice.Namespace).To(Equal(testNamespace))
+							Expect(edgeDevice.Status.Deployments).To(HaveLen(0))
+							Expect(edgeDevice.Status.Hardware.Hostname).To(Equal("fooHostname"))
+						}).
+						Return(nil).
+						Times(1)
 
-				edgeDeviceRepoMock.EXPECT().
-					PatchStatus(gomock.Any(), device, gomock.Any()).
-					Return(nil).
-					Times(1)
+					edgeDeviceRepoMock.EXPECT().
+						UpdateLabels(gomock.Any(), gomock.Any(), gomock.Any()).
+						Return(nil).
+						Times(1)
 
-				edgeDeviceRepoMock.EXPECT().
-					UpdateLabels(gomock.Any(), device, gomock.Any()).
-					Return(nil).
-					Times(1)
+					metricsMock.EXPECT().
+						IncEdgeDeviceSuccessfulRegistration().
+						AnyTimes()
 
-				params := api.PostDataMessageForDeviceParams{
-					DeviceID: deviceName,
-					Message: &models.Message{
-						Directive: directiveName,
-					},
-				}
+					params := api.PostDataMessageForDeviceParams{
+						DeviceID: deviceName,
+						Message: &models.Message{
+							Directive: directiveName,
+							Content:   content,
+						},
+					}
 
-				// when
-				res := handler.PostDataMessageForDevice(context.TODO(), params)
+					// when
+					res := handler.PostDataMessageForDevice(context.TODO(), params)
 
-				// then
-				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
-			})
+					// then
+					Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
+				})
 
-			It("Work with content", func() {
-				// given
-				content := models.Heartbeat{
-					Status:  "running",
-					Version: "1",
-					Workloads: []*models.WorkloadStatus{
-						{Name: "workload-1", Status: "running"}},
-					Hardware: &models.HardwareInfo{
-						Hostname: "test-hostname",
-					},
-				}
+				It("Cannot create device on repo", func() {
+					// given
+					edgeDeviceRepoMock.EXPECT().
+						Read(gomock.Any(), deviceName, testNamespace).
+						Return(nil, errorNotFound).
+						Times(1)
 
-				device.Status.Deployments = []v1alpha1.Deployment{{
-					Name:  "workload-1",
-					Phase: "failing",
-				}}
+					edgeDeviceRepoMock.EXPECT().
+						Create(gomock.Any(), gomock.Any()).
+						Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice) {
+							Expect(edgeDevice.Name).To(Equal(deviceName))
+							Expect(edgeDevice.Namespace).To(Equal(testNamespace))
+							Expect(edgeDevice.Finalizers).To(HaveLen(2))
+						}).
+						Return(fmt.Errorf("Failed")).
+						Times(1)
 
-				edgeDeviceRepoMock.EXPECT().
-					Read(gomock.Any(), deviceName, testNamespace).
-					Return(device, nil).
-					Times(1)
+					metricsMock.EXPECT().
+						IncEdgeDeviceFailedRegistration().
+						AnyTimes()
 
-				edgeDeviceRepoMock.EXPECT().
-					UpdateLabels(gomock.Any(), device, gomock.Any()).
-					Return(nil).
-					Times(1)
+					params := api.PostDataMessageForDeviceParams{
+						DeviceID: deviceName,
+						Message: &models.Message{
+							Directive: directiveName,
+							Content: models.RegistrationInfo{
+								CertificateRequest: givenCert,
+							},
+						},
+					}
 
-				edgeDeviceRepoMock.EXPECT().
-					PatchStatus(gomock.Any(), device, gomock.Any()).
-					Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, patch *client.Patch) {
-						Expect(edgeDevice.Status.Deployments).To(HaveLen(1))
-						Expect(edgeDevice.Status.Deployments[0].Phase).To(
-							Equal(v1alpha1.EdgeDeploymentPhase("running")))
-						Expect(edgeDevice.Status.Deployments[0].Name).To(Equal("workload-1"))
-					}).
-					Return(nil).
-					Times(1)
+					// when
+					res := handler.PostDataMessageForDevice(context.TODO(), params)
 
-				params := api.PostDataMessageForDeviceParams{
-					DeviceID: deviceName,
-					Message: &models.Message{
-						Directive: directiveName,
-						Content:   content,
-					},
-				}
+					// then
+					Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceInternalServerError{}))
+				})
 
-				// when
-				res := handler.PostDataMessageForDevice(context.TODO(), params)
+				It("Update device status failed", func() {
+					// retry on status is already tested on heartbeat section
+					// given
+					edgeDeviceRepoMock.EXPECT().
+						Read(gomock.Any(), deviceName, testNamespace).
+						Return(nil, errorNotFound).
+						Times(1)
 
-				// then
-				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
-			})
+					edgeDeviceRepoMock.EXPECT().
+						Create(gomock.Any(), gomock.Any()).
+						Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice) {
+							Expect(edgeDevice.Name).To(Equal(deviceName))
+							Expect(edgeDevice.Namespace).To(Equal(testNamespace))
+							Expect(edgeDevice.Finalizers).To(HaveLen(2))
+						}).
+						Return(nil).
+						Times(1)
 
-			It("Work with content and events", func() {
-				// given
-				content := models.Heartbeat{
-					Status:  "running",
-					Version: "1",
-					Workloads: []*models.WorkloadStatus{
-						{Name: "workload-1", Status: "created"}},
-					Hardware: &models.HardwareInfo{
-						Hostname: "test-hostname",
-					},
-					Events: []*models.EventInfo{{
-						Message: "failed to start container",
-						Reason:  "Started",
-						Type:    models.EventInfoTypeWarn,
-					}},
-				}
+					edgeDeviceRepoMock.EXPECT().
+						PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).
+						Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, patch *client.Patch) {
+							Expect(edgeDevice.Name).To(Equal(deviceName))
+							Expect(edgeDevice.Namespace).To(Equal(testNamespace))
+							Expect(edgeDevice.Status.Deployments).To(HaveLen(0))
+						}).
+						Return(fmt.Errorf("Failed")).
+						Times(1)
 
-				device.Status.Deployments = []v1alpha1.Deployment{{
-					Name:  "workload-1",
-					Phase: "failing",
-				}}
+					edgeDeviceRepoMock.EXPECT().
+						Read(gomock.Any(), deviceName, testNamespace).
+						Return(nil, fmt.Errorf("Failed")).
+						Times(3)
 
-				edgeDeviceRepoMock.EXPECT().
-					Read(gomock.Any(), deviceName, testNamespace).
-					Return(device, nil).
-					Times(1)
+					edgeDeviceRepoMock.EXPECT().
+						Patch(gomock.Any(), gomock.Any(), gomock.Any()).
+						Do(func(ctx context.Context, old, new *v1alpha1.EdgeDevice) {
+							Expect(new.Finalizers).To(BeEmpty())
+						}).
+						Return(nil).
+						Times(1)
 
-				edgeDeviceRepoMock.EXPECT().
-					UpdateLabels(gomock.Any(), device, gomock.Any()).
-					Return(nil).
-					Times(1)
+					edgeDeviceRepoMock.EXPECT().
+						Delete(gomock.Any(), gomock.Any()).
+						Return(nil).
+						Times(1)
+
+					metricsMock.EXPECT().
+						IncEdgeDeviceFailedRegistration().
+						AnyTimes()
+
+					params := api.PostDataMessageForDeviceParams{
+						DeviceID: deviceName,
+						Message: &models.Message{
+							Directive: directiveName,
+							Content: models.RegistrationInfo{
+								CertificateRequest: givenCert,
+							},
+						},
+					}
 
-				edgeDeviceRepoMock.EXPECT().
-					PatchStatus(gomock.Any(), device, gomock.Any()).
-					Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, patch *client.Patch) {
-						Expect(edgeDevice.Status.Deployments).To(HaveLen(1))
-						Expect(edgeDevice.Status.Deployments[0].Phase).To(
-							Equal(v1alpha1.EdgeDeploymentPhase("created")))
-						Expect(edgeDevice.Status.Deployments[0].Name).To(Equal("workload-1"))
-					}).
-					Return(nil).
-					Times(1)
+					// when
+					res := handler.PostDataMessageForDevice(context.TODO(), params)
 
-				params := api.PostDataMessageForDeviceParams{
-					DeviceID: deviceName,
-					Message: &models.Message{
-						Directive: directiveName,
-						Content:   content,
-					},
-				}
+					// then
+					Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceInternalServerError{}))
+				})
 
-				// when
-				res := handler.PostDataMessageForDevice(context.TODO(), params)
+			})
 
-				// test emmiting the events:
-				close(eventsRecorder.Events)
-				found := false
-				for event := range eventsRecorder.Events {
-					if strings.Contains(event, "failed to start container") {
-						found = true
+			Context("With registration token", func() {
+				const (
+					tokenName       = "site-a"
+					tokenSecret     = "bootstrap-secret"
+					tokensNamespace = "flotta"
+					targetNamespace = "site-a"
+				)
+
+				var (
+					tokenRepoMock *registrationtoken.MockRepository
+					token         *v1alpha1.RegistrationToken
+					required      bool
+					caProvider    *caProviderStub
+				)
+
+				registrationParams := func(tokenValue string) api.PostDataMessageForDeviceParams {
+					return api.PostDataMessageForDeviceParams{
+						DeviceID: deviceName,
+						Message: &models.Message{
+							Directive: directiveName,
+							Content: models.RegistrationInfo{
+								Hardware: &models.HardwareInfo{Hostname: "fooHostname"},
+								Token:    tokenValue,
+							},
+						},
 					}
 				}
-				Expect(found).To(BeTrue())
 
-				// then
-				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
-			})
+				BeforeEach(func() {
+					tokenRepoMock = registrationtoken.NewMockRepository(mockCtrl)
+					hash := sha256.Sum256([]byte(tokenSecret))
+					maxUses := int32(2)
+					token = &v1alpha1.RegistrationToken{
+						ObjectMeta: v1.ObjectMeta{Name: tokenName, Namespace: tokensNamespace},
+						Spec: v1alpha1.RegistrationTokenSpec{
+							SecretHash:      hex.EncodeToString(hash[:]),
+							MaxUses:         &maxUses,
+							TargetNamespace: targetNamespace,
+							Labels:          map[string]string{"site": "a"},
+						},
+					}
+					required = false
+					caProvider = &caProviderStub{certificate: []byte("certificate")}
+				})
 
-			It("Fail on invalid content", func() {
-				// given
-				content := "invalid"
+				JustBeforeEach(func() {
+					tlsConfig := mtls.NewMTLSConfig(nil, testNamespace, nil, false)
+					tlsConfig.SetCAProvider([]mtls.CAProvider{caProvider})
+					handler = yggdrasil.NewYggdrasilHandler(edgeDeviceRepoMock, deployRepoMock, deviceSetRepoMock, nil, Mockk8sClient, testNamespace,
+						eventsRecorder, registryAuth, metricsMock, allowListsMock, configMap, tlsConfig, nil, nil,
+						&yggdrasil.RegistrationTokens{Repository: tokenRepoMock, Namespace: tokensNamespace, Required: required}, nil, nil)
+				})
 
-				params := api.PostDataMessageForDeviceParams{
-					DeviceID: deviceName,
-					Message: &models.Message{
-						Directive: directiveName,
-						Content:   content,
-					},
-				}
+				It("should register the device in the target namespace with the labels of the token", func() {
+					// given
+					tokenRepoMock.EXPECT().Read(gomock.Any(), tokenName, tokensNamespace).Return(token, nil).Times(2)
+					tokenRepoMock.EXPECT().PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).
+						Do(func(ctx context.Context, t *v1alpha1.RegistrationToken, patch *client.Patch) {
+							Expect(t.Status.Uses).To(BeEquivalentTo(1))
+							Expect(t.Status.LastUsedTime).NotTo(BeNil())
+						}).
+						Return(nil)
+					edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), deviceName, targetNamespace).Return(nil, errorNotFound)
+					edgeDeviceRepoMock.EXPECT().Create(gomock.Any(), gomock.Any()).
+						Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice) {
+							Expect(edgeDevice.Namespace).To(Equal(targetNamespace))
+							Expect(edgeDevice.Labels).To(Equal(map[string]string{"site": "a"}))
+						}).
+						Return(nil)
+					edgeDeviceRepoMock.EXPECT().PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).
+						Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, patch *client.Patch) {
+							Expect(edgeDevice.Status.RegistrationToken).To(Equal(tokenName))
+							Expect(edgeDevice.Status.Hardware.Hostname).To(Equal("fooHostname"))
+						}).
+						Return(nil)
+					edgeDeviceRepoMock.EXPECT().UpdateLabels(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+					metricsMock.EXPECT().IncEdgeDeviceSuccessfulRegistration()
 
-				// when
-				res := handler.PostDataMessageForDevice(context.TODO(), params)
+					// when
+					res := handler.PostDataMessageForDevice(context.TODO(), registrationParams(tokenName+"."+tokenSecret))
 
-				// then
-				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceBadRequest{}))
-			})
+					// then
+					Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
+				})
 
-			It("Fail on update device status", func() {
-				// given
-				// updateDeviceStatus try to patch the status 4 times, and Read the
-				// device from repo too.
-				edgeDeviceRepoMock.EXPECT().
-					Read(gomock.Any(), deviceName, testNamespace).
-					Return(device, nil).
-					Times(4)
+				It("should not issue a certificate of a registered device to token holders", func() {
+					// given
+					tokenRepoMock.EXPECT().Read(gomock.Any(), tokenName, tokensNamespace).Return(token, nil)
+					edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), deviceName, targetNamespace).Return(device, nil)
+					params := registrationParams(tokenName + "." + tokenSecret)
+					params.Message.Content = models.RegistrationInfo{Token: tokenName + "." + tokenSecret, CertificateRequest: "csr"}
 
-				edgeDeviceRepoMock.EXPECT().
-					PatchStatus(gomock.Any(), device, gomock.Any()).
-					Return(fmt.Errorf("Failed")).
-					Times(4)
+					// when
+					res := handler.PostDataMessageForDevice(context.TODO(), params)
 
-				params := api.PostDataMessageForDeviceParams{
-					DeviceID: deviceName,
-					Message: &models.Message{
-						Directive: directiveName,
-					},
-				}
+					// then
+					Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceUnauthorized{}))
+				})
 
-				// when
-				res := handler.PostDataMessageForDevice(context.TODO(), params)
+				It("should not issue a certificate of a registered device of the initial namespace to another device", func() {
+					// given
+					cert := &x509.Certificate{Subject: pkix.Name{CommonName: "other-device"}}
+					edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), deviceName, testNamespace).Return(device, nil)
+					params := registrationParams("")
+					params.HTTPRequest = &http.Request{TLS: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}}
+					params.Message.Content = models.RegistrationInfo{CertificateRequest: "csr"}
 
-				// then
-				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceInternalServerError{}))
-			})
+					// when
+					res := handler.PostDataMessageForDevice(context.TODO(), params)
 
-			It("Update device status retries if any error", func() {
-				// given
-				// updateDeviceStatus try to patch the status 4 times, and Read the
-				// device from repo too, in this case will retry 2 times.
+					// then
+					Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceUnauthorized{}))
+				})
 
-				edgeDeviceRepoMock.EXPECT().
-					Read(gomock.Any(), deviceName, testNamespace).
-					Return(device, nil).
-					Times(4)
+				It("should not consume the token of a registered device", func() {
+					// given
+					tokenRepoMock.EXPECT().Read(gomock.Any(), tokenName, tokensNamespace).Return(token, nil)
+					edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), deviceName, targetNamespace).Return(device, nil)
 
-				edgeDeviceRepoMock.EXPECT().
-					PatchStatus(gomock.Any(), device, gomock.Any()).
-					Return(fmt.Errorf("Failed")).
-					Times(3)
+					// when
+					res := handler.PostDataMessageForDevice(context.TODO(), registrationParams(tokenName+"."+tokenSecret))
 
-				edgeDeviceRepoMock.EXPECT().
-					PatchStatus(gomock.Any(), device, gomock.Any()).
-					Return(nil).
-					Times(1)
+					// then
+					Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
+				})
 
-				edgeDeviceRepoMock.EXPECT().
-					UpdateLabels(gomock.Any(), device, gomock.Any()).
-					Return(nil).
-					Times(1)
+				It("should retry consuming the token on conflicts", func() {
+					// given
+					tokenRepoMock.EXPECT().Read(gomock.Any(), tokenName, tokensNamespace).
+						DoAndReturn(func(ctx context.Context, name, namespace string) (*v1alpha1.RegistrationToken, error) {
+							return token.DeepCopy(), nil
+						}).
+						Times(3)
+					conflict := errors.NewConflict(schema.GroupResource{Resource: "registrationtokens"}, tokenName, fmt.Errorf("conflict"))
+					gomock.InOrder(
+						tokenRepoMock.EXPECT().PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).Return(conflict),
+						tokenRepoMock.EXPECT().PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil),
+					)
+					edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), deviceName, targetNamespace).Return(nil, errorNotFound)
+					edgeDeviceRepoMock.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil)
+					edgeDeviceRepoMock.EXPECT().PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+					edgeDeviceRepoMock.EXPECT().UpdateLabels(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+					metricsMock.EXPECT().IncEdgeDeviceSuccessfulRegistration()
 
-				params := api.PostDataMessageForDeviceParams{
-					DeviceID: deviceName,
-					Message: &models.Message{
-						Directive: directiveName,
-					},
-				}
+					// when
+					res := handler.PostDataMessageForDevice(context.TODO(), registrationParams(tokenName+"."+tokenSecret))
 
-				// when
-				res := handler.PostDataMessageForDevice(context.TODO(), params)
+					// then
+					Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
+				})
 
-				// then
-				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
-			})
-		})
+				table.DescribeTable("should reject invalid tokens", func(tokenValue string, update func(token *v1alpha1.RegistrationToken)) {
+					// given
+					update(token)
+					tokenRepoMock.EXPECT().Read(gomock.Any(), gomock.Any(), tokensNamespace).Return(token, nil).AnyTimes()
+					metricsMock.EXPECT().IncEdgeDeviceFailedRegistration()
 
-		Context("Registration", func() {
+					// when
+					res := handler.PostDataMessageForDevice(context.TODO(), registrationParams(tokenValue))
 
-			// @TODO: Missing test:
-			// Device is already registered, and does not send a valid CSR
-			// Device is sending invalid CN that does not match device-id request.
+					// then
+					Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceUnauthorized{}))
+				},
+					table.Entry("wrong secret", tokenName+".other", func(token *v1alpha1.RegistrationToken) {}),
+					table.Entry("no secret", tokenName, func(token *v1alpha1.RegistrationToken) {}),
+					table.Entry("expired", tokenName+"."+tokenSecret, func(token *v1alpha1.RegistrationToken) {
+						expiration := v1.NewTime(time.Now().Add(-time.Minute))
+						token.Spec.Expiration = &expiration
+					}),
+					table.Entry("used up", tokenName+"."+tokenSecret, func(token *v1alpha1.RegistrationToken) {
+						token.Status.Uses = 2
+						edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), deviceName, targetNamespace).Return(nil, errorNotFound)
+					}),
+				)
+
+				It("should not consume the token when the certificate request cannot be signed", func() {
+					// given
+					caProvider.err = fmt.Errorf("invalid certificate request")
+					tokenRepoMock.EXPECT().Read(gomock.Any(), tokenName, tokensNamespace).Return(token, nil)
+					tokenRepoMock.EXPECT().PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+					edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), deviceName, targetNamespace).Return(nil, errorNotFound)
+					params := registrationParams(tokenName + "." + tokenSecret)
+					params.Message.Content = models.RegistrationInfo{Token: tokenName + "." + tokenSecret, CertificateRequest: "csr"}
 
-			var directiveName = "registration"
+					// when
+					res := handler.PostDataMessageForDevice(context.TODO(), params)
 
-			Context("With certificate", func() {
+					// then
+					Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceBadRequest{}))
+				})
 
-				createCSR := func() []byte {
-					keys, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
-					ExpectWithOffset(1, err).NotTo(HaveOccurred(), "Cannot create key")
-					var csrTemplate = x509.CertificateRequest{
-						Version: 0,
-						Subject: pkix.Name{
-							CommonName:   "test",
-							Organization: []string{"k4e"},
-						},
-						SignatureAlgorithm: x509.ECDSAWithSHA256,
-					}
-					// step: generate the csr request
-					csrCertificate, err := x509.CreateCertificateRequest(rand.Reader, &csrTemplate, keys)
-					Expect(err).NotTo(HaveOccurred())
-					return csrCertificate
-				}
+				It("should give the use of the token back when the device cannot be created", func() {
+					// given
+					tokenRepoMock.EXPECT().Read(gomock.Any(), tokenName, tokensNamespace).
+						DoAndReturn(func(ctx context.Context, name, namespace string) (*v1alpha1.RegistrationToken, error) {
+							return token.DeepCopy(), nil
+						}).
+						Times(3)
+					gomock.InOrder(
+						tokenRepoMock.EXPECT().PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).
+							Do(func(ctx context.Context, t *v1alpha1.RegistrationToken, patch *client.Patch) {
+								Expect(t.Status.Uses).To(BeEquivalentTo(1))
+								token.Status.Uses = t.Status.Uses
+							}).
+							Return(nil),
+						tokenRepoMock.EXPECT().PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).
+							Do(func(ctx context.Context, t *v1alpha1.RegistrationToken, patch *client.Patch) {
+								Expect(t.Status.Uses).To(BeEquivalentTo(0))
+							}).
+							Return(nil),
+					)
+					edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), deviceName, targetNamespace).Return(nil, errorNotFound)
+					edgeDeviceRepoMock.EXPECT().Create(gomock.Any(), gomock.Any()).Return(fmt.Errorf("boom"))
+					metricsMock.EXPECT().IncEdgeDeviceFailedRegistration()
 
-				var givenCert string
+					// when
+					res := handler.PostDataMessageForDevice(context.TODO(), registrationParams(tokenName+"."+tokenSecret))
 
-				BeforeEach(func() {
-					initKubeConfig()
-					MTLSConfig := mtls.NewMTLSConfig(k8sClient, testNamespace, []string{"foo.com"}, true)
-					handler = yggdrasil.NewYggdrasilHandler(
-						edgeDeviceRepoMock,
-						deployRepoMock,
-						nil,
-						Mockk8sClient,
-						testNamespace,
-						eventsRecorder,
-						registryAuth,
-						metricsMock,
-						allowListsMock,
-						configMap,
-						MTLSConfig,
+					// then
+					Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceInternalServerError{}))
+				})
+
+				It("should delete the device and give the use of the token back when the status of the device cannot be recorded", func() {
+					// given
+					tokenRepoMock.EXPECT().Read(gomock.Any(), tokenName, tokensNamespace).
+						DoAndReturn(func(ctx context.Context, name, namespace string) (*v1alpha1.RegistrationToken, error) {
+							return token.DeepCopy(), nil
+						}).
+						Times(3)
+					gomock.InOrder(
+						tokenRepoMock.EXPECT().PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).
+							Do(func(ctx context.Context, t *v1alpha1.RegistrationToken, patch *client.Patch) {
+								token.Status.Uses = t.Status.Uses
+							}).
+							Return(nil),
+						tokenRepoMock.EXPECT().PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).
+							Do(func(ctx context.Context, t *v1alpha1.RegistrationToken, patch *client.Patch) {
+								Expect(t.Status.Uses).To(BeEquivalentTo(0))
+							}).
+							Return(nil),
 					)
-					_, _, err := MTLSConfig.InitCertificates()
-					Expect(err).ToNot(HaveOccurred())
+					edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), deviceName, targetNamespace).Return(nil, errorNotFound)
+					edgeDeviceRepoMock.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil)
+					edgeDeviceRepoMock.EXPECT().PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).Return(fmt.Errorf("boom"))
+					edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), deviceName, targetNamespace).Return(nil, fmt.Errorf("boom")).Times(3)
+					gomock.InOrder(
+						edgeDeviceRepoMock.EXPECT().Patch(gomock.Any(), gomock.Any(), gomock.Any()).
+							Do(func(ctx context.Context, old, new *v1alpha1.EdgeDevice) {
+								Expect(old.Finalizers).To(HaveLen(2))
+								Expect(new.Finalizers).To(BeEmpty())
+							}).
+							Return(nil),
+						edgeDeviceRepoMock.EXPECT().Delete(gomock.Any(), gomock.Any()).
+							Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice) {
+								Expect(edgeDevice.Name).To(Equal(deviceName))
+								Expect(edgeDevice.Namespace).To(Equal(targetNamespace))
+							}).
+							Return(nil),
+					)
+					metricsMock.EXPECT().IncEdgeDeviceFailedRegistration()
 
-					givenCert = string(pem.EncodeToMemory(&pem.Block{
-						Type:  "CERTIFICATE",
-						Bytes: createCSR(),
-					}))
+					// when
+					res := handler.PostDataMessageForDevice(context.TODO(), registrationParams(tokenName+"."+tokenSecret))
 
+					// then
+					Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceInternalServerError{}))
 				})
 
-				AfterEach(func() {
-					err := testEnv.Stop()
-					Expect(err).NotTo(HaveOccurred())
+				It("should issue the certificate again to a device retrying its registration with its token", func() {
+					// given
+					token.Status.Uses = 2
+					device.Status.RegistrationToken = tokenName
+					tokenRepoMock.EXPECT().Read(gomock.Any(), tokenName, tokensNamespace).Return(token, nil)
+					tokenRepoMock.EXPECT().PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+					edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), deviceName, targetNamespace).Return(device, nil)
+					params := registrationParams(tokenName + "." + tokenSecret)
+					params.Message.Content = models.RegistrationInfo{Token: tokenName + "." + tokenSecret, CertificateRequest: "csr"}
+
+					// when
+					res := handler.PostDataMessageForDevice(context.TODO(), params)
+
+					// then
+					Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
+					content := res.(*api.PostDataMessageForDeviceOK).Payload.Content.(models.RegistrationResponse)
+					Expect(content.Certificate).To(Equal("certificate"))
 				})
 
-				It("Device is already register, and send a CSR to renew", func() {
+				It("should register devices with a token requiring approval without issuing their certificate", func() {
 					// given
-					edgeDeviceRepoMock.EXPECT().
-						Read(gomock.Any(), deviceName, testNamespace).
-						Return(device, nil).
-						Times(1)
+					token.Spec.ApprovalRequired = true
+					tokenRepoMock.EXPECT().Read(gomock.Any(), tokenName, tokensNamespace).Return(token, nil).Times(2)
+					tokenRepoMock.EXPECT().PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+					edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), deviceName, targetNamespace).Return(nil, errorNotFound)
+					edgeDeviceRepoMock.EXPECT().Create(gomock.Any(), gomock.Any()).
+						Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice) {
+							Expect(edgeDevice.Spec.RegistrationApproved).To(BeFalse())
+						}).
+						Return(nil)
+					edgeDeviceRepoMock.EXPECT().PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+					edgeDeviceRepoMock.EXPECT().UpdateLabels(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+					metricsMock.EXPECT().IncEdgeDeviceSuccessfulRegistration()
+					params := registrationParams(tokenName + "." + tokenSecret)
+					params.Message.Content = models.RegistrationInfo{Token: tokenName + "." + tokenSecret, CertificateRequest: "csr"}
 
-					params := api.PostDataMessageForDeviceParams{
-						DeviceID: deviceName,
-						Message: &models.Message{
-							Directive: directiveName,
-							Content: models.RegistrationInfo{
-								CertificateRequest: string(givenCert),
-								Hardware:           nil,
-							},
-						},
-					}
+					// when
+					res := handler.PostDataMessageForDevice(context.TODO(), params)
+
+					// then
+					Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceForbidden{}))
+				})
+
+				It("should not issue the certificate to a device retrying its registration until it is approved", func() {
+					// given
+					token.Spec.ApprovalRequired = true
+					device.Status.RegistrationToken = tokenName
+					tokenRepoMock.EXPECT().Read(gomock.Any(), tokenName, tokensNamespace).Return(token, nil)
+					edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), deviceName, targetNamespace).Return(device, nil)
+					params := registrationParams(tokenName + "." + tokenSecret)
+					params.Message.Content = models.RegistrationInfo{Token: tokenName + "." + tokenSecret, CertificateRequest: "csr"}
 
 					// when
 					res := handler.PostDataMessageForDevice(context.TODO(), params)
 
 					// then
-					Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
-					data, ok := res.(*operations.PostDataMessageForDeviceOK)
-					Expect(ok).To(BeTrue())
-					Expect(data.Payload.Content).NotTo(BeNil())
+					Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceForbidden{}))
 				})
 
-				It("Device is not registered, and send a valid CSR", func() {
-
+				It("should issue the certificate to a device retrying its registration once it is approved", func() {
 					// given
-					edgeDeviceRepoMock.EXPECT().
-						Read(gomock.Any(), deviceName, testNamespace).
-						Return(nil, errorNotFound).
-						Times(1)
-
-					edgeDeviceRepoMock.EXPECT().
-						Create(gomock.Any(), gomock.Any()).
-						Return(nil).
-						Times(1)
-
-					edgeDeviceRepoMock.EXPECT().
-						PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).
-						Return(nil).
-						Times(1)
-
-					edgeDeviceRepoMock.EXPECT().
-						UpdateLabels(gomock.Any(), gomock.Any(), gomock.Any()).
-						Return(nil).
-						Times(1)
-
-					metricsMock.EXPECT().
-						IncEdgeDeviceSuccessfulRegistration().
-						AnyTimes()
-
-					params := api.PostDataMessageForDeviceParams{
-						DeviceID: deviceName,
-						Message: &models.Message{
-							Directive: directiveName,
-							Content: models.RegistrationInfo{
-								CertificateRequest: string(givenCert),
-								Hardware:           nil,
-							},
-						},
-					}
+					token.Spec.ApprovalRequired = true
+					device.Status.RegistrationToken = tokenName
+					device.Spec.RegistrationApproved = true
+					tokenRepoMock.EXPECT().Read(gomock.Any(), tokenName, tokensNamespace).Return(token, nil)
+					edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), deviceName, targetNamespace).Return(device, nil)
+					params := registrationParams(tokenName + "." + tokenSecret)
+					params.Message.Content = models.RegistrationInfo{Token: tokenName + "." + tokenSecret, CertificateRequest: "csr"}
 
 					// when
 					res := handler.PostDataMessageForDevice(context.TODO(), params)
 
 					// then
 					Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
-					data, ok := res.(*operations.PostDataMessageForDeviceOK)
-					Expect(ok).To(BeTrue())
-					Expect(data.Payload.Content).NotTo(BeNil())
+					content := res.(*api.PostDataMessageForDeviceOK).Payload.Content.(models.RegistrationResponse)
+					Expect(content.Certificate).To(Equal("certificate"))
+				})
 
-					// // checked that response is valid certificate.
-					parsedResponse, ok := data.Payload.Content.(models.RegistrationResponse)
-					Expect(ok).To(BeTrue())
+				It("should not issue the certificate again to a device with its token once it has been seen", func() {
+					// given
+					device.Status.RegistrationToken = tokenName
+					device.Status.LastSeenTime = v1.Now()
+					tokenRepoMock.EXPECT().Read(gomock.Any(), tokenName, tokensNamespace).Return(token, nil)
+					edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), deviceName, targetNamespace).Return(device, nil)
+					params := registrationParams(tokenName + "." + tokenSecret)
+					params.Message.Content = models.RegistrationInfo{Token: tokenName + "." + tokenSecret, CertificateRequest: "csr"}
 
-					block, nextCert := pem.Decode([]byte(parsedResponse.Certificate))
-					Expect(block).NotTo(BeNil())
-					Expect(nextCert).To(HaveLen(0))
+					// when
+					res := handler.PostDataMessageForDevice(context.TODO(), params)
 
-					cert, err := x509.ParseCertificate(block.Bytes)
-					Expect(err).NotTo(HaveOccurred())
-					Expect(cert.Subject.CommonName).To(Equal(deviceName))
+					// then
+					Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceUnauthorized{}))
 				})
 
-				It("Create device with valid content", func() {
+				It("should reject tokens that do not exist", func() {
 					// given
-					content := models.RegistrationInfo{
-						Hardware:           &models.HardwareInfo{Hostname: "fooHostname"},
-						CertificateRequest: givenCert,
-					}
+					tokenRepoMock.EXPECT().Read(gomock.Any(), tokenName, tokensNamespace).Return(nil, errorNotFound)
+					metricsMock.EXPECT().IncEdgeDeviceFailedRegistration()
 
-					edgeDeviceRepoMock.EXPECT().
-						Read(gomock.Any(), deviceName, testNamespace).
-						Return(nil, errorNotFound).
-						Times(1)
+					// when
+					res := handler.PostDataMessageForDevice(context.TODO(), registrationParams(tokenName+"."+tokenSecret))
 
-					edgeDeviceRepoMock.EXPECT().
-						Create(gomock.Any(), gomock.Any()).
-						Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice) {
-							Expect(edgeDevice.Name).To(Equal(deviceName))
-							Expect(edgeDevice.Namespace).To(Equal(testNamespace))
-							Expect(edgeDevice.Finalizers).To(HaveLen(2))
-						}).
-						Return(nil).
-						Times(1)
+					// then
+					Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceUnauthorized{}))
+				})
 
-					edgeDeviceRepoMock.EXPECT().
-						PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).
-						Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, patch *client.Patch) {
-							Expect(edgeDevice.Name).To(Equal(deviceName))
-							Expect(edgeDevice.Namespace).To(Equal(testNamespace))
-							Expect(edgeDevice.Status.Deployments).To(HaveLen(0))
-							Expect(edgeDevice.Status.Hardware.Hostname).To(Equal("fooHostname"))
-						}).
-						Return(nil).
-						Times(1)
+				Context("when tokens are required", func() {
+					BeforeEach(func() {
+						required = true
+					})
 
-					edgeDeviceRepoMock.EXPECT().
-						UpdateLabels(gomock.Any(), gomock.Any(), gomock.Any()).
-						Return(nil).
-						Times(1)
+					It("should reject new devices without token", func() {
+						// given
+						edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), deviceName, testNamespace).Return(nil, errorNotFound)
+						metricsMock.EXPECT().IncEdgeDeviceFailedRegistration()
 
-					metricsMock.EXPECT().
-						IncEdgeDeviceSuccessfulRegistration().
-						AnyTimes()
+						// when
+						res := handler.PostDataMessageForDevice(context.TODO(), registrationParams(""))
 
-					params := api.PostDataMessageForDeviceParams{
-						DeviceID: deviceName,
-						Message: &models.Message{
-							Directive: directiveName,
-							Content:   content,
-						},
-					}
+						// then
+						Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceUnauthorized{}))
+					})
 
-					// when
-					res := handler.PostDataMessageForDevice(context.TODO(), params)
+					It("should accept registered devices without token", func() {
+						// given
+						edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), deviceName, testNamespace).Return(device, nil)
 
-					// then
-					Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
+						// when
+						res := handler.PostDataMessageForDevice(context.TODO(), registrationParams(""))
+
+						// then
+						Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
+					})
 				})
 
-				It("Cannot create device on repo", func() {
+				It("should read devices in the namespace recorded in their certificate", func() {
 					// given
-					edgeDeviceRepoMock.EXPECT().
-						Read(gomock.Any(), deviceName, testNamespace).
-						Return(nil, errorNotFound).
-						Times(1)
+					cert := &x509.Certificate{Subject: pkix.Name{CommonName: deviceName, OrganizationalUnit: []string{targetNamespace}}}
+					request := &http.Request{TLS: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}}
+					edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), deviceName, targetNamespace).Return(nil, errorNotFound)
 
-					edgeDeviceRepoMock.EXPECT().
-						Create(gomock.Any(), gomock.Any()).
-						Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice) {
-							Expect(edgeDevice.Name).To(Equal(deviceName))
-							Expect(edgeDevice.Namespace).To(Equal(testNamespace))
-							Expect(edgeDevice.Finalizers).To(HaveLen(2))
-						}).
-						Return(fmt.Errorf("Failed")).
-						Times(1)
+					// when
+					res := handler.GetDataMessageForDevice(context.TODO(), api.GetDataMessageForDeviceParams{
+						HTTPRequest: request,
+						DeviceID:    deviceName,
+					})
 
-					metricsMock.EXPECT().
-						IncEdgeDeviceFailedRegistration().
-						AnyTimes()
+					// then
+					Expect(res).To(BeAssignableToTypeOf(&api.GetDataMessageForDeviceNotFound{}))
+				})
+			})
 
-					params := api.PostDataMessageForDeviceParams{
+			Context("With attestation", func() {
+				var (
+					verifierMock *attestation.MockVerifier
+					required     bool
+					givenInfo    *models.Attestation
+				)
+
+				registrationParams := func(info *models.Attestation) api.PostDataMessageForDeviceParams {
+					return api.PostDataMessageForDeviceParams{
 						DeviceID: deviceName,
 						Message: &models.Message{
 							Directive: directiveName,
 							Content: models.RegistrationInfo{
-								CertificateRequest: givenCert,
+								Hardware:    &models.HardwareInfo{Hostname: "fooHostname"},
+								Attestation: info,
 							},
 						},
 					}
+				}
 
-					// when
-					res := handler.PostDataMessageForDevice(context.TODO(), params)
+				BeforeEach(func() {
+					verifierMock = attestation.NewMockVerifier(mockCtrl)
+					givenInfo = &models.Attestation{EkCertificate: "ek", AttestationKey: "ak"}
+					required = false
+				})
 
-					// then
-					Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceInternalServerError{}))
+				JustBeforeEach(func() {
+					handler = yggdrasil.NewYggdrasilHandler(edgeDeviceRepoMock, deployRepoMock, deviceSetRepoMock, nil, Mockk8sClient, testNamespace,
+						eventsRecorder, registryAuth, metricsMock, allowListsMock, configMap, nil, nil, nil, nil,
+						&yggdrasil.Attestation{Verifier: verifierMock, Required: required}, nil)
 				})
 
-				It("Update device status failed", func() {
-					// retry on status is already tested on heartbeat section
+				It("should return the challenge without registering the device", func() {
 					// given
-					edgeDeviceRepoMock.EXPECT().
-						Read(gomock.Any(), deviceName, testNamespace).
-						Return(nil, errorNotFound).
-						Times(1)
+					challenge := &models.AttestationChallenge{CredentialBlob: "blob", EncryptedSecret: "secret"}
+					edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), deviceName, testNamespace).Return(nil, errorNotFound)
+					verifierMock.EXPECT().Verify(gomock.Any(), deviceName, "", givenInfo).Return(nil, challenge, nil)
 
-					edgeDeviceRepoMock.EXPECT().
-						Create(gomock.Any(), gomock.Any()).
-						Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice) {
-							Expect(edgeDevice.Name).To(Equal(deviceName))
-							Expect(edgeDevice.Namespace).To(Equal(testNamespace))
-							Expect(edgeDevice.Finalizers).To(HaveLen(2))
-						}).
-						Return(nil).
-						Times(1)
+					// when
+					res := handler.PostDataMessageForDevice(context.TODO(), registrationParams(givenInfo))
 
-					edgeDeviceRepoMock.EXPECT().
-						PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).
+					// then
+					Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
+					content := res.(*api.PostDataMessageForDeviceOK).Payload.Content.(models.RegistrationResponse)
+					Expect(content.AttestationChallenge).To(Equal(challenge))
+					Expect(content.Certificate).To(BeEmpty())
+				})
+
+				It("should record the attestation of the registered device", func() {
+					// given
+					deviceAttestation := &v1alpha1.DeviceAttestation{EndorsementKeyCertificate: "fingerprint", PCRs: map[string]string{"0": "00"}}
+					edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), deviceName, testNamespace).Return(nil, errorNotFound)
+					verifierMock.EXPECT().Verify(gomock.Any(), deviceName, "", givenInfo).Return(deviceAttestation, nil, nil)
+					edgeDeviceRepoMock.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil)
+					edgeDeviceRepoMock.EXPECT().PatchStatus(gomock.Any(), gomock.Any(), gomock.Any()).
 						Do(func(ctx context.Context, edgeDevice *v1alpha1.EdgeDevice, patch *client.Patch) {
-							Expect(edgeDevice.Name).To(Equal(deviceName))
-							Expect(edgeDevice.Namespace).To(Equal(testNamespace))
-							Expect(edgeDevice.Status.Deployments).To(HaveLen(0))
+							Expect(edgeDevice.Status.Attestation).To(Equal(deviceAttestation))
 						}).
-						Return(fmt.Errorf("Failed")).
-						Times(1)
+						Return(nil)
+					edgeDeviceRepoMock.EXPECT().UpdateLabels(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+					metricsMock.EXPECT().IncEdgeDeviceSuccessfulRegistration()
 
-					edgeDeviceRepoMock.EXPECT().
-						Read(gomock.Any(), deviceName, testNamespace).
-						Return(nil, fmt.Errorf("Failed")).
-						Times(3)
+					// when
+					res := handler.PostDataMessageForDevice(context.TODO(), registrationParams(givenInfo))
 
-					metricsMock.EXPECT().
-						IncEdgeDeviceFailedRegistration().
-						AnyTimes()
+					// then
+					Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
+				})
 
-					params := api.PostDataMessageForDeviceParams{
-						DeviceID: deviceName,
-						Message: &models.Message{
-							Directive: directiveName,
-							Content: models.RegistrationInfo{
-								CertificateRequest: givenCert,
-							},
-						},
-					}
+				It("should reject invalid attestations", func() {
+					// given
+					edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), deviceName, testNamespace).Return(nil, errorNotFound)
+					verifierMock.EXPECT().Verify(gomock.Any(), deviceName, "", givenInfo).
+						Return(nil, nil, fmt.Errorf("%w: PCR 0 has an unexpected value", attestation.ErrInvalidAttestation))
+					metricsMock.EXPECT().IncEdgeDeviceFailedRegistration()
 
 					// when
-					res := handler.PostDataMessageForDevice(context.TODO(), params)
+					res := handler.PostDataMessageForDevice(context.TODO(), registrationParams(givenInfo))
+
+					// then
+					Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceUnauthorized{}))
+				})
+
+				It("should fail when the attestation cannot be verified", func() {
+					// given
+					edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), deviceName, testNamespace).Return(nil, errorNotFound)
+					verifierMock.EXPECT().Verify(gomock.Any(), deviceName, "", givenInfo).Return(nil, nil, fmt.Errorf("boom"))
+					metricsMock.EXPECT().IncEdgeDeviceFailedRegistration()
+
+					// when
+					res := handler.PostDataMessageForDevice(context.TODO(), registrationParams(givenInfo))
 
 					// then
 					Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceInternalServerError{}))
 				})
 
+				Context("with a registered attested device", func() {
+					var params api.PostDataMessageForDeviceParams
+
+					BeforeEach(func() {
+						device.Status.Attestation = &v1alpha1.DeviceAttestation{EndorsementKeyCertificate: "fingerprint"}
+						edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), deviceName, testNamespace).Return(device, nil)
+						params = registrationParams(givenInfo)
+						params.Message.Content = models.RegistrationInfo{CertificateRequest: "csr", Attestation: givenInfo}
+					})
+
+					It("should not issue a certificate without attestation", func() {
+						// given
+						params.Message.Content = models.RegistrationInfo{CertificateRequest: "csr"}
+
+						// when
+						res := handler.PostDataMessageForDevice(context.TODO(), params)
+
+						// then
+						Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceUnauthorized{}))
+					})
+
+					It("should challenge the device attesting again", func() {
+						// given
+						challenge := &models.AttestationChallenge{CredentialBlob: "blob", EncryptedSecret: "secret"}
+						verifierMock.EXPECT().Verify(gomock.Any(), deviceName, "csr", givenInfo).Return(nil, challenge, nil)
+
+						// when
+						res := handler.PostDataMessageForDevice(context.TODO(), params)
+
+						// then
+						Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
+						content := res.(*api.PostDataMessageForDeviceOK).Payload.Content.(models.RegistrationResponse)
+						Expect(content.AttestationChallenge).To(Equal(challenge))
+						Expect(content.Certificate).To(BeEmpty())
+					})
+
+					It("should not issue a certificate to another TPM", func() {
+						// given
+						verifierMock.EXPECT().Verify(gomock.Any(), deviceName, "csr", givenInfo).
+							Return(&v1alpha1.DeviceAttestation{EndorsementKeyCertificate: "other"}, nil, nil)
+						metricsMock.EXPECT().IncEdgeDeviceFailedRegistration()
+
+						// when
+						res := handler.PostDataMessageForDevice(context.TODO(), params)
+
+						// then
+						Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceUnauthorized{}))
+					})
+				})
+
+				Context("when attestation is required", func() {
+					BeforeEach(func() {
+						required = true
+					})
+
+					It("should reject new devices without attestation", func() {
+						// given
+						edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), deviceName, testNamespace).Return(nil, errorNotFound)
+						metricsMock.EXPECT().IncEdgeDeviceFailedRegistration()
+
+						// when
+						res := handler.PostDataMessageForDevice(context.TODO(), registrationParams(nil))
+
+						// then
+						Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceUnauthorized{}))
+					})
+
+					It("should accept registered devices without attestation", func() {
+						// given
+						edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), deviceName, testNamespace).Return(device, nil)
+
+						// when
+						res := handler.PostDataMessageForDevice(context.TODO(), registrationParams(nil))
+
+						// then
+						Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
+					})
+				})
 			})
 
 			// @TODO to be deleted, will not work without CSR entry
@@ -2362,5 +4533,123 @@ var _ = Describe("Yggdrasil", func() {
 
 		})
 
+		Context("Audit", func() {
+			var (
+				auditorMock *audit.MockAuditor
+				request     *http.Request
+			)
+
+			BeforeEach(func() {
+				auditorMock = audit.NewMockAuditor(mockCtrl)
+				handler = yggdrasil.NewYggdrasilHandler(edgeDeviceRepoMock, deployRepoMock, deviceSetRepoMock, nil, Mockk8sClient, testNamespace,
+					eventsRecorder, registryAuth, metricsMock, allowListsMock, configMap, nil, nil, nil, nil, nil, auditorMock)
+				cert := &x509.Certificate{SerialNumber: big.NewInt(0x2a), Subject: pkix.Name{CommonName: deviceName}}
+				request = &http.Request{
+					RemoteAddr: "192.0.2.10:51234",
+					TLS:        &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}},
+				}
+			})
+
+			heartbeatParams := func(hb models.Heartbeat) api.PostDataMessageForDeviceParams {
+				return api.PostDataMessageForDeviceParams{
+					HTTPRequest: request,
+					DeviceID:    deviceName,
+					Message:     &models.Message{Directive: "heartbeat", Content: hb},
+				}
+			}
+
+			It("should record the registration of a device", func() {
+				// given
+				edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), deviceName, testNamespace).Return(device, nil)
+				auditorMock.EXPECT().Record(&audit.Entry{
+					Action:            audit.ActionRegistration,
+					DeviceID:          deviceName,
+					Namespace:         testNamespace,
+					CertificateSerial: "2A",
+					RemoteAddress:     "192.0.2.10:51234",
+					Outcome:           audit.OutcomeSuccess,
+					StatusCode:        200,
+					Details:           map[string]string{"registered": "true"},
+				})
+
+				// when
+				res := handler.PostDataMessageForDevice(context.TODO(), api.PostDataMessageForDeviceParams{
+					HTTPRequest: request,
+					DeviceID:    deviceName,
+					Message: &models.Message{
+						Directive: "registration",
+						Content:   models.RegistrationInfo{Hardware: &models.HardwareInfo{Hostname: "fooHostname"}},
+					},
+				})
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
+			})
+
+			It("should record configuration requests of unknown devices as rejected", func() {
+				// given
+				edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), deviceName, testNamespace).Return(nil, errorNotFound)
+				auditorMock.EXPECT().Record(gomock.Any()).Do(func(entry *audit.Entry) {
+					Expect(entry.Action).To(Equal(audit.ActionConfiguration))
+					Expect(entry.Outcome).To(Equal(audit.OutcomeRejected))
+					Expect(entry.StatusCode).To(Equal(404))
+					Expect(entry.CertificateSerial).To(Equal("2A"))
+				})
+
+				// when
+				res := handler.GetDataMessageForDevice(context.TODO(), api.GetDataMessageForDeviceParams{
+					HTTPRequest: request,
+					DeviceID:    deviceName,
+				})
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&api.GetDataMessageForDeviceNotFound{}))
+			})
+
+			It("should record heartbeats changing the status of the device", func() {
+				// given
+				edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), deviceName, testNamespace).Return(device, nil).Times(2)
+				edgeDeviceRepoMock.EXPECT().PatchStatus(gomock.Any(), device, gomock.Any()).Return(nil)
+				edgeDeviceRepoMock.EXPECT().UpdateLabels(gomock.Any(), device, gomock.Any()).Return(nil)
+				auditorMock.EXPECT().Record(gomock.Any()).Do(func(entry *audit.Entry) {
+					Expect(entry.Action).To(Equal(audit.ActionHeartbeat))
+					Expect(entry.Outcome).To(Equal(audit.OutcomeSuccess))
+					Expect(entry.Details).To(HaveKeyWithValue("changes", `phase "" -> "up"`))
+				})
+
+				// when
+				res := handler.PostDataMessageForDevice(context.TODO(), heartbeatParams(models.Heartbeat{Status: "up"}))
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
+			})
+
+			It("should not record heartbeats leaving the status of the device unchanged", func() {
+				// given
+				device.Status.Phase = "up"
+				device.Status.LastSyncedResourceVersion = "1"
+				edgeDeviceRepoMock.EXPECT().Read(gomock.Any(), deviceName, testNamespace).Return(device, nil).Times(2)
+				edgeDeviceRepoMock.EXPECT().PatchStatus(gomock.Any(), device, gomock.Any()).Return(nil)
+				edgeDeviceRepoMock.EXPECT().UpdateLabels(gomock.Any(), device, gomock.Any()).Return(nil)
+
+				// when
+				res := handler.PostDataMessageForDevice(context.TODO(), heartbeatParams(models.Heartbeat{Status: "up", Version: "1"}))
+
+				// then
+				Expect(res).To(BeAssignableToTypeOf(&api.PostDataMessageForDeviceOK{}))
+			})
+		})
+
 	})
 })
+
+// caProviderStub signs certificate requests with a fixed certificate
+type caProviderStub struct {
+	mtls.CAProvider
+	certificate []byte
+	err         error
+}
+
+func (p *caProviderStub) SignCSR(CSRPem string, commonName string, namespace string, expiration time.Time) ([]byte, error) {
+	return p.certificate, p.err
+}
@@ -18,6 +18,7 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"crypto/x509"
 	"fmt"
 	"io/ioutil"
@@ -25,8 +26,13 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/project-flotta/flotta-operator/internal/admin"
+	"github.com/project-flotta/flotta-operator/internal/attestation"
+	"github.com/project-flotta/flotta-operator/internal/audit"
 	"github.com/project-flotta/flotta-operator/internal/configmaps"
+	"github.com/project-flotta/flotta-operator/internal/devicelogs"
 	"github.com/project-flotta/flotta-operator/internal/devicemetrics"
 
 	"github.com/kelseyhightower/envconfig"
@@ -35,8 +41,15 @@ import (
 	"github.com/project-flotta/flotta-operator/internal/k8sclient"
 	"github.com/project-flotta/flotta-operator/internal/metrics"
 	"github.com/project-flotta/flotta-operator/internal/mtls"
+	"github.com/project-flotta/flotta-operator/internal/ratelimit"
 	"github.com/project-flotta/flotta-operator/internal/repository/edgedeployment"
 	"github.com/project-flotta/flotta-operator/internal/repository/edgedevice"
+	"github.com/project-flotta/flotta-operator/internal/repository/edgedevicemigration"
+	"github.com/project-flotta/flotta-operator/internal/repository/edgedeviceset"
+	"github.com/project-flotta/flotta-operator/internal/repository/metricsallowlist"
+	"github.com/project-flotta/flotta-operator/internal/repository/registrationtoken"
+	"github.com/project-flotta/flotta-operator/internal/repository/site"
+	"github.com/project-flotta/flotta-operator/internal/selectorindex"
 	"github.com/project-flotta/flotta-operator/internal/storage"
 	"github.com/project-flotta/flotta-operator/internal/yggdrasil"
 	"github.com/project-flotta/flotta-operator/restapi"
@@ -69,6 +82,9 @@ const (
 	defaultOperatorNamespace = "flotta"
 	defaultConfigMapName     = "flotta-operator-manager-config"
 	logLevelLabel            = "LOG_LEVEL"
+
+	// gracefulShutdownTimeout is how long the manager waits for its runnables, like the audit trail, to stop
+	gracefulShutdownTimeout = 30 * time.Second
 )
 
 var (
@@ -83,12 +99,15 @@ var (
 var Config struct {
 
 	// NOTE DEPRECATE
-	// The port of the HTTP server
+	// The port of the HTTP server; its requests are neither authenticated nor rate limited
 	HttpPort uint16 `envconfig:"HTTP_PORT" default:"8888"`
 
 	// The port of the HTTPs server
 	HttpsPort uint16 `envconfig:"HTTPS_PORT" default:"8043"`
 
+	// The port of the HTTPs server of the admin API, authenticating users with Kubernetes tokens
+	AdminPort uint16 `envconfig:"ADMIN_PORT" default:"8090"`
+
 	// Domain where TLS certificate listen.
 	// FIXME check default here
 	Domain string `envconfig:"DOMAIN" default:"project-flotta.io"`
@@ -122,6 +141,77 @@ var Config struct {
 
 	// MaxConcurrentReconciles is the maximum number of concurrent Reconciles which can be run
 	MaxConcurrentReconciles uint `envconfig:"MAX_CONCURRENT_RECONCILES" default:"3"`
+
+	// Time during which changes to the objects referred to by device configurations are collected before the
+	// configurations are recomputed
+	ConfigurationDebounceSeconds uint `envconfig:"CONFIGURATION_DEBOUNCE_SECONDS" default:"5"`
+
+	// Time after which a device that has not applied its desired configuration gets a warning event; 0 disables it
+	ConfigurationOutOfSyncThresholdSeconds uint `envconfig:"CONFIGURATION_OUT_OF_SYNC_THRESHOLD_SECONDS" default:"900"`
+
+	// The address the endpoint exposing the metrics sent by devices for Prometheus federation binds to; empty disables
+	// the ingestion of device metrics. The endpoint is served over plain HTTP without authentication.
+	DeviceMetricsAddr string `envconfig:"DEVICE_METRICS_ADDR" default:""`
+
+	// Time the metrics sent by a device are exposed after the last batch of the device
+	DeviceMetricsRetentionSeconds uint `envconfig:"DEVICE_METRICS_RETENTION_SECONDS" default:"300"`
+
+	// Number of metrics batches each device can send per minute; 0 does not limit them
+	DeviceMetricsBatchesPerMinute uint `envconfig:"DEVICE_METRICS_BATCHES_PER_MINUTE" default:"6"`
+
+	// Number of series exposed for each device; 0 does not limit them
+	DeviceMetricsMaxSeries uint `envconfig:"DEVICE_METRICS_MAX_SERIES" default:"5000"`
+
+	// The sink the logs sent by devices with the yggdrasil log collection are forwarded to: syslog, loki or file;
+	// empty disables the forwarding of device logs
+	DeviceLogsSink string `envconfig:"DEVICE_LOGS_SINK" default:""`
+
+	// The host:port of the syslog server of the syslog sink
+	DeviceLogsSyslogAddress string `envconfig:"DEVICE_LOGS_SYSLOG_ADDRESS" default:""`
+
+	// The protocol used to reach the syslog server of the syslog sink: tcp or udp
+	DeviceLogsSyslogProtocol string `envconfig:"DEVICE_LOGS_SYSLOG_PROTOCOL" default:"tcp"`
+
+	// The base URL of the Loki server of the loki sink
+	DeviceLogsLokiURL string `envconfig:"DEVICE_LOGS_LOKI_URL" default:""`
+
+	// The directory the file sink writes the logs to, usually the mount point of a PVC
+	DeviceLogsDirectory string `envconfig:"DEVICE_LOGS_DIRECTORY" default:""`
+
+	// The number of requests per minute each device, identified by the device ID and the namespace of its client
+	// certificate, can send to the device API; 0 does not limit the requests
+	DeviceRequestsPerMinute uint `envconfig:"DEVICE_REQUESTS_PER_MINUTE" default:"120"`
+
+	// The number of requests each device can send at once to the device API
+	DeviceRequestsBurst uint `envconfig:"DEVICE_REQUESTS_BURST" default:"20"`
+
+	// The number of registration requests per minute from each source IP; 0 does not limit the requests
+	RegistrationRequestsPerMinute uint `envconfig:"REGISTRATION_REQUESTS_PER_MINUTE" default:"10"`
+
+	// The number of registration requests at once from each source IP
+	RegistrationRequestsBurst uint `envconfig:"REGISTRATION_REQUESTS_BURST" default:"10"`
+
+	// Reject the registration of new devices not presenting a RegistrationToken
+	RegistrationTokenRequired bool `envconfig:"REGISTRATION_TOKEN_REQUIRED" default:"false"`
+
+	// The ConfigMap, in the namespace of the operator, holding the TPM manufacturer CAs and the PCR values registering
+	// devices are attested with; empty disables the TPM attestation of devices
+	AttestationPolicyConfigMap string `envconfig:"ATTESTATION_POLICY_CONFIGMAP" default:""`
+
+	// Reject the registration of new devices not presenting a TPM attestation
+	AttestationRequired bool `envconfig:"ATTESTATION_REQUIRED" default:"false"`
+
+	// The sink the audit trail of the device API is written to: stdout, file or webhook; empty disables the audit trail
+	AuditSink string `envconfig:"AUDIT_SINK" default:""`
+
+	// The file the file sink appends the audit entries to, as JSON lines
+	AuditFile string `envconfig:"AUDIT_FILE" default:""`
+
+	// The URL the webhook sink posts the audit entries to, as JSON arrays
+	AuditWebhookURL string `envconfig:"AUDIT_WEBHOOK_URL" default:""`
+
+	// One of every AUDIT_HEARTBEAT_SAMPLING heartbeats changing the status of a device is audited; 0 audits none
+	AuditHeartbeatSampling uint `envconfig:"AUDIT_HEARTBEAT_SAMPLING" default:"1"`
 }
 
 func init() {
@@ -172,13 +262,15 @@ func main() {
 		os.Exit(1)
 	}
 	r.RateLimiter = flowcontrol.NewTokenBucketRateLimiter(100, 1000)
+	shutdownTimeout := gracefulShutdownTimeout
 	mgr, err := ctrl.NewManager(r, ctrl.Options{
-		Scheme:                 scheme,
-		MetricsBindAddress:     Config.MetricsAddr,
-		Port:                   Config.WebhookPort,
-		HealthProbeBindAddress: Config.ProbeAddr,
-		LeaderElection:         Config.EnableLeaderElection,
-		LeaderElectionID:       "b9eebab3.project-flotta.io",
+		Scheme:                  scheme,
+		MetricsBindAddress:      Config.MetricsAddr,
+		Port:                    Config.WebhookPort,
+		HealthProbeBindAddress:  Config.ProbeAddr,
+		LeaderElection:          Config.EnableLeaderElection,
+		LeaderElectionID:        "b9eebab3.project-flotta.io",
+		GracefulShutdownTimeout: &shutdownTimeout,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
@@ -188,23 +280,33 @@ func main() {
 	edgeDeviceRepository := edgedevice.NewEdgeDeviceRepository(mgr.GetClient())
 	edgeDeploymentRepository := edgedeployment.NewEdgeDeploymentRepository(mgr.GetClient())
 	claimer := storage.NewClaimer(mgr.GetClient())
+	revocations := mtls.NewRevocationList(mgr.GetClient(), operatorNamespace, initialDeviceNamespace,
+		time.Duration(Config.ClientCertExpirationTime)*24*time.Hour)
 	metricsObj := metrics.New()
+	selectorIndex := selectorindex.New()
+	if err = selectorIndex.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to set up EdgeDeployment selector index")
+		os.Exit(1)
+	}
 
 	if err = (&controllers.EdgeDeviceReconciler{
-		Client:                  mgr.GetClient(),
-		Scheme:                  mgr.GetScheme(),
-		EdgeDeviceRepository:    edgeDeviceRepository,
-		Claimer:                 claimer,
-		ObcAutoCreate:           Config.EnableObcAutoCreation,
-		MaxConcurrentReconciles: int(Config.MaxConcurrentReconciles),
+		Client:                   mgr.GetClient(),
+		Scheme:                   mgr.GetScheme(),
+		EdgeDeviceRepository:     edgeDeviceRepository,
+		EdgeDeploymentRepository: edgeDeploymentRepository,
+		Claimer:                  claimer,
+		Revocations:              revocations,
+		Recorder:                 mgr.GetEventRecorderFor("edgedevice-controller"),
+		ObcAutoCreate:            Config.EnableObcAutoCreation,
+		MaxConcurrentReconciles:  int(Config.MaxConcurrentReconciles),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "EdgeDevice")
 		os.Exit(1)
 	}
 	if err = (&controllers.EdgeDeviceLabelsReconciler{
-		EdgeDeviceRepository:     edgeDeviceRepository,
-		EdgeDeploymentRepository: edgeDeploymentRepository,
-		MaxConcurrentReconciles:  int(Config.MaxConcurrentReconciles),
+		EdgeDeviceRepository:    edgeDeviceRepository,
+		SelectorIndex:           selectorIndex,
+		MaxConcurrentReconciles: int(Config.MaxConcurrentReconciles),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "EdgeDeviceLabels")
 		os.Exit(1)
@@ -217,11 +319,88 @@ func main() {
 		Concurrency:              Config.EdgeDeploymentConcurrency,
 		ExecuteConcurrent:        controllers.ExecuteConcurrent,
 		Metrics:                  metricsObj,
+		SelectorIndex:            selectorIndex,
 		MaxConcurrentReconciles:  int(Config.MaxConcurrentReconciles),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "EdgeDeployment")
 		os.Exit(1)
 	}
+	if err = (&controllers.EdgeDeviceMigrationReconciler{
+		EdgeDeviceMigrationRepository: edgedevicemigration.NewEdgeDeviceMigrationRepository(mgr.GetClient()),
+		EdgeDeviceRepository:          edgeDeviceRepository,
+		EdgeDeploymentRepository:      edgeDeploymentRepository,
+		SelectorIndex:                 selectorIndex,
+		Revocations:                   revocations,
+		Claimer:                       claimer,
+		Recorder:                      mgr.GetEventRecorderFor("edgedevicemigration-controller"),
+		MaxConcurrentReconciles:       int(Config.MaxConcurrentReconciles),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "EdgeDeviceMigration")
+		os.Exit(1)
+	}
+	configurationK8sClient := k8sclient.NewK8sClient(mgr.GetClient())
+	configurationRecorder := mgr.GetEventRecorderFor("edgedeviceconfiguration-controller")
+	if err = (&controllers.EdgeDeviceConfigurationReconciler{
+		Client:               mgr.GetClient(),
+		EdgeDeviceRepository: edgeDeviceRepository,
+		// the configuration is rendered the way it is when the device requests it
+		Renderer: yggdrasil.NewYggdrasilHandler(
+			edgeDeviceRepository,
+			edgeDeploymentRepository,
+			edgedeviceset.NewEdgeDeviceSetRepository(mgr.GetClient()),
+			claimer,
+			configurationK8sClient,
+			initialDeviceNamespace,
+			configurationRecorder,
+			images.NewRegistryAuth(mgr.GetClient()),
+			metricsObj,
+			devicemetrics.NewAllowListGenerator(configurationK8sClient),
+			configmaps.NewConfigMap(configurationK8sClient),
+			nil,
+			nil,
+			nil,
+			nil,
+			nil,
+			nil,
+		),
+		Metrics:                 metricsObj,
+		Recorder:                configurationRecorder,
+		Debounce:                time.Duration(Config.ConfigurationDebounceSeconds) * time.Second,
+		OutOfSyncThreshold:      time.Duration(Config.ConfigurationOutOfSyncThresholdSeconds) * time.Second,
+		MaxConcurrentReconciles: int(Config.MaxConcurrentReconciles),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "EdgeDeviceConfiguration")
+		os.Exit(1)
+	}
+	if err = (&controllers.MetricsAllowListReconciler{
+		MetricsAllowListRepository: metricsallowlist.NewMetricsAllowListRepository(mgr.GetClient()),
+		EdgeDeviceRepository:       edgeDeviceRepository,
+		EdgeDeviceSetRepository:    edgedeviceset.NewEdgeDeviceSetRepository(mgr.GetClient()),
+		AllowLists:                 devicemetrics.NewAllowListGenerator(configurationK8sClient),
+		MaxConcurrentReconciles:    int(Config.MaxConcurrentReconciles),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "MetricsAllowList")
+		os.Exit(1)
+	}
+
+	siteRepository := site.NewSiteRepository(mgr.GetClient())
+	if err = (&controllers.EdgeDeviceSiteReconciler{
+		EdgeDeviceRepository:    edgeDeviceRepository,
+		SiteRepository:          siteRepository,
+		MaxConcurrentReconciles: int(Config.MaxConcurrentReconciles),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "EdgeDeviceSite")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.SiteReconciler{
+		SiteRepository:          siteRepository,
+		EdgeDeviceRepository:    edgeDeviceRepository,
+		MaxConcurrentReconciles: int(Config.MaxConcurrentReconciles),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Site")
+		os.Exit(1)
+	}
 
 	// webhooks
 	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
@@ -229,6 +408,14 @@ func main() {
 			setupLog.Error(err, "unable to create webhook", "webhook", "EdgeDeployment")
 			os.Exit(1)
 		}
+		if err = (&v1alpha1.MetricsAllowList{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "MetricsAllowList")
+			os.Exit(1)
+		}
+		if err = (&v1alpha1.Site{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Site")
+			os.Exit(1)
+		}
 	}
 
 	//+kubebuilder:scaffold:builder
@@ -241,6 +428,26 @@ func main() {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+
+	var auditor audit.Auditor
+	if Config.AuditSink != "" {
+		auditSink, err := audit.NewSink(audit.SinkConfig{
+			Kind:       Config.AuditSink,
+			File:       Config.AuditFile,
+			WebhookURL: Config.AuditWebhookURL,
+		})
+		if err != nil {
+			setupLog.Error(err, "Cannot create audit sink")
+			os.Exit(1)
+		}
+		auditTrail := audit.NewTrail(auditSink, Config.AuditHeartbeatSampling, gracefulShutdownTimeout)
+		if err = mgr.Add(auditTrail); err != nil {
+			setupLog.Error(err, "unable to run audit trail")
+			os.Exit(1)
+		}
+		auditor = auditTrail
+	}
+
 	registryAuth := images.NewRegistryAuth(mgr.GetClient())
 	go func() {
 
@@ -277,9 +484,54 @@ func main() {
 
 		k8sClient := k8sclient.NewK8sClient(mgr.GetClient())
 
+		var metricsIngester devicemetrics.Ingester
+		if Config.DeviceMetricsAddr != "" {
+			metricsStore := devicemetrics.NewStore(devicemetrics.IngestionConfig{
+				Retention:        time.Duration(Config.DeviceMetricsRetentionSeconds) * time.Second,
+				BatchesPerMinute: Config.DeviceMetricsBatchesPerMinute,
+				MaxSeries:        Config.DeviceMetricsMaxSeries,
+			})
+			metricsIngester = metricsStore
+			metricsMux := http.NewServeMux()
+			metricsMux.Handle("/metrics", metricsStore.Handler())
+			go func() {
+				log.Fatal(http.ListenAndServe(Config.DeviceMetricsAddr, metricsMux))
+			}()
+		}
+
+		var logsForwarder devicelogs.Forwarder
+		if Config.DeviceLogsSink != "" {
+			logsForwarder, err = devicelogs.NewForwarder(devicelogs.SinkConfig{
+				Kind:           Config.DeviceLogsSink,
+				SyslogAddress:  Config.DeviceLogsSyslogAddress,
+				SyslogProtocol: Config.DeviceLogsSyslogProtocol,
+				LokiURL:        Config.DeviceLogsLokiURL,
+				Directory:      Config.DeviceLogsDirectory,
+			})
+			if err != nil {
+				setupLog.Error(err, "Cannot create device logs sink")
+				os.Exit(1)
+			}
+		}
+
+		var deviceAttestation *yggdrasil.Attestation
+		if Config.AttestationPolicyConfigMap != "" {
+			credentialKey, err := attestation.CredentialKey(context.TODO(), mgr.GetClient(), operatorNamespace)
+			if err != nil {
+				setupLog.Error(err, "Cannot get attestation credential key")
+				os.Exit(1)
+			}
+			verifier := attestation.NewTPMVerifier(k8sClient, operatorNamespace, Config.AttestationPolicyConfigMap, credentialKey)
+			deviceAttestation = &yggdrasil.Attestation{Verifier: verifier, Required: Config.AttestationRequired}
+		} else if Config.AttestationRequired {
+			setupLog.Error(fmt.Errorf("no attestation policy"), "ATTESTATION_REQUIRED needs ATTESTATION_POLICY_CONFIGMAP")
+			os.Exit(1)
+		}
+
 		yggdrasilAPIHandler := yggdrasil.NewYggdrasilHandler(
 			edgeDeviceRepository,
 			edgeDeploymentRepository,
+			edgedeviceset.NewEdgeDeviceSetRepository(mgr.GetClient()),
 			claimer,
 			k8sClient,
 			initialDeviceNamespace,
@@ -289,11 +541,37 @@ func main() {
 			devicemetrics.NewAllowListGenerator(k8sClient),
 			configmaps.NewConfigMap(k8sClient),
 			mtlsConfig,
+			metricsIngester,
+			logsForwarder,
+			&yggdrasil.RegistrationTokens{
+				Repository: registrationtoken.NewRegistrationTokenRepository(mgr.GetClient()),
+				Namespace:  operatorNamespace,
+				Required:   Config.RegistrationTokenRequired,
+			},
+			deviceAttestation,
+			auditor,
 		)
 
+		rateLimiter := ratelimit.New(ratelimit.Config{
+			Device: ratelimit.Limits{
+				PerMinute: Config.DeviceRequestsPerMinute,
+				Burst:     Config.DeviceRequestsBurst,
+			},
+			Registration: ratelimit.Limits{
+				PerMinute: Config.RegistrationRequestsPerMinute,
+				Burst:     Config.RegistrationRequestsBurst,
+			},
+		}, metricsObj)
+		isRegistration := func(r *http.Request) bool {
+			return yggdrasilAPIHandler.GetAuthType(r) == yggdrasil.YggdrasilRegisterAuth || mtls.IsRegistrationRequest(r)
+		}
+
 		h, err := restapi.Handler(restapi.Config{
 			YggdrasilAPI: yggdrasilAPIHandler,
 			InnerMiddleware: func(h http.Handler) http.Handler {
+				// requests are rate limited once their client certificate is verified, so that the limits are
+				// keyed by authenticated identities
+				h = rateLimiter.Middleware(isRegistration)(h)
 				// This is needed for one reason. Registration endpoint can be
 				// triggered with a certificate signed by the CA, but can be expired
 				// The main reason to allow expired certificates in this endpoint, it's
@@ -306,6 +584,15 @@ func main() {
 							w.WriteHeader(http.StatusUnauthorized)
 							return
 						}
+						revoked, err := revocations.IsRevoked(r.Context(), r.TLS.PeerCertificates)
+						if err != nil {
+							w.WriteHeader(http.StatusInternalServerError)
+							return
+						}
+						if revoked {
+							w.WriteHeader(http.StatusUnauthorized)
+							return
+						}
 					}
 					h.ServeHTTP(w, r)
 				})
@@ -321,6 +608,18 @@ func main() {
 			_ = http.ListenAndServe(fmt.Sprintf(":%v", Config.HttpPort), h)
 		}()
 
+		// admin users authenticate with their Kubernetes token instead of a client certificate
+		adminTLSConfig := tlsConfig.Clone()
+		adminTLSConfig.ClientAuth = tls.NoClientCert
+		adminServer := &http.Server{
+			Addr:      fmt.Sprintf(":%v", Config.AdminPort),
+			TLSConfig: adminTLSConfig,
+			Handler:   admin.NewHandler(yggdrasilAPIHandler, admin.NewKubernetesReviewer(mgr.GetClient())),
+		}
+		go func() {
+			log.Fatal(adminServer.ListenAndServeTLS("", ""))
+		}()
+
 		server := &http.Server{
 			Addr:      fmt.Sprintf(":%v", Config.HttpsPort),
 			TLSConfig: tlsConfig,
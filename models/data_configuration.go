@@ -6,11 +6,13 @@ package models
 // Editing this file might prove futile when you re-run the swagger generate command
 
 import (
+	"encoding/json"
 	"strconv"
 
 	"github.com/go-openapi/errors"
 	"github.com/go-openapi/strfmt"
 	"github.com/go-openapi/swag"
+	"github.com/go-openapi/validate"
 )
 
 // DataConfiguration data configuration
@@ -18,24 +20,97 @@ import (
 // swagger:model data-configuration
 type DataConfiguration struct {
 
+	// Prefix prepended to all targets in the device bucket
+	BucketPrefix string `json:"bucket_prefix,omitempty"`
+
+	// Compression applied to files before upload
+	// Enum: [none gzip]
+	Compression string `json:"compression,omitempty"`
+
+	// Delete files from the device once they were uploaded successfully
+	DeleteAfterUpload bool `json:"delete_after_upload,omitempty"`
+
+	// Glob patterns of files that are never uploaded
+	Exclude []string `json:"exclude"`
+
+	// Glob patterns of files to upload; all files are uploaded when empty
+	Include []string `json:"include"`
+
+	// Maximum size of data uploaded in a single run
+	MaxSizeMib int32 `json:"max_size_mib,omitempty"`
+
 	// paths
 	Paths []*DataPath `json:"paths"`
+
+	// Schedule of the data upload
+	Schedule *DataUploadSchedule `json:"schedule,omitempty"`
 }
 
 // Validate validates this data configuration
 func (m *DataConfiguration) Validate(formats strfmt.Registry) error {
 	var res []error
 
+	if err := m.validateCompression(formats); err != nil {
+		res = append(res, err)
+	}
+
 	if err := m.validatePaths(formats); err != nil {
 		res = append(res, err)
 	}
 
+	if err := m.validateSchedule(formats); err != nil {
+		res = append(res, err)
+	}
+
 	if len(res) > 0 {
 		return errors.CompositeValidationError(res...)
 	}
 	return nil
 }
 
+var dataConfigurationTypeCompressionPropEnum []interface{}
+
+func init() {
+	var res []string
+	if err := json.Unmarshal([]byte(`["none","gzip"]`), &res); err != nil {
+		panic(err)
+	}
+	for _, v := range res {
+		dataConfigurationTypeCompressionPropEnum = append(dataConfigurationTypeCompressionPropEnum, v)
+	}
+}
+
+const (
+
+	// DataConfigurationCompressionNone captures enum value "none"
+	DataConfigurationCompressionNone string = "none"
+
+	// DataConfigurationCompressionGzip captures enum value "gzip"
+	DataConfigurationCompressionGzip string = "gzip"
+)
+
+// prop value enum
+func (m *DataConfiguration) validateCompressionEnum(path, location string, value string) error {
+	if err := validate.EnumCase(path, location, value, dataConfigurationTypeCompressionPropEnum, true); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *DataConfiguration) validateCompression(formats strfmt.Registry) error {
+
+	if swag.IsZero(m.Compression) { // not required
+		return nil
+	}
+
+	// value enum
+	if err := m.validateCompressionEnum("compression", "body", m.Compression); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (m *DataConfiguration) validatePaths(formats strfmt.Registry) error {
 
 	if swag.IsZero(m.Paths) { // not required
@@ -61,6 +136,24 @@ func (m *DataConfiguration) validatePaths(formats strfmt.Registry) error {
 	return nil
 }
 
+func (m *DataConfiguration) validateSchedule(formats strfmt.Registry) error {
+
+	if swag.IsZero(m.Schedule) { // not required
+		return nil
+	}
+
+	if m.Schedule != nil {
+		if err := m.Schedule.Validate(formats); err != nil {
+			if ve, ok := err.(*errors.Validation); ok {
+				return ve.ValidateName("schedule")
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
 // MarshalBinary interface implementation
 func (m *DataConfiguration) MarshalBinary() ([]byte, error) {
 	if m == nil {
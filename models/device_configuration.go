@@ -17,6 +17,9 @@ import (
 // swagger:model device-configuration
 type DeviceConfiguration struct {
 
+	// Requests the device to upload the data of all workloads ahead of its decommissioning
+	FinalDataUpload bool `json:"final_data_upload,omitempty"`
+
 	// heartbeat
 	Heartbeat *HeartbeatConfiguration `json:"heartbeat,omitempty"`
 
@@ -20,6 +20,9 @@ type DeviceConfigurationMessage struct {
 	// configuration
 	Configuration *DeviceConfiguration `json:"configuration,omitempty"`
 
+	// Desired properties of the device twin
+	DesiredProperties *TwinProperties `json:"desired_properties,omitempty"`
+
 	// Device identifier
 	DeviceID string `json:"device_id,omitempty"`
 
@@ -45,6 +48,10 @@ func (m *DeviceConfigurationMessage) Validate(formats strfmt.Registry) error {
 		res = append(res, err)
 	}
 
+	if err := m.validateDesiredProperties(formats); err != nil {
+		res = append(res, err)
+	}
+
 	if err := m.validateSecrets(formats); err != nil {
 		res = append(res, err)
 	}
@@ -81,6 +88,24 @@ func (m *DeviceConfigurationMessage) validateConfiguration(formats strfmt.Regist
 	return nil
 }
 
+func (m *DeviceConfigurationMessage) validateDesiredProperties(formats strfmt.Registry) error {
+
+	if swag.IsZero(m.DesiredProperties) { // not required
+		return nil
+	}
+
+	if m.DesiredProperties != nil {
+		if err := m.DesiredProperties.Validate(formats); err != nil {
+			if ve, ok := err.(*errors.Validation); ok {
+				return ve.ValidateName("desired_properties")
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (m *DeviceConfigurationMessage) validateSecrets(formats strfmt.Registry) error {
 
 	if swag.IsZero(m.Secrets) { // not required
@@ -20,12 +20,18 @@ import (
 // swagger:model heartbeat
 type Heartbeat struct {
 
+	// Version of the desired properties of the device twin applied by the device
+	DesiredPropertiesVersion string `json:"desired_properties_version,omitempty"`
+
 	// Events produced by device worker.
 	Events []*EventInfo `json:"events"`
 
 	// Hardware information
 	Hardware *HardwareInfo `json:"hardware,omitempty"`
 
+	// Reported properties of the device twin
+	ReportedProperties *TwinProperties `json:"reported_properties,omitempty"`
+
 	// status
 	// Enum: [up degraded]
 	Status string `json:"status,omitempty"`
@@ -52,6 +58,10 @@ func (m *Heartbeat) Validate(formats strfmt.Registry) error {
 		res = append(res, err)
 	}
 
+	if err := m.validateReportedProperties(formats); err != nil {
+		res = append(res, err)
+	}
+
 	if err := m.validateStatus(formats); err != nil {
 		res = append(res, err)
 	}
@@ -113,6 +123,24 @@ func (m *Heartbeat) validateHardware(formats strfmt.Registry) error {
 	return nil
 }
 
+func (m *Heartbeat) validateReportedProperties(formats strfmt.Registry) error {
+
+	if swag.IsZero(m.ReportedProperties) { // not required
+		return nil
+	}
+
+	if m.ReportedProperties != nil {
+		if err := m.ReportedProperties.Validate(formats); err != nil {
+			if ve, ok := err.(*errors.Validation); ok {
+				return ve.ValidateName("reported_properties")
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
 var heartbeatTypeStatusPropEnum []interface{}
 
 func init() {
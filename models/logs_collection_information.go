@@ -6,9 +6,12 @@ package models
 // Editing this file might prove futile when you re-run the swagger generate command
 
 import (
+	"encoding/json"
+
 	"github.com/go-openapi/errors"
 	"github.com/go-openapi/strfmt"
 	"github.com/go-openapi/swag"
+	"github.com/go-openapi/validate"
 )
 
 // LogsCollectionInformation logs collection information
@@ -84,12 +87,150 @@ type LogsCollectionInformationSyslogConfig struct {
 	// address
 	Address string `json:"address,omitempty"`
 
+	// Facility of the messages
+	Facility string `json:"facility,omitempty"`
+
+	// Format of the messages
+	// Enum: [rfc3164 rfc5424]
+	Format string `json:"format,omitempty"`
+
+	// Framing of the messages sent over TCP
+	// Enum: [octet-counting non-transparent]
+	Framing string `json:"framing,omitempty"`
+
 	// protocol
 	Protocol string `json:"protocol,omitempty"`
+
+	// TAG of RFC 3164 messages and APP-NAME of RFC 5424 messages
+	Tag string `json:"tag,omitempty"`
+
+	// tls
+	TLS *SyslogTLSConfiguration `json:"tls,omitempty"`
 }
 
 // Validate validates this logs collection information syslog config
 func (m *LogsCollectionInformationSyslogConfig) Validate(formats strfmt.Registry) error {
+	var res []error
+
+	if err := m.validateFormat(formats); err != nil {
+		res = append(res, err)
+	}
+
+	if err := m.validateFraming(formats); err != nil {
+		res = append(res, err)
+	}
+
+	if err := m.validateTLS(formats); err != nil {
+		res = append(res, err)
+	}
+
+	if len(res) > 0 {
+		return errors.CompositeValidationError(res...)
+	}
+	return nil
+}
+
+var logsCollectionInformationSyslogConfigTypeFormatPropEnum []interface{}
+
+func init() {
+	var res []string
+	if err := json.Unmarshal([]byte(`["rfc3164","rfc5424"]`), &res); err != nil {
+		panic(err)
+	}
+	for _, v := range res {
+		logsCollectionInformationSyslogConfigTypeFormatPropEnum = append(logsCollectionInformationSyslogConfigTypeFormatPropEnum, v)
+	}
+}
+
+const (
+
+	// LogsCollectionInformationSyslogConfigFormatRfc3164 captures enum value "rfc3164"
+	LogsCollectionInformationSyslogConfigFormatRfc3164 string = "rfc3164"
+
+	// LogsCollectionInformationSyslogConfigFormatRfc5424 captures enum value "rfc5424"
+	LogsCollectionInformationSyslogConfigFormatRfc5424 string = "rfc5424"
+)
+
+// prop value enum
+func (m *LogsCollectionInformationSyslogConfig) validateFormatEnum(path, location string, value string) error {
+	if err := validate.EnumCase(path, location, value, logsCollectionInformationSyslogConfigTypeFormatPropEnum, true); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *LogsCollectionInformationSyslogConfig) validateFormat(formats strfmt.Registry) error {
+
+	if swag.IsZero(m.Format) { // not required
+		return nil
+	}
+
+	// value enum
+	if err := m.validateFormatEnum("syslog_config"+"."+"format", "body", m.Format); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+var logsCollectionInformationSyslogConfigTypeFramingPropEnum []interface{}
+
+func init() {
+	var res []string
+	if err := json.Unmarshal([]byte(`["octet-counting","non-transparent"]`), &res); err != nil {
+		panic(err)
+	}
+	for _, v := range res {
+		logsCollectionInformationSyslogConfigTypeFramingPropEnum = append(logsCollectionInformationSyslogConfigTypeFramingPropEnum, v)
+	}
+}
+
+const (
+
+	// LogsCollectionInformationSyslogConfigFramingOctetCounting captures enum value "octet-counting"
+	LogsCollectionInformationSyslogConfigFramingOctetCounting string = "octet-counting"
+
+	// LogsCollectionInformationSyslogConfigFramingNonTransparent captures enum value "non-transparent"
+	LogsCollectionInformationSyslogConfigFramingNonTransparent string = "non-transparent"
+)
+
+// prop value enum
+func (m *LogsCollectionInformationSyslogConfig) validateFramingEnum(path, location string, value string) error {
+	if err := validate.EnumCase(path, location, value, logsCollectionInformationSyslogConfigTypeFramingPropEnum, true); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *LogsCollectionInformationSyslogConfig) validateFraming(formats strfmt.Registry) error {
+
+	if swag.IsZero(m.Framing) { // not required
+		return nil
+	}
+
+	// value enum
+	if err := m.validateFramingEnum("syslog_config"+"."+"framing", "body", m.Framing); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *LogsCollectionInformationSyslogConfig) validateTLS(formats strfmt.Registry) error {
+
+	if swag.IsZero(m.TLS) { // not required
+		return nil
+	}
+
+	if m.TLS != nil {
+		if err := m.TLS.Validate(formats); err != nil {
+			if ve, ok := err.(*errors.Validation); ok {
+				return ve.ValidateName("syslog_config" + "." + "tls")
+			}
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -17,6 +17,9 @@ type MetricsAllowList struct {
 
 	// names
 	Names []string `json:"names"`
+
+	// Regular expressions matching the whole names of the metrics to be collected, in addition to the names
+	Patterns []string `json:"patterns"`
 }
 
 // Validate validates this metrics allow list
@@ -16,17 +16,27 @@ import (
 // swagger:model registration-info
 type RegistrationInfo struct {
 
+	// TPM attestation of the device
+	Attestation *Attestation `json:"attestation,omitempty"`
+
 	// Certificate Signing Request to be signed by flotta-operator CA
 	CertificateRequest string `json:"certificate_request,omitempty"`
 
 	// Hardware information
 	Hardware *HardwareInfo `json:"hardware,omitempty"`
+
+	// Registration token, <token name>.<secret>, consumed by the registration of a new device
+	Token string `json:"token,omitempty"`
 }
 
 // Validate validates this registration info
 func (m *RegistrationInfo) Validate(formats strfmt.Registry) error {
 	var res []error
 
+	if err := m.validateAttestation(formats); err != nil {
+		res = append(res, err)
+	}
+
 	if err := m.validateHardware(formats); err != nil {
 		res = append(res, err)
 	}
@@ -37,6 +47,24 @@ func (m *RegistrationInfo) Validate(formats strfmt.Registry) error {
 	return nil
 }
 
+func (m *RegistrationInfo) validateAttestation(formats strfmt.Registry) error {
+
+	if swag.IsZero(m.Attestation) { // not required
+		return nil
+	}
+
+	if m.Attestation != nil {
+		if err := m.Attestation.Validate(formats); err != nil {
+			if ve, ok := err.(*errors.Validation); ok {
+				return ve.ValidateName("attestation")
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (m *RegistrationInfo) validateHardware(formats strfmt.Registry) error {
 
 	if swag.IsZero(m.Hardware) { // not required
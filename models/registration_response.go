@@ -6,6 +6,7 @@ package models
 // Editing this file might prove futile when you re-run the swagger generate command
 
 import (
+	"github.com/go-openapi/errors"
 	"github.com/go-openapi/strfmt"
 	"github.com/go-openapi/swag"
 )
@@ -15,12 +16,42 @@ import (
 // swagger:model registration-response
 type RegistrationResponse struct {
 
+	// Challenge to answer with TPM2_ActivateCredential before the device is registered
+	AttestationChallenge *AttestationChallenge `json:"attestation_challenge,omitempty"`
+
 	// Client certificate to be used in future operations
 	Certificate string `json:"certificate,omitempty"`
 }
 
 // Validate validates this registration response
 func (m *RegistrationResponse) Validate(formats strfmt.Registry) error {
+	var res []error
+
+	if err := m.validateAttestationChallenge(formats); err != nil {
+		res = append(res, err)
+	}
+
+	if len(res) > 0 {
+		return errors.CompositeValidationError(res...)
+	}
+	return nil
+}
+
+func (m *RegistrationResponse) validateAttestationChallenge(formats strfmt.Registry) error {
+
+	if swag.IsZero(m.AttestationChallenge) { // not required
+		return nil
+	}
+
+	if m.AttestationChallenge != nil {
+		if err := m.AttestationChallenge.Validate(formats); err != nil {
+			if ve, ok := err.(*errors.Validation); ok {
+				return ve.ValidateName("attestation_challenge")
+			}
+			return err
+		}
+	}
+
 	return nil
 }
 
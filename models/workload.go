@@ -22,6 +22,9 @@ type Workload struct {
 	// Configuration for data transfer
 	Data *DataConfiguration `json:"data,omitempty"`
 
+	// Names of the workloads that have to be running before this workload is started
+	DependsOn []string `json:"depends_on"`
+
 	// Image registries configuration
 	ImageRegistries *ImageRegistries `json:"imageRegistries,omitempty"`
 
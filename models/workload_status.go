@@ -23,11 +23,14 @@ type WorkloadStatus struct {
 	// Format: date-time
 	LastDataUpload strfmt.DateTime `json:"last_data_upload,omitempty"`
 
+	// Error of the latest data upload; empty when it succeeded
+	LastDataUploadError string `json:"last_data_upload_error,omitempty"`
+
 	// name
 	Name string `json:"name,omitempty"`
 
 	// status
-	// Enum: [deploying running crashed stopped]
+	// Enum: [deploying running crashed stopped unhealthy]
 	Status string `json:"status,omitempty"`
 }
 
@@ -66,7 +69,7 @@ var workloadStatusTypeStatusPropEnum []interface{}
 
 func init() {
 	var res []string
-	if err := json.Unmarshal([]byte(`["deploying","running","crashed","stopped"]`), &res); err != nil {
+	if err := json.Unmarshal([]byte(`["deploying","running","crashed","stopped","unhealthy"]`), &res); err != nil {
 		panic(err)
 	}
 	for _, v := range res {
@@ -87,6 +90,9 @@ const (
 
 	// WorkloadStatusStatusStopped captures enum value "stopped"
 	WorkloadStatusStatusStopped string = "stopped"
+
+	// WorkloadStatusStatusUnhealthy captures enum value "unhealthy"
+	WorkloadStatusStatusUnhealthy string = "unhealthy"
 )
 
 // prop value enum
@@ -198,6 +198,9 @@ func init() {
           "404": {
             "description": "Error"
           },
+          "429": {
+            "description": "Too many requests"
+          },
           "500": {
             "description": "Error"
           }
@@ -206,6 +209,51 @@ func init() {
     }
   },
   "definitions": {
+    "attestation": {
+      "type": "object",
+      "properties": {
+        "activated_credential": {
+          "description": "Base64 encoded credential recovered from the attestation challenge with TPM2_ActivateCredential",
+          "type": "string"
+        },
+        "attestation_key": {
+          "description": "Base64 encoded TPMT_PUBLIC structure of the attestation key",
+          "type": "string"
+        },
+        "ek_certificate": {
+          "description": "PEM encoded endorsement key certificate of the TPM, followed by its intermediate CA certificates",
+          "type": "string"
+        },
+        "pcrs": {
+          "description": "Hex encoded values of the quoted SHA-256 PCRs, by index",
+          "type": "object",
+          "additionalProperties": {
+            "type": "string"
+          }
+        },
+        "quote": {
+          "description": "Base64 encoded TPMS_ATTEST structure of a quote of the SHA-256 PCRs by the attestation key",
+          "type": "string"
+        },
+        "quote_signature": {
+          "description": "Base64 encoded TPMT_SIGNATURE structure of the quote",
+          "type": "string"
+        }
+      }
+    },
+    "attestation-challenge": {
+      "type": "object",
+      "properties": {
+        "credential_blob": {
+          "description": "Base64 encoded TPM2B_ID_OBJECT credential blob to pass to TPM2_ActivateCredential",
+          "type": "string"
+        },
+        "encrypted_secret": {
+          "description": "Base64 encoded TPM2B_ENCRYPTED_SECRET to pass to TPM2_ActivateCredential",
+          "type": "string"
+        }
+      }
+    },
     "boot": {
       "type": "object",
       "properties": {
@@ -267,11 +315,50 @@ func init() {
     "data-configuration": {
       "type": "object",
       "properties": {
+        "bucket_prefix": {
+          "description": "Prefix prepended to all targets in the device bucket",
+          "type": "string"
+        },
+        "compression": {
+          "description": "Compression applied to files before upload",
+          "type": "string",
+          "enum": [
+            "none",
+            "gzip"
+          ]
+        },
+        "delete_after_upload": {
+          "description": "Delete files from the device once they were uploaded successfully",
+          "type": "boolean"
+        },
+        "exclude": {
+          "description": "Glob patterns of files that are never uploaded",
+          "type": "array",
+          "items": {
+            "type": "string"
+          }
+        },
+        "include": {
+          "description": "Glob patterns of files to upload; all files are uploaded when empty",
+          "type": "array",
+          "items": {
+            "type": "string"
+          }
+        },
+        "max_size_mib": {
+          "description": "Maximum size of data uploaded in a single run",
+          "type": "integer",
+          "format": "int32"
+        },
         "paths": {
           "type": "array",
           "items": {
             "$ref": "#/definitions/data-path"
           }
+        },
+        "schedule": {
+          "description": "Schedule of the data upload",
+          "$ref": "#/definitions/data-upload-schedule"
         }
       }
     },
@@ -289,9 +376,27 @@ func init() {
         }
       }
     },
+    "data-upload-schedule": {
+      "type": "object",
+      "properties": {
+        "cron": {
+          "description": "Cron expression defining upload times",
+          "type": "string"
+        },
+        "interval": {
+          "description": "Interval(in seconds) between uploads",
+          "type": "integer",
+          "format": "int32"
+        }
+      }
+    },
     "device-configuration": {
       "type": "object",
       "properties": {
+        "final_data_upload": {
+          "description": "Requests the device to upload the data of all workloads ahead of its decommissioning",
+          "type": "boolean"
+        },
         "heartbeat": {
           "$ref": "#/definitions/heartbeat-configuration"
         },
@@ -320,6 +425,10 @@ func init() {
         "configuration": {
           "$ref": "#/definitions/device-configuration"
         },
+        "desired_properties": {
+          "description": "Desired properties of the device twin",
+          "$ref": "#/definitions/twin-properties"
+        },
         "device_id": {
           "description": "Device identifier",
           "type": "string"
@@ -501,6 +610,10 @@ func init() {
     "heartbeat": {
       "type": "object",
       "properties": {
+        "desired_properties_version": {
+          "description": "Version of the desired properties of the device twin applied by the device",
+          "type": "string"
+        },
         "events": {
           "description": "Events produced by device worker.",
           "type": "array",
@@ -512,6 +625,10 @@ func init() {
           "description": "Hardware information",
           "$ref": "#/definitions/hardware-info"
         },
+        "reported_properties": {
+          "description": "Reported properties of the device twin",
+          "$ref": "#/definitions/twin-properties"
+        },
         "status": {
           "type": "string",
           "enum": [
@@ -613,6 +730,20 @@ func init() {
         }
       }
     },
+    "log-entry": {
+      "type": "object",
+      "properties": {
+        "message": {
+          "description": "Log line",
+          "type": "string"
+        },
+        "timestamp": {
+          "description": "Time the line was logged",
+          "type": "string",
+          "format": "date-time"
+        }
+      }
+    },
     "logs-collection-information": {
       "type": "object",
       "properties": {
@@ -629,13 +760,55 @@ func init() {
             "address": {
               "type": "string"
             },
+            "facility": {
+              "description": "Facility of the messages",
+              "type": "string"
+            },
+            "format": {
+              "description": "Format of the messages",
+              "type": "string",
+              "enum": [
+                "rfc3164",
+                "rfc5424"
+              ]
+            },
+            "framing": {
+              "description": "Framing of the messages sent over TCP",
+              "type": "string",
+              "enum": [
+                "octet-counting",
+                "non-transparent"
+              ]
+            },
             "protocol": {
               "type": "string"
+            },
+            "tag": {
+              "description": "TAG of RFC 3164 messages and APP-NAME of RFC 5424 messages",
+              "type": "string"
+            },
+            "tls": {
+              "$ref": "#/definitions/syslog-tls-configuration"
             }
           }
         }
       }
     },
+    "logs-message": {
+      "type": "object",
+      "properties": {
+        "entries": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/log-entry"
+          }
+        },
+        "workload": {
+          "description": "Workload the logs are collected from, empty for the logs of the device",
+          "type": "string"
+        }
+      }
+    },
     "memory": {
       "type": "object",
       "properties": {
@@ -735,6 +908,13 @@ func init() {
           "items": {
             "type": "string"
           }
+        },
+        "patterns": {
+          "description": "Regular expressions matching the whole names of the metrics to be collected, in addition to the names",
+          "type": "array",
+          "items": {
+            "type": "string"
+          }
         }
       }
     },
@@ -752,6 +932,27 @@ func init() {
         }
       }
     },
+    "metrics-message": {
+      "type": "object",
+      "properties": {
+        "data": {
+          "description": "Metrics samples in the given format",
+          "type": "string"
+        },
+        "format": {
+          "description": "Format of the samples",
+          "type": "string",
+          "enum": [
+            "prometheus-text",
+            "openmetrics-text"
+          ]
+        },
+        "workload": {
+          "description": "Workload the samples are scraped from, empty for system metrics",
+          "type": "string"
+        }
+      }
+    },
     "metrics-retention": {
       "type": "object",
       "properties": {
@@ -787,6 +988,10 @@ func init() {
     "registration-info": {
       "type": "object",
       "properties": {
+        "attestation": {
+          "description": "TPM attestation of the device",
+          "$ref": "#/definitions/attestation"
+        },
         "certificate_request": {
           "description": "Certificate Signing Request to be signed by flotta-operator CA",
           "type": "string"
@@ -794,12 +999,20 @@ func init() {
         "hardware": {
           "description": "Hardware information",
           "$ref": "#/definitions/hardware-info"
+        },
+        "token": {
+          "description": "Registration token, <token name>.<secret>, consumed by the registration of a new device",
+          "type": "string"
         }
       }
     },
     "registration-response": {
       "type": "object",
       "properties": {
+        "attestation_challenge": {
+          "description": "Challenge to answer with TPM2_ActivateCredential before the device is registered",
+          "$ref": "#/definitions/attestation-challenge"
+        },
         "certificate": {
           "description": "Client certificate to be used in future operations",
           "type": "string"
@@ -860,6 +1073,23 @@ func init() {
         }
       }
     },
+    "syslog-tls-configuration": {
+      "type": "object",
+      "properties": {
+        "ca_bundle": {
+          "description": "PEM encoded CA certificates the syslog server is verified with",
+          "type": "string"
+        },
+        "client_certificate": {
+          "description": "PEM encoded certificate the device authenticates with",
+          "type": "string"
+        },
+        "client_key": {
+          "description": "PEM encoded key of the client certificate",
+          "type": "string"
+        }
+      }
+    },
     "system-metrics-configuration": {
       "type": "object",
       "properties": {
@@ -896,6 +1126,22 @@ func init() {
         }
       }
     },
+    "twin-properties": {
+      "type": "object",
+      "properties": {
+        "properties": {
+          "description": "Properties, by name",
+          "type": "object",
+          "additionalProperties": {
+            "type": "string"
+          }
+        },
+        "version": {
+          "description": "Version of the properties: a hash of the desired properties set by the operator, or any value set by the device for its reported properties",
+          "type": "string"
+        }
+      }
+    },
     "upgrade-status": {
       "type": "object",
       "properties": {
@@ -925,6 +1171,13 @@ func init() {
           "description": "Configuration for data transfer",
           "$ref": "#/definitions/data-configuration"
         },
+        "depends_on": {
+          "description": "Names of the workloads that have to be running before this workload is started",
+          "type": "array",
+          "items": {
+            "type": "string"
+          }
+        },
         "imageRegistries": {
           "description": "Image registries configuration",
           "$ref": "#/definitions/image-registries"
@@ -959,6 +1212,10 @@ func init() {
           "type": "string",
           "format": "date-time"
         },
+        "last_data_upload_error": {
+          "description": "Error of the latest data upload; empty when it succeeded",
+          "type": "string"
+        },
         "name": {
           "type": "string"
         },
@@ -968,7 +1225,8 @@ func init() {
             "deploying",
             "running",
             "crashed",
-            "stopped"
+            "stopped",
+            "unhealthy"
           ]
         }
       }
@@ -1169,6 +1427,9 @@ func init() {
           "404": {
             "description": "Error"
           },
+          "429": {
+            "description": "Too many requests"
+          },
           "500": {
             "description": "Error"
           }
@@ -1177,14 +1438,86 @@ func init() {
     }
   },
   "definitions": {
+    "attestation": {
+      "type": "object",
+      "properties": {
+        "activated_credential": {
+          "description": "Base64 encoded credential recovered from the attestation challenge with TPM2_ActivateCredential",
+          "type": "string"
+        },
+        "attestation_key": {
+          "description": "Base64 encoded TPMT_PUBLIC structure of the attestation key",
+          "type": "string"
+        },
+        "ek_certificate": {
+          "description": "PEM encoded endorsement key certificate of the TPM, followed by its intermediate CA certificates",
+          "type": "string"
+        },
+        "pcrs": {
+          "description": "Hex encoded values of the quoted SHA-256 PCRs, by index",
+          "type": "object",
+          "additionalProperties": {
+            "type": "string"
+          }
+        },
+        "quote": {
+          "description": "Base64 encoded TPMS_ATTEST structure of a quote of the SHA-256 PCRs by the attestation key",
+          "type": "string"
+        },
+        "quote_signature": {
+          "description": "Base64 encoded TPMT_SIGNATURE structure of the quote",
+          "type": "string"
+        }
+      }
+    },
+    "attestation-challenge": {
+      "type": "object",
+      "properties": {
+        "credential_blob": {
+          "description": "Base64 encoded TPM2B_ID_OBJECT credential blob to pass to TPM2_ActivateCredential",
+          "type": "string"
+        },
+        "encrypted_secret": {
+          "description": "Base64 encoded TPM2B_ENCRYPTED_SECRET to pass to TPM2_ActivateCredential",
+          "type": "string"
+        }
+      }
+    },
     "LogsCollectionInformationSyslogConfig": {
       "type": "object",
       "properties": {
         "address": {
           "type": "string"
         },
+        "facility": {
+          "description": "Facility of the messages",
+          "type": "string"
+        },
+        "format": {
+          "description": "Format of the messages",
+          "type": "string",
+          "enum": [
+            "rfc3164",
+            "rfc5424"
+          ]
+        },
+        "framing": {
+          "description": "Framing of the messages sent over TCP",
+          "type": "string",
+          "enum": [
+            "octet-counting",
+            "non-transparent"
+          ]
+        },
         "protocol": {
           "type": "string"
+        },
+        "tag": {
+          "description": "TAG of RFC 3164 messages and APP-NAME of RFC 5424 messages",
+          "type": "string"
+        },
+        "tls": {
+          "$ref": "#/definitions/syslog-tls-configuration"
         }
       }
     },
@@ -1249,11 +1582,50 @@ func init() {
     "data-configuration": {
       "type": "object",
       "properties": {
+        "bucket_prefix": {
+          "description": "Prefix prepended to all targets in the device bucket",
+          "type": "string"
+        },
+        "compression": {
+          "description": "Compression applied to files before upload",
+          "type": "string",
+          "enum": [
+            "none",
+            "gzip"
+          ]
+        },
+        "delete_after_upload": {
+          "description": "Delete files from the device once they were uploaded successfully",
+          "type": "boolean"
+        },
+        "exclude": {
+          "description": "Glob patterns of files that are never uploaded",
+          "type": "array",
+          "items": {
+            "type": "string"
+          }
+        },
+        "include": {
+          "description": "Glob patterns of files to upload; all files are uploaded when empty",
+          "type": "array",
+          "items": {
+            "type": "string"
+          }
+        },
+        "max_size_mib": {
+          "description": "Maximum size of data uploaded in a single run",
+          "type": "integer",
+          "format": "int32"
+        },
         "paths": {
           "type": "array",
           "items": {
             "$ref": "#/definitions/data-path"
           }
+        },
+        "schedule": {
+          "description": "Schedule of the data upload",
+          "$ref": "#/definitions/data-upload-schedule"
         }
       }
     },
@@ -1271,9 +1643,27 @@ func init() {
         }
       }
     },
+    "data-upload-schedule": {
+      "type": "object",
+      "properties": {
+        "cron": {
+          "description": "Cron expression defining upload times",
+          "type": "string"
+        },
+        "interval": {
+          "description": "Interval(in seconds) between uploads",
+          "type": "integer",
+          "format": "int32"
+        }
+      }
+    },
     "device-configuration": {
       "type": "object",
       "properties": {
+        "final_data_upload": {
+          "description": "Requests the device to upload the data of all workloads ahead of its decommissioning",
+          "type": "boolean"
+        },
         "heartbeat": {
           "$ref": "#/definitions/heartbeat-configuration"
         },
@@ -1302,6 +1692,10 @@ func init() {
         "configuration": {
           "$ref": "#/definitions/device-configuration"
         },
+        "desired_properties": {
+          "description": "Desired properties of the device twin",
+          "$ref": "#/definitions/twin-properties"
+        },
         "device_id": {
           "description": "Device identifier",
           "type": "string"
@@ -1484,6 +1878,10 @@ func init() {
     "heartbeat": {
       "type": "object",
       "properties": {
+        "desired_properties_version": {
+          "description": "Version of the desired properties of the device twin applied by the device",
+          "type": "string"
+        },
         "events": {
           "description": "Events produced by device worker.",
           "type": "array",
@@ -1495,6 +1893,10 @@ func init() {
           "description": "Hardware information",
           "$ref": "#/definitions/hardware-info"
         },
+        "reported_properties": {
+          "description": "Reported properties of the device twin",
+          "$ref": "#/definitions/twin-properties"
+        },
         "status": {
           "type": "string",
           "enum": [
@@ -1596,6 +1998,20 @@ func init() {
         }
       }
     },
+    "log-entry": {
+      "type": "object",
+      "properties": {
+        "message": {
+          "description": "Log line",
+          "type": "string"
+        },
+        "timestamp": {
+          "description": "Time the line was logged",
+          "type": "string",
+          "format": "date-time"
+        }
+      }
+    },
     "logs-collection-information": {
       "type": "object",
       "properties": {
@@ -1612,13 +2028,55 @@ func init() {
             "address": {
               "type": "string"
             },
+            "facility": {
+              "description": "Facility of the messages",
+              "type": "string"
+            },
+            "format": {
+              "description": "Format of the messages",
+              "type": "string",
+              "enum": [
+                "rfc3164",
+                "rfc5424"
+              ]
+            },
+            "framing": {
+              "description": "Framing of the messages sent over TCP",
+              "type": "string",
+              "enum": [
+                "octet-counting",
+                "non-transparent"
+              ]
+            },
             "protocol": {
               "type": "string"
+            },
+            "tag": {
+              "description": "TAG of RFC 3164 messages and APP-NAME of RFC 5424 messages",
+              "type": "string"
+            },
+            "tls": {
+              "$ref": "#/definitions/syslog-tls-configuration"
             }
           }
         }
       }
     },
+    "logs-message": {
+      "type": "object",
+      "properties": {
+        "entries": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/log-entry"
+          }
+        },
+        "workload": {
+          "description": "Workload the logs are collected from, empty for the logs of the device",
+          "type": "string"
+        }
+      }
+    },
     "memory": {
       "type": "object",
       "properties": {
@@ -1718,6 +2176,13 @@ func init() {
           "items": {
             "type": "string"
           }
+        },
+        "patterns": {
+          "description": "Regular expressions matching the whole names of the metrics to be collected, in addition to the names",
+          "type": "array",
+          "items": {
+            "type": "string"
+          }
         }
       }
     },
@@ -1735,6 +2200,27 @@ func init() {
         }
       }
     },
+    "metrics-message": {
+      "type": "object",
+      "properties": {
+        "data": {
+          "description": "Metrics samples in the given format",
+          "type": "string"
+        },
+        "format": {
+          "description": "Format of the samples",
+          "type": "string",
+          "enum": [
+            "prometheus-text",
+            "openmetrics-text"
+          ]
+        },
+        "workload": {
+          "description": "Workload the samples are scraped from, empty for system metrics",
+          "type": "string"
+        }
+      }
+    },
     "metrics-retention": {
       "type": "object",
       "properties": {
@@ -1770,6 +2256,10 @@ func init() {
     "registration-info": {
       "type": "object",
       "properties": {
+        "attestation": {
+          "description": "TPM attestation of the device",
+          "$ref": "#/definitions/attestation"
+        },
         "certificate_request": {
           "description": "Certificate Signing Request to be signed by flotta-operator CA",
           "type": "string"
@@ -1777,12 +2267,20 @@ func init() {
         "hardware": {
           "description": "Hardware information",
           "$ref": "#/definitions/hardware-info"
+        },
+        "token": {
+          "description": "Registration token, <token name>.<secret>, consumed by the registration of a new device",
+          "type": "string"
         }
       }
     },
     "registration-response": {
       "type": "object",
       "properties": {
+        "attestation_challenge": {
+          "description": "Challenge to answer with TPM2_ActivateCredential before the device is registered",
+          "$ref": "#/definitions/attestation-challenge"
+        },
         "certificate": {
           "description": "Client certificate to be used in future operations",
           "type": "string"
@@ -1843,6 +2341,23 @@ func init() {
         }
       }
     },
+    "syslog-tls-configuration": {
+      "type": "object",
+      "properties": {
+        "ca_bundle": {
+          "description": "PEM encoded CA certificates the syslog server is verified with",
+          "type": "string"
+        },
+        "client_certificate": {
+          "description": "PEM encoded certificate the device authenticates with",
+          "type": "string"
+        },
+        "client_key": {
+          "description": "PEM encoded key of the client certificate",
+          "type": "string"
+        }
+      }
+    },
     "system-metrics-configuration": {
       "type": "object",
       "properties": {
@@ -1879,6 +2394,22 @@ func init() {
         }
       }
     },
+    "twin-properties": {
+      "type": "object",
+      "properties": {
+        "properties": {
+          "description": "Properties, by name",
+          "type": "object",
+          "additionalProperties": {
+            "type": "string"
+          }
+        },
+        "version": {
+          "description": "Version of the properties: a hash of the desired properties set by the operator, or any value set by the device for its reported properties",
+          "type": "string"
+        }
+      }
+    },
     "upgrade-status": {
       "type": "object",
       "properties": {
@@ -1908,6 +2439,13 @@ func init() {
           "description": "Configuration for data transfer",
           "$ref": "#/definitions/data-configuration"
         },
+        "depends_on": {
+          "description": "Names of the workloads that have to be running before this workload is started",
+          "type": "array",
+          "items": {
+            "type": "string"
+          }
+        },
         "imageRegistries": {
           "description": "Image registries configuration",
           "$ref": "#/definitions/image-registries"
@@ -1942,6 +2480,10 @@ func init() {
           "type": "string",
           "format": "date-time"
         },
+        "last_data_upload_error": {
+          "description": "Error of the latest data upload; empty when it succeeded",
+          "type": "string"
+        },
         "name": {
           "type": "string"
         },
@@ -1951,7 +2493,8 @@ func init() {
             "deploying",
             "running",
             "crashed",
-            "stopped"
+            "stopped",
+            "unhealthy"
           ]
         }
       }
@@ -153,6 +153,30 @@ func (o *PostDataMessageForDeviceNotFound) WriteResponse(rw http.ResponseWriter,
 	rw.WriteHeader(404)
 }
 
+// PostDataMessageForDeviceTooManyRequestsCode is the HTTP code returned for type PostDataMessageForDeviceTooManyRequests
+const PostDataMessageForDeviceTooManyRequestsCode int = 429
+
+/*PostDataMessageForDeviceTooManyRequests Too many requests
+
+swagger:response postDataMessageForDeviceTooManyRequests
+*/
+type PostDataMessageForDeviceTooManyRequests struct {
+}
+
+// NewPostDataMessageForDeviceTooManyRequests creates PostDataMessageForDeviceTooManyRequests with default headers values
+func NewPostDataMessageForDeviceTooManyRequests() *PostDataMessageForDeviceTooManyRequests {
+
+	return &PostDataMessageForDeviceTooManyRequests{}
+}
+
+// WriteResponse to the client
+func (o *PostDataMessageForDeviceTooManyRequests) WriteResponse(rw http.ResponseWriter, producer runtime.Producer) {
+
+	rw.Header().Del(runtime.HeaderContentType) //Remove Content-Type on empty responses
+
+	rw.WriteHeader(429)
+}
+
 // PostDataMessageForDeviceInternalServerErrorCode is the HTTP code returned for type PostDataMessageForDeviceInternalServerError
 const PostDataMessageForDeviceInternalServerErrorCode int = 500
 